@@ -0,0 +1,90 @@
+// Package age encrypts report files in the age v1 format
+// (age-encryption.org/v1) to X25519 recipients, so they can be decrypted
+// with the age or rage tools and an identity from age-keygen. The format
+// itself is filippo.io/age, the reference implementation.
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// Ext is appended to the name of an encrypted file.
+const Ext = ".age"
+
+// Recipient is an X25519 public key, written age1... in Bech32.
+type Recipient struct {
+	key *age.X25519Recipient
+}
+
+// ParseRecipient parses an age1... recipient.
+func ParseRecipient(s string) (*Recipient, error) {
+	key, err := age.ParseX25519Recipient(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid age recipient %q: %v", s, err)
+	}
+	return &Recipient{key: key}, nil
+}
+
+// LoadRecipients reads a recipients file: one age1... key per line, with
+// blank lines and # comments ignored.
+func LoadRecipients(path string) ([]*Recipient, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []*Recipient
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := ParseRecipient(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		out = append(out, r)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%s: no recipients", path)
+	}
+	return out, nil
+}
+
+func (r *Recipient) String() string {
+	return r.key.String()
+}
+
+// Encrypt returns data encrypted to every recipient.
+func Encrypt(data []byte, recipients ...*Recipient) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("age: no recipients")
+	}
+	keys := make([]age.Recipient, len(recipients))
+	for i, r := range recipients {
+		keys[i] = r.key
+	}
+	var out bytes.Buffer
+	w, err := age.Encrypt(&out, keys...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
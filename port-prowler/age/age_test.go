@@ -0,0 +1,79 @@
+package age
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func newIdentity(t *testing.T) (*age.X25519Identity, *Recipient) {
+	t.Helper()
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := id.Recipient().String()
+	r, err := ParseRecipient(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.String() != s {
+		t.Fatalf("recipient %s round-tripped to %s", s, r)
+	}
+	return id, r
+}
+
+func decrypt(file []byte, id age.Identity) ([]byte, error) {
+	r, err := age.Decrypt(bytes.NewReader(file), id)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestEncrypt(t *testing.T) {
+	id1, r1 := newIdentity(t)
+	id2, r2 := newIdentity(t)
+	id3, _ := newIdentity(t)
+	big := bytes.Repeat([]byte("0123456789abcdef"), 64<<10/16*2) // exactly two chunks
+	for _, data := range [][]byte{nil, []byte(`{"hosts":[]}`), big, append(big, 'x')} {
+		enc, err := Encrypt(data, r1, r2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range []age.Identity{id1, id2} {
+			got, err := decrypt(enc, id)
+			if err != nil || !bytes.Equal(got, data) {
+				t.Fatalf("decrypt %d bytes: %d bytes, %v", len(data), len(got), err)
+			}
+		}
+		if _, err := decrypt(enc, id3); err == nil {
+			t.Error("a third identity decrypted the file")
+		}
+	}
+	if _, err := Encrypt([]byte("x")); err == nil {
+		t.Error("encrypting to nobody should fail")
+	}
+}
+
+func TestRecipients(t *testing.T) {
+	_, r := newIdentity(t)
+	s := r.String()
+	for _, bad := range []string{s[:len(s)-1] + "q", "age1" + strings.Repeat("q", 10), strings.Replace(s, "age1", "agf1", 1), s[:10] + strings.ToUpper(s[10:])} {
+		if _, err := ParseRecipient(bad); err == nil {
+			t.Errorf("ParseRecipient(%q) should fail", bad)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "recipients.txt")
+	os.WriteFile(path, []byte("# security team\n"+s+"\n\n"), 0o644)
+	rs, err := LoadRecipients(path)
+	if err != nil || len(rs) != 1 || rs[0].String() != s {
+		t.Errorf("LoadRecipients = %v, %v", rs, err)
+	}
+}
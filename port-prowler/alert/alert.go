@@ -0,0 +1,130 @@
+// Package alert decides which changes between two watch scans deserve an
+// alert, so monitoring is not paged for every trivial change.
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"portprowler/detector"
+	"portprowler/report"
+)
+
+// Metrics a rule can test besides the change kinds of report.Diff.
+const (
+	MetricChanges  = "changes"  // changes of any kind
+	MetricSeverity = "severity" // severity of ports that opened or changed
+)
+
+// Rule is one --alert rule: a count of changes compared with a threshold
+// ("opened>5", "changes>=10") or the least severity of a port that opened
+// or changed ("severity>=high").
+type Rule struct {
+	Metric   string // a report change kind, MetricChanges or MetricSeverity
+	Op       string // ">" or ">="
+	Count    int    // threshold of count rules
+	Severity string // threshold of severity rules
+}
+
+// Parse parses a rule written as metric, operator and threshold.
+func Parse(s string) (Rule, error) {
+	text := strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	i := strings.IndexByte(text, '>')
+	if i <= 0 {
+		return Rule{}, fmt.Errorf("invalid alert rule %q (e.g. opened>5 or severity>=high)", s)
+	}
+	r := Rule{Metric: strings.ToLower(text[:i]), Op: ">"}
+	value := text[i+1:]
+	if strings.HasPrefix(value, "=") {
+		r.Op, value = ">=", value[1:]
+	}
+	switch r.Metric {
+	case MetricSeverity:
+		r.Severity = strings.ToLower(value)
+		if detector.SeverityRank(r.Severity) < 0 {
+			return Rule{}, fmt.Errorf("alert rule %q: unknown severity %q (info, low, medium, high or critical)", s, value)
+		}
+	case MetricChanges, report.Opened, report.Closed, report.Changed, report.HostAdded, report.HostRemoved:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return Rule{}, fmt.Errorf("alert rule %q: threshold must be a count", s)
+		}
+		r.Count = n
+	default:
+		return Rule{}, fmt.Errorf("alert rule %q: unknown metric %q (changes, opened, closed, changed, host-added, host-removed or severity)", s, r.Metric)
+	}
+	return r, nil
+}
+
+func (r Rule) String() string {
+	if r.Metric == MetricSeverity {
+		return r.Metric + r.Op + r.Severity
+	}
+	return r.Metric + r.Op + strconv.Itoa(r.Count)
+}
+
+// Alert is a rule that fired.
+type Alert struct {
+	Rule    Rule
+	Reason  string          // e.g. "6 opened"
+	Changes []report.Change // the changes that made it fire
+}
+
+// Evaluate returns the alerts rules raise for changes, the differences
+// between the previous scan and cur. Severity rules read the severity of
+// each port from cur, so they need a scan with --context.
+func Evaluate(rules []Rule, changes []report.Change, cur report.ScanReport) []Alert {
+	var out []Alert
+	for _, r := range rules {
+		var hit []report.Change
+		if r.Metric == MetricSeverity {
+			hit = severe(r, changes, cur)
+			if len(hit) > 0 {
+				out = append(out, Alert{Rule: r, Reason: fmt.Sprintf("%d port(s) at %s severity or above", len(hit), r.Severity), Changes: hit})
+			}
+			continue
+		}
+		for _, c := range changes {
+			if r.Metric == MetricChanges || c.Kind == r.Metric {
+				hit = append(hit, c)
+			}
+		}
+		if len(hit) > r.Count || r.Op == ">=" && len(hit) == r.Count && len(hit) > 0 {
+			out = append(out, Alert{Rule: r, Reason: fmt.Sprintf("%d %s", len(hit), r.Metric), Changes: hit})
+		}
+	}
+	return out
+}
+
+// severe returns the opened and changed ports whose severity in cur
+// passes r.
+func severe(r Rule, changes []report.Change, cur report.ScanReport) []report.Change {
+	type key struct {
+		target string
+		port   uint16
+		proto  string
+	}
+	severity := make(map[key]string)
+	for _, h := range cur.Hosts {
+		name := h.Target
+		if name == "" {
+			name = h.IP
+		}
+		for _, res := range h.Results {
+			severity[key{name, res.Port, res.Proto}] = res.Severity
+		}
+	}
+	min := detector.SeverityRank(r.Severity)
+	var out []report.Change
+	for _, c := range changes {
+		if c.Kind != report.Opened && c.Kind != report.Changed {
+			continue
+		}
+		rank := detector.SeverityRank(severity[key{c.Target, c.Port, c.Proto}])
+		if rank > min || r.Op == ">=" && rank == min {
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+package alert
+
+import (
+	"testing"
+
+	"portprowler/port"
+	"portprowler/report"
+)
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]string{
+		"opened>5":          "opened>5",
+		" changes >= 10 ":   "changes>=10",
+		"Severity>=HIGH":    "severity>=high",
+		"host-removed>0":    "host-removed>0",
+		"severity>critical": "severity>critical",
+	} {
+		r, err := Parse(in)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", in, err)
+		}
+		if r.String() != want {
+			t.Errorf("Parse(%q) = %s, want %s", in, r, want)
+		}
+	}
+	for _, bad := range []string{"opened", "opened<5", "opened>many", "opened>-1", "ports>5", "severity>=urgent", ">5"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) should fail", bad)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	cur := report.ScanReport{Hosts: []report.HostReport{{
+		Target: "db.example",
+		Results: []port.PortResult{
+			{Port: 22, Proto: "tcp", State: "open", Severity: "medium"},
+			{Port: 6379, Proto: "tcp", State: "open", Severity: "critical"},
+			{Port: 8080, Proto: "tcp", State: "open", Severity: "info"},
+		},
+	}}}
+	changes := []report.Change{
+		{Kind: report.Opened, Target: "db.example", Port: 22, Proto: "tcp"},
+		{Kind: report.Opened, Target: "db.example", Port: 6379, Proto: "tcp"},
+		{Kind: report.Changed, Target: "db.example", Port: 8080, Proto: "tcp"},
+		{Kind: report.Closed, Target: "db.example", Port: 443, Proto: "tcp"},
+	}
+	rules := func(list ...string) []Rule {
+		var out []Rule
+		for _, s := range list {
+			r, err := Parse(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, r)
+		}
+		return out
+	}
+
+	if got := Evaluate(rules("opened>2", "closed>1", "changes>4"), changes, cur); len(got) != 0 {
+		t.Errorf("thresholds not exceeded, got %+v", got)
+	}
+	got := Evaluate(rules("opened>=2", "changes>3"), changes, cur)
+	if len(got) != 2 || got[0].Reason != "2 opened" || len(got[1].Changes) != 4 {
+		t.Errorf("count rules: %+v", got)
+	}
+	got = Evaluate(rules("severity>=high"), changes, cur)
+	if len(got) != 1 || len(got[0].Changes) != 1 || got[0].Changes[0].Port != 6379 {
+		t.Errorf("severity>=high: %+v", got)
+	}
+	if got = Evaluate(rules("severity>=medium"), changes, cur); len(got) != 1 || len(got[0].Changes) != 2 {
+		t.Errorf("severity>=medium: %+v", got)
+	}
+	if got = Evaluate(rules("changes>=0"), nil, cur); len(got) != 0 {
+		t.Errorf("no changes raised %+v", got)
+	}
+}
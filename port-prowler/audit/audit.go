@@ -0,0 +1,158 @@
+// Package audit writes an evidence trail of every probe and connection a
+// scan makes, one JSON object per line.
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"portprowler/netutil"
+	"portprowler/port"
+)
+
+// Record is one line of the audit log.
+type Record struct {
+	Time      time.Time `json:"ts"`               // when the probe was sent
+	Kind      string    `json:"kind"`             // tcp-connect, udp, syn, icmp-echo, udp-ping, tcp-ping, detect
+	Target    string    `json:"target,omitempty"` // target name the destination was scanned as
+	Proto     string    `json:"proto"`            // transport on the wire: tcp, udp or icmp
+	Src       string    `json:"src,omitempty"`    // local ip:port, or ip when the port is unknown
+	Dst       string    `json:"dst"`              // ip:port, or ip for host probes
+	Via       string    `json:"via,omitempty"`    // proxy chain or jump host the probe went through
+	Outcome   string    `json:"outcome"`          // port state, or connected/failed for detection
+	Error     string    `json:"error,omitempty"`
+	RTTMillis int64     `json:"rtt_ms"`
+	Banner    string    `json:"banner,omitempty"` // greeting the port sent, kept for replay
+}
+
+// Log appends records to a writer. It is safe for concurrent use by the
+// scan workers.
+type Log struct {
+	mu   sync.Mutex
+	enc  *json.Encoder
+	c    io.Closer
+	via  string
+	srcs map[string]string // destination ip -> route source ip
+	err  error
+}
+
+// New returns a Log writing to w. via names the proxy chain or jump host
+// probes go through, or is empty for direct scans.
+func New(w io.Writer, via string) *Log {
+	return &Log{enc: json.NewEncoder(w), via: via, srcs: make(map[string]string)}
+}
+
+// Open appends to the audit file at path, creating it if needed, so the
+// trail of earlier scans is kept.
+func Open(path, via string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	l := New(f, via)
+	l.c = f
+	return l, nil
+}
+
+// Write fills in Via and, when the probe did not reveal its local
+// address, the source address the kernel routes Dst from.
+func (l *Log) Write(r Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r.Via = l.via
+	if r.Src == "" {
+		r.Src = l.routeSource(r.Dst)
+	}
+	if err := l.enc.Encode(r); err != nil && l.err == nil {
+		l.err = err
+	}
+}
+
+// Probe records a scan probe sent at start from its result.
+func (l *Log) Probe(start time.Time, res port.PortResult) {
+	r := Record{
+		Time:      start.UTC(),
+		Target:    res.Target,
+		Src:       res.LocalAddr,
+		Dst:       net.JoinHostPort(res.IP, strconv.Itoa(int(res.Port))),
+		Outcome:   res.State,
+		Error:     res.Error,
+		RTTMillis: res.RTTMillis,
+		Banner:    res.ServiceBanner,
+	}
+	switch res.Proto {
+	case string(port.ScanTCP):
+		r.Kind, r.Proto = "tcp-connect", "tcp"
+	case string(port.ScanUDP):
+		r.Kind, r.Proto = "udp", "udp"
+	case string(port.ScanStealth):
+		r.Kind, r.Proto = "syn", "tcp"
+	case string(port.ScanPing):
+		r.Kind, r.Proto, r.Dst = res.Service, "icmp", res.IP
+		if res.Service == "udp-ping" {
+			r.Proto = "udp"
+		}
+	default:
+		r.Kind, r.Proto = res.Proto, res.Proto
+	}
+	l.Write(r)
+}
+
+// Conn records a TCP connection opened outside the port probes, e.g. for
+// service detection. conn is nil when the dial failed with err.
+func (l *Log) Conn(kind string, start time.Time, addr string, conn net.Conn, err error) {
+	r := Record{
+		Time:      start.UTC(),
+		Kind:      kind,
+		Proto:     "tcp",
+		Dst:       addr,
+		Outcome:   "connected",
+		RTTMillis: time.Since(start).Milliseconds(),
+	}
+	if conn != nil {
+		r.Src = conn.LocalAddr().String()
+	}
+	if err != nil {
+		r.Outcome = "failed"
+		r.Error = err.Error()
+	}
+	l.Write(r)
+}
+
+// Close closes the underlying file and reports the first write error.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err := l.err
+	if l.c != nil {
+		if cerr := l.c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// routeSource returns the local address used to reach dst's host, cached
+// per destination; the caller holds l.mu.
+func (l *Log) routeSource(dst string) string {
+	host, _, err := net.SplitHostPort(dst)
+	if err != nil {
+		host = dst
+	}
+	if src, ok := l.srcs[host]; ok {
+		return src
+	}
+	var src string
+	if ip := net.ParseIP(host); ip != nil {
+		if rt, err := netutil.RouteTo(ip); err == nil {
+			src = rt.Src.String()
+		}
+	}
+	l.srcs[host] = src
+	return src
+}
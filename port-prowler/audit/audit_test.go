@@ -0,0 +1,47 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"portprowler/port"
+)
+
+func TestProbeRecords(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, "socks5://10.0.0.1:1080")
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	l.Probe(start, port.PortResult{IP: "127.0.0.1", Port: 22, Proto: "tcp", State: "open", RTTMillis: 3, LocalAddr: "127.0.0.1:40000"})
+	l.Probe(start, port.PortResult{IP: "127.0.0.1", Proto: "ping", Service: "icmp-echo", State: "up"})
+	l.Conn("detect", start, "127.0.0.1:443", nil, errors.New("connection refused"))
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d records, want 3:\n%s", len(lines), buf.String())
+	}
+	var recs []Record
+	for _, line := range lines {
+		var r Record
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("bad record %q: %v", line, err)
+		}
+		recs = append(recs, r)
+	}
+	want := Record{Time: start, Kind: "tcp-connect", Proto: "tcp", Src: "127.0.0.1:40000", Dst: "127.0.0.1:22", Via: "socks5://10.0.0.1:1080", Outcome: "open", RTTMillis: 3}
+	if recs[0] != want {
+		t.Errorf("tcp record = %+v, want %+v", recs[0], want)
+	}
+	if r := recs[1]; r.Kind != "icmp-echo" || r.Proto != "icmp" || r.Dst != "127.0.0.1" || r.Src != "127.0.0.1" {
+		t.Errorf("ping record = %+v", r)
+	}
+	if r := recs[2]; r.Kind != "detect" || r.Outcome != "failed" || r.Error != "connection refused" {
+		t.Errorf("detect record = %+v", r)
+	}
+}
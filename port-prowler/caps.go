@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"portprowler/netutil"
+)
+
+// runCaps implements `portprowler caps`: a preflight report of what the
+// current process can do and which scan modes will therefore work.
+func runCaps(w io.Writer) int {
+	caps := netutil.Capabilities()
+	fmt.Fprintf(w, "Platform: %s (euid %d)\n", netutil.Platform(), os.Geteuid())
+
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "CAPABILITY\tAVAILABLE\tDETAIL")
+	for _, c := range caps {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Name, yesNo(c.Available), c.Detail)
+	}
+	_ = tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCAN MODE\tAVAILABLE\tREASON")
+	for _, m := range netutil.ScanModes(caps) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", m.Mode, yesNo(m.Available), m.Reason)
+	}
+	_ = tw.Flush()
+	return 0
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"portprowler/netutil"
+	"portprowler/scanner"
+)
+
+// Monitoring plugin exit statuses (Nagios, Icinga, Sensu, ...).
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+var checkStatusNames = [...]string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// runCheck implements `portprowler check`, a monitoring plugin: one tcp
+// connect to --host:--port, reported as a single OK/WARNING/CRITICAL line
+// with performance data and the matching exit status. Bad flags are
+// UNKNOWN (3) rather than the usual usage status, which plugins reserve
+// for CRITICAL.
+func runCheck(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	host := fs.String("host", "", "host name or address to check (required)")
+	portNum := fs.Uint("port", 0, "tcp port to check (required)")
+	expect := fs.String("expect", "open", "state that is OK: open, closed or filtered; anything else is CRITICAL")
+	warnRTT := fs.Duration("warn-rtt", 0, "WARNING when the connect takes longer than this (e.g. 200ms)")
+	critRTT := fs.Duration("crit-rtt", 0, "CRITICAL when the connect takes longer than this (e.g. 1s)")
+	timeout := fs.Duration("t", 10*time.Second, "connect timeout; a port that does not answer in time is filtered")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s check --host <host> --port <port> [flags]\n", progName())
+		fs.PrintDefaults()
+	}
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return checkUnknown
+	}
+
+	unknown := func(format string, a ...any) int {
+		return checkResult(checkUnknown, fmt.Sprintf(format, a...), "")
+	}
+	switch {
+	case len(names) > 0:
+		return unknown("check takes no arguments; use --host and --port")
+	case *host == "":
+		return unknown("--host is required")
+	case *portNum == 0 || *portNum > 65535:
+		return unknown("--port must be between 1 and 65535")
+	case *timeout <= 0:
+		return unknown("-t must be positive")
+	case *warnRTT < 0 || *critRTT < 0:
+		return unknown("--warn-rtt and --crit-rtt must not be negative")
+	case *warnRTT > 0 && *critRTT > 0 && *warnRTT > *critRTT:
+		return unknown("--warn-rtt must not exceed --crit-rtt")
+	}
+	switch *expect {
+	case "open", "closed", "filtered":
+	default:
+		return unknown("--expect must be open, closed or filtered")
+	}
+
+	target := net.JoinHostPort(*host, strconv.FormatUint(uint64(*portNum), 10))
+	addrs, err := netutil.ResolveTarget(*host, netutil.ResolveOptions{DualStack: true})
+	if err != nil {
+		return checkResult(checkCritical, fmt.Sprintf("%s: cannot resolve: %v", target, err), "")
+	}
+	// Time the connect here: RTTMillis is too coarse for local services.
+	d := &timedDialer{}
+	res := scanner.TCPScanVia(context.Background(), d, addrs[0], uint16(*portNum), *timeout, false)
+	rtt := d.took.Round(time.Microsecond)
+
+	status := checkOK
+	msg := fmt.Sprintf("%s %s", target, res.State)
+	switch {
+	case res.State != *expect:
+		status = checkCritical
+		msg += fmt.Sprintf(" (expected %s)", *expect)
+	case res.State == "filtered":
+		// No answer, so no round trip to judge.
+	case *critRTT > 0 && rtt > *critRTT:
+		status = checkCritical
+		msg += fmt.Sprintf(", rtt %v > %v", rtt, *critRTT)
+	case *warnRTT > 0 && rtt > *warnRTT:
+		status = checkWarning
+		msg += fmt.Sprintf(", rtt %v > %v", rtt, *warnRTT)
+	default:
+		msg += fmt.Sprintf(", rtt %v", rtt)
+	}
+	if res.IP != *host {
+		msg += " ip=" + res.IP
+	}
+	perf := fmt.Sprintf("rtt=%.6fs;%s;%s;0;%.6f", rtt.Seconds(), perfThreshold(*warnRTT), perfThreshold(*critRTT), timeout.Seconds())
+	return checkResult(status, msg, perf)
+}
+
+// checkResult prints the plugin output line and returns status.
+func checkResult(status int, msg, perf string) int {
+	line := fmt.Sprintf("PORTPROWLER %s - %s", checkStatusNames[status], msg)
+	if perf != "" {
+		line += " | " + perf
+	}
+	fmt.Println(line)
+	return status
+}
+
+// perfThreshold formats a threshold for performance data; unset is empty.
+func perfThreshold(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.6f", d.Seconds())
+}
+
+// timedDialer dials directly and records how long the connect took.
+type timedDialer struct {
+	net.Dialer
+	took time.Duration
+}
+
+func (d *timedDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	start := time.Now()
+	conn, err := d.Dialer.DialContext(ctx, network, addr)
+	d.took = time.Since(start)
+	return conn, err
+}
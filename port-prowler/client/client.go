@@ -0,0 +1,188 @@
+// Package client is a Go client for the scan API of `portprowler serve`
+// (see package server):
+//
+//	c := client.New("https://scanner:8700", os.Getenv("PORTPROWLER_TOKEN"))
+//	st, err := c.StartScan(ctx, server.Request{Targets: []string{"10.0.0.0/24"}, Ports: "22,443", TCP: true})
+//	...
+//	err = c.StreamResults(ctx, st.ID, func(r port.PortResult) error {
+//		fmt.Println(r.Target, r.Port, r.State)
+//		return nil
+//	})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"portprowler/port"
+	"portprowler/report"
+	"portprowler/server"
+)
+
+// Client calls one API server. Its zero HTTP uses http.DefaultClient.
+type Client struct {
+	URL   string // e.g. "http://127.0.0.1:8700"
+	Token string // sent as a bearer token when set
+	HTTP  *http.Client
+}
+
+// New returns a client for the server at baseURL.
+func New(baseURL, token string) *Client {
+	return &Client{URL: strings.TrimRight(baseURL, "/"), Token: token}
+}
+
+// Error is a failed API call: the server's status code and message.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("portprowler API: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// StartScan submits a scan and returns its status, queued or running.
+func (c *Client) StartScan(ctx context.Context, req server.Request) (server.Status, error) {
+	var st server.Status
+	err := c.call(ctx, http.MethodPost, "/scans", req, &st)
+	return st, err
+}
+
+// GetScan returns a scan's status, with its report once it has ended.
+func (c *Client) GetScan(ctx context.Context, id string) (server.Status, error) {
+	var st server.Status
+	err := c.call(ctx, http.MethodGet, "/scans/"+url.PathEscape(id), nil, &st)
+	return st, err
+}
+
+// ListScans returns every scan, oldest first, without reports.
+func (c *Client) ListScans(ctx context.Context) ([]server.Status, error) {
+	var out []server.Status
+	err := c.call(ctx, http.MethodGet, "/scans", nil, &out)
+	return out, err
+}
+
+// CancelScan cancels a queued or running scan.
+func (c *Client) CancelScan(ctx context.Context, id string) error {
+	return c.call(ctx, http.MethodDelete, "/scans/"+url.PathEscape(id), nil, nil)
+}
+
+// GetDiff returns the changes from scan base to scan id.
+func (c *Client) GetDiff(ctx context.Context, id, base string) ([]report.Change, error) {
+	var out []report.Change
+	err := c.call(ctx, http.MethodGet, "/scans/"+url.PathEscape(id)+"/diff?base="+url.QueryEscape(base), nil, &out)
+	return out, err
+}
+
+// Wait polls a scan every interval until it is no longer queued or
+// running, and returns its final status.
+func (c *Client) Wait(ctx context.Context, id string, interval time.Duration) (server.Status, error) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		st, err := c.GetScan(ctx, id)
+		if err != nil || (st.State != server.Queued && st.State != server.Running) {
+			return st, err
+		}
+		select {
+		case <-ctx.Done():
+			return st, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// StreamResults calls fn with each of a scan's results as the server
+// finds them, starting with those found already, and returns when the
+// scan ends, ctx is done or fn returns an error.
+func (c *Client) StreamResults(ctx context.Context, id string, fn func(port.PortResult) error) error {
+	resp, err := c.do(ctx, http.MethodGet, "/scans/"+url.PathEscape(id)+"/results", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var r port.PortResult
+		if err := dec.Decode(&r); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("portprowler API: reading results: %w", err)
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+}
+
+// call sends body as JSON and decodes the answer into out, if any.
+func (c *Client) call(ctx context.Context, method, path string, body, out any) error {
+	var rd io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(data)
+	}
+	resp, err := c.do(ctx, method, path, rd)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("portprowler API: decoding %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// do sends a request and turns error statuses into *Error.
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	hc := c.HTTP
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	var msg struct {
+		Error string `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(data, &msg) == nil && msg.Error != "" {
+		apiErr.Message = msg.Error
+	} else {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	return nil, apiErr
+}
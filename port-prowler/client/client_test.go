@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"portprowler/port"
+	"portprowler/report"
+	"portprowler/server"
+)
+
+func TestClient(t *testing.T) {
+	api := server.New(func(req server.Request) (server.Job, error) {
+		return func(ctx context.Context) (report.ScanReport, error) {
+			r := port.PortResult{Target: req.Targets[0], IP: req.Targets[0], Port: 22, Proto: "tcp", State: "open"}
+			if req.Note == "closed" {
+				r.State = "closed"
+			}
+			server.ResultFunc(ctx)(r)
+			return report.Build(report.Meta{Note: req.Note}, []port.Target{{Name: r.Target, IP: r.IP}}, []port.PortResult{r}), nil
+		}, nil
+	})
+	tokens, _ := server.ParseTokens(strings.NewReader("ci scan c-token\n"))
+	api.RequireTokens(tokens)
+	defer api.Shutdown(context.Background())
+	ts := httptest.NewServer(api)
+	defer ts.Close()
+	ctx := context.Background()
+
+	if _, err := New(ts.URL, "wrong").ListScans(ctx); !isStatus(err, http.StatusUnauthorized) {
+		t.Fatalf("wrong token: %v", err)
+	}
+	c := New(ts.URL+"/", "c-token")
+	first, err := c.StartScan(ctx, server.Request{Targets: []string{"192.0.2.1"}, Ports: "22", TCP: true, Note: "closed"})
+	if err != nil || first.ID != "1" {
+		t.Fatalf("StartScan = %+v, %v", first, err)
+	}
+	if st, err := c.Wait(ctx, first.ID, 5*time.Millisecond); err != nil || st.State != server.Done || st.Report == nil {
+		t.Fatalf("Wait = %+v, %v", st, err)
+	}
+	second, _ := c.StartScan(ctx, server.Request{Targets: []string{"192.0.2.1"}, Ports: "22", TCP: true})
+	var got []port.PortResult
+	err = c.StreamResults(ctx, second.ID, func(r port.PortResult) error {
+		got = append(got, r)
+		return nil
+	})
+	if err != nil || len(got) != 1 || got[0].State != "open" {
+		t.Fatalf("StreamResults = %+v, %v", got, err)
+	}
+	changes, err := c.GetDiff(ctx, second.ID, first.ID)
+	if err != nil || len(changes) != 1 || changes[0].Kind != report.Opened {
+		t.Errorf("GetDiff = %+v, %v", changes, err)
+	}
+	if scans, err := c.ListScans(ctx); err != nil || len(scans) != 2 {
+		t.Errorf("ListScans = %d scans, %v", len(scans), err)
+	}
+
+	if _, err := c.StartScan(ctx, server.Request{}); !isStatus(err, http.StatusBadRequest) || !strings.Contains(err.Error(), "targets is required") {
+		t.Errorf("empty request: %v", err)
+	}
+	if err := c.CancelScan(ctx, first.ID); !isStatus(err, http.StatusConflict) {
+		t.Errorf("cancelling a finished scan: %v", err)
+	}
+	if _, err := c.GetScan(ctx, "9"); !isStatus(err, http.StatusNotFound) {
+		t.Errorf("missing scan: %v", err)
+	}
+}
+
+func isStatus(err error, code int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
@@ -0,0 +1,317 @@
+// Package cloud reads instance inventories exported from AWS, GCP and
+// Azure and attaches the instance behind each scanned address (ID,
+// account, region, tags) to a report, so exposure reports name owners.
+package cloud
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"portprowler/report"
+)
+
+// Providers.
+const (
+	AWS   = "aws"
+	GCP   = "gcp"
+	Azure = "azure"
+)
+
+// Inventory maps addresses to the instances that hold them.
+type Inventory map[string]*report.CloudInstance
+
+// record holds the fields of every record shape Read understands: EC2
+// instances (`aws ec2 describe-instances`, also flattened with --query
+// 'Reservations[].Instances[]'), Compute Engine instances (`gcloud compute
+// instances list --format=json`), Azure VMs (`az vm list -d`) and
+// portprowler's own generic shape.
+type record struct {
+	// AWS
+	Reservations []struct {
+		OwnerID   string            `json:"OwnerId"`
+		Instances []json.RawMessage `json:"Instances"`
+	} `json:"Reservations"`
+	InstanceID       string `json:"InstanceId"`
+	PrivateIPAddress string `json:"PrivateIpAddress"`
+	PublicIPAddress  string `json:"PublicIpAddress"`
+	Placement        struct {
+		AvailabilityZone string `json:"AvailabilityZone"`
+	} `json:"Placement"`
+	NetworkInterfaces []struct {
+		OwnerID            string `json:"OwnerId"`
+		PrivateIPAddresses []struct {
+			PrivateIPAddress string `json:"PrivateIpAddress"`
+			Association      struct {
+				PublicIP string `json:"PublicIp"`
+			} `json:"Association"`
+		} `json:"PrivateIpAddresses"`
+		IPv6Addresses []struct {
+			IPv6Address string `json:"Ipv6Address"`
+		} `json:"Ipv6Addresses"`
+	} `json:"NetworkInterfaces"`
+
+	// GCP
+	SelfLink      string `json:"selfLink"`
+	Zone          string `json:"zone"`
+	GCPInterfaces []struct {
+		NetworkIP         string                   `json:"networkIP"`
+		IPv6Address       string                   `json:"ipv6Address"`
+		AccessConfigs     []struct{ NatIP string } `json:"accessConfigs"`
+		IPv6AccessConfigs []struct {
+			ExternalIPv6 string `json:"externalIpv6"`
+		} `json:"ipv6AccessConfigs"`
+	} `json:"networkInterfaces"`
+	Labels map[string]string `json:"labels"`
+
+	// Azure
+	Location   string `json:"location"`
+	PrivateIPs string `json:"privateIps"` // comma-separated
+	PublicIPs  string `json:"publicIps"`
+
+	// Azure, GCP and generic
+	ID   json.RawMessage `json:"id"` // a number in GCP exports
+	Name string          `json:"name"`
+	Tags json.RawMessage `json:"tags"` // AWS Tags key/value list or a map
+
+	// Generic
+	Provider string   `json:"provider"`
+	Account  string   `json:"account"`
+	Region   string   `json:"region"`
+	IPs      []string `json:"ips"`
+}
+
+// Load reads and merges the inventory files at paths.
+func Load(paths ...string) (Inventory, error) {
+	inv := make(Inventory)
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		part, err := Read(f)
+		f.Close()
+		if err != nil {
+			return nil, errors.New(p + ": " + err.Error())
+		}
+		for ip, in := range part {
+			inv[ip] = in
+		}
+	}
+	return inv, nil
+}
+
+// Read parses an AWS, GCP or Azure instance export, or JSON Lines or an
+// array of generic records ({"provider", "id", "account", "region",
+// "name", "tags", "ips"}), optionally gzip-compressed. Records of other
+// shapes are skipped; an export without any is an error.
+func Read(r io.Reader) (Inventory, error) {
+	br := bufio.NewReader(r)
+	if head, _ := br.Peek(2); bytes.Equal(head, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+	inv := make(Inventory)
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if err := inv.walk(raw, "", 0); err != nil {
+			return nil, err
+		}
+	}
+	if len(inv) == 0 {
+		return nil, errors.New("no AWS, GCP or Azure instances with addresses found")
+	}
+	return inv, nil
+}
+
+// maxDepth bounds nesting (arrays of arrays, reservations).
+const maxDepth = 4
+
+func (inv Inventory) walk(raw json.RawMessage, account string, depth int) error {
+	if depth > maxDepth {
+		return nil
+	}
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return err
+		}
+		for _, it := range items {
+			if err := inv.walk(it, account, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(raw) == 0 || raw[0] != '{' {
+		return nil
+	}
+	var rec record
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return err
+	}
+	switch {
+	case rec.Reservations != nil:
+		for _, res := range rec.Reservations {
+			for _, it := range res.Instances {
+				if err := inv.walk(it, res.OwnerID, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+	case rec.InstanceID != "":
+		inv.aws(rec, account)
+	case rec.SelfLink != "" && rec.GCPInterfaces != nil:
+		inv.gcp(rec)
+	case rec.PrivateIPs != "" || rec.PublicIPs != "":
+		inv.azure(rec)
+	case rec.Provider != "" && len(rec.IPs) > 0:
+		in := &report.CloudInstance{Provider: rec.Provider, ID: str(rec.ID), Name: rec.Name, Account: rec.Account, Region: rec.Region, Tags: tagMap(rec.Tags)}
+		inv.add(in, rec.IPs...)
+	}
+	return nil
+}
+
+func (inv Inventory) aws(rec record, account string) {
+	in := &report.CloudInstance{Provider: AWS, ID: rec.InstanceID, Account: account, Zone: rec.Placement.AvailabilityZone, Tags: tagMap(rec.Tags)}
+	// A zone is its region and a letter (us-east-1a), or for local zones
+	// the region and a suffix (us-west-2-lax-1a).
+	if parts := strings.SplitN(in.Zone, "-", 4); len(parts) >= 3 {
+		in.Region = strings.TrimRight(strings.Join(parts[:3], "-"), "abcdefghijklmnopqrstuvwxyz")
+	}
+	in.Name = in.Tags["Name"]
+	ips := []string{rec.PrivateIPAddress, rec.PublicIPAddress}
+	for _, ni := range rec.NetworkInterfaces {
+		if in.Account == "" {
+			in.Account = ni.OwnerID
+		}
+		for _, a := range ni.PrivateIPAddresses {
+			ips = append(ips, a.PrivateIPAddress, a.Association.PublicIP)
+		}
+		for _, a := range ni.IPv6Addresses {
+			ips = append(ips, a.IPv6Address)
+		}
+	}
+	inv.add(in, ips...)
+}
+
+func (inv Inventory) gcp(rec record) {
+	in := &report.CloudInstance{Provider: GCP, ID: str(rec.ID), Name: rec.Name, Tags: rec.Labels}
+	// selfLink: .../projects/<project>/zones/<zone>/instances/<name>
+	if _, rest, ok := strings.Cut(rec.SelfLink, "/projects/"); ok {
+		in.Account, _, _ = strings.Cut(rest, "/")
+	}
+	in.Zone = rec.Zone[strings.LastIndexByte(rec.Zone, '/')+1:]
+	if i := strings.LastIndexByte(in.Zone, '-'); i > 0 {
+		in.Region = in.Zone[:i]
+	}
+	var ips []string
+	for _, ni := range rec.GCPInterfaces {
+		ips = append(ips, ni.NetworkIP, ni.IPv6Address)
+		for _, ac := range ni.AccessConfigs {
+			ips = append(ips, ac.NatIP)
+		}
+		for _, ac := range ni.IPv6AccessConfigs {
+			ips = append(ips, ac.ExternalIPv6)
+		}
+	}
+	inv.add(in, ips...)
+}
+
+func (inv Inventory) azure(rec record) {
+	id := str(rec.ID)
+	in := &report.CloudInstance{Provider: Azure, ID: id, Name: rec.Name, Region: rec.Location, Tags: tagMap(rec.Tags)}
+	// id: /subscriptions/<subscription>/resourceGroups/<group>/providers/...
+	if _, rest, ok := strings.Cut(id, "/subscriptions/"); ok {
+		in.Account, _, _ = strings.Cut(rest, "/")
+	}
+	inv.add(in, append(strings.Split(rec.PrivateIPs, ","), strings.Split(rec.PublicIPs, ",")...)...)
+}
+
+func (inv Inventory) add(in *report.CloudInstance, ips ...string) {
+	if len(in.Tags) == 0 {
+		in.Tags = nil
+	}
+	for _, s := range ips {
+		if ip := net.ParseIP(strings.TrimSpace(s)); ip != nil {
+			inv[ip.String()] = in
+		}
+	}
+}
+
+// str returns a JSON string or number as a string.
+func str(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(bytes.TrimSpace(raw))
+}
+
+// tagMap decodes tags given as an AWS [{"Key", "Value"}] list or a map.
+func tagMap(raw json.RawMessage) map[string]string {
+	var m map[string]string
+	if json.Unmarshal(raw, &m) == nil {
+		return m
+	}
+	var list []struct{ Key, Value string }
+	if json.Unmarshal(raw, &list) != nil || len(list) == 0 {
+		return nil
+	}
+	m = make(map[string]string, len(list))
+	for _, t := range list {
+		m[t.Key] = t.Value
+	}
+	return m
+}
+
+// Lookup returns the instance holding ip, or nil.
+func (inv Inventory) Lookup(ip string) *report.CloudInstance {
+	if p := net.ParseIP(ip); p != nil {
+		ip = p.String()
+	}
+	return inv[ip]
+}
+
+// Tag sets the Cloud of every host whose address is in the inventory
+// and returns how many it tagged. A host with several addresses takes
+// the instance of the first one found.
+func (inv Inventory) Tag(rep *report.ScanReport) int {
+	n := 0
+	for i := range rep.Hosts {
+		h := &rep.Hosts[i]
+		addrs := h.Addrs
+		if len(addrs) == 0 {
+			addrs = []string{h.IP}
+		}
+		for _, a := range addrs {
+			if in := inv.Lookup(a); in != nil {
+				c := *in
+				h.Cloud = &c
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
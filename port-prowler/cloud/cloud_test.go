@@ -0,0 +1,94 @@
+package cloud
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"portprowler/port"
+	"portprowler/report"
+)
+
+const awsExport = `{"Reservations":[{"OwnerId":"123456789012","Instances":[{
+  "InstanceId":"i-0abc","PrivateIpAddress":"10.0.1.5","PublicIpAddress":"203.0.113.5",
+  "Placement":{"AvailabilityZone":"eu-west-1b"},
+  "Tags":[{"Key":"Name","Value":"web-1"},{"Key":"owner","Value":"team-web"}],
+  "NetworkInterfaces":[{"OwnerId":"123456789012","PrivateIpAddresses":[{"PrivateIpAddress":"10.0.1.6"}],
+    "Ipv6Addresses":[{"Ipv6Address":"2001:db8::5"}]}]}]}]}`
+
+const gcpExport = `[{"id":"4567890123","name":"db-1","zone":"https://www.googleapis.com/compute/v1/projects/shop-prod/zones/us-central1-a",
+  "selfLink":"https://www.googleapis.com/compute/v1/projects/shop-prod/zones/us-central1-a/instances/db-1",
+  "labels":{"team":"data"},
+  "networkInterfaces":[{"networkIP":"10.128.0.7","accessConfigs":[{"natIP":"198.51.100.7"}]}]}]`
+
+const azureExport = `[{"id":"/subscriptions/0000-1111/resourceGroups/RG/providers/Microsoft.Compute/virtualMachines/vm1",
+  "name":"vm1","location":"westeurope","privateIps":"10.2.0.4,10.2.0.5","publicIps":"","tags":{"owner":"ops"}}]`
+
+func TestRead(t *testing.T) {
+	inv, err := Read(strings.NewReader(awsExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := inv.Lookup("203.0.113.5")
+	if in == nil || in.ID != "i-0abc" || in.Account != "123456789012" || in.Region != "eu-west-1" ||
+		in.Zone != "eu-west-1b" || in.Name != "web-1" || in.Tags["owner"] != "team-web" {
+		t.Fatalf("unexpected AWS instance %+v", in)
+	}
+	for _, ip := range []string{"10.0.1.5", "10.0.1.6", "2001:DB8::5"} {
+		if inv.Lookup(ip) != in {
+			t.Errorf("%s not mapped to the instance", ip)
+		}
+	}
+
+	inv, err = Read(strings.NewReader(gcpExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in := inv.Lookup("198.51.100.7"); in == nil || in.Provider != GCP || in.ID != "4567890123" || in.Account != "shop-prod" ||
+		in.Region != "us-central1" || in.Zone != "us-central1-a" || in.Tags["team"] != "data" {
+		t.Fatalf("unexpected GCP instance %+v", in)
+	}
+
+	inv, err = Read(strings.NewReader(azureExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in := inv.Lookup("10.2.0.5"); in == nil || in.Provider != Azure || in.Account != "0000-1111" || in.Region != "westeurope" || in.Tags["owner"] != "ops" {
+		t.Fatalf("unexpected Azure instance %+v", in)
+	}
+
+	inv, err = Read(strings.NewReader(`{"provider":"hetzner","id":"42","ips":["192.0.2.42"]}` + "\n" + `{"provider":"aws","id":"i-1","ips":["192.0.2.43"],"tags":{"a":"b"}}`))
+	if err != nil || len(inv) != 2 || inv.Lookup("192.0.2.42").ID != "42" {
+		t.Fatalf("unexpected generic inventory %v %v", inv, err)
+	}
+
+	if _, err := Read(strings.NewReader(`{"hello":"world"}`)); err == nil {
+		t.Error("want an error for an export without instances")
+	}
+}
+
+func TestTag(t *testing.T) {
+	dir := t.TempDir()
+	aws, gcp := filepath.Join(dir, "aws.json"), filepath.Join(dir, "gcp.json")
+	os.WriteFile(aws, []byte(awsExport), 0o600)
+	os.WriteFile(gcp, []byte(gcpExport), 0o600)
+	inv, err := Load(aws, gcp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := report.Build(report.Meta{}, []port.Target{
+		{Name: "web", IP: "203.0.113.5"},
+		{Name: "db", IP: "192.0.2.1"}, {Name: "db", IP: "10.128.0.7"},
+		{Name: "other", IP: "192.0.2.2"},
+	}, nil)
+	if n := inv.Tag(&rep); n != 2 {
+		t.Fatalf("tagged %d hosts, want 2", n)
+	}
+	if rep.Hosts[0].Cloud.ID != "i-0abc" || rep.Hosts[1].Cloud.Name != "db-1" || rep.Hosts[2].Cloud != nil {
+		t.Errorf("unexpected tags: %+v", rep.Hosts)
+	}
+	if _, err := Load(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("want an error for a missing file")
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"portprowler/indexed"
+	"portprowler/kube"
+	"portprowler/logging"
+	"portprowler/netutil"
+	"portprowler/port"
+	"portprowler/report"
+)
+
+// runCompare implements `portprowler compare`: it reads a Shodan or
+// Censys export, or with --k8s the exposures a Kubernetes cluster
+// declares, and reports where it disagrees with a saved report or,
+// without one, with a fresh scan of the listed addresses and ports. It
+// exits 0 when they agree and 1 when they differ, like diff.
+func runCompare(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	format := fs.String("o", "table", "output format: table or json")
+	timeout := fs.Duration("t", time.Second, "per-probe timeout of the scan run without a report (default 1s)")
+	verbose := fs.Bool("v", false, "verbose logging")
+	silent := fs.Bool("silent", false, "suppress all diagnostics on stderr (results still go to stdout)")
+	k8s := fs.String("k8s", "", "compare with the NodePort, LoadBalancer and external-IP services of the cluster in this kubeconfig instead of an export")
+	k8sContext := fs.String("k8s-context", "", "kubeconfig context to use (default: its current-context)")
+	nodePorts := fs.String("k8s-node-ports", kube.DefaultNodePorts, "node port range also scanned on cluster addresses to find undeclared services (empty: none)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s compare [flags] <export> [report]\n", progName())
+		fmt.Fprintf(fs.Output(), "       %s compare --k8s <kubeconfig> [flags] [report]\n", progName())
+		fmt.Fprintln(fs.Output(), "The export is Shodan or Censys JSON (JSON Lines, arrays and .json.gz work). The report is a file")
+		fmt.Fprintln(fs.Output(), "written with -o json or the id of a scan saved with --save; without it the export's addresses are")
+		fmt.Fprintln(fs.Output(), "scanned now on every port the export lists. With --k8s the cluster's declared exposures take the")
+		fmt.Fprintln(fs.Output(), "export's place and its addresses are also scanned on the node port range.")
+		fs.PrintDefaults()
+	}
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return parseStatus(err)
+	}
+	logging.SetSilent(*silent)
+	if *k8s != "" {
+		if len(names) > 1 {
+			return exitStatus(&exitError{code: 2, msg: "error: compare --k8s takes at most one report", usage: true}, fs)
+		}
+	} else if len(names) != 1 && len(names) != 2 {
+		return exitStatus(&exitError{code: 2, msg: "error: compare needs an export and at most one report", usage: true}, fs)
+	}
+	if *format != "table" && *format != "json" {
+		return exitStatus(usageErr("error: unknown output format %q (want table or json)", *format), fs)
+	}
+
+	var services []indexed.Service
+	extraPorts := ""
+	if *k8s != "" {
+		if *nodePorts != "" {
+			if _, err := port.ParsePortSpec(*nodePorts); err != nil {
+				return exitStatus(usageErr("error: invalid --k8s-node-ports: %v", err), fs)
+			}
+		}
+		if services, err = kubeServices(*k8s, *k8sContext); err != nil {
+			return exitStatus(err, fs)
+		}
+		extraPorts = *nodePorts
+	} else {
+		f, err := os.Open(names[0])
+		if err != nil {
+			return exitStatus(runtimeErr("failed to read %s: %v", names[0], err), fs)
+		}
+		services, err = indexed.Read(f)
+		f.Close()
+		if err != nil {
+			return exitStatus(runtimeErr("failed to read %s: %v", names[0], err), fs)
+		}
+		names = names[1:]
+	}
+
+	var rep report.ScanReport
+	if len(names) == 1 {
+		rep, err = loadReport(names[0])
+	} else if len(services) == 0 {
+		logging.Infof("nothing to scan: no services listed")
+	} else {
+		rep, err = scanIndexed(services, extraPorts, *timeout, *verbose)
+	}
+	if err != nil {
+		return exitStatus(err, fs)
+	}
+
+	var changes []report.Change
+	if *k8s != "" {
+		changes = kube.Compare(services, rep)
+	} else {
+		changes = indexed.Compare(services, rep)
+	}
+	if err := writeChanges(changes, *format, os.Stdout); err != nil {
+		return exitStatus(runtimeErr("failed to write to stdout: %v", err), fs)
+	}
+	if len(changes) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// kubeServices lists the exposures the cluster of a kubeconfig declares.
+func kubeServices(path, contextName string) ([]indexed.Service, error) {
+	c, err := kube.Load(path, contextName)
+	if err != nil {
+		return nil, usageErr("error: --k8s: %v", err)
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	exps, err := c.Exposures(ctx)
+	if err != nil {
+		return nil, runtimeErr("failed to list services of %s: %v", c.Server, err)
+	}
+	services, err := kube.Services(exps, func(host string) ([]string, error) {
+		return netutil.ResolveTarget(host, netutil.ResolveOptions{AllIPs: true})
+	})
+	if err != nil {
+		return nil, runtimeErr("%v", err)
+	}
+	logging.Infof("%s declares %d exposed service ports", c.Server, len(services))
+	return services, nil
+}
+
+// scanIndexed scans every address in services on every port listed for
+// any of them, so ports open elsewhere in the export are checked too, and
+// on the extra port spec, if any.
+func scanIndexed(services []indexed.Service, extra string, timeout time.Duration, verbose bool) (report.ScanReport, error) {
+	seenIP := make(map[string]bool)
+	seenPort := make(map[int]bool)
+	var ips []string
+	var ports []int
+	f := defaultScanFlags()
+	for _, s := range services {
+		if !seenIP[s.IP] {
+			seenIP[s.IP] = true
+			ips = append(ips, s.IP)
+		}
+		if !seenPort[int(s.Port)] {
+			seenPort[int(s.Port)] = true
+			ports = append(ports, int(s.Port))
+		}
+		if s.Proto == "udp" {
+			f.udp = true
+		} else {
+			f.tcp = true
+		}
+	}
+	sort.Ints(ports)
+	spec := make([]string, len(ports))
+	for i, p := range ports {
+		spec[i] = strconv.Itoa(p)
+	}
+	if extra != "" {
+		spec = append(spec, extra)
+		f.tcp = true
+	}
+	f.ports = strings.Join(spec, ",")
+	f.timeout = timeout
+	f.verbose = verbose
+	p, err := f.plan(ips)
+	if err != nil {
+		return report.ScanReport{}, err
+	}
+	rep, _, err := p.run(context.Background())
+	return rep, err
+}
@@ -0,0 +1,103 @@
+// Package ct discovers the hostnames of a domain from certificate
+// transparency logs, through a crt.sh-compatible search API.
+package ct
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultURL is the public crt.sh search endpoint.
+const DefaultURL = "https://crt.sh/"
+
+// Client queries a crt.sh-compatible API. Its zero HTTP uses a client
+// with a two-minute timeout; crt.sh is slow for large domains.
+type Client struct {
+	URL  string
+	HTTP *http.Client
+}
+
+// entry is one logged certificate as crt.sh lists it.
+type entry struct {
+	CommonName string `json:"common_name"`
+	NameValue  string `json:"name_value"` // SANs, one per line
+}
+
+// maxBody bounds the response; popular domains log many certificates.
+const maxBody = 256 << 20
+
+// Names returns the hostnames of domain and its subdomains found in
+// logged certificates, lower-cased and sorted. Wildcard names count as
+// their base name (*.dev.example.com as dev.example.com); names outside
+// the domain and e-mail addresses are dropped.
+func (c *Client) Names(ctx context.Context, domain string) ([]string, error) {
+	domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+	if domain == "" || strings.ContainsAny(domain, " /*@%") || !strings.Contains(domain, ".") {
+		return nil, fmt.Errorf("invalid domain %q", domain)
+	}
+	base := c.URL
+	if base == "" {
+		base = DefaultURL
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("q", "%."+domain)
+	q.Set("output", "json")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	hc := c.HTTP
+	if hc == nil {
+		hc = &http.Client{Timeout: 2 * time.Minute}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s: %s: %s", u.Host, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var entries []entry
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxBody)).Decode(&entries); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil // crt.sh answers an empty body when nothing matched
+		}
+		return nil, fmt.Errorf("%s: decoding the answer: %v", u.Host, err)
+	}
+
+	seen := make(map[string]bool)
+	var out []string
+	for _, e := range entries {
+		for _, n := range append(strings.Split(e.NameValue, "\n"), e.CommonName) {
+			n = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(n), "."))
+			n = strings.TrimPrefix(n, "*.")
+			if n == "" || strings.ContainsAny(n, "@* ") || seen[n] {
+				continue
+			}
+			if n != domain && !strings.HasSuffix(n, "."+domain) {
+				continue
+			}
+			seen[n] = true
+			out = append(out, n)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
@@ -0,0 +1,51 @@
+package ct
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNames(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") != "%.example.com" || r.URL.Query().Get("output") != "json" {
+			http.Error(w, "bad query "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`[
+			{"common_name":"www.example.com","name_value":"www.example.com\nexample.com"},
+			{"common_name":"*.dev.example.com","name_value":"*.dev.example.com\nAPI.Example.com."},
+			{"common_name":"hostmaster@example.com","name_value":"hostmaster@example.com"},
+			{"common_name":"example.com.evil.test","name_value":"notexample.com"}
+		]`))
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL + "/"}
+	names, err := c.Names(context.Background(), "Example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(names, " "); got != "api.example.com dev.example.com example.com www.example.com" {
+		t.Errorf("unexpected names %q", got)
+	}
+
+	if _, err := c.Names(context.Background(), "*.example.com"); err == nil {
+		t.Error("want an error for an invalid domain")
+	}
+	c.URL = srv.URL + "/?x=1"
+	if _, err := c.Names(context.Background(), "other.org"); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("want the server's error, got %v", err)
+	}
+}
+
+func TestNamesEmpty(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	names, err := (&Client{URL: srv.URL}).Names(context.Background(), "example.com")
+	if err != nil || len(names) != 0 {
+		t.Errorf("want no names and no error, got %v %v", names, err)
+	}
+}
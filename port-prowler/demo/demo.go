@@ -0,0 +1,223 @@
+// Package demo runs a small fake target on the loopback interface: a few
+// in-process listeners that answer like common services, so every part
+// of a scan (connect probes, banners, service detection, TLS inspection,
+// HTTP capture, reports) can be shown or smoke-tested without touching
+// any other machine.
+package demo
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IP is the address the fake target listens on.
+const IP = "127.0.0.1"
+
+// ServerName is the name in the certificate of the fake TLS service.
+const ServerName = "scanme.demo.test"
+
+// Service is one listener of the fake target.
+type Service struct {
+	Name string // what it answers like, e.g. "ssh"
+	Port uint16
+}
+
+// Target is a running fake target.
+type Target struct {
+	Services []Service // listening ports, in port order
+	Closed   uint16    // a port nobody listens on
+
+	listeners []net.Listener
+	servers   []*http.Server
+	wg        sync.WaitGroup
+}
+
+// greeting services send their banner as soon as a connection opens.
+var greetings = []struct {
+	name   string
+	port   uint16 // preferred port; any free port is used when it is taken
+	banner string
+}{
+	{"ssh", 2222, "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13.5\r\n"},
+	{"smtp", 2525, "220 mail.demo.test ESMTP Postfix (Ubuntu)\r\n"},
+	// A binary greeting no signature knows, for --hexdump and
+	// --fingerprint-out.
+	{"unknown", 9999, "\x00\x17PPDEMO\x01\x02\x03\xfe\xffready\x00"},
+}
+
+const page = `<!DOCTYPE html>
+<html><head><title>portprowler demo target</title></head>
+<body><p>This page is served by portprowler demo. It is only reachable from this machine.</p></body></html>
+`
+
+// Start opens the fake target's listeners on IP. Services take their
+// usual unprivileged ports (2222 for ssh, 8000 for http, 8443 for https,
+// ...) when those are free, so scans identify them as they would a real
+// host, and any free port otherwise.
+func Start() (*Target, error) {
+	t := &Target{}
+	for _, g := range greetings {
+		ln, err := listen(g.port)
+		if err != nil {
+			t.Close()
+			return nil, err
+		}
+		t.add(g.name, ln)
+		t.wg.Add(1)
+		go t.greet(ln, g.banner)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.24.0 (Ubuntu)")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, page)
+	})
+	cert, err := selfSigned()
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	for _, web := range []struct {
+		name string
+		port uint16
+		tls  bool
+	}{{"http", 8000, false}, {"https", 8443, true}} {
+		ln, err := listen(web.port)
+		if err != nil {
+			t.Close()
+			return nil, err
+		}
+		t.add(web.name, ln)
+		// Scans hang up mid-handshake all the time; that is not worth a log line.
+		srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second, ErrorLog: log.New(io.Discard, "", 0)}
+		if web.tls {
+			ln = tls.NewListener(ln, &tls.Config{Certificates: []tls.Certificate{cert}, NextProtos: []string{"h2", "http/1.1"}})
+		}
+		t.servers = append(t.servers, srv)
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			srv.Serve(ln)
+		}()
+	}
+
+	// A port that was free a moment ago is closed.
+	ln, err := net.Listen("tcp", net.JoinHostPort(IP, "0"))
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	t.Closed = listenerPort(ln)
+	ln.Close()
+
+	sort.Slice(t.Services, func(i, j int) bool { return t.Services[i].Port < t.Services[j].Port })
+	return t, nil
+}
+
+// Ports returns every port of the target worth scanning: the services
+// and the closed port, in order.
+func (t *Target) Ports() []uint16 {
+	ports := []uint16{t.Closed}
+	for _, s := range t.Services {
+		ports = append(ports, s.Port)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+	return ports
+}
+
+// Close stops every listener and waits for them to finish.
+func (t *Target) Close() error {
+	var errs []error
+	for _, srv := range t.servers {
+		errs = append(errs, srv.Close())
+	}
+	for _, ln := range t.listeners {
+		if err := ln.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	t.wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (t *Target) add(name string, ln net.Listener) {
+	t.listeners = append(t.listeners, ln)
+	t.Services = append(t.Services, Service{Name: name, Port: listenerPort(ln)})
+}
+
+// greet sends banner to every connection and closes it once the client
+// goes quiet or hangs up.
+func (t *Target) greet(ln net.Listener, banner string) {
+	defer t.wg.Done()
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer c.Close()
+			c.SetDeadline(time.Now().Add(5 * time.Second))
+			if _, err := c.Write([]byte(banner)); err != nil {
+				return
+			}
+			buf := make([]byte, 512)
+			for {
+				if _, err := c.Read(buf); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// listen listens on IP at port when it is free and on any port otherwise.
+func listen(port uint16) (net.Listener, error) {
+	if ln, err := net.Listen("tcp", net.JoinHostPort(IP, strconv.Itoa(int(port)))); err == nil {
+		return ln, nil
+	}
+	return net.Listen("tcp", net.JoinHostPort(IP, "0"))
+}
+
+func listenerPort(ln net.Listener) uint16 {
+	return uint16(ln.Addr().(*net.TCPAddr).Port)
+}
+
+// selfSigned makes a short-lived certificate for ServerName and IP.
+func selfSigned() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(now.UnixNano()),
+		Subject:      pkix.Name{CommonName: ServerName, Organization: []string{"portprowler demo"}},
+		DNSNames:     []string{ServerName},
+		IPAddresses:  []net.IP{net.ParseIP(IP)},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,71 @@
+package demo
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStart(t *testing.T) {
+	target, err := Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := func(name string) string {
+		for _, s := range target.Services {
+			if s.Name == name {
+				return net.JoinHostPort(IP, strconv.Itoa(int(s.Port)))
+			}
+		}
+		t.Fatalf("no %s service in %+v", name, target.Services)
+		return ""
+	}
+	if n := len(target.Ports()); n != len(target.Services)+1 {
+		t.Errorf("%d ports for %d services", n, len(target.Services))
+	}
+
+	c, err := net.DialTimeout("tcp", addr("ssh"), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, _ := bufio.NewReader(c).ReadString('\n')
+	c.Close()
+	if !strings.HasPrefix(line, "SSH-2.0-") {
+		t.Errorf("ssh banner %q", line)
+	}
+
+	client := &http.Client{Timeout: 2 * time.Second, Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	for _, u := range []string{"http://" + addr("http") + "/", "https://" + addr("https") + "/"} {
+		resp, err := client.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !strings.Contains(string(body), "<title>portprowler demo target</title>") || !strings.HasPrefix(resp.Header.Get("Server"), "nginx") {
+			t.Errorf("%s: %s %q", u, resp.Header.Get("Server"), body)
+		}
+		if resp.TLS != nil && resp.TLS.PeerCertificates[0].Subject.CommonName != ServerName {
+			t.Errorf("certificate for %s", resp.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+	}
+
+	if c, err := net.DialTimeout("tcp", net.JoinHostPort(IP, strconv.Itoa(int(target.Closed))), time.Second); err == nil {
+		c.Close()
+		t.Errorf("closed port %d accepted a connection", target.Closed)
+	}
+
+	if err := target.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := net.DialTimeout("tcp", addr("smtp"), time.Second); err == nil {
+		c.Close()
+		t.Error("smtp still listening after Close")
+	}
+}
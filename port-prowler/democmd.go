@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"portprowler/demo"
+	"portprowler/logging"
+	"portprowler/port"
+	"portprowler/report"
+)
+
+// runDemo implements `portprowler demo`: a fake target (ssh, smtp, http,
+// https, an unidentified service and a closed port) is started on the
+// loopback interface and scanned with service detection, TLS inspection
+// and HTTP capture. Any scan flag may be added to show its effect on the
+// output; flags that choose other targets or send anything off the
+// machine are refused, so the demo is safe to run anywhere. With --check,
+// the exit status is 1 unless every port reported the state it should,
+// which makes the demo a smoke test of the whole pipeline.
+func runDemo(args []string) int {
+	fs := flag.NewFlagSet("demo", flag.ContinueOnError)
+	f := defaultScanFlags()
+	f.register(fs)
+	check := fs.Bool("check", false, "exit 1 unless every demo port reports the state it should (CI smoke tests)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s demo [scan flags]\n", progName())
+		fmt.Fprintln(fs.Output(), "Scans a fake target on the loopback interface; scan flags show their effect on it.")
+		fs.PrintDefaults()
+	}
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return parseStatus(err)
+	}
+	logging.SetSilent(f.silent)
+	if len(names) > 0 {
+		return exitStatus(&exitError{code: 2, msg: "error: demo scans only its own target; use scan for " + names[0], usage: true}, fs)
+	}
+	for _, off := range []struct {
+		set  bool
+		flag string
+	}{
+		{f.ports != "", "-p"},
+		{f.jsonrpc, "--jsonrpc"},
+		{f.replay != "", "--replay"},
+		{f.hostsFile != "", "--hosts-file"},
+		{len(f.ctDomains) > 0, "--ct-domain"},
+		{len(f.proxies) > 0, "--proxy"},
+		{f.via != "", "--via"},
+		{len(f.notifyEmail) > 0, "--notify-email"},
+		{f.incident != "", "--incident"},
+		{f.issue != "", "--issue"},
+		{f.upload != "", "--upload"},
+	} {
+		if off.set {
+			return exitStatus(usageErr("error: %s cannot be used with demo, which stays on this machine", off.flag), fs)
+		}
+	}
+
+	target, err := demo.Start()
+	if err != nil {
+		return exitStatus(runtimeErr("error: failed to start the demo target: %v", err), fs)
+	}
+	defer target.Close()
+	var ports, listing []string
+	for _, p := range target.Ports() {
+		ports = append(ports, strconv.Itoa(int(p)))
+	}
+	for _, s := range target.Services {
+		listing = append(listing, fmt.Sprintf("%s on %d", s.Name, s.Port))
+	}
+	logging.Infof("Demo target %s: %s, and %d closed", demo.IP, strings.Join(listing, ", "), target.Closed)
+
+	f.ports = strings.Join(ports, ",")
+	f.serviceDetect = true
+	f.insecure = true
+	if f.sni == "" {
+		f.sni = demo.ServerName
+	}
+	if f.httpCapture == 0 {
+		f.httpCapture = 256
+	}
+	if f.hexDump == 0 {
+		f.hexDump = 64
+	}
+	if f.note == "" {
+		f.note = "portprowler demo"
+	}
+	rep, err := f.scanAndReport([]string{demo.IP})
+	if err != nil {
+		return exitStatus(err, fs)
+	}
+	if *check {
+		if problems := checkDemo(rep, target); len(problems) > 0 {
+			for _, p := range problems {
+				logging.Warnf("demo check: %s", p)
+			}
+			return 1
+		}
+		logging.Infof("Demo check passed")
+	}
+	return 0
+}
+
+// checkDemo lists the demo ports whose state in rep is not the one the
+// demo target gives them. Connect and stealth results are checked; a scan
+// with neither is a problem.
+func checkDemo(rep report.ScanReport, target *demo.Target) []string {
+	want := map[uint16]string{target.Closed: "closed"}
+	for _, s := range target.Services {
+		want[s.Port] = "open"
+	}
+	got := map[uint16]string{}
+	for _, h := range rep.Hosts {
+		for _, r := range h.Results {
+			if r.Proto == string(port.ScanTCP) || r.Proto == string(port.ScanStealth) {
+				got[r.Port] = r.State
+			}
+		}
+	}
+	if len(got) == 0 {
+		return []string{"no tcp or stealth results to check"}
+	}
+	var problems []string
+	for _, p := range target.Ports() {
+		if got[p] != want[p] {
+			problems = append(problems, fmt.Sprintf("port %d is %q, want %s", p, got[p], want[p]))
+		}
+	}
+	return problems
+}
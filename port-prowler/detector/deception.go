@@ -0,0 +1,102 @@
+package detector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"portprowler/port"
+)
+
+// honeypotBanners are default banners of common honeypots that operators
+// rarely change.
+var honeypotBanners = []struct {
+	Substr string
+	Name   string
+}{
+	{"SSH-2.0-OpenSSH_6.0p1 Debian-4+deb7u2", "cowrie"},
+	{"SSH-2.0-OpenSSH_5.1p1 Debian-5", "kippo"},
+	{"220 DiskStation FTP server ready.", "dionaea"},
+}
+
+// greetingPorts normally send a banner right after the connect.
+var greetingPorts = map[uint16]bool{21: true, 22: true, 23: true, 25: true, 110: true, 143: true}
+
+// Thresholds for the behavioural heuristics.
+const (
+	allOpenMinPorts   = 10  // ports needed before judging the open ratio
+	allOpenRatio      = 0.9 // fraction of open ports that looks like "everything answers"
+	uniformMinPorts   = 8   // open ports needed before judging RTT spread
+	uniformMaxSpread  = 1   // ms between slowest and fastest open port
+	uniformMinMedian  = 5   // ms; nearby hosts are uniformly fast anyway
+	silentMinGreeters = 2   // silent greeting ports needed to call it tarpit-like
+	sameBannerMin     = 3   // ports sharing one banner
+)
+
+// DetectDeception looks for signs that a host is a tarpit (e.g. LaBrea) or
+// honeypot: default honeypot banners, (nearly) every port open, suspiciously
+// uniform RTTs, greeting services that stay silent and one banner on many
+// ports. It returns the reasons found; an empty result means nothing stood
+// out. These are heuristics: results from a flagged host should be treated
+// sceptically, not discarded.
+func DetectDeception(results []port.PortResult) []string {
+	var reasons []string
+	var scanned, open int
+	var rtts []int64
+	var silent []string
+	banners := make(map[string][]uint16)
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if r.Proto != "tcp" && r.Proto != "stealth" {
+			continue
+		}
+		scanned++
+		if r.State != "open" {
+			continue
+		}
+		open++
+		rtts = append(rtts, r.RTTMillis)
+		banner := strings.TrimSpace(r.ServiceBanner)
+		if banner == "" && greetingPorts[r.Port] && r.Proto == "tcp" {
+			silent = append(silent, fmt.Sprint(r.Port))
+		}
+		if banner == "" {
+			continue
+		}
+		banners[banner] = append(banners[banner], r.Port)
+		for _, hp := range honeypotBanners {
+			if strings.Contains(banner, hp.Substr) && !seen[hp.Name] {
+				seen[hp.Name] = true
+				reasons = append(reasons, fmt.Sprintf("port %d banner matches %s default", r.Port, hp.Name))
+			}
+		}
+	}
+
+	if scanned >= allOpenMinPorts && float64(open) >= allOpenRatio*float64(scanned) {
+		reasons = append(reasons, fmt.Sprintf("%d of %d scanned ports open", open, scanned))
+	}
+	if len(rtts) >= uniformMinPorts {
+		sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+		if rtts[len(rtts)-1]-rtts[0] <= uniformMaxSpread && rtts[len(rtts)/2] >= uniformMinMedian {
+			reasons = append(reasons, fmt.Sprintf("uniform rtt across %d open ports (%d-%dms)", len(rtts), rtts[0], rtts[len(rtts)-1]))
+		}
+	}
+	if len(silent) >= silentMinGreeters {
+		reasons = append(reasons, "open ports that normally greet stayed silent: "+strings.Join(silent, ","))
+	}
+	var shared []string
+	for b, ports := range banners {
+		if len(ports) >= sameBannerMin {
+			shared = append(shared, fmt.Sprintf("same banner on %d ports (%q)", len(ports), truncate(b, 40)))
+		}
+	}
+	sort.Strings(shared)
+	return append(reasons, shared...)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,46 @@
+package detector
+
+import (
+	"strings"
+	"testing"
+
+	"portprowler/port"
+)
+
+func TestDetectDeception(t *testing.T) {
+	// a normal host: a few open ports, varied RTTs, real greetings
+	normal := []port.PortResult{
+		{Port: 22, Proto: "tcp", State: "open", RTTMillis: 12, ServiceBanner: "SSH-2.0-OpenSSH_9.6"},
+		{Port: 25, Proto: "tcp", State: "open", RTTMillis: 15, ServiceBanner: "220 mail.example ESMTP"},
+		{Port: 80, Proto: "tcp", State: "open", RTTMillis: 11},
+	}
+	for p := uint16(1000); p < 1010; p++ {
+		normal = append(normal, port.PortResult{Port: p, Proto: "tcp", State: "closed", RTTMillis: 12})
+	}
+	if got := DetectDeception(normal); len(got) != 0 {
+		t.Fatalf("normal host flagged: %v", got)
+	}
+
+	// a LaBrea-style tarpit: every port accepts, nothing talks, flat RTT
+	var tarpit []port.PortResult
+	for p := uint16(20); p < 32; p++ {
+		tarpit = append(tarpit, port.PortResult{Port: p, Proto: "tcp", State: "open", RTTMillis: 40})
+	}
+	got := strings.Join(DetectDeception(tarpit), "|")
+	for _, want := range []string{"12 of 12 scanned ports open", "uniform rtt", "stayed silent: 21,22,23,25"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("tarpit reasons %q missing %q", got, want)
+		}
+	}
+
+	// a honeypot with a default banner replayed on several ports
+	banner := "SSH-2.0-OpenSSH_6.0p1 Debian-4+deb7u2"
+	var honeypot []port.PortResult
+	for _, p := range []uint16{22, 2222, 2223} {
+		honeypot = append(honeypot, port.PortResult{Port: p, Proto: "tcp", State: "open", RTTMillis: int64(p % 7), ServiceBanner: banner})
+	}
+	got = strings.Join(DetectDeception(honeypot), "|")
+	if !strings.Contains(got, "cowrie") || !strings.Contains(got, "same banner on 3 ports") {
+		t.Fatalf("honeypot reasons = %q", got)
+	}
+}
@@ -0,0 +1,194 @@
+package detector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"portprowler/logging"
+	"portprowler/port"
+)
+
+// httpPorts lists ports treated as web services for content capture, in
+// addition to any port whose detected service starts with "http".
+var httpPorts = map[uint16]bool{
+	80:   true,
+	443:  true,
+	8000: true,
+	8008: true,
+	8080: true,
+	8443: true,
+	8888: true,
+}
+
+// maxTitleScan bounds how much of the body is read looking for <title>,
+// independently of how many bytes are kept in the result.
+const maxTitleScan = 16 * 1024
+
+// IsWebResult reports whether res looks like an HTTP(S) service.
+func IsWebResult(res port.PortResult) bool {
+	return httpPorts[res.Port] || strings.HasPrefix(res.Service, "http")
+}
+
+// CaptureHTTP issues a GET / to an open web port and stores the status,
+// Server header, <title> and the first cfg.HTTPCapture body bytes in res.HTTP.
+// TLS is used for TLS ports and honours cfg.SNI / cfg.Insecure.
+func CaptureHTTP(ctx context.Context, cfg Config, res port.PortResult) port.PortResult {
+	if cfg.HTTPCapture <= 0 || res.State != "open" || res.Proto != "tcp" {
+		return res
+	}
+	addr := net.JoinHostPort(res.IP, strconv.Itoa(int(res.Port)))
+	sni := serverName(cfg, res)
+	host := sni
+	if host == "" {
+		host = res.IP
+	}
+	info, err := requestRoot(ctx, cfg, res, sni, host, cfg.HTTPCapture)
+	if err != nil {
+		if cfg.Verbose {
+			logging.Verbosef("http capture error %s: %v\n", addr, err)
+		}
+		return res
+	}
+	res.HTTP = info
+	if cfg.Verbose {
+		logging.Verbosef("http capture %s status=%d title=%q\n", addr, info.StatusCode, info.Title)
+	}
+	return res
+}
+
+// requestRoot connects to the port of res, wraps TLS ports using sni as
+// server name and fetches / with the given Host header. With cfg.Pool it
+// first tries an idle connection to the port and keeps the connection
+// open afterwards when the server allows it.
+func requestRoot(ctx context.Context, cfg Config, res port.PortResult, sni, host string, keep int) (*port.HTTPInfo, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 1 * time.Second
+	}
+	addr := net.JoinHostPort(res.IP, strconv.Itoa(int(res.Port)))
+	secure := IsTLSPort(res.Port)
+	if !secure {
+		sni = ""
+	}
+	// A connection made without verification goes back to the pool as such.
+	unverified := secure && cfg.Insecure
+	if conn := cfg.Pool.get(addr, sni, cfg.Insecure); conn != nil {
+		if cfg.Verbose {
+			logging.Verbosef("http %s: reusing connection\n", addr)
+		}
+		if info, err := roundTrip(ctx, cfg, conn, addr, sni, unverified, host, keep, timeout); err == nil || ctx.Err() != nil {
+			return info, err
+		}
+		// The server closed the idle connection; try a fresh one.
+	}
+	conn, err := dial(ctx, cfg, addr, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+	_ = conn.SetDeadline(ioDeadline(ctx, timeout))
+
+	if secure {
+		tcfg := &tls.Config{
+			ServerName:         sni,
+			InsecureSkipVerify: cfg.Insecure,
+			MinVersion:         tls.VersionTLS10,
+		}
+		applyClientHello(tcfg, cfg)
+		if len(tcfg.NextProtos) > 0 {
+			// the capture speaks HTTP/1.x only
+			tcfg.NextProtos = []string{"http/1.1"}
+		}
+		tconn := tls.Client(conn, tcfg)
+		if err := tconn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		conn = tconn
+	}
+	return roundTrip(ctx, cfg, conn, addr, sni, unverified, host, keep, timeout)
+}
+
+// roundTrip fetches / over conn, then returns conn to cfg.Pool when it
+// can carry another request and closes it otherwise.
+func roundTrip(ctx context.Context, cfg Config, conn net.Conn, addr, sni string, unverified bool, host string, keep int, timeout time.Duration) (*port.HTTPInfo, error) {
+	_ = conn.SetDeadline(ioDeadline(ctx, timeout))
+	info, reusable, err := fetchRoot(conn, host, keep, cfg.Pool != nil)
+	if err == nil && reusable {
+		_ = conn.SetDeadline(time.Time{})
+		cfg.Pool.put(addr, sni, unverified, conn)
+	} else {
+		_ = conn.Close()
+	}
+	return info, err
+}
+
+// fetchRoot writes a minimal GET / over conn and parses the response.
+// With keepAlive the request is HTTP/1.1 and reusable reports whether the
+// whole response was read and the server left the connection open.
+func fetchRoot(conn net.Conn, host string, keep int, keepAlive bool) (info *port.HTTPInfo, reusable bool, err error) {
+	req := "GET / HTTP/1.0\r\nHost: " + host + "\r\nUser-Agent: portprowler\r\nAccept: */*\r\nConnection: close\r\n\r\n"
+	if keepAlive {
+		req = "GET / HTTP/1.1\r\nHost: " + host + "\r\nUser-Agent: portprowler\r\nAccept: */*\r\n\r\n"
+	}
+	if _, err := io.WriteString(conn, req); err != nil {
+		return nil, false, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	limit := keep
+	if limit < maxTitleScan {
+		limit = maxTitleScan
+	}
+	// A read error after some bytes (e.g. deadline) still leaves useful data.
+	body, rerr := io.ReadAll(io.LimitReader(resp.Body, int64(limit)))
+	// Fewer bytes than the limit without an error means the body ended.
+	reusable = keepAlive && !resp.Close && rerr == nil && len(body) < limit && br.Buffered() == 0
+
+	info = &port.HTTPInfo{
+		StatusCode: resp.StatusCode,
+		Server:     resp.Header.Get("Server"),
+		Title:      ExtractTitle(body),
+		Location:   resp.Header.Get("Location"),
+	}
+	if len(body) > keep {
+		body = body[:keep]
+	}
+	info.Body = body
+	return info, reusable, nil
+}
+
+// ExtractTitle returns the whitespace-normalized text of the first <title>
+// element in body, or "" when none is found.
+func ExtractTitle(body []byte) string {
+	lower := bytes.ToLower(body)
+	i := bytes.Index(lower, []byte("<title"))
+	if i < 0 {
+		return ""
+	}
+	gt := bytes.IndexByte(lower[i:], '>')
+	if gt < 0 {
+		return ""
+	}
+	start := i + gt + 1
+	end := bytes.Index(lower[start:], []byte("</title"))
+	if end < 0 {
+		return ""
+	}
+	title := html.UnescapeString(string(body[start : start+end]))
+	return strings.Join(strings.Fields(title), " ")
+}
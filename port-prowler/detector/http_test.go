@@ -0,0 +1,77 @@
+package detector
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"portprowler/port"
+)
+
+func TestExtractTitle(t *testing.T) {
+	cases := map[string]string{
+		"<html><head><title>Admin &amp; Login</title></head>": "Admin & Login",
+		"<TITLE lang=en>\n  Router\n  Setup </TITLE>":         "Router Setup",
+		"<html>no title</html>":                               "",
+		"<title>unterminated":                                 "",
+	}
+	for in, want := range cases {
+		if got := ExtractTitle([]byte(in)); got != want {
+			t.Errorf("ExtractTitle(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCaptureHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "test-srv")
+		_, _ = w.Write([]byte("<html><title>Hello</title><body>0123456789</body></html>"))
+	}))
+	defer srv.Close()
+	addr := srv.Listener.Addr().(*net.TCPAddr)
+
+	res := port.PortResult{IP: "127.0.0.1", Port: uint16(addr.Port), Proto: "tcp", State: "open"}
+	res = CaptureHTTP(context.Background(), Config{Timeout: time.Second, HTTPCapture: 12}, res)
+	if res.HTTP == nil {
+		t.Fatalf("expected http capture")
+	}
+	if res.HTTP.StatusCode != 200 || res.HTTP.Server != "test-srv" || res.HTTP.Title != "Hello" {
+		t.Fatalf("unexpected capture: %+v", res.HTTP)
+	}
+	if string(res.HTTP.Body) != "<html><title" {
+		t.Fatalf("body not truncated to 12 bytes: %q", res.HTTP.Body)
+	}
+}
+
+func TestEnumerateVHosts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Host {
+		case "admin.example":
+			_, _ = w.Write([]byte("<title>Admin</title>"))
+		case "old.example":
+			http.Redirect(w, r, "https://www.example/", http.StatusMovedPermanently)
+		default:
+			_, _ = w.Write([]byte("<title>Default</title>"))
+		}
+	}))
+	defer srv.Close()
+	addr := srv.Listener.Addr().(*net.TCPAddr)
+
+	res := port.PortResult{IP: "127.0.0.1", Port: uint16(addr.Port), Proto: "tcp", State: "open", Service: "http"}
+	got := EnumerateVHosts(context.Background(), Config{Timeout: time.Second}, res, []string{"www.example", "admin.example", "old.example"})
+	if len(got) != 3 {
+		t.Fatalf("got %d vhosts, want 3: %+v", len(got), got)
+	}
+	if got[0].Differs || got[0].Title != "Default" {
+		t.Errorf("www.example = %+v, want same as bare IP", got[0])
+	}
+	if !got[1].Differs || got[1].Title != "Admin" {
+		t.Errorf("admin.example = %+v, want differing", got[1])
+	}
+	if !got[2].Differs || got[2].StatusCode != http.StatusMovedPermanently || got[2].Location != "https://www.example/" {
+		t.Errorf("old.example = %+v, want differing redirect", got[2])
+	}
+}
@@ -0,0 +1,166 @@
+package detector
+
+import (
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ParseCipherSuites parses a comma-separated list of cipher suite names
+// (as reported by crypto/tls, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)
+// or hex IDs (0xc02f) into suite IDs, preserving the given order.
+func ParseCipherSuites(spec string) ([]uint16, error) {
+	byName := make(map[string]uint16)
+	for _, cs := range tls.CipherSuites() {
+		byName[cs.Name] = cs.ID
+	}
+	for _, cs := range tls.InsecureCipherSuites() {
+		byName[cs.Name] = cs.ID
+	}
+	var out []uint16
+	for _, tok := range strings.Split(spec, ",") {
+		tok = strings.TrimSpace(tok)
+		if tok == "" {
+			continue
+		}
+		if id, ok := byName[strings.ToUpper(tok)]; ok {
+			out = append(out, id)
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(tok), "0x") {
+			v, err := strconv.ParseUint(tok[2:], 16, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cipher suite id %q", tok)
+			}
+			out = append(out, uint16(v))
+			continue
+		}
+		return nil, fmt.Errorf("unknown cipher suite %q", tok)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("empty cipher suite list")
+	}
+	return out, nil
+}
+
+// applyClientHello applies the configured ClientHello shape to tc.
+// crypto/tls does not allow TLS 1.3 suites to be chosen, so an explicit
+// cipher list caps the handshake at TLS 1.2 where the list is honoured.
+// Note that crypto/tls orders suites itself; the list restricts the offer.
+func applyClientHello(tc *tls.Config, cfg Config) {
+	if len(cfg.TLSCiphers) > 0 {
+		tc.CipherSuites = cfg.TLSCiphers
+		tc.MaxVersion = tls.VersionTLS12
+	}
+	if len(cfg.TLSALPN) > 0 {
+		tc.NextProtos = cfg.TLSALPN
+	}
+}
+
+// recordingConn keeps a copy of the first bytes read from the server so the
+// ServerHello can be parsed after crypto/tls has consumed it.
+type recordingConn struct {
+	net.Conn
+	buf []byte
+	max int
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 && len(c.buf) < c.max {
+		room := c.max - len(c.buf)
+		if room > n {
+			room = n
+		}
+		c.buf = append(c.buf, p[:room]...)
+	}
+	return n, err
+}
+
+// JA3S computes the JA3S string ("version,cipher,ext-ext-...") and its MD5
+// hash from the raw bytes a server sent at the start of a TLS handshake.
+func JA3S(serverBytes []byte) (string, string, error) {
+	version, cipher, exts, err := ParseServerHello(serverBytes)
+	if err != nil {
+		return "", "", err
+	}
+	parts := make([]string, len(exts))
+	for i, e := range exts {
+		parts[i] = strconv.Itoa(int(e))
+	}
+	s := fmt.Sprintf("%d,%d,%s", version, cipher, strings.Join(parts, "-"))
+	sum := md5.Sum([]byte(s))
+	return s, hex.EncodeToString(sum[:]), nil
+}
+
+// ParseServerHello extracts the legacy version, selected cipher suite and
+// extension types (in wire order) from TLS records containing a ServerHello.
+// Handshake records are reassembled so a fragmented ServerHello still parses.
+func ParseServerHello(data []byte) (version, cipher uint16, exts []uint16, err error) {
+	var hs []byte
+	for len(data) >= 5 {
+		ctype := data[0]
+		rlen := int(binary.BigEndian.Uint16(data[3:5]))
+		if len(data) < 5+rlen {
+			// keep what we have of a truncated record
+			rlen = len(data) - 5
+		}
+		if ctype != 22 { // handshake
+			break
+		}
+		hs = append(hs, data[5:5+rlen]...)
+		data = data[5+rlen:]
+	}
+	if len(hs) < 4 {
+		return 0, 0, nil, errors.New("no handshake data")
+	}
+	if hs[0] != 2 {
+		return 0, 0, nil, fmt.Errorf("first handshake message is type %d, not ServerHello", hs[0])
+	}
+	mlen := int(hs[1])<<16 | int(hs[2])<<8 | int(hs[3])
+	msg := hs[4:]
+	if len(msg) < mlen {
+		return 0, 0, nil, errors.New("truncated ServerHello")
+	}
+	msg = msg[:mlen]
+
+	// version(2) random(32) session_id_len(1)
+	if len(msg) < 35 {
+		return 0, 0, nil, errors.New("short ServerHello")
+	}
+	version = binary.BigEndian.Uint16(msg[0:2])
+	sidLen := int(msg[34])
+	off := 35 + sidLen
+	// cipher(2) compression(1)
+	if len(msg) < off+3 {
+		return 0, 0, nil, errors.New("short ServerHello")
+	}
+	cipher = binary.BigEndian.Uint16(msg[off : off+2])
+	off += 3
+	if len(msg) < off+2 {
+		// extensions are optional
+		return version, cipher, nil, nil
+	}
+	extLen := int(binary.BigEndian.Uint16(msg[off : off+2]))
+	off += 2
+	if len(msg) < off+extLen {
+		return 0, 0, nil, errors.New("truncated ServerHello extensions")
+	}
+	ext := msg[off : off+extLen]
+	for len(ext) >= 4 {
+		typ := binary.BigEndian.Uint16(ext[0:2])
+		l := int(binary.BigEndian.Uint16(ext[2:4]))
+		if len(ext) < 4+l {
+			return 0, 0, nil, errors.New("truncated extension")
+		}
+		exts = append(exts, typ)
+		ext = ext[4+l:]
+	}
+	return version, cipher, exts, nil
+}
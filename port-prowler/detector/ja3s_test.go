@@ -0,0 +1,83 @@
+package detector
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseServerHello(t *testing.T) {
+	// ServerHello: version 0x0303, empty session id, cipher 0xc02f,
+	// extensions 0xff01 (len 1) and 0x0010 (len 0).
+	body := []byte{0x03, 0x03}
+	body = append(body, make([]byte, 32)...)
+	body = append(body, 0x00)             // session id len
+	body = append(body, 0xc0, 0x2f, 0x00) // cipher, compression
+	body = append(body, 0x00, 0x09)       // extensions length
+	body = append(body, 0xff, 0x01, 0x00, 0x01, 0x00)
+	body = append(body, 0x00, 0x10, 0x00, 0x00)
+	hs := append([]byte{0x02, 0x00, 0x00, byte(len(body))}, body...)
+	// split the handshake message across two records
+	rec := func(b []byte) []byte {
+		return append([]byte{22, 0x03, 0x03, 0x00, byte(len(b))}, b...)
+	}
+	data := append(rec(hs[:10]), rec(hs[10:])...)
+
+	s, h, err := JA3S(data)
+	if err != nil {
+		t.Fatalf("JA3S: %v", err)
+	}
+	if s != "771,49199,65281-16" {
+		t.Fatalf("ja3s string = %q", s)
+	}
+	if len(h) != 32 {
+		t.Fatalf("ja3s hash = %q", h)
+	}
+
+	if _, _, _, err := ParseServerHello(rec([]byte{0x01, 0x00, 0x00, 0x00})); err == nil {
+		t.Fatalf("expected error for non-ServerHello message")
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	ids, err := ParseCipherSuites("TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, 0x009c")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || ids[1] != 0x009c {
+		t.Fatalf("got %v", ids)
+	}
+	if _, err := ParseCipherSuites("NOT_A_SUITE"); err == nil {
+		t.Fatalf("expected error for unknown suite")
+	}
+}
+
+func TestInspectTLS_ClientHelloControl(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	cfg := Config{
+		Timeout:    time.Second,
+		Insecure:   true,
+		TLSCiphers: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		TLSALPN:    []string{"h2"},
+	}
+	res := InspectTLS(context.Background(), cfg, tlsResult(t, srv))
+	if res.TLS == nil || res.TLS.Version != "TLS1.2" {
+		t.Fatalf("expected TLS1.2 handshake, got %+v", res.TLS)
+	}
+	want := fmt.Sprintf("771,%d,", tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)
+	if !strings.HasPrefix(res.TLS.JA3S, want) || res.TLS.JA3SHash == "" {
+		t.Fatalf("ja3s = %q (hash %q), want prefix %q", res.TLS.JA3S, res.TLS.JA3SHash, want)
+	}
+	if res.TLS.ALPN != "h2" {
+		t.Fatalf("alpn = %q, want h2", res.TLS.ALPN)
+	}
+}
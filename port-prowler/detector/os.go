@@ -7,10 +7,18 @@ import (
 )
 
 // DetectOS analyzes a slice of open PortResult entries and returns a best-effort
-// OS guess and a confidence string ("low"|"medium"|"high").
+// OS guess and its confidence on the port.Confidence scale.
 // This implementation uses banner substrings and simple open-port patterns.
 // It is conservative and designed for unit testing (deterministic string checks).
 func DetectOS(results []port.PortResult) (string, string) {
+	return DetectOSIn(results, "")
+}
+
+// DetectOSIn is DetectOS for a scan context. From outside (ContextExternal)
+// open ports are often forwarded by a NAT gateway or load balancer to
+// different machines, so port numbers alone are not counted; banners
+// still are.
+func DetectOSIn(results []port.PortResult, context string) (string, string) {
 	if len(results) == 0 {
 		return "", ""
 	}
@@ -48,7 +56,7 @@ func DetectOS(results []port.PortResult) (string, string) {
 		if strings.Contains(b, "windows") || strings.Contains(b, "microsoft") || strings.Contains(b, "mssql") {
 			scores["windows"] += 3
 		}
-		if strings.Contains(b, "rdp") || r.Port == 3389 {
+		if strings.Contains(b, "rdp") || (r.Port == 3389 && context != ContextExternal) {
 			scores["windows"] += 4
 		}
 		if strings.Contains(b, "iis") || strings.Contains(b, "winhttp") {
@@ -78,6 +86,9 @@ func DetectOS(results []port.PortResult) (string, string) {
 		}
 
 		// Port-pattern heuristics (additive)
+		if context == ContextExternal {
+			continue
+		}
 		switch r.Port {
 		case 3389:
 			scores["windows"] += 4
@@ -106,11 +117,11 @@ func DetectOS(results []port.PortResult) (string, string) {
 	}
 
 	// Map score to confidence
-	conf := "low"
+	conf := port.ConfidenceLow
 	if bestScore >= 6 {
-		conf = "high"
+		conf = port.ConfidenceHigh
 	} else if bestScore >= 3 {
-		conf = "medium"
+		conf = port.ConfidenceMedium
 	}
 
 	// Normalize OS name
@@ -0,0 +1,97 @@
+package detector
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// PoolPerHost is the most idle connections a Pool keeps per host.
+const PoolPerHost = 4
+
+// PoolIdle is how long a pooled connection may sit unused; many servers
+// close keep-alive connections after about five seconds.
+const PoolIdle = 5 * time.Second
+
+// Pool keeps connections detection has finished with but left open, so a
+// later request to the same port (the HTTP capture after a TLS handshake,
+// the next virtual host) reuses them instead of dialing again. A nil
+// *Pool keeps nothing.
+type Pool struct {
+	mu   sync.Mutex
+	idle map[string][]pooledConn // by host
+}
+
+type pooledConn struct {
+	conn     net.Conn
+	addr     string
+	sni      string // TLS server name; "" for plain connections
+	insecure bool   // a TLS connection whose chain was not verified
+	since    time.Time
+}
+
+// NewPool returns an empty Pool.
+func NewPool() *Pool {
+	return &Pool{idle: make(map[string][]pooledConn)}
+}
+
+// get takes an idle connection to addr with server name sni out of the
+// pool, or returns nil. Unverified TLS connections are only handed out
+// when insecureOK is set.
+func (p *Pool) get(addr, sni string, insecureOK bool) net.Conn {
+	if p == nil {
+		return nil
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	list := p.idle[host]
+	for i := 0; i < len(list); i++ {
+		c := list[i]
+		if time.Since(c.since) > PoolIdle {
+			_ = c.conn.Close()
+			list = append(list[:i], list[i+1:]...)
+			i--
+			continue
+		}
+		if c.addr == addr && c.sni == sni && (!c.insecure || insecureOK) {
+			p.idle[host] = append(list[:i], list[i+1:]...)
+			return c.conn
+		}
+	}
+	p.idle[host] = list
+	return nil
+}
+
+// put returns conn to the pool, closing the host's oldest idle connection
+// when it already holds PoolPerHost. On a nil pool conn is closed.
+func (p *Pool) put(addr, sni string, insecure bool, conn net.Conn) {
+	if p == nil {
+		_ = conn.Close()
+		return
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	list := p.idle[host]
+	if len(list) >= PoolPerHost {
+		_ = list[0].conn.Close()
+		list = list[1:]
+	}
+	p.idle[host] = append(list, pooledConn{conn: conn, addr: addr, sni: sni, insecure: insecure, since: time.Now()})
+}
+
+// CloseIdle closes every pooled connection.
+func (p *Pool) CloseIdle() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for host, list := range p.idle {
+		for _, c := range list {
+			_ = c.conn.Close()
+		}
+		delete(p.idle, host)
+	}
+}
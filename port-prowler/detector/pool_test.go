@@ -0,0 +1,100 @@
+package detector
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"portprowler/port"
+)
+
+// countingServer starts srv after hooking a counter of accepted connections.
+func countingServer(srv *httptest.Server, tls bool) *atomic.Int32 {
+	var n atomic.Int32
+	srv.Config.ConnState = func(_ net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			n.Add(1)
+		}
+	}
+	if tls {
+		srv.StartTLS()
+	} else {
+		srv.Start()
+	}
+	return &n
+}
+
+func TestEnumerateVHosts_ReusesConnection(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<title>" + r.Host + "</title>"))
+	}))
+	conns := countingServer(srv, false)
+	defer srv.Close()
+	addr := srv.Listener.Addr().(*net.TCPAddr)
+	res := port.PortResult{IP: "127.0.0.1", Port: uint16(addr.Port), Proto: "tcp", State: "open", Service: "http"}
+	names := []string{"a.example", "b.example", "c.example"}
+
+	pool := NewPool()
+	defer pool.CloseIdle()
+	vh := EnumerateVHosts(context.Background(), Config{Timeout: time.Second, Pool: pool}, res, names)
+	if len(vh) != 3 || vh[2].Title != "c.example" || !vh[2].Differs {
+		t.Fatalf("unexpected vhosts: %+v", vh)
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("four requests used %d connections, want 1", n)
+	}
+
+	// A connection the server dropped while idle is replaced.
+	srv.CloseClientConnections()
+	if got := CaptureHTTP(context.Background(), Config{Timeout: time.Second, HTTPCapture: 64, Pool: pool}, res); got.HTTP == nil || got.HTTP.StatusCode != 200 {
+		t.Fatalf("capture over a stale pooled connection failed: %+v", got.HTTP)
+	}
+
+	conns.Store(0)
+	EnumerateVHosts(context.Background(), Config{Timeout: time.Second}, res, names)
+	if n := conns.Load(); n != 4 {
+		t.Errorf("without a pool four requests used %d connections, want 4", n)
+	}
+}
+
+func TestCaptureHTTP_ReusesTLSInspection(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<title>secure</title>"))
+	}))
+	conns := countingServer(srv, true)
+	defer srv.Close()
+	res := tlsResult(t, srv)
+	tlsPorts[res.Port] = true
+	httpPorts[res.Port] = true
+	defer delete(tlsPorts, res.Port)
+	defer delete(httpPorts, res.Port)
+
+	pool := NewPool()
+	defer pool.CloseIdle()
+	cfg := Config{Timeout: time.Second, Insecure: true, HTTPCapture: 64, Pool: pool}
+	res = CaptureHTTP(context.Background(), cfg, InspectTLS(context.Background(), cfg, res))
+	if res.TLS == nil || res.HTTP == nil || res.HTTP.Title != "secure" {
+		t.Fatalf("unexpected result: tls=%+v http=%+v", res.TLS, res.HTTP)
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("handshake and capture used %d connections, want 1", n)
+	}
+
+	// An unverified connection is not handed to a verifying request.
+	if c := pool.get(net.JoinHostPort(res.IP, "0"), "", false); c != nil {
+		t.Error("pool returned a connection for another address")
+	}
+	addr := srv.Listener.Addr().String()
+	if c := pool.get(addr, "", false); c != nil {
+		t.Error("pool returned an unverified connection to a verifying caller")
+	}
+	if c := pool.get(addr, "", true); c == nil {
+		t.Error("the capture connection was not kept")
+	} else {
+		c.Close()
+	}
+}
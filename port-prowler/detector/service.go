@@ -2,13 +2,17 @@ package detector
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"net"
-	"strings"
 	"time"
 
+	"portprowler/audit"
+	"portprowler/logging"
+	"portprowler/netutil"
 	"portprowler/port"
 	"portprowler/sigs"
+	"portprowler/wire"
 )
 
 // Config contains the minimal fields detector needs (no import cycle with scanner).
@@ -16,19 +20,73 @@ type Config struct {
 	ServiceDetect bool
 	Timeout       time.Duration
 	Verbose       bool
+	SNI           string // override the TLS server name (defaults to the target hostname)
+	Insecure      bool   // complete TLS handshakes even when the chain does not verify
+	HTTPCapture   int    // bytes of response body to keep for web ports (0 disables capture)
+	// ClientHello shape for TLS probes; empty means crypto/tls defaults.
+	TLSCiphers []uint16
+	TLSALPN    []string
+	// BannerLimit caps the bytes read after a probe; zero uses 2048.
+	BannerLimit int
+	// Fingerprints keeps unmatched banners (probe and raw response) in res.Fingerprint.
+	Fingerprints bool
+	// HexDump dumps up to this many bytes of a banner that identified no
+	// service into res.HexDump; zero disables.
+	HexDump int
+	// Dialer opens follow-up connections (e.g. through a proxy chain); nil dials directly.
+	Dialer netutil.ContextDialer
+	// Audit, when set, records every connection detection opens.
+	Audit *audit.Log
+	// Pool, when set, keeps finished connections open for reuse by later
+	// requests to the same port instead of dialing again.
+	Pool *Pool
+}
+
+// ioDeadline returns now+timeout, or the context deadline when that is
+// sooner, so per-connection I/O never outlives the detection budget.
+func ioDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	d := time.Now().Add(timeout)
+	if cd, ok := ctx.Deadline(); ok && cd.Before(d) {
+		return cd
+	}
+	return d
+}
+
+// dial connects to addr with cfg.Dialer, bounded by timeout.
+func dial(ctx context.Context, cfg Config, addr string, timeout time.Duration) (net.Conn, error) {
+	d := cfg.Dialer
+	if d == nil {
+		d = &net.Dialer{}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if cfg.Audit != nil {
+		cfg.Audit.Conn("detect", start, addr, conn, err)
+	}
+	return conn, err
 }
 
 // DetectService enriches a PortResult with service detection info when applicable.
 //   - Only runs when result.State == "open" AND cfg.ServiceDetect == true.
 //   - Uses result.ServiceBanner if present; otherwise attempts lightweight probes
 //     for common TCP ports (80/8080/8000 => HTTP HEAD, 25 => SMTP HELO).
+//   - Performs a TLS handshake on well-known TLS ports (see InspectTLS).
+//   - Captures the title and first bytes of web responses when enabled (see CaptureHTTP).
+//   - Dumps the first bytes of banners that identified no service when enabled.
 func DetectService(ctx context.Context, cfg Config, res port.PortResult) port.PortResult {
 	if !cfg.ServiceDetect || res.State != "open" {
 		return res
 	}
 
 	// If banner already present (e.g., TCPScan populated it), use it.
-	banner := strings.TrimSpace(res.ServiceBanner)
+	banner := wire.ParseBanner([]byte(res.ServiceBanner))
+	fp := port.Fingerprint{Probe: "null"}
+	raw := res.BannerRaw
+	if raw == nil {
+		raw = []byte(res.ServiceBanner)
+	}
 
 	// If empty, attempt minimal probes for common TCP ports.
 	if banner == "" && res.Proto == "tcp" {
@@ -38,18 +96,20 @@ func DetectService(ctx context.Context, cfg Config, res port.PortResult) port.Po
 		if dialTimeout <= 0 {
 			dialTimeout = 1 * time.Second
 		}
-		conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+		conn, err := dial(ctx, cfg, addr, dialTimeout)
 		if err == nil {
 			// Ensure we close the connection.
 			defer conn.Close()
-			conn.SetDeadline(time.Now().Add(dialTimeout))
+			conn.SetDeadline(ioDeadline(ctx, dialTimeout))
 
 			var probe string
 			switch res.Port {
 			case 80, 8080, 8000:
 				probe = "HEAD / HTTP/1.0\r\n\r\n"
+				fp.Probe = "http-head"
 			case 25:
 				probe = "HELO test\r\n"
+				fp.Probe = "smtp-helo"
 			default:
 				// Generic read attempt: no probe write, just try to read any banner the server may send.
 			}
@@ -57,17 +117,23 @@ func DetectService(ctx context.Context, cfg Config, res port.PortResult) port.Po
 			if probe != "" {
 				_, _ = conn.Write([]byte(probe))
 			}
-			// Read up to 2048 bytes
-			buf := make([]byte, 2048)
+			limit := cfg.BannerLimit
+			if limit <= 0 {
+				limit = 2048
+			}
+			buf := make([]byte, limit)
 			n, _ := conn.Read(buf)
 			if n > 0 {
-				banner = strings.TrimSpace(string(buf[:n]))
-			}
-		} else {
-			// Dial failed; leave banner empty and record error in res.Error for visibility.
-			if cfg.Verbose {
-				res.Error = fmt.Sprintf("service-detect: dial error: %v", err)
+				banner = wire.ParseBanner(buf[:n])
+				raw = buf[:n:n]
+				res.BannerRaw = raw
 			}
+			fp.Payload = probe
+		} else if cfg.Verbose {
+			// The port already answered the probe, so a failed follow-up
+			// dial is only worth a diagnostic; res.Error is for probe
+			// failures.
+			logging.Verbosef("service-detect dial error %s: %v\n", addr, err)
 		}
 	}
 
@@ -75,10 +141,37 @@ func DetectService(ctx context.Context, cfg Config, res port.PortResult) port.Po
 	if banner != "" {
 		if svc, conf, ok := sigs.Detect(banner); ok {
 			res.Service = svc
-			res.Confidence = conf
+			res.ServiceConfidence = conf
+		} else if cfg.Fingerprints {
+			fp.ResponseHex = hex.EncodeToString(raw)
+			res.Fingerprint = &fp
 		}
 		res.ServiceBanner = banner
 	}
 
+	// TLS inspection for well-known TLS ports.
+	if res.Proto == "tcp" && IsTLSPort(res.Port) {
+		res = InspectTLS(ctx, cfg, res)
+		if res.TLS != nil && res.Service == "" {
+			res.Service = "tls"
+			if res.Port == 443 || res.Port == 8443 {
+				res.Service = "https"
+			}
+			res.ServiceConfidence = port.ConfidenceMedium
+		}
+	}
+
+	// Optional content capture for web services.
+	if cfg.HTTPCapture > 0 && res.Proto == "tcp" && IsWebResult(res) {
+		res = CaptureHTTP(ctx, cfg, res)
+	}
+
+	if cfg.HexDump > 0 && res.Service == "" && len(raw) > 0 {
+		if len(raw) > cfg.HexDump {
+			raw = raw[:cfg.HexDump]
+		}
+		res.HexDump = hex.Dump(raw)
+	}
+
 	return res
 }
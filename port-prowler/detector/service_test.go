@@ -0,0 +1,75 @@
+package detector
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"portprowler/logging"
+	"portprowler/port"
+)
+
+func TestDetectService_RespectsContextBudget(t *testing.T) {
+	// a service that accepts and then never says anything
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+	res := port.PortResult{IP: "127.0.0.1", Port: uint16(ln.Addr().(*net.TCPAddr).Port), Proto: "tcp", State: "open"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	DetectService(ctx, Config{ServiceDetect: true, Timeout: 5 * time.Second}, res)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("detection took %v despite a 200ms budget", elapsed)
+	}
+}
+
+func TestDetectService_HexDump(t *testing.T) {
+	res := port.PortResult{IP: "127.0.0.1", Port: 9999, Proto: "tcp", State: "open",
+		ServiceBanner: "\x00\x01ZQ-proto v7\r\n", BannerRaw: []byte("\x00\x01ZQ-proto v7\r\n")}
+	got := DetectService(context.Background(), Config{ServiceDetect: true, HexDump: 8}, res)
+	if want := "00000000  00 01 5a 51 2d 70 72 6f                           |..ZQ-pro|\n"; got.HexDump != want {
+		t.Errorf("HexDump = %q, want %q", got.HexDump, want)
+	}
+
+	res.ServiceBanner, res.BannerRaw = "SSH-2.0-OpenSSH_9.6", []byte("SSH-2.0-OpenSSH_9.6\r\n")
+	if got := DetectService(context.Background(), Config{ServiceDetect: true, HexDump: 8}, res); got.Service == "" || got.HexDump != "" {
+		t.Errorf("want no dump for an identified banner, got %+v", got)
+	}
+}
+
+func TestDetectService_DialErrorIsLogged(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	res := port.PortResult{IP: "127.0.0.1", Port: uint16(ln.Addr().(*net.TCPAddr).Port), Proto: "tcp", State: "open"}
+	ln.Close() // gone by the time detection dials
+
+	var log bytes.Buffer
+	logging.SetOutput(&log)
+	defer logging.SetOutput(os.Stderr)
+	got := DetectService(context.Background(), Config{ServiceDetect: true, Timeout: time.Second, Verbose: true}, res)
+	if got.Error != "" || got.State != "open" {
+		t.Errorf("detection changed the probe outcome: state %q, error %q", got.State, got.Error)
+	}
+	if !strings.Contains(log.String(), "service-detect dial error") {
+		t.Errorf("verbose log = %q", log.String())
+	}
+}
@@ -0,0 +1,115 @@
+package detector
+
+import (
+	"fmt"
+	"strings"
+
+	"portprowler/port"
+)
+
+// Scan contexts: where the scanner sits relative to its targets. The same
+// open port means different things from inside the network and from the
+// internet.
+const (
+	ContextInternal = "internal"
+	ContextExternal = "external"
+)
+
+// ParseContext validates a --context value; empty means unspecified.
+func ParseContext(s string) (string, error) {
+	switch c := strings.ToLower(strings.TrimSpace(s)); c {
+	case "", ContextInternal, ContextExternal:
+		return c, nil
+	default:
+		return "", fmt.Errorf("unknown scan context %q (want internal or external)", s)
+	}
+}
+
+// Severity levels, lowest first.
+const (
+	SeverityInfo     = "info"
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// SeverityRank orders severity levels: 0 for info up to 4 for critical,
+// and -1 for anything else, including no severity.
+func SeverityRank(s string) int {
+	for i, level := range []string{SeverityInfo, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical} {
+		if s == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// exposure rates an exposed service from inside and from outside.
+type exposure struct {
+	internal, external string
+}
+
+// exposureByService rates services by name (as detected or guessed from the
+// port); services not listed are info internally and low externally.
+var exposureByService = map[string]exposure{
+	"http":          {SeverityInfo, SeverityInfo},
+	"https":         {SeverityInfo, SeverityInfo},
+	"ssh":           {SeverityInfo, SeverityMedium},
+	"smtp":          {SeverityInfo, SeverityLow},
+	"dns":           {SeverityInfo, SeverityLow},
+	"ftp":           {SeverityMedium, SeverityHigh},
+	"telnet":        {SeverityHigh, SeverityCritical},
+	"pop3":          {SeverityLow, SeverityMedium},
+	"imap":          {SeverityLow, SeverityMedium},
+	"msrpc":         {SeverityLow, SeverityCritical},
+	"netbios":       {SeverityLow, SeverityCritical},
+	"smb":           {SeverityLow, SeverityCritical},
+	"snmp":          {SeverityMedium, SeverityHigh},
+	"ldap":          {SeverityLow, SeverityHigh},
+	"rdp":           {SeverityLow, SeverityHigh},
+	"vnc":           {SeverityMedium, SeverityHigh},
+	"winrm":         {SeverityLow, SeverityHigh},
+	"mssql":         {SeverityLow, SeverityHigh},
+	"oracle":        {SeverityLow, SeverityHigh},
+	"mysql":         {SeverityLow, SeverityHigh},
+	"postgresql":    {SeverityLow, SeverityHigh},
+	"mongodb":       {SeverityMedium, SeverityCritical},
+	"redis":         {SeverityMedium, SeverityCritical},
+	"memcached":     {SeverityMedium, SeverityCritical},
+	"elasticsearch": {SeverityMedium, SeverityCritical},
+	"docker":        {SeverityHigh, SeverityCritical},
+}
+
+// servicePorts names the service usually found on a port, for results
+// without a detected service.
+var servicePorts = map[uint16]string{
+	21: "ftp", 22: "ssh", 23: "telnet", 25: "smtp", 53: "dns", 80: "http",
+	110: "pop3", 135: "msrpc", 139: "netbios", 143: "imap", 161: "snmp",
+	389: "ldap", 443: "https", 445: "smb", 1433: "mssql", 1521: "oracle",
+	2375: "docker", 3306: "mysql", 3389: "rdp", 5432: "postgresql",
+	5900: "vnc", 5985: "winrm", 5986: "winrm", 6379: "redis",
+	8080: "http", 8443: "https", 9200: "elasticsearch", 11211: "memcached",
+	27017: "mongodb",
+}
+
+// Severity rates an open result for the scan context (ContextInternal or
+// ContextExternal): SMB is routine inside a network but critical when
+// reachable from the internet. Results that are not open, and any result
+// in an unspecified context, have no severity.
+func Severity(r port.PortResult, context string) string {
+	if r.State != "open" || (context != ContextInternal && context != ContextExternal) {
+		return ""
+	}
+	e, ok := exposureByService[strings.ToLower(r.Service)]
+	if !ok {
+		e, ok = exposureByService[servicePorts[r.Port]]
+	}
+	if !ok {
+		e = exposure{SeverityInfo, SeverityLow}
+	}
+	if context == ContextExternal {
+		return e.external
+	}
+	return e.internal
+}
@@ -0,0 +1,51 @@
+package detector
+
+import (
+	"testing"
+
+	"portprowler/port"
+)
+
+func TestSeverity(t *testing.T) {
+	cases := []struct {
+		r                  port.PortResult
+		internal, external string
+	}{
+		{port.PortResult{Port: 445, State: "open"}, SeverityLow, SeverityCritical},
+		{port.PortResult{Port: 2222, State: "open", Service: "ssh"}, SeverityInfo, SeverityMedium},
+		{port.PortResult{Port: 443, State: "open", Service: "https"}, SeverityInfo, SeverityInfo},
+		{port.PortResult{Port: 6379, State: "open"}, SeverityMedium, SeverityCritical},
+		{port.PortResult{Port: 9999, State: "open"}, SeverityInfo, SeverityLow},
+		{port.PortResult{Port: 13306, State: "open", Service: "mysql", ServiceConfidence: "low"}, SeverityLow, SeverityHigh},
+		{port.PortResult{Port: 445, State: "closed"}, "", ""},
+	}
+	for _, c := range cases {
+		if got := Severity(c.r, ContextInternal); got != c.internal {
+			t.Errorf("%d/%s internal = %q, want %q", c.r.Port, c.r.State, got, c.internal)
+		}
+		if got := Severity(c.r, ContextExternal); got != c.external {
+			t.Errorf("%d/%s external = %q, want %q", c.r.Port, c.r.State, got, c.external)
+		}
+	}
+	if got := Severity(cases[0].r, ""); got != "" {
+		t.Errorf("no context: severity %q", got)
+	}
+	if _, err := ParseContext("dmz"); err == nil {
+		t.Error("ParseContext accepted dmz")
+	}
+}
+
+func TestDetectOSIn(t *testing.T) {
+	// Forwarded Windows ports say little about the gateway seen from outside.
+	ports := []port.PortResult{{Port: 3389, State: "open"}, {Port: 445, State: "open"}}
+	if os, _ := DetectOSIn(ports, ContextInternal); os != "Windows" {
+		t.Errorf("internal guess = %q, want Windows", os)
+	}
+	if os, _ := DetectOSIn(ports, ContextExternal); os != "" {
+		t.Errorf("external guess from ports alone = %q, want none", os)
+	}
+	banner := append(ports, port.PortResult{Port: 80, State: "open", ServiceBanner: "Server: Microsoft-IIS/10.0"})
+	if os, _ := DetectOSIn(banner, ContextExternal); os != "Windows" {
+		t.Errorf("external guess with banner = %q, want Windows", os)
+	}
+}
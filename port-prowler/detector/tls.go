@@ -0,0 +1,177 @@
+package detector
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"portprowler/logging"
+	"portprowler/netutil"
+	"portprowler/port"
+)
+
+// tlsPorts lists ports where a TLS handshake is attempted during service detection.
+var tlsPorts = map[uint16]bool{
+	443:  true,
+	465:  true,
+	636:  true,
+	853:  true,
+	990:  true,
+	993:  true,
+	995:  true,
+	5986: true,
+	8443: true,
+}
+
+// IsTLSPort reports whether TLS inspection is attempted for the given port.
+func IsTLSPort(p uint16) bool {
+	return tlsPorts[p]
+}
+
+// serverName picks the SNI for a result: explicit cfg.SNI first, then the
+// original target when it is a hostname. IP literals are never sent as SNI.
+func serverName(cfg Config, res port.PortResult) string {
+	if cfg.SNI != "" {
+		return cfg.SNI
+	}
+	if res.Target != "" && netutil.ParseIP(res.Target) == nil {
+		return res.Target
+	}
+	return ""
+}
+
+// InspectTLS performs a TLS handshake with an open TCP port and records the
+// negotiated parameters and leaf certificate in res.TLS.
+//
+// Verification is strict by default: an invalid chain or name mismatch aborts
+// the handshake and only VerifyError is recorded. With cfg.Insecure the chain
+// is still checked, but the handshake completes and the certificate is kept.
+func InspectTLS(ctx context.Context, cfg Config, res port.PortResult) port.PortResult {
+	if res.State != "open" || res.Proto != "tcp" {
+		return res
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 1 * time.Second
+	}
+	addr := net.JoinHostPort(res.IP, strconv.Itoa(int(res.Port)))
+	raw, err := dial(ctx, cfg, addr, timeout)
+	if err != nil {
+		if cfg.Verbose {
+			logging.Verbosef("tls dial error %s: %v\n", addr, err)
+		}
+		return res
+	}
+	pooled := false
+	defer func() {
+		if !pooled {
+			_ = raw.Close()
+		}
+	}()
+	_ = raw.SetDeadline(ioDeadline(ctx, timeout))
+
+	sni := serverName(cfg, res)
+	verifyName := sni
+	if verifyName == "" {
+		verifyName = res.IP
+	}
+	info := &port.TLSInfo{ServerName: sni}
+	tcfg := &tls.Config{
+		ServerName: sni,
+		MinVersion: tls.VersionTLS10,
+		// Verification is done in VerifyConnection so that insecure mode can
+		// still report why the chain would have been rejected.
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if verr := verifyChain(cs, verifyName); verr != nil {
+				info.VerifyError = verr.Error()
+				if !cfg.Insecure {
+					return verr
+				}
+				return nil
+			}
+			info.Verified = true
+			return nil
+		},
+	}
+	applyClientHello(tcfg, cfg)
+	rec := &recordingConn{Conn: raw, max: 16 * 1024}
+	conn := tls.Client(rec, tcfg)
+	err = conn.HandshakeContext(ctx)
+	// The ServerHello is available even when verification aborted the handshake.
+	if s, h, jerr := JA3S(rec.buf); jerr == nil {
+		info.JA3S = s
+		info.JA3SHash = h
+	}
+	if err != nil {
+		if info.VerifyError == "" {
+			// handshake failed before verification; not a TLS service
+			if cfg.Verbose {
+				logging.Verbosef("tls handshake error %s: %v\n", addr, err)
+			}
+			return res
+		}
+		res.TLS = info
+		return res
+	}
+
+	cs := conn.ConnectionState()
+	info.Version = tlsVersionName(cs.Version)
+	info.CipherSuite = tls.CipherSuiteName(cs.CipherSuite)
+	info.ALPN = cs.NegotiatedProtocol
+	if len(cs.PeerCertificates) > 0 {
+		leaf := cs.PeerCertificates[0]
+		info.Subject = leaf.Subject.CommonName
+		info.Issuer = leaf.Issuer.CommonName
+		info.DNSNames = leaf.DNSNames
+		info.NotBefore = leaf.NotBefore
+		info.NotAfter = leaf.NotAfter
+	}
+	res.TLS = info
+	// A web port is asked for / next (content capture, virtual hosts);
+	// that request can run over this connection.
+	if cfg.Pool != nil && IsWebResult(res) && (cs.NegotiatedProtocol == "" || cs.NegotiatedProtocol == "http/1.1") {
+		_ = raw.SetDeadline(time.Time{})
+		cfg.Pool.put(addr, sni, !info.Verified, conn)
+		pooled = true
+	}
+	if cfg.Verbose {
+		logging.Verbosef("tls %s %s cn=%q verified=%v ja3s=%s\n", addr, info.Version, info.Subject, info.Verified, info.JA3SHash)
+	}
+	return res
+}
+
+// verifyChain checks the presented chain against system roots for name.
+func verifyChain(cs tls.ConnectionState, name string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("no peer certificates")
+	}
+	inter := x509.NewCertPool()
+	for _, c := range cs.PeerCertificates[1:] {
+		inter.AddCert(c)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       name,
+		Intermediates: inter,
+	})
+	return err
+}
+
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLS1.0"
+	case tls.VersionTLS11:
+		return "TLS1.1"
+	case tls.VersionTLS12:
+		return "TLS1.2"
+	case tls.VersionTLS13:
+		return "TLS1.3"
+	default:
+		return fmt.Sprintf("0x%04x", v)
+	}
+}
@@ -0,0 +1,57 @@
+package detector
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"portprowler/port"
+)
+
+func tlsResult(t *testing.T, srv *httptest.Server) port.PortResult {
+	t.Helper()
+	addr := srv.Listener.Addr().(*net.TCPAddr)
+	return port.PortResult{
+		Target: "127.0.0.1",
+		IP:     "127.0.0.1",
+		Port:   uint16(addr.Port),
+		Proto:  "tcp",
+		State:  "open",
+	}
+}
+
+func TestInspectTLS_StrictRejectsSelfSigned(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	res := InspectTLS(context.Background(), Config{Timeout: time.Second}, tlsResult(t, srv))
+	if res.TLS == nil {
+		t.Fatalf("expected TLS info to be recorded")
+	}
+	if res.TLS.VerifyError == "" || res.TLS.Verified {
+		t.Fatalf("expected verification failure, got %+v", res.TLS)
+	}
+	if res.TLS.Subject != "" || res.TLS.Version != "" {
+		t.Fatalf("strict mode must not collect the certificate: %+v", res.TLS)
+	}
+}
+
+func TestInspectTLS_InsecureCollectsCertificate(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	cfg := Config{Timeout: time.Second, Insecure: true, SNI: "example.com"}
+	res := InspectTLS(context.Background(), cfg, tlsResult(t, srv))
+	if res.TLS == nil || res.TLS.Version == "" {
+		t.Fatalf("expected completed handshake, got %+v", res.TLS)
+	}
+	if res.TLS.ServerName != "example.com" {
+		t.Fatalf("sni = %q, want example.com", res.TLS.ServerName)
+	}
+	if len(res.TLS.DNSNames) == 0 || res.TLS.VerifyError == "" {
+		t.Fatalf("expected certificate names and a recorded verify error: %+v", res.TLS)
+	}
+}
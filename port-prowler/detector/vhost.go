@@ -0,0 +1,68 @@
+package detector
+
+import (
+	"context"
+
+	"portprowler/logging"
+	"portprowler/port"
+)
+
+// vhostLengthSlack is the relative body length difference tolerated before
+// two otherwise identical responses count as different; it absorbs
+// timestamps, nonces and similar per-request noise.
+const vhostLengthSlack = 0.1
+
+// EnumerateVHosts requests / from the web port of res once with the bare IP
+// and once per name as Host header (and SNI on TLS ports), and reports for
+// each name whether the answer differs from the bare-IP one. A different
+// status, title or redirect, or a body length off by more than 10%, marks
+// name-based virtual hosting behind the address.
+//
+// Certificates are not verified: a name the server has no certificate for
+// is expected here and still tells its content apart.
+func EnumerateVHosts(ctx context.Context, cfg Config, res port.PortResult, names []string) []port.VHost {
+	if res.State != "open" || res.Proto != "tcp" || !IsWebResult(res) || len(names) == 0 {
+		return nil
+	}
+	cfg.Insecure = true
+	base, err := requestRoot(ctx, cfg, res, "", res.IP, maxTitleScan)
+	if err != nil && cfg.Verbose {
+		logging.Verbosef("vhost baseline %s:%d: %v\n", res.IP, res.Port, err)
+	}
+	out := make([]port.VHost, 0, len(names))
+	for _, name := range names {
+		v := port.VHost{Name: name}
+		info, err := requestRoot(ctx, cfg, res, name, name, maxTitleScan)
+		if err != nil {
+			v.Error = err.Error()
+			out = append(out, v)
+			continue
+		}
+		v.StatusCode = info.StatusCode
+		v.Title = info.Title
+		v.Location = info.Location
+		v.Length = len(info.Body)
+		v.Differs = base == nil || responseDiffers(base, info)
+		if cfg.Verbose {
+			logging.Verbosef("vhost %s:%d %s status=%d title=%q differs=%v\n", res.IP, res.Port, name, v.StatusCode, v.Title, v.Differs)
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func responseDiffers(a, b *port.HTTPInfo) bool {
+	if a.StatusCode != b.StatusCode || a.Title != b.Title || a.Location != b.Location {
+		return true
+	}
+	la, lb := float64(len(a.Body)), float64(len(b.Body))
+	diff := la - lb
+	if diff < 0 {
+		diff = -diff
+	}
+	longer := la
+	if lb > longer {
+		longer = lb
+	}
+	return longer > 0 && diff/longer > vhostLengthSlack
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"portprowler/history"
+	"portprowler/output"
+	"portprowler/report"
+)
+
+// runDiff implements `portprowler diff`. It exits 0 when the reports
+// match and 1 when they differ, like diff(1).
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	format := fs.String("o", "table", "output format: table or json")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s diff [flags] <old> <new>\n", progName())
+		fmt.Fprintln(fs.Output(), "Each report is a file written with -o json or the id of a scan saved with --save.")
+		fs.PrintDefaults()
+	}
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return parseStatus(err)
+	}
+	if len(names) != 2 {
+		return exitStatus(&exitError{code: 2, msg: "error: diff needs exactly two reports", usage: true}, fs)
+	}
+	if *format != "table" && *format != "json" {
+		return exitStatus(usageErr("error: unknown output format %q (want table or json)", *format), fs)
+	}
+	old, err := loadReport(names[0])
+	if err != nil {
+		return exitStatus(err, fs)
+	}
+	cur, err := loadReport(names[1])
+	if err != nil {
+		return exitStatus(err, fs)
+	}
+	changes := report.Diff(old, cur)
+	if err := writeChanges(changes, *format, os.Stdout); err != nil {
+		return exitStatus(runtimeErr("failed to write to stdout: %v", err), fs)
+	}
+	if len(changes) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// loadReport reads a JSON report from a file or, when no such file
+// exists, from the scan history.
+func loadReport(arg string) (report.ScanReport, error) {
+	f, err := os.Open(arg)
+	if err == nil {
+		defer f.Close()
+		rep, err := report.ReadJSON(f)
+		if err != nil {
+			return report.ScanReport{}, runtimeErr("failed to read %s: %v", arg, err)
+		}
+		return rep, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return report.ScanReport{}, runtimeErr("failed to read %s: %v", arg, err)
+	}
+	store, err := history.Default()
+	if err != nil {
+		return report.ScanReport{}, runtimeErr("failed to locate scan history: %v", err)
+	}
+	rep, err := store.Load(arg)
+	if err != nil {
+		return report.ScanReport{}, runtimeErr("%s is neither a report file nor a saved scan: %v", arg, err)
+	}
+	return rep, nil
+}
+
+func writeChanges(changes []report.Change, format string, w io.Writer) error {
+	if format == "json" {
+		if changes == nil {
+			changes = []report.Change{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(changes)
+	}
+	for _, c := range changes {
+		if _, err := fmt.Fprintln(w, output.Printable(c.String())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
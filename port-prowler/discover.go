@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"portprowler/logging"
+	"portprowler/netutil"
+	"portprowler/output"
+	"portprowler/port"
+	"portprowler/report"
+	"portprowler/scanner"
+)
+
+// runDiscover implements `portprowler discover`: a ping sweep, backed by
+// tcp probes of a few ports, that lists the hosts that answered.
+func runDiscover(args []string) int {
+	fs := flag.NewFlagSet("discover", flag.ContinueOnError)
+	portsSpec := fs.String("p", "80,443", "tcp ports probed alongside the ping; any answer (open or closed) marks a host up. Empty pings only")
+	format := fs.String("o", "table", "output format: "+strings.Join(output.Formats, ", "))
+	workers := fs.Int("c", 100, "worker count (default 100)")
+	timeout := fs.Duration("t", time.Second, "per-probe timeout (default 1s)")
+	verbose := fs.Bool("v", false, "verbose logging")
+	silent := fs.Bool("silent", false, "suppress all diagnostics on stderr (results still go to stdout)")
+	var ipv6Local stringList
+	fs.Var(&ipv6Local, "ipv6-local", "also find the IPv6 hosts on this interface's segment by pinging ff02::1 and reading the neighbour cache (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s discover [flags] [--ipv6-local iface] <target|cidr> [target|cidr...]\n", progName())
+		fs.PrintDefaults()
+	}
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return parseStatus(err)
+	}
+	logging.SetSilent(*silent)
+	return exitStatus(discover(names, ipv6Local, *portsSpec, *format, *workers, *timeout, *verbose), fs)
+}
+
+func discover(names, ipv6Local []string, portsSpec, format string, workers int, timeout time.Duration, verbose bool) error {
+	if len(names) == 0 && len(ipv6Local) == 0 {
+		return &exitError{code: 2, msg: "error: at least one target, CIDR range or --ipv6-local interface is required", usage: true}
+	}
+	for _, iface := range ipv6Local {
+		if _, err := net.InterfaceByName(iface); err != nil {
+			return usageErr("error: --ipv6-local %s: %v", iface, err)
+		}
+	}
+	if !validFormat(format) {
+		return usageErr("error: unknown output format %q", format)
+	}
+	if workers <= 0 || workers > 10000 {
+		return usageErr("error: invalid worker count (-c). Provide a positive value up to 10000.")
+	}
+	if timeout <= 0 {
+		return usageErr("error: -t must be positive")
+	}
+	var ports []uint16
+	if portsSpec != "" {
+		var err error
+		if ports, err = port.ParsePortSpec(portsSpec); err != nil {
+			return usageErr("Invalid port spec %q: %v", portsSpec, err)
+		}
+	}
+
+	var targets []port.Target
+	for _, name := range names {
+		if strings.Contains(name, "/") {
+			ips, err := netutil.ExpandCIDR(name)
+			if err != nil {
+				return usageErr("error: %v", err)
+			}
+			for _, ip := range ips {
+				targets = append(targets, port.Target{Name: ip, IP: ip})
+			}
+			continue
+		}
+		ip, err := netutil.ResolveTargetToIPv4(name)
+		if err != nil {
+			return runtimeErr("failed to resolve target %s: %v", name, err)
+		}
+		targets = append(targets, port.Target{Name: name, IP: ip})
+	}
+	// Hosts found on IPv6 segments are up already; their evidence is how
+	// they were found.
+	var found []port.PortResult
+	for _, iface := range ipv6Local {
+		for _, n := range ipv6Neighbors(iface, timeout, verbose) {
+			targets = append(targets, port.Target{Name: n.IP, IP: n.IP})
+			found = append(found, port.PortResult{Target: n.IP, IP: n.IP, Proto: string(port.ScanPing), State: "up", Service: n.Via})
+		}
+	}
+	if len(targets) == 0 {
+		return runtimeErr("no IPv6 hosts found on %s", strings.Join(ipv6Local, ", "))
+	}
+	logging.Infof("Discovering %d address(es); tcp ports: %s", len(targets), portsSpec)
+
+	mgr := scanner.NewManager(scanner.Config{
+		Targets:    targets,
+		Ports:      ports,
+		ScanTCP:    len(ports) > 0,
+		ScanPing:   true,
+		Workers:    workers,
+		TCPTimeout: timeout,
+		UDPTimeout: timeout,
+		Verbose:    verbose,
+	})
+	resultsCh, err := mgr.Run(context.Background())
+	if err != nil {
+		if errors.Is(err, scanner.ErrNeedPriv) {
+			return &exitError{code: 3, msg: err.Error()}
+		}
+		return runtimeErr("failed to start scanner manager: %v", err)
+	}
+	results := found
+	for r := range resultsCh {
+		results = append(results, r)
+	}
+	snap := mgr.Stats()
+	rep := report.Build(report.Meta{Started: snap.Started, Finished: snap.Finished, PortSpec: portsSpec}, targets, results)
+
+	up := rep.Hosts[:0]
+	for _, h := range rep.Hosts {
+		if len(evidence(h)) > 0 {
+			up = append(up, h)
+		}
+	}
+	rep.Hosts = up
+	summary := fmt.Sprintf("%d of %d host(s) up in %s", len(up), len(targets), snap.Elapsed.Round(time.Millisecond))
+
+	if format != "table" {
+		if err := output.Render(format, rep, summary, os.Stdout); err != nil {
+			return runtimeErr("failed to write to stdout: %v", err)
+		}
+		return nil
+	}
+	if err := printDiscovered(rep, summary, os.Stdout); err != nil {
+		return runtimeErr("failed to write to stdout: %v", err)
+	}
+	return nil
+}
+
+// ipv6Neighbors finds the other IPv6 hosts on the segment of iface:
+// those answering a ping of ff02::1 within timeout (when raw sockets are
+// available) and those in the neighbour cache. Either source failing is
+// a warning.
+func ipv6Neighbors(iface string, timeout time.Duration, verbose bool) []netutil.Neighbor {
+	var pinged []netutil.Neighbor
+	if ok, _ := netutil.CanOpenRawSocket(); ok {
+		conn, err := net.ListenPacket("ip6:ipv6-icmp", "::")
+		if err == nil {
+			pinged, err = netutil.PingAllNodes(context.Background(), conn, iface, timeout)
+			conn.Close()
+		}
+		if err != nil {
+			logging.Warnf("--ipv6-local %s: multicast ping failed: %v", iface, err)
+		}
+	} else {
+		logging.Warnf("--ipv6-local %s: pinging ff02::1 needs raw socket privileges; using the neighbour cache only", iface)
+	}
+	cached, err := netutil.NeighborCache(iface)
+	if err != nil {
+		logging.Warnf("--ipv6-local %s: %v", iface, err)
+	}
+	all := netutil.MergeNeighbors(pinged, cached)
+	// The multicast ping loops back, so this host answers too.
+	if ifc, err := net.InterfaceByName(iface); err == nil {
+		own, _ := ifc.Addrs()
+		others := all[:0]
+	next:
+		for _, n := range all {
+			for _, a := range own {
+				if ipn, ok := a.(*net.IPNet); ok && ipn.IP.Equal(netutil.ParseIP(n.IP)) {
+					continue next
+				}
+			}
+			others = append(others, n)
+		}
+		all = others
+	}
+	if verbose {
+		for _, n := range all {
+			logging.Verbosef("%s: %s (%s) %s", iface, n.IP, n.Via, n.MAC)
+		}
+	}
+	logging.Infof("--ipv6-local %s: found %d host(s)", iface, len(all))
+	return all
+}
+
+// evidence lists why a host counts as up: a ping reply or tcp ports that
+// answered.
+func evidence(h report.HostReport) []string {
+	results := append([]port.PortResult(nil), h.Results...)
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Port < results[j].Port })
+	var ev []string
+	for _, r := range results {
+		switch {
+		case r.Proto == string(port.ScanPing) && r.State == "up":
+			ev = append(ev, r.Service)
+		case r.Proto == string(port.ScanTCP) && (r.State == "open" || r.State == "closed"):
+			ev = append(ev, fmt.Sprintf("%d/tcp %s", r.Port, r.State))
+		}
+	}
+	return ev
+}
+
+func printDiscovered(rep report.ScanReport, summary string, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOST\tIP\tRTT\tEVIDENCE")
+	for _, h := range rep.Hosts {
+		fmt.Fprintf(tw, "%s\t%s\t%dms\t%s\n", output.Printable(h.Target), h.IP, h.RTT.MedianMillis, output.Printable(strings.Join(evidence(h), ", ")))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w, summary)
+	return err
+}
+
+func validFormat(format string) bool {
+	for _, f := range output.Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"portprowler/age"
+	"portprowler/cloud"
+	"portprowler/detector"
+	"portprowler/logging"
+	"portprowler/output"
+	"portprowler/redact"
+	"portprowler/report"
+	"portprowler/rules"
+)
+
+// analyzeReport attaches the --host-note notes and the cloud instances of
+// inv, flags hosts that look like tarpits or honeypots, rates open ports
+// for the report's scan context, guesses each host's OS when osDetect is
+// set, names unidentified open ports after their well-known service,
+// applies the --rules rules rs and orders hosts by RTT when sortRTT is
+// set.
+func analyzeReport(rep *report.ScanReport, hostNotes []string, inv cloud.Inventory, rs rules.Set, osDetect, sortRTT bool) {
+	for _, hn := range hostNotes {
+		host, text, _ := strings.Cut(hn, "=")
+		if !rep.AddHostNote(host, text) {
+			logging.Warnf("--host-note %q matches no scanned host", host)
+		}
+	}
+	if inv != nil {
+		n := inv.Tag(rep)
+		logging.Infof("--cloud-inventory: %d of %d hosts are known cloud instances", n, len(rep.Hosts))
+	}
+
+	// Flag hosts that look like tarpits or honeypots; their results are kept.
+	for i := range rep.Hosts {
+		h := &rep.Hosts[i]
+		h.Deception = detector.DetectDeception(h.Results)
+		if len(h.Deception) > 0 {
+			logging.Warnf("%s (%s) may be a tarpit or honeypot: %s", h.Target, h.IP, strings.Join(h.Deception, "; "))
+		}
+		if h.Capped {
+			logging.Warnf("%s (%s): stopped scanning after --max-open-per-host open ports; remaining ports were skipped", h.Target, h.IP)
+		}
+	}
+
+	if rep.Meta.Context != "" {
+		for i := range rep.Hosts {
+			for j := range rep.Hosts[i].Results {
+				r := &rep.Hosts[i].Results[j]
+				r.Severity = detector.Severity(*r, rep.Meta.Context)
+			}
+		}
+	}
+
+	// Perform OS detection once per host (based on all its open-port results), if requested.
+	if osDetect {
+		for i := range rep.Hosts {
+			h := &rep.Hosts[i]
+			h.OSGuess, h.OSConfidence = detector.DetectOSIn(h.Results, rep.Meta.Context)
+		}
+	}
+
+	if len(rs) > 0 {
+		matched, dropped := rs.Apply(rep)
+		logging.Infof("--rules: %d results matched, %d dropped", matched, dropped)
+	}
+	if sortRTT {
+		rep.SortByRTT()
+	}
+}
+
+// redactReport replaces names and addresses in rep with tokens from the
+// --redact mapping file at path (the default one when empty) and saves
+// the tokens it added.
+func redactReport(rep *report.ScanReport, path string) error {
+	if path == "" {
+		p, err := redact.DefaultPath()
+		if err != nil {
+			return runtimeErr("failed to locate the redaction mapping: %v", err)
+		}
+		path = p
+	}
+	m, err := redact.Load(path)
+	if err != nil {
+		return runtimeErr("failed to read the redaction mapping: %v", err)
+	}
+	m.Report(rep)
+	if err := m.Save(path); err != nil {
+		return runtimeErr("failed to write the redaction mapping: %v", err)
+	}
+	return nil
+}
+
+// writeOutputs renders rep for every output spec, then writes the
+// fingerprint export and the -f table when requested. Files are encrypted
+// to recipients, if any, and named with age.Ext appended.
+func writeOutputs(rep report.ScanReport, summary string, specs []output.Spec, fingerprintOut, fileOut string, recipients []*age.Recipient) error {
+	// Render every requested output from the same report.
+	for _, spec := range specs {
+		var buf bytes.Buffer
+		var err error
+		if spec.Format == "template" {
+			err = output.RenderTemplate(spec.Template, rep, summary, &buf)
+		} else {
+			err = output.Render(spec.Format, rep, summary, &buf)
+		}
+		if err != nil {
+			return runtimeErr("failed to render %s output: %v", spec.Format, err)
+		}
+		if spec.Path == "" {
+			if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+				return runtimeErr("failed to write to stdout: %v", err)
+			}
+			continue
+		}
+		if err := writeReportFile(spec.Path, buf.Bytes(), recipients); err != nil {
+			return runtimeErr("failed to write %s output: %v", spec.Format, err)
+		}
+	}
+
+	if fingerprintOut != "" {
+		var buf bytes.Buffer
+		n, ferr := output.WriteFingerprints(rep, &buf)
+		if ferr == nil {
+			ferr = writeReportFile(fingerprintOut, buf.Bytes(), recipients)
+		}
+		if ferr != nil {
+			return runtimeErr("failed to write fingerprints: %v", ferr)
+		}
+		logging.Infof("Wrote %d unmatched fingerprint(s) to %s", n, fingerprintOut)
+	}
+
+	// If file output requested, ensure parent dir exists and write atomically
+	// ensure result directory exists
+	if fileOut != "" {
+		var buf bytes.Buffer
+		if err := output.Render("table", rep, summary, &buf); err != nil {
+			return runtimeErr("failed to render table output: %v", err)
+		}
+		outDir := "result"
+		if err := os.MkdirAll(outDir, 0o755); err != nil {
+			return runtimeErr("failed to create result dir: %v", err)
+		}
+
+		outPath := filepath.Join(outDir, fileOut)
+		if err := writeReportFile(outPath, buf.Bytes(), recipients); err != nil {
+			return runtimeErr("failed to write output file: %v", err)
+		}
+	}
+	return nil
+}
+
+// writeReportFile writes data to path, or encrypted to path+age.Ext when
+// there are recipients.
+func writeReportFile(path string, data []byte, recipients []*age.Recipient) error {
+	if len(recipients) == 0 {
+		return output.WriteAtomic(path, data)
+	}
+	enc, err := age.Encrypt(data, recipients...)
+	if err != nil {
+		return err
+	}
+	return output.WriteAtomic(path+age.Ext, enc)
+}
+
+// parseRecipients parses an --encrypt value: age: and an age1...
+// recipient or a file of them.
+func parseRecipients(s string) ([]*age.Recipient, error) {
+	v, ok := strings.CutPrefix(s, "age:")
+	if !ok || v == "" {
+		return nil, fmt.Errorf("%q: want age:<recipient> or age:<recipients file>", s)
+	}
+	if strings.HasPrefix(v, "age1") {
+		r, err := age.ParseRecipient(v)
+		if err != nil {
+			return nil, err
+		}
+		return []*age.Recipient{r}, nil
+	}
+	return age.LoadRecipients(v)
+}
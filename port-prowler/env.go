@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envPrefix starts the environment variables that provide flag defaults.
+const envPrefix = "PORTPROWLER_"
+
+// envNames spells out the short flags; longer flags map to their name in
+// upper case with dashes as underscores (--tcp-timeout: PORTPROWLER_TCP_TIMEOUT).
+var envNames = map[string]string{
+	"c": "WORKERS",
+	"t": "TIMEOUT",
+	"o": "OUTPUT",
+	"p": "PORTS",
+	"f": "FILE",
+	"s": "STEALTH",
+	"v": "VERBOSE",
+}
+
+// envName returns the environment variable for the flag name.
+func envName(flagName string) string {
+	if n, ok := envNames[flagName]; ok {
+		return envPrefix + n
+	}
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv sets every flag of fs that was not given on the command line
+// from its PORTPROWLER_* variable, if set. Repeatable flags take a
+// comma-separated list.
+func applyEnv(fs *flag.FlagSet) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || given[f.Name] {
+			return
+		}
+		name := envName(f.Name)
+		v, ok := os.LookupEnv(name)
+		if !ok || v == "" {
+			return
+		}
+		values := []string{v}
+		if _, repeatable := f.Value.(*stringList); repeatable {
+			values = strings.Split(v, ",")
+		}
+		for _, s := range values {
+			if serr := f.Value.Set(strings.TrimSpace(s)); serr != nil {
+				err = fmt.Errorf("invalid value %q for %s: %v", v, name, serr)
+				return
+			}
+		}
+	})
+	return err
+}
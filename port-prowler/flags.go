@@ -0,0 +1,15 @@
+package main
+
+import "strings"
+
+// stringList is a repeatable string flag (e.g. --host-note a=x --host-note b=y).
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
@@ -1,3 +1,10 @@
 module portprowler
 
 go 1.20
+
+require (
+	filippo.io/age v1.2.1
+	golang.org/x/crypto v0.31.0
+)
+
+require golang.org/x/sys v0.28.0 // indirect
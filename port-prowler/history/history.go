@@ -0,0 +1,140 @@
+// Package history keeps scan reports saved with --save so later runs can
+// list them, show them again and diff against them.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"portprowler/output"
+	"portprowler/report"
+)
+
+// idLayout names saved scans after their start time (UTC).
+const idLayout = "20060102-150405"
+
+// Store is a directory of saved reports, one JSON file per scan.
+type Store struct {
+	Dir string
+}
+
+// Entry summarises a saved scan for listings.
+type Entry struct {
+	ID      string
+	Started time.Time
+	Targets []string
+	Open    int // open ports across all hosts
+	Note    string
+}
+
+// Default returns the store under the user's configuration directory
+// (e.g. ~/.config/portprowler/history).
+func Default() (Store, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return Store{}, err
+	}
+	return Store{Dir: filepath.Join(dir, "portprowler", "history")}, nil
+}
+
+// Save writes rep to the store and returns its id. Scans started in the
+// same second get a numeric suffix.
+func (s Store) Save(rep report.ScanReport) (string, error) {
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	started := rep.Meta.Started
+	if started.IsZero() {
+		started = time.Now()
+	}
+	base := started.UTC().Format(idLayout)
+	id := base
+	for n := 2; ; n++ {
+		if _, err := os.Stat(s.path(id)); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	if err := output.WriteAtomic(s.path(id), append(data, '\n')); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Load reads the saved scan with the given id.
+func (s Store) Load(id string) (report.ScanReport, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return report.ScanReport{}, fmt.Errorf("invalid history id %q", id)
+	}
+	f, err := os.Open(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return report.ScanReport{}, fmt.Errorf("no saved scan %q", id)
+	}
+	if err != nil {
+		return report.ScanReport{}, err
+	}
+	defer f.Close()
+	rep, err := report.ReadJSON(f)
+	if err != nil {
+		return report.ScanReport{}, fmt.Errorf("saved scan %s: %v", id, err)
+	}
+	return rep, nil
+}
+
+// List returns the saved scans, oldest first. A missing store is empty.
+func (s Store) List() ([]Entry, error) {
+	names, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var out []Entry
+	for _, name := range names {
+		id := strings.TrimSuffix(filepath.Base(name), ".json")
+		rep, err := s.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		e := Entry{ID: id, Started: rep.Meta.Started, Note: rep.Meta.Note}
+		for _, h := range rep.Hosts {
+			e.Targets = append(e.Targets, h.Target)
+			for _, r := range h.Results {
+				if r.State == "open" {
+					e.Open++
+				}
+			}
+		}
+		out = append(out, e)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if !a.Started.Equal(b.Started) {
+			return a.Started.Before(b.Started)
+		}
+		if len(a.ID) != len(b.ID) {
+			return len(a.ID) < len(b.ID) // -2 before -10
+		}
+		return a.ID < b.ID
+	})
+	return out, nil
+}
+
+// Latest returns the id of the most recent saved scan, or "" when the
+// store is empty.
+func (s Store) Latest() (string, error) {
+	entries, err := s.List()
+	if err != nil || len(entries) == 0 {
+		return "", err
+	}
+	return entries[len(entries)-1].ID, nil
+}
+
+func (s Store) path(id string) string {
+	return filepath.Join(s.Dir, id+".json")
+}
@@ -0,0 +1,88 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"portprowler/port"
+	"portprowler/report"
+)
+
+func TestStore(t *testing.T) {
+	s := Store{Dir: t.TempDir()}
+	if entries, err := s.List(); err != nil || len(entries) != 0 {
+		t.Fatalf("empty store: %v, %v", entries, err)
+	}
+
+	started := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	rep := report.Build(report.Meta{Started: started, Note: "baseline"},
+		[]port.Target{{Name: "web.example", IP: "192.0.2.10"}},
+		[]port.PortResult{
+			{Target: "web.example", IP: "192.0.2.10", Port: 22, Proto: "tcp", State: "open"},
+			{Target: "web.example", IP: "192.0.2.10", Port: 23, Proto: "tcp", State: "closed"},
+		})
+	id, err := s.Save(rep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "20240501-123000" {
+		t.Fatalf("id = %q", id)
+	}
+	id2, err := s.Save(rep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id2 != "20240501-123000-2" {
+		t.Fatalf("second id = %q", id2)
+	}
+
+	entries, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].ID != id || entries[0].Open != 1 || entries[0].Note != "baseline" {
+		t.Fatalf("entries = %+v", entries)
+	}
+	if latest, _ := s.Latest(); latest != id2 {
+		t.Fatalf("latest = %q, want %q", latest, id2)
+	}
+
+	got, err := s.Load(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Meta.Started.Equal(started) || len(got.Hosts) != 1 || len(got.Hosts[0].Results) != 2 {
+		t.Fatalf("loaded = %+v", got)
+	}
+	if _, err := s.Load("missing"); err == nil {
+		t.Fatal("expected an error for an unknown id")
+	}
+	if _, err := s.Load("../x"); err == nil {
+		t.Fatal("expected an error for a path id")
+	}
+}
+
+func TestIssueLog(t *testing.T) {
+	s := Store{Dir: t.TempDir()}
+	l, err := s.Issues()
+	if err != nil || len(l.Issues) != 0 {
+		t.Fatalf("empty log: %+v, %v", l, err)
+	}
+	if err := l.Add(Issue{Tracker: "github", Project: "acme/infra", Finding: "portprowler:db.example:6379/tcp", ID: "42"}); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := s.List(); err != nil || len(entries) != 0 {
+		t.Fatalf("the issue log was listed as a scan: %+v, %v", entries, err)
+	}
+
+	l, err = s.Issues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if is, ok := l.Find("github", "acme/infra", "portprowler:db.example:6379/tcp"); !ok || is.ID != "42" {
+		t.Errorf("Find = %+v, %v", is, ok)
+	}
+	if _, ok := l.Find("jira", "SEC", "portprowler:db.example:6379/tcp"); ok {
+		t.Error("an issue in another tracker was found")
+	}
+}
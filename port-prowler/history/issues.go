@@ -0,0 +1,65 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"portprowler/output"
+)
+
+// Issue records a ticket filed for a finding, so it is filed only once.
+type Issue struct {
+	Tracker string    `json:"tracker"` // "github" or "jira"
+	Project string    `json:"project"` // repository or project key
+	Finding string    `json:"finding"` // key of the finding, host and port
+	ID      string    `json:"id"`      // e.g. "42" or "SEC-17"
+	URL     string    `json:"url,omitempty"`
+	Filed   time.Time `json:"filed"`
+}
+
+// IssueLog is the list of filed tickets kept in the store.
+type IssueLog struct {
+	path   string
+	Issues []Issue
+}
+
+// Issues reads the store's issue log. It lives in a subdirectory so List
+// does not take it for a scan; a missing log is empty.
+func (s Store) Issues() (*IssueLog, error) {
+	l := &IssueLog{path: filepath.Join(s.Dir, "issues", "issues.json")}
+	data, err := os.ReadFile(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &l.Issues); err != nil {
+		return nil, fmt.Errorf("issue log %s: %v", l.path, err)
+	}
+	return l, nil
+}
+
+// Find returns the ticket filed for finding in the tracker's project.
+func (l *IssueLog) Find(tracker, project, finding string) (Issue, bool) {
+	for _, is := range l.Issues {
+		if is.Tracker == tracker && is.Project == project && is.Finding == finding {
+			return is, true
+		}
+	}
+	return Issue{}, false
+}
+
+// Add records a filed ticket and writes the log.
+func (l *IssueLog) Add(is Issue) error {
+	l.Issues = append(l.Issues, is)
+	data, err := json.MarshalIndent(l.Issues, "", "  ")
+	if err != nil {
+		return err
+	}
+	return output.WriteAtomic(l.path, append(data, '\n'))
+}
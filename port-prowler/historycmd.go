@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"portprowler/history"
+	"portprowler/logging"
+	"portprowler/output"
+	"portprowler/report"
+	"portprowler/stats"
+)
+
+// runHistory implements `portprowler history`, which lists the scans
+// saved with --save (`history list`, the default) or prints one of them
+// again (`history show <id>`).
+func runHistory(args []string) int {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	format := fs.String("o", "table", "output format for show: "+strings.Join(output.Formats, ", "))
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s history [list]\n", progName())
+		fmt.Fprintf(fs.Output(), "       %s history show [flags] <id|latest>\n", progName())
+		fs.PrintDefaults()
+	}
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return parseStatus(err)
+	}
+	store, err := history.Default()
+	if err != nil {
+		return exitStatus(runtimeErr("failed to locate scan history: %v", err), fs)
+	}
+	switch {
+	case len(names) == 0 || len(names) == 1 && names[0] == "list":
+		return exitStatus(listHistory(store), fs)
+	case len(names) == 2 && names[0] == "show":
+		if !validFormat(*format) {
+			return exitStatus(usageErr("error: unknown output format %q", *format), fs)
+		}
+		return exitStatus(showHistory(store, names[1], *format), fs)
+	default:
+		return exitStatus(&exitError{code: 2, usage: true}, fs)
+	}
+}
+
+func listHistory(store history.Store) error {
+	entries, err := store.List()
+	if err != nil {
+		return runtimeErr("failed to read scan history: %v", err)
+	}
+	if len(entries) == 0 {
+		logging.Infof("No saved scans in %s (save one with --save)", store.Dir)
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTARTED\tOPEN\tTARGETS\tNOTE")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", e.ID, e.Started.Local().Format(time.RFC3339), e.Open, strings.Join(e.Targets, ","), e.Note)
+	}
+	if err := tw.Flush(); err != nil {
+		return runtimeErr("failed to write to stdout: %v", err)
+	}
+	return nil
+}
+
+func showHistory(store history.Store, id, format string) error {
+	if id == "latest" {
+		latest, err := store.Latest()
+		if err != nil {
+			return runtimeErr("failed to read scan history: %v", err)
+		}
+		if latest == "" {
+			return runtimeErr("no saved scans in %s", store.Dir)
+		}
+		id = latest
+	}
+	rep, err := store.Load(id)
+	if err != nil {
+		return runtimeErr("%v", err)
+	}
+	if err := output.Render(format, rep, reportSummary(rep), os.Stdout); err != nil {
+		return runtimeErr("failed to write to stdout: %v", err)
+	}
+	return nil
+}
+
+// saveHistory stores rep in the scan history (--save).
+func saveHistory(rep report.ScanReport) error {
+	store, err := history.Default()
+	if err != nil {
+		return runtimeErr("failed to locate scan history: %v", err)
+	}
+	id, err := store.Save(rep)
+	if err != nil {
+		return runtimeErr("failed to save scan: %v", err)
+	}
+	logging.Infof("Saved scan %s to %s", id, store.Dir)
+	return nil
+}
+
+// reportSummary recomputes the summary line of a saved report.
+func reportSummary(rep report.ScanReport) string {
+	c := stats.New()
+	seen := make(map[string]bool)
+	for _, h := range rep.Hosts {
+		for _, r := range h.Results {
+			key := fmt.Sprintf("%s|%d|%s", r.IP, r.Port, r.Proto)
+			if !seen[key] {
+				seen[key] = true
+				c.Record(r)
+			}
+		}
+	}
+	snap := c.Snapshot()
+	snap.Started, snap.Finished = rep.Meta.Started, rep.Meta.Finished
+	snap.Elapsed = snap.Finished.Sub(snap.Started)
+	return snap.Summary()
+}
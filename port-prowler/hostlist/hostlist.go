@@ -0,0 +1,264 @@
+// Package hostlist reads the hostnames of a domain from a DNS zone file
+// (RFC 1035 master format, as exported by BIND, Route 53, Cloudflare or
+// `dig axfr`) or a subdomain list (one name per line, as subfinder and
+// similar tools write), for scanning the whole domain.
+package hostlist
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// Entry is one hostname and the addresses the input listed for it, if
+// any. Names without addresses (CNAMEs, plain lists) must be resolved.
+type Entry struct {
+	Name  string
+	Addrs []string
+}
+
+// maxLine bounds a line (or parenthesised record) of the input.
+const maxLine = 64 << 10
+
+// Read parses a zone file or a subdomain list; a zone file is recognised
+// by $ORIGIN or $TTL directives or an SOA record. Names are returned in
+// input order without duplicates, lower-cased and without the trailing
+// dot. From zone files, the owners of A, AAAA and CNAME records are
+// taken, with their A and AAAA addresses; wildcards and other record
+// types are skipped. Subdomain lists hold a name per line, optionally
+// followed by comma-separated addresses, or JSON objects with "host" (or
+// "name") and "ip" or "a"/"aaaa" lists, as `subfinder -oJ` and dnsx
+// write. Blank lines and # comments are ignored.
+func Read(r io.Reader) ([]Entry, error) {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 4096), maxLine)
+	zone := false
+	for sc.Scan() {
+		line := sc.Text()
+		if len(lines) == 0 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		lines = append(lines, line)
+		f := strings.Fields(stripComment(line))
+		if len(f) > 0 && (strings.EqualFold(f[0], "$ORIGIN") || strings.EqualFold(f[0], "$TTL")) {
+			zone = true
+		}
+		for _, t := range f {
+			if strings.EqualFold(t, "SOA") {
+				zone = true
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	l := &list{index: make(map[string]int)}
+	var err error
+	if zone {
+		err = l.zone(lines)
+	} else {
+		err = l.names(lines)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(l.out) == 0 {
+		return nil, errors.New("no hostnames found")
+	}
+	return l.out, nil
+}
+
+type list struct {
+	out   []Entry
+	index map[string]int
+}
+
+// add records name with addrs, merging duplicates.
+func (l *list) add(name string, addrs ...string) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	i, ok := l.index[name]
+	if !ok {
+		i = len(l.out)
+		l.index[name] = i
+		l.out = append(l.out, Entry{Name: name})
+	}
+	e := &l.out[i]
+next:
+	for _, a := range addrs {
+		ip := net.ParseIP(strings.TrimSpace(a))
+		if ip == nil {
+			continue
+		}
+		s := ip.String()
+		for _, have := range e.Addrs {
+			if have == s {
+				continue next
+			}
+		}
+		e.Addrs = append(e.Addrs, s)
+	}
+}
+
+// zone reads the records of a master file.
+func (l *list) zone(lines []string) error {
+	origin, owner := "", ""
+	for n := 0; n < len(lines); n++ {
+		start := n + 1
+		text := stripComment(lines[n])
+		// A record continues over lines until its parentheses close.
+		for strings.Count(text, "(") > strings.Count(text, ")") {
+			if n++; n == len(lines) {
+				return fmt.Errorf("line %d: unclosed parenthesis", start)
+			}
+			text += " " + stripComment(lines[n])
+		}
+		indented := text != "" && (text[0] == ' ' || text[0] == '\t')
+		text = strings.NewReplacer("(", " ", ")", " ").Replace(text)
+		f := strings.Fields(text)
+		if len(f) == 0 {
+			continue
+		}
+		switch strings.ToUpper(f[0]) {
+		case "$ORIGIN":
+			if len(f) < 2 {
+				return fmt.Errorf("line %d: $ORIGIN without a name", start)
+			}
+			origin = absolute(f[1], origin)
+			continue
+		case "$TTL":
+			continue
+		case "$INCLUDE", "$GENERATE":
+			return fmt.Errorf("line %d: %s is not supported; expand the zone first (e.g. named-compilezone)", start, f[0])
+		}
+		if !indented {
+			owner, f = f[0], f[1:]
+			if owner == "@" {
+				if origin == "" {
+					return fmt.Errorf("line %d: @ without $ORIGIN", start)
+				}
+				owner = origin
+			} else if !strings.HasSuffix(owner, ".") {
+				if origin == "" {
+					return fmt.Errorf("line %d: relative name %s without $ORIGIN; add a $ORIGIN line", start, owner)
+				}
+				owner = absolute(owner, origin)
+			}
+		}
+		if owner == "" {
+			return fmt.Errorf("line %d: record without an owner name", start)
+		}
+		// Skip the optional TTL and class, in either order.
+		for len(f) > 0 && (isTTL(f[0]) || isClass(f[0])) {
+			f = f[1:]
+		}
+		if len(f) == 0 {
+			return fmt.Errorf("line %d: record without a type", start)
+		}
+		rtype, rdata := strings.ToUpper(f[0]), f[1:]
+		if strings.HasPrefix(owner, "*.") {
+			continue
+		}
+		switch rtype {
+		case "A", "AAAA":
+			if len(rdata) == 0 || net.ParseIP(rdata[0]) == nil {
+				return fmt.Errorf("line %d: invalid %s record", start, rtype)
+			}
+			l.add(owner, rdata[0])
+		case "CNAME":
+			l.add(owner)
+		}
+	}
+	return nil
+}
+
+// names reads a subdomain list.
+func (l *list) names(lines []string) error {
+	for n, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '{' {
+			var rec struct {
+				Host string   `json:"host"`
+				Name string   `json:"name"`
+				IP   string   `json:"ip"`
+				A    []string `json:"a"`
+				AAAA []string `json:"aaaa"`
+			}
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				return fmt.Errorf("line %d: %v", n+1, err)
+			}
+			if rec.Host == "" {
+				rec.Host = rec.Name
+			}
+			if rec.Host == "" {
+				return fmt.Errorf("line %d: JSON record without host", n+1)
+			}
+			l.add(rec.Host, append(append([]string{rec.IP}, rec.A...), rec.AAAA...)...)
+			continue
+		}
+		parts := strings.Split(line, ",")
+		name := strings.TrimSpace(parts[0])
+		if strings.ContainsAny(name, " \t/") {
+			return fmt.Errorf("line %d: %q is not a hostname", n+1, name)
+		}
+		l.add(name, parts[1:]...)
+	}
+	return nil
+}
+
+// absolute qualifies a zone-relative name with origin.
+func absolute(name, origin string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	if origin == "" {
+		return name + "."
+	}
+	return name + "." + origin
+}
+
+func isTTL(s string) bool {
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		return false
+	}
+	// Seconds, or BIND units such as 1h30m.
+	for _, c := range strings.ToLower(s) {
+		if !strings.ContainsRune("0123456789smhdw", c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isClass(s string) bool {
+	switch strings.ToUpper(s) {
+	case "IN", "CH", "HS", "CS":
+		return true
+	}
+	return false
+}
+
+// stripComment drops a ; comment outside quotes.
+func stripComment(s string) string {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				return s[:i]
+			}
+		}
+	}
+	return s
+}
@@ -0,0 +1,69 @@
+package hostlist
+
+import (
+	"strings"
+	"testing"
+)
+
+const zoneFile = `$ORIGIN example.com.
+$TTL 3600
+@	IN	SOA	ns1.example.com. hostmaster.example.com. (
+		2024050101 ; serial
+		7200 3600 1209600 300 )
+	IN	NS	ns1
+	IN	A	192.0.2.1
+www	300	IN	A	192.0.2.10
+www		IN	AAAA	2001:db8::10
+WWW.example.com.	IN	A	192.0.2.11
+shop	IN	CNAME	shops.example.net.
+*	IN	A	192.0.2.99
+_dmarc	IN	TXT	"v=DMARC1; p=none"
+mail	IN	MX	10 mx.example.com.
+$ORIGIN dev.example.com.
+api	1h	IN	A	198.51.100.5
+`
+
+func TestReadZone(t *testing.T) {
+	entries, err := Read(strings.NewReader(zoneFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name+"="+strings.Join(e.Addrs, ","))
+	}
+	want := "example.com=192.0.2.1 www.example.com=192.0.2.10,2001:db8::10,192.0.2.11 shop.example.com= api.dev.example.com=198.51.100.5"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got  %s\nwant %s", strings.Join(got, " "), want)
+	}
+
+	for _, bad := range []string{
+		"$TTL 300\nwww IN A 192.0.2.1\n",           // relative name without $ORIGIN
+		"$ORIGIN example.com.\nwww IN A nowhere\n", // bad address
+		"$ORIGIN example.com.\n@ IN SOA ns. h. ( 1 2\n",
+		"$ORIGIN example.com.\n$INCLUDE other.zone\n",
+	} {
+		if _, err := Read(strings.NewReader(bad)); err == nil {
+			t.Errorf("want an error for %q", bad)
+		}
+	}
+}
+
+func TestReadList(t *testing.T) {
+	list := "# subfinder\nwww.example.com\nAPI.example.com.\n\nvpn.example.com,203.0.113.7\n" +
+		`{"host":"mail.example.com","input":"example.com","source":"crtsh"}` + "\n" +
+		`{"host":"cdn.example.com","a":["203.0.113.8","203.0.113.9"]}` + "\nwww.example.com\n"
+	entries, err := Read(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 || entries[1].Name != "api.example.com" || entries[2].Addrs[0] != "203.0.113.7" ||
+		entries[3].Name != "mail.example.com" || len(entries[4].Addrs) != 2 {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+	for _, bad := range []string{"", "# nothing\n", "not a host\n", "{\"source\":\"x\"}\n"} {
+		if _, err := Read(strings.NewReader(bad)); err == nil {
+			t.Errorf("want an error for %q", bad)
+		}
+	}
+}
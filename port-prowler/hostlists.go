@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"portprowler/ct"
+	"portprowler/hostlist"
+	"portprowler/logging"
+	"portprowler/netutil"
+	"portprowler/port"
+)
+
+// resolveWorkers bounds concurrent lookups of listed names.
+const resolveWorkers = 16
+
+// hostsFileTargets reads --hosts-file and resolves its names.
+func (f scanFlags) hostsFileTargets(scope netutil.Scope) ([]port.Target, error) {
+	var r io.Reader = os.Stdin
+	if f.hostsFile != "-" {
+		file, err := os.Open(f.hostsFile)
+		if err != nil {
+			return nil, usageErr("error: --hosts-file: %v", err)
+		}
+		defer file.Close()
+		r = file
+	}
+	entries, err := hostlist.Read(r)
+	if err != nil {
+		return nil, usageErr("error: --hosts-file %s: %v", f.hostsFile, err)
+	}
+	return f.listedTargets("--hosts-file "+f.hostsFile, entries, scope), nil
+}
+
+// ctTargets looks up the --ct-domain names in certificate transparency
+// logs and resolves them.
+func (f scanFlags) ctTargets(scope netutil.Scope) ([]port.Target, error) {
+	c := &ct.Client{URL: f.ctURL}
+	var targets []port.Target
+	for _, domain := range f.ctDomains {
+		names, err := c.Names(context.Background(), domain)
+		if err != nil {
+			return nil, runtimeErr("--ct-domain %s: %v", domain, err)
+		}
+		if len(names) == 0 {
+			logging.Warnf("--ct-domain %s: no logged certificates name the domain", domain)
+			continue
+		}
+		logging.Infof("--ct-domain %s: %d names in certificate transparency logs", domain, len(names))
+		entries := make([]hostlist.Entry, len(names))
+		for i, n := range names {
+			entries[i].Name = n
+		}
+		targets = append(targets, f.listedTargets("--ct-domain "+domain, entries, scope)...)
+	}
+	return targets, nil
+}
+
+// listedTargets resolves the names of a host list from source, in list
+// order. Addresses the list gives are used as given; other names are
+// looked up like target arguments. Unlike arguments, names that do not
+// resolve or resolve to refused addresses are skipped with a warning,
+// since domain inventories are full of stale records.
+func (f scanFlags) listedTargets(source string, entries []hostlist.Entry, scope netutil.Scope) []port.Target {
+	opts := netutil.ResolveOptions{DualStack: f.dualStack, AllIPs: f.allIPs}
+	addrs := make([][]string, len(entries))
+	errs := make([]error, len(entries))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, resolveWorkers)
+	for i, e := range entries {
+		if len(e.Addrs) > 0 {
+			addrs[i], errs[i] = pickAddrs(e.Addrs, opts)
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, name string) {
+			defer wg.Done()
+			addrs[i], errs[i] = netutil.ResolveTarget(name, opts)
+			<-sem
+		}(i, e.Name)
+	}
+	wg.Wait()
+
+	var targets []port.Target
+	var skipped []string
+	unique := make(map[string]bool)
+	for i, e := range entries {
+		if errs[i] == nil {
+			if gerr := f.checkResolved(e.Name, addrs[i], scope); gerr != nil {
+				errs[i] = gerr
+			}
+		}
+		if errs[i] != nil {
+			skipped = append(skipped, e.Name)
+			if f.verbose {
+				logging.Verbosef("%s: skipping %s: %v", source, e.Name, errs[i])
+			}
+			continue
+		}
+		for _, ip := range addrs[i] {
+			targets = append(targets, port.Target{Name: e.Name, IP: ip})
+			unique[ip] = true
+		}
+	}
+	if len(skipped) > 0 {
+		logging.Warnf("%s: skipped %d of %d names that did not resolve or resolved to refused addresses%s", source, len(skipped), len(entries), skippedHint(skipped, f.verbose))
+	}
+	if len(targets) == 0 {
+		logging.Warnf("%s: none of its %d names resolved to a scannable address", source, len(entries))
+		return nil
+	}
+	logging.Infof("%s: %d names resolve to %d unique addresses", source, len(entries)-len(skipped), len(unique))
+	return targets
+}
+
+// skippedHint lists the first skipped names, or points at -v.
+func skippedHint(names []string, verbose bool) string {
+	if verbose {
+		return ""
+	}
+	if len(names) > 5 {
+		return fmt.Sprintf(": %s, ... (-v lists them with reasons)", strings.Join(names[:5], ", "))
+	}
+	return ": " + strings.Join(names, ", ")
+}
+
+// pickAddrs selects among listed addresses as ResolveTarget selects among
+// resolved ones: the first IPv4 address, every one with AllIPs, and the
+// IPv6 address(es) too with DualStack.
+func pickAddrs(listed []string, opts netutil.ResolveOptions) ([]string, error) {
+	var v4, v6 []string
+	for _, a := range listed {
+		if ip := net.ParseIP(a); ip.To4() != nil {
+			v4 = append(v4, ip.To4().String())
+		} else if ip != nil {
+			v6 = append(v6, ip.String())
+		}
+	}
+	if !opts.AllIPs {
+		if len(v4) > 1 {
+			v4 = v4[:1]
+		}
+		if len(v6) > 1 {
+			v6 = v6[:1]
+		}
+	}
+	if !opts.DualStack {
+		v6 = nil
+	}
+	if len(v4)+len(v6) == 0 {
+		return nil, fmt.Errorf("only IPv6 addresses listed (use --dual-stack)")
+	}
+	return append(v4, v6...), nil
+}
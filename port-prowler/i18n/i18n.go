@@ -0,0 +1,67 @@
+// Package i18n translates the human-readable text of reports (the table
+// format's headings and labels, the scan summary, the strings templates
+// ask for with t) for stakeholders who do not read English. Machine
+// formats (json, csv, openmetrics) and the values in reports (states,
+// services, INFO fields) are never translated.
+//
+// Messages are keyed by their English text, so an untranslated message
+// falls back to English.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	mu      sync.RWMutex
+	current map[string]string // nil for English
+)
+
+// Languages returns the languages Set accepts, in order.
+func Languages() []string {
+	langs := []string{"en"}
+	for l := range catalogs {
+		langs = append(langs, l)
+	}
+	sort.Strings(langs[1:])
+	return langs
+}
+
+// Set selects the language of T and Tf for the whole process: a code from
+// Languages, optionally with a region and encoding as in $LANG (de_AT.UTF-8
+// is de). Empty selects English.
+func Set(lang string) error {
+	code := strings.ToLower(lang)
+	if i := strings.IndexAny(code, "_-.@"); i >= 0 {
+		code = code[:i]
+	}
+	var c map[string]string
+	if code != "" && code != "en" {
+		var ok bool
+		if c, ok = catalogs[code]; !ok {
+			return fmt.Errorf("unknown language %q (want one of %s)", lang, strings.Join(Languages(), ", "))
+		}
+	}
+	mu.Lock()
+	current = c
+	mu.Unlock()
+	return nil
+}
+
+// T returns msg in the selected language.
+func T(msg string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if s, ok := current[msg]; ok {
+		return s
+	}
+	return msg
+}
+
+// Tf formats the translation of format with a, like fmt.Sprintf.
+func Tf(format string, a ...any) string {
+	return fmt.Sprintf(T(format), a...)
+}
@@ -0,0 +1,69 @@
+package i18n
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// messages lists every message the report code translates.
+var messages = []string{
+	"TARGET", "PORT/PROTO", "STATE", "SERVICE", "INFO",
+	"Note: %s",
+	"Target: %s -> %s",
+	"OS: disabled",
+	"OS: unknown",
+	"OS: %s (confidence: %s)",
+	"RTT: median=%dms min=%dms max=%dms (%d samples)",
+	"Suspicious (possible tarpit/honeypot): %s",
+	"Capped: too many open ports, remaining ports not scanned",
+	"Down: the first ports probed all timed out",
+	"Cloud: %s",
+	"Unidentified banner %s %s:",
+	"(differs from bare IP)",
+	"scanned %d %s on %d %s in %s (%d open, %d closed, %d filtered)",
+	"port", "ports", "host", "hosts",
+}
+
+var verbs = regexp.MustCompile(`%[a-z]`)
+
+func TestCatalogs(t *testing.T) {
+	for lang, c := range catalogs {
+		if len(c) != len(messages) {
+			t.Errorf("%s: %d messages, want %d", lang, len(c), len(messages))
+		}
+		for _, m := range messages {
+			tr, ok := c[m]
+			if !ok {
+				t.Errorf("%s: %q is not translated", lang, m)
+				continue
+			}
+			if got, want := strings.Join(verbs.FindAllString(tr, -1), ""), strings.Join(verbs.FindAllString(m, -1), ""); got != want {
+				t.Errorf("%s: %q has verbs %s, want %s", lang, tr, got, want)
+			}
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	defer Set("")
+	if err := Set("de_AT.UTF-8"); err != nil {
+		t.Fatal(err)
+	}
+	if got := Tf("Target: %s -> %s", "db", "10.0.0.5"); got != "Ziel: db -> 10.0.0.5" {
+		t.Errorf("de: %q", got)
+	}
+	if got := T("not a message"); got != "not a message" {
+		t.Errorf("untranslated: %q", got)
+	}
+	if err := Set("xx"); err == nil || !strings.Contains(err.Error(), "en, de, es, fr") {
+		t.Errorf("unknown language: %v", err)
+	}
+	if got := T("STATE"); got != "STATUS" {
+		t.Errorf("a failed Set changed the language: %q", got)
+	}
+	Set("EN")
+	if got := T("STATE"); got != "STATE" {
+		t.Errorf("en: %q", got)
+	}
+}
@@ -0,0 +1,85 @@
+package i18n
+
+// catalogs holds the translations of every language but English, keyed
+// by language code and then by the English message. Format verbs must
+// stay in the same order as in the English message.
+var catalogs = map[string]map[string]string{
+	"de": {
+		"TARGET":     "ZIEL",
+		"PORT/PROTO": "PORT/PROTO",
+		"STATE":      "STATUS",
+		"SERVICE":    "DIENST",
+		"INFO":       "INFO",
+
+		"Note: %s":                "Notiz: %s",
+		"Target: %s -> %s":        "Ziel: %s -> %s",
+		"OS: disabled":            "BS: deaktiviert",
+		"OS: unknown":             "BS: unbekannt",
+		"OS: %s (confidence: %s)": "BS: %s (Sicherheit: %s)",
+		"RTT: median=%dms min=%dms max=%dms (%d samples)":          "RTT: Median=%dms Min=%dms Max=%dms (%d Messungen)",
+		"Suspicious (possible tarpit/honeypot): %s":                "Verdächtig (möglicherweise Tarpit/Honeypot): %s",
+		"Capped: too many open ports, remaining ports not scanned": "Abgebrochen: zu viele offene Ports, restliche Ports nicht gescannt",
+		"Down: the first ports probed all timed out":               "Nicht erreichbar: Zeitüberschreitung bei allen ersten Ports",
+		"Cloud: %s":                  "Cloud: %s",
+		"Unidentified banner %s %s:": "Nicht erkanntes Banner %s %s:",
+		"(differs from bare IP)":     "(weicht von der reinen IP ab)",
+
+		"scanned %d %s on %d %s in %s (%d open, %d closed, %d filtered)": "%d %s auf %d %s in %s gescannt (%d offen, %d geschlossen, %d gefiltert)",
+		"port":  "Port",
+		"ports": "Ports",
+		"host":  "Host",
+		"hosts": "Hosts",
+	},
+	"es": {
+		"TARGET":     "OBJETIVO",
+		"PORT/PROTO": "PUERTO/PROTO",
+		"STATE":      "ESTADO",
+		"SERVICE":    "SERVICIO",
+		"INFO":       "INFO",
+
+		"Note: %s":                "Nota: %s",
+		"Target: %s -> %s":        "Objetivo: %s -> %s",
+		"OS: disabled":            "SO: desactivado",
+		"OS: unknown":             "SO: desconocido",
+		"OS: %s (confidence: %s)": "SO: %s (confianza: %s)",
+		"RTT: median=%dms min=%dms max=%dms (%d samples)":          "RTT: mediana=%dms mín=%dms máx=%dms (%d muestras)",
+		"Suspicious (possible tarpit/honeypot): %s":                "Sospechoso (posible tarpit/honeypot): %s",
+		"Capped: too many open ports, remaining ports not scanned": "Limitado: demasiados puertos abiertos, el resto no se escaneó",
+		"Down: the first ports probed all timed out":               "Caído: todos los primeros puertos sondeados agotaron el tiempo",
+		"Cloud: %s":                  "Nube: %s",
+		"Unidentified banner %s %s:": "Banner no identificado %s %s:",
+		"(differs from bare IP)":     "(difiere de la IP sola)",
+
+		"scanned %d %s on %d %s in %s (%d open, %d closed, %d filtered)": "%d %s escaneados en %d %s en %s (%d abiertos, %d cerrados, %d filtrados)",
+		"port":  "puerto",
+		"ports": "puertos",
+		"host":  "host",
+		"hosts": "hosts",
+	},
+	"fr": {
+		"TARGET":     "CIBLE",
+		"PORT/PROTO": "PORT/PROTO",
+		"STATE":      "ÉTAT",
+		"SERVICE":    "SERVICE",
+		"INFO":       "INFO",
+
+		"Note: %s":                "Note : %s",
+		"Target: %s -> %s":        "Cible : %s -> %s",
+		"OS: disabled":            "OS : désactivé",
+		"OS: unknown":             "OS : inconnu",
+		"OS: %s (confidence: %s)": "OS : %s (confiance : %s)",
+		"RTT: median=%dms min=%dms max=%dms (%d samples)":          "RTT : médiane=%dms min=%dms max=%dms (%d mesures)",
+		"Suspicious (possible tarpit/honeypot): %s":                "Suspect (tarpit/honeypot possible) : %s",
+		"Capped: too many open ports, remaining ports not scanned": "Plafonné : trop de ports ouverts, ports restants non analysés",
+		"Down: the first ports probed all timed out":               "Injoignable : les premiers ports sondés ont tous expiré",
+		"Cloud: %s":                  "Cloud : %s",
+		"Unidentified banner %s %s:": "Bannière non identifiée %s %s :",
+		"(differs from bare IP)":     "(diffère de l'IP seule)",
+
+		"scanned %d %s on %d %s in %s (%d open, %d closed, %d filtered)": "analyse de %d %s sur %d %s en %s (%d ouverts, %d fermés, %d filtrés)",
+		"port":  "port",
+		"ports": "ports",
+		"host":  "hôte",
+		"hosts": "hôtes",
+	},
+}
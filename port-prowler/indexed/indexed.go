@@ -0,0 +1,288 @@
+// Package indexed reads host exports from internet-wide scan indexes
+// (Shodan, Censys) and compares what they list with what a scan observes.
+package indexed
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"portprowler/report"
+)
+
+// Discrepancy kinds reported by Compare.
+const (
+	IndexedOnly = "indexed-only" // indexed as open, not open now
+	NotIndexed  = "not-indexed"  // open now, absent from the index
+	NotScanned  = "not-scanned"  // indexed, but the scan did not cover it
+)
+
+// Service is one port an index lists as open.
+type Service struct {
+	IP      string    `json:"ip"`
+	Port    uint16    `json:"port"`
+	Proto   string    `json:"proto"`
+	Product string    `json:"product,omitempty"`
+	Seen    time.Time `json:"seen"`
+	Source  string    `json:"source"` // "shodan" or "censys"
+}
+
+func (s Service) describe() string {
+	d := "open"
+	if s.Product != "" {
+		d += " " + s.Product
+	}
+	if !s.Seen.IsZero() {
+		d += fmt.Sprintf(" (%s %s)", s.Source, s.Seen.Format("2006-01-02"))
+	}
+	return d
+}
+
+// record holds the fields of every record shape Read understands: Shodan
+// banners (`shodan download`, one per line) and host lookups (banners in
+// data), Censys hosts and the API's result/hits envelopes.
+type record struct {
+	// Shodan
+	IPStr     string `json:"ip_str"`
+	Port      *int   `json:"port"`
+	Transport string `json:"transport"`
+	Product   string `json:"product"`
+	Timestamp string `json:"timestamp"`
+	Shodan    struct {
+		Module string `json:"module"`
+	} `json:"_shodan"`
+	Data []json.RawMessage `json:"data"`
+	// Censys
+	IP       string            `json:"ip"`
+	Services []censysService   `json:"services"`
+	Result   json.RawMessage   `json:"result"`
+	Hits     []json.RawMessage `json:"hits"`
+}
+
+type censysService struct {
+	Port        int    `json:"port"`
+	ServiceName string `json:"service_name"`
+	Transport   string `json:"transport_protocol"`
+	ObservedAt  string `json:"observed_at"`
+}
+
+// shodanTime is the layout of Shodan banner timestamps (UTC, no zone).
+const shodanTime = "2006-01-02T15:04:05.999999"
+
+// Read parses a Shodan or Censys export: JSON Lines, a JSON array or a
+// single document, optionally gzip-compressed. Records of other shapes
+// are skipped; an export without any is an error. A port listed several
+// times keeps its latest sighting.
+func Read(r io.Reader) ([]Service, error) {
+	br := bufio.NewReader(r)
+	if head, _ := br.Peek(2); bytes.Equal(head, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+
+	seen := make(map[string]int)
+	var out []Service
+	add := func(s Service) {
+		if ip := net.ParseIP(s.IP); ip != nil {
+			s.IP = ip.String()
+		}
+		key := fmt.Sprintf("%s|%d|%s", s.IP, s.Port, s.Proto)
+		if i, ok := seen[key]; ok {
+			if s.Seen.After(out[i].Seen) {
+				out[i] = s
+			}
+			return
+		}
+		seen[key] = len(out)
+		out = append(out, s)
+	}
+
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if err := walk(raw, add, 0); err != nil {
+			return nil, err
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no Shodan or Censys host records found")
+	}
+	return out, nil
+}
+
+// maxDepth bounds envelope nesting (result, hits, data).
+const maxDepth = 4
+
+func walk(raw json.RawMessage, add func(Service), depth int) error {
+	if depth > maxDepth {
+		return nil
+	}
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return err
+		}
+		for _, it := range items {
+			if err := walk(it, add, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(raw) == 0 || raw[0] != '{' {
+		return nil
+	}
+	var rec record
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return err
+	}
+	switch {
+	case len(rec.Result) > 0:
+		return walk(rec.Result, add, depth+1)
+	case rec.Hits != nil:
+		for _, h := range rec.Hits {
+			if err := walk(h, add, depth+1); err != nil {
+				return err
+			}
+		}
+	case rec.Data != nil:
+		for _, d := range rec.Data {
+			if err := walk(d, add, depth+1); err != nil {
+				return err
+			}
+		}
+	case rec.IPStr != "" && rec.Port != nil:
+		if *rec.Port <= 0 || *rec.Port > 65535 {
+			return nil
+		}
+		s := Service{IP: rec.IPStr, Port: uint16(*rec.Port), Proto: proto(rec.Transport), Product: rec.Product, Source: "shodan"}
+		if s.Product == "" {
+			s.Product = rec.Shodan.Module
+		}
+		s.Seen, _ = time.Parse(shodanTime, rec.Timestamp)
+		add(s)
+	case rec.IP != "":
+		for _, cs := range rec.Services {
+			if cs.Port <= 0 || cs.Port > 65535 {
+				continue
+			}
+			s := Service{IP: rec.IP, Port: uint16(cs.Port), Proto: proto(cs.Transport), Product: strings.ToLower(cs.ServiceName), Source: "censys"}
+			if s.Product == "unknown" {
+				s.Product = ""
+			}
+			s.Seen, _ = time.Parse(time.RFC3339Nano, cs.ObservedAt)
+			add(s)
+		}
+	}
+	return nil
+}
+
+// proto maps an index's transport name to a scan protocol; QUIC runs
+// over udp.
+func proto(transport string) string {
+	switch strings.ToLower(transport) {
+	case "udp", "quic":
+		return "udp"
+	default:
+		return "tcp"
+	}
+}
+
+// Compare reports where services and rep disagree, for the addresses the
+// index lists: ports indexed as open that are not open now (IndexedOnly)
+// or were not scanned (NotScanned), and open ports the index does not
+// list (NotIndexed). Results are matched by address, port and protocol
+// and ordered like report.Diff, then by address.
+func Compare(services []Service, rep report.ScanReport) []report.Change {
+	type key struct {
+		ip    string
+		port  uint16
+		proto string
+	}
+	type observed struct {
+		target string
+		state  string
+		desc   string
+	}
+	scanned := make(map[key]observed)
+	names := make(map[string]string)
+	for _, h := range rep.Hosts {
+		for _, r := range h.Results {
+			ip := r.IP
+			if p := net.ParseIP(ip); p != nil {
+				ip = p.String()
+			}
+			names[ip] = h.Target
+			desc := r.State
+			if r.Service != "" {
+				desc += " " + r.Service
+			}
+			scanned[key{ip, r.Port, r.Proto}] = observed{h.Target, r.State, desc}
+		}
+	}
+
+	indexedIPs := make(map[string]bool)
+	listed := make(map[key]bool)
+	var out []report.Change
+	for _, s := range services {
+		indexedIPs[s.IP] = true
+		k := key{s.IP, s.Port, s.Proto}
+		listed[k] = true
+		target := names[s.IP]
+		if target == "" {
+			target = s.IP
+		}
+		c := report.Change{Target: target, IP: s.IP, Port: s.Port, Proto: s.Proto, Before: s.describe()}
+		obs, ok := scanned[k]
+		switch {
+		case !ok:
+			c.Kind = NotScanned
+		case obs.state != "open":
+			c.Kind, c.After = IndexedOnly, obs.desc
+		default:
+			continue
+		}
+		out = append(out, c)
+	}
+	for k, obs := range scanned {
+		if obs.state != "open" || !indexedIPs[k.ip] || listed[k] {
+			continue
+		}
+		out = append(out, report.Change{Kind: NotIndexed, Target: obs.target, IP: k.ip, Port: k.port, Proto: k.proto, After: obs.desc})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		if a.Proto != b.Proto {
+			return a.Proto < b.Proto
+		}
+		return a.IP < b.IP
+	})
+	return out
+}
@@ -0,0 +1,91 @@
+package indexed
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+	"time"
+
+	"portprowler/port"
+	"portprowler/report"
+)
+
+const shodanLines = `{"ip_str":"192.0.2.10","port":22,"transport":"tcp","product":"OpenSSH","timestamp":"2024-04-01T10:00:00.000000"}
+{"ip_str":"192.0.2.10","port":22,"transport":"tcp","product":"OpenSSH","timestamp":"2024-05-01T10:00:00.000000"}
+{"ip_str":"192.0.2.10","port":8443,"transport":"tcp","_shodan":{"module":"https"},"timestamp":"2024-05-01T10:00:00.000000"}
+{"ip_str":"192.0.2.10","port":161,"transport":"udp","product":"Net-SNMP"}
+`
+
+const censysHost = `{"code":200,"status":"OK","result":{"ip":"192.0.2.20","services":[
+  {"port":80,"service_name":"HTTP","transport_protocol":"TCP","observed_at":"2024-05-02T08:00:00.5Z"},
+  {"port":443,"service_name":"UNKNOWN","transport_protocol":"QUIC"}
+]}}`
+
+func TestRead(t *testing.T) {
+	svcs, err := Read(strings.NewReader(shodanLines))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(svcs) != 3 {
+		t.Fatalf("want 3 services (duplicate merged), got %+v", svcs)
+	}
+	if s := svcs[0]; s.Port != 22 || s.Product != "OpenSSH" || s.Source != "shodan" || s.Seen.Month() != time.May {
+		t.Errorf("want the latest port 22 sighting, got %+v", s)
+	}
+	if s := svcs[1]; s.Product != "https" {
+		t.Errorf("want the shodan module as product, got %+v", s)
+	}
+	if s := svcs[2]; s.Proto != "udp" {
+		t.Errorf("want udp, got %+v", s)
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("[" + censysHost + "]"))
+	zw.Close()
+	svcs, err = Read(&gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(svcs) != 2 || svcs[0].Product != "http" || svcs[0].Source != "censys" || svcs[0].Seen.IsZero() ||
+		svcs[1].Proto != "udp" || svcs[1].Product != "" {
+		t.Fatalf("unexpected censys services: %+v", svcs)
+	}
+
+	if _, err := Read(strings.NewReader(`{"hello":"world"}`)); err == nil {
+		t.Error("want an error for an export without host records")
+	}
+	if _, err := Read(strings.NewReader(`{"ip_str":`)); err == nil {
+		t.Error("want an error for truncated JSON")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	svcs := []Service{
+		{IP: "192.0.2.10", Port: 22, Proto: "tcp", Product: "OpenSSH", Source: "shodan"},
+		{IP: "192.0.2.10", Port: 23, Proto: "tcp", Source: "shodan"},
+		{IP: "192.0.2.10", Port: 3389, Proto: "tcp", Source: "shodan"},
+	}
+	rep := report.Build(report.Meta{}, []port.Target{
+		{Name: "web.example", IP: "192.0.2.10"},
+		{Name: "other.example", IP: "192.0.2.99"},
+	}, []port.PortResult{
+		{Target: "web.example", IP: "192.0.2.10", Port: 22, Proto: "tcp", State: "open", Service: "ssh"},
+		{Target: "web.example", IP: "192.0.2.10", Port: 23, Proto: "tcp", State: "filtered"},
+		{Target: "web.example", IP: "192.0.2.10", Port: 443, Proto: "tcp", State: "open", Service: "https"},
+		{Target: "other.example", IP: "192.0.2.99", Port: 443, Proto: "tcp", State: "open"},
+	})
+	var got []string
+	for _, c := range Compare(svcs, rep) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"indexed-only web.example 23/tcp: open -> filtered",
+		"not-indexed web.example 443/tcp: - -> open https",
+		"not-scanned web.example 3389/tcp: open -> -",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Compare:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
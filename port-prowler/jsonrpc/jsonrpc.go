@@ -0,0 +1,271 @@
+// Package jsonrpc runs scans over JSON-RPC 2.0 on a pair of streams, one
+// message per line, so portprowler can be driven as a subprocess.
+//
+//	scan    params: a server.Request; result: the report.ScanReport
+//	cancel  params: {"id": <id of a scan call>}; result: whether it was running
+//
+// While a scan runs, every port result is sent as a "result" notification
+// with params {"id": <id of the scan call>, "result": <port.PortResult>}.
+// Scans run concurrently; responses arrive in the order scans finish.
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"portprowler/port"
+	"portprowler/server"
+)
+
+// Error codes. The first four are defined by JSON-RPC 2.0; the others are
+// in its range for server errors.
+const (
+	CodeParse          = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeScanFailed     = -32000
+	CodeCancelled      = -32001
+)
+
+// Prepare validates a scan request and returns the scan to run; onResult
+// receives every port result as it is found. Its errors are reported as
+// invalid params.
+type Prepare func(req server.Request, onResult func(port.PortResult)) (server.Job, error)
+
+// Error is a JSON-RPC error object. A cancelled scan's partial report is
+// its Data.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+type request struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+type notification struct {
+	Version string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+// ResultParams are the params of a "result" notification.
+type ResultParams struct {
+	ID     json.RawMessage `json:"id"`
+	Result port.PortResult `json:"result"`
+}
+
+// maxMessage bounds one request line.
+const maxMessage = 1 << 20
+
+type conn struct {
+	prepare Prepare
+
+	wmu sync.Mutex
+	enc *json.Encoder
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// Serve reads requests from r and writes responses and notifications to w
+// until r ends or ctx is cancelled. At the end of r it waits for running
+// scans to finish; when ctx is cancelled it cancels them first.
+func Serve(ctx context.Context, r io.Reader, w io.Writer, prepare Prepare) error {
+	c := &conn{prepare: prepare, enc: json.NewEncoder(w), running: make(map[string]context.CancelFunc)}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64*1024), maxMessage)
+		for sc.Scan() {
+			line := append([]byte(nil), sc.Bytes()...)
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- sc.Err()
+	}()
+
+	for {
+		select {
+		case line := <-lines:
+			c.handle(ctx, line)
+		case err := <-readErr:
+			c.wg.Wait()
+			return err
+		case <-ctx.Done():
+			c.wg.Wait()
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *conn) handle(ctx context.Context, line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		if bytes.HasPrefix(line, []byte("[")) {
+			c.fail(nil, CodeInvalidRequest, "batches are not supported")
+			return
+		}
+		c.fail(nil, CodeParse, fmt.Sprintf("parse error: %v", err))
+		return
+	}
+	if req.Version != "2.0" || req.Method == "" {
+		c.fail(req.ID, CodeInvalidRequest, `invalid request: want "jsonrpc": "2.0" and a method`)
+		return
+	}
+	notify := len(req.ID) == 0
+	switch req.Method {
+	case "scan":
+		// A scan without an id could be neither answered nor cancelled.
+		if notify {
+			return
+		}
+		c.scan(ctx, req)
+	case "cancel":
+		var p struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if err := decodeParams(req.Params, &p); err != nil || len(p.ID) == 0 {
+			if !notify {
+				c.fail(req.ID, CodeInvalidParams, "invalid params: want {\"id\": <scan id>}")
+			}
+			return
+		}
+		c.mu.Lock()
+		cancel, ok := c.running[key(p.ID)]
+		c.mu.Unlock()
+		if ok {
+			cancel()
+		}
+		if !notify {
+			c.reply(req.ID, ok)
+		}
+	default:
+		if !notify {
+			c.fail(req.ID, CodeMethodNotFound, fmt.Sprintf("method not found: %s", req.Method))
+		}
+	}
+}
+
+func (c *conn) scan(ctx context.Context, req request) {
+	var params server.Request
+	if err := decodeParams(req.Params, &params); err != nil {
+		c.fail(req.ID, CodeInvalidParams, fmt.Sprintf("invalid params: %v", err))
+		return
+	}
+	if len(params.Targets) == 0 {
+		c.fail(req.ID, CodeInvalidParams, "invalid params: targets is required")
+		return
+	}
+	id := req.ID
+	job, err := c.prepare(params, func(res port.PortResult) {
+		c.write(notification{Version: "2.0", Method: "result", Params: ResultParams{ID: id, Result: res}})
+	})
+	if err != nil {
+		c.fail(id, CodeInvalidParams, err.Error())
+		return
+	}
+
+	k := key(id)
+	c.mu.Lock()
+	if _, dup := c.running[k]; dup {
+		c.mu.Unlock()
+		c.fail(id, CodeInvalidRequest, "a scan with this id is already running")
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	c.running[k] = cancel
+	c.mu.Unlock()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer cancel()
+		rep, err := job(ctx)
+		c.mu.Lock()
+		delete(c.running, k)
+		c.mu.Unlock()
+		switch {
+		case ctx.Err() != nil:
+			e := &Error{Code: CodeCancelled, Message: "scan cancelled"}
+			if len(rep.Hosts) > 0 {
+				e.Data = rep // partial
+			}
+			c.write(response{Version: "2.0", ID: id, Error: e})
+		case err != nil:
+			c.fail(id, CodeScanFailed, err.Error())
+		default:
+			c.reply(id, rep)
+		}
+	}()
+}
+
+// decodeParams decodes params into v, rejecting unknown fields as the HTTP
+// API does.
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return errors.New("params are required")
+	}
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// key is the map key for a request id: its compact JSON encoding, so 1
+// and "1" stay distinct.
+func key(id json.RawMessage) string {
+	var buf bytes.Buffer
+	if json.Compact(&buf, id) != nil {
+		return string(id)
+	}
+	return buf.String()
+}
+
+func (c *conn) reply(id json.RawMessage, result any) {
+	c.write(response{Version: "2.0", ID: id, Result: result})
+}
+
+func (c *conn) fail(id json.RawMessage, code int, msg string) {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	c.write(response{Version: "2.0", ID: id, Error: &Error{Code: code, Message: msg}})
+}
+
+// write sends one message per line; messages from concurrent scans never
+// interleave.
+func (c *conn) write(msg any) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_ = c.enc.Encode(msg)
+}
@@ -0,0 +1,150 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"portprowler/port"
+	"portprowler/report"
+	"portprowler/server"
+)
+
+func fakePrepare(req server.Request, onResult func(port.PortResult)) (server.Job, error) {
+	if req.Ports == "bad" {
+		return nil, errors.New("invalid port spec")
+	}
+	return func(ctx context.Context) (report.ScanReport, error) {
+		switch req.Note {
+		case "block":
+			<-ctx.Done()
+			return report.ScanReport{}, ctx.Err()
+		case "fail":
+			return report.ScanReport{}, errors.New("boom")
+		}
+		onResult(port.PortResult{Target: req.Targets[0], Port: 22, Proto: "tcp", State: "open"})
+		return report.ScanReport{Meta: report.Meta{PortSpec: req.Ports, Note: req.Note}}, nil
+	}, nil
+}
+
+type message struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params *ResultParams   `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// session runs Serve over pipes and returns a writer for requests and a
+// reader for the messages sent back.
+func session(t *testing.T) (io.WriteCloser, func() message, <-chan error) {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(context.Background(), inR, outW, fakePrepare)
+		outW.Close()
+	}()
+	msgs := make(chan message, 16)
+	go func() {
+		sc := bufio.NewScanner(outR)
+		for sc.Scan() {
+			var m message
+			if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+				t.Errorf("bad message %s: %v", sc.Text(), err)
+			}
+			msgs <- m
+		}
+		close(msgs)
+	}()
+	next := func() message {
+		t.Helper()
+		select {
+		case m := <-msgs:
+			return m
+		case <-time.After(2 * time.Second):
+			t.Fatal("no message")
+			return message{}
+		}
+	}
+	return inW, next, done
+}
+
+func TestServe(t *testing.T) {
+	in, next, done := session(t)
+
+	io.WriteString(in, `{"jsonrpc":"2.0","id":1,"method":"scan","params":{"targets":["192.0.2.1"],"ports":"22","note":"first"}}`+"\n")
+	if m := next(); m.Method != "result" || string(m.Params.ID) != "1" || m.Params.Result.Port != 22 {
+		t.Fatalf("want result notification for id 1, got %+v", m)
+	}
+	m := next()
+	var rep report.ScanReport
+	if string(m.ID) != "1" || m.Error != nil || json.Unmarshal(m.Result, &rep) != nil || rep.Meta.Note != "first" {
+		t.Fatalf("want report for id 1, got %+v", m)
+	}
+
+	for _, tc := range []struct {
+		line string
+		code int
+	}{
+		{`not json`, CodeParse},
+		{`[{"jsonrpc":"2.0","id":1,"method":"scan"}]`, CodeInvalidRequest},
+		{`{"jsonrpc":"1.0","id":2,"method":"scan"}`, CodeInvalidRequest},
+		{`{"jsonrpc":"2.0","id":3,"method":"nmap"}`, CodeMethodNotFound},
+		{`{"jsonrpc":"2.0","id":4,"method":"scan","params":{"ports":"22"}}`, CodeInvalidParams},
+		{`{"jsonrpc":"2.0","id":5,"method":"scan","params":{"targets":["x"],"bogus":1}}`, CodeInvalidParams},
+		{`{"jsonrpc":"2.0","id":6,"method":"scan","params":{"targets":["x"],"ports":"bad"}}`, CodeInvalidParams},
+		{`{"jsonrpc":"2.0","id":7,"method":"scan","params":{"targets":["x"],"note":"fail"}}`, CodeScanFailed},
+	} {
+		io.WriteString(in, tc.line+"\n")
+		if m := next(); m.Error == nil || m.Error.Code != tc.code {
+			t.Errorf("%s: want error %d, got %+v", tc.line, tc.code, m)
+		}
+	}
+
+	// Notifications get no answer; the next message belongs to id 8.
+	io.WriteString(in, `{"jsonrpc":"2.0","method":"nmap"}`+"\n")
+	io.WriteString(in, `{"jsonrpc":"2.0","id":"8","method":"scan","params":{"targets":["x"],"note":"block"}}`+"\n")
+	io.WriteString(in, `{"jsonrpc":"2.0","id":9,"method":"cancel","params":{"id":8}}`+"\n")
+	if m := next(); string(m.ID) != "9" || string(m.Result) != "false" {
+		t.Fatalf("cancel of id 8 (not \"8\") should find nothing, got %+v", m)
+	}
+	io.WriteString(in, `{"jsonrpc":"2.0","id":10,"method":"cancel","params":{"id":"8"}}`+"\n")
+	first, second := next(), next()
+	if string(first.ID) == `"8"` {
+		first, second = second, first
+	}
+	if string(first.ID) != "10" || string(first.Result) != "true" {
+		t.Errorf("want cancel to succeed, got %+v", first)
+	}
+	if string(second.ID) != `"8"` || second.Error == nil || second.Error.Code != CodeCancelled {
+		t.Errorf("want id \"8\" cancelled, got %+v", second)
+	}
+
+	in.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve did not return at end of input")
+	}
+}
+
+func TestServe_WaitsAtEOF(t *testing.T) {
+	var out strings.Builder
+	in := `{"jsonrpc":"2.0","id":1,"method":"scan","params":{"targets":["x"],"ports":"22"}}` + "\n"
+	if err := Serve(context.Background(), strings.NewReader(in), &out, fakePrepare); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 2 || !strings.Contains(lines[1], `"result":{`) {
+		t.Fatalf("want a notification and the report, got:\n%s", out.String())
+	}
+}
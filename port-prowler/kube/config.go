@@ -0,0 +1,265 @@
+package kube
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"portprowler/yaml"
+)
+
+// Client reads from one cluster's API server.
+type Client struct {
+	Server string // e.g. "https://10.0.0.1:6443"
+	HTTP   *http.Client
+
+	token              string
+	username, password string
+}
+
+// kubeconfig holds the parts of a kubeconfig file Load uses.
+type kubeconfig struct {
+	CurrentContext string `json:"current-context"`
+	Clusters       []struct {
+		Name    string `json:"name"`
+		Cluster struct {
+			Server                   string `json:"server"`
+			CertificateAuthority     string `json:"certificate-authority"`
+			CertificateAuthorityData string `json:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify"`
+			TLSServerName            string `json:"tls-server-name"`
+		} `json:"cluster"`
+	} `json:"clusters"`
+	Contexts []struct {
+		Name    string `json:"name"`
+		Context struct {
+			Cluster string `json:"cluster"`
+			User    string `json:"user"`
+		} `json:"context"`
+	} `json:"contexts"`
+	Users []struct {
+		Name string `json:"name"`
+		User struct {
+			Token                 string      `json:"token"`
+			TokenFile             string      `json:"tokenFile"`
+			Username              string      `json:"username"`
+			Password              string      `json:"password"`
+			ClientCertificate     string      `json:"client-certificate"`
+			ClientCertificateData string      `json:"client-certificate-data"`
+			ClientKey             string      `json:"client-key"`
+			ClientKeyData         string      `json:"client-key-data"`
+			Exec                  *execConfig `json:"exec"`
+			AuthProvider          any         `json:"auth-provider"`
+		} `json:"user"`
+	} `json:"users"`
+}
+
+// execConfig is a client-go credential plugin.
+type execConfig struct {
+	APIVersion string   `json:"apiVersion"`
+	Command    string   `json:"command"`
+	Args       []string `json:"args"`
+	Env        []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"env"`
+}
+
+// Load reads the kubeconfig at path (YAML or JSON) and returns a client
+// for contextName, or for the current context when it is empty. Tokens,
+// token files, basic auth, client certificates and exec credential
+// plugins (as used by EKS, GKE and AKS) are supported; the legacy
+// auth-provider plugins are not.
+func Load(path, contextName string) (*Client, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg kubeconfig
+	if err := decodeConfig(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	dir := filepath.Dir(path)
+	rel := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	if contextName == "" {
+		contextName = cfg.CurrentContext
+	}
+	if contextName == "" {
+		return nil, fmt.Errorf("%s: no current-context; name one", path)
+	}
+	var clusterName, userName string
+	found := false
+	for _, c := range cfg.Contexts {
+		if c.Name == contextName {
+			clusterName, userName, found = c.Context.Cluster, c.Context.User, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%s: no context %q", path, contextName)
+	}
+
+	c := &Client{}
+	tlsConfig := &tls.Config{}
+	found = false
+	for _, cl := range cfg.Clusters {
+		if cl.Name != clusterName {
+			continue
+		}
+		found = true
+		c.Server = strings.TrimRight(cl.Cluster.Server, "/")
+		tlsConfig.InsecureSkipVerify = cl.Cluster.InsecureSkipTLSVerify
+		tlsConfig.ServerName = cl.Cluster.TLSServerName
+		ca, err := readData(cl.Cluster.CertificateAuthorityData, rel(cl.Cluster.CertificateAuthority))
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: certificate authority: %v", clusterName, err)
+		}
+		if ca != nil {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("cluster %s: no certificates in its certificate authority", clusterName)
+			}
+			tlsConfig.RootCAs = pool
+		}
+	}
+	if !found || c.Server == "" {
+		return nil, fmt.Errorf("%s: no server for cluster %q", path, clusterName)
+	}
+
+	var cert, key []byte
+	for _, u := range cfg.Users {
+		if u.Name != userName {
+			continue
+		}
+		user := u.User
+		if user.AuthProvider != nil {
+			return nil, fmt.Errorf("user %s: auth-provider plugins are not supported; use an exec plugin or a token", userName)
+		}
+		c.token, c.username, c.password = user.Token, user.Username, user.Password
+		if c.token == "" && user.TokenFile != "" {
+			t, err := os.ReadFile(rel(user.TokenFile))
+			if err != nil {
+				return nil, fmt.Errorf("user %s: %v", userName, err)
+			}
+			c.token = strings.TrimSpace(string(t))
+		}
+		if cert, err = readData(user.ClientCertificateData, rel(user.ClientCertificate)); err != nil {
+			return nil, fmt.Errorf("user %s: client certificate: %v", userName, err)
+		}
+		if key, err = readData(user.ClientKeyData, rel(user.ClientKey)); err != nil {
+			return nil, fmt.Errorf("user %s: client key: %v", userName, err)
+		}
+		if user.Exec != nil {
+			cred, err := runExec(user.Exec)
+			if err != nil {
+				return nil, fmt.Errorf("user %s: %v", userName, err)
+			}
+			if cred.Token != "" {
+				c.token = cred.Token
+			}
+			if cred.ClientCertificateData != "" {
+				cert, key = []byte(cred.ClientCertificateData), []byte(cred.ClientKeyData)
+			}
+		}
+	}
+	if cert != nil {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("user %s: client certificate: %v", userName, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+	c.HTTP = &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+	}
+	return c, nil
+}
+
+// decodeConfig decodes a YAML or JSON kubeconfig into cfg.
+func decodeConfig(data []byte, cfg *kubeconfig) error {
+	if s := strings.TrimSpace(string(data)); strings.HasPrefix(s, "{") {
+		return json.Unmarshal(data, cfg)
+	}
+	v, err := yaml.Parse(string(data))
+	if err != nil {
+		return err
+	}
+	j, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(j, cfg)
+}
+
+// readData returns base64 inline data, or else the file at path; nil when
+// neither is set.
+func readData(inline, path string) ([]byte, error) {
+	if inline != "" {
+		return base64.StdEncoding.DecodeString(inline)
+	}
+	if path != "" {
+		return os.ReadFile(path)
+	}
+	return nil, nil
+}
+
+// execCredential is the status a credential plugin prints.
+type execCredential struct {
+	Token                 string `json:"token"`
+	ClientCertificateData string `json:"clientCertificateData"`
+	ClientKeyData         string `json:"clientKeyData"`
+}
+
+// runExec runs a credential plugin non-interactively and returns the
+// credential it prints.
+func runExec(e *execConfig) (execCredential, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	apiVersion := e.APIVersion
+	if apiVersion == "" {
+		apiVersion = "client.authentication.k8s.io/v1beta1"
+	}
+	info, _ := json.Marshal(map[string]any{
+		"apiVersion": apiVersion,
+		"kind":       "ExecCredential",
+		"spec":       map[string]any{"interactive": false},
+	})
+	cmd := exec.CommandContext(ctx, e.Command, e.Args...)
+	cmd.Env = append(os.Environ(), "KUBERNETES_EXEC_INFO="+string(info))
+	for _, kv := range e.Env {
+		cmd.Env = append(cmd.Env, kv.Name+"="+kv.Value)
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return execCredential{}, fmt.Errorf("credential plugin %s failed: %s", e.Command, msg)
+	}
+	var resp struct {
+		Status *execCredential `json:"status"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil || resp.Status == nil {
+		return execCredential{}, errors.New("credential plugin " + e.Command + " printed no ExecCredential status")
+	}
+	return *resp.Status, nil
+}
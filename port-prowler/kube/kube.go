@@ -0,0 +1,258 @@
+// Package kube lists what a Kubernetes cluster declares as reachable from
+// outside (NodePort and LoadBalancer services and external IPs) and
+// compares it with what a scan finds open, to catch exposure drift.
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"portprowler/indexed"
+	"portprowler/report"
+)
+
+// Drift kinds reported by Compare.
+const (
+	DeclaredClosed = "declared-closed" // declared, but not open
+	Undeclared     = "undeclared-open" // open on a cluster address, but not declared
+	NotScanned     = indexed.NotScanned
+)
+
+// Ways a service is exposed.
+const (
+	ViaNodePort     = "nodeport"
+	ViaLoadBalancer = "loadbalancer"
+	ViaExternalIP   = "external-ip"
+)
+
+// DefaultNodePorts is the default service-node-port-range of the API
+// server.
+const DefaultNodePorts = "30000-32767"
+
+// Exposure is one address and port a service is declared on.
+type Exposure struct {
+	Address string // IP, or hostname of a load balancer
+	Port    uint16
+	Proto   string // "tcp" or "udp"
+	Service string // namespace/name
+	Via     string
+}
+
+// Exposures lists the cluster's declared exposures, ordered by address,
+// port and protocol.
+func (c *Client) Exposures(ctx context.Context) ([]Exposure, error) {
+	var nodes []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Status struct {
+			Addresses []struct {
+				Type    string `json:"type"`
+				Address string `json:"address"`
+			} `json:"addresses"`
+		} `json:"status"`
+	}
+	if err := c.list(ctx, "/api/v1/nodes", &nodes); err != nil {
+		return nil, err
+	}
+	var nodeAddrs []string
+	for _, n := range nodes {
+		for _, a := range n.Status.Addresses {
+			if a.Type == "InternalIP" || a.Type == "ExternalIP" {
+				nodeAddrs = append(nodeAddrs, a.Address)
+			}
+		}
+	}
+
+	var services []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			Type  string `json:"type"`
+			Ports []struct {
+				Protocol string `json:"protocol"`
+				Port     uint16 `json:"port"`
+				NodePort uint16 `json:"nodePort"`
+			} `json:"ports"`
+			ExternalIPs []string `json:"externalIPs"`
+		} `json:"spec"`
+		Status struct {
+			LoadBalancer struct {
+				Ingress []struct {
+					IP       string `json:"ip"`
+					Hostname string `json:"hostname"`
+				} `json:"ingress"`
+			} `json:"loadBalancer"`
+		} `json:"status"`
+	}
+	if err := c.list(ctx, "/api/v1/services", &services); err != nil {
+		return nil, err
+	}
+	var out []Exposure
+	for _, s := range services {
+		name := s.Metadata.Namespace + "/" + s.Metadata.Name
+		for _, p := range s.Spec.Ports {
+			proto := strings.ToLower(p.Protocol)
+			if proto == "" {
+				proto = "tcp"
+			}
+			if proto != "tcp" && proto != "udp" {
+				continue // SCTP
+			}
+			add := func(addr string, port uint16, via string) {
+				out = append(out, Exposure{Address: addr, Port: port, Proto: proto, Service: name, Via: via})
+			}
+			if p.NodePort != 0 && (s.Spec.Type == "NodePort" || s.Spec.Type == "LoadBalancer") {
+				for _, a := range nodeAddrs {
+					add(a, p.NodePort, ViaNodePort)
+				}
+			}
+			if s.Spec.Type == "LoadBalancer" {
+				for _, in := range s.Status.LoadBalancer.Ingress {
+					addr := in.IP
+					if addr == "" {
+						addr = in.Hostname
+					}
+					if addr != "" {
+						add(addr, p.Port, ViaLoadBalancer)
+					}
+				}
+			}
+			for _, ip := range s.Spec.ExternalIPs {
+				add(ip, p.Port, ViaExternalIP)
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Address != b.Address {
+			return a.Address < b.Address
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		return a.Proto < b.Proto
+	})
+	return out, nil
+}
+
+// list fetches every page of a list endpoint into items.
+func (c *Client) list(ctx context.Context, path string, items any) error {
+	var all []json.RawMessage
+	cont := ""
+	for {
+		q := url.Values{"limit": {"500"}}
+		if cont != "" {
+			q.Set("continue", cont)
+		}
+		var page struct {
+			Metadata struct {
+				Continue string `json:"continue"`
+			} `json:"metadata"`
+			Items []json.RawMessage `json:"items"`
+		}
+		if err := c.get(ctx, path+"?"+q.Encode(), &page); err != nil {
+			return err
+		}
+		all = append(all, page.Items...)
+		if cont = page.Metadata.Continue; cont == "" {
+			break
+		}
+	}
+	data, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, items)
+}
+
+func (c *Client) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.Server+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var status struct {
+			Message string `json:"message"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(body, &status) != nil || status.Message == "" {
+			status.Message = strings.TrimSpace(string(body))
+		}
+		return fmt.Errorf("GET %s: %s: %s", strings.SplitN(path, "?", 2)[0], resp.Status, status.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Services turns exposures into the services Compare takes, resolving
+// load balancer hostnames with lookup. Exposures of one address and port
+// are merged.
+func Services(exps []Exposure, lookup func(host string) ([]string, error)) ([]indexed.Service, error) {
+	type key struct {
+		ip    string
+		port  uint16
+		proto string
+	}
+	index := make(map[key]int)
+	var out []indexed.Service
+	for _, e := range exps {
+		ips := []string{e.Address}
+		if ip := net.ParseIP(e.Address); ip != nil {
+			ips[0] = ip.String()
+		} else {
+			var err error
+			if ips, err = lookup(e.Address); err != nil {
+				return nil, fmt.Errorf("failed to resolve %s of %s: %v", e.Address, e.Service, err)
+			}
+		}
+		desc := e.Service + " " + e.Via
+		for _, ip := range ips {
+			k := key{ip, e.Port, e.Proto}
+			if i, ok := index[k]; ok {
+				if !strings.Contains(out[i].Product, desc) {
+					out[i].Product += ", " + desc
+				}
+				continue
+			}
+			index[k] = len(out)
+			out = append(out, indexed.Service{IP: ip, Port: e.Port, Proto: e.Proto, Product: desc, Source: "kubernetes"})
+		}
+	}
+	return out, nil
+}
+
+// Compare reports where the declared services and rep disagree, as
+// indexed.Compare does, with kinds DeclaredClosed, Undeclared and
+// NotScanned.
+func Compare(services []indexed.Service, rep report.ScanReport) []report.Change {
+	changes := indexed.Compare(services, rep)
+	for i := range changes {
+		switch changes[i].Kind {
+		case indexed.IndexedOnly:
+			changes[i].Kind = DeclaredClosed
+		case indexed.NotIndexed:
+			changes[i].Kind = Undeclared
+		}
+	}
+	return changes
+}
@@ -0,0 +1,133 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"portprowler/indexed"
+	"portprowler/port"
+	"portprowler/report"
+)
+
+const kubeconfigYAML = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: %s
+    insecure-skip-tls-verify: true
+  name: test
+- name: other
+  cluster: {}
+contexts:
+- context:
+    cluster: test
+    user: admin   # a comment
+  name: test
+current-context: "test"
+users:
+- name: admin
+  user:
+    tokenFile: token
+`
+
+func TestExposures(t *testing.T) {
+	pages := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"kind":"Status","message":"Unauthorized"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/nodes":
+			w.Write([]byte(`{"items":[{"metadata":{"name":"n1"},"status":{"addresses":[
+				{"type":"InternalIP","address":"10.0.0.1"},{"type":"Hostname","address":"n1"}]}}]}`))
+		case "/api/v1/services":
+			pages++
+			if r.URL.Query().Get("continue") == "" {
+				w.Write([]byte(`{"metadata":{"continue":"next"},"items":[
+					{"metadata":{"namespace":"default","name":"kubernetes"},"spec":{"type":"ClusterIP","ports":[{"port":443}]}},
+					{"metadata":{"namespace":"web","name":"shop"},"spec":{"type":"NodePort","ports":[{"port":80,"nodePort":30080,"protocol":"TCP"}]}}]}`))
+				return
+			}
+			w.Write([]byte(`{"metadata":{},"items":[
+				{"metadata":{"namespace":"web","name":"lb"},"spec":{"type":"LoadBalancer","ports":[{"port":443,"nodePort":30443,"protocol":"TCP"}],
+				"externalIPs":["192.0.2.9"]},"status":{"loadBalancer":{"ingress":[{"ip":"192.0.2.8"},{"hostname":"lb.example"}]}}}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+	os.WriteFile(path, []byte(strings.Replace(kubeconfigYAML, "%s", srv.URL, 1)), 0o600)
+	os.WriteFile(filepath.Join(dir, "token"), []byte("s3cret\n"), 0o600)
+	c, err := Load(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exps, err := c.Exposures(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages != 2 {
+		t.Errorf("want 2 pages of services, got %d", pages)
+	}
+	want := []string{
+		"10.0.0.1 30080 web/shop nodeport",
+		"10.0.0.1 30443 web/lb nodeport",
+		"192.0.2.8 443 web/lb loadbalancer",
+		"192.0.2.9 443 web/lb external-ip",
+		"lb.example 443 web/lb loadbalancer",
+	}
+	var got []string
+	for _, e := range exps {
+		got = append(got, fmt.Sprintf("%s %d %s %s", e.Address, e.Port, e.Service, e.Via))
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("exposures:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	svcs, err := Services(exps, func(host string) ([]string, error) { return []string{"192.0.2.8"}, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(svcs) != 4 || svcs[2].IP != "192.0.2.8" || svcs[2].Product != "web/lb loadbalancer" || svcs[2].Source != "kubernetes" {
+		t.Errorf("want the load balancer's hostname merged into its IP, got %+v", svcs)
+	}
+
+	if _, err := Load(path, "missing"); err == nil {
+		t.Error("want an error for an unknown context")
+	}
+	c.token = "wrong"
+	if _, err := c.Exposures(context.Background()); err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("want the API server's message, got %v", err)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	svcs := []indexed.Service{
+		{IP: "10.0.0.1", Port: 30080, Proto: "tcp", Product: "web/shop nodeport", Source: "kubernetes"},
+		{IP: "10.0.0.1", Port: 30443, Proto: "tcp", Product: "web/lb nodeport", Source: "kubernetes"},
+	}
+	rep := report.ScanReport{Hosts: []report.HostReport{{Target: "10.0.0.1", Results: []port.PortResult{
+		{IP: "10.0.0.1", Port: 30080, Proto: "tcp", State: "open"},
+		{IP: "10.0.0.1", Port: 30443, Proto: "tcp", State: "closed"},
+		{IP: "10.0.0.1", Port: 31000, Proto: "tcp", State: "open"},
+	}}}}
+	changes := Compare(svcs, rep)
+	kinds := map[uint16]string{}
+	for _, c := range changes {
+		kinds[c.Port] = c.Kind
+	}
+	if len(changes) != 2 || kinds[30443] != DeclaredClosed || kinds[31000] != Undeclared {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+}
@@ -0,0 +1,62 @@
+// Package logging writes diagnostics (preamble, verbose and progress
+// messages) to stderr so that stdout carries only scan results and can be
+// piped into other tools.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	mu     sync.Mutex
+	out    io.Writer = os.Stderr
+	silent bool
+)
+
+// SetOutput redirects diagnostics to w (stderr by default).
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	out = w
+}
+
+// SetSilent suppresses all diagnostics when s is true. Fatal errors are
+// reported by the caller and are not affected.
+func SetSilent(s bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	silent = s
+}
+
+// Infof writes a diagnostic line.
+func Infof(format string, args ...any) {
+	write("", format, args...)
+}
+
+// Warnf writes a diagnostic line prefixed with "warning: ".
+func Warnf(format string, args ...any) {
+	write("warning: ", format, args...)
+}
+
+// Verbosef writes a diagnostic line prefixed with "[verbose] ". Callers
+// decide whether verbose output is enabled.
+func Verbosef(format string, args ...any) {
+	write("[verbose] ", format, args...)
+}
+
+func write(prefix, format string, args ...any) {
+	msg := prefix + fmt.Sprintf(format, args...)
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if silent {
+		return
+	}
+	_, _ = io.WriteString(out, msg)
+}
@@ -0,0 +1,30 @@
+package logging
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestSilentAndPrefixes(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(os.Stderr)
+	defer SetSilent(false)
+
+	Infof("Ports: %s", "22")
+	Verbosef("tcp open %s\n", "127.0.0.1:22")
+	Warnf("no match")
+	want := "Ports: 22\n[verbose] tcp open 127.0.0.1:22\nwarning: no match\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	SetSilent(true)
+	Infof("hidden")
+	Verbosef("hidden")
+	if buf.Len() != 0 {
+		t.Fatalf("silent mode wrote %q", buf.String())
+	}
+}
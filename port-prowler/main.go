@@ -1,148 +1,154 @@
 package main
 
 import (
-	"bytes"
-	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
-	"time"
-
-	"portprowler/detector"
-	"portprowler/netutil"
-	"portprowler/output"
-	"portprowler/port"
-	"portprowler/scanner"
+	"sort"
 )
 
-func main() {
-	portsSpec := flag.String("p", "", "ports (e.g. 22,80,8000-8100) (required)")
-	tcp := flag.Bool("tcp", false, "perform tcp connect scan")
-	udp := flag.Bool("udp", false, "perform udp scan")
-	stealth := flag.Bool("s", false, "perform stealth scan (requires privileges)")
-	fileOut := flag.String("f", "", "write output to file (overwrite, atomic)")
-	serviceDetect := flag.Bool("service-detect", false, "enable service detection (opt-in)")
-	osDetect := flag.Bool("os-detect", false, "enable os detection (opt-in)")
-	workers := flag.Int("c", 100, "worker count (default 100)")
-	to := flag.Duration("t", time.Second, "per-probe timeout (default 1s)")
-	verbose := flag.Bool("v", false, "verbose logging")
-	flag.Parse()
+// command is a subcommand: it receives the arguments after its name and
+// returns the process exit status.
+type command struct {
+	run     func(args []string) int
+	summary string
+}
 
-	if flag.NArg() < 1 {
-		fmt.Fprintln(os.Stderr, "error: target positional argument required")
-		flag.Usage()
-		os.Exit(2)
-	}
-	target := flag.Arg(0)
+var commands = map[string]command{
+	"scan":     {runScan, "scan targets (the default when no command is given)"},
+	"discover": {runDiscover, "find live hosts among addresses, names and CIDR ranges"},
+	"diff":     {runDiff, "compare two scan reports (JSON files or history ids)"},
+	"compare":  {runCompare, "compare a Shodan/Censys export with a report or a fresh scan"},
+	"matrix":   {runMatrix, "line up reports scanned from several networks: which ports are reachable from where"},
+	"watch":    {runWatch, "rescan periodically and report what changed"},
+	"serve":    {runServe, "run the HTTP scan API"},
+	"history":  {runHistory, "list and show scans saved with --save"},
+	"verify":   {runVerify, "check report files against their --sign signatures"},
+	"probe":    {runProbe, "check one host:port and exit 0/1 (container healthchecks)"},
+	"track":    {runTrack, "probe host:ports at an interval and report each change of state (firewall change windows)"},
+	"check":    {runCheck, "monitoring plugin: OK/WARNING/CRITICAL for one port with rtt thresholds (Nagios, Icinga)"},
+	"profile":  {runProfile, "save, list, show and delete named sets of scan flags (--profile)"},
+	"demo":     {runDemo, "scan a fake target on this machine to try out flags offline (--check for CI smoke tests)"},
+	"version":  {func(args []string) int { return runVersion(args, os.Stdout) }, "print build information and check for updates"},
+	"caps":     {func([]string) int { return runCaps(os.Stdout) }, "report privileges and which scan modes will work"},
+}
 
-	if *portsSpec == "" {
-		fmt.Fprintln(os.Stderr, "error: -p <ports> is required (examples: -p 22 -p 22,80 -p 1-1024 -p 22,80,8000-8100)")
-		flag.Usage()
-		os.Exit(2)
+func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+		if a := os.Args[1]; a == "help" || a == "-h" || a == "-help" || a == "--help" {
+			usage()
+			os.Exit(0)
+		}
 	}
+	// `portprowler [flags] <target>...` keeps working as a scan.
+	os.Exit(runScan(os.Args[1:]))
+}
 
-	// Validate worker count early
-	if *workers <= 0 || *workers > 10000 {
-		fmt.Fprintln(os.Stderr, "error: invalid worker count (-c). Provide a positive value up to 10000.")
-		os.Exit(2)
+func usage() {
+	w := os.Stderr
+	fmt.Fprintf(w, "Usage: %s <command> [flags] [args]\n", progName())
+	fmt.Fprintf(w, "       %s [scan flags] <target> [target...]\n\nCommands:\n", progName())
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
 	}
-
-	ports, err := port.ParsePortSpec(*portsSpec)
-	if err != nil {
-		// make invalid port spec error clearer with example
-		fmt.Fprintf(os.Stderr, "Invalid port spec %q: %v\nExamples: -p 22  -p 22,80  -p 1-1024  -p 22,80,8000-8100\n", *portsSpec, err)
-		os.Exit(2)
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-9s %s\n", name, commands[name].summary)
 	}
+	fmt.Fprintf(w, "\nRun '%s <command> -h' for the flags of a command.\n", progName())
+	fmt.Fprintf(w, "Flags default to %s<FLAG> environment variables (e.g. %s, %s).\n", envPrefix, envName("c"), envName("tcp-timeout"))
+}
 
-	ipStr, err := netutil.ResolveTargetToIPv4(target)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to resolve target: %v\n", err)
-		os.Exit(4)
-	}
+func progName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// exitError is a command failure with its exit status: 2 for usage
+// errors, 3 for missing privileges, 4 for runtime failures. An empty msg
+// means the problem was already reported.
+type exitError struct {
+	code  int
+	msg   string
+	usage bool // also print the command's usage
+}
 
-	// Print Target line now; OS is computed after scan completes and printed next.
-	fmt.Printf("Target: %s -> %s\n", target, ipStr)
+func (e *exitError) Error() string { return e.msg }
 
-	cfg := scanner.Config{
-		Target:        target,
-		IP:            ipStr,
-		Ports:         ports,
-		ScanTCP:       *tcp,
-		ScanUDP:       *udp,
-		ScanStealth:   *stealth,
-		Workers:       *workers,
-		Timeout:       *to,
-		ServiceDetect: *serviceDetect,
-		OSDetect:      *osDetect,
-		Verbose:       *verbose,
-	}
+func usageErr(format string, a ...any) error {
+	return &exitError{code: 2, msg: fmt.Sprintf(format, a...)}
+}
 
-	mgr := scanner.NewManager(cfg)
+func runtimeErr(format string, a ...any) error {
+	return &exitError{code: 4, msg: fmt.Sprintf(format, a...)}
+}
 
-	ctx := context.Background()
-	resultsCh, err := mgr.Run(ctx)
-	if err != nil {
-		if errors.Is(err, scanner.ErrNeedPriv) {
-			fmt.Fprintln(os.Stderr, "Stealth scan (-s) requires raw socket privileges. Rerun with elevated privileges (root/CAP_NET_RAW) or remove -s to use TCP connect. No fallback is performed.")
-			os.Exit(3)
-		}
-		fmt.Fprintf(os.Stderr, "failed to start scanner manager: %v\n", err)
-		os.Exit(4)
+// exitStatus reports err on stderr and returns the exit status for it;
+// nil is success. Errors that are not exitErrors are runtime failures.
+func exitStatus(err error, fs *flag.FlagSet) int {
+	if err == nil {
+		return 0
 	}
-
-	// Collect all results into memory so we can run OS detection per-target (single OS guess).
-	var results []port.PortResult
-	for r := range resultsCh {
-		results = append(results, r)
+	var ee *exitError
+	if !errors.As(err, &ee) {
+		fmt.Fprintln(os.Stderr, err)
+		return 4
 	}
-
-	// Perform OS detection once for the target (based on all open-port results), if requested.
-	var osLine string
-	if cfg.OSDetect {
-		if osGuess, osConf := detector.DetectOS(results); osGuess != "" {
-			osLine = fmt.Sprintf("OS: %s (confidence: %s)\n", osGuess, osConf)
-		} else {
-			osLine = "OS: unknown\n"
-		}
-	} else {
-		osLine = "OS: disabled\n"
+	if ee.msg != "" {
+		fmt.Fprintln(os.Stderr, ee.msg)
 	}
-
-	// Print OS line, then Ports and Scan modes (match requested output ordering).
-	fmt.Print(osLine)
-	fmt.Printf("Ports: %s\n", *portsSpec)
-	fmt.Printf("Scan modes: tcp=%v udp=%v stealth=%v\n", cfg.ScanTCP, cfg.ScanUDP, cfg.ScanStealth)
-	fmt.Printf("Service detection: %v, OS detection: %v\n", cfg.ServiceDetect, cfg.OSDetect)
-	fmt.Printf("Workers: %d, timeout: %v, verbose: %v\n", cfg.Workers, cfg.Timeout, cfg.Verbose)
-	if *fileOut != "" {
-		fmt.Printf("File output: %s\n", *fileOut)
+	if ee.usage && fs != nil {
+		fs.Usage()
 	}
+	return ee.code
+}
 
-	// Render table into buffer
-	var buf bytes.Buffer
-	output.PrintTableFromSlice(results, &buf)
-
-	// Copy buffer to stdout
-	if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to write to stdout: %v\n", err)
-		os.Exit(4)
+// parseArgs parses fs from args, allowing flags after positional arguments
+// (`portprowler 10.0.0.1 -p 22`), fills flags not given from the
+// --profile profile (see applyProfile) and then from the environment (see
+// applyEnv), and returns the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return nil, err
 	}
-
-	// If file output requested, ensure parent dir exists and write atomically
-	// ensure result directory exists
-	if *fileOut != "" {
-		outDir := "result"
-		if err := os.MkdirAll(outDir, 0o755); err != nil {
-			fmt.Fprintf(os.Stderr, "failed to create result dir: %v\n", err)
-			os.Exit(4)
+	for _, apply := range []func(*flag.FlagSet) error{applyProfile, applyEnv} {
+		if err := apply(fs); err != nil {
+			fmt.Fprintln(fs.Output(), err)
+			fs.Usage()
+			return nil, err
 		}
+	}
+	return positional, nil
+}
 
-		outPath := filepath.Join(outDir, *fileOut)
-		if err := output.WriteAtomic(outPath, buf.Bytes()); err != nil {
-			fmt.Fprintf(os.Stderr, "failed to write output file: %v\n", err)
-			os.Exit(4)
+// parseFlags parses the command line only: flags may follow positional
+// arguments, and neither profiles nor the environment are consulted.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
 		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// parseStatus is the exit status for a flag parsing error: -h is not a
+// failure, anything else is a usage error (already reported by fs).
+func parseStatus(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
 	}
+	return 2
 }
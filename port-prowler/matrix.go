@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"portprowler/output"
+	"portprowler/report"
+)
+
+// runMatrix implements `portprowler matrix`: reports of the same targets
+// scanned from different networks are lined up in one table with a
+// column per network, showing which ports are reachable from where.
+func runMatrix(args []string) int {
+	fs := flag.NewFlagSet("matrix", flag.ContinueOnError)
+	format := fs.String("o", "table", "output format: table or json")
+	all := fs.Bool("all", false, "also list ports that are open from no vantage point")
+	partial := fs.Bool("partial", false, "only list ports open from some vantage points but not all")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s matrix [flags] [name=]<report> [name=]<report>...\n", progName())
+		fmt.Fprintln(fs.Output(), "Each report is a file written with -o json or the id of a scan saved with --save, scanned from")
+		fmt.Fprintln(fs.Output(), "the vantage point it is named after (by default the file name or id).")
+		fs.PrintDefaults()
+	}
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return parseStatus(err)
+	}
+	if len(names) < 2 {
+		return exitStatus(&exitError{code: 2, msg: "error: matrix needs at least two reports", usage: true}, fs)
+	}
+	if *format != "table" && *format != "json" {
+		return exitStatus(usageErr("error: unknown output format %q (want table or json)", *format), fs)
+	}
+	var vantages []report.Vantage
+	seen := map[string]bool{}
+	for _, arg := range names {
+		name, src := vantageName(arg)
+		if seen[name] {
+			return exitStatus(usageErr("error: vantage point %q given twice (name them with name=report)", name), fs)
+		}
+		seen[name] = true
+		rep, err := loadReport(src)
+		if err != nil {
+			return exitStatus(err, fs)
+		}
+		vantages = append(vantages, report.Vantage{Name: name, Report: rep})
+	}
+	m := report.BuildMatrix(vantages)
+	rows := m.Rows[:0]
+	for _, r := range m.Rows {
+		if (*partial && r.Reach != report.ReachPartial) || (!*all && r.Reach == report.ReachNone) {
+			continue
+		}
+		rows = append(rows, r)
+	}
+	m.Rows = rows
+	if err := writeMatrix(m, *format, os.Stdout); err != nil {
+		return exitStatus(runtimeErr("failed to write to stdout: %v", err), fs)
+	}
+	return 0
+}
+
+// vantageName splits a matrix argument into the vantage point's name and
+// the report to load: "office=scan.json", or "scan.json" named "scan".
+func vantageName(arg string) (name, src string) {
+	if i := strings.IndexByte(arg, '='); i > 0 {
+		if _, err := os.Stat(arg); err != nil {
+			return arg[:i], arg[i+1:]
+		}
+	}
+	base := filepath.Base(arg)
+	return strings.TrimSuffix(base, filepath.Ext(base)), arg
+}
+
+func writeMatrix(m report.Matrix, format string, w io.Writer) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	}
+	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
+	header := []string{"TARGET", "PORT", "SERVICE"}
+	for _, v := range m.Vantages {
+		header = append(header, output.Printable(strings.ToUpper(v)))
+	}
+	fmt.Fprintln(tw, strings.Join(append(header, "REACH"), "\t"))
+	for _, r := range m.Rows {
+		states := make([]string, len(r.States))
+		for i, s := range r.States {
+			if s == "" {
+				s = "-"
+			}
+			states[i] = s
+		}
+		service := r.Service
+		if service == "" {
+			service = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%d/%s\t%s\t%s\t%s\n", output.Printable(r.Target), r.Port, r.Proto, output.Printable(service), strings.Join(states, "\t"), r.Reach)
+	}
+	return tw.Flush()
+}
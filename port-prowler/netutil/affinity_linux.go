@@ -0,0 +1,46 @@
+//go:build linux
+// +build linux
+
+package netutil
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// PinThread restricts the calling OS thread to cpu. Callers lock their
+// goroutine to the thread first (runtime.LockOSThread).
+func PinThread(cpu int) error {
+	var mask [4096 / 64]uint64
+	if cpu < 0 || cpu >= len(mask)*64 {
+		return fmt.Errorf("CPU %d out of range", cpu)
+	}
+	mask[cpu/64] = 1 << (cpu % 64)
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask)))
+	if errno != 0 {
+		return fmt.Errorf("pin thread to CPU %d: %w", cpu, errno)
+	}
+	return nil
+}
+
+// NUMACPUs returns the CPUs of the NUMA node the network interface is
+// attached to, so packet processing stays next to the NIC's memory.
+func NUMACPUs(iface string) ([]int, error) {
+	raw, err := os.ReadFile("/sys/class/net/" + iface + "/device/numa_node")
+	if err != nil {
+		return nil, fmt.Errorf("%s has no NUMA node: %w", iface, err)
+	}
+	node, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	if err != nil || node < 0 {
+		return nil, fmt.Errorf("%s has no NUMA node", iface)
+	}
+	list, err := os.ReadFile(fmt.Sprintf("/sys/devices/system/node/node%d/cpulist", node))
+	if err != nil {
+		return nil, err
+	}
+	return ParseCPUList(string(list))
+}
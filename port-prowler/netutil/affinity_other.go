@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package netutil
+
+// PinThread restricts the calling OS thread to cpu; Linux only.
+func PinThread(cpu int) error { return ErrNoAffinity }
+
+// NUMACPUs returns the CPUs local to the network interface; Linux only.
+func NUMACPUs(iface string) ([]int, error) { return nil, ErrNoAffinity }
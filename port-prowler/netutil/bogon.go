@@ -0,0 +1,74 @@
+package netutil
+
+import (
+	"net"
+	"strings"
+)
+
+// Address classes returned by AddrClass.
+const (
+	ClassLoopback    = "loopback"
+	ClassPrivate     = "private"
+	ClassLinkLocal   = "link-local"
+	ClassMulticast   = "multicast"
+	ClassUnspecified = "unspecified"
+	ClassReserved    = "reserved"
+)
+
+// privateNets are RFC 1918, RFC 6598 shared address space and IPv6
+// unique local addresses.
+var privateNets = mustCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7")
+
+// reservedNets are special-purpose ranges that are not routed on the
+// internet: "this network", IETF protocol assignments, documentation,
+// benchmarking and the old class E space including broadcast.
+var reservedNets = mustCIDRs(
+	"0.0.0.0/8", "192.0.0.0/24", "192.0.2.0/24", "198.18.0.0/15",
+	"198.51.100.0/24", "203.0.113.0/24", "240.0.0.0/4",
+	"100::/64", "2001:db8::/32",
+)
+
+func mustCIDRs(cidrs ...string) []*net.IPNet {
+	out := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// AddrClass names the non-public range ip belongs to (one of the Class
+// constants), or returns "" for a public address.
+func AddrClass(ip net.IP) string {
+	switch {
+	case ip.IsUnspecified():
+		return ClassUnspecified
+	case ip.IsLoopback():
+		return ClassLoopback
+	case ip.IsMulticast():
+		return ClassMulticast
+	case ip.IsLinkLocalUnicast():
+		return ClassLinkLocal
+	}
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return ClassPrivate
+		}
+	}
+	for _, n := range reservedNets {
+		if n.Contains(ip) {
+			return ClassReserved
+		}
+	}
+	return ""
+}
+
+// IsLocalhostName reports whether name is localhost or a name under
+// .localhost, which always resolve to loopback (RFC 6761).
+func IsLocalhostName(name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	return name == "localhost" || strings.HasSuffix(name, ".localhost")
+}
@@ -0,0 +1,49 @@
+package netutil
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAddrClass(t *testing.T) {
+	cases := map[string]string{
+		"127.0.0.1":       ClassLoopback,
+		"::1":             ClassLoopback,
+		"10.1.2.3":        ClassPrivate,
+		"172.31.0.1":      ClassPrivate,
+		"172.32.0.1":      "",
+		"192.168.1.1":     ClassPrivate,
+		"100.64.0.1":      ClassPrivate,
+		"fd00::1":         ClassPrivate,
+		"169.254.1.1":     ClassLinkLocal,
+		"fe80::1":         ClassLinkLocal,
+		"224.0.0.251":     ClassMulticast,
+		"ff02::1":         ClassMulticast,
+		"0.0.0.0":         ClassUnspecified,
+		"0.1.2.3":         ClassReserved,
+		"192.0.2.10":      ClassReserved,
+		"255.255.255.255": ClassReserved,
+		"2001:db8::1":     ClassReserved,
+		"93.184.216.34":   "",
+		"2606:4700::1":    "",
+	}
+	for addr, want := range cases {
+		if got := AddrClass(net.ParseIP(addr)); got != want {
+			t.Errorf("AddrClass(%s) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+func TestIsLocalhostName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"localhost":         true,
+		"LocalHost.":        true,
+		"app.localhost":     true,
+		"localhost.example": false,
+		"mylocalhost":       false,
+	} {
+		if got := IsLocalhostName(name); got != want {
+			t.Errorf("IsLocalhostName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package netutil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+)
+
+// ErrReadBudget is returned by reads on connections whose ReadBudget is
+// used up.
+var ErrReadBudget = errors.New("read budget exhausted")
+
+// ReadBudget caps the bytes read across every connection opened through
+// its Dialer, so a hostile service cannot stream an unbounded response
+// into memory. Reads fail with ErrReadBudget once it is spent.
+type ReadBudget struct {
+	mu   sync.Mutex
+	left int64
+}
+
+// NewReadBudget returns a budget of n bytes.
+func NewReadBudget(n int64) *ReadBudget {
+	return &ReadBudget{left: n}
+}
+
+// Left returns the bytes still available.
+func (b *ReadBudget) Left() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.left
+}
+
+// take reserves up to n bytes and returns how many were granted.
+func (b *ReadBudget) take(n int) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if int64(n) > b.left {
+		n = int(b.left)
+	}
+	b.left -= int64(n)
+	return n
+}
+
+func (b *ReadBudget) refund(n int) {
+	b.mu.Lock()
+	b.left += int64(n)
+	b.mu.Unlock()
+}
+
+// Dialer returns a dialer whose connections draw on b; a nil d dials
+// directly.
+func (b *ReadBudget) Dialer(d ContextDialer) ContextDialer {
+	if d == nil {
+		d = &net.Dialer{}
+	}
+	return budgetDialer{d, b}
+}
+
+type budgetDialer struct {
+	d ContextDialer
+	b *ReadBudget
+}
+
+func (bd budgetDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	c, err := bd.d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &budgetConn{Conn: c, b: bd.b}, nil
+}
+
+type budgetConn struct {
+	net.Conn
+	b *ReadBudget
+}
+
+func (c *budgetConn) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return c.Conn.Read(p)
+	}
+	granted := c.b.take(len(p))
+	if granted == 0 {
+		return 0, ErrReadBudget
+	}
+	n, err := c.Conn.Read(p[:granted])
+	c.b.refund(granted - n)
+	return n, err
+}
@@ -0,0 +1,48 @@
+package netutil
+
+import "runtime"
+
+// Capability describes one privilege or resource the scanner may rely on.
+type Capability struct {
+	Name      string // short identifier, e.g. "raw-socket"
+	Available bool
+	Detail    string // how it was determined or why it is missing
+}
+
+// ModeSupport reports whether a scan mode can run with the detected capabilities.
+type ModeSupport struct {
+	Mode      string // "tcp", "udp", "stealth"
+	Available bool
+	Reason    string
+}
+
+// minNoFile is the open-file soft limit below which high worker counts
+// start failing with "too many open files".
+const minNoFile = 4096
+
+// Capabilities reports what the current process can do on this platform:
+// raw sockets, ICMP, packet capture and file descriptor limits.
+func Capabilities() []Capability {
+	return platformCapabilities()
+}
+
+// Platform returns the GOOS/GOARCH pair the capabilities apply to.
+func Platform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+// ScanModes maps capabilities to the scan modes that will work.
+func ScanModes(caps []Capability) []ModeSupport {
+	raw := false
+	for _, c := range caps {
+		if c.Name == "raw-socket" {
+			raw = c.Available
+		}
+	}
+	modes := []ModeSupport{
+		{Mode: "tcp", Available: true, Reason: "unprivileged connect()"},
+		{Mode: "udp", Available: true, Reason: "unprivileged datagram socket"},
+		{Mode: "stealth", Available: raw, Reason: "requires raw sockets"},
+	}
+	return modes
+}
@@ -0,0 +1,33 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+// +build darwin freebsd netbsd openbsd dragonfly
+
+package netutil
+
+import (
+	"os"
+	"syscall"
+)
+
+func platformCapabilities() []Capability {
+	return []Capability{
+		socketCapability("raw-socket", syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_TCP),
+		socketCapability("icmp-raw", syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_ICMP),
+		socketCapability("icmp-dgram", syscall.AF_INET, syscall.SOCK_DGRAM, syscall.IPPROTO_ICMP),
+		bpfCapability(),
+		noFileCapability(),
+	}
+}
+
+// bpfCapability checks that a /dev/bpf device can be opened for capture.
+func bpfCapability() Capability {
+	f, err := os.OpenFile("/dev/bpf", os.O_RDWR, 0)
+	if err != nil {
+		// older systems only expose numbered devices
+		f, err = os.OpenFile("/dev/bpf0", os.O_RDWR, 0)
+	}
+	if err != nil {
+		return Capability{Name: "pcap", Detail: err.Error()}
+	}
+	_ = f.Close()
+	return Capability{Name: "pcap", Available: true, Detail: "bpf device opened"}
+}
@@ -0,0 +1,20 @@
+//go:build linux
+// +build linux
+
+package netutil
+
+import "syscall"
+
+func platformCapabilities() []Capability {
+	// ETH_P_ALL in network byte order for AF_PACKET.
+	const ethPAll = 0x0300
+	caps := []Capability{
+		socketCapability("raw-socket", syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_TCP),
+		socketCapability("icmp-raw", syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_ICMP),
+		// unprivileged ping sockets, gated by net.ipv4.ping_group_range
+		socketCapability("icmp-dgram", syscall.AF_INET, syscall.SOCK_DGRAM, syscall.IPPROTO_ICMP),
+		socketCapability("pcap", syscall.AF_PACKET, syscall.SOCK_RAW, ethPAll),
+		noFileCapability(),
+	}
+	return caps
+}
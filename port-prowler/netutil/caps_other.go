@@ -0,0 +1,10 @@
+//go:build !linux && !windows && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!windows,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package netutil
+
+func platformCapabilities() []Capability {
+	return []Capability{
+		{Name: "raw-socket", Detail: "capability detection not supported on this platform"},
+	}
+}
@@ -0,0 +1,38 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package netutil
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// trySocket opens and immediately closes a socket to probe whether the
+// process is allowed to create it.
+func trySocket(domain, typ, proto int) error {
+	fd, err := syscall.Socket(domain, typ, proto)
+	if err != nil {
+		return err
+	}
+	return syscall.Close(fd)
+}
+
+func socketCapability(name string, domain, typ, proto int) Capability {
+	if err := trySocket(domain, typ, proto); err != nil {
+		return Capability{Name: name, Detail: err.Error()}
+	}
+	return Capability{Name: name, Available: true, Detail: "socket opened"}
+}
+
+func noFileCapability() Capability {
+	var lim syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim); err != nil {
+		return Capability{Name: "high-ulimit", Detail: err.Error()}
+	}
+	detail := fmt.Sprintf("nofile soft=%d hard=%d", lim.Cur, lim.Max)
+	if lim.Cur < minNoFile {
+		detail += fmt.Sprintf(" (raise to >= %d for large worker counts)", minNoFile)
+	}
+	return Capability{Name: "high-ulimit", Available: lim.Cur >= minNoFile, Detail: detail}
+}
@@ -0,0 +1,28 @@
+//go:build windows
+// +build windows
+
+package netutil
+
+import (
+	"os"
+	"path/filepath"
+)
+
+func platformCapabilities() []Capability {
+	caps := []Capability{
+		{Name: "raw-socket", Detail: "raw TCP sockets are not supported on Windows"},
+		{Name: "icmp-raw", Detail: "not implemented on Windows in this build"},
+		npcapCapability(),
+		{Name: "high-ulimit", Available: true, Detail: "no per-process descriptor limit"},
+	}
+	return caps
+}
+
+// npcapCapability looks for an installed Npcap driver.
+func npcapCapability() Capability {
+	dir := filepath.Join(os.Getenv("SystemRoot"), "System32", "Npcap")
+	if _, err := os.Stat(dir); err != nil {
+		return Capability{Name: "pcap", Detail: "Npcap not installed"}
+	}
+	return Capability{Name: "pcap", Available: true, Detail: "Npcap found at " + dir}
+}
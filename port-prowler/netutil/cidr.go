@@ -0,0 +1,40 @@
+package netutil
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// MaxCIDRHosts caps how many addresses ExpandCIDR returns (a /16).
+const MaxCIDRHosts = 65536
+
+// ExpandCIDR returns the host addresses of an IPv4 CIDR block in order.
+// The network and broadcast addresses are left out for blocks larger
+// than /31.
+func ExpandCIDR(cidr string) ([]string, error) {
+	ip, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("%s: only IPv4 ranges are supported", cidr)
+	}
+	ones, bits := ipnet.Mask.Size()
+	size := uint64(1) << uint(bits-ones)
+	if size > MaxCIDRHosts {
+		return nil, fmt.Errorf("%s: range is larger than %d addresses", cidr, MaxCIDRHosts)
+	}
+	first := binary.BigEndian.Uint32(ipnet.IP.To4())
+	start, end := uint64(0), size
+	if size > 2 {
+		start, end = 1, size-1
+	}
+	out := make([]string, 0, end-start)
+	for i := start; i < end; i++ {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], first+uint32(i))
+		out = append(out, net.IP(b[:]).String())
+	}
+	return out, nil
+}
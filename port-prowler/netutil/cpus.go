@@ -0,0 +1,44 @@
+package netutil
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNoAffinity is returned by PinThread and NUMACPUs where threads cannot
+// be pinned to CPUs.
+var ErrNoAffinity = errors.New("CPU pinning is only supported on Linux")
+
+// ParseCPUList parses a CPU list in the kernel's cpulist format, e.g.
+// "0-3,8,10-11", into sorted, distinct CPU numbers.
+func ParseCPUList(s string) ([]int, error) {
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(strings.TrimSpace(s), ",") {
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid CPU %q in list %q", part, s)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil || last < first {
+				return nil, fmt.Errorf("invalid CPU range %q in list %q", part, s)
+			}
+		}
+		if last >= 4096 {
+			return nil, fmt.Errorf("CPU %d out of range", last)
+		}
+		for c := first; c <= last; c++ {
+			seen[c] = true
+		}
+	}
+	cpus := make([]int, 0, len(seen))
+	for c := range seen {
+		cpus = append(cpus, c)
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
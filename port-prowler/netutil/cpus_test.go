@@ -0,0 +1,25 @@
+package netutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	for in, want := range map[string][]int{
+		"0":          {0},
+		"0-3,8":      {0, 1, 2, 3, 8},
+		" 2,1, 1-2 ": {1, 2},
+		"10-11,4\n":  {4, 10, 11},
+	} {
+		got, err := ParseCPUList(in)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseCPUList(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "a", "3-1", "-1", "1,", "0-99999"} {
+		if _, err := ParseCPUList(in); err == nil {
+			t.Errorf("ParseCPUList(%q) should fail", in)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package netutil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sort"
+	"time"
+)
+
+// Neighbor is an IPv6 host found on the local segment.
+type Neighbor struct {
+	IP  string // with the interface as zone when link-local (fe80::1%eth0)
+	MAC string // link-layer address, when the neighbour cache knows it
+	Via string // NeighborPing or NeighborCached
+}
+
+// How a neighbour was found.
+const (
+	NeighborPing   = "nd-ping"  // answered an echo request to ff02::1
+	NeighborCached = "nd-cache" // listed in the kernel's neighbour cache
+)
+
+// ErrNoNeighborCache is returned by NeighborCache where the neighbour
+// cache cannot be read.
+var ErrNoNeighborCache = errors.New("reading the IPv6 neighbour cache is only supported on Linux")
+
+// allNodes is the link-local all-nodes multicast group.
+var allNodes = net.ParseIP("ff02::1")
+
+// PingAllNodes sends ICMPv6 echo requests to the all-nodes group ff02::1
+// on the interface named iface through conn, a raw ICMPv6 socket
+// (network "ip6:ipv6-icmp", which needs privileges), and collects the
+// hosts that answer within wait. IPv6 ranges are too large to sweep, but
+// every host on a segment answers this one multicast ping. The request
+// is sent twice, at the start and halfway through, in case one is lost.
+func PingAllNodes(ctx context.Context, conn net.PacketConn, iface string, wait time.Duration) ([]Neighbor, error) {
+	var idb [2]byte
+	_, _ = rand.Read(idb[:])
+	id := binary.BigEndian.Uint16(idb[:])
+	dst := &net.IPAddr{IP: allNodes, Zone: iface}
+	send := func(seq uint16) error {
+		msg := make([]byte, 8+16)
+		msg[0] = 128 // echo request; the kernel fills in the checksum
+		binary.BigEndian.PutUint16(msg[4:6], id)
+		binary.BigEndian.PutUint16(msg[6:8], seq)
+		copy(msg[8:], "portprowler-nd6")
+		_, err := conn.WriteTo(msg, dst)
+		return err
+	}
+	if err := send(1); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	deadline := start.Add(wait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	resent := false
+	seen := make(map[string]bool)
+	var out []Neighbor
+	buf := make([]byte, 1500)
+	for {
+		next := deadline
+		if !resent {
+			next = start.Add(wait / 2)
+		}
+		_ = conn.SetReadDeadline(next)
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			var ne net.Error
+			if !errors.As(err, &ne) || !ne.Timeout() {
+				return nil, err
+			}
+			if ctx.Err() != nil || !time.Now().Before(deadline) {
+				break
+			}
+			if !resent {
+				resent = true
+				if err := send(2); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		a, ok := from.(*net.IPAddr)
+		msg := buf[:n]
+		if !ok || len(msg) < 8 || msg[0] != 129 || binary.BigEndian.Uint16(msg[4:6]) != id {
+			continue
+		}
+		ip := a.IP.String()
+		if a.IP.IsLinkLocalUnicast() {
+			ip += "%" + iface
+		}
+		if !seen[ip] {
+			seen[ip] = true
+			out = append(out, Neighbor{IP: ip, Via: NeighborPing})
+		}
+	}
+	return out, nil
+}
+
+// MergeNeighbors combines neighbour lists, keeping the first entry per
+// address but filling in a MAC address a later one knows, and sorts the
+// result by address.
+func MergeNeighbors(lists ...[]Neighbor) []Neighbor {
+	index := make(map[string]int)
+	var out []Neighbor
+	for _, list := range lists {
+		for _, n := range list {
+			if i, ok := index[n.IP]; ok {
+				if out[i].MAC == "" {
+					out[i].MAC = n.MAC
+				}
+				continue
+			}
+			index[n.IP] = len(out)
+			out = append(out, n)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].IP < out[j].IP })
+	return out
+}
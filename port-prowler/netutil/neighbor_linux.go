@@ -0,0 +1,87 @@
+//go:build linux
+// +build linux
+
+package netutil
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// Neighbour table attributes and states (linux/neighbour.h).
+const (
+	ndaDst    = 1
+	ndaLLAddr = 2
+
+	nudValid = 0x02 | 0x04 | 0x08 | 0x10 | 0x80 // reachable, stale, delay, probe, permanent
+)
+
+// ndMsg is struct ndmsg, which heads every neighbour table entry.
+type ndMsg struct {
+	Family  uint8
+	_       [3]byte
+	Ifindex int32
+	State   uint16
+	Flags   uint8
+	Type    uint8
+}
+
+// NeighborCache returns the IPv6 neighbours of the interface named iface
+// that the kernel's neighbour cache (`ip -6 neigh`) holds as reachable
+// or recently seen, read over netlink.
+func NeighborCache(iface string) ([]Neighbor, error) {
+	ifc, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, err
+	}
+	tab, err := syscall.NetlinkRIB(syscall.RTM_GETNEIGH, syscall.AF_INET6)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(tab)
+	if err != nil {
+		return nil, err
+	}
+	var out []Neighbor
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWNEIGH || len(m.Data) < int(unsafe.Sizeof(ndMsg{})) {
+			continue
+		}
+		nd := (*ndMsg)(unsafe.Pointer(&m.Data[0]))
+		if nd.Family != syscall.AF_INET6 || int(nd.Ifindex) != ifc.Index || nd.State&nudValid == 0 {
+			continue
+		}
+		var n Neighbor
+		var ip net.IP
+		for b := m.Data[unsafe.Sizeof(ndMsg{}):]; len(b) >= syscall.SizeofRtAttr; {
+			a := (*syscall.RtAttr)(unsafe.Pointer(&b[0]))
+			if int(a.Len) < syscall.SizeofRtAttr || int(a.Len) > len(b) {
+				break
+			}
+			value := b[syscall.SizeofRtAttr:a.Len]
+			switch a.Type {
+			case ndaDst:
+				if len(value) == net.IPv6len {
+					ip = net.IP(append([]byte(nil), value...))
+				}
+			case ndaLLAddr:
+				n.MAC = net.HardwareAddr(value).String()
+			}
+			next := (int(a.Len) + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+			if next > len(b) {
+				break
+			}
+			b = b[next:]
+		}
+		if ip == nil || ip.IsMulticast() {
+			continue
+		}
+		n.IP, n.Via = ip.String(), NeighborCached
+		if ip.IsLinkLocalUnicast() {
+			n.IP += "%" + iface
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
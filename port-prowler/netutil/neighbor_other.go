@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package netutil
+
+// NeighborCache returns the IPv6 neighbours of an interface from the
+// kernel's neighbour cache; Linux only.
+func NeighborCache(iface string) ([]Neighbor, error) { return nil, ErrNoNeighborCache }
@@ -0,0 +1,94 @@
+package netutil
+
+import (
+	"context"
+	"net"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// echoSegment is a PacketConn on a fake segment whose hosts answer echo
+// requests to ff02::1; the first request is lost.
+type echoSegment struct {
+	hosts   []string
+	sent    int
+	replies [][]byte
+	from    []net.Addr
+	dl      time.Time
+}
+
+func (s *echoSegment) WriteTo(b []byte, addr net.Addr) (int, error) {
+	s.sent++
+	if a := addr.(*net.IPAddr); !a.IP.Equal(allNodes) || a.Zone != "eth0" || b[0] != 128 {
+		return 0, os.ErrInvalid
+	}
+	if s.sent == 1 {
+		return len(b), nil
+	}
+	for _, h := range s.hosts {
+		reply := append([]byte(nil), b...)
+		reply[0] = 129
+		s.replies = append(s.replies, reply)
+		s.from = append(s.from, &net.IPAddr{IP: net.ParseIP(h), Zone: "eth0"})
+	}
+	// A stray reply to someone else's ping.
+	s.replies = append(s.replies, []byte{129, 0, 0, 0, 0xde, 0xad, 0, 1})
+	s.from = append(s.from, &net.IPAddr{IP: net.ParseIP("fe80::99")})
+	return len(b), nil
+}
+
+func (s *echoSegment) ReadFrom(b []byte) (int, net.Addr, error) {
+	if len(s.replies) == 0 {
+		if d := time.Until(s.dl); d > 0 {
+			time.Sleep(d)
+		}
+		return 0, nil, os.ErrDeadlineExceeded
+	}
+	n := copy(b, s.replies[0])
+	from := s.from[0]
+	s.replies, s.from = s.replies[1:], s.from[1:]
+	return n, from, nil
+}
+
+func (s *echoSegment) SetReadDeadline(t time.Time) error { s.dl = t; return nil }
+func (s *echoSegment) SetDeadline(t time.Time) error     { s.dl = t; return nil }
+func (s *echoSegment) SetWriteDeadline(time.Time) error  { return nil }
+func (s *echoSegment) Close() error                      { return nil }
+func (s *echoSegment) LocalAddr() net.Addr               { return &net.IPAddr{} }
+
+func TestPingAllNodes(t *testing.T) {
+	seg := &echoSegment{hosts: []string{"fe80::1", "2001:db8::7", "fe80::1"}}
+	got, err := PingAllNodes(context.Background(), seg, "eth0", 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seg.sent != 2 || len(got) != 2 || got[0].IP != "fe80::1%eth0" || got[1].IP != "2001:db8::7" || got[0].Via != NeighborPing {
+		t.Fatalf("sent %d, found %+v", seg.sent, got)
+	}
+
+	cached := []Neighbor{{IP: "fe80::1%eth0", MAC: "02:00:00:00:00:01", Via: NeighborCached}, {IP: "2001:db8::2", Via: NeighborCached}}
+	all := MergeNeighbors(got, cached)
+	if len(all) != 3 || all[0].IP != "2001:db8::2" || all[2].MAC != "02:00:00:00:00:01" || all[2].Via != NeighborPing {
+		t.Fatalf("merged %+v", all)
+	}
+}
+
+func TestNeighborCache(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		if _, err := NeighborCache("lo0"); err != ErrNoNeighborCache {
+			t.Fatalf("want ErrNoNeighborCache, got %v", err)
+		}
+		return
+	}
+	if _, err := NeighborCache("no-such-if0"); err == nil {
+		t.Error("want an error for a missing interface")
+	}
+	ifaces, _ := net.Interfaces()
+	for _, ifc := range ifaces {
+		if _, err := NeighborCache(ifc.Name); err != nil {
+			t.Errorf("%s: %v", ifc.Name, err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+//go:build linux
+// +build linux
+
+package netutil
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// capNetRaw is the CAP_NET_RAW capability bit (linux/capability.h).
+const capNetRaw = 13
+
+// CanOpenRawSocket returns true when the process has privileges to open raw sockets.
+// Linux implementation: check CAP_NET_RAW in the effective capability set, so
+// binaries granted the capability via setcap work without root and root in a
+// container that dropped it is correctly refused. When the capability set
+// cannot be read, fall back to actually opening a raw socket.
+func CanOpenRawSocket() (bool, error) {
+	f, err := os.Open("/proc/self/status")
+	if err == nil {
+		defer f.Close()
+		eff, perr := parseCapEff(f)
+		if perr == nil {
+			return eff&(1<<capNetRaw) != 0, nil
+		}
+	}
+	return trySocket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_TCP) == nil, nil
+}
+
+// parseCapEff extracts the effective capability mask from /proc/<pid>/status content.
+func parseCapEff(r io.Reader) (uint64, error) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, "CapEff:") {
+			continue
+		}
+		return strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+	}
+	if err := sc.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.New("CapEff not found")
+}
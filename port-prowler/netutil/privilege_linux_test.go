@@ -0,0 +1,24 @@
+//go:build linux
+// +build linux
+
+package netutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCapEff(t *testing.T) {
+	status := "Name:\tportprowler\nCapInh:\t0000000000000000\nCapPrm:\t0000000000002000\nCapEff:\t0000000000002000\n"
+	eff, err := parseCapEff(strings.NewReader(status))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if eff&(1<<capNetRaw) == 0 {
+		t.Fatalf("expected CAP_NET_RAW in %x", eff)
+	}
+
+	if _, err := parseCapEff(strings.NewReader("Name:\tx\n")); err == nil {
+		t.Fatalf("expected error when CapEff is missing")
+	}
+}
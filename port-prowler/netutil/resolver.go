@@ -3,6 +3,7 @@ package netutil
 import (
 	"errors"
 	"net"
+	"strings"
 )
 
 // ResolveTargetToIPv4 resolves the given target (hostname or IP string)
@@ -35,3 +36,82 @@ func ResolveTargetToIPv4(target string) (string, error) {
 	}
 	return "", errors.New("no A records found for host")
 }
+
+// lookupIP resolves host names; tests may replace it.
+var lookupIP = net.LookupIP
+
+// ResolveOptions selects which addresses ResolveTarget returns.
+type ResolveOptions struct {
+	// DualStack also returns an IPv6 address when the host has AAAA
+	// records, so both families are scanned.
+	DualStack bool
+	// AllIPs returns every resolved address (e.g. all DNS round-robin
+	// members) instead of the first one per family.
+	AllIPs bool
+}
+
+// ResolveTarget resolves target (hostname or IP literal) to the addresses
+// to scan: by default the first IPv4 address, with AllIPs every IPv4
+// address, and with DualStack the IPv6 address(es) as well. IPv4
+// addresses come first, each family in resolver order without duplicates.
+// IPv6 literals, which need DualStack, may carry a zone (fe80::1%eth0)
+// naming the interface of a link-local address; it is kept.
+func ResolveTarget(target string, opts ResolveOptions) ([]string, error) {
+	if ip := ParseIP(target); ip != nil && ip.To4() == nil && !opts.DualStack {
+		return nil, errors.New("IPv6 addresses are not supported (use --dual-stack)")
+	}
+	if strings.Contains(target, "%") {
+		addr, err := zonedAddr(target)
+		if err != nil {
+			return nil, err
+		}
+		return []string{addr}, nil
+	}
+	if !opts.DualStack && !opts.AllIPs {
+		ip, err := ResolveTargetToIPv4(target)
+		if err != nil {
+			return nil, err
+		}
+		return []string{ip}, nil
+	}
+	if ip := net.ParseIP(target); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return []string{ip4.String()}, nil
+		}
+		return []string{ip.String()}, nil
+	}
+	ips, err := lookupIP(target)
+	if err != nil {
+		return nil, err
+	}
+	var v4, v6 []string
+	seen := make(map[string]bool)
+	for _, ip := range ips {
+		s := ip.String()
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		if ip4 := ip.To4(); ip4 != nil {
+			v4 = append(v4, ip4.String())
+		} else if opts.DualStack {
+			v6 = append(v6, s)
+		}
+	}
+	if !opts.AllIPs {
+		if len(v4) > 1 {
+			v4 = v4[:1]
+		}
+		if len(v6) > 1 {
+			v6 = v6[:1]
+		}
+	}
+	out := append(v4, v6...)
+	if len(out) == 0 {
+		if opts.DualStack {
+			return nil, errors.New("no A or AAAA records found for host")
+		}
+		return nil, errors.New("no A records found for host (IPv6-only hosts need --dual-stack)")
+	}
+	return out, nil
+}
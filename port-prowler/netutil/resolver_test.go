@@ -22,3 +22,72 @@ func TestResolveTargetToIPv4_LiteralIPv4(t *testing.T) {
 		t.Fatalf("got %s want 1.2.3.4", ip)
 	}
 }
+
+func TestResolveTarget_DualStack(t *testing.T) {
+	defer func(f func(string) ([]net.IP, error)) { lookupIP = f }(lookupIP)
+	lookupIP = func(string) ([]net.IP, error) {
+		return []net.IP{
+			net.ParseIP("2001:db8::1"),
+			net.ParseIP("192.0.2.10"),
+			net.ParseIP("2001:db8::2"),
+			net.ParseIP("192.0.2.11"),
+		}, nil
+	}
+	got, err := ResolveTarget("dual.example", ResolveOptions{DualStack: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0] != "192.0.2.10" || got[1] != "2001:db8::1" {
+		t.Fatalf("got %v, want [192.0.2.10 2001:db8::1]", got)
+	}
+	if got, err := ResolveTarget("2001:db8::5", ResolveOptions{DualStack: true}); err != nil || got[0] != "2001:db8::5" {
+		t.Fatalf("IPv6 literal: got %v, %v", got, err)
+	}
+	if _, err := ResolveTarget("2001:db8::5", ResolveOptions{}); err == nil {
+		t.Fatalf("IPv6 literal accepted without dual-stack")
+	}
+}
+
+func TestResolveTarget_AllIPs(t *testing.T) {
+	defer func(f func(string) ([]net.IP, error)) { lookupIP = f }(lookupIP)
+	lookupIP = func(string) ([]net.IP, error) {
+		return []net.IP{
+			net.ParseIP("192.0.2.10"),
+			net.ParseIP("2001:db8::1"),
+			net.ParseIP("192.0.2.11"),
+			net.ParseIP("192.0.2.10"),
+		}, nil
+	}
+	got, err := ResolveTarget("rr.example", ResolveOptions{AllIPs: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0] != "192.0.2.10" || got[1] != "192.0.2.11" {
+		t.Fatalf("got %v, want both A records once", got)
+	}
+	got, _ = ResolveTarget("rr.example", ResolveOptions{AllIPs: true, DualStack: true})
+	if len(got) != 3 || got[2] != "2001:db8::1" {
+		t.Fatalf("got %v, want A records then AAAA", got)
+	}
+}
+
+func TestExpandCIDR(t *testing.T) {
+	got, err := ExpandCIDR("192.0.2.5/30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "192.0.2.5" || got[1] != "192.0.2.6" {
+		t.Fatalf("/30 = %v", got)
+	}
+	if got, _ := ExpandCIDR("192.0.2.7/32"); len(got) != 1 || got[0] != "192.0.2.7" {
+		t.Fatalf("/32 = %v", got)
+	}
+	if got, _ := ExpandCIDR("10.0.0.0/16"); len(got) != 65534 || got[len(got)-1] != "10.0.255.254" {
+		t.Fatalf("/16 has %d hosts", len(got))
+	}
+	for _, bad := range []string{"10.0.0.0/8", "2001:db8::/120", "10.0.0.1"} {
+		if _, err := ExpandCIDR(bad); err == nil {
+			t.Fatalf("ExpandCIDR(%q) succeeded", bad)
+		}
+	}
+}
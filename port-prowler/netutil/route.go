@@ -0,0 +1,132 @@
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Route describes how the local host would reach a destination.
+type Route struct {
+	Dst       net.IP
+	Src       net.IP // source address the kernel selects
+	Interface string // name of the interface owning Src
+}
+
+// RouteTo asks the kernel which source address and interface would carry
+// traffic to dst. It connects a UDP socket, which performs the route lookup
+// without sending any packets.
+func RouteTo(dst net.IP) (Route, error) {
+	return routeTo(dst, "")
+}
+
+// routeTo is RouteTo for an IPv6 destination scoped to the interface
+// zone, as link-local addresses are.
+func routeTo(dst net.IP, zone string) (Route, error) {
+	network := "udp6"
+	if dst.To4() != nil {
+		network = "udp4"
+	}
+	c, err := net.DialUDP(network, nil, &net.UDPAddr{IP: dst, Port: 9, Zone: zone})
+	if err != nil {
+		return Route{}, fmt.Errorf("route lookup for %s: %w", dst, err)
+	}
+	defer c.Close()
+	src := c.LocalAddr().(*net.UDPAddr).IP
+	r := Route{Dst: dst, Src: src}
+	if ifi, err := InterfaceForIP(src); err == nil {
+		r.Interface = ifi.Name
+	}
+	return r, nil
+}
+
+// InterfaceForIP returns the interface that has ip assigned.
+func InterfaceForIP(ip net.IP) (*net.Interface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ifaces {
+		addrs, err := ifaces[i].Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok && n.IP.Equal(ip) {
+				return &ifaces[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no interface has address %s", ip)
+}
+
+// vpnPrefixes are interface name prefixes used by common VPN and tunnel
+// drivers (WireGuard, OpenVPN, Tailscale, macOS utun, PPP).
+var vpnPrefixes = []string{"wg", "tun", "tap", "utun", "ppp", "tailscale", "zt", "ipsec", "nordlynx"}
+
+// IsVPNInterface reports whether name looks like a VPN or tunnel interface.
+func IsVPNInterface(name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range vpnPrefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// UpVPNInterfaces returns the names of VPN-like interfaces that are up.
+func UpVPNInterfaces() []string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, ifi := range ifaces {
+		if ifi.Flags&net.FlagUp != 0 && IsVPNInterface(ifi.Name) {
+			names = append(names, ifi.Name)
+		}
+	}
+	return names
+}
+
+// DefaultRoute returns the route the kernel uses for public destinations
+// of the same family as like.
+func DefaultRoute(like net.IP) (Route, error) {
+	if like.To4() == nil {
+		return RouteTo(net.ParseIP("2001:4860:4860::8888"))
+	}
+	return RouteTo(net.IPv4(8, 8, 8, 8))
+}
+
+// RouteCheck is how probes to one address would leave the host.
+type RouteCheck struct {
+	IP         string
+	Route      Route
+	ViaDefault bool // same interface as the default route
+	Err        error
+}
+
+// CheckRoutes looks up the route to each address and whether it shares the
+// default route's interface. No packets are sent.
+func CheckRoutes(ips []string) []RouteCheck {
+	checks := make([]RouteCheck, 0, len(ips))
+	for _, s := range ips {
+		c := RouteCheck{IP: s}
+		addr, zone := SplitZone(s)
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			c.Err = fmt.Errorf("invalid address %q", s)
+			checks = append(checks, c)
+			continue
+		}
+		c.Route, c.Err = routeTo(ip, zone)
+		if c.Err == nil {
+			if def, err := DefaultRoute(ip); err == nil {
+				c.ViaDefault = c.Route.Interface != "" && c.Route.Interface == def.Interface
+			}
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
@@ -0,0 +1,32 @@
+package netutil
+
+import "testing"
+
+func TestIsVPNInterface(t *testing.T) {
+	for name, want := range map[string]bool{
+		"wg0":        true,
+		"tun1":       true,
+		"utun3":      true,
+		"tailscale0": true,
+		"eth0":       false,
+		"en0":        false,
+		"lo":         false,
+	} {
+		if got := IsVPNInterface(name); got != want {
+			t.Errorf("IsVPNInterface(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestCheckRoutesLoopback(t *testing.T) {
+	checks := CheckRoutes([]string{"127.0.0.1", "not-an-ip"})
+	if len(checks) != 2 {
+		t.Fatalf("got %d checks, want 2", len(checks))
+	}
+	if c := checks[0]; c.Err != nil || !c.Route.Src.IsLoopback() || c.ViaDefault {
+		t.Fatalf("loopback check = %+v", c)
+	}
+	if checks[1].Err == nil {
+		t.Fatalf("expected error for invalid address")
+	}
+}
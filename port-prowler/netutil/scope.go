@@ -0,0 +1,77 @@
+package netutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// Scope is the set of networks a scan is authorized to touch.
+type Scope []*net.IPNet
+
+// ParseScope reads a --scope file: one CIDR block or address per line,
+// IPv4 or IPv6. Blank lines and anything after a # are ignored.
+func ParseScope(r io.Reader) (Scope, error) {
+	var s Scope
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		text, _, _ := strings.Cut(sc.Text(), "#")
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if !strings.Contains(text, "/") {
+			ip := net.ParseIP(text)
+			if ip == nil {
+				return nil, fmt.Errorf("line %d: %q is not an address or CIDR block", line, text)
+			}
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			s = append(s, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %q is not an address or CIDR block", line, text)
+		}
+		s = append(s, n)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(s) == 0 {
+		return nil, fmt.Errorf("no networks listed")
+	}
+	return s, nil
+}
+
+// LoadScope parses the scope file at path.
+func LoadScope(path string) (Scope, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s, err := ParseScope(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Contains reports whether ip lies in one of the scope's networks.
+func (s Scope) Contains(ip net.IP) bool {
+	for _, n := range s {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package netutil
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseScope(t *testing.T) {
+	s, err := ParseScope(strings.NewReader(`
+# engagement 2026-10
+10.20.0.0/16
+203.0.113.7      # jump host
+2001:db8:42::/48
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]bool{
+		"10.20.255.1":    true,
+		"10.21.0.1":      false,
+		"203.0.113.7":    true,
+		"203.0.113.8":    false,
+		"2001:db8:42::1": true,
+		"2001:db8:43::1": false,
+	} {
+		if got := s.Contains(net.ParseIP(addr)); got != want {
+			t.Errorf("Contains(%s) = %v, want %v", addr, got, want)
+		}
+	}
+
+	for _, bad := range []string{"10.0.0.0/33\n", "example.com\n", "# nothing\n"} {
+		if _, err := ParseScope(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseScope(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package netutil
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshServer is a minimal password-authenticated SSH server that only
+// serves direct-tcpip channels.
+func sshServer(t *testing.T) string {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "scan" && string(pass) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+	}
+	cfg.AddHostKey(signer)
+	return serve(t, func(c net.Conn) {
+		_, chans, reqs, err := ssh.NewServerConn(c, cfg)
+		if err != nil {
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+		for nc := range chans {
+			var req struct {
+				Host     string
+				Port     uint32
+				OrigHost string
+				OrigPort uint32
+			}
+			if nc.ChannelType() != "direct-tcpip" || ssh.Unmarshal(nc.ExtraData(), &req) != nil {
+				nc.Reject(ssh.Prohibited, "only direct-tcpip")
+				continue
+			}
+			up, err := net.Dial("tcp", net.JoinHostPort(req.Host, fmt.Sprint(req.Port)))
+			if err != nil {
+				nc.Reject(ssh.ConnectionFailed, "Connection refused")
+				continue
+			}
+			ch, creqs, err := nc.Accept()
+			if err != nil {
+				up.Close()
+				continue
+			}
+			go ssh.DiscardRequests(creqs)
+			go func() { io.Copy(up, ch); up.Close() }()
+			go func() { io.Copy(ch, up); ch.Close() }()
+		}
+	})
+}
+
+func TestSSHJump(t *testing.T) {
+	bastion := sshServer(t)
+	target := serve(t, func(c net.Conn) { io.Copy(c, c); c.Close() })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	jump, err := DialSSH(ctx, "ssh://scan:secret@"+bastion, SSHOptions{InsecureIgnoreHost: true, Timeout: 2 * time.Second})
+	if err != nil {
+		t.Fatalf("DialSSH: %v", err)
+	}
+	defer jump.Close()
+
+	conn, err := jump.DialContext(ctx, "tcp", target)
+	if err != nil {
+		t.Fatalf("dial through jump host: %v", err)
+	}
+	conn.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("echo through jump host = %q %v", buf, err)
+	}
+	conn.Close()
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed := ln.Addr().String()
+	ln.Close()
+	_, err = jump.DialContext(ctx, "tcp", closed)
+	var te *TargetError
+	if !errors.As(err, &te) || !te.Refused {
+		t.Fatalf("closed port err = %v, want refused TargetError", err)
+	}
+
+	_, err = DialSSH(ctx, "ssh://scan:wrong@"+bastion, SSHOptions{InsecureIgnoreHost: true, Timeout: 2 * time.Second})
+	var he *HopError
+	if !errors.As(err, &he) {
+		t.Fatalf("bad password err = %v, want HopError", err)
+	}
+}
@@ -0,0 +1,84 @@
+package netutil
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// SplitTarget splits a target as given on the command line into the host
+// to resolve and the port it names, 0 for none. "[2001:db8::1]:443",
+// "192.0.2.1:22" and "db.example:5432" name a port; "[2001:db8::1]",
+// "2001:db8::1" and "fe80::1%eth0" do not. Brackets, which only enclose
+// IPv6 addresses, are removed.
+func SplitTarget(s string) (host string, port uint16, err error) {
+	if strings.HasPrefix(s, "[") {
+		end := strings.IndexByte(s, ']')
+		if end < 0 {
+			return "", 0, errors.New("missing ]")
+		}
+		host, rest := s[1:end], s[end+1:]
+		if ip := ParseIP(host); ip == nil || ip.To4() != nil {
+			return "", 0, errors.New("brackets enclose IPv6 addresses only")
+		}
+		switch {
+		case rest == "":
+			return host, 0, nil
+		case strings.HasPrefix(rest, ":"):
+			port, err := parsePort(rest[1:])
+			return host, port, err
+		}
+		return "", 0, fmt.Errorf("unexpected %q after ]", rest)
+	}
+	if strings.Count(s, ":") != 1 {
+		return s, 0, nil // a name, an IPv4 address or a bare IPv6 address
+	}
+	host, p, _ := strings.Cut(s, ":")
+	if host == "" {
+		return "", 0, errors.New("missing host before :")
+	}
+	port, err = parsePort(p)
+	return host, port, err
+}
+
+func parsePort(s string) (uint16, error) {
+	n, err := strconv.ParseUint(s, 10, 16)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return uint16(n), nil
+}
+
+// ParseIP is net.ParseIP for addresses that may carry an IPv6 zone, as
+// link-local ones do ("fe80::1%eth0"); the zone is ignored.
+func ParseIP(s string) net.IP {
+	addr, _ := SplitZone(s)
+	return net.ParseIP(addr)
+}
+
+// SplitZone splits "fe80::1%eth0" into the address and the zone, which
+// is empty when there is none.
+func SplitZone(s string) (addr, zone string) {
+	addr, zone, _ = strings.Cut(s, "%")
+	return addr, zone
+}
+
+// zonedAddr validates an IPv6 address with a zone: the zone must be an
+// interface name or index of this machine.
+func zonedAddr(target string) (string, error) {
+	addr, zone := SplitZone(target)
+	ip := net.ParseIP(addr)
+	if ip == nil || ip.To4() != nil || zone == "" {
+		return "", fmt.Errorf("invalid address %q (zones follow IPv6 addresses only, e.g. fe80::1%%eth0)", target)
+	}
+	if n, err := strconv.Atoi(zone); err == nil {
+		if _, err := net.InterfaceByIndex(n); err != nil {
+			return "", fmt.Errorf("no interface with index %d", n)
+		}
+	} else if _, err := net.InterfaceByName(zone); err != nil {
+		return "", fmt.Errorf("no interface %s", zone)
+	}
+	return ip.String() + "%" + zone, nil
+}
@@ -0,0 +1,61 @@
+package netutil
+
+import (
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestSplitTarget(t *testing.T) {
+	cases := []struct {
+		in   string
+		host string
+		port uint16
+	}{
+		{"[2001:db8::1]:443", "2001:db8::1", 443},
+		{"[2001:db8::1]", "2001:db8::1", 0},
+		{"[fe80::1%eth0]:22", "fe80::1%eth0", 22},
+		{"2001:db8::1", "2001:db8::1", 0},
+		{"fe80::1%eth0", "fe80::1%eth0", 0},
+		{"192.0.2.1:22", "192.0.2.1", 22},
+		{"db.example:5432", "db.example", 5432},
+		{"db.example", "db.example", 0},
+	}
+	for _, c := range cases {
+		host, port, err := SplitTarget(c.in)
+		if err != nil || host != c.host || port != c.port {
+			t.Errorf("SplitTarget(%q) = %q, %d, %v; want %q, %d", c.in, host, port, err, c.host, c.port)
+		}
+	}
+	for _, bad := range []string{"[2001:db8::1", "[192.0.2.1]:80", "[2001:db8::1]x", "[2001:db8::1]:0", "host:http", ":22", "host:70000"} {
+		if _, _, err := SplitTarget(bad); err == nil {
+			t.Errorf("SplitTarget(%q): want an error", bad)
+		}
+	}
+}
+
+func TestResolveTarget_Zone(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	if err != nil || len(ifaces) == 0 {
+		t.Skip("no interfaces")
+	}
+	name := ifaces[0].Name
+	got, err := ResolveTarget("FE80::1%"+name, ResolveOptions{DualStack: true})
+	if err != nil || len(got) != 1 || got[0] != "fe80::1%"+name {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	if _, err := ResolveTarget("fe80::1%"+strconv.Itoa(ifaces[0].Index), ResolveOptions{DualStack: true}); err != nil {
+		t.Errorf("interface index zone: %v", err)
+	}
+	for _, bad := range []string{"fe80::1%no-such-if0", "192.0.2.1%" + name, "fe80::1%"} {
+		if _, err := ResolveTarget(bad, ResolveOptions{DualStack: true}); err == nil {
+			t.Errorf("ResolveTarget(%q): want an error", bad)
+		}
+	}
+	if _, err := ResolveTarget("fe80::1%"+name, ResolveOptions{}); err == nil {
+		t.Error("want an error without DualStack")
+	}
+	if ip := ParseIP("fe80::1%" + name); ip == nil || !ip.Equal(net.ParseIP("fe80::1")) {
+		t.Errorf("ParseIP kept the zone: %v", ip)
+	}
+}
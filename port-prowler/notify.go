@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"portprowler/alert"
+	"portprowler/detector"
+	"portprowler/history"
+	"portprowler/logging"
+	"portprowler/notify"
+	"portprowler/output"
+	"portprowler/report"
+)
+
+// smtpPasswordEnv holds the --smtp-user password, which is kept out of
+// flags, profiles and the process list.
+const smtpPasswordEnv = envPrefix + "SMTP_PASSWORD"
+
+// notify emails the scan to the --notify-email recipients: the summary,
+// changes and alerts as text and the JSON report attached. A failed mail
+// is a warning; the scan itself succeeded.
+func (f *scanFlags) notify(rep report.ScanReport, summary string, changes []report.Change, alerts []alert.Alert) {
+	if len(f.notifyEmail) == 0 {
+		return
+	}
+	msg, err := notifyMessage(rep, summary, changes, alerts)
+	if err == nil {
+		err = notify.Email{
+			Server:   f.smtpServer,
+			From:     f.smtpFrom,
+			To:       f.notifyEmail,
+			User:     f.smtpUser,
+			Password: os.Getenv(smtpPasswordEnv),
+		}.Send(msg)
+	}
+	if err != nil {
+		logging.Warnf("--notify-email: %v", err)
+		return
+	}
+	logging.Infof("Emailed the report to %s", strings.Join(f.notifyEmail, ", "))
+}
+
+// notifyMessage builds the message of a scan; changes and alerts are
+// those since the previous watch scan, if any.
+func notifyMessage(rep report.ScanReport, summary string, changes []report.Change, alerts []alert.Alert) (notify.Message, error) {
+	var body bytes.Buffer
+	for _, a := range alerts {
+		fmt.Fprintf(&body, "ALERT %s: %s\n", a.Rule, a.Reason)
+	}
+	if len(alerts) > 0 {
+		body.WriteString("\n")
+	}
+	if len(changes) > 0 {
+		body.WriteString("Changes since the previous scan:\n")
+		if err := writeChanges(changes, "table", &body); err != nil {
+			return notify.Message{}, err
+		}
+		body.WriteString("\n")
+	}
+	if err := output.Render("table", rep, summary, &body); err != nil {
+		return notify.Message{}, err
+	}
+	var attachment bytes.Buffer
+	if err := output.Render("json", rep, summary, &attachment); err != nil {
+		return notify.Message{}, err
+	}
+
+	subject := "portprowler: scan of " + notifyTargets(rep)
+	switch {
+	case len(alerts) > 0:
+		subject += fmt.Sprintf(" raised %d alert(s)", len(alerts))
+	case len(changes) > 0:
+		subject += fmt.Sprintf(": %d change(s)", len(changes))
+	}
+	return notify.Message{
+		Subject:        subject,
+		Body:           body.String(),
+		Attachment:     attachment.Bytes(),
+		AttachmentName: "report.json",
+	}, nil
+}
+
+// notifyTargets names the scanned hosts for a subject line, shortened
+// when there are many.
+func notifyTargets(rep report.ScanReport) string {
+	var names []string
+	for _, h := range rep.Hosts {
+		name := h.Target
+		if name == "" {
+			name = h.IP
+		}
+		names = append(names, output.Printable(name))
+	}
+	switch {
+	case len(names) == 0:
+		return "no hosts"
+	case len(names) > 3:
+		return fmt.Sprintf("%s and %d more", strings.Join(names[:3], ", "), len(names)-3)
+	}
+	return strings.Join(names, ", ")
+}
+
+// incidentKeyEnv names the variable holding the --incident service's key.
+func incidentKeyEnv(service string) string {
+	return envPrefix + strings.ToUpper(service) + "_KEY"
+}
+
+// page opens an --incident for every open port rated --incident-severity
+// or above and for every change behind an alert. Incidents are keyed by
+// host and port, so the service folds repeats from later scans into the
+// incident already open. Failures are warnings.
+func (f *scanFlags) page(ctx context.Context, rep report.ScanReport, alerts []alert.Alert) {
+	if f.incident == "" {
+		return
+	}
+	pager, err := notify.NewPager(f.incident, os.Getenv(incidentKeyEnv(f.incident)))
+	if err != nil {
+		logging.Warnf("--incident: %v", err)
+		return
+	}
+	incs := incidents(rep, alerts, f.incidentMin)
+	opened := 0
+	for _, inc := range incs {
+		if err := pager.Open(ctx, inc); err != nil {
+			logging.Warnf("--incident %s: %s: %v", f.incident, inc.Key, err)
+			continue
+		}
+		opened++
+	}
+	if len(incs) > 0 {
+		logging.Infof("Opened %d of %d incident(s) in %s", opened, len(incs), f.incident)
+	}
+}
+
+// incidents lists what page reports, one incident per host and port:
+// exposures of at least severity min first, then alerted changes.
+func incidents(rep report.ScanReport, alerts []alert.Alert, min string) []notify.Incident {
+	type key struct {
+		target string
+		port   uint16
+		proto  string
+	}
+	rated := make(map[key]string)
+	seen := make(map[string]bool)
+	var out []notify.Incident
+	for _, h := range rep.Hosts {
+		name := h.Target
+		if name == "" {
+			name = h.IP
+		}
+		for _, r := range h.Results {
+			rated[key{name, r.Port, r.Proto}] = r.Severity
+			if r.State != "open" || detector.SeverityRank(r.Severity) < detector.SeverityRank(min) {
+				continue
+			}
+			inc := notify.Incident{
+				Key:      notify.IncidentKey(name, r.Port, r.Proto),
+				Summary:  fmt.Sprintf("%s exposure: %s %d/%s open", r.Severity, name, r.Port, r.Proto),
+				Severity: r.Severity,
+				Source:   name,
+				Details: map[string]string{
+					"ip":      h.IP,
+					"context": rep.Meta.Context,
+				},
+			}
+			if r.Service != "" {
+				inc.Summary += " (" + r.Service + ")"
+				inc.Details["service"] = r.Service
+			}
+			if r.ServiceBanner != "" {
+				inc.Details["banner"] = output.Printable(r.ServiceBanner)
+			}
+			seen[inc.Key] = true
+			out = append(out, inc)
+		}
+	}
+	for _, a := range alerts {
+		for _, c := range a.Changes {
+			k := notify.IncidentKey(c.Target, c.Port, c.Proto)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			// A policy violation pages as high unless the port itself
+			// is rated critical.
+			severity := "high"
+			if rated[key{c.Target, c.Port, c.Proto}] == "critical" {
+				severity = "critical"
+			}
+			out = append(out, notify.Incident{
+				Key:      k,
+				Summary:  fmt.Sprintf("alert %s: %s", a.Rule, output.Printable(c.String())),
+				Severity: severity,
+				Source:   c.Target,
+				Details: map[string]string{
+					"rule":   a.Rule.String(),
+					"reason": a.Reason,
+					"ip":     c.IP,
+				},
+			})
+		}
+	}
+	return out
+}
+
+// issueTokenEnv names the variable holding the --issue tracker's token.
+func issueTokenEnv(tracker string) string {
+	return envPrefix + strings.ToUpper(tracker) + "_TOKEN"
+}
+
+// tracker returns the --issue tracker.
+func (f *scanFlags) tracker() notify.Tracker {
+	token := os.Getenv(issueTokenEnv(f.issue))
+	if f.issue == notify.TrackerJira {
+		return notify.Jira{URL: f.issueURL, Project: f.issueProject, User: f.issueUser, Token: token}
+	}
+	return notify.GitHub{Repo: f.issueProject, Token: token, URL: f.issueURL}
+}
+
+// fileIssues files an --issue for every open port rated --issue-severity
+// or above that has none yet. Filed tickets are recorded in the scan
+// history's issue log, so a port that stays exposed is filed once.
+// Failures are warnings.
+func (f *scanFlags) fileIssues(ctx context.Context, rep report.ScanReport) {
+	if f.issue == "" {
+		return
+	}
+	tmpl := notify.DefaultIssueTemplate()
+	if f.issueTemplate != "" {
+		t, err := notify.LoadIssueTemplate(f.issueTemplate)
+		if err != nil {
+			logging.Warnf("--issue: %v", err)
+			return
+		}
+		tmpl = t
+	}
+	store, err := history.Default()
+	if err != nil {
+		logging.Warnf("--issue: failed to locate scan history: %v", err)
+		return
+	}
+	issues, err := store.Issues()
+	if err != nil {
+		logging.Warnf("--issue: %v", err)
+		return
+	}
+	tracker := f.tracker()
+	for _, h := range rep.Hosts {
+		name := h.Target
+		if name == "" {
+			name = h.IP
+		}
+		for _, r := range h.Results {
+			if r.State != "open" || detector.SeverityRank(r.Severity) < detector.SeverityRank(f.issueMin) {
+				continue
+			}
+			key := notify.IncidentKey(name, r.Port, r.Proto)
+			if is, ok := issues.Find(f.issue, f.issueProject, key); ok {
+				if f.verbose {
+					logging.Verbosef("%s %d/%s already has issue %s", name, r.Port, r.Proto, is.ID)
+				}
+				continue
+			}
+			ticket, err := tmpl.Ticket(notify.NewFinding(name, r, rep.Meta.Context))
+			if err != nil {
+				logging.Warnf("--issue: %s: %v", key, err)
+				continue
+			}
+			id, url, err := tracker.File(ctx, ticket)
+			if err != nil {
+				logging.Warnf("--issue %s: %s: %v", f.issue, key, err)
+				continue
+			}
+			logging.Infof("Filed issue %s for %s %d/%s %s", id, name, r.Port, r.Proto, url)
+			is := history.Issue{Tracker: f.issue, Project: f.issueProject, Finding: key, ID: id, URL: url, Filed: time.Now().UTC()}
+			if err := issues.Add(is); err != nil {
+				logging.Warnf("--issue: failed to record issue %s, it may be filed again: %v", id, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Package notify delivers scan summaries to people and incident tools
+// after a scan, for scheduled scans nobody watches live.
+package notify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Message is what a notification reports.
+type Message struct {
+	Subject        string
+	Body           string // plain text: the summary and what changed
+	Attachment     []byte // e.g. the JSON report; optional
+	AttachmentName string
+}
+
+// Email sends messages through an SMTP server. Port 465 speaks TLS from
+// the start; on other ports STARTTLS is used when the server offers it.
+type Email struct {
+	Server string // host:port
+	From   string
+	To     []string
+	// User and Password, when User is set, authenticate with PLAIN,
+	// which net/smtp only allows over TLS or to localhost.
+	User, Password string
+	// Timeout bounds the whole exchange; zero uses 30s.
+	Timeout time.Duration
+}
+
+// Send delivers msg to every recipient.
+func (e Email) Send(msg Message) error {
+	if e.Server == "" || e.From == "" || len(e.To) == 0 {
+		return errors.New("email: server, sender and recipients are required")
+	}
+	host, port, err := net.SplitHostPort(e.Server)
+	if err != nil {
+		return fmt.Errorf("email: server %q: %v", e.Server, err)
+	}
+	data, err := e.build(msg, time.Now())
+	if err != nil {
+		return err
+	}
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	if port == "465" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", e.Server, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", e.Server)
+	}
+	if err != nil {
+		return fmt.Errorf("email: %v", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("email: %v", err)
+	}
+	defer c.Close()
+	if err := e.deliver(c, host, data); err != nil {
+		return fmt.Errorf("email: %v", err)
+	}
+	return c.Quit()
+}
+
+func (e Email) deliver(c *smtp.Client, host string, data []byte) error {
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if e.User != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.User, e.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.From); err != nil {
+		return err
+	}
+	for _, to := range e.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("recipient %s: %v", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// build renders msg as a MIME message: a quoted-printable text part and,
+// with an attachment, a base64 part after it.
+func (e Email) build(msg Message, now time.Time) ([]byte, error) {
+	for _, v := range append([]string{e.From, msg.AttachmentName}, e.To...) {
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, errors.New("email: line break in an address or file name")
+		}
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	boundary := ""
+	if msg.Attachment != nil {
+		var raw [12]byte
+		if _, err := rand.Read(raw[:]); err != nil {
+			return nil, err
+		}
+		boundary = "portprowler-" + hex.EncodeToString(raw[:])
+		fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+		fmt.Fprintf(&b, "--%s\r\n", boundary)
+	}
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	b.WriteString("\r\n")
+	if msg.Attachment == nil {
+		return b.Bytes(), nil
+	}
+	name := msg.AttachmentName
+	if name == "" {
+		name = "report"
+	}
+	fmt.Fprintf(&b, "--%s\r\n", boundary)
+	fmt.Fprintf(&b, "Content-Type: %s\r\n", mimeType(name))
+	b.WriteString("Content-Transfer-Encoding: base64\r\n")
+	fmt.Fprintf(&b, "Content-Disposition: %s\r\n\r\n", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	enc := base64.StdEncoding.EncodeToString(msg.Attachment)
+	for len(enc) > 76 {
+		b.WriteString(enc[:76] + "\r\n")
+		enc = enc[76:]
+	}
+	b.WriteString(enc + "\r\n")
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes(), nil
+}
+
+// mimeType picks the attachment's content type by extension.
+func mimeType(name string) string {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		if t := mime.TypeByExtension(name[i:]); t != "" {
+			return t
+		}
+	}
+	return "application/octet-stream"
+}
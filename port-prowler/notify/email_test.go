@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP accepts one message without TLS or authentication and returns
+// the recipients and the message data.
+func fakeSMTP(t *testing.T) (addr string, got <-chan []string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	out := make(chan []string, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		r := bufio.NewReader(c)
+		reply := func(s string) { io.WriteString(c, s+"\r\n") }
+		reply("220 fake ESMTP")
+		var rcpts []string
+		var data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				rcpts = append(rcpts, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+				reply("250 ok")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				out <- append(rcpts, data.String())
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return l.Addr().String(), out
+}
+
+func TestEmail_Send(t *testing.T) {
+	addr, got := fakeSMTP(t)
+	e := Email{Server: addr, From: "scanner@example.com", To: []string{"ops@example.com", "sec@example.com"}, Timeout: 5 * time.Second}
+	err := e.Send(Message{
+		Subject:        "portprowler: 2 change(s) on db.example — ünicode",
+		Body:           "opened db.example 6379/tcp: closed -> open\n",
+		Attachment:     []byte(`{"hosts":[]}`),
+		AttachmentName: "report.json",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := <-got
+	if len(res) != 3 || res[0] != "ops@example.com" || res[1] != "sec@example.com" {
+		t.Fatalf("recipients %q", res[:len(res)-1])
+	}
+	m, err := mail.ReadMessage(strings.NewReader(res[2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subj, _ := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject")); !strings.Contains(subj, "ünicode") {
+		t.Errorf("subject %q", subj)
+	}
+	_, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	text, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(text); !bytes.Contains(body, []byte("6379/tcp: closed -> open")) {
+		t.Errorf("body %q", body)
+	}
+	att, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if att.FileName() != "report.json" {
+		t.Errorf("attachment name %q", att.FileName())
+	}
+}
+
+func TestEmail_Build(t *testing.T) {
+	e := Email{From: "a@example.com", To: []string{"b@example.com\r\nBcc: x@example.com"}}
+	if _, err := e.build(Message{Subject: "x"}, time.Now()); err == nil {
+		t.Error("header injection through a recipient was not refused")
+	}
+	e.To = []string{"b@example.com"}
+	data, err := e.build(Message{Subject: "plain", Body: "no attachment"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("multipart")) {
+		t.Error("a message without attachment should be a single text part")
+	}
+}
@@ -0,0 +1,189 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Incident management services NewPager knows.
+const (
+	ServicePagerDuty = "pagerduty"
+	ServiceOpsgenie  = "opsgenie"
+)
+
+// Default API endpoints.
+const (
+	PagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	OpsgenieURL  = "https://api.opsgenie.com/v2/alerts"
+)
+
+// Incident is one finding to page about.
+type Incident struct {
+	// Key deduplicates: the services fold incidents with the same key
+	// into the one already open, so a port still exposed on the next
+	// scan does not page again.
+	Key      string
+	Summary  string
+	Severity string // detector severity, info to critical
+	Source   string // the host concerned
+	Details  map[string]string
+}
+
+// IncidentKey returns the dedup key of a finding on target's port; port
+// 0 stands for the host itself.
+func IncidentKey(target string, port uint16, proto string) string {
+	if port == 0 {
+		return "portprowler:" + target
+	}
+	return fmt.Sprintf("portprowler:%s:%d/%s", target, port, proto)
+}
+
+// Pager opens incidents in an incident management service.
+type Pager interface {
+	Open(ctx context.Context, inc Incident) error
+}
+
+// NewPager returns the pager of service (ServicePagerDuty or
+// ServiceOpsgenie) authenticated with key.
+func NewPager(service, key string) (Pager, error) {
+	switch service {
+	case ServicePagerDuty:
+		return PagerDuty{RoutingKey: key}, nil
+	case ServiceOpsgenie:
+		return Opsgenie{APIKey: key}, nil
+	}
+	return nil, fmt.Errorf("unknown incident service %q (pagerduty or opsgenie)", service)
+}
+
+// PagerDuty triggers events through the PagerDuty Events API v2.
+type PagerDuty struct {
+	RoutingKey string // integration key of the service
+	URL        string // PagerDutyURL when empty
+	Client     *http.Client
+}
+
+// Open triggers an event; PagerDuty groups it into the open incident
+// with the same dedup key, if any.
+func (p PagerDuty) Open(ctx context.Context, inc Incident) error {
+	event := map[string]any{
+		"routing_key":  p.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    inc.Key,
+		"payload": map[string]any{
+			"summary":        inc.Summary,
+			"source":         inc.Source,
+			"severity":       pagerDutySeverity(inc.Severity),
+			"component":      "portprowler",
+			"custom_details": inc.Details,
+		},
+	}
+	return post(ctx, p.Client, or(p.URL, PagerDutyURL), nil, event, nil)
+}
+
+// pagerDutySeverity maps a detector severity to the four PagerDuty knows.
+func pagerDutySeverity(s string) string {
+	switch s {
+	case "critical":
+		return "critical"
+	case "high":
+		return "error"
+	case "medium":
+		return "warning"
+	}
+	return "info"
+}
+
+// Opsgenie creates alerts through the Opsgenie Alert API.
+type Opsgenie struct {
+	APIKey string // API key of an API integration
+	URL    string // OpsgenieURL when empty; the EU instance is api.eu.opsgenie.com
+	Client *http.Client
+}
+
+// Open creates an alert; Opsgenie counts it as a repeat of the open alert
+// with the same alias, if any.
+func (o Opsgenie) Open(ctx context.Context, inc Incident) error {
+	alert := map[string]any{
+		"message":     truncate(inc.Summary, 130),
+		"alias":       inc.Key,
+		"description": inc.Summary,
+		"source":      inc.Source,
+		"priority":    opsgeniePriority(inc.Severity),
+		"details":     inc.Details,
+		"tags":        []string{"portprowler"},
+	}
+	header := http.Header{"Authorization": {"GenieKey " + o.APIKey}}
+	return post(ctx, o.Client, or(o.URL, OpsgenieURL), header, alert, nil)
+}
+
+// opsgeniePriority maps a detector severity to P1 (critical) to P5.
+func opsgeniePriority(s string) string {
+	switch s {
+	case "critical":
+		return "P1"
+	case "high":
+		return "P2"
+	case "medium":
+		return "P3"
+	case "low":
+		return "P4"
+	}
+	return "P5"
+}
+
+// post sends v as JSON to url and fails on any status but 2xx. The
+// response is decoded into out unless it is nil.
+func post(ctx context.Context, client *http.Client, url string, header http.Header, v, out any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s %s", url, resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out); err != nil {
+		return fmt.Errorf("%s: %v", url, err)
+	}
+	return nil
+}
+
+func or(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// capture serves status and records the last request's JSON body and
+// Authorization header.
+func capture(t *testing.T, status int) (url string, body map[string]any, auth *string) {
+	t.Helper()
+	body = make(map[string]any)
+	auth = new(string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, body, auth
+}
+
+var testIncident = Incident{
+	Key:      IncidentKey("db.example", 6379, "tcp"),
+	Summary:  "critical exposure: db.example 6379/tcp open (redis)",
+	Severity: "critical",
+	Source:   "db.example",
+	Details:  map[string]string{"ip": "192.0.2.7"},
+}
+
+func TestPagerDuty_Open(t *testing.T) {
+	url, body, _ := capture(t, http.StatusAccepted)
+	if err := (PagerDuty{RoutingKey: "rk", URL: url}).Open(context.Background(), testIncident); err != nil {
+		t.Fatal(err)
+	}
+	if body["routing_key"] != "rk" || body["event_action"] != "trigger" || body["dedup_key"] != "portprowler:db.example:6379/tcp" {
+		t.Errorf("event %v", body)
+	}
+	payload, _ := body["payload"].(map[string]any)
+	if payload["severity"] != "critical" || payload["source"] != "db.example" {
+		t.Errorf("payload %v", payload)
+	}
+}
+
+func TestOpsgenie_Open(t *testing.T) {
+	url, body, auth := capture(t, http.StatusAccepted)
+	inc := testIncident
+	inc.Severity = "high"
+	if err := (Opsgenie{APIKey: "k", URL: url}).Open(context.Background(), inc); err != nil {
+		t.Fatal(err)
+	}
+	if *auth != "GenieKey k" || body["alias"] != inc.Key || body["priority"] != "P2" {
+		t.Errorf("alert %v (auth %q)", body, *auth)
+	}
+
+	url, _, _ = capture(t, http.StatusUnauthorized)
+	if err := (Opsgenie{APIKey: "bad", URL: url}).Open(context.Background(), inc); err == nil {
+		t.Error("a 401 was not reported")
+	}
+}
@@ -0,0 +1,207 @@
+package notify
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"portprowler/port"
+)
+
+// Issue trackers a ticket can be filed in.
+const (
+	TrackerGitHub = "github"
+	TrackerJira   = "jira"
+)
+
+// GitHubURL is the API of github.com; GitHub Enterprise serves it under
+// https://<host>/api/v3.
+const GitHubURL = "https://api.github.com"
+
+// Finding is an exposed port a ticket is filed for; issue templates are
+// executed with it.
+type Finding struct {
+	Target   string
+	IP       string
+	Port     uint16
+	Proto    string
+	Service  string
+	Severity string
+	Context  string    // scan context the port was rated for
+	Evidence string    // what identified the service: banner, certificate, page title
+	Seen     time.Time // when the probe completed
+}
+
+// NewFinding describes the open result r of target, rated for context.
+func NewFinding(target string, r port.PortResult, context string) Finding {
+	var evidence []string
+	if r.ServiceBanner != "" {
+		evidence = append(evidence, "banner: "+r.ServiceBanner)
+	}
+	if r.TLS != nil && r.TLS.Subject != "" {
+		evidence = append(evidence, fmt.Sprintf("certificate: %s (issuer %s)", r.TLS.Subject, r.TLS.Issuer))
+	}
+	if r.HTTP != nil {
+		line := fmt.Sprintf("http: %d", r.HTTP.StatusCode)
+		if r.HTTP.Server != "" {
+			line += " server " + r.HTTP.Server
+		}
+		if r.HTTP.Title != "" {
+			line += fmt.Sprintf(" title %q", r.HTTP.Title)
+		}
+		evidence = append(evidence, line)
+	}
+	return Finding{
+		Target:   target,
+		IP:       r.IP,
+		Port:     r.Port,
+		Proto:    r.Proto,
+		Service:  r.Service,
+		Severity: r.Severity,
+		Context:  context,
+		Evidence: strings.Join(evidence, "\n"),
+		Seen:     r.Timestamp,
+	}
+}
+
+// Ticket is the title and body of an issue to file.
+type Ticket struct {
+	Title  string
+	Body   string
+	Labels []string
+}
+
+// defaultIssueTemplate is used unless a template file is given.
+const defaultIssueTemplate = `{{define "title"}}{{.Severity}} exposure: {{.Target}} {{.Port}}/{{.Proto}}{{with .Service}} ({{.}}){{end}}{{end}}
+{{- define "body"}}portprowler found port {{.Port}}/{{.Proto}} open on {{.Target}} ({{.IP}}), rated {{.Severity}} for a scan from the {{.Context}} network.
+
+Service: {{or .Service "unknown"}}
+Seen: {{.Seen.UTC.Format "2006-01-02 15:04:05 UTC"}}
+{{with .Evidence}}
+Evidence:
+{{.}}
+{{end}}{{end}}`
+
+// IssueTemplate renders tickets from findings. Its text defines a
+// "title" and a "body" template.
+type IssueTemplate struct {
+	t *template.Template
+}
+
+// DefaultIssueTemplate returns the built-in template.
+func DefaultIssueTemplate() *IssueTemplate {
+	return &IssueTemplate{template.Must(template.New("issue").Parse(defaultIssueTemplate))}
+}
+
+// LoadIssueTemplate parses the issue template in path.
+func LoadIssueTemplate(path string) (*IssueTemplate, error) {
+	t, err := template.ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("issue template %s: %v", path, err)
+	}
+	for _, name := range []string{"title", "body"} {
+		if t.Lookup(name) == nil {
+			return nil, fmt.Errorf("issue template %s: no {{define %q}}", path, name)
+		}
+	}
+	return &IssueTemplate{t}, nil
+}
+
+// Ticket renders the ticket of f, labelled portprowler and its severity.
+func (it *IssueTemplate) Ticket(f Finding) (Ticket, error) {
+	var title, body strings.Builder
+	if err := it.t.ExecuteTemplate(&title, "title", f); err != nil {
+		return Ticket{}, err
+	}
+	if err := it.t.ExecuteTemplate(&body, "body", f); err != nil {
+		return Ticket{}, err
+	}
+	labels := []string{"portprowler"}
+	if f.Severity != "" {
+		labels = append(labels, f.Severity)
+	}
+	return Ticket{
+		Title:  strings.Join(strings.Fields(title.String()), " "),
+		Body:   strings.TrimSpace(body.String()) + "\n",
+		Labels: labels,
+	}, nil
+}
+
+// Tracker files tickets in an issue tracker.
+type Tracker interface {
+	// File creates an issue and returns its id (e.g. "42" or "SEC-17")
+	// and web URL.
+	File(ctx context.Context, t Ticket) (id, url string, err error)
+}
+
+// GitHub files issues in a repository through the GitHub REST API.
+type GitHub struct {
+	Repo   string // owner/name
+	Token  string // token allowed to create issues
+	URL    string // GitHubURL when empty
+	Client *http.Client
+}
+
+// File creates an issue.
+func (g GitHub) File(ctx context.Context, t Ticket) (string, string, error) {
+	var created struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	header := http.Header{
+		"Authorization": {"Bearer " + g.Token},
+		"Accept":        {"application/vnd.github+json"},
+	}
+	url := strings.TrimSuffix(or(g.URL, GitHubURL), "/") + "/repos/" + g.Repo + "/issues"
+	issue := map[string]any{"title": t.Title, "body": t.Body, "labels": t.Labels}
+	if err := post(ctx, g.Client, url, header, issue, &created); err != nil {
+		return "", "", err
+	}
+	if created.Number == 0 {
+		return "", "", fmt.Errorf("%s: no issue number in the response", url)
+	}
+	return fmt.Sprint(created.Number), created.HTMLURL, nil
+}
+
+// Jira files issues in a project through the Jira REST API v2.
+type Jira struct {
+	URL     string // site, e.g. https://example.atlassian.net
+	Project string // project key, e.g. SEC
+	// User and Token authenticate: an account email and API token on
+	// Jira Cloud, or, with User empty, a personal access token on Jira
+	// Data Center.
+	User, Token string
+	IssueType   string // "Bug" when empty
+	Client      *http.Client
+}
+
+// File creates an issue.
+func (j Jira) File(ctx context.Context, t Ticket) (string, string, error) {
+	var created struct {
+		Key string `json:"key"`
+	}
+	auth := "Bearer " + j.Token
+	if j.User != "" {
+		auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(j.User+":"+j.Token))
+	}
+	header := http.Header{"Authorization": {auth}}
+	site := strings.TrimSuffix(j.URL, "/")
+	issue := map[string]any{"fields": map[string]any{
+		"project":     map[string]string{"key": j.Project},
+		"summary":     t.Title,
+		"description": t.Body,
+		"issuetype":   map[string]string{"name": or(j.IssueType, "Bug")},
+		"labels":      t.Labels,
+	}}
+	if err := post(ctx, j.Client, site+"/rest/api/2/issue", header, issue, &created); err != nil {
+		return "", "", err
+	}
+	if created.Key == "" {
+		return "", "", fmt.Errorf("%s: no issue key in the response", site)
+	}
+	return created.Key, site + "/browse/" + created.Key, nil
+}
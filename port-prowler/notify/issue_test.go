@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"portprowler/port"
+)
+
+func TestIssueTemplate(t *testing.T) {
+	f := NewFinding("db.example", port.PortResult{
+		IP: "192.0.2.7", Port: 6379, Proto: "tcp", State: "open",
+		Service: "redis", ServiceBanner: "-NOAUTH Authentication required.", Severity: "critical",
+		Timestamp: time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC),
+	}, "external")
+	tk, err := DefaultIssueTemplate().Ticket(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tk.Title != "critical exposure: db.example 6379/tcp (redis)" {
+		t.Errorf("title %q", tk.Title)
+	}
+	for _, want := range []string{"192.0.2.7", "external network", "2026-10-01 08:00:00 UTC", "banner: -NOAUTH"} {
+		if !strings.Contains(tk.Body, want) {
+			t.Errorf("body lacks %q:\n%s", want, tk.Body)
+		}
+	}
+	if len(tk.Labels) != 2 || tk.Labels[1] != "critical" {
+		t.Errorf("labels %q", tk.Labels)
+	}
+
+	path := filepath.Join(t.TempDir(), "issue.tmpl")
+	os.WriteFile(path, []byte(`{{define "title"}}[{{.Severity}}] {{.Target}}:{{.Port}}{{end}}`), 0o644)
+	if _, err := LoadIssueTemplate(path); err == nil {
+		t.Error("a template without a body was accepted")
+	}
+	os.WriteFile(path, []byte(`{{define "title"}}[{{.Severity}}] {{.Target}}:{{.Port}}{{end}}{{define "body"}}{{.Evidence}}{{end}}`), 0o644)
+	it, err := LoadIssueTemplate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tk, err = it.Ticket(f); err != nil || tk.Title != "[critical] db.example:6379" {
+		t.Errorf("custom template: %+v, %v", tk, err)
+	}
+}
+
+func TestGitHub_File(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/infra/issues" || r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "bad request "+r.URL.Path, http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"number": 42, "html_url": "https://github.example/acme/infra/issues/42"}`))
+	}))
+	defer srv.Close()
+	id, url, err := GitHub{Repo: "acme/infra", Token: "tok", URL: srv.URL}.File(context.Background(), Ticket{Title: "t", Body: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "42" || url != "https://github.example/acme/infra/issues/42" {
+		t.Errorf("filed %s %s", id, url)
+	}
+}
+
+func TestJira_File(t *testing.T) {
+	var fields map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "me@example.com" || pass != "tok" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var body struct{ Fields map[string]any }
+		json.NewDecoder(r.Body).Decode(&body)
+		fields = body.Fields
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "10001", "key": "SEC-17"}`))
+	}))
+	defer srv.Close()
+	id, url, err := Jira{URL: srv.URL + "/", Project: "SEC", User: "me@example.com", Token: "tok"}.File(context.Background(), Ticket{Title: "t", Body: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "SEC-17" || url != srv.URL+"/browse/SEC-17" {
+		t.Errorf("filed %s %s", id, url)
+	}
+	if project, _ := fields["project"].(map[string]any); project["key"] != "SEC" || fields["summary"] != "t" {
+		t.Errorf("fields %v", fields)
+	}
+}
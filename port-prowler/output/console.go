@@ -4,9 +4,15 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strconv"
+	"strings"
 	"text/tabwriter"
+	"unicode"
+	"unicode/utf8"
 
+	"portprowler/i18n"
 	"portprowler/port"
+	"portprowler/report"
 )
 
 // PrintTableFromSlice prints a table from an in-memory slice of results.
@@ -31,18 +37,49 @@ func PrintTableFromSlice(results []port.PortResult, w io.Writer) {
 
 	tw := tabwriter.NewWriter(w, 0, 2, 2, ' ', 0)
 	// Removed CONFIDENCE column
-	fmt.Fprintln(tw, "TARGET\tIP\tPORT/PROTO\tSTATE\tSERVICE\tINFO")
+	fmt.Fprintf(tw, "%s\tIP\t%s\t%s\t%s\t%s\n", i18n.T("TARGET"), i18n.T("PORT/PROTO"), i18n.T("STATE"), i18n.T("SERVICE"), i18n.T("INFO"))
 	for _, r := range results {
 		info := r.Error
 		if info == "" {
 			info = fmt.Sprintf("rtt=%dms", r.RTTMillis)
 		}
+		if t := r.TLS; t != nil {
+			switch {
+			case t.Version == "":
+				info += " tls-verify-failed"
+			case t.Subject != "":
+				info += fmt.Sprintf(" %s cn=%s", t.Version, t.Subject)
+			default:
+				info += " " + t.Version
+			}
+		}
+		if r.Severity != "" && r.Severity != "info" {
+			info += " severity=" + r.Severity
+		}
+		if h := r.HTTP; h != nil {
+			info += fmt.Sprintf(" http=%d", h.StatusCode)
+			if h.Title != "" {
+				info += fmt.Sprintf(" title=%q", h.Title)
+			}
+		}
+		if d := r.DNS; d != nil {
+			info += " dns=" + d.RCode
+			if d.Recursion {
+				info += " recursion"
+			}
+			for _, a := range d.Answers {
+				info += fmt.Sprintf(" answer=%q", a)
+			}
+		}
+		for _, n := range r.Notes {
+			info += fmt.Sprintf(" note=%q", n)
+		}
 		target := r.Target
 		if target == "" {
 			target = r.IP
 		}
-		fmt.Fprintf(tw, "%s\t%s\t%d/%s\t%s\t%s\t%s\n",
-			target, r.IP, r.Port, r.Proto, r.State, r.Service, info)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			Printable(target), r.IP, PortProto(r), r.State, Printable(ServiceName(r)), Printable(info))
 	}
 	_ = tw.Flush()
 }
@@ -56,3 +93,182 @@ func PrintTable(results <-chan port.PortResult, w io.Writer) {
 	}
 	PrintTableFromSlice(rs, w)
 }
+
+// PortProto renders the PORT/PROTO column, e.g. "80/tcp". Host-level
+// results such as ping have no port and render as "-/ping".
+func PortProto(r port.PortResult) string {
+	if r.Proto == string(port.ScanPing) {
+		return "-/" + r.Proto
+	}
+	return fmt.Sprintf("%d/%s", r.Port, r.Proto)
+}
+
+// ServiceName renders the SERVICE column: the service, marked with
+// port.GuessSuffix when it was only guessed from the port, e.g. "mysql?".
+func ServiceName(r port.PortResult) string {
+	if port.IsGuessed(r) {
+		return r.Service + port.GuessSuffix
+	}
+	return r.Service
+}
+
+// PrintReport prints the operator note (if any), then each host's header
+// (target, OS, RTT summary, notes) followed by its result table. Hosts are
+// separated by a blank line.
+func PrintReport(rep report.ScanReport, w io.Writer) {
+	if rep.Meta.Note != "" {
+		fmt.Fprintln(w, i18n.Tf("Note: %s", Printable(rep.Meta.Note)))
+	}
+	for i, h := range rep.Hosts {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		addrs := h.IP
+		if len(h.Addrs) > 1 {
+			addrs = strings.Join(h.Addrs, ", ")
+		}
+		fmt.Fprintln(w, i18n.Tf("Target: %s -> %s", Printable(h.Target), addrs))
+		switch {
+		case !rep.Meta.OSDetect:
+			fmt.Fprintln(w, i18n.T("OS: disabled"))
+		case h.OSGuess != "":
+			fmt.Fprintln(w, i18n.Tf("OS: %s (confidence: %s)", Printable(h.OSGuess), h.OSConfidence))
+		default:
+			fmt.Fprintln(w, i18n.T("OS: unknown"))
+		}
+		if h.RTT.Samples > 0 {
+			fmt.Fprintln(w, i18n.Tf("RTT: median=%dms min=%dms max=%dms (%d samples)",
+				h.RTT.MedianMillis, h.RTT.MinMillis, h.RTT.MaxMillis, h.RTT.Samples))
+		}
+		if len(h.Deception) > 0 {
+			fmt.Fprintln(w, i18n.Tf("Suspicious (possible tarpit/honeypot): %s", Printable(strings.Join(h.Deception, "; "))))
+		}
+		if h.Capped {
+			fmt.Fprintln(w, i18n.T("Capped: too many open ports, remaining ports not scanned"))
+		}
+		if h.Down {
+			fmt.Fprintln(w, i18n.T("Down: the first ports probed all timed out"))
+		}
+		if h.Cloud != nil {
+			fmt.Fprintln(w, i18n.Tf("Cloud: %s", Printable(CloudLabel(h.Cloud))))
+		}
+		for _, n := range h.Notes {
+			fmt.Fprintln(w, i18n.Tf("Note: %s", Printable(n)))
+		}
+		PrintTableFromSlice(h.Results, w)
+		printVHosts(h.Results, w)
+		printHexDumps(h.Results, w)
+	}
+}
+
+// printHexDumps shows the dumps of unidentified banners, one block per
+// port. hex.Dump output is plain ASCII, so it needs no escaping.
+func printHexDumps(results []port.PortResult, w io.Writer) {
+	for _, r := range results {
+		if r.HexDump == "" {
+			continue
+		}
+		fmt.Fprintln(w, i18n.Tf("Unidentified banner %s %s:", r.IP, PortProto(r)))
+		for _, line := range strings.SplitAfter(strings.TrimSuffix(r.HexDump, "\n"), "\n") {
+			fmt.Fprintf(w, "  %s", line)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// CloudLabel describes a cloud instance on one line: provider, ID, name,
+// account, zone or region, then the tags in key order.
+func CloudLabel(c *report.CloudInstance) string {
+	s := c.Provider + " " + c.ID
+	if c.Name != "" && c.Name != c.ID {
+		s += " (" + c.Name + ")"
+	}
+	if c.Account != "" {
+		s += ", account " + c.Account
+	}
+	if c.Zone != "" {
+		s += ", " + c.Zone
+	} else if c.Region != "" {
+		s += ", " + c.Region
+	}
+	if tags := cloudTags(c); tags != "" {
+		s += "; tags: " + tags
+	}
+	return s
+}
+
+// cloudTags lists a cloud instance's tags as key=value in key order.
+func cloudTags(c *report.CloudInstance) string {
+	keys := make([]string, 0, len(c.Tags))
+	for k := range c.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + "=" + c.Tags[k]
+	}
+	return strings.Join(keys, ", ")
+}
+
+// printVHosts lists the virtual host comparison of each web port, once per
+// port even when results of several aliases carry it.
+func printVHosts(results []port.PortResult, w io.Writer) {
+	seen := make(map[string]bool)
+	for _, r := range results {
+		key := fmt.Sprintf("%s:%d", r.IP, r.Port)
+		if len(r.VHosts) == 0 || seen[key] {
+			continue
+		}
+		seen[key] = true
+		for _, v := range r.VHosts {
+			desc := "error: " + v.Error
+			if v.Error == "" {
+				desc = fmt.Sprintf("http=%d len=%d", v.StatusCode, v.Length)
+				if v.Title != "" {
+					desc += fmt.Sprintf(" title=%q", v.Title)
+				}
+				if v.Location != "" {
+					desc += " location=" + v.Location
+				}
+				if v.Differs {
+					desc += " " + i18n.T("(differs from bare IP)")
+				}
+			}
+			fmt.Fprintf(w, "VHost %s %s: %s\n", key, Printable(v.Name), Printable(desc))
+		}
+	}
+}
+
+// Printable escapes what a terminal would interpret in s: control
+// characters (including ANSI escape sequences and line breaks), other
+// non-printable runes and invalid UTF-8 are written as Go escapes such as
+// \x1b, \n or \u200b. Text taken from the network (banners, certificate
+// names, page titles) goes through it before reaching the console; JSON
+// output keeps the original.
+func Printable(s string) string {
+	clean := true
+	for _, r := range s {
+		if !unicode.IsPrint(r) && r != ' ' {
+			clean = false
+			break
+		}
+	}
+	if clean {
+		return s
+	}
+	var b strings.Builder
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&b, "\\x%02x", s[0])
+		case unicode.IsPrint(r) || r == ' ':
+			b.WriteString(s[:size])
+		default:
+			q := strconv.QuoteRuneToASCII(r)
+			b.WriteString(q[1 : len(q)-1])
+		}
+		s = s[size:]
+	}
+	return b.String()
+}
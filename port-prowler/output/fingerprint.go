@@ -0,0 +1,55 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"portprowler/report"
+)
+
+// fingerprintRecord is one line of the fingerprint export. Service is left
+// empty for the contributor to fill in before proposing a signature.
+type fingerprintRecord struct {
+	Target      string    `json:"target"`
+	IP          string    `json:"ip"`
+	Port        uint16    `json:"port"`
+	Proto       string    `json:"proto"`
+	Probe       string    `json:"probe"`
+	Payload     string    `json:"payload,omitempty"`
+	ResponseHex string    `json:"response_hex"`
+	Response    string    `json:"response"` // response as text, for reading
+	Service     string    `json:"service"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// WriteFingerprints writes every unmatched fingerprint in rep as JSON Lines
+// and returns how many were written.
+func WriteFingerprints(rep report.ScanReport, w io.Writer) (int, error) {
+	enc := json.NewEncoder(w)
+	n := 0
+	for _, h := range rep.Hosts {
+		for _, r := range h.Results {
+			fp := r.Fingerprint
+			if fp == nil {
+				continue
+			}
+			rec := fingerprintRecord{
+				Target:      h.Target,
+				IP:          r.IP,
+				Port:        r.Port,
+				Proto:       r.Proto,
+				Probe:       fp.Probe,
+				Payload:     fp.Payload,
+				ResponseHex: fp.ResponseHex,
+				Response:    r.ServiceBanner,
+				Timestamp:   r.Timestamp,
+			}
+			if err := enc.Encode(rec); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,130 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"portprowler/report"
+)
+
+// Formats lists the supported -o output formats.
+var Formats = []string{"table", "json", "csv", "openmetrics"}
+
+// Spec is one requested output: a format and an optional file path.
+// An empty Path means stdout. Template is the template file of the
+// "template" format.
+type Spec struct {
+	Format   string
+	Path     string
+	Template string
+}
+
+// ParseSpec parses an -o value of the form "format" or "format=path", or
+// "template=file.tmpl[=path]" for a user template (see RenderTemplate).
+func ParseSpec(s string) (Spec, error) {
+	format, path, _ := strings.Cut(s, "=")
+	spec := Spec{Format: strings.ToLower(strings.TrimSpace(format)), Path: strings.TrimSpace(path)}
+	if spec.Format == "template" {
+		tmpl, path, _ := strings.Cut(spec.Path, "=")
+		spec.Template, spec.Path = strings.TrimSpace(tmpl), strings.TrimSpace(path)
+		if spec.Template == "" {
+			return Spec{}, fmt.Errorf("output %q names no template file (want template=file.tmpl[=path])", s)
+		}
+		if strings.Count(s, "=") > 1 && spec.Path == "" {
+			return Spec{}, fmt.Errorf("empty path in output %q", s)
+		}
+		return spec, nil
+	}
+	for _, f := range Formats {
+		if spec.Format == f {
+			if strings.Contains(s, "=") && spec.Path == "" {
+				return Spec{}, fmt.Errorf("empty path in output %q", s)
+			}
+			return spec, nil
+		}
+	}
+	return Spec{}, fmt.Errorf("unknown output format %q (want one of %s, or template=file.tmpl)", format, strings.Join(Formats, ", "))
+}
+
+// Render writes rep in the given format. summary is the one-line scan
+// summary appended to the table format.
+func Render(format string, rep report.ScanReport, summary string, w io.Writer) error {
+	switch format {
+	case "table":
+		var buf bytes.Buffer
+		PrintReport(rep, &buf)
+		fmt.Fprintln(&buf, summary)
+		_, err := w.Write(buf.Bytes())
+		return err
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	case "csv":
+		return WriteCSV(rep, w)
+	case "openmetrics":
+		return WriteOpenMetrics(rep, w)
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// csvHeader is the column order of WriteCSV.
+var csvHeader = []string{
+	"target", "ip", "port", "proto", "state", "service", "os_guess",
+	"rtt_ms", "error", "tls_version", "tls_subject", "http_status", "http_title", "timestamp", "severity",
+	"cloud_provider", "cloud_id", "cloud_account", "cloud_region", "cloud_tags", "service_confidence",
+}
+
+// WriteCSV writes one row per result, hosts in report order and results
+// sorted like the table.
+func WriteCSV(rep report.ScanReport, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, h := range rep.Hosts {
+		results := append(h.Results[:0:0], h.Results...)
+		sort.SliceStable(results, func(i, j int) bool {
+			if results[i].Proto != results[j].Proto {
+				return results[i].Proto < results[j].Proto
+			}
+			return results[i].Port < results[j].Port
+		})
+		for _, r := range results {
+			var tlsVersion, tlsSubject, httpStatus, httpTitle string
+			if r.TLS != nil {
+				tlsVersion, tlsSubject = r.TLS.Version, r.TLS.Subject
+			}
+			if r.HTTP != nil {
+				httpStatus, httpTitle = strconv.Itoa(r.HTTP.StatusCode), r.HTTP.Title
+			}
+			var cloudCols [5]string
+			if c := h.Cloud; c != nil {
+				cloudCols = [5]string{c.Provider, c.ID, c.Account, c.Region, cloudTags(c)}
+			}
+			osGuess := r.OSGuess
+			if osGuess == "" {
+				osGuess = h.OSGuess
+			}
+			row := []string{
+				h.Target, r.IP, strconv.Itoa(int(r.Port)), r.Proto, r.State, r.Service, osGuess,
+				strconv.FormatInt(r.RTTMillis, 10), r.Error, tlsVersion, tlsSubject, httpStatus, httpTitle,
+				r.Timestamp.Format(time.RFC3339), r.Severity,
+				cloudCols[0], cloudCols[1], cloudCols[2], cloudCols[3], cloudCols[4], r.ServiceConfidence,
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,229 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"portprowler/i18n"
+	"portprowler/port"
+	"portprowler/report"
+)
+
+func TestParseSpec(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    Spec
+		wantErr bool
+	}{
+		{in: "table", want: Spec{Format: "table"}},
+		{in: "json=scan.json", want: Spec{Format: "json", Path: "scan.json"}},
+		{in: "CSV=out/scan.csv", want: Spec{Format: "csv", Path: "out/scan.csv"}},
+		{in: "json=", wantErr: true},
+		{in: "xml=scan.xml", wantErr: true},
+		{in: "openmetrics=/var/lib/node_exporter/portprowler.prom", want: Spec{Format: "openmetrics", Path: "/var/lib/node_exporter/portprowler.prom"}},
+		{in: "template=report.tmpl", want: Spec{Format: "template", Template: "report.tmpl"}},
+		{in: "template=report.tmpl=out/report.md", want: Spec{Format: "template", Template: "report.tmpl", Path: "out/report.md"}},
+		{in: "template", wantErr: true},
+		{in: "template=report.tmpl=", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := ParseSpec(c.in)
+		if (err != nil) != c.wantErr {
+			t.Fatalf("ParseSpec(%q) err = %v, wantErr %v", c.in, err, c.wantErr)
+		}
+		if err == nil && got != c.want {
+			t.Fatalf("ParseSpec(%q) = %+v, want %+v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestRenderJSONAndCSV(t *testing.T) {
+	rep := report.Build(report.Meta{PortSpec: "22,80"}, []port.Target{{Name: "host.example", IP: "192.0.2.1"}}, []port.PortResult{
+		{Target: "host.example", IP: "192.0.2.1", Port: 80, Proto: "tcp", State: "open", Service: "http", ServiceConfidence: "low", HTTP: &port.HTTPInfo{StatusCode: 200, Title: "Hi, there"}},
+		{Target: "host.example", IP: "192.0.2.1", Port: 22, Proto: "tcp", State: "closed", Error: "connection refused"},
+	})
+	rep.Hosts[0].Cloud = &report.CloudInstance{Provider: "aws", ID: "i-0abc", Account: "123456789012", Region: "eu-west-1", Tags: map[string]string{"team": "web", "env": "prod"}}
+
+	var jbuf bytes.Buffer
+	if err := Render("json", rep, "", &jbuf); err != nil {
+		t.Fatalf("render json: %v", err)
+	}
+	var decoded report.ScanReport
+	if err := json.Unmarshal(jbuf.Bytes(), &decoded); err != nil {
+		t.Fatalf("json output does not decode: %v", err)
+	}
+	if decoded.Meta.PortSpec != "22,80" || len(decoded.Hosts) != 1 || len(decoded.Hosts[0].Results) != 2 {
+		t.Fatalf("unexpected decoded report: %+v", decoded)
+	}
+
+	var cbuf bytes.Buffer
+	if err := Render("csv", rep, "", &cbuf); err != nil {
+		t.Fatalf("render csv: %v", err)
+	}
+	rows, err := csv.NewReader(&cbuf).ReadAll()
+	if err != nil {
+		t.Fatalf("csv output does not parse: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d csv rows, want header + 2", len(rows))
+	}
+	if rows[1][2] != "22" || rows[2][2] != "80" || rows[2][12] != "Hi, there" {
+		t.Fatalf("unexpected csv rows: %q", rows[1:])
+	}
+	if got := strings.Join(rows[1][15:20], "|"); got != "aws|i-0abc|123456789012|eu-west-1|env=prod, team=web" {
+		t.Fatalf("unexpected cloud columns %q", got)
+	}
+	if rows[0][20] != "service_confidence" || rows[2][20] != "low" {
+		t.Fatalf("unexpected service_confidence column: %q %q", rows[0][20], rows[2][20])
+	}
+}
+
+func TestRenderOpenMetrics(t *testing.T) {
+	rep := report.Build(report.Meta{}, []port.Target{{Name: `we"ird`, IP: "192.0.2.1"}}, []port.PortResult{
+		{Target: `we"ird`, IP: "192.0.2.1", Port: 443, Proto: "tcp", State: "open"},
+		{Target: `we"ird`, IP: "192.0.2.1", Port: 22, Proto: "tcp", State: "closed"},
+		{Target: `we"ird`, IP: "192.0.2.1", Proto: "ping", State: "up"},
+	})
+	var buf bytes.Buffer
+	if err := Render("openmetrics", rep, "", &buf); err != nil {
+		t.Fatalf("render openmetrics: %v", err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"# TYPE portprowler_port_open gauge\n" +
+			`portprowler_port_open{host="we\"ird",ip="192.0.2.1",port="22",proto="tcp"} 0` + "\n" +
+			`portprowler_port_open{host="we\"ird",ip="192.0.2.1",port="443",proto="tcp"} 1` + "\n",
+		`portprowler_host_open_ports{host="we\"ird",ip="192.0.2.1"} 1` + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("openmetrics output lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `proto="ping"`) || !strings.HasSuffix(got, "# EOF\n") {
+		t.Errorf("unexpected openmetrics output:\n%s", got)
+	}
+}
+
+func TestWriteFingerprints(t *testing.T) {
+	rep := report.Build(report.Meta{}, []port.Target{{Name: "h", IP: "192.0.2.1"}}, []port.PortResult{
+		{Target: "h", IP: "192.0.2.1", Port: 22, Proto: "tcp", State: "open", Service: "ssh"},
+		{Target: "h", IP: "192.0.2.1", Port: 9999, Proto: "tcp", State: "open", ServiceBanner: "HELLO v2",
+			Fingerprint: &port.Fingerprint{Probe: "null", ResponseHex: "48454c4c4f207632"}},
+	})
+	var buf bytes.Buffer
+	n, err := WriteFingerprints(rep, &buf)
+	if err != nil || n != 1 {
+		t.Fatalf("WriteFingerprints = %d, %v; want 1 record", n, err)
+	}
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("record is not JSON: %v", err)
+	}
+	if rec["port"] != float64(9999) || rec["response_hex"] != "48454c4c4f207632" || rec["response"] != "HELLO v2" {
+		t.Fatalf("unexpected record: %v", rec)
+	}
+}
+
+func TestPrintable(t *testing.T) {
+	cases := map[string]string{
+		"SSH-2.0-OpenSSH_9.6": "SSH-2.0-OpenSSH_9.6",
+		"café":                "café",
+		"\x1b[2Jgotcha\r\n":   `\x1b[2Jgotcha\r\n`,
+		"a\x00b\u200bc\xff":   `a\x00b\u200bc\xff`,
+	}
+	for in, want := range cases {
+		if got := Printable(in); got != want {
+			t.Errorf("Printable(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRenderBanner(t *testing.T) {
+	raw := []byte("\x1b]0;pwned\x07hello\r\n")
+	rep := report.Build(report.Meta{}, []port.Target{{Name: "h\x1b[31m", IP: "192.0.2.1"}}, []port.PortResult{
+		{Target: "h\x1b[31m", IP: "192.0.2.1", Port: 7, Proto: "tcp", State: "open", Service: "echo\x1b[0m",
+			ServiceBanner: ".]0;pwned.hello", BannerRaw: raw},
+	})
+	var tbuf bytes.Buffer
+	if err := Render("table", rep, "", &tbuf); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.IndexByte(tbuf.Bytes(), 0x1b) >= 0 {
+		t.Fatalf("table output contains an escape character:\n%s", tbuf.String())
+	}
+	var jbuf bytes.Buffer
+	if err := Render("json", rep, "", &jbuf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded report.ScanReport
+	if err := json.Unmarshal(jbuf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.Hosts[0].Results[0].BannerRaw; !bytes.Equal(got, raw) {
+		t.Fatalf("banner_raw = %q, want %q", got, raw)
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	rep := report.Build(report.Meta{Note: "weekly"}, []port.Target{{Name: "host.example", IP: "192.0.2.1"}}, []port.PortResult{
+		{Target: "host.example", IP: "192.0.2.1", Port: 443, Proto: "tcp", State: "open", Service: "https"},
+		{Target: "host.example", IP: "192.0.2.1", Port: 22, Proto: "tcp", State: "open", Service: "ssh"},
+		{Target: "host.example", IP: "192.0.2.1", Port: 23, Proto: "tcp", State: "closed"},
+	})
+	path := filepath.Join(t.TempDir(), "report.tmpl")
+	tmpl := `# {{.Meta.Note}}
+{{range .Hosts}}{{.Target}}:{{range open .}} {{portproto .}}={{upper .Service}}{{end}}
+{{end}}{{.Summary}}
+`
+	if err := os.WriteFile(path, []byte(tmpl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := RenderTemplate(path, rep, "1 host", &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "# weekly\nhost.example: 443/tcp=HTTPS 22/tcp=SSH\n1 host\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+
+	if err := os.WriteFile(path, []byte("{{.Nope"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTemplate(path); err == nil {
+		t.Fatal("no error for a broken template")
+	}
+}
+
+func TestRenderTableLang(t *testing.T) {
+	rep := report.Build(report.Meta{Note: "hebdo"}, []port.Target{{Name: "host.example", IP: "192.0.2.1"}}, []port.PortResult{
+		{Target: "host.example", IP: "192.0.2.1", Port: 22, Proto: "tcp", State: "open", Service: "ssh"},
+	})
+	if err := i18n.Set("fr"); err != nil {
+		t.Fatal(err)
+	}
+	defer i18n.Set("")
+	var buf bytes.Buffer
+	if err := Render("table", rep, "", &buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{"Note : hebdo\n", "Cible : host.example -> 192.0.2.1\n", "OS : désactivé\n", "CIBLE", "ÉTAT", " open ", " ssh "} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in\n%s", want, got)
+		}
+	}
+	// Machine formats are not translated.
+	buf.Reset()
+	if err := Render("csv", rep, "", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "target,ip,port,proto,state,") {
+		t.Errorf("csv header %q", strings.SplitN(buf.String(), "\n", 2)[0])
+	}
+}
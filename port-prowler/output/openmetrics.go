@@ -0,0 +1,61 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"portprowler/port"
+	"portprowler/report"
+)
+
+// WriteOpenMetrics writes rep as OpenMetrics text, for node_exporter's
+// textfile collector and similar scrapers: portprowler_port_open is 1 for
+// every open port and 0 for every other scanned one, so a port closing
+// shows up as a change rather than a missing series.
+func WriteOpenMetrics(rep report.ScanReport, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# HELP portprowler_port_open Whether the port was found open (1) or not (0).")
+	fmt.Fprintln(bw, "# TYPE portprowler_port_open gauge")
+	openPorts := make([]int, len(rep.Hosts))
+	for i, h := range rep.Hosts {
+		results := append(h.Results[:0:0], h.Results...)
+		sort.SliceStable(results, func(i, j int) bool {
+			if results[i].Proto != results[j].Proto {
+				return results[i].Proto < results[j].Proto
+			}
+			return results[i].Port < results[j].Port
+		})
+		for _, r := range results {
+			if r.Proto == string(port.ScanPing) {
+				continue
+			}
+			v := 0
+			if r.State == "open" {
+				v = 1
+				openPorts[i]++
+			}
+			fmt.Fprintf(bw, "portprowler_port_open{host=\"%s\",ip=\"%s\",port=\"%d\",proto=\"%s\"} %d\n",
+				labelValue(h.Target), labelValue(r.IP), r.Port, labelValue(r.Proto), v)
+		}
+	}
+	fmt.Fprintln(bw, "# HELP portprowler_host_open_ports Number of open ports found on the host.")
+	fmt.Fprintln(bw, "# TYPE portprowler_host_open_ports gauge")
+	for i, h := range rep.Hosts {
+		fmt.Fprintf(bw, "portprowler_host_open_ports{host=\"%s\",ip=\"%s\"} %d\n", labelValue(h.Target), labelValue(h.IP), openPorts[i])
+	}
+	if !rep.Meta.Finished.IsZero() {
+		fmt.Fprintln(bw, "# HELP portprowler_scan_finished_seconds When the scan finished, in seconds since the epoch.")
+		fmt.Fprintln(bw, "# TYPE portprowler_scan_finished_seconds gauge")
+		fmt.Fprintf(bw, "portprowler_scan_finished_seconds %d\n", rep.Meta.Finished.Unix())
+	}
+	fmt.Fprintln(bw, "# EOF")
+	return bw.Flush()
+}
+
+// labelValue escapes s for a quoted label value.
+func labelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
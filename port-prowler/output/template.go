@@ -0,0 +1,65 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"portprowler/i18n"
+	"portprowler/port"
+	"portprowler/report"
+)
+
+// TemplateData is what a user template is executed with: the report's
+// .Meta and .Hosts, and the one-line .Summary of the table format.
+type TemplateData struct {
+	report.ScanReport
+	Summary string
+}
+
+// templateFuncs are available to user templates in addition to the
+// text/template builtins.
+var templateFuncs = template.FuncMap{
+	"join":      strings.Join,
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+	"printable": Printable,
+	"portproto": PortProto,
+	"t":         i18n.T, // the message in the --lang language
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	// open returns the open results of a host.
+	"open": func(h report.HostReport) []port.PortResult {
+		var out []port.PortResult
+		for _, r := range h.Results {
+			if r.State == "open" {
+				out = append(out, r)
+			}
+		}
+		return out
+	},
+}
+
+// LoadTemplate parses the text/template in path.
+func LoadTemplate(path string) (*template.Template, error) {
+	t, err := template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %v", path, err)
+	}
+	return t, nil
+}
+
+// RenderTemplate executes the template in path with rep and summary (see
+// TemplateData) and writes the result to w.
+func RenderTemplate(path string, rep report.ScanReport, summary string, w io.Writer) error {
+	t, err := LoadTemplate(path)
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, TemplateData{ScanReport: rep, Summary: summary})
+}
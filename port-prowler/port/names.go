@@ -0,0 +1,76 @@
+package port
+
+// GuessSuffix marks a service name guessed from the port number alone in
+// console output; machine formats carry the guess as low confidence.
+const GuessSuffix = "?"
+
+// wellKnownTCP names the service registered with IANA (or commonly
+// deployed) on a TCP port, after the IANA service name registry.
+var wellKnownTCP = map[uint16]string{
+	7: "echo", 9: "discard", 13: "daytime", 21: "ftp", 22: "ssh", 23: "telnet",
+	25: "smtp", 26: "smtp", 37: "time", 53: "dns", 79: "finger", 80: "http",
+	81: "http", 88: "kerberos", 106: "pop3pw", 110: "pop3", 111: "rpcbind",
+	113: "ident", 119: "nntp", 135: "msrpc", 139: "netbios", 143: "imap",
+	144: "news", 179: "bgp", 199: "smux", 389: "ldap", 427: "svrloc",
+	443: "https", 444: "snpp", 445: "smb", 465: "smtps", 513: "rlogin",
+	514: "rsh", 515: "printer", 543: "klogin", 544: "kshell", 548: "afp",
+	554: "rtsp", 587: "submission", 631: "ipp", 636: "ldaps", 646: "ldp",
+	873: "rsync", 990: "ftps", 993: "imaps", 995: "pop3s", 1080: "socks",
+	1433: "mssql", 1521: "oracle", 1723: "pptp", 1755: "wms", 1883: "mqtt",
+	1900: "upnp", 2049: "nfs", 2121: "ftp", 2181: "zookeeper", 2375: "docker",
+	2376: "docker", 3000: "http", 3128: "http-proxy", 3306: "mysql",
+	3389: "rdp", 4369: "epmd", 4899: "radmin", 5000: "upnp", 5060: "sip",
+	5061: "sips", 5190: "aol", 5357: "wsdapi", 5432: "postgresql",
+	5631: "pcanywhere", 5666: "nrpe", 5672: "amqp", 5800: "vnc-http",
+	5900: "vnc", 5985: "winrm", 5986: "winrm", 6000: "x11", 6379: "redis",
+	6443: "kubernetes", 6667: "irc", 8000: "http", 8008: "http",
+	8009: "ajp13", 8080: "http", 8081: "http", 8443: "https", 8888: "http",
+	9000: "http", 9042: "cassandra", 9092: "kafka", 9100: "jetdirect",
+	9200: "elasticsearch", 9418: "git", 10000: "webmin", 11211: "memcached",
+	27017: "mongodb",
+}
+
+// wellKnownUDP is wellKnownTCP for UDP.
+var wellKnownUDP = map[uint16]string{
+	7: "echo", 53: "dns", 67: "dhcps", 68: "dhcpc", 69: "tftp", 80: "http",
+	88: "kerberos", 111: "rpcbind", 123: "ntp", 135: "msrpc", 136: "profile",
+	137: "netbios-ns", 138: "netbios-dgm", 139: "netbios", 161: "snmp",
+	162: "snmptrap", 445: "smb", 500: "isakmp", 514: "syslog", 518: "ntalk",
+	520: "rip", 593: "http-rpc-epmap", 623: "ipmi", 626: "serialnumberd",
+	631: "ipp", 1194: "openvpn", 1434: "mssql-m", 1645: "radius",
+	1646: "radacct", 1701: "l2tp", 1812: "radius", 1813: "radacct",
+	1900: "upnp", 2049: "nfs", 3283: "netassistant", 3478: "stun",
+	4500: "nat-t-ike", 5060: "sip", 5353: "mdns", 5683: "coap",
+	11211: "memcached",
+}
+
+// WellKnownService returns the service name usually found on port p for
+// proto ("udp", or TCP for anything else), or "" if the port has none.
+func WellKnownService(p uint16, proto string) string {
+	if proto == "udp" {
+		return wellKnownUDP[p]
+	}
+	return wellKnownTCP[p]
+}
+
+// GuessService fills in the service of an open (or open|filtered) result
+// that has none with the port's well-known name, such as "https", at low
+// confidence. It reports whether it did.
+func GuessService(r *PortResult) bool {
+	if r.Service != "" || (r.State != "open" && r.State != "open|filtered") {
+		return false
+	}
+	name := WellKnownService(r.Port, r.Proto)
+	if name == "" {
+		return false
+	}
+	r.Service = name
+	r.ServiceConfidence = ConfidenceLow
+	return true
+}
+
+// IsGuessed reports whether r's service was guessed from the port number:
+// detection rates what it identifies medium or high.
+func IsGuessed(r PortResult) bool {
+	return r.Service != "" && r.ServiceConfidence == ConfidenceLow
+}
@@ -0,0 +1,28 @@
+package port
+
+import "testing"
+
+func TestGuessService(t *testing.T) {
+	cases := []struct {
+		r          PortResult
+		service    string
+		confidence string
+	}{
+		{PortResult{Port: 443, Proto: "tcp", State: "open"}, "https", "low"},
+		{PortResult{Port: 3306, Proto: "stealth", State: "open"}, "mysql", "low"},
+		{PortResult{Port: 161, Proto: "udp", State: "open|filtered"}, "snmp", "low"},
+		{PortResult{Port: 161, Proto: "tcp", State: "open"}, "", ""},
+		{PortResult{Port: 443, Proto: "tcp", State: "closed"}, "", ""},
+		{PortResult{Port: 443, Proto: "tcp", State: "open", Service: "ssh", ServiceConfidence: "high"}, "ssh", "high"},
+	}
+	for _, c := range cases {
+		r := c.r
+		guessed := GuessService(&r)
+		if r.Service != c.service || r.ServiceConfidence != c.confidence || guessed != (c.r.Service == "" && c.service != "") {
+			t.Errorf("%d/%s %s: got %q %q (guessed %v), want %q %q", c.r.Port, c.r.Proto, c.r.State, r.Service, r.ServiceConfidence, guessed, c.service, c.confidence)
+		}
+	}
+	if !IsGuessed(PortResult{Service: "https", ServiceConfidence: "low"}) || IsGuessed(PortResult{Service: "https", ServiceConfidence: "medium"}) {
+		t.Error("IsGuessed misreads the guess marker")
+	}
+}
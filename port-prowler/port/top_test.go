@@ -0,0 +1,40 @@
+package port
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTopTablesDistinct(t *testing.T) {
+	for name, top := range map[string][]uint16{"tcp": TopTCP, "udp": TopUDP} {
+		seen := make(map[uint16]bool)
+		for _, p := range top {
+			if seen[p] || p == 0 {
+				t.Errorf("%s table: port %d is listed twice or zero", name, p)
+			}
+			seen[p] = true
+		}
+	}
+}
+
+func TestLikelyFirst(t *testing.T) {
+	ports, err := ParsePortSpec("1-25,443,8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := LikelyFirst(ports, false)
+	want := []uint16{23, 443, 21, 22, 25, 8080, 7, 13, 9}
+	if !reflect.DeepEqual(got[:len(want)], want) {
+		t.Fatalf("head = %v, want %v", got[:len(want)], want)
+	}
+	if len(got) != len(ports) {
+		t.Fatalf("got %d ports, want %d", len(got), len(ports))
+	}
+	// The rest keep their order.
+	if rest := got[len(want):]; rest[0] != 1 || rest[1] != 2 || rest[len(rest)-1] != 24 {
+		t.Errorf("unranked ports reordered: %v", rest)
+	}
+	if udp := LikelyFirst([]uint16{53, 80, 161}, true); !reflect.DeepEqual(udp, []uint16{161, 53, 80}) {
+		t.Errorf("udp order = %v", udp)
+	}
+}
@@ -1,5 +1,10 @@
 package port
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // ScanType represents the type of scan to perform for a job.
 type ScanType string
 
@@ -7,27 +12,157 @@ const (
 	ScanTCP     ScanType = "tcp"
 	ScanUDP     ScanType = "udp"
 	ScanStealth ScanType = "stealth"
+	// ScanPing checks host reachability once per target (Port 0).
+	ScanPing ScanType = "ping"
+)
+
+// Confidence levels of service and OS detection, lowest first. High is
+// a product-specific signature or a confirmed protocol exchange, medium
+// a generic banner or protocol match (an HTTP status line, a TLS
+// handshake), low a guess from the port number or indirect hints.
+const (
+	ConfidenceLow    = "low"
+	ConfidenceMedium = "medium"
+	ConfidenceHigh   = "high"
 )
 
+// Target is one resolved scan target.
+type Target struct {
+	Name string // original target as given (hostname or IP)
+	IP   string // resolved address that is scanned
+	// Ports, when set, are scanned on this target instead of the
+	// configured ports (a target given as host:port).
+	Ports []uint16
+}
+
 // PortJob represents a scanning job for a single port and one or more scan types.
 type PortJob struct {
 	Target    string
 	IP        string
 	Port      uint16
 	ScanTypes []ScanType // ordered list of scans to run sequentially for the port
+	// Aliases are other target names resolving to IP; each result is
+	// copied to them so the address is probed only once.
+	Aliases []string
 }
 
 // PortResult represents the result of scanning a single port/protocol.
 type PortResult struct {
-	Target        string
-	IP            string
-	Port          uint16
-	Proto         string // "tcp" | "udp" | "stealth"
-	State         string // "open" | "closed" | "filtered" | "unknown"
-	Service       string
-	ServiceBanner string
-	OSGuess       string
-	Confidence    string // "low"|"medium"|"high"
-	Error         string
-	RTTMillis     int64
+	Target        string `json:"target"`
+	IP            string `json:"ip"`
+	Port          uint16 `json:"port"`
+	Proto         string `json:"proto"` // "tcp" | "udp" | "stealth" | "ping"
+	State         string `json:"state"` // "open" | "closed" | "filtered" | "unknown"; "up" | "down" for ping
+	Service       string `json:"service,omitempty"`
+	ServiceBanner string `json:"service_banner,omitempty"`
+	OSGuess       string `json:"os_guess,omitempty"`
+	// ServiceConfidence and OSConfidence rate Service and OSGuess on the
+	// Confidence scale; each is set only with its guess.
+	ServiceConfidence string    `json:"service_confidence,omitempty"`
+	OSConfidence      string    `json:"os_confidence,omitempty"`
+	Severity          string    `json:"severity,omitempty"` // exposure rating for the scan context (see --context)
+	Error             string    `json:"error,omitempty"`    // probe failure or what settled the state (e.g. "timeout"); detection problems are only logged
+	RTTMillis         int64     `json:"rtt_ms"`
+	Timestamp         time.Time `json:"timestamp"`      // when the probe completed (UTC)
+	TLS               *TLSInfo  `json:"tls,omitempty"`  // set when a TLS handshake was attempted during detection
+	HTTP              *HTTPInfo `json:"http,omitempty"` // set when web content capture ran
+	DNS               *DNSInfo  `json:"dns,omitempty"`  // set when 53/udp answered the DNS probe
+	// Fingerprint keeps the raw exchange when a banner matched no signature
+	// and fingerprint collection is enabled.
+	Fingerprint *Fingerprint `json:"fingerprint,omitempty"`
+	// BannerRaw is what ServiceBanner was decoded from, before
+	// non-printable bytes were replaced (base64 in JSON).
+	BannerRaw []byte `json:"banner_raw,omitempty"`
+	// HexDump shows the first bytes of a banner that identified no
+	// service in hex and ASCII (hexdump -C layout), for working out the
+	// protocol by hand.
+	HexDump string `json:"hexdump,omitempty"`
+	// VHosts compares the responses of a web port per hostname; set by
+	// virtual host enumeration.
+	VHosts []VHost `json:"vhosts,omitempty"`
+	// Notes are annotations added by post-processing rules (--rules).
+	Notes []string `json:"notes,omitempty"`
+	// LocalAddr is the local ip:port the probe was sent from, when the
+	// probe learned it; kept for the audit log only.
+	LocalAddr string `json:"-"`
+}
+
+// UnmarshalJSON reads a result, migrating the single "confidence" of
+// reports written before service and OS confidence were separate. That
+// value was the OS guess's whenever there was one, and the service's
+// otherwise.
+func (r *PortResult) UnmarshalJSON(data []byte) error {
+	type plain PortResult
+	var v struct {
+		plain
+		Confidence string `json:"confidence"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*r = PortResult(v.plain)
+	switch {
+	case v.Confidence == "" || r.ServiceConfidence != "" || r.OSConfidence != "":
+	case r.OSGuess != "":
+		r.OSConfidence = v.Confidence
+	case r.Service != "":
+		r.ServiceConfidence = v.Confidence
+	}
+	return nil
+}
+
+// VHost is a web port's answer to GET / with one hostname as Host header
+// (and SNI on TLS ports), compared with the answer for the bare IP.
+type VHost struct {
+	Name       string `json:"name"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Location   string `json:"location,omitempty"` // redirect target, if any
+	Length     int    `json:"length"`             // body bytes read (capped)
+	Differs    bool   `json:"differs"`            // response differs from the bare-IP request
+	Error      string `json:"error,omitempty"`
+}
+
+// Fingerprint is an unmatched service response and the probe that elicited
+// it, kept so new signatures can be written from it.
+type Fingerprint struct {
+	Probe       string `json:"probe"`             // probe name: "null" (passive read), "http-head", "smtp-helo"
+	Payload     string `json:"payload,omitempty"` // bytes sent, empty for the null probe
+	ResponseHex string `json:"response_hex"`
+}
+
+// DNSInfo is what a DNS server's answer to the UDP probe showed.
+type DNSInfo struct {
+	Query string `json:"query"` // e.g. "example.com IN A", "version.bind CH TXT"
+	RCode string `json:"rcode"` // e.g. NOERROR, REFUSED
+	// Recursion is set when the server resolved the recursive IN query
+	// for us: an open resolver, if the name is not its own.
+	Recursion bool     `json:"recursion"`
+	Answers   []string `json:"answers,omitempty"` // decoded answer records: addresses, names or TXT strings
+}
+
+// HTTPInfo holds a small capture of a web service's response to GET /.
+type HTTPInfo struct {
+	StatusCode int    `json:"status_code"`
+	Server     string `json:"server,omitempty"`   // Server response header
+	Title      string `json:"title,omitempty"`    // text of the first <title> element
+	Location   string `json:"location,omitempty"` // Location response header
+	Body       []byte `json:"body,omitempty"`     // first N bytes of the response body
+}
+
+// TLSInfo holds what was learned from a TLS handshake with an open port.
+type TLSInfo struct {
+	Version     string    `json:"version,omitempty"` // e.g. "TLS1.3"
+	CipherSuite string    `json:"cipher_suite,omitempty"`
+	ALPN        string    `json:"alpn,omitempty"`        // negotiated application protocol
+	ServerName  string    `json:"server_name,omitempty"` // SNI sent in the ClientHello (empty when none)
+	Subject     string    `json:"subject,omitempty"`     // leaf certificate subject common name
+	Issuer      string    `json:"issuer,omitempty"`      // leaf certificate issuer common name
+	DNSNames    []string  `json:"dns_names,omitempty"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+	Verified    bool      `json:"verified"`               // chain and name verified against system roots
+	VerifyError string    `json:"verify_error,omitempty"` // verification failure (strict mode aborts the handshake)
+	JA3S        string    `json:"ja3s,omitempty"`         // JA3S string: "version,cipher,ext-ext-..."
+	JA3SHash    string    `json:"ja3s_hash,omitempty"`    // MD5 of JA3S
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"portprowler/logging"
+	"portprowler/netutil"
+	"portprowler/port"
+)
+
+// preflightRoutes checks which interface will carry probes to each target.
+// With requireIface set, any target routed elsewhere is an error and false
+// is returned. Otherwise it only warns when a VPN interface is up but a
+// target would leave through the default route instead.
+func preflightRoutes(targets []port.Target, requireIface string, verbose bool) bool {
+	ips := make([]string, len(targets))
+	for i, t := range targets {
+		ips[i] = t.IP
+	}
+	vpns := netutil.UpVPNInterfaces()
+	ok := true
+	for i, c := range netutil.CheckRoutes(ips) {
+		t := targets[i]
+		if c.Err != nil {
+			if requireIface != "" {
+				fmt.Fprintf(os.Stderr, "error: route check for %s (%s) failed: %v\n", t.Name, t.IP, c.Err)
+				ok = false
+			} else {
+				logging.Warnf("route check for %s (%s) failed: %v", t.Name, t.IP, c.Err)
+			}
+			continue
+		}
+		if verbose {
+			logging.Verbosef("route to %s (%s): iface=%s src=%s default=%v", t.Name, t.IP, c.Route.Interface, c.Route.Src, c.ViaDefault)
+		}
+		switch {
+		case requireIface != "" && c.Route.Interface != requireIface:
+			fmt.Fprintf(os.Stderr, "error: %s (%s) routes via %s, not --require-iface %s\n", t.Name, t.IP, c.Route.Interface, requireIface)
+			ok = false
+		case requireIface == "" && len(vpns) > 0 && c.ViaDefault && !netutil.IsVPNInterface(c.Route.Interface):
+			logging.Warnf("%s (%s) routes via the default route on %s, not VPN interface %s (use --require-iface to enforce)",
+				t.Name, t.IP, c.Route.Interface, strings.Join(vpns, "/"))
+		}
+	}
+	return ok
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"portprowler/logging"
+	"portprowler/netutil"
+	"portprowler/scanner"
+)
+
+// runProbe implements `portprowler probe host:port`, a single tcp connect
+// for container healthchecks (a replacement for `nc -z`). It exits 0 when
+// the port is in the --expect state and 1 otherwise, including when the
+// host does not resolve.
+func runProbe(args []string) int {
+	fs := flag.NewFlagSet("probe", flag.ContinueOnError)
+	expect := fs.String("expect", "open", "state that counts as healthy: open, closed or filtered")
+	timeout := fs.Duration("t", time.Second, "connect timeout (default 1s)")
+	silent := fs.Bool("silent", false, "print nothing; only the exit status reports the result")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s probe [flags] <host:port>\n", progName())
+		fs.PrintDefaults()
+	}
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return parseStatus(err)
+	}
+	logging.SetSilent(*silent)
+	if len(names) != 1 {
+		return exitStatus(&exitError{code: 2, msg: "error: probe takes exactly one host:port", usage: true}, fs)
+	}
+	switch *expect {
+	case "open", "closed", "filtered":
+	default:
+		return exitStatus(usageErr("error: --expect must be open, closed or filtered"), fs)
+	}
+	if *timeout <= 0 {
+		return exitStatus(usageErr("error: -t must be positive"), fs)
+	}
+	host, portStr, err := net.SplitHostPort(names[0])
+	if err != nil {
+		return exitStatus(usageErr("error: %v (want host:port, e.g. db:5432 or [::1]:80)", err), fs)
+	}
+	portNum, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil || portNum == 0 {
+		return exitStatus(usageErr("error: invalid port %q", portStr), fs)
+	}
+
+	addrs, err := netutil.ResolveTarget(host, netutil.ResolveOptions{DualStack: true})
+	if err != nil {
+		logging.Warnf("failed to resolve %s: %v", host, err)
+		return 1
+	}
+	res := scanner.TCPScan(context.Background(), addrs[0], uint16(portNum), *timeout, false)
+	if !*silent {
+		line := fmt.Sprintf("%s %s rtt=%dms", names[0], res.State, res.RTTMillis)
+		if res.IP != host {
+			line += " ip=" + res.IP
+		}
+		fmt.Println(line)
+	}
+	if res.State != *expect {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,143 @@
+// Package profile stores named sets of scan flags (`portprowler profile
+// save`) so teams can standardise scan configurations and share them as
+// files.
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"portprowler/output"
+)
+
+// Profile is a named set of flag values. Flags maps a flag name (without
+// dashes) to its values in order; repeatable flags have several.
+type Profile struct {
+	Name  string              `json:"name"`
+	Flags map[string][]string `json:"flags"`
+}
+
+// Args returns the profile as command line arguments, flags sorted by name.
+func (p Profile) Args() []string {
+	names := make([]string, 0, len(p.Flags))
+	for name := range p.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var args []string
+	for _, name := range names {
+		dash := "--"
+		if len(name) == 1 {
+			dash = "-"
+		}
+		for _, v := range p.Flags[name] {
+			args = append(args, dash+name+"="+v)
+		}
+	}
+	return args
+}
+
+// Store is a directory of profiles, one JSON file per profile.
+type Store struct {
+	Dir string
+}
+
+// Default returns the store under the user's configuration directory
+// (e.g. ~/.config/portprowler/profiles).
+func Default() (Store, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return Store{}, err
+	}
+	return Store{Dir: filepath.Join(dir, "portprowler", "profiles")}, nil
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidName reports whether name can name a profile: letters, digits,
+// dots, dashes and underscores, not starting with a dot or dash.
+func ValidName(name string) bool {
+	return validName.MatchString(name)
+}
+
+// Save writes p, replacing a profile of the same name.
+func (s Store) Save(p Profile) error {
+	if !ValidName(p.Name) {
+		return fmt.Errorf("invalid profile name %q", p.Name)
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return output.WriteAtomic(s.path(p.Name), append(data, '\n'))
+}
+
+// Load reads the named profile. A name ending in .json or containing a
+// path separator is read as a file instead, for profiles shared in a
+// repository.
+func (s Store) Load(name string) (Profile, error) {
+	path := name
+	if !strings.HasSuffix(name, ".json") && !strings.ContainsAny(name, `/\`) {
+		if !ValidName(name) {
+			return Profile{}, fmt.Errorf("invalid profile name %q", name)
+		}
+		path = s.path(name)
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && path != name {
+		return Profile{}, fmt.Errorf("no profile %q", name)
+	}
+	if err != nil {
+		return Profile{}, err
+	}
+	var p Profile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Profile{}, fmt.Errorf("profile %s: %v", name, err)
+	}
+	if p.Name == "" {
+		p.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+	}
+	return p, nil
+}
+
+// Delete removes the named profile.
+func (s Store) Delete(name string) error {
+	if !ValidName(name) {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	err := os.Remove(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no profile %q", name)
+	}
+	return err
+}
+
+// List returns the stored profiles sorted by name. A missing store is
+// empty.
+func (s Store) List() ([]Profile, error) {
+	names, err := filepath.Glob(filepath.Join(s.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var out []Profile
+	for _, name := range names {
+		p, err := s.Load(name)
+		if err != nil {
+			return nil, err
+		}
+		p.Name = strings.TrimSuffix(filepath.Base(name), ".json")
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (s Store) path(name string) string {
+	return filepath.Join(s.Dir, name+".json")
+}
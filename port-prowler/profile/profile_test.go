@@ -0,0 +1,57 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	s := Store{Dir: filepath.Join(t.TempDir(), "profiles")}
+	if list, err := s.List(); err != nil || len(list) != 0 {
+		t.Fatalf("empty store: %v, %v", list, err)
+	}
+
+	quick := Profile{Name: "quick", Flags: map[string][]string{
+		"p": {"1-1024"}, "c": {"500"}, "o": {"table", "json=scan.json"}, "service-detect": {"true"},
+	}}
+	if err := s.Save(quick); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(Profile{Name: "../escape", Flags: quick.Flags}); err == nil {
+		t.Fatal("saved a profile with a path as name")
+	}
+	got, err := s.Load("quick")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, quick) {
+		t.Fatalf("loaded %+v, want %+v", got, quick)
+	}
+	want := "-c=500 -o=table -o=json=scan.json -p=1-1024 --service-detect=true"
+	if args := strings.Join(got.Args(), " "); args != want {
+		t.Fatalf("args = %q, want %q", args, want)
+	}
+
+	// A shared profile file is loaded by path.
+	shared := filepath.Join(t.TempDir(), "team.json")
+	if err := os.WriteFile(shared, []byte(`{"flags":{"t":["2s"]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	team, err := s.Load(shared)
+	if err != nil || team.Name != "team" || team.Flags["t"][0] != "2s" {
+		t.Fatalf("shared profile = %+v, %v", team, err)
+	}
+
+	if list, err := s.List(); err != nil || len(list) != 1 || list[0].Name != "quick" {
+		t.Fatalf("list = %+v, %v", list, err)
+	}
+	if err := s.Delete("quick"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load("quick"); err == nil || !strings.Contains(err.Error(), "no profile") {
+		t.Fatalf("load after delete: %v", err)
+	}
+}
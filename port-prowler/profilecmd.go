@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"portprowler/logging"
+	"portprowler/profile"
+)
+
+// runProfile implements `portprowler profile`: `save <name> [scan flags]`
+// stores the flags under name, `list` (the default) and `show <name>`
+// print stored profiles, and `delete <name>` removes one. Scans pick a
+// profile with --profile.
+func runProfile(args []string) int {
+	fs := flag.NewFlagSet("profile", flag.ContinueOnError)
+	f := defaultScanFlags()
+	f.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s profile save <name> [scan flags]\n", progName())
+		fmt.Fprintf(fs.Output(), "       %s profile list\n", progName())
+		fmt.Fprintf(fs.Output(), "       %s profile show <name>\n", progName())
+		fmt.Fprintf(fs.Output(), "       %s profile delete <name>\n", progName())
+		fmt.Fprintf(fs.Output(), "\nExample: %s profile save quick -p 1-1024 -c 500 -t 500ms\n", progName())
+		fmt.Fprintf(fs.Output(), "         %s --profile quick 10.0.0.1\n", progName())
+	}
+	// Only the command line is saved, not the environment.
+	names, err := parseFlags(fs, args)
+	if err != nil {
+		return parseStatus(err)
+	}
+	store, err := profile.Default()
+	if err != nil {
+		return exitStatus(runtimeErr("failed to locate profiles: %v", err), fs)
+	}
+	switch {
+	case len(names) == 0 || len(names) == 1 && names[0] == "list":
+		return exitStatus(listProfiles(store), fs)
+	case len(names) == 2 && names[0] == "save":
+		return exitStatus(saveProfile(store, names[1], fs), fs)
+	case len(names) == 2 && names[0] == "show":
+		p, err := store.Load(names[1])
+		if err != nil {
+			return exitStatus(runtimeErr("%v", err), fs)
+		}
+		fmt.Println(strings.Join(p.Args(), " "))
+		return 0
+	case len(names) == 2 && names[0] == "delete":
+		if err := store.Delete(names[1]); err != nil {
+			return exitStatus(runtimeErr("%v", err), fs)
+		}
+		logging.Infof("Deleted profile %s", names[1])
+		return 0
+	default:
+		return exitStatus(&exitError{code: 2, usage: true}, fs)
+	}
+}
+
+// saveProfile stores the flags given on fs's command line as profile name.
+func saveProfile(store profile.Store, name string, fs *flag.FlagSet) error {
+	if !profile.ValidName(name) {
+		return usageErr("error: invalid profile name %q (use letters, digits, '.', '-' and '_')", name)
+	}
+	p := profile.Profile{Name: name, Flags: make(map[string][]string)}
+	fs.Visit(func(fl *flag.Flag) {
+		if list, ok := fl.Value.(*stringList); ok {
+			p.Flags[fl.Name] = append([]string(nil), (*list)...)
+			return
+		}
+		p.Flags[fl.Name] = []string{fl.Value.String()}
+	})
+	if _, ok := p.Flags["profile"]; ok {
+		return usageErr("error: a profile cannot refer to another profile")
+	}
+	if len(p.Flags) == 0 {
+		return usageErr("error: give the scan flags to save (e.g. -p 1-1024 -c 500)")
+	}
+	if err := store.Save(p); err != nil {
+		return runtimeErr("failed to save profile: %v", err)
+	}
+	logging.Infof("Saved profile %s: %s", name, strings.Join(p.Args(), " "))
+	return nil
+}
+
+func listProfiles(store profile.Store) error {
+	profiles, err := store.List()
+	if err != nil {
+		return runtimeErr("failed to read profiles: %v", err)
+	}
+	if len(profiles) == 0 {
+		logging.Infof("No profiles in %s (save one with profile save)", store.Dir)
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tFLAGS")
+	for _, p := range profiles {
+		fmt.Fprintf(tw, "%s\t%s\n", p.Name, strings.Join(p.Args(), " "))
+	}
+	if err := tw.Flush(); err != nil {
+		return runtimeErr("failed to write to stdout: %v", err)
+	}
+	return nil
+}
+
+// applyProfile sets every flag of fs that was not given on the command
+// line from the profile named by --profile (or PORTPROWLER_PROFILE).
+// Commands without a --profile flag are left alone.
+func applyProfile(fs *flag.FlagSet) error {
+	pf := fs.Lookup("profile")
+	if pf == nil {
+		return nil
+	}
+	name := pf.Value.String()
+	if name == "" {
+		name = os.Getenv(envName("profile"))
+	}
+	if name == "" {
+		return nil
+	}
+	store, err := profile.Default()
+	if err != nil {
+		return fmt.Errorf("failed to locate profiles: %v", err)
+	}
+	p, err := store.Load(name)
+	if err != nil {
+		return err
+	}
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for flagName, values := range p.Flags {
+		if given[flagName] {
+			continue
+		}
+		if fs.Lookup(flagName) == nil || flagName == "profile" {
+			return fmt.Errorf("profile %s: unknown flag -%s", p.Name, flagName)
+		}
+		for _, v := range values {
+			if err := fs.Set(flagName, v); err != nil {
+				return fmt.Errorf("profile %s: invalid value %q for -%s: %v", p.Name, v, flagName, err)
+			}
+		}
+	}
+	return fs.Set("profile", name)
+}
@@ -0,0 +1,58 @@
+package rawsock
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// BatchWriter is implemented by Conns that can hand several packets to the
+// kernel in one system call (sendmmsg on Linux).
+type BatchWriter interface {
+	// WritePackets sends pkts[i] to dsts[i] and returns how many were sent.
+	WritePackets(pkts [][]byte, dsts []net.IP) (int, error)
+}
+
+// BatchReader is implemented by Conns that can receive several packets in
+// one system call (recvmmsg on Linux).
+type BatchReader interface {
+	// ReadPackets blocks until at least one packet arrives or deadline
+	// passes, then fills bufs[i] with up to len(bufs) packets and sets
+	// sizes[i] to their lengths. It returns the number of packets read.
+	ReadPackets(bufs [][]byte, sizes []int, deadline time.Time) (int, error)
+}
+
+// WritePackets sends pkts[i] to dsts[i] over c, in one system call when c
+// is a BatchWriter and one WritePacket per packet otherwise. It returns
+// the number of packets sent before the first error.
+func WritePackets(c Conn, pkts [][]byte, dsts []net.IP) (int, error) {
+	if len(pkts) != len(dsts) {
+		return 0, errors.New("rawsock: packet and destination counts differ")
+	}
+	if bw, ok := c.(BatchWriter); ok {
+		return bw.WritePackets(pkts, dsts)
+	}
+	for i, pkt := range pkts {
+		if err := c.WritePacket(pkt, dsts[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(pkts), nil
+}
+
+// ReadPackets reads received packets into bufs over c, several per system
+// call when c is a BatchReader and one otherwise; see BatchReader.
+func ReadPackets(c Conn, bufs [][]byte, sizes []int, deadline time.Time) (int, error) {
+	if len(bufs) == 0 || len(sizes) < len(bufs) {
+		return 0, errors.New("rawsock: need at least one buffer and a size per buffer")
+	}
+	if br, ok := c.(BatchReader); ok {
+		return br.ReadPackets(bufs, sizes, deadline)
+	}
+	n, err := c.ReadPacket(bufs[0], deadline)
+	if err != nil {
+		return 0, err
+	}
+	sizes[0] = n
+	return 1, nil
+}
@@ -0,0 +1,109 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package rawsock
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// mmsghdr mirrors struct mmsghdr; Go pads it like C on 64-bit platforms.
+type mmsghdr struct {
+	hdr syscall.Msghdr
+	n   uint32
+}
+
+// WritePackets sends the packets with sendmmsg, repeating the call until
+// the kernel has taken all of them.
+func (c *linuxConn) WritePackets(pkts [][]byte, dsts []net.IP) (int, error) {
+	if len(pkts) != len(dsts) {
+		return 0, errors.New("rawsock: packet and destination counts differ")
+	}
+	if len(pkts) == 0 {
+		return 0, nil
+	}
+	hdrs := make([]mmsghdr, len(pkts))
+	iovs := make([]syscall.Iovec, len(pkts))
+	addrs := make([]syscall.RawSockaddrInet4, len(pkts))
+	for i, pkt := range pkts {
+		if len(pkt) == 0 {
+			return 0, errors.New("rawsock: empty packet")
+		}
+		addrs[i].Family = syscall.AF_INET
+		copy(addrs[i].Addr[:], dsts[i].To4())
+		iovs[i].Base = &pkt[0]
+		iovs[i].SetLen(len(pkt))
+		hdrs[i].hdr.Name = (*byte)(unsafe.Pointer(&addrs[i]))
+		hdrs[i].hdr.Namelen = syscall.SizeofSockaddrInet4
+		hdrs[i].hdr.Iov = &iovs[i]
+		hdrs[i].hdr.Iovlen = 1
+	}
+	sent := 0
+	for sent < len(hdrs) {
+		n, _, errno := syscall.Syscall6(sysSendmmsg, uintptr(c.send), uintptr(unsafe.Pointer(&hdrs[sent])), uintptr(len(hdrs)-sent), 0, 0, 0)
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return sent, fmt.Errorf("rawsock: sendmmsg: %w", errno)
+		}
+		sent += int(n)
+	}
+	return sent, nil
+}
+
+// ReadPackets receives up to len(bufs) packets with one recvmmsg, waiting
+// in the runtime poller so deadline applies as for ReadPacket.
+func (c *linuxConn) ReadPackets(bufs [][]byte, sizes []int, deadline time.Time) (int, error) {
+	if len(bufs) == 0 || len(sizes) < len(bufs) {
+		return 0, errors.New("rawsock: need at least one buffer and a size per buffer")
+	}
+	if err := c.recv.SetReadDeadline(deadline); err != nil {
+		return 0, err
+	}
+	rc, err := c.recv.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	hdrs := make([]mmsghdr, len(bufs))
+	iovs := make([]syscall.Iovec, len(bufs))
+	for i, b := range bufs {
+		if len(b) == 0 {
+			return 0, errors.New("rawsock: empty buffer")
+		}
+		iovs[i].Base = &b[0]
+		iovs[i].SetLen(len(b))
+		hdrs[i].hdr.Iov = &iovs[i]
+		hdrs[i].hdr.Iovlen = 1
+	}
+	var n int
+	var rerr error
+	err = rc.Read(func(fd uintptr) bool {
+		r, _, errno := syscall.Syscall6(sysRecvmmsg, fd, uintptr(unsafe.Pointer(&hdrs[0])), uintptr(len(hdrs)), syscall.MSG_DONTWAIT, 0, 0)
+		switch errno {
+		case 0:
+			n = int(r)
+		case syscall.EAGAIN, syscall.EINTR:
+			return false // wait until readable
+		default:
+			rerr = fmt.Errorf("rawsock: recvmmsg: %w", errno)
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if rerr != nil {
+		return 0, rerr
+	}
+	for i := 0; i < n; i++ {
+		sizes[i] = int(hdrs[i].n)
+	}
+	return n, nil
+}
@@ -0,0 +1,54 @@
+package rawsock
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeConn records written packets and fails the write after limit.
+type fakeConn struct {
+	sent  []net.IP
+	limit int
+	in    [][]byte
+}
+
+func (c *fakeConn) WritePacket(pkt []byte, dst net.IP) error {
+	if len(c.sent) == c.limit {
+		return errors.New("no buffer space")
+	}
+	c.sent = append(c.sent, dst)
+	return nil
+}
+
+func (c *fakeConn) ReadPacket(buf []byte, deadline time.Time) (int, error) {
+	if len(c.in) == 0 {
+		return 0, errors.New("nothing to read")
+	}
+	n := copy(buf, c.in[0])
+	c.in = c.in[1:]
+	return n, nil
+}
+
+func (c *fakeConn) LocalIP() net.IP { return net.IPv4(192, 0, 2, 1) }
+func (c *fakeConn) Close() error    { return nil }
+
+func TestBatchFallback(t *testing.T) {
+	c := &fakeConn{limit: 2, in: [][]byte{[]byte("one"), []byte("two")}}
+	pkts := [][]byte{{1}, {2}, {3}}
+	dsts := []net.IP{net.IPv4(198, 51, 100, 1), net.IPv4(198, 51, 100, 2), net.IPv4(198, 51, 100, 3)}
+	if n, err := WritePackets(c, pkts, dsts); n != 2 || err == nil {
+		t.Fatalf("WritePackets = %d, %v; want 2 and the third packet's error", n, err)
+	}
+	if _, err := WritePackets(c, pkts, dsts[:1]); err == nil {
+		t.Error("mismatched destinations should fail")
+	}
+
+	bufs := [][]byte{make([]byte, 8), make([]byte, 8)}
+	sizes := make([]int, 2)
+	n, err := ReadPackets(c, bufs, sizes, time.Now().Add(time.Second))
+	if err != nil || n != 1 || string(bufs[0][:sizes[0]]) != "one" {
+		t.Fatalf("ReadPackets = %d, %v (%q); want one packet per call without batching", n, err, bufs[0][:sizes[0]])
+	}
+}
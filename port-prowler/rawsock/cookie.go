@@ -0,0 +1,94 @@
+package rawsock
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"math/bits"
+	"net"
+)
+
+// Cookie derives SYN sequence numbers from the probe's addresses and
+// ports with keyed SipHash-2-4, so a reply can be matched to the probe it
+// answers (its ack is the cookie plus one) without remembering the probe.
+// Without the key a third party cannot forge matching replies.
+type Cookie struct {
+	k0, k1 uint64
+}
+
+// NewCookie returns a Cookie with a random key.
+func NewCookie() (Cookie, error) {
+	var key [16]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return Cookie{}, err
+	}
+	return CookieWithKey(key), nil
+}
+
+// CookieWithKey returns a Cookie for a fixed key, e.g. to match replies in
+// another process.
+func CookieWithKey(key [16]byte) Cookie {
+	return Cookie{k0: binary.LittleEndian.Uint64(key[:8]), k1: binary.LittleEndian.Uint64(key[8:])}
+}
+
+// Seq returns the sequence number for a SYN from src:srcPort to
+// dst:dstPort. Both addresses must be IPv4.
+func (c Cookie) Seq(src, dst net.IP, srcPort, dstPort uint16) uint32 {
+	var msg [12]byte
+	copy(msg[0:4], src.To4())
+	copy(msg[4:8], dst.To4())
+	binary.BigEndian.PutUint16(msg[8:10], srcPort)
+	binary.BigEndian.PutUint16(msg[10:12], dstPort)
+	return uint32(sipHash24(c.k0, c.k1, msg[:]))
+}
+
+// Match reports whether reply answers a SYN sent with this cookie: it
+// comes from the probed address and port and acknowledges Seq plus one.
+func (c Cookie) Match(reply TCPSegment) bool {
+	return reply.Ack == c.Seq(reply.Dst, reply.Src, reply.DstPort, reply.SrcPort)+1
+}
+
+// sipHash24 is SipHash-2-4 (Aumasson and Bernstein) of msg under the key
+// k0, k1.
+func sipHash24(k0, k1 uint64, msg []byte) uint64 {
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+	n := len(msg)
+	for ; len(msg) >= 8; msg = msg[8:] {
+		m := binary.LittleEndian.Uint64(msg)
+		v3 ^= m
+		round()
+		round()
+		v0 ^= m
+	}
+	var last [8]byte
+	copy(last[:], msg)
+	last[7] = byte(n)
+	m := binary.LittleEndian.Uint64(last[:])
+	v3 ^= m
+	round()
+	round()
+	v0 ^= m
+	v2 ^= 0xff
+	for i := 0; i < 4; i++ {
+		round()
+	}
+	return v0 ^ v1 ^ v2 ^ v3
+}
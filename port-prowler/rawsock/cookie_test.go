@@ -0,0 +1,48 @@
+package rawsock
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSipHash24(t *testing.T) {
+	// Test vector from the SipHash paper: key 00..0f, message 00..0e.
+	var key [16]byte
+	msg := make([]byte, 15)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+	c := CookieWithKey(key)
+	if got := sipHash24(c.k0, c.k1, msg); got != 0xa129ca6149be45e5 {
+		t.Fatalf("sipHash24 = %#x, want 0xa129ca6149be45e5", got)
+	}
+}
+
+func TestCookieMatch(t *testing.T) {
+	c, err := NewCookie()
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, target := net.ParseIP("192.0.2.1"), net.ParseIP("198.51.100.7")
+	seq := c.Seq(local, target, 40000, 443)
+	reply := TCPSegment{Src: target, Dst: local, SrcPort: 443, DstPort: 40000, Ack: seq + 1, Flags: FlagSYN | FlagACK}
+	if !c.Match(reply) {
+		t.Fatal("reply to the probe should match")
+	}
+	for name, r := range map[string]TCPSegment{
+		"other port":    {Src: target, Dst: local, SrcPort: 444, DstPort: 40000, Ack: seq + 1},
+		"other address": {Src: net.ParseIP("198.51.100.8"), Dst: local, SrcPort: 443, DstPort: 40000, Ack: seq + 1},
+		"wrong ack":     {Src: target, Dst: local, SrcPort: 443, DstPort: 40000, Ack: seq},
+	} {
+		if c.Match(r) {
+			t.Errorf("%s: should not match", name)
+		}
+	}
+	other, _ := NewCookie()
+	if other.Match(reply) {
+		t.Error("a cookie with another key should not match")
+	}
+}
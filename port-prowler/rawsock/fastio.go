@@ -0,0 +1,11 @@
+//go:build !linux || !fastio
+// +build !linux !fastio
+
+package rawsock
+
+// FastIO reports whether this build has the PACKET_MMAP path.
+const FastIO = false
+
+func openFast(opts Options) (Conn, error) {
+	return nil, ErrNoFastIO
+}
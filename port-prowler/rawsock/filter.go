@@ -0,0 +1,139 @@
+package rawsock
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+// Filter restricts what a Conn receives to replies to a scan, so the kernel
+// drops unrelated traffic instead of copying every TCP segment of a busy
+// interface to userspace.
+type Filter struct {
+	// Port is the scan's source port: only segments sent to it pass.
+	Port uint16
+	// Sources, when not empty, are the only IPv4 addresses segments may
+	// come from. Sets larger than MaxFilterSources are not checked in the
+	// kernel.
+	Sources []net.IP
+}
+
+// MaxFilterSources is the largest source set compiled into a filter; the
+// program needs two instructions per address and the kernel caps its size.
+const MaxFilterSources = 1024
+
+// Filterer is implemented by Conns that can install a kernel filter.
+type Filterer interface {
+	SetFilter(f Filter) error
+}
+
+// ErrNoFilter is returned by SetFilter for Conns without kernel filtering.
+var ErrNoFilter = errors.New("rawsock: kernel packet filters are not supported here")
+
+// SetFilter installs f on c. Filtering is an optimisation: callers must
+// still check what they read, e.g. after an ErrNoFilter.
+func SetFilter(c Conn, f Filter) error {
+	if fc, ok := c.(Filterer); ok {
+		return fc.SetFilter(f)
+	}
+	return ErrNoFilter
+}
+
+// Classic BPF opcodes, shared by Linux socket filters and BSD bpf(4).
+const (
+	bpfLD   = 0x00
+	bpfLDX  = 0x01
+	bpfALU  = 0x04
+	bpfJMP  = 0x05
+	bpfRET  = 0x06
+	bpfW    = 0x00
+	bpfH    = 0x08
+	bpfB    = 0x10
+	bpfABS  = 0x20
+	bpfIND  = 0x40
+	bpfMSH  = 0xa0
+	bpfAND  = 0x50
+	bpfJEQ  = 0x10
+	bpfJSET = 0x40
+	bpfK    = 0x00
+)
+
+// bpfAccept is the snapshot length returned for accepted packets.
+const bpfAccept = 0x40000
+
+type bpfInsn struct {
+	code   uint16
+	jt, jf uint8
+	k      uint32
+}
+
+// program compiles f for packets whose IPv4 header starts at offset off;
+// with ether set the packet is an Ethernet frame checked for the IPv4
+// ethertype first.
+func (f Filter) program(off uint32, ether bool) []bpfInsn {
+	var p []bpfInsn
+	// Failed checks jump to a "ret 0" right after them, so the jumps stay
+	// short however long the source list is.
+	type check struct {
+		at         int
+		dropOnTrue bool
+	}
+	var checks []check
+	test := func(i bpfInsn, dropOnTrue bool) {
+		checks = append(checks, check{len(p), dropOnTrue})
+		p = append(p, i)
+	}
+	if ether {
+		p = append(p, bpfInsn{code: bpfLD | bpfH | bpfABS, k: 12})
+		test(bpfInsn{code: bpfJMP | bpfJEQ | bpfK, k: 0x0800}, false)
+	}
+	p = append(p,
+		bpfInsn{code: bpfLD | bpfB | bpfABS, k: off},
+		bpfInsn{code: bpfALU | bpfAND | bpfK, k: 0xf0},
+	)
+	test(bpfInsn{code: bpfJMP | bpfJEQ | bpfK, k: 0x40}, false) // IPv4
+	p = append(p, bpfInsn{code: bpfLD | bpfB | bpfABS, k: off + 9})
+	test(bpfInsn{code: bpfJMP | bpfJEQ | bpfK, k: protoTCP}, false)
+	// Later fragments carry no TCP header.
+	p = append(p, bpfInsn{code: bpfLD | bpfH | bpfABS, k: off + 6})
+	test(bpfInsn{code: bpfJMP | bpfJSET | bpfK, k: 0x1fff}, true)
+	p = append(p,
+		bpfInsn{code: bpfLDX | bpfB | bpfMSH, k: off},    // X = IP header length
+		bpfInsn{code: bpfLD | bpfH | bpfIND, k: off + 2}, // TCP destination port
+	)
+	test(bpfInsn{code: bpfJMP | bpfJEQ | bpfK, k: uint32(f.Port)}, false)
+	reject := len(p)
+	p = append(p, bpfInsn{code: bpfRET | bpfK, k: 0})
+	for n, c := range checks {
+		pass := uint8(0)
+		if n == len(checks)-1 {
+			pass = 1 // over the "ret 0"
+		}
+		fail := uint8(reject - c.at - 1)
+		if c.dropOnTrue {
+			p[c.at].jt, p[c.at].jf = fail, pass
+		} else {
+			p[c.at].jt, p[c.at].jf = pass, fail
+		}
+	}
+
+	var sources []uint32
+	if len(f.Sources) <= MaxFilterSources {
+		for _, src := range f.Sources {
+			if ip := src.To4(); ip != nil {
+				sources = append(sources, binary.BigEndian.Uint32(ip))
+			}
+		}
+	}
+	if len(sources) == 0 {
+		return append(p, bpfInsn{code: bpfRET | bpfK, k: bpfAccept})
+	}
+	p = append(p, bpfInsn{code: bpfLD | bpfW | bpfABS, k: off + 12})
+	for _, ip := range sources {
+		p = append(p,
+			bpfInsn{code: bpfJMP | bpfJEQ | bpfK, jt: 0, jf: 1, k: ip},
+			bpfInsn{code: bpfRET | bpfK, k: bpfAccept},
+		)
+	}
+	return append(p, bpfInsn{code: bpfRET | bpfK, k: 0})
+}
@@ -0,0 +1,141 @@
+package rawsock
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// runBPF interprets the instructions program emits; out-of-range loads
+// reject the packet like the kernel does.
+func runBPF(t *testing.T, prog []bpfInsn, pkt []byte) uint32 {
+	t.Helper()
+	var a, x uint32
+	for pc := 0; pc < len(prog); pc++ {
+		in := prog[pc]
+		load := func(at uint32, size int) (uint32, bool) {
+			if int(at)+size > len(pkt) {
+				return 0, false
+			}
+			switch size {
+			case 1:
+				return uint32(pkt[at]), true
+			case 2:
+				return uint32(binary.BigEndian.Uint16(pkt[at:])), true
+			}
+			return binary.BigEndian.Uint32(pkt[at:]), true
+		}
+		size := map[uint16]int{bpfW: 4, bpfH: 2, bpfB: 1}[in.code&0x18]
+		var ok bool
+		switch in.code {
+		case bpfLD | bpfW | bpfABS, bpfLD | bpfH | bpfABS, bpfLD | bpfB | bpfABS:
+			if a, ok = load(in.k, size); !ok {
+				return 0
+			}
+		case bpfLD | bpfH | bpfIND:
+			if a, ok = load(x+in.k, size); !ok {
+				return 0
+			}
+		case bpfLDX | bpfB | bpfMSH:
+			if x, ok = load(in.k, 1); !ok {
+				return 0
+			}
+			x = (x & 0x0f) * 4
+		case bpfALU | bpfAND | bpfK:
+			a &= in.k
+		case bpfJMP | bpfJEQ | bpfK, bpfJMP | bpfJSET | bpfK:
+			taken := a == in.k
+			if in.code&0xf0 == bpfJSET {
+				taken = a&in.k != 0
+			}
+			if taken {
+				pc += int(in.jt)
+			} else {
+				pc += int(in.jf)
+			}
+		case bpfRET | bpfK:
+			return in.k
+		default:
+			t.Fatalf("instruction %d: unexpected opcode %#x", pc, in.code)
+		}
+	}
+	t.Fatal("program ran off its end")
+	return 0
+}
+
+func TestFilterProgram(t *testing.T) {
+	local, target := net.ParseIP("192.0.2.1"), net.ParseIP("198.51.100.7")
+	reply := func(src net.IP, dstPort uint16) []byte {
+		pkt, err := TCPSegment{Src: src, Dst: local, SrcPort: 443, DstPort: dstPort, Flags: FlagSYN | FlagACK}.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pkt
+	}
+	f := Filter{Port: 40000, Sources: []net.IP{net.ParseIP("198.51.100.1"), target}}
+	prog := f.program(0, false)
+
+	udp := reply(target, 40000)
+	udp[9] = 17
+	frag := reply(target, 40000)
+	binary.BigEndian.PutUint16(frag[6:8], 10) // fragment offset 80
+	for name, tc := range map[string]struct {
+		pkt  []byte
+		pass bool
+	}{
+		"reply":        {reply(target, 40000), true},
+		"other port":   {reply(target, 40001), false},
+		"other source": {reply(net.ParseIP("203.0.113.9"), 40000), false},
+		"not tcp":      {udp, false},
+		"later frag":   {frag, false},
+		"truncated":    {reply(target, 40000)[:22], false},
+		"empty":        {nil, false},
+		"ipv6 version": {append([]byte{0x60}, reply(target, 40000)[1:]...), false},
+	} {
+		if got := runBPF(t, prog, tc.pkt) != 0; got != tc.pass {
+			t.Errorf("%s: passed = %v, want %v", name, got, tc.pass)
+		}
+	}
+
+	// Ethernet frames are checked for the IPv4 ethertype first.
+	frame := append(make([]byte, 14), reply(target, 40000)...)
+	if runBPF(t, f.program(14, true), frame) != 0 {
+		t.Error("frame without the IPv4 ethertype should be rejected")
+	}
+	binary.BigEndian.PutUint16(frame[12:14], 0x0800)
+	if runBPF(t, f.program(14, true), frame) == 0 {
+		t.Error("IPv4 frame should pass")
+	}
+
+	// Without a usable source list only the port is checked.
+	many := Filter{Port: 40000, Sources: make([]net.IP, MaxFilterSources+1)}
+	for i := range many.Sources {
+		many.Sources[i] = net.IPv4(10, 0, byte(i>>8), byte(i))
+	}
+	for _, g := range []Filter{{Port: 40000}, many} {
+		if runBPF(t, g.program(0, false), reply(net.ParseIP("203.0.113.9"), 40000)) == 0 {
+			t.Errorf("%d sources: reply from any address should pass", len(g.Sources))
+		}
+	}
+}
+
+func TestFilterProgramJumps(t *testing.T) {
+	f := Filter{Port: 1, Sources: make([]net.IP, MaxFilterSources)}
+	for i := range f.Sources {
+		f.Sources[i] = net.IPv4(10, 0, byte(i>>8), byte(i))
+	}
+	for _, ether := range []bool{false, true} {
+		prog := f.program(14, ether)
+		if len(prog) > 4096 {
+			t.Fatalf("program has %d instructions, more than the kernel accepts", len(prog))
+		}
+		for i, in := range prog {
+			if in.code&0x07 != bpfJMP {
+				continue
+			}
+			if i+1+int(in.jt) >= len(prog) || i+1+int(in.jf) >= len(prog) {
+				t.Fatalf("instruction %d jumps past the end", i)
+			}
+		}
+	}
+}
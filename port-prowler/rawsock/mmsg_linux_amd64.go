@@ -0,0 +1,7 @@
+package rawsock
+
+// Package syscall lacks these numbers on amd64.
+const (
+	sysSendmmsg = 307
+	sysRecvmmsg = 299
+)
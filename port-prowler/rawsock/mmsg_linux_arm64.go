@@ -0,0 +1,6 @@
+package rawsock
+
+const (
+	sysSendmmsg = 269
+	sysRecvmmsg = 243
+)
@@ -0,0 +1,164 @@
+//go:build linux && fastio
+// +build linux,fastio
+
+package rawsock
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nextHopRetry is how long a destination without a resolved link address
+// keeps using the fallback socket before the tables are read again.
+const nextHopRetry = time.Second
+
+// nextHops finds the link address of the next hop towards a destination
+// on one interface: the destination itself when it is on-link, otherwise
+// the gateway of the most specific route, looked up in the neighbour
+// (ARP) table.
+type nextHops struct {
+	ifc    *net.Interface
+	macs   map[[4]byte]net.HardwareAddr
+	misses map[[4]byte]time.Time
+}
+
+func newNextHops(ifc *net.Interface) *nextHops {
+	return &nextHops{
+		ifc:    ifc,
+		macs:   make(map[[4]byte]net.HardwareAddr),
+		misses: make(map[[4]byte]time.Time),
+	}
+}
+
+// lookup returns the destination MAC for frames to dst. Callers serialise
+// calls.
+func (h *nextHops) lookup(dst net.IP) (net.HardwareAddr, bool) {
+	ip4 := dst.To4()
+	if ip4 == nil {
+		return nil, false
+	}
+	key := [4]byte(ip4)
+	if mac, ok := h.macs[key]; ok {
+		return mac, true
+	}
+	if t, ok := h.misses[key]; ok && time.Since(t) < nextHopRetry {
+		return nil, false
+	}
+	mac := h.resolve(ip4)
+	if mac == nil {
+		h.misses[key] = time.Now()
+		return nil, false
+	}
+	delete(h.misses, key)
+	h.macs[key] = mac
+	return mac, true
+}
+
+func (h *nextHops) resolve(dst net.IP) net.HardwareAddr {
+	hop := dst
+	if !h.onLink(dst) {
+		f, err := os.Open("/proc/net/route")
+		if err != nil {
+			return nil
+		}
+		gw := routeGateway(f, h.ifc.Name, dst)
+		f.Close()
+		if gw == nil {
+			return nil
+		}
+		hop = gw
+	}
+	f, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	return arpLookup(f, h.ifc.Name, hop)
+}
+
+func (h *nextHops) onLink(dst net.IP) bool {
+	addrs, err := h.ifc.Addrs()
+	if err != nil {
+		return false
+	}
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && n.Contains(dst) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeGateway returns the gateway of the most specific route to dst
+// through iface in a /proc/net/route table, or nil for on-link routes and
+// no match. Addresses there are hex in host (little-endian) byte order.
+func routeGateway(r io.Reader, iface string, dst net.IP) net.IP {
+	d := binary.BigEndian.Uint32(dst.To4())
+	var gw net.IP
+	best := -1
+	sc := bufio.NewScanner(r)
+	sc.Scan() // header
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 8 || fields[0] != iface {
+			continue
+		}
+		dest, err1 := procAddr(fields[1])
+		gateway, err2 := procAddr(fields[2])
+		mask, err3 := procAddr(fields[7])
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		m := binary.BigEndian.Uint32(mask)
+		ones, _ := net.IPMask(mask).Size()
+		if d&m != binary.BigEndian.Uint32(dest)&m || ones <= best {
+			continue
+		}
+		best = ones
+		gw = nil
+		if !gateway.Equal(net.IPv4zero) {
+			gw = gateway
+		}
+	}
+	return gw
+}
+
+func procAddr(s string) (net.IP, error) {
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return nil, err
+	}
+	ip := make(net.IP, 4)
+	binary.LittleEndian.PutUint32(ip, uint32(v))
+	return ip, nil
+}
+
+// arpLookup returns the link address of ip on iface from a /proc/net/arp
+// table, or nil when the entry is missing or incomplete.
+func arpLookup(r io.Reader, iface string, ip net.IP) net.HardwareAddr {
+	sc := bufio.NewScanner(r)
+	sc.Scan() // header
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 6 || fields[5] != iface || !net.ParseIP(fields[0]).Equal(ip) {
+			continue
+		}
+		flags, err := strconv.ParseUint(strings.TrimPrefix(fields[2], "0x"), 16, 32)
+		if err != nil || flags&0x2 == 0 { // ATF_COM: resolved
+			return nil
+		}
+		mac, err := net.ParseMAC(fields[3])
+		if err != nil || len(mac) != 6 || bytes.Equal(mac, make([]byte, 6)) {
+			return nil
+		}
+		return mac
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package rawsock
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// TCP flag bits.
+const (
+	FlagFIN uint8 = 0x01
+	FlagSYN uint8 = 0x02
+	FlagRST uint8 = 0x04
+	FlagPSH uint8 = 0x08
+	FlagACK uint8 = 0x10
+)
+
+const (
+	ipv4HeaderLen = 20
+	tcpHeaderLen  = 20
+	protoTCP      = 6
+)
+
+// TCPSegment is an IPv4 TCP segment without payload, as sent and received
+// by the stealth scanner.
+type TCPSegment struct {
+	Src, Dst         net.IP
+	SrcPort, DstPort uint16
+	Seq, Ack         uint32
+	Flags            uint8
+	Window           uint16
+	TTL              uint8 // 0 selects 64 when marshalling
+}
+
+// Marshal encodes the segment as a complete IPv4 packet with valid IP and
+// TCP checksums. SYNs carry an MSS option like a regular connection attempt.
+func (s TCPSegment) Marshal() ([]byte, error) {
+	src, dst := s.Src.To4(), s.Dst.To4()
+	if src == nil || dst == nil {
+		return nil, errors.New("rawsock: only IPv4 addresses are supported")
+	}
+	var opts []byte
+	if s.Flags&FlagSYN != 0 {
+		opts = []byte{0x02, 0x04, 0x05, 0xb4} // MSS 1460
+	}
+	tcpLen := tcpHeaderLen + len(opts)
+	pkt := make([]byte, ipv4HeaderLen+tcpLen)
+
+	ttl := s.TTL
+	if ttl == 0 {
+		ttl = 64
+	}
+	var id [2]byte
+	_, _ = rand.Read(id[:])
+	ip := pkt[:ipv4HeaderLen]
+	ip[0] = 0x45 // version 4, IHL 5
+	binary.BigEndian.PutUint16(ip[2:4], uint16(len(pkt)))
+	copy(ip[4:6], id[:])
+	ip[8] = ttl
+	ip[9] = protoTCP
+	copy(ip[12:16], src)
+	copy(ip[16:20], dst)
+	binary.BigEndian.PutUint16(ip[10:12], Checksum(ip))
+
+	tcp := pkt[ipv4HeaderLen:]
+	binary.BigEndian.PutUint16(tcp[0:2], s.SrcPort)
+	binary.BigEndian.PutUint16(tcp[2:4], s.DstPort)
+	binary.BigEndian.PutUint32(tcp[4:8], s.Seq)
+	binary.BigEndian.PutUint32(tcp[8:12], s.Ack)
+	tcp[12] = byte(tcpLen/4) << 4
+	tcp[13] = s.Flags
+	win := s.Window
+	if win == 0 {
+		win = 1024
+	}
+	binary.BigEndian.PutUint16(tcp[14:16], win)
+	copy(tcp[tcpHeaderLen:], opts)
+	binary.BigEndian.PutUint16(tcp[16:18], tcpChecksum(src, dst, tcp))
+	return pkt, nil
+}
+
+// ParseTCP decodes an IPv4 packet carrying TCP. Options and payload are ignored.
+func ParseTCP(pkt []byte) (TCPSegment, error) {
+	var s TCPSegment
+	if len(pkt) < ipv4HeaderLen {
+		return s, errors.New("rawsock: short ipv4 packet")
+	}
+	if pkt[0]>>4 != 4 {
+		return s, fmt.Errorf("rawsock: not ipv4 (version %d)", pkt[0]>>4)
+	}
+	ihl := int(pkt[0]&0x0f) * 4
+	if ihl < ipv4HeaderLen || len(pkt) < ihl+tcpHeaderLen {
+		return s, errors.New("rawsock: truncated packet")
+	}
+	if pkt[9] != protoTCP {
+		return s, fmt.Errorf("rawsock: not tcp (protocol %d)", pkt[9])
+	}
+	tcp := pkt[ihl:]
+	s.Src = net.IP(append([]byte(nil), pkt[12:16]...))
+	s.Dst = net.IP(append([]byte(nil), pkt[16:20]...))
+	s.TTL = pkt[8]
+	s.SrcPort = binary.BigEndian.Uint16(tcp[0:2])
+	s.DstPort = binary.BigEndian.Uint16(tcp[2:4])
+	s.Seq = binary.BigEndian.Uint32(tcp[4:8])
+	s.Ack = binary.BigEndian.Uint32(tcp[8:12])
+	s.Flags = tcp[13]
+	s.Window = binary.BigEndian.Uint16(tcp[14:16])
+	return s, nil
+}
+
+// Checksum computes the Internet checksum (RFC 1071) of b.
+func Checksum(b []byte) uint16 {
+	return finish(sum(0, b))
+}
+
+func tcpChecksum(src, dst net.IP, seg []byte) uint16 {
+	var pseudo [12]byte
+	copy(pseudo[0:4], src)
+	copy(pseudo[4:8], dst)
+	pseudo[9] = protoTCP
+	binary.BigEndian.PutUint16(pseudo[10:12], uint16(len(seg)))
+	return finish(sum(sum(0, pseudo[:]), seg))
+}
+
+func sum(acc uint32, b []byte) uint32 {
+	for i := 0; i+1 < len(b); i += 2 {
+		acc += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		acc += uint32(b[len(b)-1]) << 8
+	}
+	return acc
+}
+
+func finish(acc uint32) uint16 {
+	for acc>>16 != 0 {
+		acc = (acc & 0xffff) + (acc >> 16)
+	}
+	return ^uint16(acc)
+}
@@ -0,0 +1,54 @@
+package rawsock
+
+import (
+	"net"
+	"testing"
+)
+
+func TestTCPSegmentRoundTrip(t *testing.T) {
+	in := TCPSegment{
+		Src:     net.ParseIP("192.0.2.1"),
+		Dst:     net.ParseIP("198.51.100.7"),
+		SrcPort: 40000,
+		DstPort: 443,
+		Seq:     0xdeadbeef,
+		Flags:   FlagSYN,
+	}
+	pkt, err := in.Marshal()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if len(pkt) != 44 {
+		t.Fatalf("SYN packet length = %d, want 44 (ip + tcp + mss)", len(pkt))
+	}
+	if Checksum(pkt[:20]) != 0 {
+		t.Fatalf("ip header checksum does not verify")
+	}
+	if tcpChecksum(pkt[12:16], pkt[16:20], pkt[20:]) != 0 {
+		t.Fatalf("tcp checksum does not verify")
+	}
+
+	out, err := ParseTCP(pkt)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !out.Src.Equal(in.Src) || !out.Dst.Equal(in.Dst) || out.SrcPort != in.SrcPort ||
+		out.DstPort != in.DstPort || out.Seq != in.Seq || out.Flags != FlagSYN || out.TTL != 64 {
+		t.Fatalf("round trip mismatch: %+v", out)
+	}
+}
+
+func TestParseTCP_Rejects(t *testing.T) {
+	if _, err := ParseTCP([]byte{0x45}); err == nil {
+		t.Fatalf("expected error for short packet")
+	}
+	udp := make([]byte, 40)
+	udp[0] = 0x45
+	udp[9] = 17
+	if _, err := ParseTCP(udp); err == nil {
+		t.Fatalf("expected error for non-tcp packet")
+	}
+	if _, err := (TCPSegment{Src: net.ParseIP("::1"), Dst: net.ParseIP("::2")}).Marshal(); err == nil {
+		t.Fatalf("expected error for ipv6 addresses")
+	}
+}
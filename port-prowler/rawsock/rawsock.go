@@ -0,0 +1,57 @@
+// Package rawsock is the raw IPv4 send/receive layer used by stealth (SYN)
+// scanning. Packets are sent through a raw socket with a caller-built IP
+// header. Receiving uses a raw TCP socket on Linux and a BPF device on
+// macOS/BSD, whose kernels do not deliver TCP segments to raw sockets.
+package rawsock
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// ErrUnsupported is returned by Open on platforms without a raw socket layer.
+var ErrUnsupported = errors.New("rawsock: raw sockets are not supported on this platform")
+
+// ErrNoFastIO is returned by Open for Options.FastIO in builds without the
+// PACKET_MMAP path (see FastIO).
+var ErrNoFastIO = errors.New("rawsock: fast I/O needs a Linux build with -tags fastio")
+
+// Conn sends and receives raw IPv4 packets.
+type Conn interface {
+	// WritePacket sends a complete IPv4 packet (header included) to dst.
+	WritePacket(pkt []byte, dst net.IP) error
+	// ReadPacket reads the next received IPv4 packet into buf, starting at
+	// the IP header. It returns an error satisfying
+	// errors.Is(err, os.ErrDeadlineExceeded) once deadline passes.
+	ReadPacket(buf []byte, deadline time.Time) (int, error)
+	// LocalIP is the source address to put into packets sent to the target.
+	LocalIP() net.IP
+	Close() error
+}
+
+// Options configures Open.
+type Options struct {
+	// Target is used to select the source address and, for BPF, the
+	// capture interface.
+	Target net.IP
+	// Interface overrides the capture interface (BPF and FastIO only).
+	Interface string
+	// FastIO sends and receives through PACKET_MMAP rings on the
+	// interface instead of raw IP sockets: batches of packets cost one
+	// system call and replies are read from memory shared with the
+	// kernel. Only Linux builds with the fastio tag support it.
+	FastIO bool
+}
+
+// Open returns a raw Conn for the current platform. It requires raw socket
+// privileges (see netutil.CanOpenRawSocket).
+func Open(opts Options) (Conn, error) {
+	if opts.Target.To4() == nil {
+		return nil, errors.New("rawsock: target must be an IPv4 address")
+	}
+	if opts.FastIO {
+		return openFast(opts)
+	}
+	return open(opts)
+}
@@ -0,0 +1,256 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+// +build darwin freebsd netbsd openbsd dragonfly
+
+package rawsock
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"portprowler/netutil"
+)
+
+// bsdConn sends through a raw IP socket and captures replies with BPF,
+// since BSD kernels never hand TCP segments to raw sockets.
+type bsdConn struct {
+	send  int
+	bpf   int
+	local net.IP
+	dlt   int
+
+	mu      sync.Mutex
+	buf     []byte // BPF read buffer (must match BIOCGBLEN)
+	pending []byte // unconsumed records from the last read
+}
+
+// bpfPollInterval bounds how long a single BPF read blocks so that
+// ReadPacket can honour its deadline.
+const bpfPollInterval = 100 * time.Millisecond
+
+func open(opts Options) (Conn, error) {
+	route, err := netutil.RouteTo(opts.Target)
+	if err != nil {
+		return nil, err
+	}
+	ifname := opts.Interface
+	if ifname == "" {
+		ifname = route.Interface
+	}
+	if ifname == "" {
+		return nil, fmt.Errorf("rawsock: no capture interface for %s", opts.Target)
+	}
+
+	sfd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_RAW)
+	if err != nil {
+		return nil, fmt.Errorf("rawsock: send socket: %w", err)
+	}
+	if err := syscall.SetsockoptInt(sfd, syscall.IPPROTO_IP, syscall.IP_HDRINCL, 1); err != nil {
+		_ = syscall.Close(sfd)
+		return nil, fmt.Errorf("rawsock: IP_HDRINCL: %w", err)
+	}
+
+	bfd, err := openBPF()
+	if err != nil {
+		_ = syscall.Close(sfd)
+		return nil, err
+	}
+	c := &bsdConn{send: sfd, bpf: bfd, local: route.Src.To4()}
+	if err := c.setup(ifname); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// openBPF opens the cloning /dev/bpf device or the first free /dev/bpfN.
+func openBPF() (int, error) {
+	if fd, err := syscall.Open("/dev/bpf", syscall.O_RDWR, 0); err == nil {
+		return fd, nil
+	}
+	for i := 0; i < 256; i++ {
+		fd, err := syscall.Open(fmt.Sprintf("/dev/bpf%d", i), syscall.O_RDWR, 0)
+		if err == nil {
+			return fd, nil
+		}
+		if err != syscall.EBUSY {
+			return -1, fmt.Errorf("rawsock: open /dev/bpf%d: %w", i, err)
+		}
+	}
+	return -1, errors.New("rawsock: no free bpf device")
+}
+
+func ioctl(fd int, req uint, arg unsafe.Pointer) error {
+	if _, _, e := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg)); e != 0 {
+		return e
+	}
+	return nil
+}
+
+func (c *bsdConn) setup(ifname string) error {
+	var ifr struct {
+		Name [syscall.IFNAMSIZ]byte
+		_    [16]byte
+	}
+	copy(ifr.Name[:], ifname)
+	if err := ioctl(c.bpf, syscall.BIOCSETIF, unsafe.Pointer(&ifr)); err != nil {
+		return fmt.Errorf("rawsock: BIOCSETIF %s: %w", ifname, err)
+	}
+	one := uint32(1)
+	if err := ioctl(c.bpf, syscall.BIOCIMMEDIATE, unsafe.Pointer(&one)); err != nil {
+		return fmt.Errorf("rawsock: BIOCIMMEDIATE: %w", err)
+	}
+	tv := syscall.NsecToTimeval(bpfPollInterval.Nanoseconds())
+	if err := ioctl(c.bpf, syscall.BIOCSRTIMEOUT, unsafe.Pointer(&tv)); err != nil {
+		return fmt.Errorf("rawsock: BIOCSRTIMEOUT: %w", err)
+	}
+	var blen uint32
+	if err := ioctl(c.bpf, syscall.BIOCGBLEN, unsafe.Pointer(&blen)); err != nil {
+		return fmt.Errorf("rawsock: BIOCGBLEN: %w", err)
+	}
+	var dlt uint32
+	if err := ioctl(c.bpf, syscall.BIOCGDLT, unsafe.Pointer(&dlt)); err != nil {
+		return fmt.Errorf("rawsock: BIOCGDLT: %w", err)
+	}
+	switch dlt {
+	case syscall.DLT_EN10MB, syscall.DLT_NULL, syscall.DLT_LOOP, syscall.DLT_RAW:
+	default:
+		return fmt.Errorf("rawsock: unsupported link type %d on %s", dlt, ifname)
+	}
+	c.dlt = int(dlt)
+	c.buf = make([]byte, blen)
+	return nil
+}
+
+func (c *bsdConn) WritePacket(pkt []byte, dst net.IP) error {
+	if runtime.GOOS == "darwin" {
+		// Darwin expects ip_len and ip_off in host byte order with IP_HDRINCL.
+		p := append([]byte(nil), pkt...)
+		binary.LittleEndian.PutUint16(p[2:4], binary.BigEndian.Uint16(pkt[2:4]))
+		binary.LittleEndian.PutUint16(p[6:8], binary.BigEndian.Uint16(pkt[6:8]))
+		pkt = p
+	}
+	sa := &syscall.SockaddrInet4{}
+	copy(sa.Addr[:], dst.To4())
+	return syscall.Sendto(c.send, pkt, 0, sa)
+}
+
+func (c *bsdConn) ReadPacket(buf []byte, deadline time.Time) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		for len(c.pending) > 0 {
+			if n, ok := c.nextRecord(buf); ok {
+				return n, nil
+			}
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		n, err := syscall.Read(c.bpf, c.buf)
+		if err != nil {
+			if err == syscall.EINTR || err == syscall.EAGAIN {
+				continue
+			}
+			return 0, err
+		}
+		c.pending = c.buf[:n]
+	}
+}
+
+// nextRecord consumes one BPF record from c.pending and copies its IPv4
+// packet into buf. It returns false for records that are not IPv4.
+func (c *bsdConn) nextRecord(buf []byte) (int, bool) {
+	var hdr syscall.BpfHdr
+	if len(c.pending) < int(unsafe.Sizeof(hdr)) {
+		c.pending = nil
+		return 0, false
+	}
+	hdr = *(*syscall.BpfHdr)(unsafe.Pointer(&c.pending[0]))
+	start := int(hdr.Hdrlen)
+	end := start + int(hdr.Caplen)
+	if end > len(c.pending) {
+		c.pending = nil
+		return 0, false
+	}
+	frame := c.pending[start:end]
+	next := bpfWordAlign(end)
+	if next >= len(c.pending) {
+		c.pending = nil
+	} else {
+		c.pending = c.pending[next:]
+	}
+
+	var pkt []byte
+	switch c.dlt {
+	case syscall.DLT_EN10MB:
+		if len(frame) < 14 || binary.BigEndian.Uint16(frame[12:14]) != 0x0800 {
+			return 0, false
+		}
+		pkt = frame[14:]
+	case syscall.DLT_NULL, syscall.DLT_LOOP:
+		if len(frame) < 4 {
+			return 0, false
+		}
+		pkt = frame[4:]
+	default:
+		pkt = frame
+	}
+	if len(pkt) == 0 || pkt[0]>>4 != 4 {
+		return 0, false
+	}
+	return copy(buf, pkt), true
+}
+
+// bpfWordAlign mirrors BPF_WORDALIGN: records are aligned to sizeof(long)
+// on FreeBSD/NetBSD/DragonFly and to 4 bytes on Darwin/OpenBSD.
+func bpfWordAlign(x int) int {
+	align := int(unsafe.Sizeof(uintptr(0)))
+	if runtime.GOOS == "darwin" || runtime.GOOS == "openbsd" {
+		align = 4
+	}
+	return (x + align - 1) &^ (align - 1)
+}
+
+// SetFilter installs f on the BPF device, skipping the capture's link
+// header.
+func (c *bsdConn) SetFilter(f Filter) error {
+	var prog []bpfInsn
+	switch c.dlt {
+	case syscall.DLT_EN10MB:
+		prog = f.program(14, true)
+	case syscall.DLT_NULL, syscall.DLT_LOOP:
+		prog = f.program(4, false)
+	default:
+		prog = f.program(0, false)
+	}
+	insns := make([]syscall.BpfInsn, len(prog))
+	for i, in := range prog {
+		insns[i] = syscall.BpfInsn{Code: in.code, Jt: in.jt, Jf: in.jf, K: in.k}
+	}
+	bp := syscall.BpfProgram{Len: uint32(len(insns)), Insns: &insns[0]}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := ioctl(c.bpf, syscall.BIOCSETF, unsafe.Pointer(&bp)); err != nil {
+		return fmt.Errorf("rawsock: BIOCSETF: %w", err)
+	}
+	c.pending = nil // captured under the old filter
+	return nil
+}
+
+func (c *bsdConn) LocalIP() net.IP { return c.local }
+
+func (c *bsdConn) Close() error {
+	err := syscall.Close(c.bpf)
+	if cerr := syscall.Close(c.send); err == nil {
+		err = cerr
+	}
+	return err
+}
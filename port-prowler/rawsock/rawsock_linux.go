@@ -0,0 +1,92 @@
+//go:build linux
+// +build linux
+
+package rawsock
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"portprowler/netutil"
+)
+
+type linuxConn struct {
+	send  int      // IPPROTO_RAW socket (IP_HDRINCL implied)
+	recv  *os.File // IPPROTO_TCP raw socket, pollable for deadlines
+	local net.IP
+}
+
+func open(opts Options) (Conn, error) {
+	route, err := netutil.RouteTo(opts.Target)
+	if err != nil {
+		return nil, err
+	}
+	sfd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_RAW)
+	if err != nil {
+		return nil, fmt.Errorf("rawsock: send socket: %w", err)
+	}
+	rfd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_TCP)
+	if err != nil {
+		_ = syscall.Close(sfd)
+		return nil, fmt.Errorf("rawsock: receive socket: %w", err)
+	}
+	// Non-blocking so the runtime poller handles read deadlines.
+	if err := syscall.SetNonblock(rfd, true); err != nil {
+		_ = syscall.Close(sfd)
+		_ = syscall.Close(rfd)
+		return nil, fmt.Errorf("rawsock: set nonblock: %w", err)
+	}
+	return &linuxConn{
+		send:  sfd,
+		recv:  os.NewFile(uintptr(rfd), "rawsock-recv"),
+		local: route.Src.To4(),
+	}, nil
+}
+
+func (c *linuxConn) WritePacket(pkt []byte, dst net.IP) error {
+	sa := &syscall.SockaddrInet4{}
+	copy(sa.Addr[:], dst.To4())
+	return syscall.Sendto(c.send, pkt, 0, sa)
+}
+
+func (c *linuxConn) ReadPacket(buf []byte, deadline time.Time) (int, error) {
+	if err := c.recv.SetReadDeadline(deadline); err != nil {
+		return 0, err
+	}
+	return c.recv.Read(buf)
+}
+
+// SetFilter attaches f to the receive socket as a classic BPF socket
+// filter; packets there start at the IP header.
+func (c *linuxConn) SetFilter(f Filter) error {
+	prog := f.program(0, false)
+	insns := make([]syscall.SockFilter, len(prog))
+	for i, in := range prog {
+		insns[i] = syscall.SockFilter{Code: in.code, Jt: in.jt, Jf: in.jf, K: in.k}
+	}
+	rc, err := c.recv.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var aerr error
+	if err := rc.Control(func(fd uintptr) { aerr = syscall.AttachLsf(int(fd), insns) }); err != nil {
+		return err
+	}
+	if aerr != nil {
+		return fmt.Errorf("rawsock: attach filter: %w", aerr)
+	}
+	return nil
+}
+
+func (c *linuxConn) LocalIP() net.IP { return c.local }
+
+func (c *linuxConn) Close() error {
+	err := c.recv.Close()
+	if cerr := syscall.Close(c.send); err == nil {
+		err = cerr
+	}
+	return err
+}
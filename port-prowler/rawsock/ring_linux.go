@@ -0,0 +1,384 @@
+//go:build linux && fastio
+// +build linux,fastio
+
+package rawsock
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"portprowler/netutil"
+)
+
+// FastIO reports whether this build has the PACKET_MMAP path.
+const FastIO = true
+
+// Packet socket options package syscall does not define.
+const (
+	packetVersion        = 10
+	packetTXRing         = 13
+	packetQdiscBypass    = 20
+	packetIgnoreOutgoing = 23
+	tpacketV2            = 1
+)
+
+// tpacket2_hdr status bits.
+const (
+	tpStatusKernel      = 0
+	tpStatusUser        = 1
+	tpStatusAvailable   = 0
+	tpStatusSendRequest = 1
+	tpStatusWrongFormat = 4
+)
+
+// Ring geometry. Frames hold the tpacket2 header, the sockaddr_ll the
+// kernel appends on receive and an IP packet; scan traffic is headers only.
+const (
+	ringFrame    = 2048
+	ringBlock    = 1 << 16
+	ringRXBlocks = 64 // 2048 frames, 4 MiB
+	ringTXBlocks = 16 // 512 frames, 1 MiB
+
+	tpacket2Hdr = 32                                                 // TPACKET_ALIGN(sizeof(struct tpacket2_hdr))
+	txData      = tpacket2Hdr + 20 - syscall.SizeofSockaddrLinklayer // TPACKET2_HDRLEN - sizeof(sockaddr_ll)
+)
+
+// tpacket2 mirrors struct tpacket2_hdr.
+type tpacket2 struct {
+	status  uint32
+	len     uint32
+	snaplen uint32
+	mac     uint16
+	net     uint16
+	sec     uint32
+	nsec    uint32
+	vlanTCI uint16
+	vlanTP  uint16
+	_       [4]uint8
+}
+
+// tpacketReq mirrors struct tpacket_req.
+type tpacketReq struct {
+	blockSize, blockNr, frameSize, frameNr uint32
+}
+
+// ring is an mmap'd PACKET_MMAP ring; head is the next frame to use.
+type ring struct {
+	mem    []byte
+	frames int
+	head   int
+}
+
+func (r *ring) hdr(i int) *tpacket2 {
+	return (*tpacket2)(unsafe.Pointer(&r.mem[i*ringFrame]))
+}
+
+func (r *ring) status(i int) uint32 { return atomic.LoadUint32(&r.hdr(i).status) }
+
+func (r *ring) setStatus(i int, s uint32) { atomic.StoreUint32(&r.hdr(i).status, s) }
+
+// ringConn sends Ethernet frames through a PACKET_TX_RING and receives IP
+// packets from a PACKET_RX_RING, so a batch of SYNs costs one system call
+// and received packets are read straight from shared memory. Packets to
+// destinations whose next hop has no resolved link address yet go out
+// through a raw IP socket instead, which lets the kernel resolve it.
+type ringConn struct {
+	fallback int // IPPROTO_RAW socket
+	tx, rx   int
+	rxFile   *os.File // rx, pollable for deadlines
+	txRing   ring
+	rxRing   ring
+	local    net.IP
+	ifc      net.Interface
+	ether    bool // Ethernet link; otherwise all packets go to fallback
+	nh       *nextHops
+
+	mu sync.Mutex // guards txRing
+}
+
+func openFast(opts Options) (Conn, error) {
+	route, err := netutil.RouteTo(opts.Target)
+	if err != nil {
+		return nil, err
+	}
+	name := opts.Interface
+	if name == "" {
+		name = route.Interface
+	}
+	ifc, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("rawsock: fast path interface: %w", err)
+	}
+	c := &ringConn{fallback: -1, tx: -1, rx: -1, local: route.Src.To4(), ifc: *ifc}
+	// Frames injected on loopback carry martian (127/8) sources and are
+	// dropped on input, so loopback sends through the IP socket.
+	c.ether = ifc.Flags&net.FlagLoopback == 0 && len(ifc.HardwareAddr) == 6
+	c.nh = newNextHops(ifc)
+	if err := c.setup(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *ringConn) setup() error {
+	var err error
+	if c.fallback, err = syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_RAW); err != nil {
+		return fmt.Errorf("rawsock: send socket: %w", err)
+	}
+
+	// The receive socket is cooked (SOCK_DGRAM): frames start at the IP
+	// header, like packets read from a raw IP socket.
+	if c.rx, err = syscall.Socket(syscall.AF_PACKET, syscall.SOCK_DGRAM, 0); err != nil {
+		return fmt.Errorf("rawsock: packet socket: %w", err)
+	}
+	if c.rxRing, err = mapRing(c.rx, syscall.PACKET_RX_RING, ringRXBlocks); err != nil {
+		return err
+	}
+	// Our own SYNs would otherwise come back as outgoing packets; kernels
+	// before 4.20 lack the option and readers skip them instead.
+	_ = syscall.SetsockoptInt(c.rx, syscall.SOL_PACKET, packetIgnoreOutgoing, 1)
+	if err := syscall.Bind(c.rx, &syscall.SockaddrLinklayer{Protocol: htons(syscall.ETH_P_IP), Ifindex: c.ifc.Index}); err != nil {
+		return fmt.Errorf("rawsock: bind packet socket: %w", err)
+	}
+	if err := syscall.SetNonblock(c.rx, true); err != nil {
+		return fmt.Errorf("rawsock: set nonblock: %w", err)
+	}
+	c.rxFile = os.NewFile(uintptr(c.rx), "rawsock-rx-ring")
+
+	if !c.ether {
+		return nil
+	}
+	// Protocol 0: the transmit socket receives nothing.
+	if c.tx, err = syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, 0); err != nil {
+		return fmt.Errorf("rawsock: packet socket: %w", err)
+	}
+	if c.txRing, err = mapRing(c.tx, packetTXRing, ringTXBlocks); err != nil {
+		return err
+	}
+	_ = syscall.SetsockoptInt(c.tx, syscall.SOL_PACKET, packetQdiscBypass, 1)
+	if err := syscall.Bind(c.tx, &syscall.SockaddrLinklayer{Ifindex: c.ifc.Index}); err != nil {
+		return fmt.Errorf("rawsock: bind packet socket: %w", err)
+	}
+	return nil
+}
+
+// mapRing sets up a TPACKET_V2 ring of the given kind on fd and maps it.
+func mapRing(fd, kind, blocks int) (ring, error) {
+	if err := syscall.SetsockoptInt(fd, syscall.SOL_PACKET, packetVersion, tpacketV2); err != nil {
+		return ring{}, fmt.Errorf("rawsock: TPACKET_V2: %w", err)
+	}
+	req := tpacketReq{blockSize: ringBlock, blockNr: uint32(blocks), frameSize: ringFrame, frameNr: uint32(blocks * ringBlock / ringFrame)}
+	raw := (*[unsafe.Sizeof(req)]byte)(unsafe.Pointer(&req))
+	if err := syscall.SetsockoptString(fd, syscall.SOL_PACKET, kind, string(raw[:])); err != nil {
+		return ring{}, fmt.Errorf("rawsock: packet ring: %w", err)
+	}
+	mem, err := syscall.Mmap(fd, 0, blocks*ringBlock, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return ring{}, fmt.Errorf("rawsock: mmap packet ring: %w", err)
+	}
+	return ring{mem: mem, frames: int(req.frameNr)}, nil
+}
+
+func (c *ringConn) WritePacket(pkt []byte, dst net.IP) error {
+	_, err := c.WritePackets([][]byte{pkt}, []net.IP{dst})
+	return err
+}
+
+// WritePackets queues the packets on the transmit ring and flushes it
+// with one sendmsg, or a few when the ring fills up.
+func (c *ringConn) WritePackets(pkts [][]byte, dsts []net.IP) (int, error) {
+	if len(pkts) != len(dsts) {
+		return 0, errors.New("rawsock: packet and destination counts differ")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sent, queued := 0, 0
+	for i, pkt := range pkts {
+		var mac net.HardwareAddr
+		onRing := c.ether && len(pkt)+14 <= ringFrame-txData
+		if onRing {
+			mac, onRing = c.nh.lookup(dsts[i])
+		}
+		// Flush first so packets leave in order.
+		if !onRing || queued == c.txRing.frames {
+			n, err := c.flush(queued)
+			if sent += n; err != nil {
+				return sent, err
+			}
+			queued = 0
+		}
+		if !onRing {
+			if err := c.sendFallback(pkt, dsts[i]); err != nil {
+				return sent, err
+			}
+			sent++
+			continue
+		}
+		f := (c.txRing.head + queued) % c.txRing.frames
+		if s := c.txRing.status(f); s != tpStatusAvailable && s != tpStatusWrongFormat {
+			return sent, errors.New("rawsock: transmit ring frame still in use")
+		}
+		frame := c.txRing.mem[f*ringFrame+txData:]
+		copy(frame[0:6], mac)
+		copy(frame[6:12], c.ifc.HardwareAddr)
+		frame[12], frame[13] = 0x08, 0x00
+		copy(frame[14:], pkt)
+		c.txRing.hdr(f).len = uint32(len(pkt) + 14)
+		c.txRing.setStatus(f, tpStatusSendRequest)
+		queued++
+	}
+	n, err := c.flush(queued)
+	return sent + n, err
+}
+
+// flush hands the queued frames to the kernel and waits until it has sent
+// them. On an error the kernel stops at the failing frame; it and the
+// frames after it are reclaimed and reported unsent.
+func (c *ringConn) flush(queued int) (int, error) {
+	if queued == 0 {
+		return 0, nil
+	}
+	var err error
+	for {
+		// An empty message sends what the ring holds, and blocks until
+		// the kernel is done with it.
+		if err = syscall.Sendmsg(c.tx, nil, nil, nil, 0); err != syscall.EINTR {
+			break
+		}
+	}
+	start := c.txRing.head
+	done := 0
+	for ; done < queued; done++ {
+		if c.txRing.status((start+done)%c.txRing.frames) != tpStatusAvailable {
+			break
+		}
+	}
+	for i := done; i < queued; i++ {
+		c.txRing.setStatus((start+i)%c.txRing.frames, tpStatusAvailable)
+	}
+	c.txRing.head = (start + done) % c.txRing.frames
+	if err != nil {
+		return done, fmt.Errorf("rawsock: transmit ring: %w", err)
+	}
+	if done < queued {
+		return done, errors.New("rawsock: transmit ring rejected a frame")
+	}
+	return done, nil
+}
+
+func (c *ringConn) sendFallback(pkt []byte, dst net.IP) error {
+	sa := &syscall.SockaddrInet4{}
+	copy(sa.Addr[:], dst.To4())
+	return syscall.Sendto(c.fallback, pkt, 0, sa)
+}
+
+func (c *ringConn) ReadPacket(buf []byte, deadline time.Time) (int, error) {
+	sizes := []int{0}
+	if _, err := c.ReadPackets([][]byte{buf}, sizes, deadline); err != nil {
+		return 0, err
+	}
+	return sizes[0], nil
+}
+
+// ReadPackets copies the packets waiting on the receive ring into bufs,
+// waiting in the runtime poller until at least one arrives or deadline
+// passes.
+func (c *ringConn) ReadPackets(bufs [][]byte, sizes []int, deadline time.Time) (int, error) {
+	if len(bufs) == 0 || len(sizes) < len(bufs) {
+		return 0, errors.New("rawsock: need at least one buffer and a size per buffer")
+	}
+	if err := c.rxFile.SetReadDeadline(deadline); err != nil {
+		return 0, err
+	}
+	rc, err := c.rxFile.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	err = rc.Read(func(uintptr) bool {
+		n = c.drain(bufs, sizes)
+		return n > 0
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// drain moves ready frames from the receive ring into bufs and returns
+// them to the kernel. Outgoing packets are skipped.
+func (c *ringConn) drain(bufs [][]byte, sizes []int) int {
+	r := &c.rxRing
+	n := 0
+	for n < len(bufs) && r.status(r.head)&tpStatusUser != 0 {
+		h := r.hdr(r.head)
+		base := r.head * ringFrame
+		pkttype := r.mem[base+tpacket2Hdr+10] // sockaddr_ll.sll_pkttype
+		if pkttype != syscall.PACKET_OUTGOING && int(h.net)+int(h.snaplen) <= ringFrame {
+			sizes[n] = copy(bufs[n], r.mem[base+int(h.net):base+int(h.net)+int(h.snaplen)])
+			n++
+		}
+		r.setStatus(r.head, tpStatusKernel)
+		r.head = (r.head + 1) % r.frames
+	}
+	return n
+}
+
+// SetFilter attaches f to the receive socket; cooked frames start at the
+// IP header.
+func (c *ringConn) SetFilter(f Filter) error {
+	prog := f.program(0, false)
+	insns := make([]syscall.SockFilter, len(prog))
+	for i, in := range prog {
+		insns[i] = syscall.SockFilter{Code: in.code, Jt: in.jt, Jf: in.jf, K: in.k}
+	}
+	rc, err := c.rxFile.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var aerr error
+	if err := rc.Control(func(fd uintptr) { aerr = syscall.AttachLsf(int(fd), insns) }); err != nil {
+		return err
+	}
+	if aerr != nil {
+		return fmt.Errorf("rawsock: attach filter: %w", aerr)
+	}
+	// Frames queued before the filter was attached may be anything.
+	c.drain(make([][]byte, c.rxRing.frames), make([]int, c.rxRing.frames))
+	return nil
+}
+
+func (c *ringConn) LocalIP() net.IP { return c.local }
+
+func (c *ringConn) Close() error {
+	var err error
+	for _, r := range []ring{c.txRing, c.rxRing} {
+		if r.mem != nil {
+			_ = syscall.Munmap(r.mem)
+		}
+	}
+	if c.rxFile != nil {
+		err = c.rxFile.Close()
+	} else if c.rx >= 0 {
+		err = syscall.Close(c.rx)
+	}
+	for _, fd := range []int{c.tx, c.fallback} {
+		if fd < 0 {
+			continue
+		}
+		if cerr := syscall.Close(fd); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func htons(v uint16) uint16 { return v<<8 | v>>8 }
@@ -0,0 +1,116 @@
+//go:build linux && fastio
+// +build linux,fastio
+
+package rawsock
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"portprowler/netutil"
+)
+
+func TestRouteGateway(t *testing.T) {
+	table := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	00000000	010200C0	0003	0	0	0	00000000	0	0	0
+eth0	000200C0	00000000	0001	0	0	0	00FFFFFF	0	0	0
+eth0	00000A0A	FE0200C0	0003	0	0	0	0000FFFF	0	0	0
+eth1	00000000	01000A0A	0003	0	0	0	00000000	0	0	0
+`
+	for dst, want := range map[string]string{
+		"203.0.113.9": "192.0.2.1",   // default route
+		"10.10.3.4":   "192.0.2.254", // more specific route
+		"192.0.2.77":  "",            // on-link
+	} {
+		got := routeGateway(strings.NewReader(table), "eth0", net.ParseIP(dst))
+		if (want == "" && got != nil) || (want != "" && !got.Equal(net.ParseIP(want))) {
+			t.Errorf("routeGateway(%s) = %v, want %q", dst, got, want)
+		}
+	}
+}
+
+func TestARPLookup(t *testing.T) {
+	table := `IP address       HW type     Flags       HW address            Mask     Device
+192.0.2.1        0x1         0x2         02:fc:00:00:00:05     *        eth0
+192.0.2.7        0x1         0x0         00:00:00:00:00:00     *        eth0
+192.0.2.1        0x1         0x2         02:fc:00:00:00:99     *        eth1
+`
+	if mac := arpLookup(strings.NewReader(table), "eth0", net.ParseIP("192.0.2.1")); mac.String() != "02:fc:00:00:00:05" {
+		t.Errorf("resolved entry = %v", mac)
+	}
+	for _, ip := range []string{"192.0.2.7", "192.0.2.8"} {
+		if mac := arpLookup(strings.NewReader(table), "eth0", net.ParseIP(ip)); mac != nil {
+			t.Errorf("%s: incomplete or missing entry gave %v", ip, mac)
+		}
+	}
+}
+
+func TestRingConn_Loopback(t *testing.T) {
+	if ok, _ := netutil.CanOpenRawSocket(); !ok {
+		t.Skip("needs raw socket privileges")
+	}
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := uint16(ln.Addr().(*net.TCPAddr).Port)
+
+	lo := net.ParseIP("127.0.0.1")
+	c, err := Open(Options{Target: lo, FastIO: true})
+	if err != nil {
+		t.Skipf("packet rings unavailable: %v", err)
+	}
+	defer c.Close()
+	const srcPort = 47001
+	if err := SetFilter(c, Filter{Port: srcPort, Sources: []net.IP{lo}}); err != nil {
+		t.Fatal(err)
+	}
+	pkts := make([][]byte, 3)
+	dsts := make([]net.IP, 3)
+	for i := range pkts {
+		dst := port
+		if i > 0 {
+			dst = 1 // closed: answered with a RST
+		}
+		if pkts[i], err = (TCPSegment{Src: lo, Dst: lo, SrcPort: srcPort, DstPort: dst, Seq: 1000, Flags: FlagSYN}).Marshal(); err != nil {
+			t.Fatal(err)
+		}
+		dsts[i] = lo
+	}
+	if n, err := WritePackets(c, pkts, dsts); n != 3 || err != nil {
+		t.Fatalf("WritePackets = %d, %v", n, err)
+	}
+
+	var synAck, rst int
+	bufs := [][]byte{make([]byte, 256), make([]byte, 256)}
+	sizes := make([]int, 2)
+	deadline := time.Now().Add(2 * time.Second)
+	for synAck+rst < 3 {
+		n, err := ReadPackets(c, bufs, sizes, deadline)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatalf("got %d SYN/ACKs and %d RSTs, want 1 and 2", synAck, rst)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < n; i++ {
+			seg, err := ParseTCP(bufs[i][:sizes[i]])
+			if err != nil || seg.DstPort != srcPort || seg.Ack != 1001 {
+				t.Fatalf("unexpected packet %+v (%v) passed the filter", seg, err)
+			}
+			if seg.Flags&FlagRST != 0 {
+				rst++
+			} else {
+				synAck++
+			}
+		}
+	}
+	if synAck != 1 || rst != 2 {
+		t.Fatalf("got %d SYN/ACKs and %d RSTs, want 1 and 2", synAck, rst)
+	}
+}
@@ -0,0 +1,232 @@
+// Package redact replaces target names and addresses in reports with
+// stable tokens, so results can be shared without revealing the network
+// they came from. The secret key and the token of every original are kept
+// in a local mapping file: the same name gets the same token on every run
+// that uses the file, and only its owner can map tokens back.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"portprowler/output"
+	"portprowler/port"
+	"portprowler/report"
+)
+
+// Mapping is the contents of a mapping file.
+type Mapping struct {
+	Key    string            `json:"key"`    // hex HMAC-SHA256 key
+	Tokens map[string]string `json:"tokens"` // token -> original name or address
+
+	key   []byte
+	dirty bool
+}
+
+// DefaultPath returns the mapping file under the user's configuration
+// directory (e.g. ~/.config/portprowler/redact.json).
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "portprowler", "redact.json"), nil
+}
+
+// Load reads the mapping file at path, or starts a new mapping with a
+// random key when the file does not exist yet.
+func Load(path string) (*Mapping, error) {
+	m := &Mapping{Tokens: make(map[string]string)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		m.key = make([]byte, 32)
+		if _, err := rand.Read(m.key); err != nil {
+			return nil, err
+		}
+		m.Key = hex.EncodeToString(m.key)
+		m.dirty = true
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if m.key, err = hex.DecodeString(m.Key); err != nil || len(m.key) < 16 {
+		return nil, fmt.Errorf("%s: invalid key", path)
+	}
+	if m.Tokens == nil {
+		m.Tokens = make(map[string]string)
+	}
+	return m, nil
+}
+
+// Save writes the mapping to path when tokens were added since Load. The
+// file is created readable by its owner only.
+func (m *Mapping) Save(path string) error {
+	if !m.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := output.WriteAtomic(path, append(data, '\n')); err != nil {
+		return err
+	}
+	m.dirty = false
+	return nil
+}
+
+// Token returns the token for a name or address: "ip-" or "host-" and the
+// first 12 hex digits of its keyed hash. Names are compared without case.
+func (m *Mapping) Token(s string) string {
+	if s == "" {
+		return ""
+	}
+	prefix := "host-"
+	if ip := net.ParseIP(s); ip != nil {
+		prefix, s = "ip-", ip.String()
+	} else {
+		s = strings.ToLower(strings.TrimSuffix(s, "."))
+	}
+	mac := hmac.New(sha256.New, m.key)
+	mac.Write([]byte(s))
+	tok := prefix + hex.EncodeToString(mac.Sum(nil))[:12]
+	if _, ok := m.Tokens[tok]; !ok {
+		m.Tokens[tok] = s
+		m.dirty = true
+	}
+	return tok
+}
+
+// Report redacts rep in place. Target names, addresses, virtual host
+// names and certificate names become tokens; occurrences of them in
+// banners, titles, errors, notes and cloud instance names and tags are
+// replaced too. Raw bytes that cannot be rewritten safely (BannerRaw,
+// hex dumps, captured bodies, fingerprints) are dropped.
+func (m *Mapping) Report(rep *report.ScanReport) {
+	// Collect the originals first so free text can be rewritten with all of them.
+	var originals []string
+	add := func(s string) {
+		if s != "" {
+			originals = append(originals, s)
+		}
+	}
+	for _, h := range rep.Hosts {
+		add(h.Target)
+		add(h.IP)
+		for _, a := range h.Addrs {
+			add(a)
+		}
+		for _, r := range h.Results {
+			add(r.Target)
+			add(r.IP)
+			for _, v := range r.VHosts {
+				add(v.Name)
+			}
+			if r.TLS != nil {
+				add(r.TLS.ServerName)
+				add(r.TLS.Subject)
+				for _, n := range r.TLS.DNSNames {
+					add(n)
+				}
+			}
+		}
+	}
+	// Longest first, so a name is replaced before any name it contains.
+	sort.SliceStable(originals, func(i, j int) bool { return len(originals[i]) > len(originals[j]) })
+	var pairs []string
+	seen := make(map[string]bool)
+	for _, o := range originals {
+		if !seen[o] {
+			seen[o] = true
+			pairs = append(pairs, o, m.Token(o))
+		}
+	}
+	text := strings.NewReplacer(pairs...).Replace
+
+	for i := range rep.Hosts {
+		h := &rep.Hosts[i]
+		h.Target, h.IP = m.Token(h.Target), m.Token(h.IP)
+		for j := range h.Addrs {
+			h.Addrs[j] = m.Token(h.Addrs[j])
+		}
+		for j := range h.Notes {
+			h.Notes[j] = text(h.Notes[j])
+		}
+		for j := range h.Deception {
+			h.Deception[j] = text(h.Deception[j])
+		}
+		if h.Cloud != nil {
+			c := *h.Cloud
+			c.Name = text(c.Name)
+			if c.Tags != nil {
+				c.Tags = make(map[string]string, len(h.Cloud.Tags))
+				for k, v := range h.Cloud.Tags {
+					c.Tags[k] = text(v)
+				}
+			}
+			h.Cloud = &c
+		}
+		for j := range h.Results {
+			m.result(&h.Results[j], text)
+		}
+	}
+	rep.Meta.Note = text(rep.Meta.Note)
+}
+
+func (m *Mapping) result(r *port.PortResult, text func(string) string) {
+	r.Target, r.IP = m.Token(r.Target), m.Token(r.IP)
+	r.ServiceBanner = text(r.ServiceBanner)
+	r.Error = text(r.Error)
+	for i := range r.Notes {
+		r.Notes[i] = text(r.Notes[i])
+	}
+	r.BannerRaw = nil
+	r.HexDump = ""
+	r.Fingerprint = nil
+	for i := range r.VHosts {
+		v := &r.VHosts[i]
+		v.Name = m.Token(v.Name)
+		v.Title, v.Location, v.Error = text(v.Title), text(v.Location), text(v.Error)
+	}
+	if r.HTTP != nil {
+		h := *r.HTTP
+		h.Title, h.Location, h.Server = text(h.Title), text(h.Location), text(h.Server)
+		h.Body = nil
+		r.HTTP = &h
+	}
+	if r.DNS != nil {
+		d := *r.DNS
+		d.Query = text(d.Query)
+		answers := make([]string, len(d.Answers))
+		for i, a := range d.Answers {
+			answers[i] = text(a)
+		}
+		d.Answers = answers
+		r.DNS = &d
+	}
+	if r.TLS != nil {
+		t := *r.TLS
+		t.ServerName, t.Subject = m.Token(t.ServerName), m.Token(t.Subject)
+		t.VerifyError = text(t.VerifyError)
+		names := make([]string, len(t.DNSNames))
+		for i, n := range t.DNSNames {
+			names[i] = m.Token(n)
+		}
+		t.DNSNames = names
+		r.TLS = &t
+	}
+}
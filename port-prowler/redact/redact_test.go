@@ -0,0 +1,97 @@
+package redact
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"portprowler/port"
+	"portprowler/report"
+)
+
+func sample() report.ScanReport {
+	return report.Build(report.Meta{Note: "before the db.corp.example migration"}, []port.Target{{Name: "db.corp.example", IP: "10.1.2.3"}}, []port.PortResult{
+		{Target: "db.corp.example", IP: "10.1.2.3", Port: 25, Proto: "tcp", State: "open",
+			ServiceBanner: "220 db.corp.example ESMTP", BannerRaw: []byte("220 db.corp.example ESMTP\r\n")},
+		{Target: "db.corp.example", IP: "10.1.2.3", Port: 443, Proto: "tcp", State: "open",
+			TLS:  &port.TLSInfo{Subject: "db.corp.example", DNSNames: []string{"db.corp.example", "www.corp.example"}, Issuer: "Public CA"},
+			HTTP: &port.HTTPInfo{StatusCode: 301, Location: "https://www.corp.example/", Body: []byte("moved")}},
+		{Target: "db.corp.example", IP: "10.1.2.3", Port: 53, Proto: "udp", State: "open",
+			DNS: &port.DNSInfo{Query: "db.corp.example IN A", RCode: "NOERROR", Answers: []string{"10.1.2.3"}}},
+	})
+}
+
+func TestReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redact.json")
+	m, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := sample()
+	rep.Hosts[0].Cloud = &report.CloudInstance{Provider: "aws", ID: "i-0abc", Name: "db.corp.example", Tags: map[string]string{"owner": "dba"}}
+	m.Report(&rep)
+
+	data, _ := json.Marshal(rep)
+	for _, secret := range []string{"corp.example", "10.1.2.3", "moved"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("redacted report still contains %q: %s", secret, data)
+		}
+	}
+	h := rep.Hosts[0]
+	if !strings.HasPrefix(h.Target, "host-") || !strings.HasPrefix(h.IP, "ip-") || h.Results[0].Target != h.Target {
+		t.Fatalf("unexpected tokens: %+v", h)
+	}
+	if want := "220 " + h.Target + " ESMTP"; h.Results[0].ServiceBanner != want || h.Results[0].BannerRaw != nil {
+		t.Errorf("banner = %q, want %q without raw bytes", h.Results[0].ServiceBanner, want)
+	}
+	if tls := h.Results[1].TLS; tls.Subject != h.Target || tls.Issuer != "Public CA" {
+		t.Errorf("unexpected TLS after redaction: %+v", tls)
+	}
+	for _, r := range h.Results {
+		if d := r.DNS; d != nil && (d.Query != h.Target+" IN A" || len(d.Answers) != 1 || d.Answers[0] != h.IP) {
+			t.Errorf("unexpected DNS after redaction: %+v", d)
+		}
+	}
+	if c := h.Cloud; c.ID != "i-0abc" || c.Name != h.Target || c.Tags["owner"] != "dba" {
+		t.Errorf("unexpected cloud instance after redaction: %+v", c)
+	}
+	if m.Tokens[h.Target] != "db.corp.example" || m.Tokens[h.IP] != "10.1.2.3" {
+		t.Errorf("mapping lacks the originals: %v", m.Tokens)
+	}
+
+	// The saved mapping gives the same tokens on the next run.
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && fi.Mode().Perm()&0o077 != 0 {
+		t.Errorf("mapping file mode %v, want it private", fi.Mode())
+	}
+	again, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep2 := sample()
+	again.Report(&rep2)
+	if rep2.Hosts[0].Target != h.Target || rep2.Hosts[0].IP != h.IP {
+		t.Errorf("tokens changed between runs: %s/%s vs %s/%s", rep2.Hosts[0].Target, rep2.Hosts[0].IP, h.Target, h.IP)
+	}
+
+	// Another key gives other tokens.
+	other, _ := Load(filepath.Join(t.TempDir(), "other.json"))
+	if other.Token("db.corp.example") == h.Target {
+		t.Error("tokens should depend on the key")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redact.json")
+	os.WriteFile(path, []byte(`{"key":"zz"}`), 0o600)
+	if _, err := Load(path); err == nil {
+		t.Fatal("want an error for an invalid key")
+	}
+}
@@ -0,0 +1,394 @@
+package replay
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"portprowler/port"
+	"portprowler/wire"
+)
+
+// Classic pcap magic numbers (microsecond and nanosecond timestamps).
+const (
+	magicMicro = 0xa1b2c3d4
+	magicNano  = 0xa1b23c4d
+)
+
+var pcapngMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+// Link types whose framing FromPcap can strip.
+const (
+	linkNull      = 0 // BSD loopback, 4-byte host-order family
+	linkEthernet  = 1
+	linkRawA      = 12  // DLT_RAW on most BSDs
+	linkRawB      = 14  // DLT_RAW on OpenBSD
+	linkRaw       = 101 // LINKTYPE_RAW
+	linkLoop      = 108 // OpenBSD loopback, network-order family
+	linkLinuxSLL  = 113
+	linkLinuxSLL2 = 276
+)
+
+// udpPingPort is the port the scanner's UDP ping targets (see scanner.PingScan).
+const udpPingPort = 33434
+
+// maxSnap bounds a single record so a corrupt length cannot exhaust memory.
+const maxSnap = 256 * 1024
+
+const maxBanner = 1024
+
+func isPcap(head []byte) bool {
+	if len(head) < 4 {
+		return false
+	}
+	for _, m := range []uint32{binary.LittleEndian.Uint32(head), binary.BigEndian.Uint32(head)} {
+		if m == magicMicro || m == magicNano {
+			return true
+		}
+	}
+	return false
+}
+
+// FromPcap rebuilds results from a classic pcap capture of a scan:
+//   - TCP: a SYN is a probe; SYN/ACK marks the port open, RST closed, and
+//     silence filtered. The first data the port sends is its banner.
+//   - UDP: a datagram is a probe; a reply marks the port open, ICMP port
+//     unreachable closed, and silence open|filtered.
+//   - ICMP echo requests and replies become ping results, as do UDP pings.
+//
+// Other ICMP unreachables leave ports filtered with the reason in Error.
+// A capture cut off mid-record is read up to the cut.
+func FromPcap(r io.Reader) ([]port.PortResult, error) {
+	var hdr [24]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("pcap header: %v", err)
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if m := binary.BigEndian.Uint32(hdr[:4]); m == magicMicro || m == magicNano {
+		order = binary.BigEndian
+	}
+	nano := order.Uint32(hdr[:4]) == magicNano
+	link := order.Uint32(hdr[20:24]) & 0x0fffffff
+
+	t := newTracker()
+	var rec [16]byte
+	for {
+		if _, err := io.ReadFull(r, rec[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, err
+		}
+		n := order.Uint32(rec[8:12])
+		if n > maxSnap {
+			return nil, fmt.Errorf("pcap record of %d bytes: corrupt capture", n)
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, err
+		}
+		frac := time.Duration(order.Uint32(rec[4:8]))
+		if !nano {
+			frac *= time.Microsecond
+		}
+		ts := time.Unix(int64(order.Uint32(rec[0:4])), int64(frac)).UTC()
+		raw, err := stripLink(link, data)
+		if err != nil {
+			return nil, err
+		}
+		if pkt, ok := parseIP(raw); ok {
+			t.add(ts, pkt)
+		}
+	}
+	return classify(t.results()), nil
+}
+
+var errLinkType = errors.New("unsupported pcap link type")
+
+// stripLink returns the IP packet inside a link-layer frame, or nil for
+// frames that do not carry IP.
+func stripLink(link uint32, b []byte) ([]byte, error) {
+	switch link {
+	case linkEthernet:
+		if len(b) < 14 {
+			return nil, nil
+		}
+		etype, off := binary.BigEndian.Uint16(b[12:14]), 14
+		for (etype == 0x8100 || etype == 0x88a8) && len(b) >= off+4 {
+			etype, off = binary.BigEndian.Uint16(b[off+2:off+4]), off+4
+		}
+		if etype != 0x0800 && etype != 0x86dd {
+			return nil, nil
+		}
+		return b[off:], nil
+	case linkRaw, linkRawA, linkRawB:
+		return b, nil
+	case linkNull, linkLoop:
+		if len(b) < 4 {
+			return nil, nil
+		}
+		return b[4:], nil
+	case linkLinuxSLL:
+		if len(b) < 16 {
+			return nil, nil
+		}
+		return b[16:], nil
+	case linkLinuxSLL2:
+		if len(b) < 20 {
+			return nil, nil
+		}
+		return b[20:], nil
+	}
+	return nil, fmt.Errorf("%w %d", errLinkType, link)
+}
+
+// packet is the part of an IP packet the tracker needs.
+type packet struct {
+	src, dst string
+	proto    uint8 // IP protocol number: 1 icmp, 6 tcp, 17 udp, 58 icmpv6
+	payload  []byte
+}
+
+// parseIP decodes an IPv4 or IPv6 header. IPv6 extension headers and
+// non-first IPv4 fragments are not followed.
+func parseIP(b []byte) (packet, bool) {
+	if len(b) < 1 {
+		return packet{}, false
+	}
+	switch b[0] >> 4 {
+	case 4:
+		if len(b) < 20 {
+			return packet{}, false
+		}
+		ihl := int(b[0]&0x0f) * 4
+		end := int(binary.BigEndian.Uint16(b[2:4]))
+		if end > len(b) {
+			end = len(b)
+		}
+		if ihl < 20 || ihl > end || binary.BigEndian.Uint16(b[6:8])&0x1fff != 0 {
+			return packet{}, false
+		}
+		return packet{
+			src:     net.IP(b[12:16]).String(),
+			dst:     net.IP(b[16:20]).String(),
+			proto:   b[9],
+			payload: b[ihl:end],
+		}, true
+	case 6:
+		if len(b) < 40 {
+			return packet{}, false
+		}
+		end := 40 + int(binary.BigEndian.Uint16(b[4:6]))
+		if end > len(b) {
+			end = len(b)
+		}
+		return packet{
+			src:     net.IP(b[8:24]).String(),
+			dst:     net.IP(b[24:40]).String(),
+			proto:   b[6],
+			payload: b[40:end],
+		}, true
+	}
+	return packet{}, false
+}
+
+// flowKey identifies a probe by the prober's and the target's endpoints.
+// Pings use the echo id and sequence number as ports.
+type flowKey struct {
+	src, dst     string
+	sport, dport uint16
+	kind         string // tcp, udp or ping
+}
+
+type flow struct {
+	start time.Time
+	res   port.PortResult
+}
+
+type tracker struct {
+	flows map[flowKey]*flow
+	order []*flow
+}
+
+func newTracker() *tracker {
+	return &tracker{flows: make(map[flowKey]*flow)}
+}
+
+// probe returns the flow for k, starting one at ts with the given result
+// when the capture has not seen it yet.
+func (t *tracker) probe(k flowKey, ts time.Time, res port.PortResult) *flow {
+	if f, ok := t.flows[k]; ok {
+		return f
+	}
+	res.Timestamp = ts
+	f := &flow{start: ts, res: res}
+	t.flows[k] = f
+	t.order = append(t.order, f)
+	return f
+}
+
+// answer sets the state of the probe f from a reply seen at ts.
+func (f *flow) answer(ts time.Time, state, errText string) {
+	f.res.State = state
+	f.res.Error = errText
+	f.res.RTTMillis = ts.Sub(f.start).Milliseconds()
+}
+
+func (t *tracker) add(ts time.Time, p packet) {
+	switch p.proto {
+	case 6:
+		t.tcp(ts, p)
+	case 17:
+		t.udp(ts, p)
+	case 1, 58:
+		t.icmp(ts, p)
+	}
+}
+
+func (t *tracker) tcp(ts time.Time, p packet) {
+	seg := p.payload
+	if len(seg) < 20 {
+		return
+	}
+	sport, dport := binary.BigEndian.Uint16(seg[0:2]), binary.BigEndian.Uint16(seg[2:4])
+	flags := seg[13]
+	syn, rst, ack := flags&0x02 != 0, flags&0x04 != 0, flags&0x10 != 0
+	if syn && !ack {
+		t.probe(flowKey{p.src, p.dst, sport, dport, "tcp"}, ts, port.PortResult{
+			IP: p.dst, Port: dport, Proto: string(port.ScanTCP), State: "filtered", Error: "timeout",
+		})
+		return
+	}
+	f, ok := t.flows[flowKey{p.dst, p.src, dport, sport, "tcp"}]
+	if !ok {
+		return
+	}
+	switch {
+	case syn && ack && f.res.State != "open":
+		f.answer(ts, "open", "")
+	case rst && f.res.State == "filtered":
+		f.answer(ts, "closed", "connection refused")
+	}
+	if off := int(seg[12]>>4) * 4; f.res.State == "open" && f.res.ServiceBanner == "" && off >= 20 && off < len(seg) {
+		data := seg[off:]
+		if len(data) > maxBanner {
+			data = data[:maxBanner]
+		}
+		f.res.ServiceBanner = wire.ParseBanner(data)
+		f.res.BannerRaw = append([]byte(nil), data...)
+	}
+}
+
+func (t *tracker) udp(ts time.Time, p packet) {
+	seg := p.payload
+	if len(seg) < 8 {
+		return
+	}
+	sport, dport := binary.BigEndian.Uint16(seg[0:2]), binary.BigEndian.Uint16(seg[2:4])
+	if f, ok := t.flows[flowKey{p.dst, p.src, dport, sport, "udp"}]; ok {
+		if f.res.Proto == string(port.ScanPing) {
+			f.answer(ts, "up", "")
+		} else {
+			f.answer(ts, "open", "")
+		}
+		return
+	}
+	if dport == udpPingPort {
+		t.probe(flowKey{p.src, p.dst, sport, dport, "udp"}, ts, port.PortResult{
+			IP: p.dst, Proto: string(port.ScanPing), Service: "udp-ping", State: "down", Error: "no reply",
+		})
+		return
+	}
+	t.probe(flowKey{p.src, p.dst, sport, dport, "udp"}, ts, port.PortResult{
+		IP: p.dst, Port: dport, Proto: string(port.ScanUDP), State: "open|filtered",
+	})
+}
+
+func (t *tracker) icmp(ts time.Time, p packet) {
+	msg := p.payload
+	if len(msg) < 8 {
+		return
+	}
+	typ, code := msg[0], msg[1]
+	v6 := p.proto == 58
+	echoReq, echoReply, unreach := uint8(8), uint8(0), uint8(3)
+	if v6 {
+		echoReq, echoReply, unreach = 128, 129, 1
+	}
+	id, seq := binary.BigEndian.Uint16(msg[4:6]), binary.BigEndian.Uint16(msg[6:8])
+	switch typ {
+	case echoReq:
+		t.probe(flowKey{p.src, p.dst, id, seq, "ping"}, ts, port.PortResult{
+			IP: p.dst, Proto: string(port.ScanPing), Service: "icmp-echo", State: "down", Error: "no reply",
+		})
+	case echoReply:
+		if f, ok := t.flows[flowKey{p.dst, p.src, id, seq, "ping"}]; ok {
+			f.answer(ts, "up", "")
+		}
+	case unreach:
+		orig, ok := parseIP(msg[8:])
+		if !ok || len(orig.payload) < 4 {
+			return
+		}
+		sport, dport := binary.BigEndian.Uint16(orig.payload[0:2]), binary.BigEndian.Uint16(orig.payload[2:4])
+		kind := "udp"
+		if orig.proto == 6 {
+			kind = "tcp"
+		}
+		f, ok := t.flows[flowKey{orig.src, orig.dst, sport, dport, kind}]
+		if !ok || f.res.State == "open" || f.res.State == "up" {
+			return
+		}
+		portUnreachable := (!v6 && code == 3) || (v6 && code == 4)
+		switch {
+		case f.res.Proto == string(port.ScanPing):
+			// any unreachable from the target itself proves it is up
+			if p.src == orig.dst {
+				f.answer(ts, "up", "")
+			}
+		case portUnreachable && kind == "udp":
+			f.answer(ts, "closed", "connection refused")
+		default:
+			f.answer(ts, "filtered", fmt.Sprintf("icmp unreachable (code %d) from %s", code, p.src))
+		}
+	}
+}
+
+// stateRank orders states so retries and follow-up connections to a port
+// report the most conclusive answer seen.
+var stateRank = map[string]int{"open": 4, "up": 4, "closed": 3, "open|filtered": 1}
+
+// results merges the flows per address, port and protocol in the order
+// they were first probed.
+func (t *tracker) results() []port.PortResult {
+	var out []port.PortResult
+	index := make(map[string]int)
+	for _, f := range t.order {
+		key := fmt.Sprintf("%s|%d|%s", f.res.IP, f.res.Port, f.res.Proto)
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(out)
+			out = append(out, f.res)
+			continue
+		}
+		cur := &out[i]
+		banner, raw := cur.ServiceBanner, cur.BannerRaw
+		if stateRank[f.res.State] > stateRank[cur.State] {
+			ts := cur.Timestamp
+			*cur = f.res
+			cur.Timestamp = ts
+		}
+		if banner != "" {
+			cur.ServiceBanner, cur.BannerRaw = banner, raw
+		} else if f.res.State == "open" {
+			cur.ServiceBanner, cur.BannerRaw = f.res.ServiceBanner, f.res.BannerRaw
+		}
+	}
+	return out
+}
@@ -0,0 +1,178 @@
+// Package replay rebuilds scan results from an earlier scan's audit log or
+// packet capture, so reports can be regenerated offline without sending
+// any traffic.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"portprowler/audit"
+	"portprowler/port"
+	"portprowler/sigs"
+)
+
+// Load reads path as a pcap capture or an audit log (--audit), telling
+// them apart by the file's leading bytes.
+func Load(path string) ([]port.PortResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	head, _ := br.Peek(4)
+	if isPcap(head) {
+		return FromPcap(br)
+	}
+	if bytes.Equal(head, pcapngMagic) {
+		return nil, fmt.Errorf("%s: pcapng is not supported; convert it with: editcap -F pcap in.pcapng out.pcap", path)
+	}
+	return FromAudit(br)
+}
+
+// FromAudit rebuilds results from audit log records. Detection
+// connections are skipped; when a log holds several scans of the same
+// address and port, the latest record wins.
+func FromAudit(r io.Reader) ([]port.PortResult, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var out []port.PortResult
+	index := make(map[string]int)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := bytes.TrimSpace(sc.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var rec audit.Record
+		if err := json.Unmarshal(text, &rec); err != nil {
+			return nil, fmt.Errorf("audit log line %d: %v", line, err)
+		}
+		res, ok := fromRecord(rec)
+		if !ok {
+			continue
+		}
+		key := fmt.Sprintf("%s|%d|%s", res.IP, res.Port, res.Proto)
+		if i, seen := index[key]; seen {
+			if !res.Timestamp.Before(out[i].Timestamp) {
+				out[i] = res
+			}
+			continue
+		}
+		index[key] = len(out)
+		out = append(out, res)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return classify(out), nil
+}
+
+func fromRecord(rec audit.Record) (port.PortResult, bool) {
+	res := port.PortResult{
+		Target:        rec.Target,
+		State:         rec.Outcome,
+		Error:         rec.Error,
+		RTTMillis:     rec.RTTMillis,
+		Timestamp:     rec.Time,
+		ServiceBanner: rec.Banner,
+	}
+	switch rec.Kind {
+	case "tcp-connect":
+		res.Proto = string(port.ScanTCP)
+	case "udp":
+		res.Proto = string(port.ScanUDP)
+	case "syn":
+		res.Proto = string(port.ScanStealth)
+	case "icmp-echo", "udp-ping":
+		res.Proto = string(port.ScanPing)
+		res.Service = rec.Kind
+		res.IP = rec.Dst
+		return res, true
+	default:
+		return res, false
+	}
+	host, p, err := net.SplitHostPort(rec.Dst)
+	if err != nil {
+		return res, false
+	}
+	n, err := strconv.ParseUint(p, 10, 16)
+	if err != nil {
+		return res, false
+	}
+	res.IP, res.Port = host, uint16(n)
+	return res, true
+}
+
+// classify matches recorded banners against the current signature set, so
+// a replay picks up signatures added since the scan.
+func classify(results []port.PortResult) []port.PortResult {
+	for i := range results {
+		r := &results[i]
+		if r.State != "open" || r.ServiceBanner == "" {
+			continue
+		}
+		if svc, conf, ok := sigs.Detect(r.ServiceBanner); ok {
+			r.Service, r.ServiceConfidence = svc, conf
+		}
+	}
+	return results
+}
+
+// Select narrows results to the given targets and ports; either may be
+// empty to keep everything. With targets, results are attributed to every
+// target name resolving to their IP, just as a live scan reports an
+// address shared by several names; otherwise results recorded without a
+// name (all of a pcap's) are reported under their IP.
+func Select(results []port.PortResult, targets []port.Target, ports []uint16) []port.PortResult {
+	names := make(map[string][]string)
+	for _, t := range targets {
+		names[t.IP] = append(names[t.IP], t.Name)
+	}
+	wanted := make(map[uint16]bool)
+	for _, p := range ports {
+		wanted[p] = true
+	}
+	var out []port.PortResult
+	for _, r := range results {
+		if len(wanted) > 0 && r.Proto != string(port.ScanPing) && !wanted[r.Port] {
+			continue
+		}
+		if len(targets) == 0 {
+			if r.Target == "" {
+				r.Target = r.IP
+			}
+			out = append(out, r)
+			continue
+		}
+		for _, name := range names[r.IP] {
+			dup := r
+			dup.Target = name
+			out = append(out, dup)
+		}
+	}
+	return out
+}
+
+// Span returns when the recorded scan started and finished.
+func Span(results []port.PortResult) (started, finished time.Time) {
+	for _, r := range results {
+		end := r.Timestamp.Add(time.Duration(r.RTTMillis) * time.Millisecond)
+		if started.IsZero() || r.Timestamp.Before(started) {
+			started = r.Timestamp
+		}
+		if end.After(finished) {
+			finished = end
+		}
+	}
+	return started, finished
+}
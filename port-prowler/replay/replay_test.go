@@ -0,0 +1,159 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"portprowler/audit"
+	"portprowler/port"
+)
+
+func TestFromAudit(t *testing.T) {
+	var buf bytes.Buffer
+	l := audit.New(&buf, "")
+	t0 := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	l.Probe(t0, port.PortResult{Target: "db", IP: "10.0.0.9", Port: 22, Proto: "tcp", State: "filtered", Error: "timeout", LocalAddr: "10.0.0.5:1"})
+	l.Probe(t0.Add(time.Minute), port.PortResult{Target: "db", IP: "10.0.0.9", Port: 22, Proto: "tcp", State: "open", ServiceBanner: "SSH-2.0-OpenSSH_9.6", LocalAddr: "10.0.0.5:2"})
+	l.Probe(t0, port.PortResult{Target: "db", IP: "10.0.0.9", Proto: "ping", Service: "udp-ping", State: "up", LocalAddr: "10.0.0.5:3"})
+	l.Conn("detect", t0, "10.0.0.9:22", nil, nil)
+
+	got, err := FromAudit(&buf)
+	if err != nil {
+		t.Fatalf("FromAudit: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d results, want 2: %+v", len(got), got)
+	}
+	if r := got[0]; r.Target != "db" || r.State != "open" || r.Service != "ssh" || !r.Timestamp.Equal(t0.Add(time.Minute)) {
+		t.Errorf("tcp result = %+v, want latest open ssh record", r)
+	}
+	if r := got[1]; r.Proto != "ping" || r.IP != "10.0.0.9" || r.State != "up" || r.Service != "udp-ping" {
+		t.Errorf("ping result = %+v", r)
+	}
+
+	sel := Select(got, []port.Target{{Name: "db", IP: "10.0.0.9"}, {Name: "db-alias", IP: "10.0.0.9"}}, []uint16{22})
+	if len(sel) != 4 || sel[1].Target != "db-alias" {
+		t.Errorf("Select = %+v, want both results under both names", sel)
+	}
+}
+
+// capture assembles a little-endian pcap with Ethernet framing.
+type capture struct {
+	buf bytes.Buffer
+	t   time.Time
+}
+
+func newCapture() *capture {
+	c := &capture{t: time.Unix(1700000000, 0)}
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:], magicMicro)
+	binary.LittleEndian.PutUint16(hdr[4:], 2)
+	binary.LittleEndian.PutUint16(hdr[6:], 4)
+	binary.LittleEndian.PutUint32(hdr[16:], 65535)
+	binary.LittleEndian.PutUint32(hdr[20:], linkEthernet)
+	c.buf.Write(hdr)
+	return c
+}
+
+// ip4 writes one IPv4 packet 10ms after the previous one.
+func (c *capture) ip4(src, dst string, proto uint8, l4 []byte) {
+	c.t = c.t.Add(10 * time.Millisecond)
+	frame := make([]byte, 14, 34+len(l4))
+	binary.BigEndian.PutUint16(frame[12:], 0x0800)
+	frame = append(frame, ipv4Header(src, dst, proto, len(l4))...)
+	frame = append(frame, l4...)
+	rec := make([]byte, 16)
+	binary.LittleEndian.PutUint32(rec[0:], uint32(c.t.Unix()))
+	binary.LittleEndian.PutUint32(rec[4:], uint32(c.t.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(rec[8:], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(rec[12:], uint32(len(frame)))
+	c.buf.Write(rec)
+	c.buf.Write(frame)
+}
+
+func ipv4Header(src, dst string, proto uint8, n int) []byte {
+	h := make([]byte, 20)
+	h[0] = 0x45
+	binary.BigEndian.PutUint16(h[2:], uint16(20+n))
+	h[8], h[9] = 64, proto
+	copy(h[12:], net.ParseIP(src).To4())
+	copy(h[16:], net.ParseIP(dst).To4())
+	return h
+}
+
+func tcpSeg(sport, dport uint16, flags byte, data string) []byte {
+	s := make([]byte, 20)
+	binary.BigEndian.PutUint16(s[0:], sport)
+	binary.BigEndian.PutUint16(s[2:], dport)
+	s[12], s[13] = 5<<4, flags
+	return append(s, data...)
+}
+
+func udpSeg(sport, dport uint16, data string) []byte {
+	s := make([]byte, 8)
+	binary.BigEndian.PutUint16(s[0:], sport)
+	binary.BigEndian.PutUint16(s[2:], dport)
+	binary.BigEndian.PutUint16(s[4:], uint16(8+len(data)))
+	return append(s, data...)
+}
+
+func icmpMsg(typ, code byte, rest []byte) []byte {
+	return append([]byte{typ, code, 0, 0}, rest...)
+}
+
+func TestFromPcap(t *testing.T) {
+	const me, host = "10.0.0.5", "10.0.0.9"
+	const syn, synAck, rst, pshAck = 0x02, 0x12, 0x14, 0x18
+	c := newCapture()
+	c.ip4(me, host, 6, tcpSeg(40000, 22, syn, ""))
+	c.ip4(host, me, 6, tcpSeg(22, 40000, synAck, ""))
+	c.ip4(host, me, 6, tcpSeg(22, 40000, pshAck, "SSH-2.0-OpenSSH_9.6\r\n"))
+	c.ip4(me, host, 6, tcpSeg(40001, 23, syn, ""))
+	c.ip4(host, me, 6, tcpSeg(23, 40001, rst, ""))
+	c.ip4(me, host, 6, tcpSeg(40002, 25, syn, ""))
+	c.ip4(me, host, 17, udpSeg(50000, 53, "q"))
+	c.ip4(host, me, 17, udpSeg(53, 50000, "a"))
+	probe := udpSeg(50001, 161, "")
+	c.ip4(me, host, 17, probe)
+	c.ip4(host, me, 1, icmpMsg(3, 3, append(make([]byte, 4), append(ipv4Header(me, host, 17, len(probe)), probe...)...)))
+	c.ip4(me, host, 1, icmpMsg(8, 0, []byte{0, 7, 0, 1}))
+	c.ip4(host, me, 1, icmpMsg(0, 0, []byte{0, 7, 0, 1}))
+
+	path := filepath.Join(t.TempDir(), "scan.pcap")
+	if err := os.WriteFile(path, c.buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []struct {
+		port  uint16
+		proto string
+		state string
+	}{
+		{22, "tcp", "open"},
+		{23, "tcp", "closed"},
+		{25, "tcp", "filtered"},
+		{53, "udp", "open"},
+		{161, "udp", "closed"},
+		{0, "ping", "up"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		r := got[i]
+		if r.IP != host || r.Port != w.port || r.Proto != w.proto || r.State != w.state {
+			t.Errorf("result %d = %s %d/%s %s, want %d/%s %s", i, r.IP, r.Port, r.Proto, r.State, w.port, w.proto, w.state)
+		}
+	}
+	if r := got[0]; r.ServiceBanner != "SSH-2.0-OpenSSH_9.6" || r.Service != "ssh" || r.RTTMillis != 10 {
+		t.Errorf("ssh result = %+v, want banner, service and 10ms rtt", r)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+
+	"portprowler/logging"
+	"portprowler/port"
+	"portprowler/replay"
+	"portprowler/report"
+	"portprowler/stats"
+)
+
+// replayReport rebuilds a report and its summary line from a recorded
+// scan, keeping only the given targets and ports when set.
+func replayReport(path string, targets []port.Target, ports []uint16, meta report.Meta) (report.ScanReport, string, error) {
+	results, err := replay.Load(path)
+	if err != nil {
+		return report.ScanReport{}, "", runtimeErr("failed to replay %s: %v", path, err)
+	}
+	results = replay.Select(results, targets, ports)
+	logging.Infof("Replayed %d result(s) from %s", len(results), path)
+
+	// count each probe once, however many names share its address
+	c := stats.New()
+	seen := make(map[string]bool)
+	for _, r := range results {
+		key := fmt.Sprintf("%s|%d|%s", r.IP, r.Port, r.Proto)
+		if !seen[key] {
+			seen[key] = true
+			c.Record(r)
+		}
+	}
+	snap := c.Snapshot()
+	snap.Started, snap.Finished = replay.Span(results)
+	snap.Elapsed = snap.Finished.Sub(snap.Started)
+	meta.Started, meta.Finished = snap.Started, snap.Finished
+	return report.Build(meta, targets, results), snap.Summary(), nil
+}
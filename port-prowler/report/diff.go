@@ -0,0 +1,158 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"portprowler/port"
+)
+
+// Change kinds reported by Diff.
+const (
+	HostAdded   = "host-added"
+	HostRemoved = "host-removed"
+	Opened      = "opened"  // port became open
+	Closed      = "closed"  // open port is no longer open
+	Changed     = "changed" // state or service changed otherwise
+)
+
+// Change is one difference between two reports. Host changes leave Port
+// and Proto empty; Before and After describe the port as "state service".
+type Change struct {
+	Kind   string `json:"kind"`
+	Target string `json:"target"`
+	IP     string `json:"ip,omitempty"`
+	Port   uint16 `json:"port,omitempty"`
+	Proto  string `json:"proto,omitempty"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+func (c Change) String() string {
+	if c.Port == 0 && c.Proto == "" {
+		return fmt.Sprintf("%s %s", c.Kind, c.Target)
+	}
+	before, after := c.Before, c.After
+	if before == "" {
+		before = "-"
+	}
+	if after == "" {
+		after = "-"
+	}
+	return fmt.Sprintf("%s %s %d/%s: %s -> %s", c.Kind, c.Target, c.Port, c.Proto, before, after)
+}
+
+// Diff compares two reports host by host (matched by target name) and
+// port by port. A port scanned in only one of the reports counts as a
+// change only when it is open there. Changes are ordered by target, port
+// and protocol.
+func Diff(old, cur ScanReport) []Change {
+	oldHosts := hostIndex(old)
+	curHosts := hostIndex(cur)
+	var out []Change
+	for target, h := range oldHosts {
+		if _, ok := curHosts[target]; !ok {
+			out = append(out, Change{Kind: HostRemoved, Target: target, IP: h.IP})
+		}
+	}
+	for target, h := range curHosts {
+		prev, ok := oldHosts[target]
+		if !ok {
+			out = append(out, Change{Kind: HostAdded, Target: target, IP: h.IP})
+			prev = HostReport{}
+		}
+		out = append(out, diffPorts(target, prev, h)...)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		return a.Proto < b.Proto
+	})
+	return out
+}
+
+func diffPorts(target string, old, cur HostReport) []Change {
+	type key struct {
+		port  uint16
+		proto string
+	}
+	before := make(map[key]port.PortResult)
+	for _, r := range old.Results {
+		if r.Proto != string(port.ScanPing) {
+			before[key{r.Port, r.Proto}] = r
+		}
+	}
+	var out []Change
+	seen := make(map[key]bool)
+	for _, r := range cur.Results {
+		k := key{r.Port, r.Proto}
+		if r.Proto == string(port.ScanPing) || seen[k] {
+			continue
+		}
+		seen[k] = true
+		c := Change{Target: target, IP: r.IP, Port: r.Port, Proto: r.Proto, After: describe(r)}
+		prev, ok := before[k]
+		switch {
+		case !ok:
+			if r.State != "open" {
+				continue
+			}
+			c.Kind = Opened
+		case prev.State != "open" && r.State == "open":
+			c.Kind = Opened
+		case prev.State == "open" && r.State != "open":
+			c.Kind = Closed
+		case describe(prev) != describe(r):
+			c.Kind = Changed
+		default:
+			continue
+		}
+		if ok {
+			c.Before = describe(prev)
+		}
+		out = append(out, c)
+	}
+	for k, r := range before {
+		if !seen[k] && r.State == "open" {
+			out = append(out, Change{Kind: Closed, Target: target, IP: r.IP, Port: r.Port, Proto: r.Proto, Before: describe(r)})
+		}
+	}
+	return out
+}
+
+// describe is the comparable summary of a port result. Services guessed
+// from the port number are left out; they say nothing the port does not.
+func describe(r port.PortResult) string {
+	if r.Service == "" || port.IsGuessed(r) {
+		return r.State
+	}
+	return r.State + " " + r.Service
+}
+
+func hostIndex(rep ScanReport) map[string]HostReport {
+	m := make(map[string]HostReport, len(rep.Hosts))
+	for _, h := range rep.Hosts {
+		name := h.Target
+		if name == "" {
+			name = h.IP
+		}
+		m[name] = h
+	}
+	return m
+}
+
+// ReadJSON decodes a report written by the json output format.
+func ReadJSON(r io.Reader) (ScanReport, error) {
+	var rep ScanReport
+	if err := json.NewDecoder(r).Decode(&rep); err != nil {
+		return ScanReport{}, err
+	}
+	return rep, nil
+}
@@ -0,0 +1,98 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"portprowler/port"
+)
+
+func TestDiff(t *testing.T) {
+	old := Build(Meta{}, []port.Target{
+		{Name: "web.example", IP: "192.0.2.10"},
+		{Name: "gone.example", IP: "192.0.2.30"},
+	}, []port.PortResult{
+		{Target: "web.example", IP: "192.0.2.10", Port: 22, Proto: "tcp", State: "open", Service: "ssh"},
+		{Target: "web.example", IP: "192.0.2.10", Port: 80, Proto: "tcp", State: "open", Service: "http"},
+		{Target: "web.example", IP: "192.0.2.10", Port: 443, Proto: "tcp", State: "closed"},
+		{Target: "web.example", IP: "192.0.2.10", Port: 8080, Proto: "tcp", State: "open"},
+		{Target: "web.example", IP: "192.0.2.10", Port: 3306, Proto: "tcp", State: "open"},
+		{Target: "web.example", IP: "192.0.2.10", Proto: "ping", State: "up", RTTMillis: 3},
+	})
+	cur := Build(Meta{}, []port.Target{
+		{Name: "web.example", IP: "192.0.2.10"},
+		{Name: "new.example", IP: "192.0.2.40"},
+	}, []port.PortResult{
+		{Target: "web.example", IP: "192.0.2.10", Port: 22, Proto: "tcp", State: "open", Service: "ssh"},
+		{Target: "web.example", IP: "192.0.2.10", Port: 80, Proto: "tcp", State: "open", Service: "nginx"},
+		{Target: "web.example", IP: "192.0.2.10", Port: 443, Proto: "tcp", State: "open", Service: "https"},
+		{Target: "web.example", IP: "192.0.2.10", Port: 3306, Proto: "tcp", State: "open", Service: "mysql", ServiceConfidence: "low"},
+		{Target: "web.example", IP: "192.0.2.10", Proto: "ping", State: "up", RTTMillis: 9},
+		{Target: "new.example", IP: "192.0.2.40", Port: 22, Proto: "tcp", State: "closed"},
+	})
+
+	var got []string
+	for _, c := range Diff(old, cur) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"host-removed gone.example",
+		"host-added new.example",
+		"changed web.example 80/tcp: open http -> open nginx",
+		"opened web.example 443/tcp: closed -> open https",
+		"closed web.example 8080/tcp: open -> -",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("diff = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("diff[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+	if d := Diff(cur, cur); len(d) != 0 {
+		t.Fatalf("report differs from itself: %v", d)
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	rep := Build(Meta{PortSpec: "22", Note: "baseline"}, []port.Target{{Name: "a.example", IP: "192.0.2.1"}},
+		[]port.PortResult{{Target: "a.example", IP: "192.0.2.1", Port: 22, Proto: "tcp", State: "open"}})
+	data, err := json.Marshal(rep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadJSON(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Meta.Note != "baseline" || len(got.Hosts) != 1 || got.Hosts[0].Results[0].Port != 22 {
+		t.Fatalf("round trip = %+v", got)
+	}
+
+	// Older reports carried a single confidence for the service.
+	legacy := `{"hosts":[{"target":"a.example","ip":"192.0.2.1","results":[
+		{"ip":"192.0.2.1","port":22,"proto":"tcp","state":"open","service":"ssh","confidence":"high"}]}]}`
+	got, err = ReadJSON(bytes.NewReader([]byte(legacy)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := got.Hosts[0].Results[0]; r.ServiceConfidence != "high" || r.OSConfidence != "" {
+		t.Fatalf("legacy confidence read as %+v", r)
+	}
+	// The scanner overwrote that confidence with OS detection's whenever
+	// it made a guess.
+	legacy = `{"hosts":[{"target":"a.example","ip":"192.0.2.1","results":[
+		{"ip":"192.0.2.1","port":22,"proto":"tcp","state":"open","service":"ssh","os_guess":"Linux","confidence":"medium"}]}]}`
+	got, err = ReadJSON(bytes.NewReader([]byte(legacy)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := got.Hosts[0].Results[0]; r.OSGuess != "Linux" || r.OSConfidence != "medium" || r.ServiceConfidence != "" {
+		t.Fatalf("legacy OS confidence read as %+v", r)
+	}
+	if _, err := ReadJSON(bytes.NewReader([]byte("not json"))); err == nil {
+		t.Fatal("expected an error for invalid input")
+	}
+}
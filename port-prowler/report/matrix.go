@@ -0,0 +1,115 @@
+package report
+
+import (
+	"sort"
+
+	"portprowler/port"
+)
+
+// Reachability of a port across the vantage points of a matrix.
+const (
+	ReachAll     = "all"     // open from every vantage point that scanned it
+	ReachPartial = "partial" // open from some vantage points only
+	ReachNone    = "none"    // open from none
+)
+
+// Vantage is a report together with the name of the network it was
+// scanned from, e.g. "office", "vpn" or "internet".
+type Vantage struct {
+	Name   string
+	Report ScanReport
+}
+
+// MatrixRow is one port of one host as seen from each vantage point.
+type MatrixRow struct {
+	Target  string   `json:"target"`
+	Port    uint16   `json:"port"`
+	Proto   string   `json:"proto"`
+	Service string   `json:"service,omitempty"` // the first service any vantage point identified
+	States  []string `json:"states"`            // per vantage point; "" when it did not scan the port
+	Reach   string   `json:"reach"`             // ReachAll, ReachPartial or ReachNone
+}
+
+// Matrix shows which ports are reachable from where: one column per
+// vantage point and one row per port scanned from any of them.
+type Matrix struct {
+	Vantages []string    `json:"vantages"`
+	Rows     []MatrixRow `json:"rows"`
+}
+
+// stateRank orders states from least to most reachable, for merging the
+// results of a host scanned on several addresses.
+var stateRank = map[string]int{
+	"filtered":      1,
+	"closed":        2,
+	"open|filtered": 3,
+	"open":          4,
+}
+
+// BuildMatrix lines the reports up host by host (matched by target name,
+// as in Diff) and port by port, leaving out ping results. A host scanned
+// on several addresses counts as reachable on a port when any address
+// was. Rows are ordered by target, port and protocol.
+func BuildMatrix(vantages []Vantage) Matrix {
+	type key struct {
+		target string
+		port   uint16
+		proto  string
+	}
+	m := Matrix{Vantages: make([]string, len(vantages))}
+	rows := map[key]*MatrixRow{}
+	for i, v := range vantages {
+		m.Vantages[i] = v.Name
+		for target, h := range hostIndex(v.Report) {
+			for _, r := range h.Results {
+				if r.Proto == string(port.ScanPing) {
+					continue
+				}
+				k := key{target, r.Port, r.Proto}
+				row := rows[k]
+				if row == nil {
+					row = &MatrixRow{Target: target, Port: r.Port, Proto: r.Proto, States: make([]string, len(vantages))}
+					rows[k] = row
+				}
+				if row.States[i] == "" || stateRank[r.State] > stateRank[row.States[i]] {
+					row.States[i] = r.State
+				}
+				if row.Service == "" && r.Service != "" && !port.IsGuessed(r) {
+					row.Service = r.Service
+				}
+			}
+		}
+	}
+	m.Rows = make([]MatrixRow, 0, len(rows))
+	for _, row := range rows {
+		open, scanned := 0, 0
+		for _, s := range row.States {
+			if s != "" {
+				scanned++
+			}
+			if s == "open" {
+				open++
+			}
+		}
+		switch {
+		case open == 0:
+			row.Reach = ReachNone
+		case open == scanned:
+			row.Reach = ReachAll
+		default:
+			row.Reach = ReachPartial
+		}
+		m.Rows = append(m.Rows, *row)
+	}
+	sort.Slice(m.Rows, func(i, j int) bool {
+		a, b := m.Rows[i], m.Rows[j]
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		return a.Proto < b.Proto
+	})
+	return m
+}
@@ -0,0 +1,44 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+
+	"portprowler/port"
+)
+
+func TestBuildMatrix(t *testing.T) {
+	office := Build(Meta{}, []port.Target{{Name: "db.example", IP: "10.0.0.5"}, {Name: "web.example", IP: "192.0.2.10"}}, []port.PortResult{
+		{Target: "db.example", IP: "10.0.0.5", Port: 5432, Proto: "tcp", State: "open", Service: "postgresql"},
+		{Target: "db.example", IP: "10.0.0.5", Port: 22, Proto: "tcp", State: "open", Service: "ssh", ServiceConfidence: "low"},
+		{Target: "db.example", IP: "10.0.0.5", Proto: "ping", State: "up"},
+		{Target: "web.example", IP: "192.0.2.10", Port: 443, Proto: "tcp", State: "open"},
+	})
+	internet := Build(Meta{}, []port.Target{{Name: "db.example", IP: "10.0.0.5"}, {Name: "web.example", IP: "192.0.2.10"}}, []port.PortResult{
+		{Target: "db.example", IP: "10.0.0.5", Port: 5432, Proto: "tcp", State: "filtered"},
+		{Target: "db.example", IP: "10.0.0.5", Port: 22, Proto: "tcp", State: "filtered"},
+		{Target: "web.example", IP: "192.0.2.10", Port: 443, Proto: "tcp", State: "open", Service: "https"},
+		// Scanned on two addresses: reachable on one is reachable.
+		{Target: "web.example", IP: "2001:db8::10", Port: 80, Proto: "tcp", State: "open"},
+		{Target: "web.example", IP: "192.0.2.10", Port: 80, Proto: "tcp", State: "filtered"},
+	})
+	m := BuildMatrix([]Vantage{{"office", office}, {"internet", internet}})
+
+	if !reflect.DeepEqual(m.Vantages, []string{"office", "internet"}) {
+		t.Errorf("vantages %v", m.Vantages)
+	}
+	want := []MatrixRow{
+		{Target: "db.example", Port: 22, Proto: "tcp", States: []string{"open", "filtered"}, Reach: ReachPartial},
+		{Target: "db.example", Port: 5432, Proto: "tcp", Service: "postgresql", States: []string{"open", "filtered"}, Reach: ReachPartial},
+		{Target: "web.example", Port: 80, Proto: "tcp", States: []string{"", "open"}, Reach: ReachAll},
+		{Target: "web.example", Port: 443, Proto: "tcp", Service: "https", States: []string{"open", "open"}, Reach: ReachAll},
+	}
+	if !reflect.DeepEqual(m.Rows, want) {
+		t.Errorf("rows\n got %+v\nwant %+v", m.Rows, want)
+	}
+
+	m = BuildMatrix([]Vantage{{"internet", internet}})
+	if m.Rows[0].Reach != ReachNone {
+		t.Errorf("filtered everywhere: %+v", m.Rows[0])
+	}
+}
@@ -0,0 +1,179 @@
+// Package report groups scan results per host and carries the metadata
+// that output writers render alongside them.
+package report
+
+import (
+	"sort"
+	"time"
+
+	"portprowler/port"
+)
+
+// Meta describes the scan that produced a report.
+type Meta struct {
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	PortSpec string    `json:"port_spec"`         // ports as given on the command line
+	OSDetect bool      `json:"os_detect"`         // whether OS detection was requested
+	Note     string    `json:"note,omitempty"`    // operator note, e.g. "pre-change scan"
+	Context  string    `json:"context,omitempty"` // "internal" or "external" (--context); sets severities
+	Version  string    `json:"version"`           // portprowler version that produced the report
+	Status   string    `json:"status,omitempty"`  // StatusComplete or StatusCancelled
+}
+
+// Report statuses.
+const (
+	StatusComplete  = "complete"
+	StatusCancelled = "cancelled" // stopped early; results are partial
+)
+
+// ScanReport is the complete, per-host view of a scan.
+type ScanReport struct {
+	Meta  Meta         `json:"meta"`
+	Hosts []HostReport `json:"hosts"`
+}
+
+// HostReport holds the results for one scanned address.
+type HostReport struct {
+	Target       string            `json:"target"` // original target as given (hostname or IP)
+	IP           string            `json:"ip"`     // first scanned address
+	Addrs        []string          `json:"addrs"`  // every scanned address, IPv4 first
+	OSGuess      string            `json:"os_guess,omitempty"`
+	OSConfidence string            `json:"os_confidence,omitempty"`
+	RTT          RTTSummary        `json:"rtt"`
+	Notes        []string          `json:"notes,omitempty"`     // operator notes attached with AddHostNote
+	Deception    []string          `json:"deception,omitempty"` // tarpit/honeypot indicators; treat results sceptically
+	Capped       bool              `json:"capped,omitempty"`    // scanning stopped after too many open ports; the rest are missing
+	Down         bool              `json:"down,omitempty"`      // the first ports all timed out and none answered; skipped ports are missing
+	Cloud        *CloudInstance    `json:"cloud,omitempty"`     // the cloud instance holding the address, from an inventory
+	Results      []port.PortResult `json:"results"`
+}
+
+// CloudInstance is the cloud instance behind a host's address.
+type CloudInstance struct {
+	Provider string            `json:"provider"` // aws, gcp or azure
+	ID       string            `json:"id"`
+	Name     string            `json:"name,omitempty"`
+	Account  string            `json:"account,omitempty"` // AWS account, GCP project or Azure subscription
+	Region   string            `json:"region,omitempty"`
+	Zone     string            `json:"zone,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"` // tags, or labels on GCP
+}
+
+// RTTSummary summarises round-trip times of probes that got an answer.
+type RTTSummary struct {
+	Samples      int   `json:"samples"`
+	MinMillis    int64 `json:"min_ms"`
+	MedianMillis int64 `json:"median_ms"`
+	MaxMillis    int64 `json:"max_ms"`
+}
+
+// Build groups results by logical host (the target as given), keeping
+// hosts in the order of targets. A target scanned on several addresses
+// (e.g. IPv4 and IPv6) is one host listing every address in Addrs.
+// Results for hosts not listed in targets are appended in first-seen order.
+func Build(meta Meta, targets []port.Target, results []port.PortResult) ScanReport {
+	rep := ScanReport{Meta: meta}
+	index := make(map[string]int)
+	add := func(name, ip string) int {
+		key := name
+		if key == "" {
+			key = ip
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(rep.Hosts)
+			index[key] = i
+			rep.Hosts = append(rep.Hosts, HostReport{Target: name, IP: ip})
+		}
+		h := &rep.Hosts[i]
+		for _, a := range h.Addrs {
+			if a == ip {
+				return i
+			}
+		}
+		h.Addrs = append(h.Addrs, ip)
+		return i
+	}
+	for _, t := range targets {
+		add(t.Name, t.IP)
+	}
+	for _, r := range results {
+		i := add(r.Target, r.IP)
+		rep.Hosts[i].Results = append(rep.Hosts[i].Results, r)
+	}
+	for i := range rep.Hosts {
+		rep.Hosts[i].RTT = SummarizeRTT(rep.Hosts[i].Results)
+	}
+	return rep
+}
+
+// answered reports whether a result's RTT reflects a real reply rather
+// than a timeout.
+func answered(r port.PortResult) bool {
+	switch r.State {
+	case "open", "closed", "up":
+		return true
+	}
+	return false
+}
+
+// SummarizeRTT computes min/median/max RTT over answered probes.
+func SummarizeRTT(results []port.PortResult) RTTSummary {
+	var rtts []int64
+	for _, r := range results {
+		if answered(r) {
+			rtts = append(rtts, r.RTTMillis)
+		}
+	}
+	if len(rtts) == 0 {
+		return RTTSummary{}
+	}
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	median := rtts[len(rtts)/2]
+	if len(rtts)%2 == 0 {
+		median = (rtts[len(rtts)/2-1] + rtts[len(rtts)/2]) / 2
+	}
+	return RTTSummary{
+		Samples:      len(rtts),
+		MinMillis:    rtts[0],
+		MedianMillis: median,
+		MaxMillis:    rtts[len(rtts)-1],
+	}
+}
+
+// SortByRTT orders hosts by median RTT so nearby (likely internal) hosts
+// come first. Hosts without any answered probe sort last; ties keep their
+// original order.
+func (r *ScanReport) SortByRTT() {
+	sort.SliceStable(r.Hosts, func(i, j int) bool {
+		a, b := r.Hosts[i].RTT, r.Hosts[j].RTT
+		if (a.Samples == 0) != (b.Samples == 0) {
+			return a.Samples != 0
+		}
+		return a.MedianMillis < b.MedianMillis
+	})
+}
+
+// AddHostNote attaches a note to every host whose target name or one of
+// whose addresses equals host. It reports whether any host matched.
+func (r *ScanReport) AddHostNote(host, note string) bool {
+	matched := false
+	for i := range r.Hosts {
+		h := &r.Hosts[i]
+		if h.Target == host || h.hasAddr(host) {
+			h.Notes = append(h.Notes, note)
+			matched = true
+		}
+	}
+	return matched
+}
+
+func (h *HostReport) hasAddr(ip string) bool {
+	for _, a := range h.Addrs {
+		if a == ip {
+			return true
+		}
+	}
+	return h.IP == ip
+}
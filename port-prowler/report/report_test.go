@@ -0,0 +1,84 @@
+package report
+
+import (
+	"testing"
+
+	"portprowler/port"
+)
+
+func TestBuildAndSortByRTT(t *testing.T) {
+	targets := []port.Target{
+		{Name: "far.example", IP: "203.0.113.5"},
+		{Name: "dead.example", IP: "203.0.113.9"},
+		{Name: "near.example", IP: "10.0.0.2"},
+	}
+	results := []port.PortResult{
+		{Target: "far.example", IP: "203.0.113.5", Port: 22, State: "open", RTTMillis: 80},
+		{Target: "far.example", IP: "203.0.113.5", Port: 80, State: "closed", RTTMillis: 90},
+		{Target: "far.example", IP: "203.0.113.5", Port: 81, State: "filtered", RTTMillis: 1000},
+		{Target: "dead.example", IP: "203.0.113.9", Port: 22, State: "filtered", RTTMillis: 1000},
+		{Target: "near.example", IP: "10.0.0.2", Port: 22, State: "open", RTTMillis: 1},
+		{Target: "near.example", IP: "10.0.0.2", Port: 80, State: "closed", RTTMillis: 3},
+		{Target: "near.example", IP: "10.0.0.2", Port: 81, State: "closed", RTTMillis: 2},
+	}
+	rep := Build(Meta{}, targets, results)
+	if len(rep.Hosts) != 3 || rep.Hosts[0].IP != "203.0.113.5" {
+		t.Fatalf("hosts not in target order: %+v", rep.Hosts)
+	}
+	if got := rep.Hosts[0].RTT; got.Samples != 2 || got.MedianMillis != 85 || got.MaxMillis != 90 {
+		t.Fatalf("far rtt summary = %+v (filtered probes must be excluded)", got)
+	}
+
+	rep.SortByRTT()
+	order := []string{rep.Hosts[0].Target, rep.Hosts[1].Target, rep.Hosts[2].Target}
+	want := []string{"near.example", "far.example", "dead.example"}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("sorted order = %v, want %v", order, want)
+		}
+	}
+	if rep.Hosts[0].RTT.MedianMillis != 2 {
+		t.Fatalf("near median = %d, want 2", rep.Hosts[0].RTT.MedianMillis)
+	}
+}
+
+func TestAddHostNote(t *testing.T) {
+	rep := Build(Meta{Note: "pre-change scan"}, []port.Target{
+		{Name: "web.example", IP: "192.0.2.10"},
+		{Name: "db.example", IP: "192.0.2.20"},
+	}, nil)
+	if !rep.AddHostNote("web.example", "owned by team A") || !rep.AddHostNote("192.0.2.10", "behind WAF") {
+		t.Fatalf("expected notes to match by name and by IP")
+	}
+	if rep.AddHostNote("unknown.example", "x") {
+		t.Fatalf("unexpected match for unknown host")
+	}
+	if got := rep.Hosts[0].Notes; len(got) != 2 || got[1] != "behind WAF" {
+		t.Fatalf("notes = %v", got)
+	}
+	if len(rep.Hosts[1].Notes) != 0 {
+		t.Fatalf("note leaked to other host: %v", rep.Hosts[1].Notes)
+	}
+}
+
+func TestBuildGroupsAddressesOfOneTarget(t *testing.T) {
+	targets := []port.Target{
+		{Name: "dual.example", IP: "192.0.2.10"},
+		{Name: "dual.example", IP: "2001:db8::10"},
+	}
+	results := []port.PortResult{
+		{Target: "dual.example", IP: "2001:db8::10", Port: 443, State: "open"},
+		{Target: "dual.example", IP: "192.0.2.10", Port: 443, State: "filtered"},
+	}
+	rep := Build(Meta{}, targets, results)
+	if len(rep.Hosts) != 1 {
+		t.Fatalf("got %d hosts, want one logical host: %+v", len(rep.Hosts), rep.Hosts)
+	}
+	h := rep.Hosts[0]
+	if h.IP != "192.0.2.10" || len(h.Addrs) != 2 || h.Addrs[1] != "2001:db8::10" || len(h.Results) != 2 {
+		t.Fatalf("unexpected host: %+v", h)
+	}
+	if !rep.AddHostNote("2001:db8::10", "v6 only exposes 443") {
+		t.Fatalf("note by secondary address did not match")
+	}
+}
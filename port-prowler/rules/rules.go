@@ -0,0 +1,395 @@
+// Package rules post-processes scan results after user-defined rules read
+// from YAML files: each rule matches results by target, address, port,
+// protocol, state, service or banner and sets fields on them, annotates
+// them or drops them, e.g. to name an in-house service or to suppress a
+// port known to be noisy.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strings"
+
+	"portprowler/detector"
+	"portprowler/port"
+	"portprowler/report"
+	"portprowler/yaml"
+)
+
+// Fields a rule can set.
+const (
+	FieldService           = "service"
+	FieldServiceConfidence = "service_confidence"
+	FieldSeverity          = "severity"
+)
+
+// Rule is one rule of a rules file. A result matches when it meets every
+// condition given; a condition with several values (a list) is met by any
+// of them. Matching results get Set applied and Note appended, then are
+// removed when Drop is set.
+type Rule struct {
+	Name string
+	Set  map[string]string // field (FieldService, ...) to value
+	Note string
+	Drop bool
+
+	targets  []string // globs over the target name
+	nets     []*net.IPNet
+	ports    map[uint16]bool
+	protos   []string
+	states   []string
+	services []string // globs over the service name
+	banners  []string // lower-cased banner substrings
+}
+
+// Set is the rules of one or more files, applied in order.
+type Set []Rule
+
+// Load reads rules files; the rules of later files follow those of
+// earlier ones.
+func Load(paths ...string) (Set, error) {
+	var out Set
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		rs, err := Parse(string(data))
+		if err != nil {
+			return nil, errors.New(p + ": " + err.Error())
+		}
+		out = append(out, rs...)
+	}
+	return out, nil
+}
+
+// Parse reads a rules file: a list of rules, either at the top level or
+// under a "rules" key. Each rule has an optional "name", a "match"
+// mapping of conditions ("target", "ip", "port", "proto", "state",
+// "service", "banner"; each a value or a list) and at least one action:
+// a "set" mapping of fields, a "note" or "drop: true". Targets and
+// services match as shell globs ("*.lab", "http*"), ignoring case; ip
+// takes addresses and CIDR blocks, port numbers and ranges ("8000-8100").
+func Parse(data string) (Set, error) {
+	v, err := yaml.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := v.(map[string]any); ok {
+		v = m["rules"]
+	}
+	items, ok := v.([]any)
+	if !ok {
+		return nil, errors.New("want a list of rules, at the top level or under rules:")
+	}
+	out := make(Set, 0, len(items))
+	for i, item := range items {
+		r, err := parseRule(item)
+		if err != nil {
+			if name := ruleName(item); name != "" {
+				return nil, fmt.Errorf("rule %d (%s): %v", i+1, name, err)
+			}
+			return nil, fmt.Errorf("rule %d: %v", i+1, err)
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func ruleName(item any) string {
+	m, _ := item.(map[string]any)
+	name, _ := m["name"].(string)
+	return name
+}
+
+func parseRule(item any) (Rule, error) {
+	m, ok := item.(map[string]any)
+	if !ok {
+		return Rule{}, errors.New("want a mapping")
+	}
+	var r Rule
+	for key, v := range m {
+		switch key {
+		case "name":
+			r.Name, _ = v.(string)
+		case "note":
+			s, ok := v.(string)
+			if !ok || s == "" {
+				return Rule{}, errors.New("note must be text")
+			}
+			r.Note = s
+		case "drop":
+			b, ok := v.(bool)
+			if !ok {
+				return Rule{}, errors.New("drop must be true or false")
+			}
+			r.Drop = b
+		case "set":
+			set, ok := v.(map[string]any)
+			if !ok {
+				return Rule{}, errors.New("set must be a mapping of fields")
+			}
+			r.Set = make(map[string]string, len(set))
+			for field, fv := range set {
+				s, _ := fv.(string)
+				if fv != nil && s == "" {
+					return Rule{}, fmt.Errorf("set %s: want a value", field)
+				}
+				if err := checkField(field, s); err != nil {
+					return Rule{}, err
+				}
+				r.Set[field] = s
+			}
+		case "match":
+			cond, ok := v.(map[string]any)
+			if !ok {
+				return Rule{}, errors.New("match must be a mapping of conditions")
+			}
+			if err := r.parseMatch(cond); err != nil {
+				return Rule{}, err
+			}
+		default:
+			return Rule{}, fmt.Errorf("unknown key %q (name, match, set, note or drop)", key)
+		}
+	}
+	if len(r.Set) == 0 && r.Note == "" && !r.Drop {
+		return Rule{}, errors.New("no action; give set, note or drop")
+	}
+	return r, nil
+}
+
+// checkField validates a value set on field; an empty value clears it.
+func checkField(field, value string) error {
+	switch field {
+	case FieldService:
+	case FieldServiceConfidence:
+		switch value {
+		case "", port.ConfidenceLow, port.ConfidenceMedium, port.ConfidenceHigh:
+		default:
+			return fmt.Errorf("set %s: unknown confidence %q (low, medium or high)", field, value)
+		}
+	case FieldSeverity:
+		if value != "" && detector.SeverityRank(value) < 0 {
+			return fmt.Errorf("set %s: unknown severity %q (info, low, medium, high or critical)", field, value)
+		}
+	default:
+		return fmt.Errorf("cannot set %q (service, service_confidence or severity)", field)
+	}
+	return nil
+}
+
+func (r *Rule) parseMatch(cond map[string]any) error {
+	for key, v := range cond {
+		values, err := strList(v)
+		if err != nil {
+			return fmt.Errorf("match %s: %v", key, err)
+		}
+		switch key {
+		case "target", "service":
+			for _, g := range values {
+				g = strings.ToLower(g)
+				if _, err := path.Match(g, ""); err != nil {
+					return fmt.Errorf("match %s: invalid pattern %q", key, g)
+				}
+				if key == "target" {
+					r.targets = append(r.targets, g)
+				} else {
+					r.services = append(r.services, g)
+				}
+			}
+		case "ip":
+			for _, s := range values {
+				if !strings.Contains(s, "/") {
+					ip := net.ParseIP(s)
+					if ip == nil {
+						return fmt.Errorf("match ip: invalid address %q", s)
+					}
+					bits := 8 * len(ip.To16())
+					if ip.To4() != nil {
+						ip, bits = ip.To4(), 32
+					}
+					r.nets = append(r.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+					continue
+				}
+				_, n, err := net.ParseCIDR(s)
+				if err != nil {
+					return fmt.Errorf("match ip: invalid block %q", s)
+				}
+				r.nets = append(r.nets, n)
+			}
+		case "port":
+			ports, err := port.ParsePortSpec(strings.Join(values, ","))
+			if err != nil {
+				return fmt.Errorf("match port: %v", err)
+			}
+			r.ports = make(map[uint16]bool, len(ports))
+			for _, p := range ports {
+				r.ports[p] = true
+			}
+		case "proto":
+			r.protos = lower(values)
+		case "state":
+			r.states = lower(values)
+		case "banner":
+			r.banners = lower(values)
+		default:
+			return fmt.Errorf("unknown condition %q (target, ip, port, proto, state, service or banner)", key)
+		}
+	}
+	return nil
+}
+
+// strList reads a condition's value or list of values.
+func strList(v any) ([]string, error) {
+	var items []any
+	switch v := v.(type) {
+	case []any:
+		items = v
+	case nil:
+		return nil, errors.New("want a value")
+	default:
+		items = []any{v}
+	}
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok || s == "" {
+			return nil, errors.New("want text or numbers")
+		}
+		out = append(out, s)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("want at least one value")
+	}
+	return out, nil
+}
+
+func lower(values []string) []string {
+	for i := range values {
+		values[i] = strings.ToLower(values[i])
+	}
+	return values
+}
+
+// Match reports whether r matches a result of the host named target.
+func (r *Rule) Match(target string, res port.PortResult) bool {
+	if len(r.targets) > 0 && !glob(r.targets, target) {
+		return false
+	}
+	if len(r.nets) > 0 {
+		ip := net.ParseIP(res.IP)
+		found := false
+		for _, n := range r.nets {
+			if ip != nil && n.Contains(ip) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.ports != nil && !r.ports[res.Port] {
+		return false
+	}
+	if len(r.protos) > 0 && !contains(r.protos, res.Proto) {
+		return false
+	}
+	if len(r.states) > 0 && !contains(r.states, res.State) {
+		return false
+	}
+	if len(r.services) > 0 && !glob(r.services, res.Service) {
+		return false
+	}
+	if len(r.banners) > 0 {
+		b := strings.ToLower(res.ServiceBanner)
+		found := false
+		for _, s := range r.banners {
+			if strings.Contains(b, s) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func glob(patterns []string, s string) bool {
+	s = strings.ToLower(s)
+	for _, g := range patterns {
+		if ok, _ := path.Match(g, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// apply sets r's fields and note on res.
+func (r *Rule) apply(res *port.PortResult) {
+	for f, v := range r.Set {
+		switch f {
+		case FieldService:
+			res.Service = v
+		case FieldServiceConfidence:
+			res.ServiceConfidence = v
+		case FieldSeverity:
+			res.Severity = v
+		}
+	}
+	if r.Note != "" {
+		res.Notes = append(res.Notes, r.Note)
+	}
+}
+
+// Apply runs the rules over every result of rep in order, each rule
+// seeing what earlier ones set; a dropped result is not offered to later
+// rules. It returns how many results matched a rule and how many were
+// dropped.
+func (s Set) Apply(rep *report.ScanReport) (matched, dropped int) {
+	for i := range rep.Hosts {
+		h := &rep.Hosts[i]
+		kept := h.Results[:0]
+	results:
+		for _, res := range h.Results {
+			target := res.Target
+			if target == "" {
+				target = h.Target
+			}
+			hit := false
+			for j := range s {
+				r := &s[j]
+				if !r.Match(target, res) {
+					continue
+				}
+				hit = true
+				r.apply(&res)
+				if r.Drop {
+					matched++
+					dropped++
+					continue results
+				}
+			}
+			if hit {
+				matched++
+			}
+			kept = append(kept, res)
+		}
+		h.Results = kept
+	}
+	return matched, dropped
+}
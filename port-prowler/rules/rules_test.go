@@ -0,0 +1,94 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"portprowler/port"
+	"portprowler/report"
+)
+
+const rulesFile = `rules:
+- name: jenkins
+  match:
+    port: 8080
+    service: "http*"
+  set:
+    service: jenkins
+    service_confidence: high
+- name: netbios noise
+  match: {proto: udp, port: [137, 138]}
+  drop: true
+- match:
+    ip: 10.0.5.0/24
+    target: "*.lab"
+  note: bastion, expected
+  set: {severity: info}
+- match:
+    banner: OpenSSH_7
+  note: outdated OpenSSH
+`
+
+func TestApply(t *testing.T) {
+	rs, err := Parse(rulesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 4 || rs[0].Name != "jenkins" || !rs[1].Drop {
+		t.Fatalf("unexpected rules %+v", rs)
+	}
+	rep := report.Build(report.Meta{}, []port.Target{{Name: "gw.lab", IP: "10.0.5.1"}, {Name: "ci", IP: "192.0.2.8"}}, []port.PortResult{
+		{Target: "gw.lab", IP: "10.0.5.1", Port: 22, Proto: "tcp", State: "open", Service: "ssh", ServiceBanner: "SSH-2.0-OpenSSH_7.4", Severity: "medium"},
+		{Target: "gw.lab", IP: "10.0.5.1", Port: 137, Proto: "udp", State: "open|filtered"},
+		{Target: "ci", IP: "192.0.2.8", Port: 8080, Proto: "tcp", State: "open", Service: "http?"},
+		{Target: "ci", IP: "192.0.2.8", Port: 137, Proto: "tcp", State: "closed"},
+	})
+	matched, dropped := rs.Apply(&rep)
+	if matched != 3 || dropped != 1 {
+		t.Errorf("matched %d and dropped %d, want 3 and 1", matched, dropped)
+	}
+	gw, ci := rep.Hosts[0].Results, rep.Hosts[1].Results
+	if len(gw) != 1 || gw[0].Severity != "info" || strings.Join(gw[0].Notes, "; ") != "bastion, expected; outdated OpenSSH" {
+		t.Errorf("unexpected gw.lab results %+v", gw)
+	}
+	if len(ci) != 2 || ci[0].Service != "jenkins" || ci[0].ServiceConfidence != "high" || ci[1].Service != "" {
+		t.Errorf("unexpected ci results %+v", ci)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, bad := range []string{
+		"a: b\n",
+		"- match: {port: 22}\n",
+		"- match: {port: 70000}\n  drop: true\n",
+		"- match: {colour: red}\n  drop: true\n",
+		"- match: {ip: 10.0.0.0/33}\n  drop: true\n",
+		"- set: {state: closed}\n",
+		"- set: {severity: dire}\n",
+		"- name: x\n  drop: yes\n",
+	} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("want an error for %q", bad)
+		}
+	}
+	if _, err := Parse("- name: quiet\n  match: {port: 22}\n  set: {service: [a]}\n"); err == nil || !strings.Contains(err.Error(), "rule 1 (quiet)") {
+		t.Errorf("want the rule named in the error, got %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")
+	os.WriteFile(a, []byte(rulesFile), 0o600)
+	os.WriteFile(b, []byte("- [x]\n"), 0o600)
+	if _, err := Load(a, b); err == nil || !strings.Contains(err.Error(), "b.yaml") {
+		t.Errorf("want an error naming b.yaml, got %v", err)
+	}
+	os.WriteFile(b, []byte("- note: everything\n"), 0o600)
+	rs, err := Load(a, b)
+	if err != nil || len(rs) != 5 || rs[4].Note != "everything" {
+		t.Fatalf("unexpected rules %+v %v", rs, err)
+	}
+}
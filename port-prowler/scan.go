@@ -0,0 +1,1075 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"portprowler/age"
+	"portprowler/audit"
+	"portprowler/cloud"
+	"portprowler/ct"
+	"portprowler/detector"
+	"portprowler/i18n"
+	"portprowler/logging"
+	"portprowler/netutil"
+	"portprowler/notify"
+	"portprowler/output"
+	"portprowler/port"
+	"portprowler/rawsock"
+	"portprowler/report"
+	"portprowler/rules"
+	"portprowler/scanner"
+	"portprowler/signing"
+	"portprowler/sigs"
+	"portprowler/stats"
+	"portprowler/upload"
+	"portprowler/version"
+	"portprowler/wire"
+)
+
+// scanFlags are the scan settings shared by the scan and watch commands;
+// serve fills them from API requests.
+type scanFlags struct {
+	ports          string
+	tcp            bool
+	udp            bool
+	stealth        bool
+	ping           bool
+	pingGate       bool
+	gateTimeout    time.Duration
+	defaultModes   string
+	fileOut        string
+	outputs        stringList
+	serviceDetect  bool
+	osDetect       bool
+	workers        int
+	timeout        time.Duration
+	tcpTimeout     time.Duration
+	udpTimeout     time.Duration
+	udpGrace       time.Duration
+	dnsQuery       string
+	dnsNoRecurse   bool
+	stealthTimeout time.Duration
+	sigFile        string
+	fingerprintOut string
+	detectTimeout  time.Duration
+	sni            string
+	insecure       bool
+	tlsCiphers     string
+	tlsALPN        string
+	httpCapture    int
+	bannerLimit    int
+	hexDump        int
+	detectBytes    int64
+	maxOpen        int
+	firstOpen      bool
+	deadAfter      int
+	skipDead       bool
+	likelyFirst    bool
+	quietHours     string
+	quietWorkers   int
+	jitter         string
+	maxBandwidth   string
+	hostBandwidth  string
+	rstClose       bool
+	stateless      bool
+	fastIO         bool
+	synShards      int
+	pinCPUs        string
+	vhosts         bool
+	proxies        stringList
+	proxyTimeout   time.Duration
+	via            string
+	viaKey         string
+	viaKnownHosts  string
+	viaInsecure    bool
+	dualStack      bool
+	allIPs         bool
+	allowPrivate   bool
+	allowLoopback  bool
+	scope          string
+	force          bool
+	requireIface   string
+	replay         string
+	audit          string
+	progressJSON   string
+	jsonrpc        bool
+	note           string
+	scanContext    string
+	hostNotes      stringList
+	cloudInventory stringList
+	rulesFiles     stringList
+	hostsFile      string
+	ctDomains      stringList
+	ctURL          string
+	sortRTT        bool
+	save           bool
+	redact         bool
+	redactMap      string
+	notifyEmail    stringList
+	smtpServer     string
+	smtpFrom       string
+	smtpUser       string
+	incident       string
+	incidentMin    string
+	issue          string
+	issueProject   string
+	issueURL       string
+	issueUser      string
+	issueTemplate  string
+	issueMin       string
+	upload         string
+	uploadSSE      string
+	uploadKMS      string
+	sign           string
+	encrypt        stringList
+	profile        string
+	lang           string
+	verbose        bool
+	silent         bool
+}
+
+// defaultScanFlags returns the flag defaults, for callers that do not
+// parse a command line. They come from register, so the two cannot
+// disagree.
+func defaultScanFlags() scanFlags {
+	var f scanFlags
+	f.register(flag.NewFlagSet("defaults", flag.ContinueOnError))
+	return f
+}
+
+// register defines the scan flags on fs.
+func (f *scanFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.ports, "p", "", "ports (e.g. 22,80,8000-8100) (required)")
+	fs.BoolVar(&f.tcp, "tcp", false, "perform tcp connect scan")
+	fs.BoolVar(&f.udp, "udp", false, "perform udp scan")
+	fs.BoolVar(&f.stealth, "s", false, "perform stealth scan (requires privileges)")
+	fs.BoolVar(&f.ping, "ping", false, "check host reachability (icmp echo when privileged, udp ping otherwise)")
+	fs.BoolVar(&f.pingGate, "ping-gate", false, "ping each host (icmp or udp, then tcp 443 and 80) before scanning it; hosts that answer none are reported unreachable and their ports skipped")
+	fs.DurationVar(&f.gateTimeout, "ping-gate-timeout", 0, "how long each --ping-gate ping waits for an answer (default -t)")
+	fs.StringVar(&f.defaultModes, "default-modes", "tcp", "scan modes used when none of -tcp, -udp, -s and -ping is given: comma-separated tcp, udp, stealth, ping (e.g. tcp,udp)")
+	fs.StringVar(&f.fileOut, "f", "", "write output to file (overwrite, atomic)")
+	fs.Var(&f.outputs, "o", "output as format[=path], repeatable; formats: "+strings.Join(output.Formats, ", ")+", template=file.tmpl (default table to stdout)")
+	fs.BoolVar(&f.serviceDetect, "service-detect", false, "enable service detection (opt-in)")
+	fs.BoolVar(&f.osDetect, "os-detect", false, "enable os detection (opt-in)")
+	fs.IntVar(&f.workers, "c", 100, "worker count (default 100)")
+	fs.DurationVar(&f.timeout, "t", time.Second, "per-probe timeout (default 1s)")
+	fs.DurationVar(&f.tcpTimeout, "tcp-timeout", 0, "tcp connect timeout (defaults to -t)")
+	fs.DurationVar(&f.udpTimeout, "udp-timeout", 0, "udp probe timeout (defaults to -t; UDP often needs 2-3x)")
+	fs.DurationVar(&f.udpGrace, "udp-grace", 0, "keep udp probes that timed out listening this much longer and upgrade open|filtered ports that answer late")
+	fs.StringVar(&f.dnsQuery, "dns-query", "", "query udp probes send to port 53, as name[/type[/class]], e.g. example.org/NS or version.bind (default example.com/A)")
+	fs.BoolVar(&f.dnsNoRecurse, "dns-no-recurse", false, "send the port 53 query without the recursion-desired flag")
+	fs.DurationVar(&f.stealthTimeout, "stealth-timeout", 0, "stealth probe timeout (defaults to -t)")
+	fs.BoolVar(&f.stateless, "stateless", false, "with -s, send SYNs without tracking them and report only ports that answer (cookie-matched, constant memory)")
+	fs.BoolVar(&f.fastIO, "fast-io", false, "with -s, send and receive through PACKET_MMAP rings (Linux builds with -tags fastio)")
+	fs.IntVar(&f.synShards, "syn-shards", 1, fmt.Sprintf("with -s, run N stealth engines per interface, each with its own socket and send/receive goroutines (1-%d)", scanner.MaxSYNShards))
+	fs.StringVar(&f.pinCPUs, "pin-cpus", "", "with -s, pin the stealth engines' goroutines to these CPUs (e.g. 0-3,8) or to the interface's NUMA node (numa); Linux only")
+	fs.StringVar(&f.sigFile, "sig-file", "", "extra service signatures, one substring|service|confidence per line (checked before built-ins)")
+	fs.StringVar(&f.fingerprintOut, "fingerprint-out", "", "write banners that matched no signature to this file as JSON Lines (requires --service-detect)")
+	fs.DurationVar(&f.detectTimeout, "detect-timeout", 0, "total service detection time per open port (defaults to 3x the probe timeout)")
+	fs.StringVar(&f.sni, "sni", "", "TLS server name for inspection (defaults to the target hostname)")
+	fs.BoolVar(&f.insecure, "insecure", false, "collect TLS certificates even when the chain does not verify")
+	fs.StringVar(&f.tlsCiphers, "tls-ciphers", "", "comma-separated cipher suites offered by TLS probes (names or 0x ids; limits probes to TLS 1.2)")
+	fs.StringVar(&f.tlsALPN, "tls-alpn", "", "comma-separated ALPN protocols offered by TLS probes (e.g. h2,http/1.1)")
+	fs.IntVar(&f.httpCapture, "http-capture", 0, "keep the first N response body bytes and <title> of web ports (requires --service-detect)")
+	fs.IntVar(&f.bannerLimit, "banner-limit", 0, fmt.Sprintf("read at most N bytes of a service banner, 1-%d (default 1024 for greetings, 2048 after a probe)", wire.MaxBanner))
+	fs.IntVar(&f.hexDump, "hexdump", 0, fmt.Sprintf("show the first N bytes (1-%d) of banners that identify no service as a hex and ASCII dump (requires --service-detect)", wire.MaxBanner))
+	fs.Int64Var(&f.detectBytes, "detect-bytes", scanner.DefaultDetectBytes, "total bytes service detection may read from one host")
+	fs.IntVar(&f.maxOpen, "max-open-per-host", 0, "stop scanning a host once N of its ports are open (a middlebox answering everything) and mark it capped")
+	fs.BoolVar(&f.likelyFirst, "likely-first", false, "probe each host's most commonly open ports first (top-ports table) so findings show up early; all requested ports are still scanned")
+	fs.BoolVar(&f.firstOpen, "first-open", false, "stop scanning a host as soon as one of its ports is open (liveness sweeps)")
+	fs.IntVar(&f.deadAfter, "dead-after", 10, "mark a host down when its first N tcp/stealth ports all time out and none answers (0 = never)")
+	fs.BoolVar(&f.skipDead, "skip-dead-hosts", false, "skip the remaining ports of hosts marked down (see --dead-after)")
+	fs.StringVar(&f.quietHours, "quiet-hours", "", "pause during this daily window, e.g. \"mon-fri 08:00-18:00 Europe/Berlin\" (days and zone optional; see --quiet-workers)")
+	fs.IntVar(&f.quietWorkers, "quiet-workers", 0, "during --quiet-hours run this many workers instead of pausing")
+	fs.StringVar(&f.jitter, "jitter", "", "wait a random delay in this range between probes to the same host, e.g. 50-250ms")
+	fs.StringVar(&f.maxBandwidth, "max-bandwidth", "", "cap probe traffic of the whole scan, e.g. 5mbps or 512KB/s (counts headers and payloads)")
+	fs.StringVar(&f.hostBandwidth, "max-host-bandwidth", "", "cap probe traffic to each address, e.g. 256kbps")
+	fs.BoolVar(&f.rstClose, "rst-close", false, "close connect-scan sockets with a RST instead of a FIN (avoids TIME_WAIT buildup at high -c)")
+	fs.BoolVar(&f.vhosts, "vhosts", false, "re-request open web ports with each hostname as Host header/SNI and report name-based virtual hosts")
+	fs.Var(&f.proxies, "proxy", "route tcp connect probes through a socks5:// or http:// proxy; repeat to chain hops in order")
+	fs.DurationVar(&f.proxyTimeout, "proxy-timeout", netutil.DefaultHopTimeout, "timeout for reaching and negotiating each proxy hop (per hop: ?timeout=3s)")
+	fs.StringVar(&f.via, "via", "", "scan through an ssh jump host (ssh://user@bastion[:port]); tcp connect probes run from the bastion")
+	fs.StringVar(&f.viaKey, "via-key", "", "private key for --via (defaults to the ssh agent and ~/.ssh/id_*)")
+	fs.StringVar(&f.viaKnownHosts, "via-known-hosts", "", "known_hosts file verifying the --via host (default ~/.ssh/known_hosts)")
+	fs.BoolVar(&f.viaInsecure, "via-insecure-hostkey", false, "do not verify the --via host key")
+	fs.BoolVar(&f.dualStack, "dual-stack", false, "scan both the IPv4 and IPv6 address of hostnames with A and AAAA records")
+	fs.BoolVar(&f.allIPs, "all-ips", false, "scan every resolved address of a hostname (DNS round-robin, CDN origins), grouped by hostname")
+	fs.BoolVar(&f.allowPrivate, "allow-private", false, "scan hostnames that resolve to private, link-local, multicast or reserved addresses (implied by --context internal)")
+	fs.BoolVar(&f.allowLoopback, "allow-loopback", false, "scan hostnames other than localhost that resolve to a loopback address")
+	fs.StringVar(&f.scope, "scope", "", "file of authorized CIDR blocks and addresses, one per line; targets outside it are refused")
+	fs.BoolVar(&f.force, "force", false, "scan targets outside --scope anyway, with a warning")
+	fs.StringVar(&f.requireIface, "require-iface", "", "abort unless every target routes via this interface (e.g. wg0)")
+	fs.StringVar(&f.replay, "replay", "", "rebuild results from an --audit log or pcap capture instead of scanning (sends no traffic)")
+	fs.StringVar(&f.audit, "audit", "", "append every probe and connection (time, source, destination, outcome) to this file as JSON Lines")
+	fs.StringVar(&f.progressJSON, "progress-json", "", "write NDJSON progress events (completed, total, rate, eta) every second to stderr (-) or this file or named pipe")
+	fs.BoolVar(&f.jsonrpc, "jsonrpc", false, "read scan requests as JSON-RPC 2.0 from stdin, one per line, and answer on stdout; other flags are defaults for every request")
+	fs.StringVar(&f.scanContext, "context", "", "where the scan runs from, internal or external; rates open ports by exposure and tunes OS heuristics")
+	fs.StringVar(&f.note, "note", "", "operator note stored in the report metadata (e.g. \"pre-change scan\")")
+	fs.Var(&f.hostNotes, "host-note", "attach a note to one host as target=text (repeatable)")
+	fs.StringVar(&f.hostsFile, "hosts-file", "", "also scan every hostname of this DNS zone file or subdomain list (- for stdin); names that do not resolve are skipped")
+	fs.Var(&f.ctDomains, "ct-domain", "also scan the hostnames certificate transparency logs list for this domain and its subdomains (repeatable)")
+	fs.StringVar(&f.ctURL, "ct-url", ct.DefaultURL, "crt.sh-compatible search API queried by --ct-domain")
+	fs.Var(&f.cloudInventory, "cloud-inventory", "attach the instance ID, account, region and tags of hosts found in this AWS, GCP or Azure instance export (repeatable)")
+	fs.Var(&f.rulesFiles, "rules", "rewrite, annotate or drop results matching the rules of this YAML file before output (repeatable)")
+	fs.BoolVar(&f.sortRTT, "sort-rtt", false, "order hosts by median RTT (nearest first)")
+	fs.StringVar(&f.profile, "profile", "", "take flags not given on the command line from this saved profile (or profile .json file)")
+	fs.BoolVar(&f.save, "save", false, "store the report in the scan history (see portprowler history)")
+	fs.BoolVar(&f.redact, "redact", false, "replace target names and addresses in the output with stable tokens, kept in --redact-map")
+	fs.StringVar(&f.redactMap, "redact-map", "", "mapping file of --redact tokens and their originals (default ~/.config/portprowler/redact.json)")
+	fs.Var(&f.notifyEmail, "notify-email", "email the summary and JSON report to this address after the scan (repeatable; needs --smtp-server and --smtp-from)")
+	fs.StringVar(&f.smtpServer, "smtp-server", "", "SMTP server for --notify-email as host:port (465 uses TLS, others STARTTLS when offered)")
+	fs.StringVar(&f.smtpFrom, "smtp-from", "", "sender address of --notify-email mails")
+	fs.StringVar(&f.smtpUser, "smtp-user", "", "SMTP user for --notify-email; the password is read from PORTPROWLER_SMTP_PASSWORD")
+	fs.StringVar(&f.incident, "incident", "", "open incidents for exposed ports and --alert violations in pagerduty or opsgenie (needs --context; key from PORTPROWLER_PAGERDUTY_KEY or PORTPROWLER_OPSGENIE_KEY)")
+	fs.StringVar(&f.incidentMin, "incident-severity", "critical", "least severity of an open port that opens an --incident: info, low, medium, high or critical")
+	fs.StringVar(&f.issue, "issue", "", "file a github or jira issue per newly exposed port (needs --context and --issue-project; token from PORTPROWLER_GITHUB_TOKEN or PORTPROWLER_JIRA_TOKEN)")
+	fs.StringVar(&f.issueProject, "issue-project", "", "repository (owner/name) or Jira project key --issue files in")
+	fs.StringVar(&f.issueURL, "issue-url", "", "Jira site for --issue jira, or the API of a GitHub Enterprise server (https://<host>/api/v3)")
+	fs.StringVar(&f.issueUser, "issue-user", "", "Jira Cloud account email for --issue jira (empty: the token is a Data Center personal access token)")
+	fs.StringVar(&f.issueTemplate, "issue-template", "", "text/template file defining the \"title\" and \"body\" of --issue tickets")
+	fs.Var(&f.encrypt, "encrypt", "encrypt report files to age:<recipient> (age1...) or age:<recipients file>, writing <file>.age (repeatable)")
+	fs.StringVar(&f.sign, "sign", "", "sign every report file with this Ed25519 private key (PEM), writing <file>.sig; check with portprowler verify")
+	fs.StringVar(&f.upload, "upload", "", "upload the report files to s3://bucket/prefix/ or gs://bucket/prefix/ after the scan (credentials from the environment)")
+	fs.StringVar(&f.uploadSSE, "upload-sse", "", "server-side encryption of --upload objects: aes256 or kms")
+	fs.StringVar(&f.uploadKMS, "upload-kms-key", "", "KMS key for --upload-sse kms (default: the bucket's key)")
+	fs.StringVar(&f.issueMin, "issue-severity", "critical", "least severity of an open port that files an --issue: info, low, medium, high or critical")
+	fs.StringVar(&f.lang, "lang", "", "language of the table format, the scan summary and template t strings: "+strings.Join(i18n.Languages(), ", ")+" (default en; json, csv and openmetrics are not translated)")
+	fs.BoolVar(&f.verbose, "v", false, "verbose logging")
+	fs.BoolVar(&f.silent, "silent", false, "suppress all diagnostics on stderr (results still go to stdout)")
+}
+
+// scanPlan is a validated scan, ready to run (repeatedly, for watch).
+type scanPlan struct {
+	flags   scanFlags
+	targets []port.Target
+	ports   []uint16
+	specs   []output.Spec
+	cfg     scanner.Config // Audit and the jump host are set up per run
+	proxied bool           // probes leave through --proxy or --via
+	// recipients are the --encrypt keys report files are encrypted to.
+	recipients []*age.Recipient
+	inventory  cloud.Inventory // --cloud-inventory
+	rules      rules.Set       // --rules
+}
+
+// plan validates the flags, loads --sig-file and resolves the target
+// names. Invalid settings are usage errors; unresolvable targets are
+// runtime errors.
+func (f scanFlags) plan(names []string) (*scanPlan, error) {
+	if len(names) < 1 && f.replay == "" && f.hostsFile == "" && len(f.ctDomains) == 0 {
+		return nil, &exitError{code: 2, msg: "error: target positional argument required", usage: true}
+	}
+
+	if err := i18n.Set(f.lang); err != nil {
+		return nil, usageErr("error: --lang: %v", err)
+	}
+
+	if !f.tcp && !f.udp && !f.stealth && !f.ping {
+		if err := f.applyDefaultModes(); err != nil {
+			return nil, err
+		}
+	}
+	pingOnly := f.ping && !f.tcp && !f.udp && !f.stealth
+	if f.stateless {
+		if !f.stealth || f.tcp || f.udp || f.ping || f.pingGate {
+			return nil, usageErr("error: --stateless is a stealth-only mode; use -s without -tcp, -udp, -ping and --ping-gate")
+		}
+		if f.serviceDetect || f.vhosts || f.jitter != "" || f.quietHours != "" {
+			return nil, usageErr("error: --stateless keeps no per-probe state; drop --service-detect, --vhosts, --jitter and --quiet-hours")
+		}
+	}
+	if f.fastIO {
+		if !f.stealth {
+			return nil, usageErr("error: --fast-io only speeds up stealth scans; add -s")
+		}
+		if !rawsock.FastIO {
+			return nil, usageErr("error: --fast-io is not in this build; rebuild on Linux with -tags fastio")
+		}
+	}
+	if f.synShards < 1 || f.synShards > scanner.MaxSYNShards {
+		return nil, usageErr("error: --syn-shards must be between 1 and %d", scanner.MaxSYNShards)
+	}
+	var pinCPUs []int
+	if f.pinCPUs != "" && f.pinCPUs != "numa" {
+		var err error
+		if pinCPUs, err = netutil.ParseCPUList(f.pinCPUs); err != nil {
+			return nil, usageErr("error: invalid --pin-cpus: %v", err)
+		}
+	}
+	if (f.synShards > 1 || f.pinCPUs != "") && !f.stealth {
+		return nil, usageErr("error: --syn-shards and --pin-cpus shard stealth scans; add -s")
+	}
+	if f.pinCPUs != "" && runtime.GOOS != "linux" {
+		return nil, usageErr("error: --pin-cpus is only supported on Linux")
+	}
+	namedPorts := len(names) > 0 && f.hostsFile == "" && len(f.ctDomains) == 0
+	for _, name := range names {
+		host, tport, terr := netutil.SplitTarget(name)
+		if terr != nil {
+			return nil, usageErr("error: invalid target %q: %v", name, terr)
+		}
+		if tport != 0 && f.stateless {
+			return nil, usageErr("error: --stateless scans every target on -p; drop the port of %s", name)
+		}
+		namedPorts = namedPorts && tport != 0 && host != ""
+	}
+	if f.ports == "" && !pingOnly && f.replay == "" && !namedPorts {
+		return nil, &exitError{code: 2, msg: "error: -p <ports> is required (examples: -p 22 -p 22,80 -p 1-1024 -p 22,80,8000-8100)", usage: true}
+	}
+
+	// Validate worker count early
+	if f.workers <= 0 || f.workers > 10000 {
+		return nil, usageErr("error: invalid worker count (-c). Provide a positive value up to 10000.")
+	}
+
+	// Per-protocol timeouts fall back to -t when not given explicitly.
+	for _, d := range []*time.Duration{&f.tcpTimeout, &f.udpTimeout, &f.stealthTimeout} {
+		if *d < 0 {
+			return nil, usageErr("error: timeouts must not be negative")
+		}
+		if *d == 0 {
+			*d = f.timeout
+		}
+	}
+
+	if len(f.notifyEmail) > 0 && (f.smtpServer == "" || f.smtpFrom == "") {
+		return nil, usageErr("error: --notify-email needs --smtp-server and --smtp-from")
+	}
+	if f.incident != "" {
+		if _, perr := notify.NewPager(f.incident, ""); perr != nil {
+			return nil, usageErr("error: --incident: %v", perr)
+		}
+		if os.Getenv(incidentKeyEnv(f.incident)) == "" {
+			return nil, usageErr("error: --incident %s needs the key in %s", f.incident, incidentKeyEnv(f.incident))
+		}
+		if f.scanContext == "" {
+			return nil, usageErr("error: --incident needs --context to rate ports")
+		}
+	}
+	if detector.SeverityRank(f.incidentMin) < 0 {
+		return nil, usageErr("error: invalid --incident-severity %q (info, low, medium, high or critical)", f.incidentMin)
+	}
+	if f.issue != "" {
+		switch {
+		case f.issue != notify.TrackerGitHub && f.issue != notify.TrackerJira:
+			return nil, usageErr("error: invalid --issue %q (github or jira)", f.issue)
+		case os.Getenv(issueTokenEnv(f.issue)) == "":
+			return nil, usageErr("error: --issue %s needs the token in %s", f.issue, issueTokenEnv(f.issue))
+		case f.issueProject == "":
+			return nil, usageErr("error: --issue needs --issue-project")
+		case f.issue == notify.TrackerGitHub && strings.Count(f.issueProject, "/") != 1:
+			return nil, usageErr("error: --issue-project %q: want owner/name for github", f.issueProject)
+		case f.issue == notify.TrackerJira && f.issueURL == "":
+			return nil, usageErr("error: --issue jira needs --issue-url")
+		case f.scanContext == "":
+			return nil, usageErr("error: --issue needs --context to rate ports")
+		}
+		if f.issueTemplate != "" {
+			if _, terr := notify.LoadIssueTemplate(f.issueTemplate); terr != nil {
+				return nil, usageErr("error: %v", terr)
+			}
+		}
+	}
+	if detector.SeverityRank(f.issueMin) < 0 {
+		return nil, usageErr("error: invalid --issue-severity %q (info, low, medium, high or critical)", f.issueMin)
+	}
+	if f.sign != "" {
+		if _, serr := signing.LoadPrivateKey(f.sign); serr != nil {
+			return nil, usageErr("error: --sign: %v", serr)
+		}
+	}
+	if f.upload != "" {
+		loc, uerr := upload.Parse(f.upload)
+		if uerr != nil {
+			return nil, usageErr("error: --upload: %v", uerr)
+		}
+		if _, uerr := upload.FromEnv(loc); uerr != nil {
+			return nil, usageErr("error: --upload: %v", uerr)
+		}
+	}
+	switch f.uploadSSE {
+	case "", upload.SSEAES256, upload.SSEKMS:
+	default:
+		return nil, usageErr("error: invalid --upload-sse %q (aes256 or kms)", f.uploadSSE)
+	}
+	if (f.uploadSSE != "" || f.uploadKMS != "") && f.upload == "" {
+		return nil, usageErr("error: --upload-sse and --upload-kms-key require --upload")
+	}
+	if f.uploadKMS != "" && f.uploadSSE != upload.SSEKMS {
+		return nil, usageErr("error: --upload-kms-key requires --upload-sse kms")
+	}
+	if f.smtpServer != "" {
+		if _, _, serr := net.SplitHostPort(f.smtpServer); serr != nil {
+			return nil, usageErr("error: invalid --smtp-server %q (want host:port)", f.smtpServer)
+		}
+	}
+	if f.udpGrace < 0 {
+		return nil, usageErr("error: --udp-grace must not be negative")
+	}
+
+	if f.replay != "" && f.progressJSON != "" {
+		return nil, usageErr("error: --replay runs no probes; drop --progress-json")
+	}
+	if f.replay != "" && (f.audit != "" || f.vhosts || len(f.proxies) > 0 || f.via != "") {
+		return nil, usageErr("error: --replay sends no traffic; drop --audit, --vhosts, --proxy and --via")
+	}
+
+	if f.fingerprintOut != "" && !f.serviceDetect {
+		return nil, usageErr("error: --fingerprint-out requires --service-detect")
+	}
+
+	if f.sigFile != "" {
+		userSigs, serr := sigs.LoadFile(f.sigFile)
+		if serr != nil {
+			return nil, usageErr("error: --sig-file: %v", serr)
+		}
+		sigs.SetUser(userSigs)
+	}
+
+	if f.detectTimeout < 0 {
+		return nil, usageErr("error: --detect-timeout must not be negative")
+	}
+
+	if f.httpCapture < 0 {
+		return nil, usageErr("error: --http-capture must not be negative")
+	}
+	if f.httpCapture > 0 && !f.serviceDetect {
+		return nil, usageErr("error: --http-capture requires --service-detect")
+	}
+	if f.bannerLimit < 0 || f.bannerLimit > wire.MaxBanner {
+		return nil, usageErr("error: --banner-limit must be between 1 and %d", wire.MaxBanner)
+	}
+	if f.hexDump < 0 || f.hexDump > wire.MaxBanner {
+		return nil, usageErr("error: --hexdump must be between 1 and %d", wire.MaxBanner)
+	}
+	if f.hexDump > 0 && !f.serviceDetect {
+		return nil, usageErr("error: --hexdump needs --service-detect")
+	}
+	scanContext, cerr := detector.ParseContext(f.scanContext)
+	if cerr != nil {
+		return nil, usageErr("error: invalid --context: %v", cerr)
+	}
+	f.scanContext = scanContext
+	if f.maxOpen < 0 {
+		return nil, usageErr("error: --max-open-per-host must not be negative")
+	}
+	if f.firstOpen && f.maxOpen > 0 {
+		return nil, usageErr("error: --first-open already stops a host at its first open port; drop --max-open-per-host")
+	}
+	if f.deadAfter < 0 {
+		return nil, usageErr("error: --dead-after must not be negative")
+	}
+	if f.skipDead && f.deadAfter == 0 {
+		return nil, usageErr("error: --skip-dead-hosts needs --dead-after")
+	}
+	if f.detectBytes <= 0 {
+		return nil, usageErr("error: --detect-bytes must be positive")
+	}
+	var quiet *scanner.QuietHours
+	if f.quietHours != "" {
+		q, qerr := scanner.ParseQuietHours(f.quietHours)
+		if qerr != nil {
+			return nil, usageErr("error: invalid --quiet-hours: %v", qerr)
+		}
+		quiet = q
+	}
+	if f.quietWorkers < 0 || f.quietWorkers > 0 && quiet == nil {
+		return nil, usageErr("error: --quiet-workers needs --quiet-hours and must not be negative")
+	}
+	var jitterMin, jitterMax time.Duration
+	if f.jitter != "" {
+		var jerr error
+		if jitterMin, jitterMax, jerr = scanner.ParseJitter(f.jitter); jerr != nil {
+			return nil, usageErr("error: %v", jerr)
+		}
+	}
+	var dnsQuery scanner.DNSQuery
+	if f.dnsQuery != "" {
+		var derr error
+		if dnsQuery, derr = scanner.ParseDNSQuery(f.dnsQuery); derr != nil {
+			return nil, usageErr("error: invalid --dns-query: %v", derr)
+		}
+	}
+	dnsQuery.NoRecurse = f.dnsNoRecurse
+	var maxBandwidth, hostBandwidth int64
+	for _, bw := range []struct {
+		flag, val string
+		rate      *int64
+	}{{"--max-bandwidth", f.maxBandwidth, &maxBandwidth}, {"--max-host-bandwidth", f.hostBandwidth, &hostBandwidth}} {
+		if bw.val == "" {
+			continue
+		}
+		var berr error
+		if *bw.rate, berr = scanner.ParseBandwidth(bw.val); berr != nil {
+			return nil, usageErr("error: %s: %v", bw.flag, berr)
+		}
+	}
+
+	var cipherIDs []uint16
+	if f.tlsCiphers != "" {
+		ids, cerr := detector.ParseCipherSuites(f.tlsCiphers)
+		if cerr != nil {
+			return nil, usageErr("error: invalid --tls-ciphers: %v", cerr)
+		}
+		cipherIDs = ids
+	}
+	var alpn []string
+	for _, p := range strings.Split(f.tlsALPN, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			alpn = append(alpn, p)
+		}
+	}
+
+	var ports []uint16
+	if f.ports != "" {
+		var err error
+		ports, err = port.ParsePortSpec(f.ports)
+		if err != nil {
+			// make invalid port spec error clearer with example
+			return nil, usageErr("Invalid port spec %q: %v\nExamples: -p 22  -p 22,80  -p 1-1024  -p 22,80,8000-8100", f.ports, err)
+		}
+	}
+
+	if (len(f.proxies) > 0 || f.via != "") && (f.udp || f.stealth || f.ping || f.pingGate) {
+		return nil, usageErr("error: --proxy and --via only support tcp connect scans (drop -udp, -s, -ping and --ping-gate)")
+	}
+	if f.gateTimeout < 0 {
+		return nil, usageErr("error: --ping-gate-timeout must not be negative")
+	}
+	var dialer netutil.ContextDialer
+	if len(f.proxies) > 0 {
+		if f.proxyTimeout <= 0 {
+			return nil, usageErr("error: --proxy-timeout must be positive")
+		}
+		chain := &netutil.ProxyChain{HopTimeout: f.proxyTimeout}
+		for _, raw := range f.proxies {
+			hop, perr := netutil.ParseProxy(raw)
+			if perr != nil {
+				return nil, usageErr("error: %v", perr)
+			}
+			chain.Hops = append(chain.Hops, hop)
+		}
+		dialer = chain
+	}
+
+	var specs []output.Spec
+	stdoutSpecs := 0
+	for _, o := range f.outputs {
+		spec, serr := output.ParseSpec(o)
+		if serr != nil {
+			return nil, usageErr("error: invalid -o: %v", serr)
+		}
+		if spec.Format == "template" {
+			if _, terr := output.LoadTemplate(spec.Template); terr != nil {
+				return nil, usageErr("error: invalid -o: %v", terr)
+			}
+		}
+		if spec.Path == "" {
+			stdoutSpecs++
+		}
+		specs = append(specs, spec)
+	}
+	if stdoutSpecs > 1 {
+		return nil, usageErr("error: at most one -o may write to stdout; give the others a path (e.g. -o json=scan.json)")
+	}
+	if len(specs) == 0 {
+		specs = []output.Spec{{Format: "table"}}
+	}
+	var recipients []*age.Recipient
+	for _, e := range f.encrypt {
+		rs, eerr := parseRecipients(e)
+		if eerr != nil {
+			return nil, usageErr("error: --encrypt: %v", eerr)
+		}
+		recipients = append(recipients, rs...)
+	}
+	if len(recipients) > 0 && len(f.reportPaths(specs)) == 0 && f.upload == "" {
+		return nil, usageErr("error: --encrypt needs a report file (-o format=path, -f or --fingerprint-out) or --upload; stdout is not encrypted")
+	}
+	if f.sign != "" && len(f.reportPaths(specs)) == 0 && f.upload == "" {
+		return nil, usageErr("error: --sign needs a report file (-o format=path, -f or --fingerprint-out) or --upload")
+	}
+
+	if f.redactMap != "" && !f.redact {
+		return nil, usageErr("error: --redact-map requires --redact")
+	}
+
+	for _, hn := range f.hostNotes {
+		if host, text, ok := strings.Cut(hn, "="); !ok || host == "" || text == "" {
+			return nil, usageErr("error: invalid --host-note %q (want target=text)", hn)
+		}
+	}
+
+	var inventory cloud.Inventory
+	if len(f.cloudInventory) > 0 {
+		var ierr error
+		if inventory, ierr = cloud.Load(f.cloudInventory...); ierr != nil {
+			return nil, usageErr("error: --cloud-inventory: %v", ierr)
+		}
+	}
+	var rs rules.Set
+	if len(f.rulesFiles) > 0 {
+		var rerr error
+		if rs, rerr = rules.Load(f.rulesFiles...); rerr != nil {
+			return nil, usageErr("error: --rules: %v", rerr)
+		}
+	}
+
+	proxied := len(f.proxies) > 0 || f.via != ""
+	if !proxied && f.requireIface != "" {
+		if _, ierr := net.InterfaceByName(f.requireIface); ierr != nil {
+			return nil, usageErr("error: --require-iface %s: %v", f.requireIface, ierr)
+		}
+	}
+
+	var scope netutil.Scope
+	if f.scope != "" {
+		var serr error
+		if scope, serr = netutil.LoadScope(f.scope); serr != nil {
+			return nil, usageErr("error: --scope: %v", serr)
+		}
+	} else if f.force {
+		return nil, usageErr("error: --force only overrides --scope; add --scope")
+	}
+
+	var targets []port.Target
+	var outside []string
+	resolveOpts := netutil.ResolveOptions{DualStack: f.dualStack, AllIPs: f.allIPs}
+	for _, name := range names {
+		host, tport, _ := netutil.SplitTarget(name)
+		addrs, err := netutil.ResolveTarget(host, resolveOpts)
+		if err != nil {
+			return nil, runtimeErr("failed to resolve target %s: %v", name, err)
+		}
+		if len(addrs) > 1 {
+			logging.Infof("Resolved %s to %s", host, strings.Join(addrs, ", "))
+		}
+		if gerr := f.checkResolved(host, addrs, scope); gerr != nil {
+			return nil, gerr
+		}
+		for _, ip := range addrs {
+			targets = addTarget(targets, port.Target{Name: host, IP: ip}, tport)
+		}
+	}
+	if f.hostsFile != "" {
+		listed, herr := f.hostsFileTargets(scope)
+		if herr != nil {
+			return nil, herr
+		}
+		targets = append(targets, listed...)
+	}
+	if len(f.ctDomains) > 0 {
+		listed, cerr := f.ctTargets(scope)
+		if cerr != nil {
+			return nil, cerr
+		}
+		targets = append(targets, listed...)
+	}
+	if len(targets) == 0 && f.replay == "" {
+		return nil, runtimeErr("no targets to scan")
+	}
+	if len(ports) == 0 && namedPorts {
+		ports = targetPorts(targets)
+	}
+	for _, t := range targets {
+		if scope != nil && !scope.Contains(netutil.ParseIP(t.IP)) {
+			outside = append(outside, targetLabel(t.Name, t.IP))
+		}
+	}
+	if len(outside) > 0 {
+		if !f.force {
+			return nil, runtimeErr("refusing to scan targets outside --scope %s: %s (add --force to scan them anyway)", f.scope, strings.Join(outside, ", "))
+		}
+		logging.Warnf("scanning targets outside --scope %s because of --force: %s", f.scope, strings.Join(outside, ", "))
+	}
+
+	return &scanPlan{
+		flags:      f,
+		targets:    targets,
+		ports:      ports,
+		specs:      specs,
+		proxied:    proxied,
+		recipients: recipients,
+		inventory:  inventory,
+		rules:      rs,
+		cfg: scanner.Config{
+			Targets:         targets,
+			Ports:           ports,
+			ScanTCP:         f.tcp,
+			ScanUDP:         f.udp,
+			ScanStealth:     f.stealth,
+			ScanPing:        f.ping,
+			PingGate:        f.pingGate,
+			PingGateTimeout: f.gateTimeout,
+			Workers:         f.workers,
+			TCPTimeout:      f.tcpTimeout,
+			UDPTimeout:      f.udpTimeout,
+			UDPGrace:        f.udpGrace,
+			DNSQuery:        dnsQuery,
+			StealthTimeout:  f.stealthTimeout,
+			ServiceDetect:   f.serviceDetect,
+			OSDetect:        f.osDetect,
+			Verbose:         f.verbose,
+			TLSServerName:   f.sni,
+			TLSInsecure:     f.insecure,
+			HTTPCapture:     f.httpCapture,
+			BannerLimit:     f.bannerLimit,
+			DetectBytes:     f.detectBytes,
+			MaxOpenPerHost:  f.maxOpen,
+			FirstOpen:       f.firstOpen,
+			DeadAfter:       f.deadAfter,
+			SkipDead:        f.skipDead,
+			LikelyFirst:     f.likelyFirst,
+			QuietHours:      quiet,
+			QuietWorkers:    f.quietWorkers,
+			JitterMin:       jitterMin,
+			JitterMax:       jitterMax,
+			MaxBandwidth:    maxBandwidth,
+			HostBandwidth:   hostBandwidth,
+			RSTClose:        f.rstClose,
+			Stateless:       f.stateless,
+			FastIO:          f.fastIO,
+			SYNShards:       f.synShards,
+			PinCPUs:         pinCPUs,
+			PinNUMA:         f.pinCPUs == "numa",
+			TLSCiphers:      cipherIDs,
+			TLSALPN:         alpn,
+			Dialer:          dialer,
+			DetectTimeout:   f.detectTimeout,
+			Fingerprints:    f.fingerprintOut != "",
+			HexDump:         f.hexDump,
+		},
+	}, nil
+}
+
+// applyDefaultModes turns on the scan modes listed in --default-modes.
+func (f *scanFlags) applyDefaultModes() error {
+	for _, m := range strings.Split(f.defaultModes, ",") {
+		switch port.ScanType(strings.ToLower(strings.TrimSpace(m))) {
+		case port.ScanTCP:
+			f.tcp = true
+		case port.ScanUDP:
+			f.udp = true
+		case port.ScanStealth:
+			f.stealth = true
+		case port.ScanPing:
+			f.ping = true
+		case "":
+		default:
+			return usageErr("error: invalid --default-modes %q (want a comma-separated list of tcp, udp, stealth, ping)", f.defaultModes)
+		}
+	}
+	if !f.tcp && !f.udp && !f.stealth && !f.ping {
+		return usageErr("error: --default-modes must name at least one mode")
+	}
+	return nil
+}
+
+// checkResolved refuses a hostname that resolved to a loopback or other
+// non-public address, which usually means a stale or hijacked DNS record
+// rather than an intended internal scan. Address literals, localhost
+// names and addresses inside scope are taken as given.
+func (f scanFlags) checkResolved(name string, addrs []string, scope netutil.Scope) error {
+	if netutil.ParseIP(name) != nil || netutil.IsLocalhostName(name) {
+		return nil
+	}
+	for _, a := range addrs {
+		ip := netutil.ParseIP(a)
+		switch class := netutil.AddrClass(ip); {
+		case class == "" || scope.Contains(ip):
+		case class == netutil.ClassLoopback:
+			if !f.allowLoopback {
+				return runtimeErr("refusing to scan %s: it resolved to the loopback address %s; add --allow-loopback if that is intended", name, a)
+			}
+		case !f.allowPrivate && f.scanContext != detector.ContextInternal:
+			return runtimeErr("refusing to scan %s: it resolved to the %s address %s; add --allow-private (or scan the address itself) if that is intended", name, class, a)
+		}
+	}
+	return nil
+}
+
+// addTarget appends t to targets, limited to port when it is not 0 (a
+// target given as host:port). Targets of the same name and address are
+// merged, scanning the union of their ports; one without a port scans
+// every port.
+func addTarget(targets []port.Target, t port.Target, p uint16) []port.Target {
+	for i := range targets {
+		have := &targets[i]
+		if have.Name != t.Name || have.IP != t.IP {
+			continue
+		}
+		switch {
+		case p == 0:
+			have.Ports = nil
+		case len(have.Ports) > 0:
+			for _, hp := range have.Ports {
+				if hp == p {
+					return targets
+				}
+			}
+			have.Ports = append(have.Ports, p)
+		}
+		return targets
+	}
+	if p != 0 {
+		t.Ports = []uint16{p}
+	}
+	return append(targets, t)
+}
+
+// targetPorts is the union of the ports targets name, in order.
+func targetPorts(targets []port.Target) []uint16 {
+	var out []uint16
+	seen := make(map[uint16]bool)
+	for _, t := range targets {
+		for _, p := range t.Ports {
+			if !seen[p] {
+				seen[p] = true
+				out = append(out, p)
+			}
+		}
+	}
+	return out
+}
+
+// targetLabel names a target for messages: its address, followed by the
+// name it was given as when that differs.
+func targetLabel(name, ip string) string {
+	if name == ip {
+		return ip
+	}
+	return ip + " (" + name + ")"
+}
+
+// meta returns the report metadata for a run of the plan.
+func (p *scanPlan) meta() report.Meta {
+	return report.Meta{
+		PortSpec: p.flags.ports,
+		OSDetect: p.flags.osDetect,
+		Note:     p.flags.note,
+		Context:  p.flags.scanContext,
+		Version:  version.Get().Version,
+	}
+}
+
+// run performs the scan and returns the analysed report with the scan
+// statistics.
+func (p *scanPlan) run(ctx context.Context) (report.ScanReport, stats.Snapshot, error) {
+	f := p.flags
+	cfg := p.cfg
+
+	// Proxied and jump-host probes leave from elsewhere; check local routes only.
+	if !p.proxied {
+		if !preflightRoutes(p.targets, f.requireIface, f.verbose) {
+			return report.ScanReport{}, stats.Snapshot{}, &exitError{code: 4}
+		}
+	} else if f.requireIface != "" {
+		logging.Warnf("--require-iface is ignored with --proxy/--via")
+	}
+
+	// The preamble is diagnostic output; only results are written to stdout.
+	logging.Infof("Ports: %s", f.ports)
+	logging.Infof("Scan modes: tcp=%v udp=%v stealth=%v ping=%v", cfg.ScanTCP, cfg.ScanUDP, cfg.ScanStealth, cfg.ScanPing)
+	logging.Infof("Service detection: %v, OS detection: %v", cfg.ServiceDetect, cfg.OSDetect)
+	logging.Infof("Workers: %d, timeouts: tcp=%v udp=%v stealth=%v, verbose: %v",
+		cfg.Workers, cfg.TCPTimeout, cfg.UDPTimeout, cfg.StealthTimeout, cfg.Verbose)
+	if cfg.JitterMax > 0 {
+		logging.Infof("Jitter: %v-%v between probes to each host", cfg.JitterMin, cfg.JitterMax)
+	}
+	if cfg.QuietHours != nil {
+		if cfg.QuietWorkers > 0 {
+			logging.Infof("Quiet hours: %s (%d workers)", cfg.QuietHours, cfg.QuietWorkers)
+		} else {
+			logging.Infof("Quiet hours: %s (paused)", cfg.QuietHours)
+		}
+	}
+	if f.fileOut != "" {
+		logging.Infof("File output: %s", f.fileOut)
+	}
+	chain, _ := cfg.Dialer.(*netutil.ProxyChain)
+	if chain != nil {
+		for i, hop := range chain.Hops {
+			logging.Infof("Proxy hop %d: %s", i+1, hop)
+		}
+	}
+	var jump *netutil.SSHJump
+	if f.via != "" {
+		// The jump host is reached through the proxy chain, if any.
+		var jerr error
+		jump, jerr = netutil.DialSSH(ctx, f.via, netutil.SSHOptions{
+			KeyFile:            f.viaKey,
+			KnownHosts:         f.viaKnownHosts,
+			InsecureIgnoreHost: f.viaInsecure,
+			Timeout:            f.proxyTimeout,
+			Forward:            cfg.Dialer,
+		})
+		if jerr != nil {
+			return report.ScanReport{}, stats.Snapshot{}, runtimeErr("failed to connect to jump host: %v", jerr)
+		}
+		defer jump.Close()
+		logging.Infof("Jump host: %s", jump)
+		cfg.Dialer = jump
+	}
+	for _, spec := range p.specs {
+		if spec.Path != "" {
+			logging.Infof("Output: %s -> %s", spec.Format, spec.Path)
+		}
+	}
+	if f.audit != "" {
+		var route []string
+		if chain != nil {
+			for _, hop := range chain.Hops {
+				route = append(route, hop.String())
+			}
+		}
+		if jump != nil {
+			route = append(route, jump.String())
+		}
+		auditLog, aerr := audit.Open(f.audit, strings.Join(route, " -> "))
+		if aerr != nil {
+			return report.ScanReport{}, stats.Snapshot{}, runtimeErr("failed to open audit log: %v", aerr)
+		}
+		cfg.Audit = auditLog
+		logging.Infof("Audit log: %s", f.audit)
+	}
+
+	switch f.progressJSON {
+	case "":
+	case "-":
+		cfg.Progress = os.Stderr
+	default:
+		// Opening a named pipe blocks until a reader attaches.
+		pf, perr := os.OpenFile(f.progressJSON, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if perr != nil {
+			if cfg.Audit != nil {
+				cfg.Audit.Close()
+			}
+			return report.ScanReport{}, stats.Snapshot{}, runtimeErr("failed to open progress output: %v", perr)
+		}
+		defer pf.Close()
+		cfg.Progress = pf
+	}
+
+	mgr := scanner.NewManager(cfg)
+
+	resultsCh, err := mgr.Run(ctx)
+	if err != nil {
+		if cfg.Audit != nil {
+			cfg.Audit.Close()
+		}
+		if errors.Is(err, scanner.ErrNeedPriv) {
+			return report.ScanReport{}, stats.Snapshot{}, &exitError{code: 3, msg: "Stealth scan (-s) requires raw socket privileges. Rerun with elevated privileges (root/CAP_NET_RAW) or remove -s to use TCP connect. No fallback is performed."}
+		}
+		return report.ScanReport{}, stats.Snapshot{}, runtimeErr("failed to start scanner manager: %v", err)
+	}
+
+	// Wait for all results; the manager keeps them so OS detection can run per host (single OS guess).
+	for range resultsCh {
+	}
+	sum, _ := mgr.Wait(context.Background())
+	if sum.Outcome == scanner.OutcomePartial || sum.Outcome == scanner.OutcomeFailed {
+		logging.Warnf("%d of %d probes failed with errors (see the INFO column)", sum.Stats.Errors, sum.Stats.Probes)
+	}
+	snap := mgr.Stats()
+	rep := mgr.Report(p.meta())
+	if f.vhosts {
+		cfgFor := func(ip string) detector.Config { return mgr.HostDetectorConfig(port.ScanTCP, ip) }
+		enumerateVHosts(ctx, cfgFor, p.targets, &rep)
+		mgr.CloseIdle()
+	}
+	// All traffic has been sent; an incomplete evidence trail is fatal.
+	if cfg.Audit != nil {
+		if err := cfg.Audit.Close(); err != nil {
+			return report.ScanReport{}, stats.Snapshot{}, runtimeErr("failed to write audit log: %v", err)
+		}
+	}
+
+	analyzeReport(&rep, f.hostNotes, p.inventory, p.rules, cfg.OSDetect, f.sortRTT)
+	return rep, snap, nil
+}
+
+// runScan implements `portprowler scan`, which is also what runs when no
+// subcommand is given.
+func runScan(args []string) int {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	f := defaultScanFlags()
+	f.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [scan] [flags] <target> [target...]\n", progName())
+		fs.PrintDefaults()
+	}
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return parseStatus(err)
+	}
+	logging.SetSilent(f.silent)
+
+	if f.jsonrpc {
+		if len(names) > 0 {
+			return exitStatus(&exitError{code: 2, msg: "error: --jsonrpc takes targets from requests, not arguments", usage: true}, fs)
+		}
+		if f.hostsFile == "-" {
+			return exitStatus(usageErr("error: --jsonrpc reads requests from stdin; give --hosts-file a file"), fs)
+		}
+		return exitStatus(serveJSONRPC(f), fs)
+	}
+	_, err = f.scanAndReport(names)
+	return exitStatus(err, fs)
+}
+
+// scanAndReport scans names (or replays --replay) and hands the report
+// to the outputs, history, notifications and uploads the flags ask for.
+func (f scanFlags) scanAndReport(names []string) (report.ScanReport, error) {
+	p, err := f.plan(names)
+	if err != nil {
+		return report.ScanReport{}, err
+	}
+
+	var rep report.ScanReport
+	var summary string
+	if f.replay != "" {
+		rep, summary, err = replayReport(f.replay, p.targets, p.ports, p.meta())
+		if err != nil {
+			return rep, err
+		}
+		analyzeReport(&rep, f.hostNotes, p.inventory, p.rules, f.osDetect, f.sortRTT)
+	} else {
+		var snap stats.Snapshot
+		rep, snap, err = p.run(context.Background())
+		if err != nil {
+			return rep, err
+		}
+		summary = snap.Summary()
+	}
+	if f.save {
+		if err := saveHistory(rep); err != nil {
+			return rep, err
+		}
+	}
+	if f.redact {
+		if err := redactReport(&rep, f.redactMap); err != nil {
+			return rep, err
+		}
+	}
+	if err := writeOutputs(rep, summary, p.specs, f.fingerprintOut, f.fileOut, p.recipients); err != nil {
+		return rep, err
+	}
+	if err := f.signReports(p.specs); err != nil {
+		return rep, err
+	}
+	if err := f.uploadReports(context.Background(), rep, summary, p.specs, p.recipients); err != nil {
+		return rep, err
+	}
+	f.notify(rep, summary, nil, nil)
+	f.page(context.Background(), rep, nil)
+	f.fileIssues(context.Background(), rep)
+	return rep, nil
+}
@@ -0,0 +1,145 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"portprowler/netutil"
+	"portprowler/port"
+)
+
+// ParseBandwidth parses a --max-bandwidth rate into bytes per second.
+// Bit rates use bps, kbps, mbps or gbps ("5mbps"); byte rates use B/s,
+// KB/s, MB/s or GB/s ("512KB/s"). Prefixes are decimal.
+func ParseBandwidth(s string) (int64, error) {
+	in := strings.TrimSpace(s)
+	num := strings.TrimRight(in, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ/")
+	unit := in[len(num):]
+	v, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q (e.g. 5mbps or 512KB/s)", s)
+	}
+	scale := map[string]float64{
+		"bps": 1.0 / 8, "kbps": 1e3 / 8, "mbps": 1e6 / 8, "gbps": 1e9 / 8,
+		"b/s": 1, "kb/s": 1e3, "mb/s": 1e6, "gb/s": 1e9,
+	}
+	// Only the case of B matters: "Mbps" is bits and "MB/s" bytes, while
+	// "Mb/s" and "MBps" are rejected as ambiguous.
+	f, ok := scale[strings.ToLower(unit)]
+	ambiguous := strings.HasSuffix(unit, "/s") && !strings.HasSuffix(unit, "B/s") || strings.Contains(unit, "Bps")
+	if !ok || ambiguous {
+		return 0, fmt.Errorf("invalid bandwidth unit in %q (bps, kbps, mbps, gbps, B/s, KB/s, MB/s or GB/s)", s)
+	}
+	rate := int64(v * f)
+	if rate < 1 {
+		return 0, fmt.Errorf("bandwidth %q is below one byte per second", s)
+	}
+	return rate, nil
+}
+
+// byteLimiter paces traffic to rate bytes per second: each send reserves
+// the time its bytes take at that rate, starting where the previous
+// reservation ended, and waits until its slot begins.
+type byteLimiter struct {
+	rate int64
+	mu   sync.Mutex
+	next time.Time // end of the last reservation
+}
+
+// reserve books n bytes and returns how long to wait before sending them.
+func (l *byteLimiter) reserve(now time.Time, n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	return wait
+}
+
+// Approximate on-wire sizes of the probes' packets, without link headers.
+const (
+	ipv4Header = 20
+	ipv6Header = 40
+	// A connect probe sends a SYN with options, the handshake's ACK and a
+	// FIN or RST, the last two with the timestamp option.
+	tcpConnectBytes = 40 + 32 + 32
+	stealthBytes    = 24 // SYN with the MSS option
+	udpHeader       = 8
+)
+
+// probeBytes returns what a probe of type st to ip:portNum puts on the
+// wire: IP and transport headers plus the probe's payload, which for UDP
+// to port 53 is the DNS query q.
+func probeBytes(st port.ScanType, ip string, portNum uint16, q DNSQuery) int {
+	hdr, packets := ipv4Header, 1
+	if p := netutil.ParseIP(ip); p != nil && p.To4() == nil {
+		hdr = ipv6Header
+	}
+	var n int
+	switch st {
+	case port.ScanTCP:
+		n, packets = tcpConnectBytes, 3
+	case port.ScanUDP:
+		payload, _ := udpProbe(portNum, q)
+		n = udpHeader + len(payload)
+	case port.ScanStealth:
+		n = stealthBytes
+	case port.ScanPing:
+		n = len(buildEchoRequest(0, 0))
+	}
+	return n + packets*hdr
+}
+
+// bandwidthWait holds a probe of n bytes to ip until both MaxBandwidth
+// and HostBandwidth allow it. It returns false when the scan was stopped
+// or ctx cancelled.
+func (m *Manager) bandwidthWait(ctx context.Context, ip string, n int) bool {
+	if m.cfg.MaxBandwidth <= 0 && m.cfg.HostBandwidth <= 0 {
+		return true
+	}
+	now := time.Now()
+	var wait time.Duration
+	if m.cfg.MaxBandwidth > 0 {
+		m.bwMu.Lock()
+		if m.bwGlobal == nil {
+			m.bwGlobal = &byteLimiter{rate: m.cfg.MaxBandwidth}
+		}
+		l := m.bwGlobal
+		m.bwMu.Unlock()
+		wait = l.reserve(now, n)
+	}
+	if m.cfg.HostBandwidth > 0 {
+		m.bwMu.Lock()
+		if m.bwHosts == nil {
+			m.bwHosts = make(map[string]*byteLimiter)
+		}
+		l := m.bwHosts[ip]
+		if l == nil {
+			l = &byteLimiter{rate: m.cfg.HostBandwidth}
+			m.bwHosts[ip] = l
+		}
+		m.bwMu.Unlock()
+		if w := l.reserve(now, n); w > wait {
+			wait = w
+		}
+	}
+	if wait <= 0 {
+		return true
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-m.stop:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,104 @@
+package scanner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"portprowler/port"
+	"portprowler/testsupport"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	cases := []struct {
+		in       string
+		want     int64
+		wantFail bool
+	}{
+		{in: "5mbps", want: 625000},
+		{in: "5Mbps", want: 625000},
+		{in: "1.5gbps", want: 187500000},
+		{in: "800bps", want: 100},
+		{in: "512KB/s", want: 512000},
+		{in: "2 MB/s", want: 2000000},
+		{in: "100B/s", want: 100},
+		{in: "5Mb/s", wantFail: true},
+		{in: "5MBps", wantFail: true},
+		{in: "4bps", wantFail: true}, // half a byte per second
+		{in: "5", wantFail: true},
+		{in: "-1mbps", wantFail: true},
+		{in: "fast", wantFail: true},
+	}
+	for _, c := range cases {
+		got, err := ParseBandwidth(c.in)
+		if (err != nil) != c.wantFail {
+			t.Fatalf("ParseBandwidth(%q) err = %v, wantFail %v", c.in, err, c.wantFail)
+		}
+		if err == nil && got != c.want {
+			t.Errorf("ParseBandwidth(%q) = %d, want %d", c.in, got, c.want)
+		}
+	}
+}
+
+func TestProbeBytes(t *testing.T) {
+	if dns, generic := probeBytes(port.ScanUDP, "192.0.2.1", 53, DNSQuery{}), probeBytes(port.ScanUDP, "192.0.2.1", 161, DNSQuery{}); dns <= generic || generic != 20+8+1 {
+		t.Errorf("udp probes: dns %d, generic %d; want the query to cost more than 29 bytes", dns, generic)
+	}
+	if v4, v6 := probeBytes(port.ScanTCP, "192.0.2.1", 80, DNSQuery{}), probeBytes(port.ScanTCP, "2001:db8::1", 80, DNSQuery{}); v6-v4 != 3*20 {
+		t.Errorf("tcp probes: %d over IPv4, %d over IPv6; want 20 more bytes per packet", v4, v6)
+	}
+}
+
+func TestByteLimiter(t *testing.T) {
+	l := &byteLimiter{rate: 1000}
+	now := time.Now()
+	if w := l.reserve(now, 500); w != 0 {
+		t.Fatalf("first reservation waits %v", w)
+	}
+	if w := l.reserve(now, 100); w != 500*time.Millisecond {
+		t.Fatalf("second reservation waits %v, want 500ms", w)
+	}
+	// Idle time is not saved up as a burst.
+	if w := l.reserve(now.Add(5*time.Second), 100); w != 0 {
+		t.Fatalf("reservation after idle time waits %v", w)
+	}
+	if w := l.reserve(now.Add(5*time.Second), 100); w != 100*time.Millisecond {
+		t.Fatalf("next reservation waits %v, want 100ms", w)
+	}
+}
+
+func TestManager_Bandwidth(t *testing.T) {
+	fake := testsupport.NewNetwork()
+	fake.AddHost("192.0.2.1")
+	fake.AddHost("192.0.2.2")
+	per := probeBytes(port.ScanTCP, "192.0.2.1", 1, DNSQuery{})
+	const gap = 30 * time.Millisecond
+	run := func(cfg Config) time.Duration {
+		cfg.Targets = []port.Target{{Name: "a.example", IP: "192.0.2.1"}, {Name: "b.example", IP: "192.0.2.2"}}
+		cfg.Ports = []uint16{1, 2, 3, 4}
+		cfg.ScanTCP, cfg.Workers, cfg.TCPTimeout, cfg.Dialer = true, 8, time.Second, fake
+		start := time.Now()
+		out, err := NewManager(cfg).Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for range out {
+			n++
+		}
+		if n != 8 {
+			t.Fatalf("got %d results, want 8", n)
+		}
+		return time.Since(start)
+	}
+	rate := int64(per) * int64(time.Second/gap) // one probe per gap
+
+	// Per host, four probes need three gaps and the hosts go in parallel.
+	if took := run(Config{HostBandwidth: rate}); took < 3*gap || took > 6*gap {
+		t.Errorf("per-host cap: scan took %v, want about %v", took, 3*gap)
+	}
+	// Globally, all eight probes share one budget.
+	if took := run(Config{MaxBandwidth: rate}); took < 7*gap || took > 11*gap {
+		t.Errorf("global cap: scan took %v, want about %v", took, 7*gap)
+	}
+}
@@ -0,0 +1,118 @@
+package scanner
+
+import (
+	"fmt"
+	"strings"
+
+	"portprowler/port"
+	"portprowler/wire"
+)
+
+// DNSQuery is the query UDP probes send to port 53. Zero fields take
+// DefaultDNSQuery's values.
+type DNSQuery struct {
+	Name      string
+	Type      uint16 // wire.DNSTypeA, wire.DNSTypeNS, ...
+	Class     uint16 // wire.DNSClassIN or wire.DNSClassCHAOS
+	NoRecurse bool   // leave the RD (recursion desired) flag clear
+}
+
+// DefaultDNSQuery asks for the A record of example.com with recursion
+// desired, which any resolver answers.
+var DefaultDNSQuery = DNSQuery{Name: "example.com", Type: wire.DNSTypeA, Class: wire.DNSClassIN}
+
+var dnsTypes = map[string]uint16{
+	"A":     wire.DNSTypeA,
+	"NS":    wire.DNSTypeNS,
+	"CNAME": wire.DNSTypeCNAME,
+	"PTR":   wire.DNSTypePTR,
+	"TXT":   wire.DNSTypeTXT,
+	"AAAA":  wire.DNSTypeAAAA,
+	"ANY":   wire.DNSTypeANY,
+}
+
+var dnsClasses = map[string]uint16{"IN": wire.DNSClassIN, "CH": wire.DNSClassCHAOS}
+
+// chaosNames are the CHAOS class names servers answer about themselves.
+var chaosNames = map[string]bool{"version.bind": true, "hostname.bind": true, "id.server": true, "version.server": true}
+
+// ParseDNSQuery reads a query given as name[/type[/class]], e.g.
+// "example.org/NS" or "version.bind/TXT/CH". The type defaults to A and
+// the class to IN, except for the CHAOS names (version.bind,
+// hostname.bind, id.server, version.server), which default to TXT in
+// class CH.
+func ParseDNSQuery(s string) (DNSQuery, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) > 3 {
+		return DNSQuery{}, fmt.Errorf("dns query %q: want name[/type[/class]]", s)
+	}
+	q := DefaultDNSQuery
+	q.Name = strings.TrimSuffix(parts[0], ".")
+	if _, err := encodeDNSName(q.Name); err != nil {
+		return DNSQuery{}, err
+	}
+	if chaosNames[strings.ToLower(q.Name)] {
+		q.Type, q.Class = wire.DNSTypeTXT, wire.DNSClassCHAOS
+	}
+	if len(parts) > 1 {
+		t, ok := dnsTypes[strings.ToUpper(parts[1])]
+		if !ok {
+			return DNSQuery{}, fmt.Errorf("dns query %q: unknown type %q (want A, AAAA, NS, CNAME, PTR, TXT or ANY)", s, parts[1])
+		}
+		q.Type = t
+	}
+	if len(parts) > 2 {
+		c, ok := dnsClasses[strings.ToUpper(parts[2])]
+		if !ok {
+			return DNSQuery{}, fmt.Errorf("dns query %q: unknown class %q (want IN or CH)", s, parts[2])
+		}
+		q.Class = c
+	}
+	return q, nil
+}
+
+// orDefault fills the zero fields of q from DefaultDNSQuery.
+func (q DNSQuery) orDefault() DNSQuery {
+	if q.Name == "" {
+		q.Name = DefaultDNSQuery.Name
+	}
+	if q.Type == 0 {
+		q.Type = DefaultDNSQuery.Type
+	}
+	if q.Class == 0 {
+		q.Class = DefaultDNSQuery.Class
+	}
+	return q
+}
+
+// String formats q as "name class type", e.g. "version.bind CH TXT".
+func (q DNSQuery) String() string {
+	q = q.orDefault()
+	return q.Name + " " + codeName(dnsClasses, q.Class) + " " + codeName(dnsTypes, q.Type)
+}
+
+func codeName(names map[string]uint16, code uint16) string {
+	for n, c := range names {
+		if c == code {
+			return n
+		}
+	}
+	return fmt.Sprint(code)
+}
+
+// dnsInfo describes m, the validated answer to q.
+func dnsInfo(q DNSQuery, m wire.DNSMessage) *port.DNSInfo {
+	q = q.orDefault()
+	info := &port.DNSInfo{
+		Query: q.String(),
+		RCode: wire.DNSRCodeName(m.RCode),
+		// Answers to a CHAOS query come from the server itself.
+		Recursion: !q.NoRecurse && q.Class == wire.DNSClassIN && m.Recursion && m.RCode == 0 && len(m.Answers) > 0,
+	}
+	for _, rr := range m.Answers {
+		if rr.Text != "" {
+			info.Answers = append(info.Answers, rr.Text)
+		}
+	}
+	return info
+}
@@ -0,0 +1,159 @@
+package scanner
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"portprowler/port"
+	"portprowler/report"
+	"portprowler/wire"
+)
+
+func TestParseDNSQuery(t *testing.T) {
+	for _, c := range []struct {
+		in, want string
+	}{
+		{"example.org", "example.org IN A"},
+		{"example.org./ns", "example.org IN NS"},
+		{"example.org/TXT/CH", "example.org CH TXT"},
+		{"version.bind", "version.bind CH TXT"},
+		{"Hostname.Bind/TXT", "Hostname.Bind CH TXT"},
+		{"version.bind/A/IN", "version.bind IN A"},
+	} {
+		q, err := ParseDNSQuery(c.in)
+		if err != nil {
+			t.Errorf("%q: %v", c.in, err)
+			continue
+		}
+		if q.String() != c.want {
+			t.Errorf("%q = %q, want %q", c.in, q, c.want)
+		}
+	}
+	for _, in := range []string{"", "a..b", "example.org/MX", "example.org/A/HS", "a/A/IN/x"} {
+		if _, err := ParseDNSQuery(in); err == nil {
+			t.Errorf("%q: no error", in)
+		}
+	}
+	if got := (DNSQuery{}).String(); got != "example.com IN A" {
+		t.Errorf("zero query = %q", got)
+	}
+}
+
+// redirectDialer sends every dial to addr, so a probe of port 53 reaches
+// a test server on an unprivileged port.
+type redirectDialer string
+
+func (a redirectDialer) DialContext(ctx context.Context, network, _ string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, network, string(a))
+}
+
+// serveDNS answers each query on conn, after delay, with one record of
+// the query's type and class, setting RA, and reports the query's RD flag
+// on rd.
+func serveDNS(conn *net.UDPConn, rd chan<- bool, delay time.Duration) {
+	buf := make([]byte, 512)
+	for {
+		n, raddr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if n < 12+5 {
+			continue
+		}
+		q := buf[:n]
+		flags := binary.BigEndian.Uint16(q[2:4])
+		rd <- flags&0x0100 != 0
+		typ := binary.BigEndian.Uint16(q[n-4 : n-2])
+		class := binary.BigEndian.Uint16(q[n-2:])
+		var data []byte
+		if typ == wire.DNSTypeTXT {
+			data = append([]byte{6}, "9.18.1"...)
+		} else {
+			data = []byte{192, 0, 2, 53}
+		}
+		resp := append([]byte(nil), q...)
+		binary.BigEndian.PutUint16(resp[2:4], 0x8000|flags&0x0100|0x0080)
+		binary.BigEndian.PutUint16(resp[6:8], 1) // ANCOUNT
+		rr := make([]byte, 12)
+		binary.BigEndian.PutUint16(rr[0:2], 0xc00c) // name: pointer to the question
+		binary.BigEndian.PutUint16(rr[2:4], typ)
+		binary.BigEndian.PutUint16(rr[4:6], class)
+		binary.BigEndian.PutUint32(rr[6:10], 60)
+		binary.BigEndian.PutUint16(rr[10:12], uint16(len(data)))
+		resp = append(append(resp, rr...), data...)
+		time.Sleep(delay)
+		_, _ = conn.WriteToUDP(resp, raddr)
+	}
+}
+
+func TestUDPScan_DNSQuery(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer conn.Close()
+	rd := make(chan bool, 1)
+	go serveDNS(conn, rd, 0)
+	d := redirectDialer(conn.LocalAddr().String())
+
+	chaos, _ := ParseDNSQuery("version.bind")
+	for _, c := range []struct {
+		q         DNSQuery
+		rd        bool
+		query     string
+		recursion bool
+		answers   string
+	}{
+		{DNSQuery{}, true, "example.com IN A", true, "192.0.2.53"},
+		{DNSQuery{NoRecurse: true}, false, "example.com IN A", false, "192.0.2.53"},
+		{chaos, true, "version.bind CH TXT", false, "9.18.1"},
+	} {
+		res := udpScan(context.Background(), d, "192.0.2.1", 53, c.q, time.Second, false, nil)
+		if res.State != "open" || res.DNS == nil {
+			t.Fatalf("%s: state %s (%s), dns %v", c.query, res.State, res.Error, res.DNS)
+		}
+		if got := <-rd; got != c.rd {
+			t.Errorf("%s: query RD = %v, want %v", c.query, got, c.rd)
+		}
+		info := res.DNS
+		if info.Query != c.query || info.RCode != "NOERROR" || info.Recursion != c.recursion || strings.Join(info.Answers, ",") != c.answers {
+			t.Errorf("%s: got %+v", c.query, *info)
+		}
+	}
+}
+
+func TestManager_LateDNSReply(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer conn.Close()
+	go serveDNS(conn, make(chan bool, 1), 150*time.Millisecond)
+	mgr := NewManager(Config{
+		Targets:      []port.Target{{Name: "ns.example", IP: "192.0.2.1"}},
+		Ports:        []uint16{53},
+		ScanUDP:      true,
+		Workers:      1,
+		UDPTimeout:   50 * time.Millisecond,
+		UDPGrace:     time.Second,
+		PacketDialer: redirectDialer(conn.LocalAddr().String()),
+	})
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for range out {
+	}
+	r := mgr.Report(report.Meta{}).Hosts[0].Results[0]
+	if r.State != "open" || r.Error != "late response" || r.DNS == nil {
+		t.Fatalf("53/udp = %s (%s), dns %v", r.State, r.Error, r.DNS)
+	}
+	if !r.DNS.Recursion || strings.Join(r.DNS.Answers, ",") != "192.0.2.53" {
+		t.Errorf("late answer read as %+v", *r.DNS)
+	}
+}
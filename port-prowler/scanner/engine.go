@@ -0,0 +1,105 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"portprowler/port"
+)
+
+// Engine runs one probe of a custom scan type against ip:portNum and
+// returns its result. cfg is the scan's configuration, for the dialers
+// and TCPTimeout (custom types use the TCP timeout). The manager fills in
+// Target and Timestamp, and IP, Port and Proto when left empty; results
+// in state "open" go through service and OS detection like any other.
+type Engine func(ctx context.Context, cfg Config, ip string, portNum uint16) port.PortResult
+
+var (
+	enginesMu sync.RWMutex
+	engines   = map[port.ScanType]Engine{}
+)
+
+// RegisterScanType adds a custom scan type run by engine, so it can be
+// listed in Config.ScanTypes and Config.DefaultScanTypes. The name must
+// be new: built-in types and types registered before are refused. It is
+// meant to be called from an init function or before the first scan.
+func RegisterScanType(st port.ScanType, engine Engine) error {
+	if st == "" || strings.ContainsAny(string(st), ", \t\r\n") {
+		return fmt.Errorf("invalid scan type name %q", st)
+	}
+	if engine == nil {
+		return fmt.Errorf("scan type %q: nil engine", st)
+	}
+	if builtinScanType(st) {
+		return fmt.Errorf("scan type %q is built in", st)
+	}
+	enginesMu.Lock()
+	defer enginesMu.Unlock()
+	if engines[st] != nil {
+		return fmt.Errorf("scan type %q already registered", st)
+	}
+	engines[st] = engine
+	return nil
+}
+
+// RegisteredScanTypes returns the custom scan types, sorted.
+func RegisteredScanTypes() []port.ScanType {
+	enginesMu.RLock()
+	defer enginesMu.RUnlock()
+	types := make([]port.ScanType, 0, len(engines))
+	for st := range engines {
+		types = append(types, st)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+func lookupEngine(st port.ScanType) Engine {
+	enginesMu.RLock()
+	defer enginesMu.RUnlock()
+	return engines[st]
+}
+
+func builtinScanType(st port.ScanType) bool {
+	switch st {
+	case port.ScanTCP, port.ScanUDP, port.ScanStealth, port.ScanPing:
+		return true
+	}
+	return false
+}
+
+// checkScanTypes rejects per-port scan types that are neither built in
+// nor registered, before any job is built.
+func (c Config) checkScanTypes() error {
+	for _, types := range [][]port.ScanType{c.ScanTypes, c.DefaultScanTypes} {
+		for _, st := range types {
+			switch {
+			case st == port.ScanPing:
+				return errors.New("invalid manager config: ping is a host probe, set ScanPing")
+			case builtinScanType(st):
+			case lookupEngine(st) == nil:
+				return fmt.Errorf("invalid manager config: unknown scan type %q", st)
+			}
+		}
+	}
+	return nil
+}
+
+// customScan runs a registered engine for one job.
+func (m *Manager) customScan(ctx context.Context, job port.PortJob, st port.ScanType) port.PortResult {
+	res := lookupEngine(st)(ctx, m.cfg, job.IP, job.Port)
+	if res.IP == "" {
+		res.IP = job.IP
+	}
+	if res.Port == 0 {
+		res.Port = job.Port
+	}
+	if res.Proto == "" {
+		res.Proto = string(st)
+	}
+	return res
+}
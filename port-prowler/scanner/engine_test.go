@@ -0,0 +1,89 @@
+package scanner
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"portprowler/port"
+	"portprowler/testsupport"
+)
+
+func TestRegisterScanType(t *testing.T) {
+	even := port.ScanType("test-even")
+	err := RegisterScanType(even, func(ctx context.Context, cfg Config, ip string, portNum uint16) port.PortResult {
+		if portNum%2 == 0 {
+			return port.PortResult{State: "open", Error: "even"}
+		}
+		return port.PortResult{State: "closed"}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		enginesMu.Lock()
+		delete(engines, even)
+		enginesMu.Unlock()
+	})
+	noop := func(context.Context, Config, string, uint16) port.PortResult { return port.PortResult{} }
+	for _, st := range []port.ScanType{even, port.ScanTCP, port.ScanPing, "", "a,b"} {
+		if err := RegisterScanType(st, noop); err == nil {
+			t.Errorf("registered %q", st)
+		}
+	}
+	if err := RegisterScanType("test-nil", nil); err == nil {
+		t.Error("registered a nil engine")
+	}
+	found := false
+	for _, st := range RegisteredScanTypes() {
+		found = found || st == even
+	}
+	if !found {
+		t.Errorf("%s not in %v", even, RegisteredScanTypes())
+	}
+
+	fake := testsupport.NewNetwork()
+	fake.AddHost("192.0.2.1").TCP(22, testsupport.Port{})
+	cfg := Config{
+		Targets:    []port.Target{{Name: "a.example", IP: "192.0.2.1"}},
+		Ports:      []uint16{21, 22},
+		ScanTCP:    true,
+		ScanTypes:  []port.ScanType{even, port.ScanTCP},
+		Workers:    1,
+		TCPTimeout: time.Second,
+		Dialer:     fake,
+	}
+	mgr := NewManager(cfg)
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	var got []string
+	for r := range out {
+		got = append(got, r.Target+" "+r.IP+" "+r.Proto+" "+r.State+" "+r.Error)
+	}
+	want := []string{
+		"a.example 192.0.2.1 tcp closed connection refused",
+		"a.example 192.0.2.1 test-even closed ",
+		"a.example 192.0.2.1 tcp open ",
+		"a.example 192.0.2.1 test-even open even",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("results:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	for _, c := range []struct {
+		types, defaults []port.ScanType
+		want            string
+	}{
+		{[]port.ScanType{"bogus"}, nil, `unknown scan type "bogus"`},
+		{nil, []port.ScanType{"bogus"}, `unknown scan type "bogus"`},
+		{[]port.ScanType{port.ScanPing}, nil, "set ScanPing"},
+	} {
+		cfg.ScanTypes, cfg.DefaultScanTypes = c.types, c.defaults
+		if _, err := NewManager(cfg).Run(context.Background()); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("types %v, defaults %v: %v", c.types, c.defaults, err)
+		}
+	}
+}
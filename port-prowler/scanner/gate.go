@@ -0,0 +1,150 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"portprowler/audit"
+	"portprowler/logging"
+	"portprowler/netutil"
+	"portprowler/port"
+)
+
+// tcpPingPorts are dialled by the ping gate when a host ignores the ICMP
+// or UDP ping, as many firewalls drop those: a connection or a refusal on
+// either proves the host is up.
+var tcpPingPorts = []uint16{443, 80}
+
+// pingGated reports whether addresses are pinged before their ports are
+// scanned. A ping-only scan needs no gate.
+func (m *Manager) pingGated() bool {
+	return m.cfg.PingGate && !m.pingOnly()
+}
+
+// pingGate pings every address, workers at a time, before any port job
+// is queued, and marks those that answer no ping unreachable so skipHost
+// skips their ports. An unreachable address gets its ping result, with
+// Error "host unreachable", on out in place of its port results; with
+// ScanPing every address gets its ping result, in place of a ping job.
+// Addresses not pinged when the scan is stopped or ctx cancelled are left
+// alone.
+func (m *Manager) pingGate(ctx context.Context, out chan<- port.PortResult, workers int) {
+	targets := m.dedupTargets()
+	next := make(chan dedupTarget)
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(targets); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range next {
+				if !m.waitRunnable(ctx) {
+					continue
+				}
+				res := m.gatePing(ctx, t)
+				if ctx.Err() != nil {
+					continue
+				}
+				m.stats.Record(res)
+				if res.State == "up" && !m.cfg.ScanPing {
+					continue
+				}
+				if res.State != "up" {
+					m.mu.Lock()
+					if m.noReply == nil {
+						m.noReply = make(map[string]bool)
+					}
+					m.noReply[t.IP] = true
+					m.mu.Unlock()
+				}
+				for _, name := range append([]string{t.Name}, t.aliases...) {
+					dup := res
+					dup.Target = name
+					if !m.deliver(ctx, out, dup) {
+						break
+					}
+				}
+			}
+		}()
+	}
+feed:
+	for _, t := range targets {
+		select {
+		case <-ctx.Done():
+			break feed
+		case <-m.stop:
+			break feed
+		case next <- t:
+		}
+	}
+	close(next)
+	wg.Wait()
+}
+
+// gatePing checks whether t is up: the usual ping (see PingScanVia)
+// first, then a TCP ping of tcpPingPorts. A host answering neither is
+// reported down with Error "host unreachable". Every attempt goes to the
+// audit log.
+func (m *Manager) gatePing(ctx context.Context, t dedupTarget) port.PortResult {
+	timeout := m.cfg.PingGateTimeout
+	if timeout <= 0 {
+		timeout = m.cfg.TimeoutFor(port.ScanTCP)
+	}
+	start := time.Now()
+	res := PingScanVia(ctx, m.cfg.PacketDialer, m.cfg.PacketListener, t.IP, timeout, m.cfg.Verbose)
+	res.Target = t.Name
+	res.Timestamp = time.Now().UTC()
+	if m.cfg.Audit != nil {
+		m.cfg.Audit.Probe(start, res)
+	}
+	if res.State == "up" {
+		return res
+	}
+	if up, ok := tcpPing(ctx, m.cfg.Dialer, m.cfg.Audit, t.IP, timeout); ok {
+		up.Target = t.Name
+		up.Timestamp = time.Now().UTC()
+		return up
+	}
+	res.Error = "host unreachable"
+	if m.cfg.Verbose {
+		logging.Verbosef("ping gate: %s unreachable, skipping its ports", t.IP)
+	}
+	return res
+}
+
+// tcpPing dials tcpPingPorts on ip in turn and returns an "up" ping
+// result for the first that accepts or refuses the connection. Each dial
+// is recorded in log, when set, as kind "tcp-ping".
+func tcpPing(ctx context.Context, d netutil.ContextDialer, log *audit.Log, ip string, timeout time.Duration) (port.PortResult, bool) {
+	if d == nil {
+		d = &net.Dialer{}
+	}
+	for _, p := range tcpPingPorts {
+		dctx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		addr := net.JoinHostPort(ip, strconv.Itoa(int(p)))
+		conn, err := d.DialContext(dctx, "tcp", addr)
+		rtt := time.Since(start)
+		cancel()
+		if log != nil {
+			log.Conn("tcp-ping", start, addr, conn, err)
+		}
+		if err == nil {
+			conn.Close()
+		}
+		var targetErr *netutil.TargetError
+		if err == nil || isConnRefusedErr(err) || (errors.As(err, &targetErr) && targetErr.Refused) {
+			res := pingResult(ip, "tcp-ping")
+			res.State = "up"
+			res.RTTMillis = rtt.Milliseconds()
+			return res, true
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return port.PortResult{}, false
+}
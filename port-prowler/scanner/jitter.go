@@ -0,0 +1,72 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// ParseJitter parses a --jitter range such as "50-250ms" or "1s-3s"; the
+// low end may leave out the unit of the high end. A single duration d
+// means 0-d.
+func ParseJitter(s string) (low, high time.Duration, err error) {
+	lo, hi, isRange := strings.Cut(strings.TrimSpace(s), "-")
+	if !isRange {
+		lo, hi = "0", lo
+	}
+	if high, err = time.ParseDuration(hi); err != nil {
+		return 0, 0, fmt.Errorf("invalid jitter %q (want min-max, e.g. 50-250ms)", s)
+	}
+	if low, err = time.ParseDuration(lo); err != nil {
+		unit := strings.TrimLeft(hi, "0123456789.")
+		if low, err = time.ParseDuration(lo + unit); err != nil {
+			return 0, 0, fmt.Errorf("invalid jitter %q (want min-max, e.g. 50-250ms)", s)
+		}
+	}
+	if low < 0 || high <= 0 || low > high {
+		return 0, 0, fmt.Errorf("invalid jitter %q: want 0 <= min <= max and max > 0", s)
+	}
+	return low, high, nil
+}
+
+// jitterWait spaces probes to ip by a random JitterMin-JitterMax delay:
+// each probe reserves the next slot for its address and sleeps until
+// that slot. Different addresses do not wait for each other. It returns
+// false when the scan was stopped or ctx cancelled.
+func (m *Manager) jitterWait(ctx context.Context, ip string) bool {
+	if m.cfg.JitterMax <= 0 {
+		return true
+	}
+	delay := m.cfg.JitterMin
+	if span := m.cfg.JitterMax - m.cfg.JitterMin; span > 0 {
+		delay += time.Duration(rand.Int63n(int64(span) + 1))
+	}
+	now := time.Now()
+	m.jitterMu.Lock()
+	if m.nextProbe == nil {
+		m.nextProbe = make(map[string]time.Time)
+	}
+	at := m.nextProbe[ip]
+	if at.Before(now) {
+		at = now
+	}
+	m.nextProbe[ip] = at.Add(delay)
+	m.jitterMu.Unlock()
+
+	wait := at.Sub(now)
+	if wait <= 0 {
+		return true
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-m.stop:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
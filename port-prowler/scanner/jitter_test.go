@@ -0,0 +1,71 @@
+package scanner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"portprowler/port"
+	"portprowler/testsupport"
+)
+
+func TestParseJitter(t *testing.T) {
+	cases := []struct {
+		in       string
+		low, hi  time.Duration
+		wantFail bool
+	}{
+		{in: "50-250ms", low: 50 * time.Millisecond, hi: 250 * time.Millisecond},
+		{in: "1s-3s", low: time.Second, hi: 3 * time.Second},
+		{in: "500ms-1s", low: 500 * time.Millisecond, hi: time.Second},
+		{in: "0.5-2s", low: 500 * time.Millisecond, hi: 2 * time.Second},
+		{in: "100ms", hi: 100 * time.Millisecond},
+		{in: "250-50ms", wantFail: true},
+		{in: "0", wantFail: true},
+		{in: "fast", wantFail: true},
+		{in: "-5ms", wantFail: true},
+	}
+	for _, c := range cases {
+		low, hi, err := ParseJitter(c.in)
+		if (err != nil) != c.wantFail {
+			t.Fatalf("ParseJitter(%q) err = %v, wantFail %v", c.in, err, c.wantFail)
+		}
+		if err == nil && (low != c.low || hi != c.hi) {
+			t.Errorf("ParseJitter(%q) = %v-%v, want %v-%v", c.in, low, hi, c.low, c.hi)
+		}
+	}
+}
+
+func TestManager_Jitter(t *testing.T) {
+	fake := testsupport.NewNetwork()
+	fake.AddHost("192.0.2.1")
+	fake.AddHost("192.0.2.2")
+	const gap = 30 * time.Millisecond
+	mgr := NewManager(Config{
+		Targets:    []port.Target{{Name: "a.example", IP: "192.0.2.1"}, {Name: "b.example", IP: "192.0.2.2"}},
+		Ports:      []uint16{1, 2, 3, 4},
+		ScanTCP:    true,
+		Workers:    8,
+		TCPTimeout: time.Second,
+		Dialer:     fake,
+		JitterMin:  gap,
+		JitterMax:  gap,
+	})
+	start := time.Now()
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for range out {
+		n++
+	}
+	took := time.Since(start)
+	if n != 8 {
+		t.Fatalf("got %d results, want 8", n)
+	}
+	// Four probes per address need three gaps; the addresses wait in parallel.
+	if took < 3*gap || took > 6*gap {
+		t.Errorf("scan took %v, want about %v", took, 3*gap)
+	}
+}
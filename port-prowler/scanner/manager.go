@@ -2,38 +2,299 @@ package scanner
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"portprowler/audit"
 	"portprowler/detector"
+	"portprowler/logging"
+	"portprowler/netutil"
 	"portprowler/port"
+	"portprowler/rawsock"
+	"portprowler/report"
+	"portprowler/stats"
 )
 
 // Config contains runtime configuration for the Manager.
 type Config struct {
-	Target        string
-	IP            string
-	Ports         []uint16
-	ScanTCP       bool
-	ScanUDP       bool
-	ScanStealth   bool
-	Workers       int
-	Timeout       time.Duration
+	Target string
+	IP     string
+	// Targets lists all hosts to scan. When empty, Target/IP is scanned.
+	Targets     []port.Target
+	Ports       []uint16
+	ScanTCP     bool
+	ScanUDP     bool
+	ScanStealth bool
+	// ScanPing adds one host reachability probe per target (ICMP echo when
+	// privileged, UDP ping otherwise). Ports may be empty when it is the
+	// only scan type.
+	ScanPing bool
+	// PingGate pings every address before scanning its ports, with ICMP
+	// echo or UDP ping as ScanPing does and then a TCP connect to ports
+	// 443 and 80, each waiting PingGateTimeout (zero uses TCPTimeout).
+	// The ports of an address answering none are skipped; it gets a
+	// single ping result, state "down" with Error "host unreachable",
+	// instead of a filtered result per port. With ScanPing the gate's
+	// results stand in for the ping probes.
+	PingGate        bool
+	PingGateTimeout time.Duration
+	// ScanTypes adds per-port scan types, built in or registered with
+	// RegisterScanType, after those selected by ScanTCP, ScanUDP and
+	// ScanStealth. Run rejects types that are neither.
+	ScanTypes []port.ScanType
+	// DefaultScanTypes are the port scan types run when none of ScanTCP,
+	// ScanUDP, ScanStealth and ScanTypes is set; empty means TCP.
+	DefaultScanTypes []port.ScanType
+	Workers          int
+	// Per-protocol probe timeouts. UDP usually needs a noticeably longer
+	// timeout than TCP since silence is the common case.
+	TCPTimeout     time.Duration
+	UDPTimeout     time.Duration
+	StealthTimeout time.Duration
+	// UDPGrace, when positive, keeps the socket of each UDP probe that
+	// timed out open this much longer, and the scan ends only once the
+	// last of them is done. A reply or port-unreachable arriving in that
+	// time upgrades the probe's open|filtered result in Report to open or
+	// closed, marked "late response"; results already sent on the results
+	// channel or to hooks are not revised.
+	UDPGrace time.Duration
+	// DNSQuery is what UDP probes of port 53 ask; zero fields take
+	// DefaultDNSQuery's values. A valid answer fills the result's DNS
+	// field, which tells whether the server recursed for us.
+	DNSQuery      DNSQuery
 	ServiceDetect bool
 	OSDetect      bool
 	Verbose       bool
+	// TLS inspection controls used by service detection.
+	TLSServerName string
+	TLSInsecure   bool
+	TLSCiphers    []uint16 // cipher suites offered by TLS probes (caps at TLS 1.2)
+	TLSALPN       []string // ALPN protocols offered by TLS probes
+	// TelemetryInterval is how often verbose mode reports worker/queue
+	// telemetry; zero uses a 2s default.
+	TelemetryInterval time.Duration
+	// HTTPCapture keeps this many body bytes (plus <title>) for web ports; 0 disables.
+	HTTPCapture int
+	// BannerLimit caps the bytes read for a banner, by connect probes and
+	// by detection probes (at most wire.MaxBanner); zero keeps the
+	// defaults of 1024 and 2048 bytes.
+	BannerLimit int
+	// DetectBytes caps the bytes service detection reads from one host
+	// over all its connections (banners, TLS handshakes, HTTP responses,
+	// virtual hosts); zero uses DefaultDetectBytes. Reads past it fail,
+	// so a service streaming endless data cannot exhaust memory.
+	DetectBytes int64
+	// Dialer carries TCP connect probes and detection connections, e.g.
+	// through a proxy chain; nil dials directly. Only connect-mode scans
+	// can be proxied.
+	Dialer netutil.ContextDialer
+	// PacketDialer opens the connected UDP sockets of UDP probes and the
+	// UDP ping, and PacketListener the ICMP socket of privileged pings; nil
+	// uses the real network. With Dialer they let tests and embedders run
+	// scans over fake networks or userspace stacks. Stealth scans always
+	// use raw sockets.
+	PacketDialer   netutil.ContextDialer
+	PacketListener PacketListener
+	// DetectTimeout bounds all service detection work for one open port
+	// (dials, probes, TLS, HTTP capture); zero uses 3x the probe timeout.
+	DetectTimeout time.Duration
+	// Fingerprints keeps unmatched banners with their probe for export.
+	Fingerprints bool
+	// HexDump dumps up to this many bytes of banners that identified no
+	// service into PortResult.HexDump; 0 disables.
+	HexDump int
+	// Audit, when set, records every probe and detection connection.
+	Audit *audit.Log
+	// Progress, when set, receives a stats.Progress event as one JSON line
+	// every ProgressInterval (zero uses 1s) and once more when the scan ends.
+	Progress         io.Writer
+	ProgressInterval time.Duration
+	// OnResult, when set, is called with every result as it is delivered,
+	// and OnHostComplete with a host's report once all its probes have
+	// finished (hosts left unfinished by Stop or cancellation are not
+	// reported). Hooks run on worker goroutines, one call at a time, and
+	// slow hooks slow the scan down.
+	OnResult       func(port.PortResult)
+	OnHostComplete func(report.HostReport)
+	// ResultBuffer is the capacity of the results channel; zero uses
+	// DefaultResultBuffer. Once it is full, workers wait for the consumer
+	// to catch up, so a slow consumer slows the scan down instead of the
+	// results piling up in the channel. The limit is the channel's only:
+	// the manager also keeps every result for Report, unless DropResults
+	// is set.
+	ResultBuffer int
+	// DropResults makes workers drop results the results channel has no
+	// room for instead of waiting, for consumers (e.g. a slow output
+	// backend) that must not hold up the scan. Dropped results still reach
+	// the hooks, and are counted in stats.Snapshot.Dropped. So that memory
+	// stays bounded however large the scan, the manager then keeps no
+	// results: Report and Stop list the hosts without them.
+	DropResults bool
+	// MaxOpenPerHost, when positive, stops scanning an address once this
+	// many of its ports were found open; a middlebox answering on every
+	// port would otherwise be scanned in full. The remaining ports are
+	// skipped and the host's report is marked Capped.
+	MaxOpenPerHost int
+	// FirstOpen stops scanning an address as soon as one of its ports is
+	// found open, for liveness sweeps where any open port will do. Unlike
+	// MaxOpenPerHost it does not mark the host Capped. Probes already in
+	// flight still finish, so a host may report more than one open port.
+	FirstOpen bool
+	// DeadAfter, when positive, marks an address down once its first
+	// DeadAfter ports all went unanswered (TCP connects or SYNs that timed
+	// out) with none answering; its report has Down set unless a later
+	// port answers. UDP silence is common on live hosts and is not
+	// counted. SkipDead also skips the remaining ports of a down address
+	// instead of waiting out a timeout on each; probes already in flight
+	// still finish.
+	DeadAfter int
+	SkipDead  bool
+	// QuietHours, when set, is a daily window (e.g. business hours) in
+	// which no new jobs start or, with QuietWorkers, at most that many run
+	// at once. Jobs in flight when a window begins complete.
+	QuietHours   *QuietHours
+	QuietWorkers int
+	// JitterMin and JitterMax, when JitterMax is positive, space the
+	// probes to each address by a random delay in that range, so the
+	// traffic has no regular rhythm. Addresses are spaced independently.
+	JitterMin, JitterMax time.Duration
+	// MaxBandwidth and HostBandwidth, when positive, cap the probe traffic
+	// of the whole scan and to each address in bytes per second. Probes
+	// are charged their IP and transport headers plus payload (a UDP
+	// probe's actual payload, e.g. a DNS query), not a flat packet count.
+	// Service detection traffic is not metered.
+	MaxBandwidth, HostBandwidth int64
+	// LikelyFirst scans each address's most commonly open ports first
+	// (see port.LikelyFirst), so findings stream out early; every
+	// requested port is still scanned.
+	LikelyFirst bool
+	// Stateless runs a stealth-only scan masscan-style: SYNs carry a
+	// SipHash cookie instead of being tracked, and only ports that answer
+	// produce results (open or closed). Detection, jitter and quiet hours
+	// do not apply. See runStateless.
+	Stateless bool
+	// FastIO moves stealth probes to PACKET_MMAP rings (see
+	// rawsock.Options.FastIO). Engines that cannot set them up use raw
+	// sockets as usual.
+	FastIO bool
+	// SYNShards, when above one, runs that many stealth engines per
+	// interface, each with its own raw socket, source port and send and
+	// receive goroutines (at most MaxSYNShards).
+	SYNShards int
+	// PinCPUs pins the goroutines of stealth engine i to PinCPUs[i %
+	// len(PinCPUs)]; PinNUMA picks the CPUs of the interface's NUMA node
+	// instead. Linux only; engines that cannot be pinned run unpinned.
+	PinCPUs []int
+	PinNUMA bool
+	// RSTClose closes connect-scan sockets with a RST (SO_LINGER 0)
+	// instead of a FIN, so high-concurrency scans do not pile up
+	// TIME_WAIT sockets locally or half-closed sessions on targets.
+	// Proxied connections are closed normally.
+	RSTClose bool
+}
+
+// DefaultResultBuffer is the results channel capacity used when
+// Config.ResultBuffer is zero.
+const DefaultResultBuffer = 1024
+
+// DefaultDetectBytes is the per-host detection read limit used when
+// Config.DetectBytes is zero.
+const DefaultDetectBytes = 4 << 20
+
+// ScanTargets returns the hosts to scan: Targets, or Target/IP when
+// Targets is empty.
+func (c Config) ScanTargets() []port.Target {
+	if len(c.Targets) > 0 {
+		return c.Targets
+	}
+	if c.Target == "" && c.IP == "" {
+		return nil
+	}
+	return []port.Target{{Name: c.Target, IP: c.IP}}
+}
+
+// TimeoutFor returns the probe timeout configured for the given scan type.
+func (c Config) TimeoutFor(st port.ScanType) time.Duration {
+	switch st {
+	case port.ScanUDP:
+		return c.UDPTimeout
+	case port.ScanStealth:
+		return c.StealthTimeout
+	default:
+		return c.TCPTimeout
+	}
 }
 
 // Manager orchestrates job creation and worker pool.
 type Manager struct {
-	cfg Config
+	cfg   Config
+	stats *stats.Collector
+
+	progressMu   sync.Mutex // serialises writes to cfg.Progress
+	progressDone bool       // the final event was written
+
+	mu        sync.Mutex
+	results   []port.PortResult // everything delivered, for Report; nil with DropResults
+	cancelled bool              // stopped or context cancelled before all jobs ran
+	resume    chan struct{}     // non-nil while paused; closed by Resume
+	stop      chan struct{}     // closed by Stop
+	stopOnce  sync.Once
+	finished  chan struct{}   // closed after Run's results channel; nil before Run
+	err       error           // how the scan ended, for Wait; set before finished is closed
+	open      map[string]int  // ports found open per IP, with an open-port limit
+	capped    map[string]bool // IPs that reached the limit
+	alive     map[string]bool // IPs with a port that answered, with DeadAfter
+	silent    map[string]int  // unanswered ports per IP, with DeadAfter
+	noReply   map[string]bool // IPs that failed the ping gate
+
+	quietSlots chan struct{} // running jobs in quiet hours, with QuietWorkers
+	quietEnd   atomic.Int64  // end of the quiet window last logged (Unix seconds)
+
+	jitterMu  sync.Mutex
+	nextProbe map[string]time.Time // earliest start of the next probe per IP, with jitter
+
+	bwMu     sync.Mutex
+	bwGlobal *byteLimiter            // with MaxBandwidth
+	bwHosts  map[string]*byteLimiter // per IP, with HostBandwidth
+
+	lateMu    sync.Mutex
+	lateWG    sync.WaitGroup
+	lateConns map[net.Conn]bool    // UDP sockets kept open, with UDPGrace
+	late      map[string]lateReply // late UDP replies by ip:port
+
+	budgetMu sync.Mutex
+	budgets  map[string]*netutil.ReadBudget // detection read budget per host IP
+
+	pool *detector.Pool // idle detection connections, shared by all hosts
+
+	synMu      sync.Mutex
+	synEngines map[string]synShards                     // stealth engines by interface
+	synErr     error                                    // why no engine can be opened
+	synReply   func(rawsock.TCPSegment, time.Time) bool // makes engines stateless, with Stateless; false for a duplicate
+
+	hookMu   sync.Mutex                   // serialises hook calls
+	pending  map[string]int               // unfinished jobs per target name
+	byTarget map[string][]port.PortResult // results per target name, for OnHostComplete
 }
 
 // NewManager creates a new Manager with the provided config.
 func NewManager(cfg Config) *Manager {
-	return &Manager{cfg: cfg}
+	m := &Manager{cfg: cfg, stats: stats.New(), stop: make(chan struct{}), pool: detector.NewPool()}
+	if cfg.QuietHours != nil && cfg.QuietWorkers > 0 {
+		m.quietSlots = make(chan struct{}, cfg.QuietWorkers)
+	}
+	return m
+}
+
+// Stats returns a snapshot of the scan statistics collected so far.
+func (m *Manager) Stats() stats.Snapshot {
+	return m.stats.Snapshot()
 }
 
 // sentinel error returned when stealth requested but privileges missing
@@ -42,39 +303,62 @@ var ErrNeedPriv = errors.New("stealth requires raw socket privileges")
 // Run starts the worker pool and returns a results channel. It returns an error for invalid config.
 // The returned channel will be closed once all work is completed.
 func (m *Manager) Run(ctx context.Context) (<-chan port.PortResult, error) {
-	if m.cfg.Target == "" || m.cfg.IP == "" {
+	targets := m.cfg.ScanTargets()
+	if len(targets) == 0 {
 		return nil, errors.New("invalid manager config: missing target/ip")
 	}
-	if len(m.cfg.Ports) == 0 {
+	for _, t := range targets {
+		if t.Name == "" || t.IP == "" {
+			return nil, errors.New("invalid manager config: missing target/ip")
+		}
+	}
+	if len(m.cfg.Ports) == 0 && !m.pingOnly() {
 		return nil, errors.New("no ports to scan")
 	}
-
-	// Determine scan types for jobs
-	scanTypes := make([]port.ScanType, 0, 3)
-	if m.cfg.ScanStealth {
-		scanTypes = append(scanTypes, port.ScanStealth)
+	if err := m.cfg.checkScanTypes(); err != nil {
+		return nil, err
 	}
-	if m.cfg.ScanTCP {
-		scanTypes = append(scanTypes, port.ScanTCP)
+	if m.cfg.SYNShards > MaxSYNShards {
+		return nil, fmt.Errorf("invalid manager config: at most %d SYN shards", MaxSYNShards)
 	}
-	if m.cfg.ScanUDP {
-		scanTypes = append(scanTypes, port.ScanUDP)
+	if m.cfg.Stateless {
+		return m.runStateless(ctx)
 	}
-	// Default to TCP if none specified
-	if len(scanTypes) == 0 {
-		scanTypes = append(scanTypes, port.ScanTCP)
-	}
-
-	jobCount := len(m.cfg.Ports)
-	jobChan := make(chan port.PortJob, jobCount)
-	resultsChan := make(chan port.PortResult, jobCount*len(scanTypes))
 
+	jobs := m.buildJobs()
+	probes := 0
+	for _, j := range jobs {
+		probes += len(j.ScanTypes)
+	}
+	if m.pingGated() {
+		probes += len(m.dedupTargets())
+	}
+	m.stats.SetTotal(probes)
+	if m.cfg.OnHostComplete != nil {
+		m.pending = make(map[string]int)
+		m.byTarget = make(map[string][]port.PortResult)
+		for _, j := range jobs {
+			m.pending[j.Target]++
+			for _, alias := range j.Aliases {
+				m.pending[alias]++
+			}
+		}
+	}
 	workers := m.cfg.Workers
 	if workers <= 0 {
 		workers = 1
 	}
+	// A short queue is enough to keep the workers busy; the dispatcher
+	// feeds it as they take jobs.
+	jobChan := make(chan port.PortJob, 2*workers)
+	resultsChan := make(chan port.PortResult, m.resultBuffer())
 
 	var wg sync.WaitGroup
+	m.stats.Start()
+	m.mu.Lock()
+	m.finished = make(chan struct{})
+	finished := m.finished
+	m.mu.Unlock()
 
 	// start workers
 	for i := 0; i < workers; i++ {
@@ -82,163 +366,794 @@ func (m *Manager) Run(ctx context.Context) (<-chan port.PortResult, error) {
 		go func() {
 			defer wg.Done()
 			for {
+				if !m.waitRunnable(ctx) {
+					return
+				}
 				select {
 				case <-ctx.Done():
 					return
+				case <-m.stop:
+					return
 				case job, ok := <-jobChan:
 					if !ok {
 						return
 					}
-					// Execute scan types sequentially for this job.
-					for _, st := range job.ScanTypes {
-						select {
-						case <-ctx.Done():
-							return
-						default:
-						}
-						if st == port.ScanTCP {
-							// perform real TCP connect scan
-							if m.cfg.Verbose {
-								fmt.Printf("[verbose] worker: scanning tcp %s:%d\n", job.IP, job.Port)
-							}
-							res := TCPScan(ctx, job.IP, job.Port, m.cfg.Timeout, m.cfg.Verbose)
-							// attach original target string from job
-							res.Target = job.Target
-
-							// If open and service detection enabled, run detector and use updated result.
-							if res.State == "open" && m.cfg.ServiceDetect {
-								dcfg := detector.Config{
-									ServiceDetect: m.cfg.ServiceDetect,
-									Timeout:       m.cfg.Timeout,
-									Verbose:       m.cfg.Verbose,
-								}
-								res = detector.DetectService(ctx, dcfg, res)
-							}
-
-							// If open and OS detection enabled, run OS heuristics (prefer after service detection).
-							if res.State == "open" && m.cfg.OSDetect {
-								if osGuess, osConf := detector.DetectOSForResult(res); osGuess != "" {
-									res.OSGuess = osGuess
-									// Overwrite Confidence with OS confidence per spec (best-effort).
-									res.Confidence = osConf
-								}
-							}
-
-							select {
-							case <-ctx.Done():
-								return
-							case resultsChan <- res:
-							}
-							continue
-						}
-						if st == port.ScanUDP {
-							// perform real UDP probe
-							if m.cfg.Verbose {
-								fmt.Printf("[verbose] worker: scanning udp %s:%d\n", job.IP, job.Port)
-							}
-							res := UDPScan(ctx, job.IP, job.Port, m.cfg.Timeout, m.cfg.Verbose)
-							res.Target = job.Target
-
-							// For UDP open results, optionally run service detection too (best-effort).
-							if res.State == "open" && m.cfg.ServiceDetect {
-								dcfg := detector.Config{
-									ServiceDetect: m.cfg.ServiceDetect,
-									Timeout:       m.cfg.Timeout,
-									Verbose:       m.cfg.Verbose,
-								}
-								res = detector.DetectService(ctx, dcfg, res)
-							}
-
-							// For UDP open results, optionally run OS detection if requested.
-							if res.State == "open" && m.cfg.OSDetect {
-								if osGuess, osConf := detector.DetectOSForResult(res); osGuess != "" {
-									res.OSGuess = osGuess
-									res.Confidence = osConf
-								}
-							}
-
-							select {
-							case <-ctx.Done():
-								return
-							case resultsChan <- res:
-							}
-							continue
-						}
-						if st == port.ScanStealth {
-							// perform stealth (SYN) scan via scaffold
-							if m.cfg.Verbose {
-								fmt.Printf("[verbose] worker: scanning stealth %s:%d\n", job.IP, job.Port)
-							}
-							res := StealthScan(ctx, job.IP, job.Port, m.cfg.Timeout, m.cfg.Verbose)
-							res.Target = job.Target
-
-							// If open and service detection enabled, run detector and use updated result.
-							if res.State == "open" && m.cfg.ServiceDetect {
-								dcfg := detector.Config{
-									ServiceDetect: m.cfg.ServiceDetect,
-									Timeout:       m.cfg.Timeout,
-									Verbose:       m.cfg.Verbose,
-								}
-								res = detector.DetectService(ctx, dcfg, res)
-							}
-
-							// If open and OS detection enabled, run OS heuristics.
-							if res.State == "open" && m.cfg.OSDetect {
-								if osGuess, osConf := detector.DetectOSForResult(res); osGuess != "" {
-									res.OSGuess = osGuess
-									res.Confidence = osConf
-								}
-							}
-
-							select {
-							case <-ctx.Done():
-								return
-							case resultsChan <- res:
-							}
-							continue
-						}
-						// For other scan types keep previous placeholder behavior for now.
-						res := port.PortResult{
-							Target: job.Target,
-							IP:     job.IP,
-							Port:   job.Port,
-							Proto:  string(st),
-							State:  "unknown",
-						}
-						select {
-						case <-ctx.Done():
-							return
-						case resultsChan <- res:
-						}
+					release, ok := m.quietGate(ctx)
+					if !ok {
+						m.markCancelled() // job was taken but never run
+						return
+					}
+					m.stats.WorkerBusy()
+					ok = m.runJob(ctx, job, resultsChan)
+					m.stats.WorkerIdle()
+					release()
+					if !ok {
+						return
 					}
 				}
 			}
 		}()
 	}
 
-	// dispatcher goroutine: enqueue jobs then close jobChan and wait for workers to finish, then close resultsChan
+	done := make(chan struct{})
+	if m.cfg.Verbose {
+		go m.reportTelemetry(done, jobChan, workers)
+	}
+	if m.cfg.Progress != nil {
+		go m.reportProgress(done)
+	}
+
+	// dispatcher goroutine: enqueue jobs until they run out or the scan is
+	// stopped or cancelled, then wait for the workers to finish and close
+	// resultsChan. Every send also waits on ctx and m.stop, so workers that
+	// quit early can never leave the dispatcher blocked.
 	go func() {
-		// enqueue jobs
-		for _, p := range m.cfg.Ports {
+		if m.pingGated() {
+			m.pingGate(ctx, resultsChan, workers)
+		}
+		queued := 0
+	enqueue:
+		for _, job := range jobs {
 			select {
 			case <-ctx.Done():
-				break
-			default:
+				break enqueue
+			case <-m.stop:
+				break enqueue
+			case jobChan <- job:
+				queued++
 			}
-			job := port.PortJob{
-				Target:    m.cfg.Target,
-				IP:        m.cfg.IP,
-				Port:      p,
-				ScanTypes: scanTypes,
-			}
-			jobChan <- job
 		}
 		close(jobChan)
 		// wait for workers
 		wg.Wait()
+		m.waitLateUDP(ctx)
+		m.pool.CloseIdle()
+		m.closeSYN()
+		m.settle(ctx, queued < len(jobs) || len(jobChan) > 0)
+		m.stats.Finish()
+		if m.cfg.Progress != nil {
+			m.writeProgress(true) // the final event precedes the end of results
+		}
+		close(done)
 		// close results
 		close(resultsChan)
+		close(finished)
 	}()
 
 	return resultsChan, nil
 }
+
+// Pause stops workers from starting further jobs until Resume; probes
+// already in flight complete. Pausing a paused scan has no effect.
+func (m *Manager) Pause() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.resume == nil {
+		m.resume = make(chan struct{})
+	}
+}
+
+// Resume continues a paused scan.
+func (m *Manager) Resume() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.resume != nil {
+		close(m.resume)
+		m.resume = nil
+	}
+}
+
+// Paused reports whether the scan is paused.
+func (m *Manager) Paused() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.resume != nil
+}
+
+// Stop ends the scan gracefully: no further jobs start, in-flight probes
+// (including their service detection) finish and are delivered, and the
+// results channel is closed. Results that no longer fit the channel are
+// kept for Report only, or not at all with DropResults. Unlike
+// cancelling Run's context, nothing is cut short. Stop waits for the
+// drain and returns the partial report, whose status is cancelled unless
+// every job had already run. It is safe to call more than once, and from
+// a paused scan.
+func (m *Manager) Stop() report.ScanReport {
+	m.stopOnce.Do(func() { close(m.stop) })
+	m.mu.Lock()
+	finished := m.finished
+	m.mu.Unlock()
+	if finished != nil {
+		<-finished
+	}
+	return m.Report(report.Meta{})
+}
+
+// ErrStopped is returned by Wait when Stop ended the scan before every job
+// had run.
+var ErrStopped = errors.New("scan stopped")
+
+// ErrNotStarted is returned by Wait when Run has not started a scan.
+var ErrNotStarted = errors.New("scan not started")
+
+// Scan outcomes reported by Wait.
+const (
+	OutcomeCompleted = "completed" // every job ran and no probe failed
+	OutcomePartial   = "partial"   // every job ran, but some probes failed with errors
+	OutcomeFailed    = "failed"    // every job ran, and every probe failed with an error
+	OutcomeCancelled = "cancelled" // cancelled or stopped before every job ran
+)
+
+// ScanSummary is how a finished scan ended.
+type ScanSummary struct {
+	Outcome string
+	// Err is why the scan ended early: the context's error when Run's
+	// context was cancelled, ErrStopped after Stop; nil otherwise.
+	Err   error
+	Stats stats.Snapshot // final statistics; Errors counts the failed probes
+}
+
+// Wait blocks until the scan started by Run has finished (every worker
+// has returned and the results channel is closed) and returns how it
+// ended. Its error is the summary's Err, or ctx's error when ctx is done
+// first, in which case the scan carries on. The results channel must be
+// drained (or be large enough) for the scan to finish.
+func (m *Manager) Wait(ctx context.Context) (ScanSummary, error) {
+	m.mu.Lock()
+	finished := m.finished
+	m.mu.Unlock()
+	if finished == nil {
+		return ScanSummary{}, ErrNotStarted
+	}
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		return ScanSummary{}, ctx.Err()
+	}
+	sum := ScanSummary{Stats: m.stats.Snapshot()}
+	m.mu.Lock()
+	sum.Err = m.err
+	cancelled := m.cancelled
+	m.mu.Unlock()
+	switch {
+	case cancelled:
+		sum.Outcome = OutcomeCancelled
+	case sum.Stats.Errors > 0 && sum.Stats.Errors == sum.Stats.Probes:
+		sum.Outcome = OutcomeFailed
+	case sum.Stats.Errors > 0:
+		sum.Outcome = OutcomePartial
+	default:
+		sum.Outcome = OutcomeCompleted
+	}
+	return sum, sum.Err
+}
+
+// settle records how the scan ended once no job runs anymore: cancelled
+// when jobs were left unrun, on top of those cut short.
+func (m *Manager) settle(ctx context.Context, cancelled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cancelled = m.cancelled || cancelled || ctx.Err() != nil
+	switch {
+	case !m.cancelled:
+		m.err = nil
+	case ctx.Err() != nil:
+		m.err = ctx.Err()
+	default:
+		m.err = ErrStopped
+	}
+}
+
+// Report returns the results delivered so far grouped per host, with
+// meta's Started, Finished and Status filled in. Call it after the results
+// channel is closed for the complete picture. With DropResults it has no
+// results.
+func (m *Manager) Report(meta report.Meta) report.ScanReport {
+	snap := m.stats.Snapshot()
+	meta.Started, meta.Finished = snap.Started, snap.Finished
+	m.mu.Lock()
+	results := append([]port.PortResult(nil), m.results...)
+	m.applyLateUDP(results)
+	meta.Status = report.StatusComplete
+	if m.cancelled || m.finished == nil {
+		meta.Status = report.StatusCancelled
+	}
+	m.mu.Unlock()
+	rep := report.Build(meta, m.cfg.ScanTargets(), results)
+	m.markHosts(rep.Hosts)
+	return rep
+}
+
+// markHosts sets Capped on hosts with an address that reached
+// MaxOpenPerHost, and Down on hosts whose addresses are all down.
+func (m *Manager) markHosts(hosts []report.HostReport) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range hosts {
+		down := m.cfg.DeadAfter > 0 && len(hosts[i].Addrs) > 0
+		for _, a := range hosts[i].Addrs {
+			if m.capped[a] && !m.cfg.FirstOpen {
+				hosts[i].Capped = true
+			}
+			down = down && m.down(a)
+		}
+		hosts[i].Down = down
+	}
+}
+
+// openLimit returns how many open ports stop an address from being
+// scanned further, or 0 for no limit.
+func (m *Manager) openLimit() int {
+	if m.cfg.FirstOpen {
+		return 1
+	}
+	return m.cfg.MaxOpenPerHost
+}
+
+// skipHost reports whether job's address reached its open-port limit,
+// failed the ping gate or, with SkipDead, is down, in which case the job
+// is not run.
+func (m *Manager) skipHost(job port.PortJob) bool {
+	if job.Port == 0 || (m.openLimit() <= 0 && !m.cfg.SkipDead && !m.pingGated()) {
+		return false
+	}
+	m.mu.Lock()
+	skip := m.capped[job.IP] || m.noReply[job.IP] || (m.cfg.SkipDead && m.down(job.IP))
+	m.mu.Unlock()
+	if skip {
+		m.stats.Skip(len(job.ScanTypes))
+	}
+	return skip
+}
+
+// down reports whether ip's first DeadAfter ports went unanswered and
+// none answered since. m.mu must be held.
+func (m *Manager) down(ip string) bool {
+	return m.cfg.DeadAfter > 0 && !m.alive[ip] && m.silent[ip] >= m.cfg.DeadAfter
+}
+
+// noteLiveness records whether a port of ip answered (up) or went
+// unanswered (silent), for DeadAfter. Once a port answered, the address
+// is up for good.
+func (m *Manager) noteLiveness(ip string, up, silent bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.alive == nil {
+		m.alive = make(map[string]bool)
+		m.silent = make(map[string]int)
+	}
+	switch {
+	case m.alive[ip]:
+	case up:
+		m.alive[ip] = true
+	case silent:
+		m.silent[ip]++
+		if m.silent[ip] == m.cfg.DeadAfter && m.cfg.Verbose {
+			logging.Verbosef("%s: first %d ports unanswered, host looks down", ip, m.silent[ip])
+		}
+	}
+}
+
+// liveness tells whether res shows its host is up (the port answered)
+// and, failing that, whether it went unanswered: a TCP connect or SYN
+// that timed out. Anything else, UDP silence included, proves nothing.
+func liveness(res port.PortResult) (up, silent bool) {
+	switch res.State {
+	case "open", "closed":
+		return true, false
+	case "filtered":
+		switch port.ScanType(res.Proto) {
+		case port.ScanTCP:
+			return false, res.Error == "timeout"
+		case port.ScanStealth:
+			return false, res.Error == ""
+		}
+	}
+	return false, false
+}
+
+// countOpen records that job found its port open and caps the address
+// when that reaches its open-port limit.
+func (m *Manager) countOpen(job port.PortJob) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.open == nil {
+		m.open = make(map[string]int)
+		m.capped = make(map[string]bool)
+	}
+	m.open[job.IP]++
+	if m.open[job.IP] == m.openLimit() {
+		m.capped[job.IP] = true
+		if m.cfg.Verbose {
+			logging.Verbosef("%s: %d ports open, skipping its remaining ports", job.IP, m.open[job.IP])
+		}
+	}
+}
+
+// markCancelled records that a job was cut short, so the report is partial.
+func (m *Manager) markCancelled() {
+	m.mu.Lock()
+	m.cancelled = true
+	m.mu.Unlock()
+}
+
+// waitRunnable blocks while the scan is paused. It returns false when the
+// scan was stopped or ctx cancelled.
+func (m *Manager) waitRunnable(ctx context.Context) bool {
+	for {
+		m.mu.Lock()
+		resume := m.resume
+		m.mu.Unlock()
+		if resume == nil {
+			select {
+			case <-m.stop:
+				return false
+			case <-ctx.Done():
+				return false
+			default:
+				return true
+			}
+		}
+		select {
+		case <-resume:
+		case <-m.stop:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// portScanTypes returns the per-port scan types in execution order.
+func (m *Manager) portScanTypes() []port.ScanType {
+	scanTypes := make([]port.ScanType, 0, 3)
+	if m.cfg.ScanStealth {
+		scanTypes = append(scanTypes, port.ScanStealth)
+	}
+	if m.cfg.ScanTCP {
+		scanTypes = append(scanTypes, port.ScanTCP)
+	}
+	if m.cfg.ScanUDP {
+		scanTypes = append(scanTypes, port.ScanUDP)
+	}
+	for _, st := range m.cfg.ScanTypes {
+		if !containsType(scanTypes, st) {
+			scanTypes = append(scanTypes, st)
+		}
+	}
+	if len(scanTypes) == 0 {
+		scanTypes = append(scanTypes, m.cfg.DefaultScanTypes...)
+	}
+	if len(scanTypes) == 0 {
+		scanTypes = append(scanTypes, port.ScanTCP)
+	}
+	return scanTypes
+}
+
+func containsType(types []port.ScanType, st port.ScanType) bool {
+	for _, t := range types {
+		if t == st {
+			return true
+		}
+	}
+	return false
+}
+
+// pingOnly reports whether only the ping probe runs: ping was requested
+// with no port scan types and no ports (ports alone imply the default
+// scan types).
+func (m *Manager) pingOnly() bool {
+	return m.cfg.ScanPing && !m.cfg.ScanTCP && !m.cfg.ScanUDP && !m.cfg.ScanStealth && len(m.cfg.ScanTypes) == 0 && len(m.cfg.Ports) == 0
+}
+
+// buildJobs creates the job list per address: one ping job (Port 0) when
+// requested, followed by one job per port carrying the port scan types.
+// Targets resolving to an address already listed become aliases of the
+// first target instead of being scanned again.
+func (m *Manager) buildJobs() []port.PortJob {
+	var jobs []port.PortJob
+	scanTypes := m.portScanTypes()
+	ports := m.portOrder()
+	for _, t := range m.dedupTargets() {
+		if m.cfg.ScanPing && !m.pingGated() {
+			jobs = append(jobs, port.PortJob{
+				Target:    t.Name,
+				IP:        t.IP,
+				ScanTypes: []port.ScanType{port.ScanPing},
+				Aliases:   t.aliases,
+			})
+		}
+		if m.pingOnly() {
+			continue
+		}
+		targetPorts := ports
+		if len(t.Ports) > 0 {
+			targetPorts = t.Ports
+		}
+		for _, p := range targetPorts {
+			jobs = append(jobs, port.PortJob{
+				Target:    t.Name,
+				IP:        t.IP,
+				Port:      p,
+				ScanTypes: scanTypes,
+				Aliases:   t.aliases,
+			})
+		}
+	}
+	return jobs
+}
+
+// portOrder returns the ports in the order each address is scanned.
+func (m *Manager) portOrder() []uint16 {
+	if !m.cfg.LikelyFirst {
+		return m.cfg.Ports
+	}
+	return port.LikelyFirst(m.cfg.Ports, m.cfg.ScanUDP && !m.cfg.ScanTCP && !m.cfg.ScanStealth)
+}
+
+type dedupTarget struct {
+	port.Target
+	aliases []string
+}
+
+// dedupTargets returns one entry per distinct address (and port list),
+// in target order, with the names of later targets sharing that address
+// as aliases.
+func (m *Manager) dedupTargets() []dedupTarget {
+	var out []dedupTarget
+	byIP := make(map[string]int)
+	for _, t := range m.cfg.ScanTargets() {
+		key := t.IP
+		if len(t.Ports) > 0 {
+			key += fmt.Sprint(t.Ports)
+		}
+		i, ok := byIP[key]
+		if !ok {
+			byIP[key] = len(out)
+			out = append(out, dedupTarget{Target: t})
+			continue
+		}
+		d := &out[i]
+		if t.Name == d.Name || contains(d.aliases, t.Name) {
+			continue
+		}
+		d.aliases = append(d.aliases, t.Name)
+		if m.cfg.Verbose {
+			logging.Verbosef("%s resolves to %s like %s; scanning it once", t.Name, t.IP, d.Name)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// runJob executes the job's scan types sequentially, sending each result.
+// It returns false when the context was cancelled.
+func (m *Manager) runJob(ctx context.Context, job port.PortJob, out chan<- port.PortResult) bool {
+	if m.skipHost(job) {
+		if m.cfg.OnHostComplete != nil {
+			m.jobDone(job)
+		}
+		return true
+	}
+	open, up, silent := false, false, false
+	for _, st := range job.ScanTypes {
+		select {
+		case <-ctx.Done():
+			return false
+		default:
+		}
+		if !m.jitterWait(ctx, job.IP) || !m.bandwidthWait(ctx, job.IP, probeBytes(st, job.IP, job.Port, m.cfg.DNSQuery)) {
+			m.markCancelled()
+			return false
+		}
+		res := m.scanOne(ctx, job, st)
+		m.stats.Record(res)
+		open = open || res.State == "open"
+		if u, s := liveness(res); u {
+			up = true
+		} else if s {
+			silent = true
+		}
+		if !m.deliver(ctx, out, res) {
+			return false
+		}
+		// attribute the same probe to every name sharing the address
+		for _, alias := range job.Aliases {
+			dup := res
+			dup.Target = alias
+			if !m.deliver(ctx, out, dup) {
+				return false
+			}
+		}
+	}
+	if open && m.openLimit() > 0 && job.Port != 0 {
+		m.countOpen(job)
+	}
+	if m.cfg.DeadAfter > 0 && job.Port != 0 {
+		m.noteLiveness(job.IP, up, silent)
+	}
+	if m.cfg.OnHostComplete != nil {
+		m.jobDone(job)
+	}
+	return true
+}
+
+// jobDone reports the job's hosts to OnHostComplete once none of their
+// jobs remain.
+func (m *Manager) jobDone(job port.PortJob) {
+	m.hookMu.Lock()
+	defer m.hookMu.Unlock()
+	for _, name := range append([]string{job.Target}, job.Aliases...) {
+		m.pending[name]--
+		if m.pending[name] > 0 {
+			continue
+		}
+		var targets []port.Target
+		for _, t := range m.cfg.ScanTargets() {
+			if t.Name == name {
+				targets = append(targets, t)
+			}
+		}
+		rep := report.Build(report.Meta{}, targets, m.byTarget[name])
+		m.markHosts(rep.Hosts)
+		delete(m.byTarget, name)
+		if len(rep.Hosts) > 0 {
+			m.cfg.OnHostComplete(rep.Hosts[0])
+		}
+	}
+}
+
+// resultBuffer returns the capacity of the results channel.
+func (m *Manager) resultBuffer() int {
+	if m.cfg.ResultBuffer > 0 {
+		return m.cfg.ResultBuffer
+	}
+	return DefaultResultBuffer
+}
+
+// deliver sends res, keeps it for Report unless DropResults is set, and
+// passes it to OnResult. It returns false when the context was
+// cancelled. When the channel is full it waits for the consumer, unless
+// DropResults is set or the scan was stopped (Stop must not depend on
+// anyone draining the channel); results it does not send are counted as
+// dropped.
+func (m *Manager) deliver(ctx context.Context, out chan<- port.PortResult, res port.PortResult) bool {
+	select {
+	case out <- res:
+	default:
+		if m.cfg.DropResults {
+			m.stats.Drop()
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case out <- res:
+		case <-m.stop:
+			m.stats.Drop()
+		}
+	}
+	if !m.cfg.DropResults {
+		m.mu.Lock()
+		m.results = append(m.results, res)
+		m.mu.Unlock()
+	}
+	if m.cfg.OnResult != nil || m.cfg.OnHostComplete != nil {
+		m.hookMu.Lock()
+		if m.byTarget != nil {
+			m.byTarget[res.Target] = append(m.byTarget[res.Target], res)
+		}
+		if m.cfg.OnResult != nil {
+			m.cfg.OnResult(res)
+		}
+		m.hookMu.Unlock()
+	}
+	return true
+}
+
+// reportTelemetry periodically prints worker utilisation, queue depth and
+// probe/error/timeout rates until done is closed. A high timeout rate with
+// all workers busy points at a timeout-bound scan; idle workers with a
+// draining queue point at the network.
+func (m *Manager) reportTelemetry(done <-chan struct{}, queue chan port.PortJob, workers int) {
+	interval := m.cfg.TelemetryInterval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	prev := m.stats.Snapshot()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			cur := m.stats.Snapshot()
+			r := cur.RatesSince(prev)
+			logging.Verbosef("telemetry: active=%d/%d queue=%d probes/s=%.1f errors/s=%.1f timeouts/s=%.1f done=%d dropped=%d\n",
+				cur.Active, workers, len(queue), r.Probes, r.Errors, r.Timeouts, cur.Probes, cur.Dropped)
+			prev = cur
+		}
+	}
+}
+
+// reportProgress writes a progress event every ProgressInterval until
+// done is closed.
+func (m *Manager) reportProgress(done <-chan struct{}) {
+	interval := m.cfg.ProgressInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			m.writeProgress(false)
+		}
+	}
+}
+
+// writeProgress writes the current progress as one JSON line; nothing is
+// written after the final event. Progress is advisory, so write errors
+// (e.g. a closed pipe) are ignored.
+func (m *Manager) writeProgress(final bool) {
+	m.progressMu.Lock()
+	defer m.progressMu.Unlock()
+	if m.progressDone {
+		return
+	}
+	m.progressDone = final
+	line, err := json.Marshal(m.stats.Snapshot().Progress())
+	if err != nil {
+		return
+	}
+	_, _ = m.cfg.Progress.Write(append(line, '\n'))
+}
+
+// scanOne runs a single scan type for a job and, when the port is open,
+// applies the opt-in service and OS detectors (service detection first).
+func (m *Manager) scanOne(ctx context.Context, job port.PortJob, st port.ScanType) port.PortResult {
+	if m.cfg.Verbose {
+		logging.Verbosef("worker: scanning %s %s:%d\n", st, job.IP, job.Port)
+	}
+	var res port.PortResult
+	start := time.Now()
+	switch st {
+	case port.ScanTCP:
+		res = tcpScan(ctx, m.cfg.Dialer, job.IP, job.Port, m.cfg.TCPTimeout, m.cfg.BannerLimit, m.cfg.RSTClose, m.cfg.Verbose)
+	case port.ScanUDP:
+		var keep func(net.Conn, uint16, time.Time)
+		if m.cfg.UDPGrace > 0 {
+			keep = m.keepUDP(job.IP, job.Port)
+		}
+		res = udpScan(ctx, m.cfg.PacketDialer, job.IP, job.Port, m.cfg.DNSQuery, m.cfg.UDPTimeout, m.cfg.Verbose, keep)
+	case port.ScanStealth:
+		res = m.stealthScan(ctx, job.IP, job.Port)
+	case port.ScanPing:
+		res = PingScanVia(ctx, m.cfg.PacketDialer, m.cfg.PacketListener, job.IP, m.cfg.TCPTimeout, m.cfg.Verbose)
+	default:
+		// Run accepts no other type unless it was registered.
+		res = m.customScan(ctx, job, st)
+	}
+	// attach original target string from job
+	res.Target = job.Target
+	res.Timestamp = time.Now().UTC()
+	if m.cfg.Audit != nil {
+		m.cfg.Audit.Probe(start, res)
+	}
+	if res.State != "open" {
+		if m.cfg.ServiceDetect {
+			port.GuessService(&res) // open|filtered UDP
+		}
+		return res
+	}
+
+	if m.cfg.ServiceDetect {
+		budget := m.detectBudget(st)
+		dctx, cancel := context.WithTimeout(ctx, budget)
+		res = detector.DetectService(dctx, m.HostDetectorConfig(st, job.IP), res)
+		if m.cfg.Verbose && errors.Is(dctx.Err(), context.DeadlineExceeded) {
+			logging.Verbosef("detection budget %v exhausted for %s:%d", budget, job.IP, job.Port)
+		}
+		cancel()
+		// Name open ports nothing identified after their well-known service.
+		port.GuessService(&res)
+	}
+	if m.cfg.OSDetect {
+		if osGuess, osConf := detector.DetectOSForResult(res); osGuess != "" {
+			res.OSGuess, res.OSConfidence = osGuess, osConf
+		}
+	}
+	return res
+}
+
+// detectBudget returns the total detection time allowed per open port.
+func (m *Manager) detectBudget(st port.ScanType) time.Duration {
+	if m.cfg.DetectTimeout > 0 {
+		return m.cfg.DetectTimeout
+	}
+	if t := m.cfg.TimeoutFor(st); t > 0 {
+		return 3 * t
+	}
+	return 3 * time.Second
+}
+
+// DetectorConfig builds the detector configuration for results of scan type st.
+func (m *Manager) DetectorConfig(st port.ScanType) detector.Config {
+	return detector.Config{
+		ServiceDetect: m.cfg.ServiceDetect,
+		Timeout:       m.cfg.TimeoutFor(st),
+		Verbose:       m.cfg.Verbose,
+		SNI:           m.cfg.TLSServerName,
+		Insecure:      m.cfg.TLSInsecure,
+		HTTPCapture:   m.cfg.HTTPCapture,
+		TLSCiphers:    m.cfg.TLSCiphers,
+		TLSALPN:       m.cfg.TLSALPN,
+		Dialer:        m.cfg.Dialer,
+		Fingerprints:  m.cfg.Fingerprints,
+		HexDump:       m.cfg.HexDump,
+		Audit:         m.cfg.Audit,
+		BannerLimit:   m.cfg.BannerLimit,
+		Pool:          m.pool,
+	}
+}
+
+// CloseIdle closes the detection connections kept open for reuse. Run
+// does this when its workers finish; call it again after detection run
+// outside the scan, e.g. with HostDetectorConfig.
+func (m *Manager) CloseIdle() {
+	m.pool.CloseIdle()
+}
+
+// HostDetectorConfig is DetectorConfig for detection against ip: its
+// connections draw on the host's DetectBytes budget.
+func (m *Manager) HostDetectorConfig(st port.ScanType, ip string) detector.Config {
+	cfg := m.DetectorConfig(st)
+	m.budgetMu.Lock()
+	b := m.budgets[ip]
+	if b == nil {
+		limit := m.cfg.DetectBytes
+		if limit <= 0 {
+			limit = DefaultDetectBytes
+		}
+		b = netutil.NewReadBudget(limit)
+		if m.budgets == nil {
+			m.budgets = make(map[string]*netutil.ReadBudget)
+		}
+		m.budgets[ip] = b
+	}
+	m.budgetMu.Unlock()
+	cfg.Dialer = b.Dialer(cfg.Dialer)
+	return cfg
+}
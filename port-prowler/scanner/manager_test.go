@@ -0,0 +1,824 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"portprowler/netutil"
+	"portprowler/port"
+	"portprowler/report"
+	"portprowler/stats"
+	"portprowler/testsupport"
+)
+
+func TestManager_DeduplicatesSharedAddress(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	portNum := uint16(l.Addr().(*net.TCPAddr).Port)
+
+	mgr := NewManager(Config{
+		Targets: []port.Target{
+			{Name: "a.example", IP: "127.0.0.1"},
+			{Name: "b.example", IP: "127.0.0.1"},
+			{Name: "a.example", IP: "127.0.0.1"},
+		},
+		Ports:      []uint16{portNum},
+		Workers:    2,
+		TCPTimeout: time.Second,
+	})
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	byName := make(map[string]port.PortResult)
+	for r := range out {
+		if _, dup := byName[r.Target]; dup {
+			t.Fatalf("duplicate result for %s", r.Target)
+		}
+		byName[r.Target] = r
+	}
+	if len(byName) != 2 || byName["a.example"].State != "open" || byName["b.example"].State != "open" {
+		t.Fatalf("results = %+v, want one open result per name", byName)
+	}
+	if s := mgr.Stats(); s.Probes != 1 {
+		t.Fatalf("probes = %d, want the shared address probed once", s.Probes)
+	}
+}
+
+func TestManager_TargetPorts(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	portNum := uint16(l.Addr().(*net.TCPAddr).Port)
+
+	mgr := NewManager(Config{
+		Targets: []port.Target{
+			{Name: "127.0.0.1", IP: "127.0.0.1", Ports: []uint16{portNum}},
+			{Name: "all.example", IP: "127.0.0.1"},
+		},
+		Ports:      []uint16{portNum, 1},
+		Workers:    2,
+		TCPTimeout: time.Second,
+	})
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	count := make(map[string]int)
+	for r := range out {
+		count[r.Target]++
+	}
+	if count["127.0.0.1"] != 1 || count["all.example"] != 2 {
+		t.Fatalf("results per target = %v, want 1 for the target naming its port and 2 for the other", count)
+	}
+}
+
+func TestManager_Progress(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	portNum := uint16(l.Addr().(*net.TCPAddr).Port)
+
+	var buf bytes.Buffer
+	mgr := NewManager(Config{
+		Targets:          []port.Target{{Name: "a.example", IP: "127.0.0.1"}},
+		Ports:            []uint16{portNum, portNum},
+		ScanTCP:          true,
+		Workers:          1,
+		TCPTimeout:       time.Second,
+		Progress:         &buf,
+		ProgressInterval: time.Millisecond,
+	})
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for range out {
+	}
+	var events []stats.Progress
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var p stats.Progress
+		if err := json.Unmarshal(sc.Bytes(), &p); err != nil {
+			t.Fatalf("bad progress line %q: %v", sc.Text(), err)
+		}
+		events = append(events, p)
+	}
+	if len(events) == 0 {
+		t.Fatal("no progress events")
+	}
+	last := events[len(events)-1]
+	if !last.Done || last.Completed != 2 || last.Total != 2 || last.Open != 2 || last.Percent != 100 {
+		t.Fatalf("final event = %+v", last)
+	}
+	for _, p := range events[:len(events)-1] {
+		if p.Done {
+			t.Fatalf("done event before the last: %+v", events)
+		}
+	}
+}
+
+func TestManager_PauseResumeStop(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	portNum := uint16(l.Addr().(*net.TCPAddr).Port)
+	cfg := Config{
+		Targets:    []port.Target{{Name: "a.example", IP: "127.0.0.1"}},
+		Ports:      []uint16{portNum, portNum, portNum},
+		ScanTCP:    true,
+		Workers:    1,
+		TCPTimeout: time.Second,
+	}
+
+	// Paused before it starts, the scan runs nothing until resumed.
+	mgr := NewManager(cfg)
+	mgr.Pause()
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := mgr.Stats().Probes; n != 0 || !mgr.Paused() {
+		t.Fatalf("paused scan ran %d probes", n)
+	}
+	mgr.Resume()
+	n := 0
+	for range out {
+		n++
+	}
+	if rep := mgr.Report(report.Meta{Note: "x"}); n != 3 || rep.Meta.Status != report.StatusComplete || rep.Meta.Note != "x" || len(rep.Hosts[0].Results) != 3 {
+		t.Fatalf("resumed scan: %d results, report %+v", n, rep.Meta)
+	}
+	if rep := mgr.Stop(); rep.Meta.Status != report.StatusComplete {
+		t.Fatalf("stop after completion = %q", rep.Meta.Status)
+	}
+	if sum, err := mgr.Wait(context.Background()); err != nil || sum.Outcome != OutcomeCompleted || sum.Stats.Probes != 3 {
+		t.Fatalf("wait after completion = %+v, %v", sum, err)
+	}
+
+	// Stopping a paused scan drains it with no further probes.
+	mgr = NewManager(cfg)
+	mgr.Pause()
+	out, err = mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	rep := mgr.Stop()
+	if rep.Meta.Status != report.StatusCancelled || len(rep.Hosts) != 1 || len(rep.Hosts[0].Results) != 0 {
+		t.Fatalf("stopped report = %+v", rep)
+	}
+	if _, open := <-out; open {
+		t.Fatal("results channel still open after Stop")
+	}
+	if sum, err := mgr.Wait(context.Background()); !errors.Is(err, ErrStopped) || sum.Outcome != OutcomeCancelled || sum.Err != err {
+		t.Fatalf("wait after stop = %+v, %v", sum, err)
+	}
+	if _, err := NewManager(cfg).Wait(context.Background()); !errors.Is(err, ErrNotStarted) {
+		t.Fatalf("wait before run = %v", err)
+	}
+}
+
+func TestManager_CancelUnwinds(t *testing.T) {
+	// Far more jobs than the queue holds, each slow enough that
+	// cancellation lands while the dispatcher is still enqueuing.
+	fake := testsupport.NewNetwork()
+	fake.AddHost("192.0.2.1").Latency = 20 * time.Millisecond
+	ports := make([]uint16, 500)
+	for i := range ports {
+		ports[i] = uint16(i + 1)
+	}
+	mgr := NewManager(Config{
+		Targets:    []port.Target{{Name: "slow.example", IP: "192.0.2.1"}},
+		Ports:      ports,
+		ScanTCP:    true,
+		Workers:    2,
+		TCPTimeout: time.Second,
+		Dialer:     fake,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	out, err := mgr.Run(ctx)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	<-out
+	cancel()
+
+	waited := make(chan error, 1)
+	go func() {
+		_, err := mgr.Wait(context.Background())
+		waited <- err
+	}()
+	select {
+	case err := <-waited:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("wait = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan did not unwind after cancellation")
+	}
+	n := 1
+	for range out {
+		n++
+	}
+	if n >= len(ports) || mgr.Report(report.Meta{}).Meta.Status != report.StatusCancelled {
+		t.Fatalf("%d results after cancelling, status %s", n, mgr.Report(report.Meta{}).Meta.Status)
+	}
+}
+
+func TestManager_ResultBackpressure(t *testing.T) {
+	fake := testsupport.NewNetwork()
+	fake.AddHost("192.0.2.1")
+	ports := make([]uint16, 50)
+	for i := range ports {
+		ports[i] = uint16(i + 1)
+	}
+	cfg := Config{
+		Targets:      []port.Target{{Name: "a.example", IP: "192.0.2.1"}},
+		Ports:        ports,
+		ScanTCP:      true,
+		Workers:      2,
+		TCPTimeout:   time.Second,
+		Dialer:       fake,
+		ResultBuffer: 4,
+	}
+
+	// Nobody reads: workers wait once the buffer is full.
+	mgr := NewManager(cfg)
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := mgr.Stats().Probes; n > cfg.ResultBuffer+cfg.Workers {
+		t.Fatalf("%d probes ran with no consumer", n)
+	}
+	n := 0
+	for range out {
+		n++
+	}
+	if s := mgr.Stats(); n != len(ports) || s.Dropped != 0 {
+		t.Fatalf("%d results, %d dropped", n, s.Dropped)
+	}
+
+	// Stop does not need a consumer either.
+	mgr = NewManager(cfg)
+	if _, err := mgr.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	stopped := make(chan report.ScanReport, 1)
+	go func() { stopped <- mgr.Stop() }()
+	select {
+	case rep := <-stopped:
+		if len(rep.Hosts[0].Results) != mgr.Stats().Probes {
+			t.Fatalf("stopped report has %d of %d results", len(rep.Hosts[0].Results), mgr.Stats().Probes)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop blocked on a full results channel")
+	}
+
+	// With DropResults the scan runs to the end, the consumer misses what
+	// did not fit, and the manager keeps nothing.
+	cfg.DropResults = true
+	mgr = NewManager(cfg)
+	out, err = mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if sum, err := mgr.Wait(context.Background()); err != nil || sum.Outcome != OutcomeCompleted {
+		t.Fatalf("wait = %+v, %v", sum, err)
+	}
+	n = 0
+	for range out {
+		n++
+	}
+	s := mgr.Stats()
+	if n != cfg.ResultBuffer || s.Dropped != len(ports)-n || len(mgr.Report(report.Meta{}).Hosts[0].Results) != 0 {
+		t.Fatalf("%d results sent, %d dropped", n, s.Dropped)
+	}
+}
+
+func TestManager_Hooks(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	portNum := uint16(l.Addr().(*net.TCPAddr).Port)
+
+	var seen []port.PortResult
+	hosts := make(map[string]report.HostReport)
+	mgr := NewManager(Config{
+		Targets: []port.Target{
+			{Name: "a.example", IP: "127.0.0.1"},
+			{Name: "b.example", IP: "127.0.0.1"},
+			{Name: "c.example", IP: "127.0.0.2"},
+		},
+		Ports:      []uint16{portNum, portNum + 1},
+		ScanTCP:    true,
+		Workers:    4,
+		TCPTimeout: time.Second,
+		OnResult:   func(r port.PortResult) { seen = append(seen, r) },
+		OnHostComplete: func(h report.HostReport) {
+			if _, dup := hosts[h.Target]; dup {
+				t.Errorf("host %s completed twice", h.Target)
+			}
+			hosts[h.Target] = h
+		},
+	})
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	n := 0
+	for range out {
+		n++
+	}
+	if len(seen) != n || n != 6 {
+		t.Fatalf("OnResult saw %d of %d results", len(seen), n)
+	}
+	if len(hosts) != 3 {
+		t.Fatalf("completed hosts = %v", hosts)
+	}
+	for name, h := range hosts {
+		if len(h.Results) != 2 || h.Results[0].Target != name {
+			t.Fatalf("host %s report = %+v", name, h)
+		}
+	}
+	if h := hosts["c.example"]; h.IP != "127.0.0.2" || len(h.Addrs) != 1 {
+		t.Fatalf("c.example = %+v", h)
+	}
+}
+
+func TestManager_InjectedTransport(t *testing.T) {
+	fake := testsupport.NewNetwork()
+	fake.AddHost("192.0.2.1").
+		TCP(22, testsupport.Port{Banner: "SSH-2.0-fake\r\n"}).
+		UDP(161, testsupport.Port{Reply: "pong"})
+	mgr := NewManager(Config{
+		Targets:      []port.Target{{Name: "fake.example", IP: "192.0.2.1"}},
+		Ports:        []uint16{22, 23, 161},
+		ScanTCP:      true,
+		ScanUDP:      true,
+		ScanPing:     true,
+		Workers:      2,
+		TCPTimeout:   time.Second,
+		UDPTimeout:   time.Second,
+		Dialer:       fake,
+		PacketDialer: fake,
+	})
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got := make(map[string]port.PortResult)
+	for r := range out {
+		got[fmt.Sprintf("%s/%d", r.Proto, r.Port)] = r
+	}
+	want := map[string]string{
+		"tcp/22": "open", "tcp/23": "closed", "tcp/161": "closed",
+		"udp/22": "closed", "udp/23": "closed", "udp/161": "open",
+		"ping/0": "up",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("results = %+v", got)
+	}
+	for k, state := range want {
+		if got[k].State != state {
+			t.Errorf("%s = %s (%s), want %s", k, got[k].State, got[k].Error, state)
+		}
+	}
+	if b := got["tcp/22"].ServiceBanner; b != "SSH-2.0-fake" {
+		t.Errorf("banner = %q", b)
+	}
+	if s := got["ping/0"].Service; s != "udp-ping" {
+		t.Errorf("ping method = %q, want udp-ping over the injected dialer", s)
+	}
+}
+
+func TestManager_GuessService(t *testing.T) {
+	fake := testsupport.NewNetwork()
+	fake.AddHost("192.0.2.1").TCP(3306, testsupport.Port{})
+	for _, detect := range []bool{false, true} {
+		mgr := NewManager(Config{
+			Targets:       []port.Target{{Name: "db.example", IP: "192.0.2.1"}},
+			Ports:         []uint16{3306},
+			ScanTCP:       true,
+			ServiceDetect: detect,
+			Workers:       1,
+			TCPTimeout:    time.Second,
+			DetectTimeout: 100 * time.Millisecond,
+			Dialer:        fake,
+		})
+		out, err := mgr.Run(context.Background())
+		if err != nil {
+			t.Fatalf("run: %v", err)
+		}
+		var got []port.PortResult
+		for r := range out {
+			got = append(got, r)
+		}
+		want := port.PortResult{}
+		if detect {
+			want = port.PortResult{Service: "mysql", ServiceConfidence: port.ConfidenceLow}
+		}
+		if len(got) != 1 || got[0].Service != want.Service || got[0].ServiceConfidence != want.ServiceConfidence {
+			t.Errorf("service detection %v: streamed %+v, want service %q at %q confidence", detect, got, want.Service, want.ServiceConfidence)
+		}
+	}
+}
+
+func TestManager_ReadLimits(t *testing.T) {
+	fake := testsupport.NewNetwork()
+	fake.AddHost("192.0.2.1").TCP(9000, testsupport.Port{Banner: strings.Repeat("A", 100)})
+	mgr := NewManager(Config{
+		Targets:     []port.Target{{Name: "fake.example", IP: "192.0.2.1"}},
+		Ports:       []uint16{9000},
+		ScanTCP:     true,
+		Workers:     1,
+		TCPTimeout:  time.Second,
+		Dialer:      fake,
+		BannerLimit: 16,
+		DetectBytes: 150,
+	})
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for r := range out {
+		if len(r.BannerRaw) != 16 || r.ServiceBanner != strings.Repeat("A", 16) {
+			t.Fatalf("banner = %q (%d raw bytes), want 16 bytes", r.ServiceBanner, len(r.BannerRaw))
+		}
+	}
+
+	// Detection connections to one host share its budget.
+	var total int64
+	for i := 0; i < 2; i++ {
+		cfg := mgr.HostDetectorConfig(port.ScanTCP, "192.0.2.1")
+		c, err := cfg.Dialer.DialContext(context.Background(), "tcp", "192.0.2.1:9000")
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		c.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, err := io.Copy(io.Discard, c)
+		c.Close()
+		total += n
+		if i == 1 && !errors.Is(err, netutil.ErrReadBudget) {
+			t.Errorf("second connection: err = %v, want ErrReadBudget", err)
+		}
+	}
+	if total != 150 {
+		t.Errorf("detection read %d bytes, want the 150 byte budget", total)
+	}
+}
+
+func TestManager_MaxOpenPerHost(t *testing.T) {
+	fake := testsupport.NewNetwork()
+	greet := testsupport.Port{Banner: "hello\r\n"} // no wait for a banner
+	everything := fake.AddHost("192.0.2.1")
+	normal := fake.AddHost("192.0.2.2").TCP(22, greet)
+	var ports []uint16
+	for p := uint16(20); p < 40; p++ {
+		ports = append(ports, p)
+		everything.TCP(p, greet)
+	}
+	normal.TCP(25, greet)
+	mgr := NewManager(Config{
+		Targets: []port.Target{
+			{Name: "middlebox.example", IP: "192.0.2.1"},
+			{Name: "normal.example", IP: "192.0.2.2"},
+		},
+		Ports:          ports,
+		ScanTCP:        true,
+		Workers:        1,
+		TCPTimeout:     time.Second,
+		Dialer:         fake,
+		MaxOpenPerHost: 5,
+	})
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for range out {
+	}
+	rep := mgr.Report(report.Meta{})
+	if len(rep.Hosts) != 2 {
+		t.Fatalf("hosts = %+v", rep.Hosts)
+	}
+	if h := rep.Hosts[0]; !h.Capped || len(h.Results) != 5 {
+		t.Errorf("%s: capped=%v with %d results, want capped after 5", h.Target, h.Capped, len(h.Results))
+	}
+	if h := rep.Hosts[1]; h.Capped || len(h.Results) != len(ports) {
+		t.Errorf("%s: capped=%v with %d results, want all %d", h.Target, h.Capped, len(h.Results), len(ports))
+	}
+	if rep.Meta.Status != report.StatusComplete {
+		t.Errorf("status = %s", rep.Meta.Status)
+	}
+	if p := mgr.Stats().Progress(); p.Completed != p.Total {
+		t.Errorf("progress %d/%d after skipping", p.Completed, p.Total)
+	}
+}
+
+func TestManager_DeadHosts(t *testing.T) {
+	fake := testsupport.NewNetwork()
+	fake.AddHost("192.0.2.1").Down = true
+	late := fake.AddHost("192.0.2.2")
+	var ports []uint16
+	for p := uint16(1); p <= 10; p++ {
+		ports = append(ports, p)
+		late.TCP(p, testsupport.Port{State: testsupport.Filtered})
+	}
+	late.TCP(10, testsupport.Port{State: testsupport.Closed})
+	run := func(skip bool) report.ScanReport {
+		mgr := NewManager(Config{
+			Targets: []port.Target{
+				{Name: "down.example", IP: "192.0.2.1"},
+				{Name: "late.example", IP: "192.0.2.2"},
+			},
+			Ports:      ports,
+			ScanTCP:    true,
+			Workers:    1,
+			TCPTimeout: 10 * time.Millisecond,
+			Dialer:     fake,
+			DeadAfter:  3,
+			SkipDead:   skip,
+		})
+		out, err := mgr.Run(context.Background())
+		if err != nil {
+			t.Fatalf("run: %v", err)
+		}
+		for range out {
+		}
+		if p := mgr.Stats().Progress(); p.Completed != p.Total {
+			t.Errorf("progress %d/%d", p.Completed, p.Total)
+		}
+		return mgr.Report(report.Meta{})
+	}
+
+	// Without skipping every port is scanned; a port answering last
+	// keeps the host up.
+	rep := run(false)
+	if h := rep.Hosts[0]; !h.Down || len(h.Results) != len(ports) {
+		t.Errorf("%s: down=%v with %d results", h.Target, h.Down, len(h.Results))
+	}
+	if h := rep.Hosts[1]; h.Down || len(h.Results) != len(ports) {
+		t.Errorf("%s: down=%v with %d results", h.Target, h.Down, len(h.Results))
+	}
+
+	// Skipping stops at the third timeout, also on the host that would
+	// have answered later.
+	rep = run(true)
+	for _, h := range rep.Hosts {
+		if !h.Down || len(h.Results) != 3 {
+			t.Errorf("%s: down=%v with %d results, want down after 3", h.Target, h.Down, len(h.Results))
+		}
+	}
+	if rep.Meta.Status != report.StatusComplete {
+		t.Errorf("status = %s", rep.Meta.Status)
+	}
+}
+
+func TestManager_PingGate(t *testing.T) {
+	fake := testsupport.NewNetwork()
+	fake.AddHost("192.0.2.1").TCP(22, testsupport.Port{})
+	fake.AddHost("192.0.2.2").Down = true
+	// Drops the UDP ping but refuses connections: up by TCP ping.
+	fake.AddHost("192.0.2.3").UDP(testsupport.PingPort, testsupport.Port{State: testsupport.Filtered})
+	ports := []uint16{21, 22, 23}
+	run := func(ping bool) (map[string][]port.PortResult, stats.Snapshot) {
+		mgr := NewManager(Config{
+			Targets: []port.Target{
+				{Name: "up.example", IP: "192.0.2.1"},
+				{Name: "down.example", IP: "192.0.2.2"},
+				{Name: "firewalled.example", IP: "192.0.2.3"},
+			},
+			Ports:           ports,
+			ScanTCP:         true,
+			ScanPing:        ping,
+			Workers:         2,
+			TCPTimeout:      time.Second,
+			UDPTimeout:      time.Second,
+			PingGate:        true,
+			PingGateTimeout: 20 * time.Millisecond,
+			Dialer:          fake,
+			PacketDialer:    fake,
+		})
+		out, err := mgr.Run(context.Background())
+		if err != nil {
+			t.Fatalf("run: %v", err)
+		}
+		got := map[string][]port.PortResult{}
+		for r := range out {
+			got[r.Target] = append(got[r.Target], r)
+		}
+		return got, mgr.Stats()
+	}
+
+	got, s := run(false)
+	if d := got["down.example"]; len(d) != 1 || d[0].Proto != "ping" || d[0].State != "down" || d[0].Error != "host unreachable" {
+		t.Errorf("down host: %+v", d)
+	}
+	for _, name := range []string{"up.example", "firewalled.example"} {
+		if n := len(got[name]); n != len(ports) || got[name][0].Proto != "tcp" {
+			t.Errorf("%s: %+v", name, got[name])
+		}
+	}
+	if p := s.Progress(); p.Completed != p.Total || s.Probes != 3+2*len(ports) {
+		t.Errorf("progress %d/%d, %d probes", p.Completed, p.Total, s.Probes)
+	}
+	if n := fake.Dials("tcp", "192.0.2.2:22"); n != 0 {
+		t.Errorf("unreachable host dialled %d times", n)
+	}
+
+	// With ScanPing the gate's results are the ping results.
+	got, _ = run(true)
+	for name, method := range map[string]string{"up.example": "udp-ping", "firewalled.example": "tcp-ping"} {
+		if r := got[name]; len(r) != 1+len(ports) || r[0].Proto != "ping" || r[0].State != "up" || r[0].Service != method {
+			t.Errorf("%s: %+v", name, r)
+		}
+	}
+	if n := len(got["down.example"]); n != 1 {
+		t.Errorf("down host has %d results", n)
+	}
+}
+
+func TestManager_FirstOpen(t *testing.T) {
+	fake := testsupport.NewNetwork()
+	greet := testsupport.Port{Banner: "hello\r\n"}
+	fake.AddHost("192.0.2.1").TCP(22, greet).TCP(80, greet)
+	fake.AddHost("192.0.2.2")
+	ports := []uint16{21, 22, 23, 80, 443}
+	mgr := NewManager(Config{
+		Targets: []port.Target{
+			{Name: "up.example", IP: "192.0.2.1"},
+			{Name: "down.example", IP: "192.0.2.2"},
+		},
+		Ports:      ports,
+		ScanTCP:    true,
+		Workers:    1,
+		TCPTimeout: time.Second,
+		Dialer:     fake,
+		FirstOpen:  true,
+	})
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for range out {
+	}
+	rep := mgr.Report(report.Meta{})
+	if len(rep.Hosts) != 2 {
+		t.Fatalf("hosts = %+v", rep.Hosts)
+	}
+	if h := rep.Hosts[0]; h.Capped || len(h.Results) != 2 || h.Results[1].Port != 22 {
+		t.Errorf("%s: capped=%v with results %+v, want to stop after 22 without capping", h.Target, h.Capped, h.Results)
+	}
+	if h := rep.Hosts[1]; len(h.Results) != len(ports) {
+		t.Errorf("%s: %d results, want all %d", h.Target, len(h.Results), len(ports))
+	}
+	if p := mgr.Stats().Progress(); p.Completed != p.Total {
+		t.Errorf("progress %d/%d after skipping", p.Completed, p.Total)
+	}
+}
+
+func TestManager_DefaultScanTypes(t *testing.T) {
+	fake := testsupport.NewNetwork()
+	fake.AddHost("192.0.2.1").TCP(22, testsupport.Port{Banner: "SSH-2.0-fake\r\n"})
+	mgr := NewManager(Config{
+		Targets:          []port.Target{{Name: "fake.example", IP: "192.0.2.1"}},
+		Ports:            []uint16{22},
+		DefaultScanTypes: []port.ScanType{port.ScanTCP, port.ScanUDP},
+		Workers:          1,
+		TCPTimeout:       time.Second,
+		UDPTimeout:       time.Second,
+		Dialer:           fake,
+		PacketDialer:     fake,
+	})
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	var got []string
+	for r := range out {
+		got = append(got, r.Proto+"/"+r.State)
+	}
+	if strings.Join(got, " ") != "tcp/open udp/closed" {
+		t.Fatalf("results = %v, want tcp and udp", got)
+	}
+}
+
+func TestManager_LikelyFirst(t *testing.T) {
+	fake := testsupport.NewNetwork()
+	fake.AddHost("192.0.2.1").TCP(443, testsupport.Port{Banner: "x"})
+	ports, err := port.ParsePortSpec("400-450")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := NewManager(Config{
+		Targets:     []port.Target{{Name: "fake.example", IP: "192.0.2.1"}},
+		Ports:       ports,
+		ScanTCP:     true,
+		Workers:     1,
+		TCPTimeout:  time.Second,
+		Dialer:      fake,
+		LikelyFirst: true,
+	})
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	var got []uint16
+	for r := range out {
+		got = append(got, r.Port)
+	}
+	// 443, 445, 427 and 444 follow the top-ports table; the rest keep
+	// their order.
+	if len(got) != len(ports) || got[0] != 443 || got[1] != 445 || got[2] != 427 || got[3] != 444 || got[4] != 400 {
+		t.Fatalf("scan order = %v", got)
+	}
+}
+
+// unreachableDialer fails every dial to the ports in down with an error
+// that is not a port state, and passes the rest to next.
+type unreachableDialer struct {
+	next netutil.ContextDialer
+	down map[string]bool
+}
+
+func (d unreachableDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if _, p, _ := net.SplitHostPort(addr); d.down[p] {
+		return nil, errors.New("network is unreachable")
+	}
+	return d.next.DialContext(ctx, network, addr)
+}
+
+func TestManager_WaitOutcome(t *testing.T) {
+	fake := testsupport.NewNetwork()
+	fake.AddHost("192.0.2.1").TCP(22, testsupport.Port{})
+	run := func(down ...string) ScanSummary {
+		d := unreachableDialer{next: fake, down: map[string]bool{}}
+		for _, p := range down {
+			d.down[p] = true
+		}
+		mgr := NewManager(Config{
+			Targets:    []port.Target{{Name: "a.example", IP: "192.0.2.1"}},
+			Ports:      []uint16{22, 23},
+			ScanTCP:    true,
+			Workers:    2,
+			TCPTimeout: time.Second,
+			Dialer:     d,
+		})
+		if _, err := mgr.Run(context.Background()); err != nil {
+			t.Fatalf("run: %v", err)
+		}
+		sum, _ := mgr.Wait(context.Background())
+		return sum
+	}
+	for _, c := range []struct {
+		down []string
+		want string
+	}{
+		{nil, OutcomeCompleted},
+		{[]string{"23"}, OutcomePartial},
+		{[]string{"22", "23"}, OutcomeFailed},
+	} {
+		if sum := run(c.down...); sum.Outcome != c.want || sum.Err != nil {
+			t.Errorf("down %v: %+v, want %s", c.down, sum, c.want)
+		}
+	}
+
+	// Waiting can give up before the scan ends.
+	mgr := NewManager(Config{Targets: []port.Target{{Name: "a.example", IP: "192.0.2.1"}}, Ports: []uint16{22}, ScanTCP: true, Workers: 1, TCPTimeout: time.Second, Dialer: fake})
+	mgr.Pause()
+	if _, err := mgr.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := mgr.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait on a paused scan = %v", err)
+	}
+	mgr.Resume()
+	if sum, err := mgr.Wait(context.Background()); err != nil || sum.Outcome != OutcomeCompleted {
+		t.Fatalf("wait after resume = %+v, %v", sum, err)
+	}
+}
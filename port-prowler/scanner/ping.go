@@ -0,0 +1,199 @@
+package scanner
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"portprowler/logging"
+	"portprowler/netutil"
+	"portprowler/port"
+)
+
+// udpPingPort is the destination for the unprivileged UDP ping; like
+// traceroute's base port it is very unlikely to be open, so a live host
+// answers with ICMP port-unreachable.
+const udpPingPort = 33434
+
+// PingScan checks whether a host is up. With raw socket privileges it sends
+// an ICMP echo request; otherwise it falls back to a UDP ping where an ICMP
+// port-unreachable (or any reply) proves the host is up.
+// The result has Proto "ping", Port 0, State "up" or "down", and Service
+// naming the method used ("icmp-echo" or "udp-ping").
+func PingScan(ctx context.Context, ip string, timeout time.Duration, verbose bool) port.PortResult {
+	return PingScanVia(ctx, nil, nil, ip, timeout, verbose)
+}
+
+// PacketListener opens the packet socket ICMP echo uses (network
+// "ip4:icmp"). *net.ListenConfig satisfies it.
+type PacketListener interface {
+	ListenPacket(ctx context.Context, network, address string) (net.PacketConn, error)
+}
+
+// PingScanVia is PingScan with injected sockets: l opens the ICMP socket
+// and d the UDP ping socket. ICMP echo is tried when l is set, or when both
+// are nil and raw sockets are available, so a fake network given only d
+// never touches the real one.
+func PingScanVia(ctx context.Context, d netutil.ContextDialer, l PacketListener, ip string, timeout time.Duration, verbose bool) port.PortResult {
+	tryICMP := l != nil
+	if l == nil && d == nil {
+		if ok, _ := netutil.CanOpenRawSocket(); ok {
+			tryICMP = true
+			l = &net.ListenConfig{}
+		}
+	}
+	if tryICMP {
+		res, err := icmpEcho(ctx, l, ip, timeout)
+		if err == nil {
+			if verbose {
+				logging.Verbosef("ping icmp %s -> %s rtt=%dms\n", ip, res.State, res.RTTMillis)
+			}
+			return res
+		}
+		if verbose {
+			logging.Verbosef("ping icmp %s unavailable (%v); falling back to udp\n", ip, err)
+		}
+	}
+	res := udpPing(ctx, d, ip, timeout)
+	if verbose {
+		logging.Verbosef("ping udp %s -> %s rtt=%dms\n", ip, res.State, res.RTTMillis)
+	}
+	return res
+}
+
+func pingResult(ip, method string) port.PortResult {
+	return port.PortResult{
+		IP:      ip,
+		Proto:   string(port.ScanPing),
+		State:   "down",
+		Service: method,
+	}
+}
+
+// icmpEcho sends one ICMP echo request and waits for the matching reply.
+// An error means the probe could not be sent at all (not that the host is down).
+func icmpEcho(ctx context.Context, l PacketListener, ip string, timeout time.Duration) (port.PortResult, error) {
+	res := pingResult(ip, "icmp-echo")
+	dst := net.ParseIP(ip)
+	if dst == nil || dst.To4() == nil {
+		return res, errors.New("icmp echo requires an IPv4 address")
+	}
+	conn, err := l.ListenPacket(ctx, "ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return res, err
+	}
+	defer conn.Close()
+
+	var idb [4]byte
+	_, _ = rand.Read(idb[:])
+	id := binary.BigEndian.Uint16(idb[0:2])
+	seq := binary.BigEndian.Uint16(idb[2:4])
+	msg := buildEchoRequest(id, seq)
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+
+	start := time.Now()
+	if _, err := conn.WriteTo(msg, &net.IPAddr{IP: dst}); err != nil {
+		return res, err
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			// deadline: host did not answer
+			res.Error = "no echo reply"
+			return res, nil
+		}
+		if a, ok := from.(*net.IPAddr); !ok || !a.IP.Equal(dst) {
+			continue
+		}
+		if isEchoReply(buf[:n], id, seq) {
+			res.State = "up"
+			res.RTTMillis = time.Since(start).Milliseconds()
+			return res, nil
+		}
+	}
+}
+
+// buildEchoRequest returns an ICMP echo request with a small payload.
+func buildEchoRequest(id, seq uint16) []byte {
+	msg := make([]byte, 8+16)
+	msg[0] = 8 // echo request
+	binary.BigEndian.PutUint16(msg[4:6], id)
+	binary.BigEndian.PutUint16(msg[6:8], seq)
+	copy(msg[8:], "portprowler-ping")
+	binary.BigEndian.PutUint16(msg[2:4], icmpChecksum(msg))
+	return msg
+}
+
+// isEchoReply reports whether msg is an ICMP echo reply for id/seq.
+func isEchoReply(msg []byte, id, seq uint16) bool {
+	if len(msg) < 8 || msg[0] != 0 || msg[1] != 0 {
+		return false
+	}
+	return binary.BigEndian.Uint16(msg[4:6]) == id && binary.BigEndian.Uint16(msg[6:8]) == seq
+}
+
+func icmpChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	return ^uint16(sum)
+}
+
+// udpPing sends a datagram to an unlikely-open port. Port-unreachable
+// (surfaced as connection refused) or any reply means the host is up.
+func udpPing(ctx context.Context, d netutil.ContextDialer, ip string, timeout time.Duration) port.PortResult {
+	res := pingResult(ip, "udp-ping")
+	if d == nil {
+		d = &net.Dialer{Timeout: timeout}
+	}
+	conn, err := d.DialContext(ctx, "udp", net.JoinHostPort(ip, fmt.Sprint(udpPingPort)))
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer conn.Close()
+	res.LocalAddr = conn.LocalAddr().String()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+
+	start := time.Now()
+	if _, err := conn.Write([]byte{0x00}); err != nil {
+		if isConnRefusedErr(err) {
+			res.State = "up"
+			res.RTTMillis = time.Since(start).Milliseconds()
+			return res
+		}
+		res.Error = err.Error()
+		return res
+	}
+	buf := make([]byte, 512)
+	_, err = conn.Read(buf)
+	rtt := time.Since(start)
+	if err == nil || isConnRefusedErr(err) {
+		res.State = "up"
+		res.RTTMillis = rtt.Milliseconds()
+		return res
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		res.Error = "no reply"
+		return res
+	}
+	res.Error = err.Error()
+	return res
+}
@@ -0,0 +1,18 @@
+package scanner
+
+import "testing"
+
+func TestEchoRequestRoundTrip(t *testing.T) {
+	msg := buildEchoRequest(0x1234, 7)
+	if icmpChecksum(msg) != 0 {
+		t.Fatalf("echo request checksum does not verify")
+	}
+	reply := append([]byte(nil), msg...)
+	reply[0] = 0 // echo reply
+	if !isEchoReply(reply, 0x1234, 7) {
+		t.Fatalf("expected reply to match id/seq")
+	}
+	if isEchoReply(reply, 0x1234, 8) || isEchoReply(msg, 0x1234, 7) {
+		t.Fatalf("mismatched seq or request type must not match")
+	}
+}
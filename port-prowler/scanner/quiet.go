@@ -0,0 +1,200 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"portprowler/logging"
+)
+
+// QuietHours is a daily window, e.g. business hours, during which a scan
+// pauses or slows down (see Config.QuietWorkers). A window whose end is
+// not after its start wraps past midnight and belongs to the day it
+// starts on.
+type QuietHours struct {
+	Start, End time.Duration // offsets from midnight
+	Days       [7]bool       // indexed by time.Weekday; the window applies on these days
+	Loc        *time.Location
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseQuietHours parses "[days] HH:MM-HH:MM [zone]", e.g. "08:00-18:00",
+// "mon-fri 08:00-18:00" or "08:00-18:00 Europe/Berlin". Days are a range
+// or comma-separated list of three-letter names and default to every day;
+// the zone is an IANA name and defaults to local time.
+func ParseQuietHours(s string) (*QuietHours, error) {
+	fields := strings.Fields(s)
+	q := &QuietHours{Loc: time.Local}
+	if len(fields) > 0 && len(fields[0]) >= 3 && isLetter(fields[0][0]) {
+		days, err := parseDays(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		q.Days = days
+		fields = fields[1:]
+	} else {
+		for i := range q.Days {
+			q.Days[i] = true
+		}
+	}
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("invalid quiet hours %q (want [days] HH:MM-HH:MM [zone], e.g. mon-fri 08:00-18:00 Europe/Berlin)", s)
+	}
+	start, end, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid time range %q (want HH:MM-HH:MM)", fields[0])
+	}
+	var err error
+	if q.Start, err = parseClock(start); err != nil {
+		return nil, err
+	}
+	if q.End, err = parseClock(end); err != nil {
+		return nil, err
+	}
+	if len(fields) == 2 {
+		if q.Loc, err = time.LoadLocation(fields[1]); err != nil {
+			return nil, fmt.Errorf("unknown time zone %q", fields[1])
+		}
+	}
+	return q, nil
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q (want HH:MM)", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func parseDays(s string) ([7]bool, error) {
+	var days [7]bool
+	day := func(name string) (int, error) {
+		for i, d := range weekdays {
+			if strings.EqualFold(name, d) {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("invalid day %q (want mon, tue, ...)", name)
+	}
+	for _, part := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		a, err := day(from)
+		if err != nil {
+			return days, err
+		}
+		b := a
+		if isRange {
+			if b, err = day(to); err != nil {
+				return days, err
+			}
+		}
+		for i := a; ; i = (i + 1) % 7 {
+			days[i] = true
+			if i == b {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// String formats q the way ParseQuietHours reads it.
+func (q *QuietHours) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	}
+	s := clock(q.Start) + "-" + clock(q.End)
+	var days []string
+	for i, on := range q.Days {
+		if on {
+			days = append(days, weekdays[i])
+		}
+	}
+	if len(days) < 7 {
+		s = strings.Join(days, ",") + " " + s
+	}
+	if q.Loc != nil && q.Loc != time.Local {
+		s += " " + q.Loc.String()
+	}
+	return s
+}
+
+// Until reports whether t falls in a quiet window and, if so, when that
+// window ends.
+func (q *QuietHours) Until(t time.Time) (time.Time, bool) {
+	loc := q.Loc
+	if loc == nil {
+		loc = time.Local
+	}
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	off := t.Sub(midnight)
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+	switch {
+	case q.Start < q.End:
+		if q.Days[today] && off >= q.Start && off < q.End {
+			return midnight.Add(q.End), true
+		}
+	case q.Days[today] && off >= q.Start:
+		return midnight.AddDate(0, 0, 1).Add(q.End), true
+	case q.Days[yesterday] && off < q.End:
+		return midnight.Add(q.End), true
+	}
+	return time.Time{}, false
+}
+
+// quietRecheck bounds how long a worker sleeps in quiet hours before
+// looking at the clock again (e.g. after a suspend or clock change).
+const quietRecheck = time.Minute
+
+// quietGate holds a worker during quiet hours: until they end or, with
+// QuietWorkers, until one of that many slots is free. The returned
+// release gives the slot back. ok is false when the scan was stopped or
+// ctx cancelled.
+func (m *Manager) quietGate(ctx context.Context) (release func(), ok bool) {
+	release = func() {}
+	q := m.cfg.QuietHours
+	if q == nil {
+		return release, true
+	}
+	for {
+		now := time.Now()
+		end, quiet := q.Until(now)
+		if !quiet {
+			return release, true
+		}
+		wait := end.Sub(now)
+		if wait > quietRecheck {
+			wait = quietRecheck
+		}
+		if m.quietEnd.Swap(end.Unix()) != end.Unix() && m.cfg.Verbose {
+			if m.quietSlots == nil {
+				logging.Verbosef("quiet hours: pausing until %s", end.Format("15:04 MST"))
+			} else {
+				logging.Verbosef("quiet hours: running %d worker(s) until %s", cap(m.quietSlots), end.Format("15:04 MST"))
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case m.quietSlots <- struct{}{}: // never ready without QuietWorkers
+			timer.Stop()
+			return func() { <-m.quietSlots }, true
+		case <-timer.C:
+		case <-m.stop:
+			timer.Stop()
+			return nil, false
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, false
+		}
+	}
+}
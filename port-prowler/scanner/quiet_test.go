@@ -0,0 +1,103 @@
+package scanner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"portprowler/port"
+	"portprowler/report"
+	"portprowler/testsupport"
+)
+
+func TestParseQuietHours(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	q, err := ParseQuietHours("mon-fri 08:00-18:30 Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.String() != "mon,tue,wed,thu,fri 08:00-18:30 Europe/Berlin" {
+		t.Errorf("String() = %q", q.String())
+	}
+	// 2024-05-03 is a Friday, 2024-05-04 a Saturday.
+	cases := []struct {
+		at    time.Time
+		quiet bool
+		end   string
+	}{
+		{time.Date(2024, 5, 3, 7, 59, 0, 0, berlin), false, ""},
+		{time.Date(2024, 5, 3, 8, 0, 0, 0, berlin), true, "2024-05-03 18:30"},
+		{time.Date(2024, 5, 3, 6, 30, 0, 0, time.UTC), true, "2024-05-03 18:30"}, // 08:30 in Berlin
+		{time.Date(2024, 5, 3, 18, 30, 0, 0, berlin), false, ""},
+		{time.Date(2024, 5, 4, 12, 0, 0, 0, berlin), false, ""},
+	}
+	for _, c := range cases {
+		end, quiet := q.Until(c.at)
+		if quiet != c.quiet || quiet && end.Format("2006-01-02 15:04") != c.end {
+			t.Errorf("Until(%v) = %v, %v; want %v, %s", c.at, end, quiet, c.quiet, c.end)
+		}
+	}
+
+	// A night window belongs to the day it starts on.
+	night, err := ParseQuietHours("fri 22:00-06:00 UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if end, quiet := night.Until(time.Date(2024, 5, 4, 5, 0, 0, 0, time.UTC)); !quiet || end.Hour() != 6 || end.Day() != 4 {
+		t.Errorf("saturday 05:00 should be in friday's window until 06:00, got %v %v", end, quiet)
+	}
+	if _, quiet := night.Until(time.Date(2024, 5, 3, 5, 0, 0, 0, time.UTC)); quiet {
+		t.Error("friday 05:00 belongs to thursday's window, which is not set")
+	}
+
+	for _, bad := range []string{"", "8-18", "08:00", "25:00-26:00", "someday 08:00-18:00", "08:00-18:00 Mars/Olympus", "08:00-18:00 UTC extra"} {
+		if _, err := ParseQuietHours(bad); err == nil {
+			t.Errorf("ParseQuietHours(%q) should fail", bad)
+		}
+	}
+}
+
+func TestManager_QuietHours(t *testing.T) {
+	always := &QuietHours{Days: [7]bool{true, true, true, true, true, true, true}, Loc: time.UTC} // 00:00-00:00 is all day
+	fake := testsupport.NewNetwork()
+	fake.AddHost("192.0.2.1").TCP(22, testsupport.Port{Banner: "SSH-2.0-test\r\n"})
+	cfg := Config{
+		Targets:    []port.Target{{Name: "quiet.example", IP: "192.0.2.1"}},
+		Ports:      []uint16{22, 23, 24},
+		ScanTCP:    true,
+		Workers:    4,
+		TCPTimeout: time.Second,
+		Dialer:     fake,
+		QuietHours: always,
+	}
+
+	// Paused: nothing runs until the scan is stopped.
+	mgr := NewManager(cfg)
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if rep := mgr.Stop(); len(rep.Hosts) != 1 || len(rep.Hosts[0].Results) != 0 || rep.Meta.Status != report.StatusCancelled {
+		t.Fatalf("paused scan ran probes or completed: %+v", rep)
+	}
+	for range out {
+	}
+
+	// Throttled: everything runs, on fewer workers.
+	cfg.QuietWorkers = 1
+	mgr = NewManager(cfg)
+	if out, err = mgr.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for range out {
+		n++
+	}
+	if n != 3 || mgr.Report(report.Meta{}).Meta.Status != report.StatusComplete {
+		t.Fatalf("throttled scan delivered %d results", n)
+	}
+}
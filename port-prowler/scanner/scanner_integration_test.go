@@ -2,6 +2,7 @@ package scanner
 
 import (
 	"context"
+	"io"
 	"net"
 	"testing"
 	"time"
@@ -61,3 +62,39 @@ func TestUDPScan_Open(t *testing.T) {
 		t.Fatalf("expected udp open, got %s (err=%s)", res.State, res.Error)
 	}
 }
+
+func TestTCPScan_RSTClose(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	portNum := uint16(l.Addr().(*net.TCPAddr).Port)
+
+	// The server sees EOF after a FIN and an error after a RST.
+	closeErr := func(rst bool) error {
+		done := make(chan error, 1)
+		go func() {
+			c, err := l.Accept()
+			if err != nil {
+				done <- err
+				return
+			}
+			defer c.Close()
+			_ = c.SetReadDeadline(time.Now().Add(2 * time.Second))
+			_, err = c.Read(make([]byte, 1))
+			done <- err
+		}()
+		res := tcpScan(context.Background(), nil, "127.0.0.1", portNum, time.Second, 0, rst, false)
+		if res.State != "open" {
+			t.Fatalf("expected open, got %s (err=%s)", res.State, res.Error)
+		}
+		return <-done
+	}
+	if err := closeErr(false); err != io.EOF {
+		t.Errorf("FIN close: server read err = %v, want EOF", err)
+	}
+	if err := closeErr(true); err == nil || err == io.EOF {
+		t.Errorf("RST close: server read err = %v, want a reset", err)
+	}
+}
@@ -0,0 +1,96 @@
+package scanner
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"time"
+
+	"portprowler/port"
+)
+
+// MaxSYNShards bounds Config.SYNShards.
+const MaxSYNShards = 64
+
+// synShards spreads the stealth probes to one interface over several
+// engines, each with its own socket, source port and send and receive
+// loops, so one goroutine per direction does not cap the packet rate.
+// The kernel filter of each socket passes only its engine's replies.
+// A target address and port always map to the same engine.
+type synShards []*synEngine
+
+// newSYNShards opens n engines with consecutive source ports. With cpus,
+// engine i pins its loops to cpus[i%len(cpus)].
+func newSYNShards(cfg synConfig, n int, cpus []int) (synShards, error) {
+	if n < 1 {
+		n = 1
+	}
+	base := uint16(40000 + rand.Intn(20000-n))
+	s := make(synShards, 0, n)
+	for i := 0; i < n; i++ {
+		c := cfg
+		c.srcPort = base + uint16(i)
+		if len(cpus) > 0 {
+			c.pin, c.cpu = true, cpus[i%len(cpus)]
+		}
+		e, err := newSYNEngine(c)
+		if err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+		s = append(s, e)
+	}
+	return s, nil
+}
+
+// pick returns the engine for probes to dst:portNum.
+func (s synShards) pick(dst net.IP, portNum uint16) *synEngine {
+	if len(s) == 1 {
+		return s[0]
+	}
+	var h uint32
+	if ip := dst.To4(); ip != nil {
+		h = binary.BigEndian.Uint32(ip)
+	}
+	h = (h ^ uint32(portNum)<<16 ^ uint32(portNum)) * 2654435761 // Knuth's multiplicative hash
+	return s[h%uint32(len(s))]
+}
+
+func (s synShards) probe(ctx context.Context, ip string, portNum uint16, timeout time.Duration, verbose bool) port.PortResult {
+	return s.pick(net.ParseIP(ip), portNum).probe(ctx, ip, portNum, timeout, verbose)
+}
+
+func (s synShards) send(ctx context.Context, dst net.IP, portNum uint16) bool {
+	return s.pick(dst, portNum).send(ctx, dst, portNum)
+}
+
+// dropped sums the stateless SYNs the engines' sockets refused.
+func (s synShards) dropped() int64 {
+	var n int64
+	for _, e := range s {
+		n += e.dropped.Load()
+	}
+	return n
+}
+
+// replies sums the duplicate replies the engines suppressed and the ones
+// that matched no probe.
+func (s synShards) replies() (dups, strays int64) {
+	for _, e := range s {
+		dups += e.dups.Load()
+		strays += e.strays.Load()
+	}
+	return dups, strays
+}
+
+// Close closes every engine.
+func (s synShards) Close() error {
+	var err error
+	for _, e := range s {
+		if cerr := e.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
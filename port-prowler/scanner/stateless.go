@@ -0,0 +1,170 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"portprowler/logging"
+	"portprowler/netutil"
+	"portprowler/port"
+	"portprowler/rawsock"
+)
+
+// runStateless is Run for Config.Stateless. A single goroutine sends a
+// SYN to every port of every address, with a cookie derived from the
+// addresses and ports as sequence number, and the receive loop turns each
+// reply carrying a valid cookie into a result. Nothing is kept per probe,
+// only per address and per answering port, so memory does not grow with
+// the number of probes. After the last SYN the scan waits StealthTimeout
+// for late replies.
+func (m *Manager) runStateless(ctx context.Context) (<-chan port.PortResult, error) {
+	if !m.cfg.ScanStealth || m.cfg.ScanTCP || m.cfg.ScanUDP || m.cfg.ScanPing || m.cfg.PingGate || len(m.portScanTypes()) > 1 {
+		return nil, errors.New("invalid manager config: stateless scans are stealth-only")
+	}
+	if ok, _ := netutil.CanOpenRawSocket(); !ok {
+		return nil, ErrNeedPriv
+	}
+	targets := m.dedupTargets()
+	names := make(map[[4]byte][]string, len(targets))
+	for _, t := range targets {
+		ip := net.ParseIP(t.IP).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("%s (%s): %w", t.Name, t.IP, errStealthIPv4)
+		}
+		names[[4]byte(ip)] = append([]string{t.Name}, t.aliases...)
+	}
+	ports := m.portOrder()
+	m.stats.SetTotal(len(targets) * len(ports))
+	if m.cfg.OnHostComplete != nil {
+		m.pending = make(map[string]int)
+		m.byTarget = make(map[string][]port.PortResult)
+		for _, t := range targets {
+			for _, name := range append([]string{t.Name}, t.aliases...) {
+				m.pending[name]++
+			}
+		}
+	}
+	out := make(chan port.PortResult, m.resultBuffer())
+
+	// Replies are only deduplicated, e.g. against retransmitted SYN/ACKs.
+	var seenMu sync.Mutex
+	seen := make(map[synKey]bool)
+	m.synReply = func(seg rawsock.TCPSegment, at time.Time) bool {
+		key := synKey{port: seg.SrcPort}
+		copy(key.ip[:], seg.Src.To4())
+		seenMu.Lock()
+		dup := seen[key]
+		seen[key] = true
+		seenMu.Unlock()
+		if dup {
+			return false
+		}
+		if names[key.ip] == nil {
+			return true
+		}
+		res := port.PortResult{IP: seg.Src.String(), Port: seg.SrcPort, Proto: string(port.ScanStealth), State: "open", Timestamp: at.UTC()}
+		if seg.Flags&rawsock.FlagRST != 0 {
+			res.State = "closed"
+		}
+		m.stats.Record(res)
+		for _, name := range names[key.ip] {
+			res.Target = name
+			if m.cfg.Audit != nil {
+				m.cfg.Audit.Probe(at, res)
+			}
+			if !m.deliver(ctx, out, res) {
+				return true
+			}
+		}
+		if res.State == "open" && m.openLimit() > 0 {
+			m.countOpen(port.PortJob{IP: res.IP, Port: res.Port})
+		}
+		return true
+	}
+
+	m.stats.Start()
+	m.mu.Lock()
+	m.finished = make(chan struct{})
+	finished := m.finished
+	m.mu.Unlock()
+	done := make(chan struct{})
+	if m.cfg.Progress != nil {
+		go m.reportProgress(done)
+	}
+
+	go func() {
+	send:
+		for _, t := range targets {
+			dst := net.ParseIP(t.IP).To4()
+			e, err := m.synEngineFor(dst)
+			if err != nil {
+				if m.cfg.Verbose {
+					logging.Verbosef("stateless: skipping %s: %v", t.IP, err)
+				}
+				m.stats.Skip(len(m.cfg.Ports))
+				continue
+			}
+			for _, p := range ports {
+				if !m.waitRunnable(ctx) {
+					m.markCancelled()
+					break send
+				}
+				if m.skipHost(port.PortJob{IP: t.IP, Port: p, ScanTypes: []port.ScanType{port.ScanStealth}}) {
+					continue
+				}
+				if !m.bandwidthWait(ctx, t.IP, probeBytes(port.ScanStealth, t.IP, p, m.cfg.DNSQuery)) {
+					m.markCancelled()
+					break send
+				}
+				if !e.send(ctx, dst, p) {
+					m.markCancelled()
+					break send
+				}
+			}
+		}
+
+		// Late replies still count; a stop ends the wait.
+		grace := m.cfg.StealthTimeout
+		if grace <= 0 {
+			grace = time.Second
+		}
+		timer := time.NewTimer(grace)
+		select {
+		case <-timer.C:
+		case <-m.stop:
+		case <-ctx.Done():
+		}
+		timer.Stop()
+		var dropped int64
+		m.synMu.Lock()
+		for _, s := range m.synEngines {
+			dropped += s.dropped()
+		}
+		m.synMu.Unlock()
+		m.closeSYN()
+		if dropped > 0 && m.cfg.Verbose {
+			logging.Verbosef("stateless: the kernel refused %d SYNs", dropped)
+		}
+
+		snap := m.stats.Snapshot()
+		m.stats.Skip(snap.Total - snap.Probes) // silent ports produce no result
+		m.settle(ctx, false)
+		m.stats.Finish()
+		if m.cfg.Progress != nil {
+			m.writeProgress(true)
+		}
+		close(done)
+		if m.cfg.OnHostComplete != nil {
+			for _, t := range targets {
+				m.jobDone(port.PortJob{Target: t.Name, Aliases: t.aliases})
+			}
+		}
+		close(out)
+		close(finished)
+	}()
+	return out, nil
+}
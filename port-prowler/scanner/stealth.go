@@ -2,42 +2,144 @@ package scanner
 
 import (
 	"context"
-	"fmt"
+	"net"
 	"time"
 
+	"portprowler/logging"
 	"portprowler/netutil"
 	"portprowler/port"
+	"portprowler/rawsock"
 )
 
-// StealthScan is a minimal scaffold for a SYN/stealth scan.
-// Behavior:
-//   - Returns PortResult.Proto == "stealth".
-//   - Fails early if raw-socket privileges are not available.
-//   - When privileges present this is a stub (not performing real raw-socket SYNs).
+// StealthScan sends a single SYN to ip:portNum through a raw socket and
+// classifies the reply: SYN/ACK is open, RST closed, and silence within
+// timeout filtered. The kernel answers the SYN/ACK with a RST, so no
+// connection is ever completed. Results have Proto "stealth".
 //
-// Notes:
-//   - This file intentionally implements a conservative, testable stub. A full
-//     Linux-focused raw-socket implementation may be added behind build tags later.
+// StealthScan opens and closes its own socket; a Manager shares one
+// engine per interface between all stealth probes of a scan.
 func StealthScan(ctx context.Context, ip string, portNum uint16, timeout time.Duration, verbose bool) port.PortResult {
-	res := port.PortResult{
-		IP:        ip,
-		Port:      portNum,
-		Proto:     "stealth",
-		State:     "filtered",
-		RTTMillis: 0,
+	target := net.ParseIP(ip)
+	e, err := newSYNEngine(synConfig{opts: rawsock.Options{Target: target}, sources: []net.IP{target}})
+	if err != nil {
+		return stealthError(ip, portNum, err)
+	}
+	defer e.Close()
+	return e.probe(ctx, ip, portNum, timeout, verbose)
+}
+
+func stealthError(ip string, portNum uint16, err error) port.PortResult {
+	return port.PortResult{IP: ip, Port: portNum, Proto: string(port.ScanStealth), State: "filtered", Error: err.Error()}
+}
+
+// stealthScan probes ip:portNum through the engine of the interface that
+// routes to ip, opening it on first use (see synEngineFor).
+func (m *Manager) stealthScan(ctx context.Context, ip string, portNum uint16) port.PortResult {
+	e, err := m.synEngineFor(net.ParseIP(ip))
+	if err != nil {
+		return stealthError(ip, portNum, err)
 	}
+	return e.probe(ctx, ip, portNum, m.cfg.StealthTimeout, m.cfg.Verbose)
+}
 
-	ok, err := netutil.CanOpenRawSocket()
+// synEngineFor returns the engines of the interface that routes to ip,
+// opening them on first use: Config.SYNShards of them, pinned to
+// Config.PinCPUs or the interface's NUMA node when asked to. Engines are
+// stateless when m.synReply is set.
+func (m *Manager) synEngineFor(ip net.IP) (synShards, error) {
+	if ip.To4() == nil {
+		return nil, errStealthIPv4
+	}
+	route, err := netutil.RouteTo(ip)
 	if err != nil {
-		res.Error = fmt.Sprintf("stealth privilege check error: %v", err)
-		return res
+		return nil, err
+	}
+	key := route.Interface
+	if key == "" {
+		key = route.Src.String()
+	}
+	m.synMu.Lock()
+	defer m.synMu.Unlock()
+	if m.synErr != nil {
+		return nil, m.synErr
 	}
-	if !ok {
-		res.Error = "stealth scan requires raw socket privileges"
-		return res
+	if e := m.synEngines[key]; e != nil {
+		return e, nil
+	}
+	cpus := m.cfg.PinCPUs
+	if m.cfg.PinNUMA {
+		if cpus, err = netutil.NUMACPUs(route.Interface); err != nil && m.cfg.Verbose {
+			logging.Verbosef("syn: not pinning engines on %s: %v", key, err)
+		}
+	}
+	e, err := newSYNShards(synConfig{
+		opts:    rawsock.Options{Target: ip, FastIO: m.cfg.FastIO},
+		sources: m.synSources(),
+		onReply: m.synReply,
+	}, m.cfg.SYNShards, cpus)
+	if err != nil {
+		if err == errNoRawPriv {
+			m.synErr = err // no interface will do better
+		}
+		return nil, err
 	}
+	if m.cfg.Verbose {
+		m.logSYNEngines(key, e)
+	}
+	if m.synEngines == nil {
+		m.synEngines = make(map[string]synShards)
+	}
+	m.synEngines[key] = e
+	return e, nil
+}
 
-	// Privileges present but full stealth implementation not provided in this milestone.
-	res.Error = "stealth scan not implemented in this build (stub)"
-	return res
+// logSYNEngines reports how the engines of an interface were set up.
+func (m *Manager) logSYNEngines(key string, s synShards) {
+	// The engines share their setup, so the first one speaks for all.
+	if e := s[0]; e.fastErr != nil {
+		logging.Verbosef("syn: no packet rings on %s, using raw sockets: %v", key, e.fastErr)
+	}
+	if e := s[0]; e.filterErr != nil {
+		logging.Verbosef("syn: no kernel filter on %s, filtering replies in userspace: %v", key, e.filterErr)
+	}
+	var cpus []int
+	for _, e := range s {
+		if e.pinErr != nil {
+			logging.Verbosef("syn: engine on port %d runs unpinned: %v", e.srcPort, e.pinErr)
+		} else if e.cpu >= 0 {
+			cpus = append(cpus, e.cpu)
+		}
+	}
+	if len(cpus) > 0 {
+		logging.Verbosef("syn: %d engine(s) on %s, source ports %d-%d, pinned to CPUs %v", len(s), key, s[0].srcPort, s[len(s)-1].srcPort, cpus)
+	} else if len(s) > 1 {
+		logging.Verbosef("syn: %d engines on %s, source ports %d-%d", len(s), key, s[0].srcPort, s[len(s)-1].srcPort)
+	}
+}
+
+// synSources lists the IPv4 target addresses, the only sources stealth
+// replies may come from.
+func (m *Manager) synSources() []net.IP {
+	var out []net.IP
+	seen := make(map[string]bool)
+	for _, t := range m.cfg.ScanTargets() {
+		if ip := net.ParseIP(t.IP).To4(); ip != nil && !seen[t.IP] {
+			seen[t.IP] = true
+			out = append(out, ip)
+		}
+	}
+	return out
+}
+
+// closeSYN closes the stealth engines once no probe can use them.
+func (m *Manager) closeSYN() {
+	m.synMu.Lock()
+	defer m.synMu.Unlock()
+	for key, e := range m.synEngines {
+		_ = e.Close()
+		delete(m.synEngines, key)
+		if dups, strays := e.replies(); m.cfg.Verbose && dups+strays > 0 {
+			logging.Verbosef("syn: %s: suppressed %d duplicate replies, ignored %d matching no probe", key, dups, strays)
+		}
+	}
 }
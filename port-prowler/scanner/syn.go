@@ -0,0 +1,479 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"portprowler/logging"
+	"portprowler/netutil"
+	"portprowler/port"
+	"portprowler/rawsock"
+)
+
+// synBatch is the most SYNs sent, or replies read, in one system call.
+const synBatch = 64
+
+// synPoll bounds how long the receive loop blocks before checking whether
+// the engine was closed.
+const synPoll = 200 * time.Millisecond
+
+// synDupWindow is how long an answered probe is remembered, so that
+// retransmitted SYN/ACKs (typically 1s and 3s after the first) are
+// recognised as duplicates.
+const synDupWindow = 4 * time.Second
+
+// synEngine runs stealth probes over one raw socket: a sender goroutine
+// hands queued SYNs to the kernel in batches, and a single receive loop
+// passes each SYN/ACK or RST to the probe waiting for it. Probes are
+// told apart by target address and port; the engine uses one source port.
+// A reply is attributed to a probe only if it comes from the probed
+// address and port, goes to the engine's address and port and
+// acknowledges the probe's sequence number; each probe takes one reply
+// and later copies of it are counted as duplicates.
+//
+// A stateless engine (see newSYNEngine) keeps no waiting probes: it puts
+// a cookie into every SYN and hands each reply carrying a valid one to
+// onReply, which tells duplicates apart itself.
+type synEngine struct {
+	conn    rawsock.Conn
+	local   net.IP // source address of the SYNs
+	srcPort uint16
+	queue   chan synPacket
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	cookie    rawsock.Cookie
+	onReply   func(seg rawsock.TCPSegment, at time.Time) bool
+	dropped   atomic.Int64 // stateless SYNs the kernel refused
+	dups      atomic.Int64 // replies to a probe that already had one
+	strays    atomic.Int64 // replies that matched no probe
+	filterErr error        // why no kernel filter is installed, if none is
+	fastErr   error        // why FastIO fell back to raw sockets, if it did
+	pinErr    error        // why the loops run unpinned, if they were to be pinned
+	cpu       int          // CPU the loops are pinned to, or -1
+
+	mu       sync.Mutex
+	waiting  map[synKey]*synWaiter
+	answered [2]map[synReplyKey]bool // probes answered this and the previous synDupWindow
+	rotated  time.Time               // when answered last moved on
+	broken   error                   // why the receive loop stopped
+}
+
+type synKey struct {
+	ip   [4]byte
+	port uint16
+}
+
+// synReplyKey identifies the reply to one probe.
+type synReplyKey struct {
+	synKey
+	ack uint32
+}
+
+type synWaiter struct {
+	seq   uint32
+	reply chan synReply // buffered; receives at most one reply
+}
+
+type synReply struct {
+	seg rawsock.TCPSegment
+	at  time.Time
+	err error
+}
+
+// synPacket is a queued SYN. Stateless SYNs leave pkt nil and are built
+// by the send loop.
+type synPacket struct {
+	key  synKey
+	pkt  []byte
+	dst  net.IP
+	port uint16
+}
+
+// synConfig configures newSYNEngine.
+type synConfig struct {
+	opts    rawsock.Options
+	sources []net.IP // the only addresses replies may come from
+	srcPort uint16   // 0 picks a random port
+	pin     bool     // pin the loops to cpu
+	cpu     int
+	onReply func(rawsock.TCPSegment, time.Time) bool // makes the engine stateless; false for a duplicate
+}
+
+var (
+	// errNoRawPriv is reported for stealth probes without raw socket privileges.
+	errNoRawPriv   = errors.New("stealth scan requires raw socket privileges")
+	errStealthIPv4 = errors.New("stealth scan supports IPv4 targets only")
+)
+
+// newSYNEngine opens a raw socket on the route to cfg.opts.Target and
+// starts the engine's send and receive loops; with opts.FastIO it tries
+// packet rings first. The kernel is asked to pass only replies to the
+// engine's source port from the sources addresses. With onReply the
+// engine is stateless: probes are sent with send instead of probe.
+func newSYNEngine(cfg synConfig) (*synEngine, error) {
+	opts := cfg.opts
+	ok, err := netutil.CanOpenRawSocket()
+	if err != nil {
+		return nil, fmt.Errorf("stealth privilege check error: %v", err)
+	}
+	if !ok {
+		return nil, errNoRawPriv
+	}
+	if opts.Target.To4() == nil {
+		return nil, errStealthIPv4
+	}
+	cookie, err := rawsock.NewCookie()
+	if err != nil {
+		return nil, err
+	}
+	var fastErr error
+	conn, err := rawsock.Open(opts)
+	if err != nil && opts.FastIO {
+		fastErr = err
+		opts.FastIO = false
+		conn, err = rawsock.Open(opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	e := &synEngine{
+		conn:    conn,
+		local:   conn.LocalIP().To4(),
+		srcPort: cfg.srcPort,
+		queue:   make(chan synPacket, synBatch*4),
+		done:    make(chan struct{}),
+		waiting: make(map[synKey]*synWaiter),
+		cookie:  cookie,
+		onReply: cfg.onReply,
+		fastErr: fastErr,
+		cpu:     -1,
+	}
+	if e.srcPort == 0 {
+		e.srcPort = uint16(40000 + rand.Intn(20000))
+	}
+	if cfg.pin {
+		e.cpu = cfg.cpu
+	}
+	// Without a filter the receive loop sorts the traffic itself.
+	e.filterErr = rawsock.SetFilter(conn, rawsock.Filter{Port: e.srcPort, Sources: cfg.sources})
+	pinned := make(chan error, 2)
+	e.wg.Add(2)
+	go e.sendLoop(pinned)
+	go e.recvLoop(pinned)
+	for i := 0; i < 2; i++ {
+		if err := <-pinned; err != nil {
+			e.pinErr = err
+		}
+	}
+	return e, nil
+}
+
+// pin locks the calling loop to its thread and the thread to e.cpu. On
+// failure the loop runs unpinned.
+func (e *synEngine) pin() error {
+	if e.cpu < 0 {
+		return nil
+	}
+	runtime.LockOSThread()
+	if err := netutil.PinThread(e.cpu); err != nil {
+		runtime.UnlockOSThread()
+		return err
+	}
+	return nil
+}
+
+// Close stops the loops and closes the socket. Waiting probes time out.
+func (e *synEngine) Close() error {
+	close(e.done)
+	err := e.conn.Close()
+	e.wg.Wait()
+	return err
+}
+
+// probe sends one SYN to ip:portNum and classifies the answer: SYN/ACK is
+// open, RST closed, and no answer within timeout filtered.
+func (e *synEngine) probe(ctx context.Context, ip string, portNum uint16, timeout time.Duration, verbose bool) port.PortResult {
+	res := port.PortResult{IP: ip, Port: portNum, Proto: string(port.ScanStealth), State: "filtered"}
+	dst := net.ParseIP(ip).To4()
+	if dst == nil {
+		res.Error = errStealthIPv4.Error()
+		return res
+	}
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	key := synKey{port: portNum}
+	copy(key.ip[:], dst)
+	w := &synWaiter{seq: rand.Uint32(), reply: make(chan synReply, 1)}
+	e.mu.Lock()
+	if e.broken != nil {
+		e.mu.Unlock()
+		res.Error = e.broken.Error()
+		return res
+	}
+	if _, busy := e.waiting[key]; busy {
+		e.mu.Unlock()
+		res.Error = "stealth probe to this port already in flight"
+		return res
+	}
+	e.waiting[key] = w
+	e.mu.Unlock()
+	defer e.forget(key, w)
+
+	pkt, err := rawsock.TCPSegment{
+		Src: e.local, Dst: dst,
+		SrcPort: e.srcPort, DstPort: portNum,
+		Seq: w.seq, Flags: rawsock.FlagSYN,
+	}.Marshal()
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	start := time.Now()
+	select {
+	case e.queue <- synPacket{key: key, pkt: pkt, dst: dst}:
+	case <-ctx.Done():
+		return res
+	case <-e.done:
+		res.Error = "stealth engine closed"
+		return res
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-w.reply:
+		if r.err != nil {
+			res.Error = r.err.Error()
+			return res
+		}
+		res.RTTMillis = r.at.Sub(start).Milliseconds()
+		if r.seg.Flags&rawsock.FlagRST != 0 {
+			res.State = "closed"
+		} else {
+			res.State = "open"
+		}
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	if verbose {
+		logging.Verbosef("syn %s:%d %s rtt=%dms", ip, portNum, res.State, res.RTTMillis)
+	}
+	return res
+}
+
+// send queues a stateless SYN to dst:portNum whose sequence number is the
+// engine's cookie. It returns false when ctx was cancelled or the engine
+// closed before the SYN was queued.
+func (e *synEngine) send(ctx context.Context, dst net.IP, portNum uint16) bool {
+	select {
+	case e.queue <- synPacket{dst: dst, port: portNum}:
+		return true
+	case <-ctx.Done():
+	case <-e.done:
+	}
+	return false
+}
+
+// forget removes w from the waiting probes unless a reply already did.
+func (e *synEngine) forget(key synKey, w *synWaiter) {
+	e.mu.Lock()
+	if e.waiting[key] == w {
+		delete(e.waiting, key)
+	}
+	e.mu.Unlock()
+}
+
+// take removes and returns the probe a reply belongs to, or nil.
+func (e *synEngine) take(key synKey, match func(*synWaiter) bool) *synWaiter {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	w := e.waiting[key]
+	if w == nil || !match(w) {
+		return nil
+	}
+	delete(e.waiting, key)
+	return w
+}
+
+// answer remembers that the probe acknowledged by k got its reply.
+func (e *synEngine) answer(k synReplyKey, at time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rotate(at)
+	e.answered[0][k] = true
+}
+
+// duplicate reports whether the probe acknowledged by k got its reply
+// within the last synDupWindow or so.
+func (e *synEngine) duplicate(k synReplyKey, at time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rotate(at)
+	return e.answered[0][k] || e.answered[1][k]
+}
+
+// rotate forgets the answers older than two synDupWindows. e.mu is held.
+func (e *synEngine) rotate(at time.Time) {
+	switch age := at.Sub(e.rotated); {
+	case e.answered[0] == nil || age >= 2*synDupWindow:
+		e.answered = [2]map[synReplyKey]bool{make(map[synReplyKey]bool), nil}
+		e.rotated = at
+	case age >= synDupWindow:
+		e.answered[1], e.answered[0] = e.answered[0], make(map[synReplyKey]bool)
+		e.rotated = at
+	}
+}
+
+// statelessSYN builds the SYN for a packet queued by send.
+func (e *synEngine) statelessSYN(p synPacket) ([]byte, error) {
+	src := e.local
+	return rawsock.TCPSegment{
+		Src: src, Dst: p.dst,
+		SrcPort: e.srcPort, DstPort: p.port,
+		Seq: e.cookie.Seq(src, p.dst, e.srcPort, p.port), Flags: rawsock.FlagSYN,
+	}.Marshal()
+}
+
+// sendLoop writes queued SYNs, batching whatever is queued at the time
+// (up to synBatch) into one WritePackets call.
+func (e *synEngine) sendLoop(pinned chan<- error) {
+	defer e.wg.Done()
+	pinned <- e.pin()
+	batch := make([]synPacket, 0, synBatch)
+	pkts := make([][]byte, 0, synBatch)
+	dsts := make([]net.IP, 0, synBatch)
+	for {
+		select {
+		case p := <-e.queue:
+			batch = append(batch[:0], p)
+		case <-e.done:
+			return
+		}
+	fill:
+		for len(batch) < synBatch {
+			select {
+			case p := <-e.queue:
+				batch = append(batch, p)
+			default:
+				break fill
+			}
+		}
+		pkts, dsts = pkts[:0], dsts[:0]
+		kept := batch[:0]
+		for _, p := range batch {
+			if p.pkt == nil {
+				var err error
+				if p.pkt, err = e.statelessSYN(p); err != nil {
+					e.dropped.Add(1)
+					continue
+				}
+			}
+			kept = append(kept, p)
+			pkts = append(pkts, p.pkt)
+			dsts = append(dsts, p.dst)
+		}
+		batch = kept
+		n, err := rawsock.WritePackets(e.conn, pkts, dsts)
+		if err == nil {
+			continue
+		}
+		if e.onReply != nil {
+			e.dropped.Add(int64(len(batch) - n))
+			continue
+		}
+		// Everything from the failed packet on was not sent.
+		for _, p := range batch[n:] {
+			if w := e.take(p.key, func(*synWaiter) bool { return true }); w != nil {
+				w.reply <- synReply{err: err}
+			}
+		}
+	}
+}
+
+// recvLoop reads replies in batches and delivers the ones that answer a
+// waiting probe: sent to the engine's source port from the probed address
+// and port, acknowledging the probe's sequence number.
+func (e *synEngine) recvLoop(pinned chan<- error) {
+	defer e.wg.Done()
+	pinned <- e.pin()
+	bufs := make([][]byte, synBatch)
+	for i := range bufs {
+		bufs[i] = make([]byte, 256) // IP and TCP headers; payload is not needed
+	}
+	sizes := make([]int, synBatch)
+	for {
+		n, err := rawsock.ReadPackets(e.conn, bufs, sizes, time.Now().Add(synPoll))
+		select {
+		case <-e.done:
+			return
+		default:
+		}
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				continue
+			}
+			e.fail(fmt.Errorf("stealth receive: %w", err))
+			return
+		}
+		at := time.Now()
+		for i := 0; i < n; i++ {
+			e.deliver(bufs[i][:sizes[i]], at)
+		}
+	}
+}
+
+// fail ends every waiting probe, and any later one, with err.
+func (e *synEngine) fail(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.broken = err
+	for key, w := range e.waiting {
+		w.reply <- synReply{err: err}
+		delete(e.waiting, key)
+	}
+}
+
+// deliver attributes one received packet: a SYN/ACK or RST to the
+// engine's address and port goes to the probe it answers, and anything
+// else is ignored.
+func (e *synEngine) deliver(pkt []byte, at time.Time) {
+	seg, err := rawsock.ParseTCP(pkt)
+	if err != nil || seg.DstPort != e.srcPort || !seg.Dst.Equal(e.local) {
+		return
+	}
+	synAck := rawsock.FlagSYN | rawsock.FlagACK
+	if seg.Flags&synAck != synAck && seg.Flags&rawsock.FlagRST == 0 {
+		return
+	}
+	if e.onReply != nil {
+		if !e.cookie.Match(seg) {
+			e.strays.Add(1)
+		} else if !e.onReply(seg, at) {
+			e.dups.Add(1)
+		}
+		return
+	}
+	key := synKey{port: seg.SrcPort}
+	copy(key.ip[:], seg.Src.To4())
+	k := synReplyKey{key, seg.Ack}
+	if w := e.take(key, func(w *synWaiter) bool { return seg.Ack == w.seq+1 }); w != nil {
+		e.answer(k, at)
+		w.reply <- synReply{seg: seg, at: at}
+		return
+	}
+	if e.duplicate(k, at) {
+		e.dups.Add(1)
+	} else {
+		e.strays.Add(1)
+	}
+}
@@ -0,0 +1,198 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"portprowler/netutil"
+	"portprowler/port"
+	"portprowler/rawsock"
+)
+
+func TestManager_Stealth(t *testing.T) {
+	if ok, _ := netutil.CanOpenRawSocket(); !ok {
+		t.Skip("needs raw socket privileges")
+	}
+	var open []uint16
+	for i := 0; i < 3; i++ {
+		l, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		open = append(open, uint16(l.Addr().(*net.TCPAddr).Port))
+	}
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := uint16(l.Addr().(*net.TCPAddr).Port)
+	l.Close()
+
+	mgr := NewManager(Config{
+		Targets:        []port.Target{{Name: "localhost", IP: "127.0.0.1"}},
+		Ports:          append(open, closed),
+		ScanStealth:    true,
+		Workers:        8,
+		StealthTimeout: time.Second,
+	})
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := make(map[uint16]string)
+	for r := range out {
+		if r.Error != "" {
+			t.Errorf("%d: %s", r.Port, r.Error)
+		}
+		states[r.Port] = r.State
+	}
+	for _, p := range open {
+		if states[p] != "open" {
+			t.Errorf("port %d: %q, want open", p, states[p])
+		}
+	}
+	if states[closed] != "closed" {
+		t.Errorf("port %d: %q, want closed", closed, states[closed])
+	}
+	if len(mgr.synEngines) != 0 {
+		t.Error("engines left open after the scan")
+	}
+}
+
+func TestManager_Stateless(t *testing.T) {
+	if ok, _ := netutil.CanOpenRawSocket(); !ok {
+		t.Skip("needs raw socket privileges")
+	}
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	open := uint16(l.Addr().(*net.TCPAddr).Port)
+
+	mgr := NewManager(Config{
+		Targets:        []port.Target{{Name: "localhost", IP: "127.0.0.1"}, {Name: "loopback", IP: "127.0.0.1"}},
+		Ports:          []uint16{open, open + 1},
+		ScanStealth:    true,
+		Stateless:      true,
+		StealthTimeout: 100 * time.Millisecond,
+	})
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for r := range out {
+		got[r.Target+"/"+r.State] = r.Error
+	}
+	// Both names share the address; the next port up is most likely closed.
+	for _, want := range []string{"localhost/open", "loopback/open"} {
+		if _, ok := got[want]; !ok {
+			t.Errorf("missing %s in %v", want, got)
+		}
+	}
+	if snap := mgr.Stats(); snap.Probes != snap.Total || snap.Open != 1 {
+		t.Errorf("stats %+v: want one open port and a total matching the answers", snap)
+	}
+
+	if _, err := NewManager(Config{Targets: []port.Target{{Name: "x", IP: "127.0.0.1"}}, Ports: []uint16{1}, ScanTCP: true, Stateless: true}).Run(context.Background()); err == nil {
+		t.Error("a stateless tcp scan should be rejected")
+	}
+}
+
+func TestSYNEngine_Deliver(t *testing.T) {
+	local, target := net.IPv4(192, 0, 2, 2).To4(), net.IPv4(192, 0, 2, 10).To4()
+	e := &synEngine{local: local, srcPort: 40000, waiting: make(map[synKey]*synWaiter)}
+	w := &synWaiter{seq: 1000, reply: make(chan synReply, 1)}
+	key := synKey{ip: [4]byte(target), port: 443}
+	e.waiting[key] = w
+	reply := func(dst net.IP, ack uint32) []byte {
+		pkt, err := rawsock.TCPSegment{
+			Src: target, Dst: dst, SrcPort: 443, DstPort: 40000,
+			Ack: ack, Flags: rawsock.FlagSYN | rawsock.FlagACK,
+		}.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pkt
+	}
+	now := time.Now()
+	e.deliver(reply(net.IPv4(192, 0, 2, 3), 1001), now) // to another address
+	e.deliver(reply(local, 5001), now)                  // acknowledges another probe
+	if len(w.reply) != 0 || e.strays.Load() != 1 {
+		t.Fatalf("misattributed replies: %d delivered, %d strays", len(w.reply), e.strays.Load())
+	}
+	e.deliver(reply(local, 1001), now)
+	e.deliver(reply(local, 1001), now.Add(time.Second)) // retransmitted SYN/ACK
+	if len(w.reply) != 1 || e.dups.Load() != 1 || e.strays.Load() != 1 {
+		t.Fatalf("%d delivered, %d duplicates, %d strays; want 1, 1, 1", len(w.reply), e.dups.Load(), e.strays.Load())
+	}
+	e.deliver(reply(local, 1001), now.Add(3*synDupWindow))
+	if e.dups.Load() != 1 || e.strays.Load() != 2 {
+		t.Errorf("a reply long after the answer is not a duplicate: %d duplicates, %d strays", e.dups.Load(), e.strays.Load())
+	}
+}
+
+func TestSYNShards_Pick(t *testing.T) {
+	s := synShards{{srcPort: 1}, {srcPort: 2}, {srcPort: 3}, {srcPort: 4}}
+	used := make(map[uint16]int)
+	for i := 0; i < 64; i++ {
+		ip := net.IPv4(10, 0, 0, byte(i))
+		e := s.pick(ip, 443)
+		if s.pick(ip, 443) != e {
+			t.Fatal("the same address and port must map to the same engine")
+		}
+		used[e.srcPort]++
+	}
+	for _, e := range s {
+		if used[e.srcPort] == 0 {
+			t.Errorf("engine %d got no probes: %v", e.srcPort, used)
+		}
+	}
+}
+
+func TestManager_StealthShards(t *testing.T) {
+	if ok, _ := netutil.CanOpenRawSocket(); !ok {
+		t.Skip("needs raw socket privileges")
+	}
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	open := uint16(l.Addr().(*net.TCPAddr).Port)
+
+	mgr := NewManager(Config{
+		Targets:        []port.Target{{Name: "localhost", IP: "127.0.0.1"}},
+		Ports:          []uint16{open, open + 1, open + 2, open + 3},
+		ScanStealth:    true,
+		Workers:        4,
+		StealthTimeout: time.Second,
+		SYNShards:      3,
+		PinCPUs:        []int{0},
+	})
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for r := range out {
+		n++
+		if r.Port == open && r.State != "open" {
+			t.Errorf("port %d: %q (%s), want open", r.Port, r.State, r.Error)
+		}
+		if r.State == "filtered" {
+			t.Errorf("port %d went unanswered: a shard lost its reply", r.Port)
+		}
+	}
+	if n != 4 {
+		t.Errorf("got %d results, want 4", n)
+	}
+
+	if _, err := NewManager(Config{Targets: []port.Target{{Name: "x", IP: "127.0.0.1"}}, Ports: []uint16{1}, ScanStealth: true, SYNShards: MaxSYNShards + 1}).Run(context.Background()); err == nil {
+		t.Error("too many shards should be rejected")
+	}
+}
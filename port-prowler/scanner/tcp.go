@@ -2,23 +2,55 @@ package scanner
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"portprowler/logging"
+	"portprowler/netutil"
 	"portprowler/port"
+	"portprowler/wire"
 )
 
 // TCPScan performs a TCP connect scan to the specified IP and port using the provided timeout.
 // It returns a PortResult populated with proto="tcp", State {open|closed|filtered}, and RTTMillis.
 func TCPScan(ctx context.Context, ip string, portNum uint16, timeout time.Duration, verbose bool) port.PortResult {
-	addr := fmt.Sprintf("%s:%d", ip, portNum)
+	return TCPScanVia(ctx, nil, ip, portNum, timeout, verbose)
+}
+
+// TCPScanVia is TCPScan connecting through d (e.g. a proxy chain); a nil d
+// connects directly. A broken proxy hop yields State "unknown" with the
+// failing hop in Error, since it says nothing about the target port.
+func TCPScanVia(ctx context.Context, d netutil.ContextDialer, ip string, portNum uint16, timeout time.Duration, verbose bool) port.PortResult {
+	return tcpScan(ctx, d, ip, portNum, timeout, 0, false, verbose)
+}
+
+// defaultGreetingLimit is how much of a greeting connect probes read when
+// no banner limit is configured.
+const defaultGreetingLimit = 1024
+
+// tcpScan is TCPScanVia reading at most bannerLimit bytes of greeting
+// (zero uses defaultGreetingLimit). With rstClose, direct connections are
+// closed with a RST (SO_LINGER 0) instead of a FIN.
+func tcpScan(ctx context.Context, d netutil.ContextDialer, ip string, portNum uint16, timeout time.Duration, bannerLimit int, rstClose, verbose bool) port.PortResult {
+	if bannerLimit <= 0 {
+		bannerLimit = defaultGreetingLimit
+	}
+	addr := net.JoinHostPort(ip, strconv.Itoa(int(portNum)))
+	// Through a proxy the socket leads to the proxy, which must not be reset.
+	rstClose = rstClose && d == nil
+	if d == nil {
+		d = &net.Dialer{}
+	}
+	dctx, cancel := context.WithTimeout(ctx, timeout)
 	start := time.Now()
-	conn, err := net.DialTimeout("tcp", addr, timeout)
+	conn, err := d.DialContext(dctx, "tcp", addr)
 	rtt := time.Since(start)
+	cancel()
 
 	res := port.PortResult{
 		IP:        ip,
@@ -33,34 +65,61 @@ func TCPScan(ctx context.Context, ip string, portNum uint16, timeout time.Durati
 		res.State = "open"
 		// Try to read a small banner (non-blocking-ish using a short deadline).
 		if conn != nil {
+			res.LocalAddr = conn.LocalAddr().String()
 			// set small read deadline (min(timeout, 500ms))
 			bannerTimeout := 500 * time.Millisecond
 			if timeout > 0 && timeout < bannerTimeout {
 				bannerTimeout = timeout
 			}
 			_ = conn.SetReadDeadline(time.Now().Add(bannerTimeout))
-			buf := make([]byte, 1024)
+			buf := make([]byte, bannerLimit)
 			n, _ := conn.Read(buf)
 			if n > 0 {
-				res.ServiceBanner = strings.TrimSpace(string(buf[:n]))
+				res.ServiceBanner = wire.ParseBanner(buf[:n])
+				res.BannerRaw = buf[:n:n]
 				if verbose {
-					fmt.Printf("[verbose] tcp banner %s -> %q\n", addr, res.ServiceBanner)
+					logging.Verbosef("tcp banner %s -> %q\n", addr, res.ServiceBanner)
 				}
 			}
+			if tc, ok := conn.(*net.TCPConn); ok && rstClose {
+				_ = tc.SetLinger(0)
+			}
 			_ = conn.Close()
 		}
 		if verbose {
-			fmt.Printf("[verbose] tcp connect success %s rtt=%dms\n", addr, res.RTTMillis)
+			logging.Verbosef("tcp connect success %s rtt=%dms\n", addr, res.RTTMillis)
 		}
 		return res
 	}
 
 	// classify error
+	var hopErr *netutil.HopError
+	if errors.As(err, &hopErr) {
+		res.State = "unknown"
+		res.Error = hopErr.Error()
+		if verbose {
+			logging.Verbosef("tcp proxy error %s: %v", addr, hopErr)
+		}
+		return res
+	}
+	var targetErr *netutil.TargetError
+	if errors.As(err, &targetErr) {
+		res.State = "filtered"
+		res.Error = targetErr.Error()
+		if targetErr.Refused {
+			res.State = "closed"
+			res.Error = "connection refused"
+		}
+		if verbose {
+			logging.Verbosef("tcp %s %s: %v", res.State, addr, targetErr)
+		}
+		return res
+	}
 	if ne, ok := err.(net.Error); ok && ne.Timeout() {
 		res.State = "filtered"
 		res.Error = "timeout"
 		if verbose {
-			fmt.Printf("[verbose] tcp timeout %s\n", addr)
+			logging.Verbosef("tcp timeout %s\n", addr)
 		}
 		return res
 	}
@@ -73,7 +132,7 @@ func TCPScan(ctx context.Context, ip string, portNum uint16, timeout time.Durati
 				res.State = "closed"
 				res.Error = "connection refused"
 				if verbose {
-					fmt.Printf("[verbose] tcp conn refused %s\n", addr)
+					logging.Verbosef("tcp conn refused %s\n", addr)
 				}
 				return res
 			}
@@ -84,7 +143,7 @@ func TCPScan(ctx context.Context, ip string, portNum uint16, timeout time.Durati
 				res.State = "closed"
 				res.Error = "connection refused"
 				if verbose {
-					fmt.Printf("[verbose] tcp conn refused %s\n", addr)
+					logging.Verbosef("tcp conn refused %s\n", addr)
 				}
 				return res
 			}
@@ -98,7 +157,7 @@ func TCPScan(ctx context.Context, ip string, portNum uint16, timeout time.Durati
 			res.State = "closed"
 			res.Error = errStr
 			if verbose {
-				fmt.Printf("[verbose] tcp error (assume closed) %s: %s\n", addr, errStr)
+				logging.Verbosef("tcp error (assume closed) %s: %s\n", addr, errStr)
 			}
 			return res
 		}
@@ -108,7 +167,7 @@ func TCPScan(ctx context.Context, ip string, portNum uint16, timeout time.Durati
 	res.State = "filtered"
 	res.Error = err.Error()
 	if verbose {
-		fmt.Printf("[verbose] tcp error %s: %v\n", addr, err)
+		logging.Verbosef("tcp error %s: %v\n", addr, err)
 	}
 	return res
 }
@@ -7,11 +7,15 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"portprowler/logging"
+	"portprowler/netutil"
 	"portprowler/port"
+	"portprowler/wire"
 )
 
 // UDPScan performs a UDP probe to the specified IP and port using the provided timeout.
@@ -20,7 +24,20 @@ import (
 //   - ICMP port-unreachable surfaced as connection-refused -> "closed"
 //   - timeout / no response -> "open|filtered"
 func UDPScan(ctx context.Context, ip string, portNum uint16, timeout time.Duration, verbose bool) port.PortResult {
-	addr := fmt.Sprintf("%s:%d", ip, portNum)
+	return UDPScanVia(ctx, nil, ip, portNum, timeout, verbose)
+}
+
+// UDPScanVia is UDPScan opening its socket with d (e.g. a fake network in
+// tests); a nil d uses the real network.
+func UDPScanVia(ctx context.Context, d netutil.ContextDialer, ip string, portNum uint16, timeout time.Duration, verbose bool) port.PortResult {
+	return udpScan(ctx, d, ip, portNum, DefaultDNSQuery, timeout, verbose, nil)
+}
+
+// udpScan is UDPScanVia sending q to port 53 that, with keep, hands the
+// socket of a probe that timed out to keep instead of closing it, so a
+// late reply can still be read. keep must not block.
+func udpScan(ctx context.Context, d netutil.ContextDialer, ip string, portNum uint16, q DNSQuery, timeout time.Duration, verbose bool, keep func(conn net.Conn, dnsTXID uint16, sent time.Time)) port.PortResult {
+	addr := net.JoinHostPort(ip, strconv.Itoa(int(portNum)))
 	res := port.PortResult{
 		IP:        ip,
 		Port:      portNum,
@@ -29,55 +46,42 @@ func UDPScan(ctx context.Context, ip string, portNum uint16, timeout time.Durati
 		RTTMillis: 0,
 	}
 
-	raddr, err := net.ResolveUDPAddr("udp", addr)
-	if err != nil {
-		res.Error = err.Error()
-		if verbose {
-			fmt.Printf("[verbose] udp resolve error %s: %v\n", addr, err)
-		}
-		return res
+	if d == nil {
+		d = &net.Dialer{}
 	}
-
-	conn, err := net.DialUDP("udp", nil, raddr)
+	conn, err := d.DialContext(ctx, "udp", addr)
 	if err != nil {
 		if strings.Contains(err.Error(), "connection refused") || isConnRefusedErr(err) {
 			res.State = "closed"
 			res.Error = err.Error()
 			if verbose {
-				fmt.Printf("[verbose] udp dial conn refused %s: %v\n", addr, err)
+				logging.Verbosef("udp dial conn refused %s: %v\n", addr, err)
 			}
 			return res
 		}
 		res.Error = err.Error()
 		if verbose {
-			fmt.Printf("[verbose] udp dial error %s: %v\n", addr, err)
+			logging.Verbosef("udp dial error %s: %v\n", addr, err)
 		}
 		return res
 	}
-	defer conn.Close()
+	kept := false
+	defer func() {
+		if !kept {
+			conn.Close()
+		}
+	}()
+	res.LocalAddr = conn.LocalAddr().String()
 
 	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
 		res.Error = err.Error()
 		if verbose {
-			fmt.Printf("[verbose] udp setdeadline error %s: %v\n", addr, err)
+			logging.Verbosef("udp setdeadline error %s: %v\n", addr, err)
 		}
 		return res
 	}
 
-	// Choose probe payload.
-	var payload []byte
-	var dnsTXID uint16
-	if portNum == 53 {
-		var perr error
-		payload, dnsTXID, perr = buildDNSQueryA("example.com")
-		if perr != nil {
-			// fallback to a single byte if DNS query build fails (shouldn't happen)
-			payload = []byte{0x00}
-		}
-	} else {
-		// generic probe: single zero byte
-		payload = []byte{0x00}
-	}
+	payload, dnsTXID := udpProbe(portNum, q)
 
 	start := time.Now()
 	_, err = conn.Write(payload)
@@ -86,13 +90,13 @@ func UDPScan(ctx context.Context, ip string, portNum uint16, timeout time.Durati
 			res.State = "closed"
 			res.Error = err.Error()
 			if verbose {
-				fmt.Printf("[verbose] udp write conn refused %s: %v\n", addr, err)
+				logging.Verbosef("udp write conn refused %s: %v\n", addr, err)
 			}
 			return res
 		}
 		res.Error = err.Error()
 		if verbose {
-			fmt.Printf("[verbose] udp write error %s: %v\n", addr, err)
+			logging.Verbosef("udp write error %s: %v\n", addr, err)
 		}
 		return res
 	}
@@ -105,10 +109,11 @@ func UDPScan(ctx context.Context, ip string, portNum uint16, timeout time.Durati
 	if err == nil && n > 0 {
 		// If this is DNS, validate response shape and TXID to reduce false positives.
 		if portNum == 53 {
-			if isValidDNSResponse(buf[:n], dnsTXID) {
+			if msg, ok := parseDNSReply(buf[:n], dnsTXID); ok {
 				res.State = "open"
+				res.DNS = dnsInfo(q, msg)
 				if verbose {
-					fmt.Printf("[verbose] udp dns response %d bytes from %s rtt=%dms\n", n, addr, res.RTTMillis)
+					logging.Verbosef("udp dns response %d bytes from %s rtt=%dms rcode=%s recursion=%v\n", n, addr, res.RTTMillis, res.DNS.RCode, res.DNS.Recursion)
 				}
 				return res
 			}
@@ -117,7 +122,7 @@ func UDPScan(ctx context.Context, ip string, portNum uint16, timeout time.Durati
 			res.State = "open"
 			res.Error = "dns response not validated"
 			if verbose {
-				fmt.Printf("[verbose] udp got %d bytes from %s but dns validation failed rtt=%dms\n", n, addr, res.RTTMillis)
+				logging.Verbosef("udp got %d bytes from %s but dns validation failed rtt=%dms\n", n, addr, res.RTTMillis)
 			}
 			return res
 		}
@@ -125,7 +130,7 @@ func UDPScan(ctx context.Context, ip string, portNum uint16, timeout time.Durati
 		// Generic UDP: any bytes -> open
 		res.State = "open"
 		if verbose {
-			fmt.Printf("[verbose] udp got %d bytes from %s rtt=%dms\n", n, addr, res.RTTMillis)
+			logging.Verbosef("udp got %d bytes from %s rtt=%dms\n", n, addr, res.RTTMillis)
 		}
 		return res
 	}
@@ -135,7 +140,11 @@ func UDPScan(ctx context.Context, ip string, portNum uint16, timeout time.Durati
 		res.State = "open|filtered"
 		res.Error = "timeout"
 		if verbose {
-			fmt.Printf("[verbose] udp timeout %s\n", addr)
+			logging.Verbosef("udp timeout %s\n", addr)
+		}
+		if keep != nil {
+			kept = true
+			keep(conn, dnsTXID, start)
 		}
 		return res
 	}
@@ -145,14 +154,14 @@ func UDPScan(ctx context.Context, ip string, portNum uint16, timeout time.Durati
 			res.State = "closed"
 			res.Error = err.Error()
 			if verbose {
-				fmt.Printf("[verbose] udp conn refused %s: %v\n", addr, err)
+				logging.Verbosef("udp conn refused %s: %v\n", addr, err)
 			}
 			return res
 		}
 		res.State = "open|filtered"
 		res.Error = err.Error()
 		if verbose {
-			fmt.Printf("[verbose] udp read error %s: %v\n", addr, err)
+			logging.Verbosef("udp read error %s: %v\n", addr, err)
 		}
 		return res
 	}
@@ -162,9 +171,23 @@ func UDPScan(ctx context.Context, ip string, portNum uint16, timeout time.Durati
 	return res
 }
 
-// buildDNSQueryA builds a minimal DNS query asking for A record of name.
-// Returns payload and transaction ID.
-func buildDNSQueryA(name string) ([]byte, uint16, error) {
+// udpProbe chooses the probe payload for portNum: the DNS query q for 53,
+// with its transaction id, and a single zero byte otherwise.
+func udpProbe(portNum uint16, q DNSQuery) (payload []byte, dnsTXID uint16) {
+	if portNum == 53 {
+		payload, dnsTXID, err := buildDNSQuery(q)
+		if err == nil {
+			return payload, dnsTXID
+		}
+		// fallback to a single byte if DNS query build fails (shouldn't happen)
+	}
+	return []byte{0x00}, 0
+}
+
+// buildDNSQuery builds a minimal DNS query for q, with recursion desired
+// unless q.NoRecurse. Returns payload and transaction ID.
+func buildDNSQuery(q DNSQuery) ([]byte, uint16, error) {
+	q = q.orDefault()
 	// TXID
 	var txidBytes [2]byte
 	if _, err := rand.Read(txidBytes[:]); err != nil {
@@ -176,20 +199,22 @@ func buildDNSQueryA(name string) ([]byte, uint16, error) {
 	// ID, Flags, QDCOUNT, ANCOUNT, NSCOUNT, ARCOUNT
 	hdr := make([]byte, 12)
 	binary.BigEndian.PutUint16(hdr[0:2], txid)
-	// flags: standard query, recursion desired
-	binary.BigEndian.PutUint16(hdr[2:4], 0x0100)
+	// flags: standard query, recursion desired (RD) unless turned off
+	if !q.NoRecurse {
+		binary.BigEndian.PutUint16(hdr[2:4], 0x0100)
+	}
 	binary.BigEndian.PutUint16(hdr[4:6], 1) // QDCOUNT=1
 
 	// QNAME: labels
-	qname, err := encodeDNSName(name)
+	qname, err := encodeDNSName(q.Name)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	// QTYPE=A (1), QCLASS=IN (1)
+	// QTYPE, QCLASS
 	qtail := make([]byte, 4)
-	binary.BigEndian.PutUint16(qtail[0:2], 1)
-	binary.BigEndian.PutUint16(qtail[2:4], 1)
+	binary.BigEndian.PutUint16(qtail[0:2], q.Type)
+	binary.BigEndian.PutUint16(qtail[2:4], q.Class)
 
 	payload := append(hdr, qname...)
 	payload = append(payload, qtail...)
@@ -218,21 +243,11 @@ func encodeDNSName(name string) ([]byte, error) {
 	return out, nil
 }
 
-// isValidDNSResponse does a minimal sanity check:
-// - at least 12 bytes (DNS header)
-// - TXID matches
-// - QR bit set (response)
-func isValidDNSResponse(pkt []byte, wantTXID uint16) bool {
-	if len(pkt) < 12 {
-		return false
-	}
-	gotTXID := binary.BigEndian.Uint16(pkt[0:2])
-	if gotTXID != wantTXID {
-		return false
-	}
-	flags := binary.BigEndian.Uint16(pkt[2:4])
-	qr := (flags & 0x8000) != 0
-	return qr
+// parseDNSReply parses pkt and reports whether it is a well-formed DNS
+// response (see wire.ParseDNSResponse) carrying our TXID.
+func parseDNSReply(pkt []byte, wantTXID uint16) (wire.DNSMessage, bool) {
+	m, err := wire.ParseDNSResponse(pkt)
+	return m, err == nil && m.Response && m.ID == wantTXID
 }
 
 // isConnRefusedErr attempts to detect connection-refused semantics from various error wrappers.
@@ -0,0 +1,121 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"portprowler/logging"
+	"portprowler/port"
+)
+
+// lateReply is what a UDP socket kept open for UDPGrace received after its
+// probe had been reported open|filtered.
+type lateReply struct {
+	state string // "open" or "closed"
+	rtt   time.Duration
+	dns   *port.DNSInfo // a valid answer to the DNS probe
+}
+
+// keepUDP returns the keep hook of udpScan for a probe to ip:portNum: the
+// socket is read for another UDPGrace, and a reply or port-unreachable
+// arriving in that time is recorded for Report. A late answer to the DNS
+// probe is parsed like a timely one.
+func (m *Manager) keepUDP(ip string, portNum uint16) func(net.Conn, uint16, time.Time) {
+	return func(conn net.Conn, dnsTXID uint16, sent time.Time) {
+		m.lateMu.Lock()
+		if m.lateConns == nil {
+			m.lateConns = make(map[net.Conn]bool)
+		}
+		m.lateConns[conn] = true
+		m.lateMu.Unlock()
+		m.lateWG.Add(1)
+		go func() {
+			defer m.lateWG.Done()
+			defer func() {
+				m.lateMu.Lock()
+				delete(m.lateConns, conn)
+				m.lateMu.Unlock()
+				conn.Close()
+			}()
+			if conn.SetDeadline(time.Now().Add(m.cfg.UDPGrace)) != nil {
+				return
+			}
+			buf := make([]byte, 4096)
+			n, err := conn.Read(buf)
+			var state string
+			switch {
+			case err == nil && n > 0:
+				state = "open"
+			case err != nil && isConnRefusedErr(err):
+				state = "closed"
+			default:
+				return
+			}
+			late := lateReply{state: state, rtt: time.Since(sent)}
+			valid := ""
+			if state == "open" && portNum == 53 {
+				if msg, ok := parseDNSReply(buf[:n], dnsTXID); ok {
+					late.dns = dnsInfo(m.cfg.DNSQuery, msg)
+				} else {
+					valid = " (dns validation failed)"
+				}
+			}
+			if m.cfg.Verbose {
+				logging.Verbosef("udp late response from %s after %dms: %s%s\n", net.JoinHostPort(ip, strconv.Itoa(int(portNum))), late.rtt.Milliseconds(), state, valid)
+			}
+			m.lateMu.Lock()
+			if m.late == nil {
+				m.late = make(map[string]lateReply)
+			}
+			m.late[lateKey(ip, portNum)] = late
+			m.lateMu.Unlock()
+		}()
+	}
+}
+
+func lateKey(ip string, portNum uint16) string {
+	return net.JoinHostPort(ip, strconv.Itoa(int(portNum)))
+}
+
+// waitLateUDP waits until every socket kept by keepUDP has been read or
+// its grace has run out. Cancelling ctx closes the sockets early.
+func (m *Manager) waitLateUDP(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		m.lateWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+	m.lateMu.Lock()
+	for c := range m.lateConns {
+		c.Close()
+	}
+	m.lateMu.Unlock()
+	<-done
+}
+
+// applyLateUDP upgrades the open|filtered UDP results that got a late
+// reply, with what a late DNS answer showed.
+func (m *Manager) applyLateUDP(results []port.PortResult) {
+	m.lateMu.Lock()
+	defer m.lateMu.Unlock()
+	if len(m.late) == 0 {
+		return
+	}
+	for i := range results {
+		r := &results[i]
+		if r.Proto != "udp" || r.State != "open|filtered" {
+			continue
+		}
+		if l, ok := m.late[lateKey(r.IP, r.Port)]; ok {
+			r.State, r.Error, r.RTTMillis = l.state, "late response", l.rtt.Milliseconds()
+			r.DNS = l.dns
+		}
+	}
+}
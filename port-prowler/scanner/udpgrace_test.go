@@ -0,0 +1,80 @@
+package scanner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"portprowler/port"
+	"portprowler/report"
+	"portprowler/testsupport"
+)
+
+func TestManager_UDPGrace(t *testing.T) {
+	fake := testsupport.NewNetwork()
+	fake.AddHost("192.0.2.1").
+		UDP(161, testsupport.Port{Reply: "late", Latency: 150 * time.Millisecond}).
+		UDP(162, testsupport.Port{Reply: "too late", Latency: 2 * time.Second})
+	mgr := NewManager(Config{
+		Targets:      []port.Target{{Name: "slow.example", IP: "192.0.2.1"}},
+		Ports:        []uint16{161, 162},
+		ScanUDP:      true,
+		Workers:      2,
+		UDPTimeout:   50 * time.Millisecond,
+		UDPGrace:     300 * time.Millisecond,
+		PacketDialer: fake,
+	})
+	start := time.Now()
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for r := range out {
+		if r.State != "open|filtered" {
+			t.Errorf("streamed %d/udp as %s, want open|filtered", r.Port, r.State)
+		}
+	}
+	if took := time.Since(start); took < 300*time.Millisecond {
+		t.Errorf("scan ended after %v, before the grace ran out", took)
+	}
+	rep := mgr.Report(report.Meta{})
+	states := map[uint16]port.PortResult{}
+	for _, r := range rep.Hosts[0].Results {
+		states[r.Port] = r
+	}
+	if r := states[161]; r.State != "open" || r.Error != "late response" || r.RTTMillis < 150 {
+		t.Errorf("161/udp = %s (%s, %dms), want open from a late response", r.State, r.Error, r.RTTMillis)
+	}
+	if r := states[162]; r.State != "open|filtered" {
+		t.Errorf("162/udp = %s, want open|filtered after the grace", r.State)
+	}
+}
+
+func TestManager_UDPGraceCancel(t *testing.T) {
+	fake := testsupport.NewNetwork()
+	fake.AddHost("192.0.2.1").UDP(161, testsupport.Port{Reply: "x", Latency: 5 * time.Second})
+	ctx, cancel := context.WithCancel(context.Background())
+	mgr := NewManager(Config{
+		Targets:      []port.Target{{Name: "slow.example", IP: "192.0.2.1"}},
+		Ports:        []uint16{161},
+		ScanUDP:      true,
+		Workers:      1,
+		UDPTimeout:   10 * time.Millisecond,
+		UDPGrace:     time.Minute,
+		PacketDialer: fake,
+	})
+	out, err := mgr.Run(ctx)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	<-out
+	cancel()
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Fatal("unexpected second result")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("cancelling did not end the grace")
+	}
+}
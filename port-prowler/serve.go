@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"portprowler/jsonrpc"
+	"portprowler/logging"
+	"portprowler/netutil"
+	"portprowler/port"
+	"portprowler/report"
+	"portprowler/server"
+)
+
+// runServe implements `portprowler serve`, the HTTP scan API (see package
+// server for the routes).
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := fs.String("listen", "127.0.0.1:8700", "address to serve the API on")
+	tokensPath := fs.String("tokens", "", "file of API tokens, one \"name role secret [cidr,...]\" per line; requests need one of them")
+	tlsCert := fs.String("tls-cert", "", "serve HTTPS with this PEM certificate (chain)")
+	tlsKey := fs.String("tls-key", "", "PEM private key for --tls-cert")
+	clientCA := fs.String("tls-client-ca", "", "also require client certificates signed by this PEM CA bundle")
+	maxScans := fs.Int("max-scans", 4, "scans to run at once; more wait in a queue (0: no limit)")
+	tenantScans := fs.Int("tenant-scans", 0, "scans to run at once per token (0: up to --max-scans)")
+	stateDir := fs.String("state-dir", "", "keep scans in this directory so they survive a restart; queued scans are queued again")
+	resume := fs.Bool("resume", false, "with --state-dir, run scans a restart interrupted again from the start instead of marking them interrupted")
+	openAPI := fs.Bool("openapi", false, "print the API's OpenAPI 3 document (with --tokens, requiring a token) and exit")
+	verbose := fs.Bool("v", false, "verbose logging")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s serve [flags]\n", progName())
+		fs.PrintDefaults()
+	}
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return parseStatus(err)
+	}
+	if len(names) > 0 {
+		return exitStatus(&exitError{code: 2, msg: "error: serve takes no arguments", usage: true}, fs)
+	}
+	if *maxScans < 0 || *tenantScans < 0 {
+		return exitStatus(usageErr("error: --max-scans and --tenant-scans must not be negative"), fs)
+	}
+	if *resume && *stateDir == "" {
+		return exitStatus(usageErr("error: --resume needs --state-dir"), fs)
+	}
+	if (*tlsCert == "") != (*tlsKey == "") {
+		return exitStatus(usageErr("error: --tls-cert and --tls-key go together"), fs)
+	}
+	if *clientCA != "" && *tlsCert == "" {
+		return exitStatus(usageErr("error: --tls-client-ca needs --tls-cert and --tls-key"), fs)
+	}
+	var tokens server.Tokens
+	if *tokensPath != "" {
+		if tokens, err = server.LoadTokens(*tokensPath); err != nil {
+			return exitStatus(usageErr("error: --tokens: %v", err), fs)
+		}
+	}
+	if *openAPI {
+		doc, err := server.OpenAPI(tokens != nil)
+		if err != nil {
+			return exitStatus(runtimeErr("failed to generate the OpenAPI document: %v", err), fs)
+		}
+		fmt.Println(string(doc))
+		return 0
+	}
+	var tlsConfig *tls.Config
+	if *clientCA != "" {
+		pem, err := os.ReadFile(*clientCA)
+		if err != nil {
+			return exitStatus(usageErr("error: --tls-client-ca: %v", err), fs)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return exitStatus(usageErr("error: --tls-client-ca: no certificates in %s", *clientCA), fs)
+		}
+		tlsConfig = &tls.Config{ClientCAs: pool, ClientAuth: tls.RequireAndVerifyClientCert}
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return exitStatus(runtimeErr("failed to listen on %s: %v", *listen, err), fs)
+	}
+	base := defaultScanFlags()
+	base.verbose = *verbose
+	api := server.New(func(req server.Request) (server.Job, error) {
+		return prepareScan(base, req, nil)
+	})
+	api.SetLimits(server.Limits{Scans: *maxScans, PerTenant: *tenantScans})
+	if tokens != nil {
+		api.RequireTokens(tokens)
+	} else if !isLoopback(ln.Addr()) {
+		logging.Warnf("the scan API on %s is open to anyone who can reach it; add --tokens", ln.Addr())
+	}
+	if *stateDir != "" {
+		if err := api.Persist(*stateDir, *resume); err != nil {
+			return exitStatus(runtimeErr("failed to load scans from --state-dir: %v", err), fs)
+		}
+	}
+	srv := &http.Server{Handler: api, ReadHeaderTimeout: 10 * time.Second, TLSConfig: tlsConfig}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+		_ = api.Shutdown(shutdown)
+	}()
+
+	if *tlsCert != "" {
+		logging.Infof("Serving the scan API on https://%s", ln.Addr())
+		err = srv.ServeTLS(ln, *tlsCert, *tlsKey)
+	} else {
+		logging.Infof("Serving the scan API on http://%s", ln.Addr())
+		err = srv.Serve(ln)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return exitStatus(runtimeErr("server failed: %v", err), fs)
+	}
+	<-stopped // let the scans stop and, with --state-dir, be saved
+	return 0
+}
+
+// prepareScan turns an API request into a scan plan, applying the same
+// validation as the scan command. Request fields override the settings in
+// base; onResult, when set, receives results as they are found.
+func prepareScan(base scanFlags, req server.Request, onResult func(port.PortResult)) (server.Job, error) {
+	f := base
+	if req.Ports != "" {
+		f.ports = req.Ports
+	}
+	if req.TCP || req.UDP || req.Stealth || req.Ping {
+		f.tcp, f.udp, f.stealth, f.ping = req.TCP, req.UDP, req.Stealth, req.Ping
+	}
+	f.serviceDetect = f.serviceDetect || req.ServiceDetect
+	f.osDetect = f.osDetect || req.OSDetect
+	if req.Note != "" {
+		f.note = req.Note
+	}
+	if req.Workers != 0 {
+		f.workers = req.Workers
+	}
+	if req.Timeout != "" {
+		d, err := time.ParseDuration(req.Timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q", req.Timeout)
+		}
+		f.timeout = d
+	}
+	p, err := f.plan(req.Targets)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTokenScope(req.Token, p.targets); err != nil {
+		return nil, err
+	}
+	p.cfg.OnResult = onResult
+	return func(ctx context.Context) (report.ScanReport, error) {
+		if w := server.ProgressWriter(ctx); w != nil {
+			p.cfg.Progress = w
+		}
+		if fn := server.ResultFunc(ctx); fn != nil {
+			p.cfg.OnResult = fn
+		}
+		rep, _, err := p.run(ctx)
+		if err != nil && err.Error() == "" {
+			err = errors.New("scan failed; see the server log")
+		}
+		return rep, err
+	}, nil
+}
+
+// checkTokenScope refuses targets outside the scope of the API token that
+// asked for them; unlike --scope, there is no --force for the client.
+func checkTokenScope(t *server.Token, targets []port.Target) error {
+	if t == nil || t.Scope == nil {
+		return nil
+	}
+	var outside []string
+	for _, tg := range targets {
+		if !t.Scope.Contains(netutil.ParseIP(tg.IP)) {
+			outside = append(outside, targetLabel(tg.Name, tg.IP))
+		}
+	}
+	if len(outside) > 0 {
+		return fmt.Errorf("%w: targets outside the scope of token %s: %s", server.ErrForbidden, t.Name, strings.Join(outside, ", "))
+	}
+	return nil
+}
+
+// isLoopback reports whether addr only accepts local connections.
+func isLoopback(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
+
+// serveJSONRPC implements --jsonrpc: scan requests arrive on stdin and
+// responses leave on stdout (see package jsonrpc). f holds the defaults
+// for every request.
+func serveJSONRPC(f scanFlags) error {
+	if f.replay != "" || f.fileOut != "" || len(f.outputs) > 0 || f.save || f.fingerprintOut != "" {
+		return usageErr("error: --jsonrpc answers on stdout; drop --replay, -f, -o, --save and --fingerprint-out")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err := jsonrpc.Serve(ctx, os.Stdin, os.Stdout, func(req server.Request, onResult func(port.PortResult)) (server.Job, error) {
+		return prepareScan(f, req, onResult)
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return runtimeErr("failed to read requests: %v", err)
+	}
+	return nil
+}
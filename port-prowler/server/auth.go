@@ -0,0 +1,200 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"portprowler/netutil"
+)
+
+// Token roles: read tokens may list and fetch scans, scan tokens may also
+// submit and cancel their own. A scan token is a tenant and sees only the
+// scans it submitted; a read token sees those whose targets all lie
+// inside its scope.
+const (
+	RoleRead = "read"
+	RoleScan = "scan"
+)
+
+// ErrForbidden marks Prepare errors the client is not allowed to get
+// past, such as targets outside its token's scope; they are reported as
+// 403 Forbidden.
+var ErrForbidden = errors.New("forbidden")
+
+// Token is an API client allowed by --tokens.
+type Token struct {
+	Name  string
+	Role  string
+	Scope netutil.Scope // networks it may scan; nil means any
+	hash  [sha256.Size]byte
+}
+
+// Tokens are the API clients; a server with none is open to everyone.
+type Tokens []*Token
+
+// ParseTokens reads a tokens file, one client per line:
+//
+//	name role secret [cidr,...]
+//
+// where role is read or scan, secret is the token itself or sha256:<hex>
+// of it, and the optional CIDR blocks and addresses limit the targets the
+// client may scan. Blank lines and anything after a # are ignored.
+func ParseTokens(r io.Reader) (Tokens, error) {
+	var ts Tokens
+	names := make(map[string]bool)
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: want name, role and secret", line)
+		}
+		t := &Token{Name: fields[0], Role: fields[1]}
+		if names[t.Name] {
+			return nil, fmt.Errorf("line %d: duplicate token name %q", line, t.Name)
+		}
+		names[t.Name] = true
+		if t.Role != RoleRead && t.Role != RoleScan {
+			return nil, fmt.Errorf("line %d: unknown role %q (read or scan)", line, t.Role)
+		}
+		if hexSum, ok := strings.CutPrefix(fields[2], "sha256:"); ok {
+			sum, err := hex.DecodeString(hexSum)
+			if err != nil || len(sum) != sha256.Size {
+				return nil, fmt.Errorf("line %d: invalid sha256 secret", line)
+			}
+			copy(t.hash[:], sum)
+		} else {
+			t.hash = sha256.Sum256([]byte(fields[2]))
+		}
+		if len(fields) > 3 {
+			cidrs := strings.ReplaceAll(strings.Join(fields[3:], ","), ",", "\n")
+			scope, err := netutil.ParseScope(strings.NewReader(cidrs))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: scope: %v", line, err)
+			}
+			t.Scope = scope
+		}
+		ts = append(ts, t)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(ts) == 0 {
+		return nil, errors.New("no tokens listed")
+	}
+	return ts, nil
+}
+
+// LoadTokens parses the tokens file at path.
+func LoadTokens(path string) (Tokens, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ts, err := ParseTokens(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return ts, nil
+}
+
+// lookup returns the token whose secret is presented, comparing hashes
+// in constant time.
+func (ts Tokens) lookup(secret string) *Token {
+	sum := sha256.Sum256([]byte(secret))
+	var found *Token
+	for _, t := range ts {
+		if subtle.ConstantTimeCompare(sum[:], t.hash[:]) == 1 {
+			found = t
+		}
+	}
+	return found
+}
+
+// named returns the token called name.
+func (ts Tokens) named(name string) *Token {
+	for _, t := range ts {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// authorize checks the request's bearer token for the role its method
+// needs. When the request must not go on it has already answered the
+// client and returns false.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) (*Token, bool) {
+	if len(s.tokens) == 0 {
+		return nil, true
+	}
+	secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	t := s.tokens.lookup(strings.TrimSpace(secret))
+	if !ok || t == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="portprowler"`)
+		writeError(w, http.StatusUnauthorized, "missing or unknown API token")
+		return nil, false
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && t.Role != RoleScan {
+		writeError(w, http.StatusForbidden, fmt.Sprintf("token %s may only read scans", t.Name))
+		return nil, false
+	}
+	return t, true
+}
+
+// canRead reports whether token may see sc: its own scans, and for read
+// tokens those whose targets all lie inside its scope. Without tokens
+// every scan is visible.
+func canRead(token *Token, sc *scan) bool {
+	if token == nil || sc.Tenant == token.Name {
+		return true
+	}
+	return token.Role == RoleRead && sc.inScope(token.Scope)
+}
+
+// inScope reports whether every target of sc lies inside scope, nil
+// meaning any. Addresses are checked as given, a hostname by the
+// addresses it was scanned at, so a scan of one is only inside a
+// scope once it has a report.
+func (sc *scan) inScope(scope netutil.Scope) bool {
+	if scope == nil {
+		return true
+	}
+	named := false
+	for _, t := range sc.Request.Targets {
+		host, _, _ := netutil.SplitTarget(t)
+		if ip := netutil.ParseIP(host); ip != nil {
+			if !scope.Contains(ip) {
+				return false
+			}
+			continue
+		}
+		named = true
+	}
+	if !named {
+		return true
+	}
+	if sc.Report == nil || len(sc.Report.Hosts) == 0 {
+		return false
+	}
+	for _, h := range sc.Report.Hosts {
+		for _, a := range append([]string{h.IP}, h.Addrs...) {
+			if !scope.Contains(netutil.ParseIP(a)) {
+				return false
+			}
+		}
+	}
+	return true
+}
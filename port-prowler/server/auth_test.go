@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"portprowler/report"
+)
+
+func TestParseTokens(t *testing.T) {
+	sum := sha256.Sum256([]byte("s3cret"))
+	ts, err := ParseTokens(strings.NewReader(fmt.Sprintf(`# API clients
+dashboard read  dash-token
+ci        scan  sha256:%s 192.0.2.0/24, 198.51.100.7
+`, hex.EncodeToString(sum[:]))))
+	if err != nil || len(ts) != 2 {
+		t.Fatalf("ParseTokens = %v, %v", ts, err)
+	}
+	if ts.lookup("dash-token") != ts[0] || ts.lookup("s3cret") != ts[1] || ts.lookup("sha256:"+hex.EncodeToString(sum[:])) != nil {
+		t.Error("lookup matched the wrong tokens")
+	}
+	if ci := ts[1]; ci.Role != RoleScan || len(ci.Scope) != 2 || !ci.Scope.Contains(net.ParseIP("192.0.2.9")) || ci.Scope.Contains(net.ParseIP("198.51.100.8")) {
+		t.Errorf("ci token = %+v", ci)
+	}
+
+	for _, bad := range []string{"", "a read", "a admin x", "a read x\na scan y", "a read sha256:abcd", "a scan x 10.0.0.0/33"} {
+		if _, err := ParseTokens(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseTokens(%q) should fail", bad)
+		}
+	}
+}
+
+func TestAuth(t *testing.T) {
+	ts, err := ParseTokens(strings.NewReader("viewer read v-token\nci scan c-token 192.0.2.0/24\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got *Token
+	s := New(func(req Request) (Job, error) {
+		got = req.Token
+		return fakePrepare(req)
+	})
+	s.RequireTokens(ts)
+	defer s.Shutdown(context.Background())
+
+	call := func(method, path, token, body string) int {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		return rec.Code
+	}
+	submit := `{"targets":["192.0.2.1"],"ports":"22"}`
+	for _, c := range []struct {
+		method, path, token string
+		want                int
+	}{
+		{"GET", "/scans", "", http.StatusUnauthorized},
+		{"GET", "/scans", "wrong", http.StatusUnauthorized},
+		{"GET", "/scans", "v-token", http.StatusOK},
+		{"POST", "/scans", "v-token", http.StatusForbidden},
+		{"POST", "/scans", "c-token", http.StatusAccepted},
+		{"DELETE", "/scans/1", "v-token", http.StatusForbidden},
+	} {
+		if code := call(c.method, c.path, c.token, submit); code != c.want {
+			t.Errorf("%s %s with %q = %d, want %d", c.method, c.path, c.token, code, c.want)
+		}
+	}
+	if got == nil || got.Name != "ci" {
+		t.Errorf("Prepare saw token %+v, want ci", got)
+	}
+
+	s = New(func(req Request) (Job, error) {
+		return nil, fmt.Errorf("%w: targets outside the scope of token %s", ErrForbidden, req.Token.Name)
+	})
+	s.RequireTokens(ts)
+	defer s.Shutdown(context.Background())
+	if code := call("POST", "/scans", "c-token", submit); code != http.StatusForbidden {
+		t.Errorf("out-of-scope submit = %d, want 403", code)
+	}
+}
+
+func TestTokenVisibility(t *testing.T) {
+	ts, err := ParseTokens(strings.NewReader("ci scan c-token 192.0.2.0/24\nops scan o-token\nlab read l-token 192.0.2.0/24\nall read a-token\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(func(req Request) (Job, error) {
+		return func(ctx context.Context) (report.ScanReport, error) {
+			var rep report.ScanReport
+			for _, tg := range req.Targets {
+				ip := tg
+				if tg == "in.example" {
+					ip = "192.0.2.80"
+				} else if tg == "out.example" {
+					ip = "198.51.100.80"
+				}
+				rep.Hosts = append(rep.Hosts, report.HostReport{Target: tg, IP: ip, Addrs: []string{ip}})
+			}
+			return rep, nil
+		}, nil
+	})
+	s.RequireTokens(ts)
+	defer s.Shutdown(context.Background())
+
+	call := func(method, path, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		return rec
+	}
+	for _, c := range []struct{ token, targets string }{
+		{"c-token", `"192.0.2.1"`},                // 1: inside lab's scope
+		{"o-token", `"198.51.100.1"`},             // 2: outside it
+		{"o-token", `"192.0.2.2","192.0.2.3"`},    // 3: inside
+		{"o-token", `"192.0.2.4","198.51.100.4"`}, // 4: partly outside
+		{"o-token", `"in.example"`},               // 5: resolved inside
+		{"o-token", `"out.example"`},              // 6: resolved outside
+		{"o-token", `"192.0.2.9:22"`},             // 7: inside
+	} {
+		if rec := call("POST", "/scans", c.token, `{"targets":[`+c.targets+`],"ports":"22"}`); rec.Code != http.StatusAccepted {
+			t.Fatalf("submit %s: %d %s", c.targets, rec.Code, rec.Body)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for id := 1; id <= 7; id++ {
+		for !strings.Contains(call("GET", fmt.Sprintf("/scans/%d", id), "a-token", "").Body.String(), `"status": "done"`) {
+			if time.Now().After(deadline) {
+				t.Fatalf("scan %d never finished", id)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	listed := func(token string) string {
+		var sts []Status
+		if err := json.Unmarshal(call("GET", "/scans", token, "").Body.Bytes(), &sts); err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, st := range sts {
+			ids = append(ids, st.ID)
+		}
+		return strings.Join(ids, ",")
+	}
+	for token, want := range map[string]string{"l-token": "1,3,5,7", "a-token": "1,2,3,4,5,6,7", "c-token": "1", "o-token": "2,3,4,5,6,7"} {
+		if got := listed(token); got != want {
+			t.Errorf("%s lists %s, want %s", token, got, want)
+		}
+	}
+	for _, c := range []struct {
+		path string
+		want int
+	}{
+		{"/scans/1", http.StatusOK},
+		{"/scans/2", http.StatusNotFound},
+		{"/scans/1/results", http.StatusOK},
+		{"/scans/2/results", http.StatusNotFound},
+		{"/scans/1/diff?base=3", http.StatusOK},
+		{"/scans/1/diff?base=2", http.StatusNotFound},
+		{"/scans/2/diff?base=1", http.StatusNotFound},
+	} {
+		if code := call("GET", c.path, "l-token", "").Code; code != c.want {
+			t.Errorf("GET %s with the lab token = %d, want %d", c.path, code, c.want)
+		}
+	}
+	if code := call("GET", "/scans/1", "o-token", "").Code; code != http.StatusNotFound {
+		t.Errorf("another tenant's scan = %d, want 404", code)
+	}
+	if code := call("DELETE", "/scans/1", "o-token", "").Code; code != http.StatusNotFound {
+		t.Errorf("cancelling another tenant's scan = %d, want 404", code)
+	}
+	if code := call("DELETE", "/scans/1", "c-token", "").Code; code != http.StatusConflict {
+		t.Errorf("cancelling an own finished scan = %d, want 409", code)
+	}
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"portprowler/port"
+	"portprowler/report"
+	"portprowler/version"
+)
+
+// OpenAPI returns the OpenAPI 3 document of the API. The schemas are
+// generated from the Go types the server encodes, so they cannot drift
+// from the wire format. With auth the operations require a bearer token,
+// as RequireTokens makes them.
+func OpenAPI(auth bool) ([]byte, error) {
+	g := &schemaGen{schemas: make(map[string]any), names: make(map[reflect.Type]string)}
+	errResp := func(desc string) any {
+		return map[string]any{"description": desc, "content": jsonContent(ref("Error"))}
+	}
+	g.schemas["Error"] = map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"error": map[string]any{"type": "string"}},
+		"required":   []string{"error"},
+	}
+	ok := func(desc string, schema any) any {
+		return map[string]any{"description": desc, "content": jsonContent(schema)}
+	}
+	idParam := map[string]any{"name": "id", "in": "path", "required": true, "schema": map[string]any{"type": "string"}}
+	status := g.schema(reflect.TypeOf(Status{}))
+
+	paths := map[string]any{
+		"/scans": map[string]any{
+			"get": map[string]any{
+				"operationId": "listScans",
+				"summary":     "List scans",
+				"description": "Every scan, oldest first, without the reports.",
+				"responses":   map[string]any{"200": ok("The scans.", map[string]any{"type": "array", "items": status})},
+			},
+			"post": map[string]any{
+				"operationId": "startScan",
+				"summary":     "Submit a scan",
+				"description": "Queues a scan; it runs when the concurrency limits allow.",
+				"requestBody": map[string]any{"required": true, "content": jsonContent(g.schema(reflect.TypeOf(Request{})))},
+				"responses": map[string]any{
+					"202": ok("The scan was queued or started; Location points to it.", status),
+					"400": errResp("The request is invalid."),
+					"503": errResp("The server is shutting down."),
+				},
+			},
+		},
+		"/scans/{id}": map[string]any{
+			"parameters": []any{idParam},
+			"get": map[string]any{
+				"operationId": "getScan",
+				"summary":     "Get a scan",
+				"description": "The scan's status, its progress while running and its report once done.",
+				"responses":   map[string]any{"200": ok("The scan.", status), "404": errResp("No such scan.")},
+			},
+			"delete": map[string]any{
+				"operationId": "cancelScan",
+				"summary":     "Cancel a queued or running scan",
+				"responses": map[string]any{
+					"204": map[string]any{"description": "The scan is being cancelled."},
+					"404": errResp("No such scan."),
+					"409": errResp("The scan is not queued or running."),
+				},
+			},
+		},
+		"/scans/{id}/results": map[string]any{
+			"parameters": []any{idParam},
+			"get": map[string]any{
+				"operationId": "streamResults",
+				"summary":     "Stream a scan's results",
+				"description": "One result per line: those found so far, then the rest as they are found, until the scan ends.",
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "The results, as JSON lines.",
+						"content":     map[string]any{"application/x-ndjson": map[string]any{"schema": g.schema(reflect.TypeOf(port.PortResult{}))}},
+					},
+					"404": errResp("No such scan."),
+				},
+			},
+		},
+		"/scans/{id}/diff": map[string]any{
+			"parameters": []any{idParam, map[string]any{
+				"name": "base", "in": "query", "required": true,
+				"description": "ID of the scan to compare against.",
+				"schema":      map[string]any{"type": "string"},
+			}},
+			"get": map[string]any{
+				"operationId": "getDiff",
+				"summary":     "Changes since another scan",
+				"responses": map[string]any{
+					"200": ok("The changes.", map[string]any{"type": "array", "items": g.schema(reflect.TypeOf(report.Change{}))}),
+					"400": errResp("base is missing."),
+					"404": errResp("No such scan."),
+					"409": errResp("A scan has no report."),
+				},
+			},
+		},
+	}
+	if auth {
+		for _, p := range paths {
+			for method, op := range p.(map[string]any) {
+				if method != "parameters" {
+					op.(map[string]any)["responses"].(map[string]any)["401"] = errResp("The API token is missing or unknown.")
+				}
+			}
+		}
+		forbidden := func(path, method, description string) {
+			paths[path].(map[string]any)[method].(map[string]any)["responses"].(map[string]any)["403"] = errResp(description)
+		}
+		forbidden("/scans", "post", "The token is a read token, or a target lies outside its scope.")
+		forbidden("/scans/{id}", "delete", "The token is a read token.")
+	}
+
+	doc := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "portprowler scan API",
+			"version": version.Get().Version,
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": g.schemas},
+	}
+	if auth {
+		doc["components"].(map[string]any)["securitySchemes"] = map[string]any{
+			"token": map[string]any{"type": "http", "scheme": "bearer"},
+		}
+		doc["security"] = []any{map[string]any{"token": []string{}}}
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func (s *Server) openAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, "GET")
+		return
+	}
+	doc, err := OpenAPI(len(s.tokens) > 0)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(append(doc, '\n'))
+}
+
+func jsonContent(schema any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// schemaGen derives JSON schemas from Go types as encoding/json encodes
+// them. Named structs become components, referenced by their type name.
+type schemaGen struct {
+	schemas map[string]any
+	names   map[reflect.Type]string
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGen) schema(t reflect.Type) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return map[string]any{"type": "string", "format": "byte"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = t.Name()
+			if _, taken := g.schemas[name]; taken {
+				name = strings.ReplaceAll(t.PkgPath(), "/", ".") + "." + name
+			}
+			g.names[t] = name
+			g.schemas[name] = nil // reserve the name for recursive types
+			g.schemas[name] = g.object(t)
+		}
+		return ref(name)
+	}
+	return map[string]any{}
+}
+
+// object is the schema of a struct's encoded fields. Fields without
+// omitempty are always present and so required.
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	var required []string
+	var fields func(t reflect.Type)
+	fields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+				fields(f.Type)
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = g.schema(f.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+	}
+	fields(t)
+	obj := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	return obj
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var regexpRefs = regexp.MustCompile(`"#/components/schemas/([^"]+)"`)
+
+func TestOpenAPI(t *testing.T) {
+	s := New(fakePrepare)
+	ts, _ := ParseTokens(strings.NewReader("ci scan c-token\n"))
+	s.RequireTokens(ts)
+	rec, _ := do(t, s, "GET", "/openapi.json", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json = %d", rec.Code)
+	}
+	var doc struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Security   []map[string][]string                 `json:"security"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+				Required   []string                   `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.0.3" || len(doc.Security) != 1 {
+		t.Errorf("openapi %q, security %v", doc.OpenAPI, doc.Security)
+	}
+	for _, p := range []string{"/scans", "/scans/{id}", "/scans/{id}/results", "/scans/{id}/diff"} {
+		if doc.Paths[p] == nil {
+			t.Errorf("path %s missing", p)
+		}
+	}
+	if !strings.Contains(string(doc.Paths["/scans"]["post"]), `"401"`) {
+		t.Error("operations should answer 401 with tokens")
+	}
+	if !strings.Contains(string(doc.Paths["/scans/{id}"]["delete"]), `"403"`) || strings.Contains(string(doc.Paths["/scans/{id}"]["get"]), `"403"`) {
+		t.Error("only changing a scan should answer 403 to read tokens")
+	}
+
+	// Every reference resolves, and the schemas follow the json tags.
+	for _, m := range regexpRefs.FindAllStringSubmatch(rec.Body.String(), -1) {
+		if _, ok := doc.Components.Schemas[m[1]]; !ok {
+			t.Errorf("dangling $ref %s", m[1])
+		}
+	}
+	req := doc.Components.Schemas["Request"]
+	if req.Properties["service_detect"] == nil || req.Properties["Token"] != nil || req.Properties["token"] != nil {
+		t.Errorf("Request properties = %v", req.Properties)
+	}
+	if !strings.Contains(strings.Join(req.Required, " "), "targets") || strings.Contains(strings.Join(req.Required, " "), "note") {
+		t.Errorf("Request required = %v", req.Required)
+	}
+	if hosts := string(doc.Components.Schemas["ScanReport"].Properties["hosts"]); !strings.Contains(hosts, "HostReport") {
+		t.Errorf("ScanReport.hosts = %s", hosts)
+	}
+
+	if open, err := OpenAPI(false); err != nil || strings.Contains(string(open), "securitySchemes") {
+		t.Errorf("OpenAPI(false) should have no security: %v", err)
+	} else if strings.Contains(string(open), `"403"`) {
+		t.Error("OpenAPI(false) should not answer 403")
+	}
+}
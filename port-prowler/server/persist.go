@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"portprowler/logging"
+	"portprowler/output"
+)
+
+// Persist keeps every scan in dir as <id>.json, written on each change
+// of state, and takes over the scans a previous server left there.
+// Finished scans are listed again; queued scans are prepared again and
+// queued. Scans that were running when the server stopped are marked
+// Interrupted, or with resume queued to run again from the start. Call
+// it after RequireTokens and SetLimits and before serving.
+func (s *Server) Persist(dir string, resume bool) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var loaded []*scan
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		if _, err := strconv.Atoi(id); err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		sc := &scan{}
+		if err := json.Unmarshal(data, &sc.Status); err != nil || sc.ID != id {
+			return fmt.Errorf("%s: not a saved scan", filepath.Join(dir, e.Name()))
+		}
+		loaded = append(loaded, sc)
+	}
+	sort.Slice(loaded, func(i, j int) bool {
+		a, _ := strconv.Atoi(loaded[i].ID)
+		b, _ := strconv.Atoi(loaded[j].ID)
+		return a < b
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dir = dir
+	for _, sc := range loaded {
+		n, _ := strconv.Atoi(sc.ID)
+		if n > s.seq {
+			s.seq = n
+		}
+		sc.cancel = func() {}
+		s.scans[sc.ID] = sc
+		switch {
+		case sc.State == Queued, resume && (sc.State == Running || sc.State == Interrupted):
+			s.requeue(sc)
+		case sc.State == Running:
+			now := time.Now().UTC()
+			sc.State, sc.Finished = Interrupted, &now
+			s.save(sc)
+		}
+	}
+	s.dispatch()
+	return nil
+}
+
+// requeue prepares a loaded scan again and queues it, or fails it when
+// it can no longer be prepared. s.mu must be held.
+func (s *Server) requeue(sc *scan) {
+	fail := func(msg string) {
+		now := time.Now().UTC()
+		sc.State, sc.Error, sc.Finished = Failed, msg, &now
+		s.save(sc)
+	}
+	req := sc.Request
+	if len(s.tokens) > 0 {
+		if req.Token = s.tokens.named(sc.Tenant); req.Token == nil || req.Token.Role != RoleScan {
+			fail(fmt.Sprintf("token %s may no longer submit scans", sc.Tenant))
+			return
+		}
+	}
+	job, err := s.prepare(req)
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	sc.ctx, sc.cancel = context.WithCancel(context.Background())
+	sc.job = job
+	sc.State, sc.Started, sc.Finished, sc.Error, sc.Report = Queued, nil, nil, "", nil
+	s.queue = append(s.queue, sc)
+	s.save(sc)
+}
+
+// save writes sc to the state directory, if any. s.mu must be held.
+func (s *Server) save(sc *scan) {
+	if s.dir == "" {
+		return
+	}
+	data, err := json.MarshalIndent(sc.Status, "", "  ")
+	if err == nil {
+		err = output.WriteAtomic(filepath.Join(s.dir, sc.ID+".json"), append(data, '\n'))
+	}
+	if err != nil {
+		logging.Warnf("failed to save scan %s: %v", sc.ID, err)
+	}
+}
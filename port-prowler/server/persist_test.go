@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPersist(t *testing.T) {
+	dir := t.TempDir()
+	s := New(fakePrepare)
+	s.SetLimits(Limits{Scans: 1})
+	if err := s.Persist(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	do(t, s, "POST", "/scans", `{"targets":["192.0.2.1"],"ports":"22"}`)
+	wait(t, s, "1", Done)
+	do(t, s, "POST", "/scans", `{"targets":["192.0.2.1"],"ports":"22","note":"block"}`)
+	do(t, s, "POST", "/scans", `{"targets":["192.0.2.1"],"ports":"80"}`)
+	wait(t, s, "2", Running)
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// A crashed server leaves its running scans behind as running.
+	os.WriteFile(filepath.Join(dir, "4.json"), []byte(`{"id":"4","status":"running","request":{"targets":["192.0.2.1"],"ports":"443"}}`), 0o600)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0o600)
+
+	quick := func(req Request) (Job, error) {
+		req.Note = ""
+		return fakePrepare(req)
+	}
+	s = New(quick)
+	if err := s.Persist(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if st := wait(t, s, "1", Done); st.Report == nil {
+		t.Error("finished scan lost its report")
+	}
+	wait(t, s, "2", Interrupted)
+	wait(t, s, "4", Interrupted)
+	if st := wait(t, s, "3", Done); st.Report.Meta.PortSpec != "80" {
+		t.Errorf("queued scan ran with %+v", st.Report.Meta)
+	}
+	if _, st := do(t, s, "POST", "/scans", `{"targets":["192.0.2.1"],"ports":"22"}`); st.ID != "5" {
+		t.Errorf("new scan got id %s, want 5", st.ID)
+	}
+	wait(t, s, "5", Done)
+	s.Shutdown(context.Background())
+
+	s = New(quick)
+	if err := s.Persist(dir, true); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Shutdown(context.Background())
+	wait(t, s, "2", Done)
+	if st := wait(t, s, "4", Done); st.Report.Meta.PortSpec != "443" {
+		t.Errorf("resumed scan ran with %+v", st.Report.Meta)
+	}
+
+	os.WriteFile(filepath.Join(dir, "9.json"), []byte("{"), 0o600)
+	if err := New(quick).Persist(dir, false); err == nil {
+		t.Error("a corrupt scan file should fail Persist")
+	}
+}
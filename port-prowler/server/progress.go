@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"portprowler/stats"
+)
+
+type progressKey struct{}
+
+// ProgressWriter returns where a Job running on the server should write
+// its progress events (scanner.Config.Progress), or nil outside the
+// server. The latest event is shown in the scan's status.
+func ProgressWriter(ctx context.Context) io.Writer {
+	w, _ := ctx.Value(progressKey{}).(*progressWriter)
+	if w == nil {
+		return nil
+	}
+	return w
+}
+
+type progressWriter struct {
+	s  *Server
+	sc *scan
+}
+
+// Write takes one progress event per call, as the scanner writes them.
+func (w *progressWriter) Write(p []byte) (int, error) {
+	var ev stats.Progress
+	if err := json.Unmarshal(p, &ev); err != nil {
+		return 0, err
+	}
+	w.s.mu.Lock()
+	if w.sc.State == Running {
+		w.sc.Progress = &ev
+	}
+	w.s.mu.Unlock()
+	return len(p), nil
+}
@@ -0,0 +1,124 @@
+package server
+
+import "time"
+
+// Limits bound how many scans run at once; 0 means no limit. Scans over
+// either limit wait in the queue.
+type Limits struct {
+	Scans     int // across all tenants
+	PerTenant int // for each tenant
+}
+
+// SetLimits sets the concurrency limits. Call it before serving.
+func (s *Server) SetLimits(l Limits) {
+	s.limits = l
+}
+
+// dispatch starts queued scans while the limits allow. When a slot is
+// free it goes to the tenant with the fewest running scans, the one that
+// has waited longest for its turn on a tie, and within that tenant to its
+// oldest queued scan; so one tenant's pile of scans cannot hold back
+// another tenant's first. s.mu must be held.
+func (s *Server) dispatch() {
+	for !s.closed && (s.limits.Scans == 0 || s.runningTotal() < s.limits.Scans) {
+		var next *scan
+		seen := make(map[string]bool)
+		for _, sc := range s.queue {
+			if seen[sc.Tenant] {
+				continue
+			}
+			seen[sc.Tenant] = true
+			if s.limits.PerTenant > 0 && s.running[sc.Tenant] >= s.limits.PerTenant {
+				continue
+			}
+			if next == nil || s.before(sc.Tenant, next.Tenant) {
+				next = sc
+			}
+		}
+		if next == nil {
+			return
+		}
+		s.start(next)
+	}
+}
+
+// before reports whether tenant a is due a slot before tenant b.
+func (s *Server) before(a, b string) bool {
+	if s.running[a] != s.running[b] {
+		return s.running[a] < s.running[b]
+	}
+	return s.turn[a] < s.turn[b]
+}
+
+func (s *Server) runningTotal() int {
+	n := 0
+	for _, c := range s.running {
+		n += c
+	}
+	return n
+}
+
+// dequeue takes a queued scan out of the queue and finishes it in state.
+// s.mu must be held.
+func (s *Server) dequeue(sc *scan, state string) {
+	s.unqueue(sc)
+	now := time.Now().UTC()
+	sc.State, sc.Finished = state, &now
+	sc.cancel()
+	s.notify(sc)
+	s.save(sc)
+}
+
+func (s *Server) unqueue(sc *scan) {
+	for i, q := range s.queue {
+		if q == sc {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// start runs a queued scan in the background. s.mu must be held.
+func (s *Server) start(sc *scan) {
+	s.unqueue(sc)
+	s.starts++
+	s.turn[sc.Tenant] = s.starts
+	s.running[sc.Tenant]++
+	now := time.Now().UTC()
+	sc.State, sc.Started = Running, &now
+	s.save(sc)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer sc.cancel()
+		rep, err := sc.job(s.withHooks(sc))
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		now := time.Now().UTC()
+		sc.Finished, sc.Progress = &now, nil
+		switch {
+		case sc.ctx.Err() != nil && !sc.killed && s.closed && s.dir != "":
+			sc.State = Interrupted
+			if len(rep.Hosts) > 0 {
+				sc.Report = &rep // partial
+			}
+		case sc.ctx.Err() != nil:
+			sc.State = Cancelled
+			if len(rep.Hosts) > 0 {
+				sc.Report = &rep // partial
+			}
+		case err != nil:
+			sc.State = Failed
+			sc.Error = err.Error()
+		default:
+			sc.State = Done
+			sc.Report = &rep
+		}
+		sc.job = nil
+		s.notify(sc)
+		s.save(sc)
+		s.running[sc.Tenant]--
+		s.dispatch()
+	}()
+}
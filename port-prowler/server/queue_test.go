@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"portprowler/report"
+)
+
+func TestQueue(t *testing.T) {
+	var mu sync.Mutex
+	release := make(map[string]chan struct{})
+	gate := func(note string) chan struct{} {
+		mu.Lock()
+		defer mu.Unlock()
+		if release[note] == nil {
+			release[note] = make(chan struct{})
+		}
+		return release[note]
+	}
+	s := New(func(req Request) (Job, error) {
+		return func(ctx context.Context) (report.ScanReport, error) {
+			select {
+			case <-gate(req.Note):
+			case <-ctx.Done():
+			}
+			return report.ScanReport{}, ctx.Err()
+		}, nil
+	})
+	ts, _ := ParseTokens(strings.NewReader("alice scan a-token\nbob scan b-token\n"))
+	s.RequireTokens(ts)
+	s.SetLimits(Limits{Scans: 2})
+	defer s.Shutdown(context.Background())
+
+	submit := func(token, note string) {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/scans", strings.NewReader(`{"targets":["192.0.2.1"],"ports":"22","note":"`+note+`"}`))
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("submit %s = %d %s", note, rec.Code, rec.Body)
+		}
+	}
+	// alice queues four scans before bob's one.
+	for _, note := range []string{"a1", "a2", "a3", "a4"} {
+		submit("a-token", note)
+	}
+	submit("b-token", "b1")
+	states := func() string {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var b strings.Builder
+		for id := 1; id <= 5; id++ {
+			b.WriteString(s.scans[string(rune('0'+id))].State[:1])
+		}
+		return b.String()
+	}
+	if got := states(); got != "rrqqq" {
+		t.Fatalf("after submitting: %s, want rrqqq", got)
+	}
+	if sc := s.status(t, "5"); sc.Tenant != "bob" || sc.Started != nil {
+		t.Fatalf("bob's scan = %+v", sc)
+	}
+
+	// The first free slot goes to bob, not to alice's older scans.
+	close(gate("a1"))
+	s.await(t, "5", Running)
+	if got := states(); got != "drqqr" {
+		t.Fatalf("after a1: %s, want drqqr", got)
+	}
+	close(gate("a2"))
+	s.await(t, "3", Running)
+
+	// Cancelling a queued scan takes it out of the queue.
+	r := httptest.NewRequest("DELETE", "/scans/4", nil)
+	r.Header.Set("Authorization", "Bearer a-token")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, r)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("cancel queued = %d", rec.Code)
+	}
+	if got := states(); got != "ddrcr" {
+		t.Fatalf("after cancelling a4: %s, want ddrcr", got)
+	}
+}
+
+func TestQueuePerTenant(t *testing.T) {
+	block := make(chan struct{})
+	s := New(func(req Request) (Job, error) {
+		return func(ctx context.Context) (report.ScanReport, error) {
+			select {
+			case <-block:
+			case <-ctx.Done():
+			}
+			return report.ScanReport{}, nil
+		}, nil
+	})
+	s.SetLimits(Limits{PerTenant: 1})
+	defer s.Shutdown(context.Background())
+	for i := 0; i < 3; i++ {
+		do(t, s, "POST", "/scans", `{"targets":["192.0.2.1"],"ports":"22"}`)
+	}
+	if st := s.status(t, "2"); st.State != Queued {
+		t.Fatalf("second scan of the tenant is %s, want queued", st.State)
+	}
+	close(block)
+	wait(t, s, "3", Done)
+}
+
+// await waits for scan id to reach state, bypassing the API's tokens.
+func (s *Server) await(t *testing.T, id, state string) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if s.status(t, id).State == state {
+			return
+		}
+	}
+	t.Fatalf("scan %s never reached %s", id, state)
+}
+
+// status returns scan id as the API would.
+func (s *Server) status(t *testing.T, id string) Status {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, ok := s.scans[id]
+	if !ok {
+		t.Fatalf("no scan %s", id)
+	}
+	return sc.Status
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"portprowler/port"
+)
+
+type resultKey struct{}
+
+// ResultFunc returns the function a Job running on the server should
+// pass each result to as it is found (scanner.Config.OnResult), or nil
+// outside the server. GET /scans/{id}/results streams them to clients.
+func ResultFunc(ctx context.Context) func(port.PortResult) {
+	fn, _ := ctx.Value(resultKey{}).(func(port.PortResult))
+	return fn
+}
+
+// withHooks returns the context a scan's Job runs with.
+func (s *Server) withHooks(sc *scan) context.Context {
+	ctx := context.WithValue(sc.ctx, progressKey{}, &progressWriter{s: s, sc: sc})
+	return context.WithValue(ctx, resultKey{}, func(r port.PortResult) {
+		s.mu.Lock()
+		sc.found = append(sc.found, r)
+		s.notify(sc)
+		s.mu.Unlock()
+	})
+}
+
+// notify wakes the clients streaming sc. s.mu must be held.
+func (s *Server) notify(sc *scan) {
+	if sc.changed != nil {
+		close(sc.changed)
+		sc.changed = nil
+	}
+}
+
+// results streams a scan's results as JSON lines: those found so far,
+// then the rest as they are found, ending when the scan does. Scans taken
+// over from a previous server stream the results of their report. Scans
+// token may not see are not found.
+func (s *Server) results(w http.ResponseWriter, r *http.Request, id string, token *Token) {
+	s.mu.Lock()
+	sc, ok := s.scans[id]
+	ok = ok && canRead(token, sc)
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such scan")
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	sent := 0
+	for {
+		s.mu.Lock()
+		batch := sc.found[sent:]
+		live := sc.State == Queued || sc.State == Running
+		if !live && sc.found == nil && sc.Report != nil {
+			batch = nil
+			for _, h := range sc.Report.Hosts {
+				batch = append(batch, h.Results...)
+			}
+		}
+		var changed chan struct{}
+		if live {
+			if sc.changed == nil {
+				sc.changed = make(chan struct{})
+			}
+			changed = sc.changed
+		}
+		s.mu.Unlock()
+
+		for _, res := range batch {
+			if err := enc.Encode(res); err != nil {
+				return
+			}
+		}
+		sent += len(batch)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if !live {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,378 @@
+// Package server exposes scans over HTTP: clients submit a scan, poll it
+// until it finishes and fetch the report.
+//
+//	POST   /scans                      submit a Request; answers 202 with the scan id
+//	GET    /scans                      list scans
+//	GET    /scans/{id}                 scan status, with the report once done
+//	DELETE /scans/{id}                 cancel a queued or running scan
+//	GET    /scans/{id}/results         stream the results as JSON lines
+//	GET    /scans/{id}/diff?base={id}  changes since the base scan
+//	GET    /openapi.json               the OpenAPI 3 document of these routes
+//	GET    /                           the web UI
+//
+// With RequireTokens, every request needs an API token (see ParseTokens).
+// With SetLimits, scans beyond the limits wait in a queue shared fairly
+// between tenants. With Persist, scans outlive the server.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"portprowler/port"
+	"portprowler/report"
+	"portprowler/stats"
+)
+
+// Request is the body of POST /scans. Its fields mirror the scan flags.
+type Request struct {
+	Targets       []string `json:"targets"`
+	Ports         string   `json:"ports"`
+	TCP           bool     `json:"tcp"`
+	UDP           bool     `json:"udp"`
+	Stealth       bool     `json:"stealth"`
+	Ping          bool     `json:"ping"`
+	ServiceDetect bool     `json:"service_detect"`
+	OSDetect      bool     `json:"os_detect"`
+	Workers       int      `json:"workers,omitempty"`
+	Timeout       string   `json:"timeout,omitempty"` // Go duration, e.g. "2s"
+	Note          string   `json:"note,omitempty"`
+	// Token is the client that submitted the request when the server
+	// requires tokens; Prepare must keep targets inside its Scope.
+	Token *Token `json:"-"`
+}
+
+// Job runs a prepared scan until it finishes or ctx is cancelled.
+type Job func(ctx context.Context) (report.ScanReport, error)
+
+// Prepare validates a request and returns the scan to run. Its errors are
+// reported to the client as 400 Bad Request.
+type Prepare func(Request) (Job, error)
+
+// Scan states.
+const (
+	Queued    = "queued"
+	Running   = "running"
+	Done      = "done"
+	Failed    = "failed"
+	Cancelled = "cancelled"
+	// Interrupted scans were running when a persisted server stopped.
+	Interrupted = "interrupted"
+)
+
+// Status is the representation of a scan returned by the API.
+type Status struct {
+	ID       string             `json:"id"`
+	State    string             `json:"status"`
+	Tenant   string             `json:"tenant,omitempty"` // token name
+	Request  Request            `json:"request"`
+	Created  time.Time          `json:"created"`
+	Started  *time.Time         `json:"started,omitempty"`
+	Progress *stats.Progress    `json:"progress,omitempty"` // latest, while running
+	Finished *time.Time         `json:"finished,omitempty"`
+	Error    string             `json:"error,omitempty"`
+	Report   *report.ScanReport `json:"report,omitempty"`
+}
+
+type scan struct {
+	Status
+	job    Job
+	ctx    context.Context
+	cancel context.CancelFunc
+	killed bool // cancelled by a client rather than by Shutdown
+	// found are the results so far, in the order found, and changed is
+	// closed when more arrive or the scan ends.
+	found   []port.PortResult
+	changed chan struct{}
+}
+
+// Server is an http.Handler running scans in the background. Scans are
+// kept in memory for the lifetime of the server.
+type Server struct {
+	prepare Prepare
+	tokens  Tokens
+	limits  Limits
+
+	mu      sync.Mutex
+	seq     int
+	scans   map[string]*scan
+	queue   []*scan        // oldest first
+	running map[string]int // per tenant
+	turn    map[string]int // per tenant, when it last started a scan
+	starts  int
+	closed  bool
+	dir     string // see Persist
+	wg      sync.WaitGroup
+}
+
+// New returns a server that prepares submitted scans with prepare.
+func New(prepare Prepare) *Server {
+	return &Server{
+		prepare: prepare,
+		scans:   make(map[string]*scan),
+		running: make(map[string]int),
+		turn:    make(map[string]int),
+	}
+}
+
+// RequireTokens makes the server refuse requests without one of ts.
+// Call it before serving.
+func (s *Server) RequireTokens(ts Tokens) {
+	s.tokens = ts
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	switch path {
+	case "":
+		serveUI(w, r) // the page asks for a token itself
+		return
+	case "openapi.json":
+		s.openAPI(w, r) // describes the API to clients before they have a token
+		return
+	}
+	token, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	switch {
+	case path == "scans":
+		switch r.Method {
+		case http.MethodGet:
+			s.list(w, token)
+		case http.MethodPost:
+			s.submit(w, r, token)
+		default:
+			methodNotAllowed(w, "GET, POST")
+		}
+	case strings.HasPrefix(path, "scans/") && !strings.Contains(path[len("scans/"):], "/"):
+		id := path[len("scans/"):]
+		switch r.Method {
+		case http.MethodGet:
+			s.get(w, id, token)
+		case http.MethodDelete:
+			s.cancel(w, id, token)
+		default:
+			methodNotAllowed(w, "GET, DELETE")
+		}
+	case strings.HasPrefix(path, "scans/") && strings.Count(path, "/") == 2:
+		parts := strings.Split(path, "/")
+		if parts[2] != "results" && parts[2] != "diff" {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, "GET")
+			return
+		}
+		if parts[2] == "results" {
+			s.results(w, r, parts[1], token)
+		} else {
+			s.diff(w, parts[1], r.URL.Query().Get("base"), token)
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// Shutdown cancels queued and running scans and waits for them to stop
+// or for ctx to end. A persisted server leaves its queued scans queued
+// and its running scans Interrupted for the next one.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	if s.dir == "" {
+		for _, sc := range append([]*scan(nil), s.queue...) {
+			s.dequeue(sc, Cancelled)
+		}
+	}
+	for _, sc := range s.scans {
+		sc.cancel()
+	}
+	s.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) submit(w http.ResponseWriter, r *http.Request, token *Token) {
+	var req Request
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if len(req.Targets) == 0 {
+		writeError(w, http.StatusBadRequest, "targets is required")
+		return
+	}
+	req.Token = token
+	job, err := s.prepare(req)
+	if errors.Is(err, ErrForbidden) {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		cancel()
+		writeError(w, http.StatusServiceUnavailable, "server is shutting down")
+		return
+	}
+	s.seq++
+	sc := &scan{
+		Status: Status{ID: strconv.Itoa(s.seq), State: Queued, Request: req, Created: time.Now().UTC()},
+		job:    job,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	if token != nil {
+		sc.Tenant = token.Name
+	}
+	s.scans[sc.ID] = sc
+	s.queue = append(s.queue, sc)
+	s.save(sc)
+	s.dispatch()
+	resp := sc.Status
+	s.mu.Unlock()
+
+	w.Header().Set("Location", "/scans/"+resp.ID)
+	writeJSON(w, http.StatusAccepted, resp)
+}
+
+// list answers the scans token may see.
+func (s *Server) list(w http.ResponseWriter, token *Token) {
+	s.mu.Lock()
+	out := make([]Status, 0, len(s.scans))
+	for _, sc := range s.scans {
+		if !canRead(token, sc) {
+			continue
+		}
+		st := sc.Status
+		st.Report = nil // listings stay small; fetch /scans/{id} for the report
+		out = append(out, st)
+	}
+	s.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		a, _ := strconv.Atoi(out[i].ID)
+		b, _ := strconv.Atoi(out[j].ID)
+		return a < b
+	})
+	writeJSON(w, http.StatusOK, out)
+}
+
+// get answers scan id; scans token may not see are not found.
+func (s *Server) get(w http.ResponseWriter, id string, token *Token) {
+	s.mu.Lock()
+	sc, ok := s.scans[id]
+	ok = ok && canRead(token, sc)
+	var st Status
+	if ok {
+		st = sc.Status
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such scan")
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+// diff answers the changes from scan base to scan id. Both must be scans
+// token may see.
+func (s *Server) diff(w http.ResponseWriter, id, base string, token *Token) {
+	if base == "" {
+		writeError(w, http.StatusBadRequest, "base is required")
+		return
+	}
+	s.mu.Lock()
+	cur, ok1 := s.scans[id]
+	old, ok2 := s.scans[base]
+	ok1 = ok1 && canRead(token, cur)
+	ok2 = ok2 && canRead(token, old)
+	var curRep, oldRep *report.ScanReport
+	if ok1 && ok2 {
+		curRep, oldRep = cur.Report, old.Report
+	}
+	s.mu.Unlock()
+	switch {
+	case !ok1 || !ok2:
+		writeError(w, http.StatusNotFound, "no such scan")
+	case curRep == nil || oldRep == nil:
+		writeError(w, http.StatusConflict, "both scans need a report")
+	default:
+		changes := report.Diff(*oldRep, *curRep)
+		if changes == nil {
+			changes = []report.Change{}
+		}
+		writeJSON(w, http.StatusOK, changes)
+	}
+}
+
+// cancel stops scan id, if token submitted it; other tenants' scans are
+// not found.
+func (s *Server) cancel(w http.ResponseWriter, id string, token *Token) {
+	s.mu.Lock()
+	sc, ok := s.scans[id]
+	ok = ok && (token == nil || sc.Tenant == token.Name)
+	state := ""
+	if ok {
+		state = sc.State
+		switch state {
+		case Queued:
+			s.dequeue(sc, Cancelled)
+		case Running:
+			sc.killed = true
+			sc.cancel()
+		}
+	}
+	s.mu.Unlock()
+	switch {
+	case !ok:
+		writeError(w, http.StatusNotFound, "no such scan")
+	case state != Queued && state != Running:
+		writeError(w, http.StatusConflict, "scan is not queued or running")
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
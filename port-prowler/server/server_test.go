@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"portprowler/port"
+	"portprowler/report"
+)
+
+func fakePrepare(req Request) (Job, error) {
+	if req.Ports == "bad" {
+		return nil, errors.New("invalid port spec")
+	}
+	return func(ctx context.Context) (report.ScanReport, error) {
+		if req.Note == "block" {
+			<-ctx.Done()
+			return report.ScanReport{}, ctx.Err()
+		}
+		return report.ScanReport{Meta: report.Meta{PortSpec: req.Ports, Note: req.Note}}, nil
+	}, nil
+}
+
+func do(t *testing.T, h http.Handler, method, path, body string) (*httptest.ResponseRecorder, Status) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	var st Status
+	if rec.Code < 300 && rec.Body.Len() > 0 && strings.HasPrefix(rec.Body.String(), "{") {
+		if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return rec, st
+}
+
+func wait(t *testing.T, h http.Handler, id, state string) Status {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, st := do(t, h, "GET", "/scans/"+id, ""); st.State == state {
+			return st
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("scan %s never reached %s", id, state)
+	return Status{}
+}
+
+func TestServer(t *testing.T) {
+	s := New(fakePrepare)
+	defer s.Shutdown(context.Background())
+
+	rec, st := do(t, s, "POST", "/scans", `{"targets":["192.0.2.1"],"ports":"22","tcp":true,"note":"first"}`)
+	if rec.Code != http.StatusAccepted || st.ID != "1" || rec.Header().Get("Location") != "/scans/1" {
+		t.Fatalf("submit = %d %+v", rec.Code, st)
+	}
+	done := wait(t, s, "1", Done)
+	if done.Report == nil || done.Report.Meta.Note != "first" || done.Finished == nil {
+		t.Fatalf("finished scan = %+v", done)
+	}
+
+	for _, body := range []string{`{"ports":"22"}`, `{"targets":["x"],"ports":"bad"}`, `{"targets":["x"],"bogus":1}`, `not json`} {
+		if rec, _ := do(t, s, "POST", "/scans", body); rec.Code != http.StatusBadRequest {
+			t.Fatalf("POST %s = %d, want 400", body, rec.Code)
+		}
+	}
+
+	_, st = do(t, s, "POST", "/scans", `{"targets":["192.0.2.1"],"ports":"22","note":"block"}`)
+	if rec, _ := do(t, s, "DELETE", "/scans/"+st.ID, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("cancel = %d", rec.Code)
+	}
+	wait(t, s, st.ID, Cancelled)
+	if rec, _ := do(t, s, "DELETE", "/scans/"+st.ID, ""); rec.Code != http.StatusConflict {
+		t.Fatalf("second cancel = %d, want 409", rec.Code)
+	}
+
+	rec, _ = do(t, s, "GET", "/scans", "")
+	var list []Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "1" || list[0].Report != nil {
+		t.Fatalf("list = %+v", list)
+	}
+
+	if rec, _ := do(t, s, "GET", "/scans/99", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown scan = %d", rec.Code)
+	}
+	if rec, _ := do(t, s, "PUT", "/scans", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("PUT /scans = %d", rec.Code)
+	}
+}
+
+func TestDiffAndUI(t *testing.T) {
+	s := New(fakePrepare)
+	defer s.Shutdown(context.Background())
+	do(t, s, "POST", "/scans", `{"targets":["192.0.2.1"],"ports":"22"}`)
+	do(t, s, "POST", "/scans", `{"targets":["192.0.2.1"],"ports":"22","note":"block"}`)
+	wait(t, s, "1", Done)
+
+	rec, _ := do(t, s, "GET", "/scans/1/diff?base=1", "")
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("diff with itself = %d %s", rec.Code, rec.Body)
+	}
+	for path, want := range map[string]int{
+		"/scans/1/diff":        http.StatusBadRequest,
+		"/scans/1/diff?base=9": http.StatusNotFound,
+		"/scans/1/diff?base=2": http.StatusConflict,
+	} {
+		if rec, _ := do(t, s, "GET", path, ""); rec.Code != want {
+			t.Errorf("GET %s = %d, want %d", path, rec.Code, want)
+		}
+	}
+
+	rec, _ = do(t, s, "GET", "/", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<title>portprowler</title>") {
+		t.Errorf("UI = %d %.80s", rec.Code, rec.Body)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	release := make(chan struct{})
+	s := New(func(req Request) (Job, error) {
+		return func(ctx context.Context) (report.ScanReport, error) {
+			fmt.Fprintln(ProgressWriter(ctx), `{"completed":5,"total":20,"percent":25,"open":1}`)
+			<-release
+			return report.ScanReport{}, nil
+		}, nil
+	})
+	defer s.Shutdown(context.Background())
+	if ProgressWriter(context.Background()) != nil {
+		t.Error("ProgressWriter outside the server should be nil")
+	}
+	do(t, s, "POST", "/scans", `{"targets":["192.0.2.1"],"ports":"22"}`)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, st := do(t, s, "GET", "/scans/1", "")
+		if st.Progress != nil {
+			if st.Progress.Completed != 5 || st.Progress.Total != 20 {
+				t.Errorf("progress = %+v", st.Progress)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no progress reported")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(release)
+	if st := wait(t, s, "1", Done); st.Progress != nil {
+		t.Error("a finished scan should drop its progress")
+	}
+}
+
+func TestStreamResults(t *testing.T) {
+	release := make(chan struct{})
+	s := New(func(req Request) (Job, error) {
+		return func(ctx context.Context) (report.ScanReport, error) {
+			found := ResultFunc(ctx)
+			found(port.PortResult{Target: "192.0.2.1", Port: 22, Proto: "tcp", State: "open"})
+			<-release
+			found(port.PortResult{Target: "192.0.2.1", Port: 80, Proto: "tcp", State: "closed"})
+			return report.ScanReport{}, nil
+		}, nil
+	})
+	defer s.Shutdown(context.Background())
+	if ResultFunc(context.Background()) != nil {
+		t.Error("ResultFunc outside the server should be nil")
+	}
+	do(t, s, "POST", "/scans", `{"targets":["192.0.2.1"],"ports":"22,80"}`)
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/scans/1/results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	var first port.PortResult
+	if err := dec.Decode(&first); err != nil || first.Port != 22 {
+		t.Fatalf("first result = %+v, %v", first, err)
+	}
+	close(release) // the second result arrives while streaming
+	var rest []port.PortResult
+	for {
+		var r port.PortResult
+		if err := dec.Decode(&r); err != nil {
+			break
+		}
+		rest = append(rest, r)
+	}
+	if len(rest) != 1 || rest[0].Port != 80 {
+		t.Errorf("streamed afterwards = %+v", rest)
+	}
+	if rec, _ := do(t, s, "GET", "/scans/9/results", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("results of a missing scan = %d", rec.Code)
+	}
+}
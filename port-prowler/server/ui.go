@@ -0,0 +1,23 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// uiPage is the web UI: one page that drives the API from the browser.
+//
+//go:embed ui/index.html
+var uiPage []byte
+
+func serveUI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, "GET")
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+	h.Set("X-Frame-Options", "DENY")
+	_, _ = w.Write(uiPage)
+}
@@ -0,0 +1,105 @@
+// Package signing signs report files with Ed25519 and verifies them, so
+// archived scan evidence can be shown to be unchanged.
+//
+// A signature is kept next to its file as <file>.sig: the raw 64-byte
+// Ed25519 signature of the file's bytes, base64 encoded on one line. Keys
+// are PEM files as written by `openssl genpkey -algorithm ed25519` and
+// `openssl pkey -pubout`, so signatures can also be checked without
+// portprowler.
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Ext is appended to a file's name to name its signature.
+const Ext = ".sig"
+
+// ErrMismatch reports a signature that does not match the file and key.
+var ErrMismatch = errors.New("signature does not match")
+
+// LoadPrivateKey reads a PKCS #8 Ed25519 private key in PEM.
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	key, err := loadKey(path)
+	if err != nil {
+		return nil, err
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 private key", path)
+	}
+	return priv, nil
+}
+
+// LoadPublicKey reads an Ed25519 public key in PEM, or the public half of
+// a private key.
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	key, err := loadKey(path)
+	if err != nil {
+		return nil, err
+	}
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return k, nil
+	case ed25519.PrivateKey:
+		return k.Public().(ed25519.PublicKey), nil
+	}
+	return nil, fmt.Errorf("%s: not an Ed25519 key", path)
+}
+
+func loadKey(path string) (any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM key", path)
+	}
+	var key any
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM type %q (want PRIVATE KEY or PUBLIC KEY)", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return key, nil
+}
+
+// Sign returns the signature file content for data.
+func Sign(key ed25519.PrivateKey, data []byte) []byte {
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+	return []byte(sig + "\n")
+}
+
+// Verify checks the signature file content sig of data against pub.
+func Verify(pub ed25519.PublicKey, data, sig []byte) error {
+	raw, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig)))
+	if err != nil || len(raw) != ed25519.SignatureSize {
+		return errors.New("malformed signature")
+	}
+	if !ed25519.Verify(pub, data, raw) {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// Fingerprint identifies pub in messages: the SHA-256 of the key, as ssh
+// prints it.
+func Fingerprint(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,61 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writePEM(t *testing.T, typ string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSignVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privDER, _ := x509.MarshalPKCS8PrivateKey(priv)
+	pubDER, _ := x509.MarshalPKIXPublicKey(pub)
+	privPath, pubPath := writePEM(t, "PRIVATE KEY", privDER), writePEM(t, "PUBLIC KEY", pubDER)
+
+	key, err := LoadPrivateKey(privPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPrivateKey(pubPath); err == nil {
+		t.Error("a public key was loaded as private key")
+	}
+	for _, p := range []string{pubPath, privPath} {
+		got, err := LoadPublicKey(p)
+		if err != nil || !got.Equal(pub) {
+			t.Fatalf("LoadPublicKey(%s) = %x, %v", p, got, err)
+		}
+	}
+
+	data := []byte(`{"hosts":[]}`)
+	sig := Sign(key, data)
+	if err := Verify(pub, data, sig); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(pub, []byte(`{"hosts":[1]}`), sig); !errors.Is(err, ErrMismatch) {
+		t.Errorf("tampered data: %v", err)
+	}
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	if err := Verify(other, data, sig); !errors.Is(err, ErrMismatch) {
+		t.Errorf("other key: %v", err)
+	}
+	if err := Verify(pub, data, []byte("bm90IGEgc2lnbmF0dXJl\n")); err == nil || errors.Is(err, ErrMismatch) {
+		t.Errorf("malformed signature: %v", err)
+	}
+}
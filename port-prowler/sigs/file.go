@@ -0,0 +1,89 @@
+package sigs
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Parse reads signatures in the --sig-file format: one per line as
+// substring|service|confidence, where confidence is optional (default
+// "medium"). Blank lines and lines starting with # are ignored.
+func Parse(r io.Reader) ([]Signature, error) {
+	var out []Signature
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		parts := strings.Split(text, "|")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("line %d: want substring|service|confidence", line)
+		}
+		s := Signature{Substr: parts[0], Service: strings.TrimSpace(parts[1]), Confidence: "medium"}
+		if len(parts) == 3 {
+			switch c := strings.TrimSpace(parts[2]); c {
+			case "low", "medium", "high":
+				s.Confidence = c
+			default:
+				return nil, fmt.Errorf("line %d: confidence %q is not low, medium or high", line, c)
+			}
+		}
+		out = append(out, s)
+	}
+	return out, sc.Err()
+}
+
+// LoadFile parses the signature file at path.
+func LoadFile(path string) ([]Signature, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Watch polls path every interval until ctx is done and installs the file's
+// signatures with SetUser whenever its modification time or size changes.
+// A file that fails to parse leaves the previous set active. onReload, if
+// not nil, is called after every reload attempt.
+func Watch(ctx context.Context, path string, interval time.Duration, onReload func(n int, err error)) {
+	var lastMod time.Time
+	var lastSize int64 = -1
+	if fi, err := os.Stat(path); err == nil {
+		lastMod, lastSize = fi.ModTime(), fi.Size()
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		fi, err := os.Stat(path)
+		if err != nil || (fi.ModTime().Equal(lastMod) && fi.Size() == lastSize) {
+			continue
+		}
+		lastMod, lastSize = fi.ModTime(), fi.Size()
+		s, err := LoadFile(path)
+		if err == nil {
+			SetUser(s)
+		}
+		if onReload != nil {
+			onReload(len(s), err)
+		}
+	}
+}
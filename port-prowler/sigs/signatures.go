@@ -1,14 +1,20 @@
 package sigs
 
-import "strings"
+import (
+	"strings"
+	"sync"
+)
 
-// Small signature DB mapping substrings to service name and confidence.
-// Matching is done case-insensitively.
-var signatures = []struct {
+// Signature maps a banner substring to a service name and confidence.
+type Signature struct {
 	Substr     string
 	Service    string
 	Confidence string
-}{
+}
+
+// Small signature DB mapping substrings to service name and confidence.
+// Matching is done case-insensitively.
+var signatures = []Signature{
 	{"ssh-", "ssh", "high"},     // OpenSSH banners include "SSH-"
 	{"http/", "http", "medium"}, // e.g. "HTTP/1.1"
 	{"nginx", "http/nginx", "high"},
@@ -16,6 +22,20 @@ var signatures = []struct {
 	{"dns", "dns", "medium"},   // generic DNS hint
 }
 
+// user signatures loaded from a --sig-file; they are checked before the
+// built-in ones and may be replaced at any time (see SetUser and Watch).
+var (
+	mu   sync.RWMutex
+	user []Signature
+)
+
+// SetUser replaces the user signature set.
+func SetUser(s []Signature) {
+	mu.Lock()
+	defer mu.Unlock()
+	user = s
+}
+
 // Detect examines banner text and returns service, confidence and found flag.
 func Detect(banner string) (service, confidence string, found bool) {
 	b := banner
@@ -24,9 +44,14 @@ func Detect(banner string) (service, confidence string, found bool) {
 	}
 	// Normalize to lower-case once for case-insensitive substring checks.
 	lb := strings.ToLower(b)
-	for _, s := range signatures {
-		if strings.Contains(lb, strings.ToLower(s.Substr)) {
-			return s.Service, s.Confidence, true
+	mu.RLock()
+	active := user
+	mu.RUnlock()
+	for _, set := range [][]Signature{active, signatures} {
+		for _, s := range set {
+			if strings.Contains(lb, strings.ToLower(s.Substr)) {
+				return s.Service, s.Confidence, true
+			}
 		}
 	}
 	return "", "", false
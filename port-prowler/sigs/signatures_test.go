@@ -0,0 +1,68 @@
+package sigs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	got, err := Parse(strings.NewReader("# widgets\nWIDGETD|widgetd|high\n\nacme-proto|acme\n"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(got) != 2 || got[0] != (Signature{"WIDGETD", "widgetd", "high"}) || got[1].Confidence != "medium" {
+		t.Fatalf("Parse = %+v", got)
+	}
+	if _, err := Parse(strings.NewReader("only-one-field\n")); err == nil {
+		t.Fatalf("expected error for malformed line")
+	}
+	if _, err := Parse(strings.NewReader("x|y|certain\n")); err == nil {
+		t.Fatalf("expected error for bad confidence")
+	}
+}
+
+func TestWatchReloadsUserSignatures(t *testing.T) {
+	defer SetUser(nil)
+	path := filepath.Join(t.TempDir(), "sigs.txt")
+	if err := os.WriteFile(path, []byte("WIDGETD|widgetd|high\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetUser(s)
+	if svc, _, _ := Detect("WIDGETD 1.2 ready"); svc != "widgetd" {
+		t.Fatalf("user signature not applied, got %q", svc)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloaded := make(chan error, 4)
+	go Watch(ctx, path, 10*time.Millisecond, func(n int, err error) { reloaded <- err })
+	time.Sleep(50 * time.Millisecond) // let Watch record the current file state
+
+	if err := os.WriteFile(path, []byte("WIDGETD|widgetd-v2|high\nbroken\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-reloaded; err == nil {
+		t.Fatalf("expected parse error on broken file")
+	}
+	if svc, _, _ := Detect("WIDGETD 1.2 ready"); svc != "widgetd" {
+		t.Fatalf("broken file replaced active signatures, got %q", svc)
+	}
+
+	if err := os.WriteFile(path, []byte("WIDGETD|widgetd-v2|high\n# fixed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-reloaded; err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if svc, _, _ := Detect("WIDGETD 1.2 ready"); svc != "widgetd-v2" {
+		t.Fatalf("reloaded signature not applied, got %q", svc)
+	}
+}
@@ -0,0 +1,249 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"portprowler/i18n"
+	"portprowler/port"
+)
+
+// Collector accumulates statistics about a scan as results are produced.
+// It is safe for concurrent use by the manager's workers.
+type Collector struct {
+	mu       sync.Mutex
+	started  time.Time
+	finished time.Time
+	hosts    map[string]struct{}
+	ports    map[uint16]struct{}
+	probes   int
+	total    int
+	states   map[string]int
+	errors   int
+	timeouts int
+	dropped  int
+	active   int
+}
+
+// Snapshot is a point-in-time copy of the collected statistics.
+type Snapshot struct {
+	Started  time.Time
+	Finished time.Time // zero while the scan is running
+	Elapsed  time.Duration
+	Hosts    int // distinct IPs probed
+	Ports    int // distinct port numbers probed
+	Probes   int // results recorded (one per port/protocol)
+	Total    int // probes planned, when known (see SetTotal)
+	Open     int
+	Closed   int
+	Filtered int // includes open|filtered
+	Errors   int // results carrying an error that is not a plain timeout/refusal
+	Timeouts int // results whose probe timed out
+	Dropped  int // results not sent to a consumer that could not keep up
+	Active   int // workers currently busy with a job
+}
+
+// New returns an empty Collector.
+func New() *Collector {
+	return &Collector{
+		hosts:  make(map[string]struct{}),
+		ports:  make(map[uint16]struct{}),
+		states: make(map[string]int),
+	}
+}
+
+// Start marks the beginning of the scan.
+func (c *Collector) Start() {
+	c.mu.Lock()
+	c.started = time.Now()
+	c.mu.Unlock()
+}
+
+// SetTotal records how many probes the scan will run, for progress.
+func (c *Collector) SetTotal(n int) {
+	c.mu.Lock()
+	c.total = n
+	c.mu.Unlock()
+}
+
+// Skip removes n planned probes that will not run from the total.
+func (c *Collector) Skip(n int) {
+	c.mu.Lock()
+	c.total -= n
+	c.mu.Unlock()
+}
+
+// Finish marks the end of the scan.
+func (c *Collector) Finish() {
+	c.mu.Lock()
+	c.finished = time.Now()
+	c.mu.Unlock()
+}
+
+// Record accounts for one result.
+func (c *Collector) Record(r port.PortResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hosts[r.IP] = struct{}{}
+	if r.Proto != string(port.ScanPing) {
+		c.ports[r.Port] = struct{}{}
+	}
+	c.probes++
+	c.states[r.State]++
+	if isProbeError(r.Error) {
+		c.errors++
+	}
+	if r.Error == "timeout" {
+		c.timeouts++
+	}
+}
+
+// Drop counts a result that was not sent to the consumer.
+func (c *Collector) Drop() {
+	c.mu.Lock()
+	c.dropped++
+	c.mu.Unlock()
+}
+
+// WorkerBusy marks a worker as having picked up a job.
+func (c *Collector) WorkerBusy() {
+	c.mu.Lock()
+	c.active++
+	c.mu.Unlock()
+}
+
+// WorkerIdle marks a worker as having finished its job.
+func (c *Collector) WorkerIdle() {
+	c.mu.Lock()
+	c.active--
+	c.mu.Unlock()
+}
+
+// isProbeError reports whether a result error is an actual failure rather
+// than the expected timeout/refusal that determines a port state.
+func isProbeError(e string) bool {
+	switch e {
+	case "", "timeout", "connection refused":
+		return false
+	}
+	return true
+}
+
+// Snapshot returns the current statistics.
+func (c *Collector) Snapshot() Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := Snapshot{
+		Started:  c.started,
+		Finished: c.finished,
+		Hosts:    len(c.hosts),
+		Ports:    len(c.ports),
+		Probes:   c.probes,
+		Total:    c.total,
+		Open:     c.states["open"],
+		Closed:   c.states["closed"],
+		Filtered: c.states["filtered"] + c.states["open|filtered"],
+		Errors:   c.errors,
+		Timeouts: c.timeouts,
+		Dropped:  c.dropped,
+		Active:   c.active,
+	}
+	switch {
+	case c.started.IsZero():
+	case c.finished.IsZero():
+		s.Elapsed = time.Since(c.started)
+	default:
+		s.Elapsed = c.finished.Sub(c.started)
+	}
+	return s
+}
+
+// Summary renders the human summary line printed at the end of console output,
+// e.g. "scanned 1024 ports on 3 hosts in 42.3s (5 open, 1019 closed, 0 filtered)".
+func (s Snapshot) Summary() string {
+	return i18n.Tf("scanned %d %s on %d %s in %s (%d open, %d closed, %d filtered)",
+		s.Ports, plural(s.Ports, "port", "ports"),
+		s.Hosts, plural(s.Hosts, "host", "hosts"),
+		FormatDuration(s.Elapsed), s.Open, s.Closed, s.Filtered)
+}
+
+// FormatDuration formats d for humans: milliseconds below one second,
+// one decimal of seconds below a minute, and h/m/s above.
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	case d < time.Minute:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	default:
+		return d.Round(100 * time.Millisecond).String()
+	}
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return i18n.T(one)
+	}
+	return i18n.T(many)
+}
+
+// Rates holds per-second rates derived from two snapshots.
+type Rates struct {
+	Probes   float64
+	Errors   float64
+	Timeouts float64
+}
+
+// RatesSince computes per-second rates between prev and s.
+func (s Snapshot) RatesSince(prev Snapshot) Rates {
+	dt := (s.Elapsed - prev.Elapsed).Seconds()
+	if dt <= 0 {
+		return Rates{}
+	}
+	return Rates{
+		Probes:   float64(s.Probes-prev.Probes) / dt,
+		Errors:   float64(s.Errors-prev.Errors) / dt,
+		Timeouts: float64(s.Timeouts-prev.Timeouts) / dt,
+	}
+}
+
+// Progress is one event of the --progress-json stream.
+type Progress struct {
+	Time      time.Time `json:"ts"`
+	Completed int       `json:"completed"` // probes finished
+	Total     int       `json:"total"`     // probes planned
+	Percent   float64   `json:"percent"`
+	Rate      float64   `json:"rate"`  // probes per second since the scan started
+	ETASecs   float64   `json:"eta_s"` // estimated seconds left; -1 until a rate is known
+	Open      int       `json:"open"`
+	Elapsed   float64   `json:"elapsed_s"`
+	Done      bool      `json:"done"`
+}
+
+// Progress returns the progress event for the snapshot.
+func (s Snapshot) Progress() Progress {
+	p := Progress{
+		Time:      time.Now().UTC(),
+		Completed: s.Probes,
+		Total:     s.Total,
+		Open:      s.Open,
+		Elapsed:   math.Round(1000*s.Elapsed.Seconds()) / 1000,
+		Done:      !s.Finished.IsZero(),
+		ETASecs:   -1,
+	}
+	if s.Total > 0 {
+		p.Percent = math.Round(1000*float64(s.Probes)/float64(s.Total)) / 10
+	}
+	if secs := s.Elapsed.Seconds(); secs > 0 {
+		p.Rate = math.Round(10*float64(s.Probes)/secs) / 10
+	}
+	switch {
+	case p.Done:
+		p.ETASecs = 0
+	case p.Rate > 0 && s.Total >= s.Probes:
+		p.ETASecs = math.Round(10*float64(s.Total-s.Probes)/(float64(s.Probes)/s.Elapsed.Seconds())) / 10
+	}
+	return p
+}
@@ -0,0 +1,59 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"portprowler/port"
+)
+
+func TestCollectorSummary(t *testing.T) {
+	c := New()
+	c.Start()
+	c.Record(port.PortResult{IP: "10.0.0.1", Port: 22, State: "open"})
+	c.Record(port.PortResult{IP: "10.0.0.1", Port: 80, State: "closed", Error: "connection refused"})
+	c.Record(port.PortResult{IP: "10.0.0.2", Port: 22, State: "open|filtered", Error: "timeout"})
+	c.Record(port.PortResult{IP: "10.0.0.2", Port: 80, State: "filtered", Error: "no route to host"})
+	c.Finish()
+
+	s := c.Snapshot()
+	if s.Hosts != 2 || s.Ports != 2 || s.Probes != 4 {
+		t.Fatalf("unexpected counts: %+v", s)
+	}
+	if s.Open != 1 || s.Closed != 1 || s.Filtered != 2 || s.Errors != 1 {
+		t.Fatalf("unexpected state counts: %+v", s)
+	}
+	s.Elapsed = 42300 * time.Millisecond
+	want := "scanned 2 ports on 2 hosts in 42.3s (1 open, 1 closed, 2 filtered)"
+	if got := s.Summary(); got != want {
+		t.Fatalf("summary = %q, want %q", got, want)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	cases := map[time.Duration]string{
+		250 * time.Millisecond:  "250ms",
+		1500 * time.Millisecond: "1.5s",
+		125 * time.Second:       "2m5s",
+	}
+	for d, want := range cases {
+		if got := FormatDuration(d); got != want {
+			t.Errorf("FormatDuration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	s := Snapshot{Probes: 25, Total: 100, Open: 3, Elapsed: 5 * time.Second}
+	p := s.Progress()
+	if p.Completed != 25 || p.Total != 100 || p.Percent != 25 || p.Rate != 5 || p.ETASecs != 15 || p.Open != 3 || p.Done {
+		t.Fatalf("progress = %+v", p)
+	}
+	if p := (Snapshot{Total: 100}).Progress(); p.ETASecs != -1 || p.Rate != 0 {
+		t.Fatalf("progress before any probe = %+v", p)
+	}
+	s.Probes, s.Finished = 100, time.Now()
+	if p := s.Progress(); !p.Done || p.ETASecs != 0 || p.Percent != 100 {
+		t.Fatalf("final progress = %+v", p)
+	}
+}
@@ -0,0 +1,254 @@
+// Package testsupport provides an in-process fake network for tests and
+// benchmarks of scans. A Network implements netutil.ContextDialer, so it
+// plugs into scanner.Config.Dialer and PacketDialer; its hosts answer with
+// configured port states, banners and latencies without any real socket.
+//
+//	fake := testsupport.NewNetwork()
+//	fake.AddHost("192.0.2.10").
+//		TCP(22, testsupport.Port{State: testsupport.Open, Banner: "SSH-2.0-OpenSSH_9.6\r\n"}).
+//		UDP(53, testsupport.Port{State: testsupport.Open, Reply: "\x00"})
+//	cfg.Dialer, cfg.PacketDialer = fake, fake
+package testsupport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Port states.
+const (
+	Open     = "open"
+	Closed   = "closed"
+	Filtered = "filtered" // no answer at all
+)
+
+// PingPort is the destination of the scanner's UDP ping. Like every
+// unconfigured udp port it is closed, so a host that is not Down answers
+// the ping with (simulated) ICMP port-unreachable.
+const PingPort = 33434
+
+// Port configures how a fake port answers.
+type Port struct {
+	State   string        // Open, Closed or Filtered; empty means Open
+	Banner  string        // tcp: sent as soon as the connection opens
+	Reply   string        // udp: sent in answer to each datagram; empty sends nothing
+	Latency time.Duration // delay before the port answers
+}
+
+// Host is a fake host. Ports that are not configured are closed.
+type Host struct {
+	IP string
+	// Down makes every port filtered, as if the host were unreachable.
+	Down bool
+	// Latency applies to ports that set none.
+	Latency time.Duration
+
+	mu    sync.Mutex
+	ports map[string]Port
+}
+
+// TCP configures a tcp port and returns h for chaining.
+func (h *Host) TCP(port uint16, p Port) *Host { return h.set("tcp", port, p) }
+
+// UDP configures a udp port and returns h for chaining.
+func (h *Host) UDP(port uint16, p Port) *Host { return h.set("udp", port, p) }
+
+func (h *Host) set(proto string, port uint16, p Port) *Host {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if p.State == "" {
+		p.State = Open
+	}
+	h.ports[proto+"/"+strconv.Itoa(int(port))] = p
+	return h
+}
+
+func (h *Host) lookup(proto string, port uint16) Port {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	p, ok := h.ports[proto+"/"+strconv.Itoa(int(port))]
+	switch {
+	case h.Down:
+		p = Port{State: Filtered}
+	case !ok:
+		p = Port{State: Closed}
+	}
+	if p.Latency == 0 {
+		p.Latency = h.Latency
+	}
+	return p
+}
+
+// Network is a set of fake hosts. Dials to unknown addresses are filtered:
+// tcp dials block until their context ends.
+// It is safe for concurrent use.
+type Network struct {
+	mu    sync.Mutex
+	hosts map[string]*Host
+	dials map[string]int
+}
+
+// NewNetwork returns an empty network.
+func NewNetwork() *Network {
+	return &Network{hosts: make(map[string]*Host), dials: make(map[string]int)}
+}
+
+// AddHost adds (or returns the existing) host with the given IP.
+func (n *Network) AddHost(ip string) *Host {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if h, ok := n.hosts[ip]; ok {
+		return h
+	}
+	h := &Host{IP: ip, ports: make(map[string]Port)}
+	n.hosts[ip] = h
+	return h
+}
+
+// Dials returns how often addr ("tcp/192.0.2.10:22") was dialled.
+func (n *Network) Dials(network, addr string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.dials[network+"/"+addr]
+}
+
+// DialContext implements netutil.ContextDialer for "tcp" and "udp".
+func (n *Network) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	portNum, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", portStr)
+	}
+	proto := network
+	switch network {
+	case "tcp4", "tcp6":
+		proto = "tcp"
+	case "udp4", "udp6":
+		proto = "udp"
+	case "tcp", "udp":
+	default:
+		return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("unsupported network")}
+	}
+
+	n.mu.Lock()
+	n.dials[proto+"/"+addr]++
+	h := n.hosts[host]
+	n.mu.Unlock()
+	p := Port{State: Filtered}
+	if h != nil {
+		p = h.lookup(proto, uint16(portNum))
+	}
+
+	remote := addrFor(proto, host, int(portNum))
+	local := addrFor(proto, "198.51.100.1", 40000+int(portNum)%20000)
+	if proto == "tcp" {
+		switch p.State {
+		case Filtered:
+			<-ctx.Done()
+			return nil, &net.OpError{Op: "dial", Net: network, Addr: remote, Err: timeoutError{}}
+		case Closed:
+			if err := sleep(ctx, p.Latency); err != nil {
+				return nil, &net.OpError{Op: "dial", Net: network, Addr: remote, Err: timeoutError{}}
+			}
+			return nil, &net.OpError{Op: "dial", Net: network, Addr: remote, Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+		}
+		if err := sleep(ctx, p.Latency); err != nil {
+			return nil, &net.OpError{Op: "dial", Net: network, Addr: remote, Err: timeoutError{}}
+		}
+	}
+	// udp "connects" immediately, like a real connected udp socket
+	client, server := net.Pipe()
+	go serve(server, proto, p)
+	return &conn{Conn: client, proto: proto, state: p.State, latency: p.Latency, local: local, remote: remote}, nil
+}
+
+// serve plays the remote end of a connection.
+func serve(c net.Conn, proto string, p Port) {
+	defer c.Close()
+	if proto == "tcp" {
+		if p.Banner != "" {
+			_, _ = c.Write([]byte(p.Banner))
+		}
+		// keep the connection open until the client closes it
+		_, _ = c.Read(make([]byte, 1))
+		return
+	}
+	buf := make([]byte, 64*1024)
+	for {
+		if _, err := c.Read(buf); err != nil {
+			return
+		}
+		if p.State == Open && p.Reply != "" {
+			time.Sleep(p.Latency)
+			if _, err := c.Write([]byte(p.Reply)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// conn is the client end. Closed udp ports fail reads with connection
+// refused, as the ICMP port-unreachable of a real host does.
+type conn struct {
+	net.Conn
+	proto         string
+	state         string
+	latency       time.Duration
+	local, remote net.Addr
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	if c.proto == "udp" && c.state == Closed {
+		time.Sleep(c.latency)
+		return 0, &net.OpError{Op: "read", Net: "udp", Addr: c.remote, Err: os.NewSyscallError("recvfrom", syscall.ECONNREFUSED)}
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	if c.proto == "udp" && c.state != Open {
+		return len(b), nil // the datagram is lost or answered with ICMP
+	}
+	return c.Conn.Write(b)
+}
+
+func (c *conn) LocalAddr() net.Addr  { return c.local }
+func (c *conn) RemoteAddr() net.Addr { return c.remote }
+
+func addrFor(proto, ip string, port int) net.Addr {
+	if proto == "udp" {
+		return &net.UDPAddr{IP: net.ParseIP(ip), Port: port}
+	}
+	return &net.TCPAddr{IP: net.ParseIP(ip), Port: port}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// timeoutError is the error of a dial that got no answer.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
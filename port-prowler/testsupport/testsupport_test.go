@@ -0,0 +1,101 @@
+package testsupport_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"portprowler/port"
+	"portprowler/scanner"
+	"portprowler/testsupport"
+)
+
+func scan(t testing.TB, fake *testsupport.Network, targets []port.Target, ports []uint16) map[string]port.PortResult {
+	mgr := scanner.NewManager(scanner.Config{
+		Targets:      targets,
+		Ports:        ports,
+		ScanTCP:      true,
+		ScanUDP:      true,
+		ScanPing:     true,
+		Workers:      16,
+		TCPTimeout:   100 * time.Millisecond,
+		UDPTimeout:   100 * time.Millisecond,
+		Dialer:       fake,
+		PacketDialer: fake,
+	})
+	out, err := mgr.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got := make(map[string]port.PortResult)
+	for r := range out {
+		got[fmt.Sprintf("%s %s/%d", r.IP, r.Proto, r.Port)] = r
+	}
+	return got
+}
+
+func TestNetwork(t *testing.T) {
+	fake := testsupport.NewNetwork()
+	fake.AddHost("192.0.2.10").
+		TCP(22, testsupport.Port{Banner: "SSH-2.0-OpenSSH_9.6\r\n"}).
+		TCP(80, testsupport.Port{Latency: 30 * time.Millisecond}).
+		TCP(443, testsupport.Port{State: testsupport.Filtered}).
+		UDP(53, testsupport.Port{Reply: "answer"}).
+		UDP(123, testsupport.Port{State: testsupport.Filtered})
+	fake.AddHost("192.0.2.20").Down = true
+
+	got := scan(t, fake, []port.Target{
+		{Name: "up.example", IP: "192.0.2.10"},
+		{Name: "down.example", IP: "192.0.2.20"},
+		{Name: "unknown.example", IP: "192.0.2.30"},
+	}, []uint16{22, 53, 80, 123, 443})
+
+	want := map[string]string{
+		"192.0.2.10 tcp/22":  "open",
+		"192.0.2.10 tcp/53":  "closed",
+		"192.0.2.10 tcp/80":  "open",
+		"192.0.2.10 tcp/443": "filtered",
+		"192.0.2.10 udp/53":  "open",
+		"192.0.2.10 udp/123": "open|filtered",
+		"192.0.2.10 udp/22":  "closed",
+		"192.0.2.10 ping/0":  "up",
+		"192.0.2.20 tcp/22":  "filtered",
+		"192.0.2.20 udp/53":  "open|filtered",
+		"192.0.2.20 ping/0":  "down",
+		"192.0.2.30 tcp/80":  "filtered",
+		"192.0.2.30 ping/0":  "down",
+	}
+	for k, state := range want {
+		if got[k].State != state {
+			t.Errorf("%s = %q (%s), want %q", k, got[k].State, got[k].Error, state)
+		}
+	}
+	if b := got["192.0.2.10 tcp/22"].ServiceBanner; b != "SSH-2.0-OpenSSH_9.6" {
+		t.Errorf("banner = %q", b)
+	}
+	if rtt := got["192.0.2.10 tcp/80"].RTTMillis; rtt < 30 {
+		t.Errorf("tcp/80 rtt = %dms, want the configured 30ms latency", rtt)
+	}
+	if n := fake.Dials("tcp", "192.0.2.10:22"); n != 1 {
+		t.Errorf("tcp/22 dialled %d times", n)
+	}
+}
+
+// BenchmarkScan measures scanner overhead on 4096 probes without network I/O.
+func BenchmarkScan(b *testing.B) {
+	fake := testsupport.NewNetwork()
+	h := fake.AddHost("192.0.2.10")
+	ports := make([]uint16, 0, 2048)
+	for p := uint16(1); p <= 2048; p++ {
+		ports = append(ports, p)
+		if p%10 == 0 {
+			h.TCP(p, testsupport.Port{Banner: "220 ready\r\n"}) // no banner wait
+		}
+	}
+	targets := []port.Target{{Name: "bench.example", IP: "192.0.2.10"}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scan(b, fake, targets, ports)
+	}
+}
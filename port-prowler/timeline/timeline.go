@@ -0,0 +1,124 @@
+// Package timeline follows the state of ports probed over and over, as
+// when watching a firewall change window: it reports every transition
+// (open to filtered, filtered to closed, ...) and how long each state
+// lasted, so fail-open and fail-closed behaviour shows up.
+package timeline
+
+import (
+	"sort"
+	"time"
+)
+
+// Transition is a port changing state between two probes.
+type Transition struct {
+	Port   string        `json:"port"` // e.g. "db.example:5432/tcp"
+	From   string        `json:"from"` // empty for the first probe
+	To     string        `json:"to"`
+	At     time.Time     `json:"at"`               // when the first probe in the new state ran
+	Lasted time.Duration `json:"-"`                // how long From held
+	Probes int           `json:"probes,omitempty"` // probes that saw From
+
+	LastedMillis int64 `json:"lasted_ms,omitempty"`
+}
+
+// Summary is the history of one port over the whole run.
+type Summary struct {
+	Port        string                   `json:"port"`
+	Probes      int                      `json:"probes"`
+	Transitions int                      `json:"transitions"` // not counting the first probe
+	Time        map[string]time.Duration `json:"-"`           // time spent per state
+	Count       map[string]int           `json:"count"`       // probes per state
+
+	TimeMillis map[string]int64 `json:"time_ms"`
+}
+
+type port struct {
+	state  string
+	since  time.Time // first probe in state
+	last   time.Time // latest probe
+	probes int       // probes in state
+	sum    Summary
+}
+
+// Timeline records probes of any number of ports, each named by a key.
+// It is not safe for concurrent use.
+type Timeline struct {
+	order []string
+	ports map[string]*port
+}
+
+// New returns an empty timeline.
+func New() *Timeline {
+	return &Timeline{ports: make(map[string]*port)}
+}
+
+// Observe records that a probe at time at saw key in state. It returns
+// the transition when the state differs from the previous probe's, and
+// for the first probe of key (with an empty From).
+func (t *Timeline) Observe(key, state string, at time.Time) (Transition, bool) {
+	p, ok := t.ports[key]
+	if !ok {
+		p = &port{state: state, since: at, last: at, probes: 1}
+		p.sum = Summary{Port: key, Probes: 1, Time: map[string]time.Duration{}, Count: map[string]int{state: 1}}
+		t.ports[key] = p
+		t.order = append(t.order, key)
+		return Transition{Port: key, To: state, At: at}, true
+	}
+	// The time up to this probe belongs to the state seen before it.
+	p.sum.Time[p.state] += at.Sub(p.last)
+	p.last = at
+	p.sum.Probes++
+	p.sum.Count[state]++
+	if state == p.state {
+		p.probes++
+		return Transition{}, false
+	}
+	tr := Transition{Port: key, From: p.state, To: state, At: at, Lasted: at.Sub(p.since), Probes: p.probes}
+	tr.LastedMillis = tr.Lasted.Milliseconds()
+	p.state, p.since, p.probes = state, at, 1
+	p.sum.Transitions++
+	return tr, true
+}
+
+// Summaries returns the history of every port in the order they were
+// first seen, counting the time from each port's last probe to end in
+// its last state.
+func (t *Timeline) Summaries(end time.Time) []Summary {
+	out := make([]Summary, 0, len(t.order))
+	for _, key := range t.order {
+		p := t.ports[key]
+		s := p.sum
+		s.Time = make(map[string]time.Duration, len(p.sum.Time)+1)
+		for k, v := range p.sum.Time {
+			s.Time[k] = v
+		}
+		if end.After(p.last) {
+			s.Time[p.state] += end.Sub(p.last)
+		}
+		s.TimeMillis = make(map[string]int64, len(s.Time))
+		for k, v := range s.Time {
+			s.TimeMillis[k] = v.Milliseconds()
+		}
+		s.Count = make(map[string]int, len(p.sum.Count))
+		for k, v := range p.sum.Count {
+			s.Count[k] = v
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// States returns the states of s, most probed first.
+func (s Summary) States() []string {
+	states := make([]string, 0, len(s.Count))
+	for st := range s.Count {
+		states = append(states, st)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if s.Count[states[i]] != s.Count[states[j]] {
+			return s.Count[states[i]] > s.Count[states[j]]
+		}
+		return states[i] < states[j]
+	})
+	return states
+}
@@ -0,0 +1,52 @@
+package timeline
+
+import (
+	"testing"
+	"time"
+)
+
+func TestObserve(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return t0.Add(time.Duration(s) * time.Second) }
+	tl := New()
+
+	var got []Transition
+	for i, st := range []string{"open", "open", "open", "filtered", "filtered", "open"} {
+		if tr, ok := tl.Observe("db:5432/tcp", st, at(i*10)); ok {
+			got = append(got, tr)
+		}
+		if i == 0 {
+			tl.Observe("web:443/tcp", "open", at(0))
+		}
+	}
+	if len(got) != 3 || got[0].From != "" || got[0].To != "open" {
+		t.Fatalf("transitions %+v", got)
+	}
+	if tr := got[1]; tr.From != "open" || tr.To != "filtered" || !tr.At.Equal(at(30)) || tr.Lasted != 30*time.Second || tr.Probes != 3 || tr.LastedMillis != 30000 {
+		t.Errorf("open -> filtered: %+v", tr)
+	}
+	if tr := got[2]; tr.From != "filtered" || tr.Lasted != 20*time.Second || tr.Probes != 2 {
+		t.Errorf("filtered -> open: %+v", tr)
+	}
+
+	sums := tl.Summaries(at(60))
+	if len(sums) != 2 || sums[0].Port != "db:5432/tcp" || sums[1].Port != "web:443/tcp" {
+		t.Fatalf("summaries %+v", sums)
+	}
+	s := sums[0]
+	if s.Probes != 6 || s.Transitions != 2 || s.Time["open"] != 40*time.Second || s.Time["filtered"] != 20*time.Second || s.TimeMillis["filtered"] != 20000 {
+		t.Errorf("db summary %+v", s)
+	}
+	if st := s.States(); len(st) != 2 || st[0] != "open" || s.Count["filtered"] != 2 {
+		t.Errorf("states %v, counts %v", st, s.Count)
+	}
+	if w := sums[1]; w.Probes != 1 || w.Transitions != 0 || w.Time["open"] != time.Minute {
+		t.Errorf("web summary %+v", w)
+	}
+
+	// Summaries leave the timeline as it was.
+	tl.Observe("db:5432/tcp", "open", at(70))
+	if s := tl.Summaries(at(70))[0]; s.Time["open"] != 50*time.Second || s.Probes != 7 {
+		t.Errorf("after another probe: %+v", s)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"portprowler/logging"
+	"portprowler/netutil"
+	"portprowler/port"
+	"portprowler/scanner"
+	"portprowler/timeline"
+)
+
+// trackTarget is one host:port followed by `portprowler track`.
+type trackTarget struct {
+	name string // as printed, e.g. "db:5432/tcp"
+	ip   string
+	port uint16
+}
+
+// runTrack implements `portprowler track host:port...`: the ports are
+// probed every --interval and each change of state is printed with the
+// time it was first seen and how long the previous state held, followed
+// by a summary of the time spent in each state. It is meant for firewall
+// change windows, to see when a rule took effect and whether a port
+// failed open or closed while it was being changed. With --expect, the
+// exit status is 1 when any probe saw another state.
+func runTrack(args []string) int {
+	fs := flag.NewFlagSet("track", flag.ContinueOnError)
+	interval := fs.Duration("interval", time.Second, "time between the start of one round of probes and the next")
+	count := fs.Int("count", 0, "stop after this many probes of each port (0 runs until interrupted or --duration)")
+	duration := fs.Duration("duration", 0, "stop after this long (0 runs until interrupted or --count)")
+	timeout := fs.Duration("t", time.Second, "probe timeout; a port that does not answer in time is filtered")
+	udp := fs.Bool("udp", false, "probe udp instead of tcp")
+	expect := fs.String("expect", "", "exit 1 when any probe saw a state other than this one: open, closed or filtered")
+	asJSON := fs.Bool("json", false, "print transitions and summaries as JSON lines")
+	silent := fs.Bool("silent", false, "print nothing but the transitions and summaries")
+	verbose := fs.Bool("v", false, "verbose output")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s track [flags] <host:port> [host:port...]\n", progName())
+		fs.PrintDefaults()
+	}
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return parseStatus(err)
+	}
+	logging.SetSilent(*silent)
+	if len(names) == 0 {
+		return exitStatus(&exitError{code: 2, msg: "error: track needs at least one host:port", usage: true}, fs)
+	}
+	if *interval <= 0 || *timeout <= 0 || *count < 0 || *duration < 0 {
+		return exitStatus(usageErr("error: --interval and -t must be positive, --count and --duration not negative"), fs)
+	}
+	switch *expect {
+	case "", "open", "closed", "filtered":
+	default:
+		return exitStatus(usageErr("error: --expect must be open, closed or filtered"), fs)
+	}
+	proto := "tcp"
+	if *udp {
+		proto = "udp"
+	}
+	var targets []trackTarget
+	for _, name := range names {
+		host, p, serr := netutil.SplitTarget(name)
+		if serr != nil {
+			return exitStatus(usageErr("error: %v", serr), fs)
+		}
+		if p == 0 {
+			return exitStatus(usageErr("error: %s names no port (want host:port, e.g. db:5432 or [::1]:80)", name), fs)
+		}
+		addrs, rerr := netutil.ResolveTarget(host, netutil.ResolveOptions{DualStack: true})
+		if rerr != nil {
+			return exitStatus(runtimeErr("error: failed to resolve %s: %v", host, rerr), fs)
+		}
+		targets = append(targets, trackTarget{name: name + "/" + proto, ip: addrs[0], port: p})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+	logging.Infof("Tracking %d port(s) every %s; interrupt to stop", len(targets), *interval)
+
+	enc := json.NewEncoder(os.Stdout)
+	tl := timeline.New()
+	unexpected := false
+	tick := time.NewTicker(*interval)
+	defer tick.Stop()
+probes:
+	for n := 1; ; n++ {
+		at := time.Now()
+		results := make([]port.PortResult, len(targets))
+		var wg sync.WaitGroup
+		for i, t := range targets {
+			wg.Add(1)
+			go func(i int, t trackTarget) {
+				defer wg.Done()
+				if *udp {
+					results[i] = scanner.UDPScan(ctx, t.ip, t.port, *timeout, *verbose)
+				} else {
+					results[i] = scanner.TCPScan(ctx, t.ip, t.port, *timeout, *verbose)
+				}
+			}(i, t)
+		}
+		wg.Wait()
+		// A probe cut short by the interrupt says nothing about the port.
+		if ctx.Err() != nil {
+			break
+		}
+		for i, t := range targets {
+			state := results[i].State
+			if *expect != "" && state != *expect {
+				unexpected = true
+			}
+			tr, changed := tl.Observe(t.name, state, at)
+			if !changed {
+				continue
+			}
+			if *asJSON {
+				enc.Encode(struct {
+					Event string `json:"event"`
+					timeline.Transition
+				}{"transition", tr})
+			} else {
+				fmt.Println(formatTransition(tr))
+			}
+		}
+		if *count > 0 && n >= *count {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break probes
+		case <-tick.C:
+		}
+	}
+
+	for _, s := range tl.Summaries(time.Now()) {
+		if *asJSON {
+			enc.Encode(struct {
+				Event string `json:"event"`
+				timeline.Summary
+			}{"summary", s})
+		} else {
+			fmt.Println(formatTrackSummary(s))
+		}
+	}
+	if unexpected {
+		return 1
+	}
+	return 0
+}
+
+// formatTransition renders tr as a line of `portprowler track` output.
+func formatTransition(tr timeline.Transition) string {
+	line := tr.At.Format("2006-01-02T15:04:05.000Z07:00") + " " + tr.Port + " "
+	if tr.From == "" {
+		return line + tr.To
+	}
+	return fmt.Sprintf("%s%s -> %s (%s for %s, %d probe(s))", line, tr.From, tr.To, tr.From, tr.Lasted.Round(time.Millisecond), tr.Probes)
+}
+
+// formatTrackSummary renders s as the closing line for its port.
+func formatTrackSummary(s timeline.Summary) string {
+	var parts []string
+	for _, st := range s.States() {
+		parts = append(parts, fmt.Sprintf("%s %s (%d)", st, s.Time[st].Round(time.Millisecond), s.Count[st]))
+	}
+	return fmt.Sprintf("%s: %d probe(s), %d transition(s); %s", s.Port, s.Probes, s.Transitions, strings.Join(parts, ", "))
+}
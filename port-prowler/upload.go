@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"mime"
+	"os"
+	"path/filepath"
+
+	"portprowler/age"
+	"portprowler/logging"
+	"portprowler/output"
+	"portprowler/report"
+	"portprowler/signing"
+	"portprowler/upload"
+)
+
+// uploadReports copies the files of this scan to --upload, under a
+// directory named after the scan's start time so runs do not overwrite
+// each other: the files of reportPaths and their --sign signatures, or
+// the JSON report when the scan wrote no file.
+func (f *scanFlags) uploadReports(ctx context.Context, rep report.ScanReport, summary string, specs []output.Spec, recipients []*age.Recipient) error {
+	if f.upload == "" {
+		return nil
+	}
+	loc, err := upload.Parse(f.upload)
+	if err != nil {
+		return runtimeErr("--upload: %v", err)
+	}
+	c, err := upload.FromEnv(loc)
+	if err != nil {
+		return runtimeErr("--upload: %v", err)
+	}
+	c.SSE, c.KMSKey = f.uploadSSE, f.uploadKMS
+
+	var paths []string
+	for _, p := range f.reportPaths(specs) {
+		paths = append(paths, p)
+		if f.sign != "" {
+			paths = append(paths, p+signing.Ext)
+		}
+	}
+	files := make(map[string][]byte)
+	var names []string
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return runtimeErr("--upload: %v", err)
+		}
+		name := filepath.Base(p)
+		if _, dup := files[name]; !dup {
+			names = append(names, name)
+		}
+		files[name] = data
+	}
+	if len(names) == 0 {
+		var buf bytes.Buffer
+		if err := output.Render("json", rep, summary, &buf); err != nil {
+			return runtimeErr("failed to render json output: %v", err)
+		}
+		name, data := "report.json", buf.Bytes()
+		if len(recipients) > 0 {
+			enc, err := age.Encrypt(data, recipients...)
+			if err != nil {
+				return runtimeErr("failed to encrypt the report: %v", err)
+			}
+			name, data = name+age.Ext, enc
+		}
+		names, files[name] = []string{name}, data
+		if f.sign != "" {
+			key, err := signing.LoadPrivateKey(f.sign)
+			if err != nil {
+				return runtimeErr("--sign: %v", err)
+			}
+			names, files[name+signing.Ext] = append(names, name+signing.Ext), signing.Sign(key, data)
+		}
+	}
+
+	dir := rep.Meta.Started.UTC().Format("20060102-150405") + "/"
+	for _, name := range names {
+		u, err := c.Put(ctx, dir+name, files[name], mime.TypeByExtension(filepath.Ext(name)))
+		if err != nil {
+			return runtimeErr("failed to upload %s: %v", name, err)
+		}
+		logging.Infof("Uploaded %s", u)
+	}
+	return nil
+}
+
+// reportPaths lists the files writeOutputs wrote: the -o files, the -f
+// table and the fingerprint export, named with age.Ext under --encrypt.
+func (f *scanFlags) reportPaths(specs []output.Spec) []string {
+	var paths []string
+	for _, spec := range specs {
+		if spec.Path != "" {
+			paths = append(paths, spec.Path)
+		}
+	}
+	if f.fileOut != "" {
+		paths = append(paths, filepath.Join("result", f.fileOut))
+	}
+	if f.fingerprintOut != "" {
+		paths = append(paths, f.fingerprintOut)
+	}
+	if len(f.encrypt) > 0 {
+		for i := range paths {
+			paths[i] += age.Ext
+		}
+	}
+	return paths
+}
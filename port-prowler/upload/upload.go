@@ -0,0 +1,257 @@
+// Package upload copies report files to object storage (Amazon S3, S3
+// compatible stores and Google Cloud Storage), so scans run from
+// ephemeral machines keep their results.
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Server-side encryption modes.
+const (
+	SSEAES256 = "aes256" // keys managed by the store
+	SSEKMS    = "kms"    // a KMS key (--upload-kms-key, or the store's default)
+)
+
+// Location is where reports are uploaded: a bucket and a key prefix.
+type Location struct {
+	Scheme string // "s3" or "gs"
+	Bucket string
+	Prefix string // "" or ends with "/"
+}
+
+// Parse parses an s3://bucket/prefix/ or gs://bucket/prefix/ URL.
+func Parse(s string) (Location, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return Location{}, err
+	}
+	if u.Scheme != "s3" && u.Scheme != "gs" {
+		return Location{}, fmt.Errorf("%q: want s3://bucket/prefix/ or gs://bucket/prefix/", s)
+	}
+	if u.Host == "" {
+		return Location{}, fmt.Errorf("%q: no bucket", s)
+	}
+	prefix := strings.TrimPrefix(u.Path, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return Location{Scheme: u.Scheme, Bucket: u.Host, Prefix: prefix}, nil
+}
+
+func (l Location) String() string {
+	return l.Scheme + "://" + l.Bucket + "/" + l.Prefix
+}
+
+// Client puts objects with AWS Signature Version 4, which Google Cloud
+// Storage also accepts with HMAC keys.
+type Client struct {
+	Loc Location
+	// Endpoint is the store's base URL; empty is AWS for s3 and
+	// storage.googleapis.com for gs. Buckets are addressed by path on
+	// any other endpoint (MinIO, Ceph, ...).
+	Endpoint  string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Token     string // session token of temporary credentials
+	SSE       string // "", SSEAES256 or SSEKMS
+	KMSKey    string // key id or ARN (s3), key name (gs) for SSEKMS
+	HTTP      *http.Client
+}
+
+// FromEnv returns a client for loc with credentials from the
+// environment: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+// AWS_SESSION_TOKEN, AWS_REGION (or AWS_DEFAULT_REGION) and
+// AWS_ENDPOINT_URL for s3; GCS_HMAC_ACCESS_ID and GCS_HMAC_SECRET for gs.
+func FromEnv(loc Location) (*Client, error) {
+	c := &Client{Loc: loc}
+	if loc.Scheme == "gs" {
+		c.AccessKey, c.SecretKey = os.Getenv("GCS_HMAC_ACCESS_ID"), os.Getenv("GCS_HMAC_SECRET")
+		c.Region = "auto"
+		if c.AccessKey == "" || c.SecretKey == "" {
+			return nil, errors.New("gs uploads need an HMAC key in GCS_HMAC_ACCESS_ID and GCS_HMAC_SECRET")
+		}
+		return c, nil
+	}
+	c.AccessKey, c.SecretKey = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	c.Token = os.Getenv("AWS_SESSION_TOKEN")
+	c.Endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	c.Region = os.Getenv("AWS_REGION")
+	if c.Region == "" {
+		c.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if c.Region == "" {
+		c.Region = "us-east-1"
+	}
+	if c.AccessKey == "" || c.SecretKey == "" {
+		return nil, errors.New("s3 uploads need AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return c, nil
+}
+
+// Put stores data under the location's prefix followed by name and
+// returns the object's URL.
+func (c *Client) Put(ctx context.Context, name string, data []byte, contentType string) (string, error) {
+	key := c.Loc.Prefix + name
+	u, err := c.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	switch {
+	case c.SSE == SSEKMS && c.Loc.Scheme == "gs":
+		if c.KMSKey != "" {
+			req.Header.Set("X-Goog-Encryption-Kms-Key-Name", c.KMSKey)
+		}
+	case c.SSE == SSEKMS:
+		req.Header.Set("X-Amz-Server-Side-Encryption", "aws:kms")
+		if c.KMSKey != "" {
+			req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", c.KMSKey)
+		}
+	case c.SSE == SSEAES256 && c.Loc.Scheme == "s3":
+		req.Header.Set("X-Amz-Server-Side-Encryption", "AES256")
+	}
+	if c.Token != "" {
+		req.Header.Set("X-Amz-Security-Token", c.Token)
+	}
+	sum := sha256.Sum256(data)
+	c.sign(req, hex.EncodeToString(sum[:]), time.Now())
+
+	client := c.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("put %s: %s %s", key, resp.Status, bytes.TrimSpace(msg))
+	}
+	return c.Loc.Scheme + "://" + c.Loc.Bucket + "/" + key, nil
+}
+
+// objectURL addresses key virtual-host style on AWS and by path
+// elsewhere.
+func (c *Client) objectURL(key string) (string, error) {
+	path := "/" + escapePath(key)
+	switch {
+	case c.Endpoint != "":
+		base, err := url.Parse(c.Endpoint)
+		if err != nil || base.Host == "" {
+			return "", fmt.Errorf("invalid endpoint %q", c.Endpoint)
+		}
+		return strings.TrimSuffix(c.Endpoint, "/") + "/" + c.Loc.Bucket + path, nil
+	case c.Loc.Scheme == "gs":
+		return "https://storage.googleapis.com/" + c.Loc.Bucket + path, nil
+	case strings.Contains(c.Loc.Bucket, "."):
+		// Dotted names do not match the wildcard certificate.
+		return "https://s3." + c.Region + ".amazonaws.com/" + c.Loc.Bucket + path, nil
+	}
+	return "https://" + c.Loc.Bucket + ".s3." + c.Region + ".amazonaws.com" + path, nil
+}
+
+// sign adds a Signature Version 4 Authorization header covering the host,
+// the headers already set and the payload hash.
+func (c *Client) sign(req *http.Request, payloadHash string, now time.Time) {
+	stamp := now.UTC().Format("20060102T150405Z")
+	day := stamp[:8]
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonHeaders.String(),
+		signed,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + c.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretKey), day)
+	for _, part := range []string{c.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", c.AccessKey, scope, signed, sig))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+func canonicalQuery(q url.Values) string {
+	var parts []string
+	for k, vs := range q {
+		for _, v := range vs {
+			parts = append(parts, escape(k)+"="+escape(v))
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "&")
+}
+
+// escapePath escapes every segment of key as SigV4 requires.
+func escapePath(key string) string {
+	segs := strings.Split(key, "/")
+	for i, s := range segs {
+		segs[i] = escape(s)
+	}
+	return strings.Join(segs, "/")
+}
+
+// escape percent-encodes everything but RFC 3986 unreserved characters.
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if 'A' <= ch && ch <= 'Z' || 'a' <= ch && ch <= 'z' || '0' <= ch && ch <= '9' || strings.IndexByte("-_.~", ch) >= 0 {
+			b.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", ch)
+	}
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"portprowler/logging"
+	"portprowler/output"
+	"portprowler/signing"
+)
+
+// signReports writes a --sign signature next to every file of
+// reportPaths.
+func (f *scanFlags) signReports(specs []output.Spec) error {
+	if f.sign == "" {
+		return nil
+	}
+	key, err := signing.LoadPrivateKey(f.sign)
+	if err != nil {
+		return runtimeErr("--sign: %v", err)
+	}
+	for _, p := range f.reportPaths(specs) {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return runtimeErr("failed to sign %s: %v", p, err)
+		}
+		if err := output.WriteAtomic(p+signing.Ext, signing.Sign(key, data)); err != nil {
+			return runtimeErr("failed to write the signature of %s: %v", p, err)
+		}
+		if f.verbose {
+			logging.Verbosef("Signed %s", p)
+		}
+	}
+	return nil
+}
+
+// runVerify implements `portprowler verify`: it checks each file against
+// its .sig with the public key and prints OK or FAILED per file, like
+// sha256sum -c. It exits 0 when every file verifies and 1 otherwise.
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	keyPath := fs.String("key", "", "Ed25519 public key (PEM) the files were signed for; a private key also works (required)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s verify --key <public.pem> <file> [file...]\n", progName())
+		fmt.Fprintf(fs.Output(), "Each file is checked against <file>%s as written by --sign.\n", signing.Ext)
+		fs.PrintDefaults()
+	}
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return parseStatus(err)
+	}
+	if *keyPath == "" || len(names) == 0 {
+		return exitStatus(&exitError{code: 2, msg: "error: verify needs --key and at least one file", usage: true}, fs)
+	}
+	pub, err := signing.LoadPublicKey(*keyPath)
+	if err != nil {
+		return exitStatus(usageErr("error: --key: %v", err), fs)
+	}
+	logging.Infof("Verifying with key %s", signing.Fingerprint(pub))
+
+	status := 0
+	for _, name := range names {
+		if err := verifyFile(pub, name); err != nil {
+			fmt.Printf("%s: FAILED (%v)\n", name, err)
+			status = 1
+			continue
+		}
+		fmt.Printf("%s: OK\n", name)
+	}
+	return status
+}
+
+func verifyFile(pub ed25519.PublicKey, name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	sig, err := os.ReadFile(name + signing.Ext)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no %s", name+signing.Ext)
+	}
+	if err != nil {
+		return err
+	}
+	return signing.Verify(pub, data, sig)
+}
@@ -0,0 +1,65 @@
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// FeedURL is the release feed --check-update reads by default: the latest
+// published GitHub release of this project.
+const FeedURL = "https://api.github.com/repos/gergolesk/portprowler/releases/latest"
+
+// maxFeedSize bounds how much of the feed is read.
+const maxFeedSize = 1 << 20
+
+// Release is the latest release as published in the feed.
+type Release struct {
+	Version   string `json:"tag_name"`
+	URL       string `json:"html_url"`
+	Published string `json:"published_at"`
+}
+
+// FetchRelease reads the release feed from an https URL or from a local
+// file, so hosts without internet access can check against a mirrored
+// copy of the feed.
+func FetchRelease(ctx context.Context, feed string) (Release, error) {
+	var rel Release
+	var body io.Reader
+	switch {
+	case strings.HasPrefix(feed, "https://") || strings.HasPrefix(feed, "http://"):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, feed, nil)
+		if err != nil {
+			return rel, err
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("User-Agent", "portprowler/"+Get().Version)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return rel, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return rel, fmt.Errorf("release feed %s: %s", feed, resp.Status)
+		}
+		body = resp.Body
+	default:
+		f, err := os.Open(strings.TrimPrefix(feed, "file://"))
+		if err != nil {
+			return rel, err
+		}
+		defer f.Close()
+		body = f
+	}
+	if err := json.NewDecoder(io.LimitReader(body, maxFeedSize)).Decode(&rel); err != nil {
+		return rel, fmt.Errorf("release feed %s: %v", feed, err)
+	}
+	if rel.Version == "" {
+		return rel, fmt.Errorf("release feed %s: no tag_name", feed)
+	}
+	return rel, nil
+}
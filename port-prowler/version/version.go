@@ -0,0 +1,141 @@
+// Package version identifies the running build and compares it with the
+// latest published release.
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X portprowler/version.Version=v1.2.0 -X portprowler/version.Commit=$(git rev-parse HEAD)"
+//
+// Unset values are filled from the VCS data the Go toolchain embeds.
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// Info describes the running build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"` // built from a dirty work tree
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"` // GOOS/GOARCH
+}
+
+// Get returns the build information of the running binary.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = s.Value
+			}
+		case "vcs.time":
+			if info.Date == "" {
+				info.Date = s.Value
+			}
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
+
+// String renders the build on one line, e.g.
+// "v1.2.0 (commit 1a2b3c4d5e6f, 2024-05-01T10:00:00Z) go1.22.3 linux/amd64".
+func (i Info) String() string {
+	s := i.Version
+	var details []string
+	if i.Commit != "" {
+		c := i.Commit
+		if len(c) > 12 {
+			c = c[:12]
+		}
+		if i.Modified {
+			c += "-dirty"
+		}
+		details = append(details, "commit "+c)
+	}
+	if i.Date != "" {
+		details = append(details, i.Date)
+	}
+	if len(details) > 0 {
+		s += " (" + strings.Join(details, ", ") + ")"
+	}
+	return s + " " + i.GoVersion + " " + i.Platform
+}
+
+// Compare compares two vMAJOR.MINOR.PATCH[-pre] versions and returns -1,
+// 0 or +1. A pre-release sorts before its release; pre-release labels are
+// otherwise compared as strings.
+func Compare(a, b string) (int, error) {
+	pa, prea, err := parse(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, preb, err := parse(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range pa {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	switch {
+	case prea == preb:
+		return 0, nil
+	case prea == "":
+		return 1, nil
+	case preb == "":
+		return -1, nil
+	case prea < preb:
+		return -1, nil
+	}
+	return 1, nil
+}
+
+func parse(v string) ([3]int, string, error) {
+	var nums [3]int
+	s := strings.TrimPrefix(v, "v")
+	s, _, _ = strings.Cut(s, "+") // build metadata does not order
+	s, pre, _ := strings.Cut(s, "-")
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nums, "", fmt.Errorf("invalid version %q (want vMAJOR.MINOR.PATCH)", v)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nums, "", fmt.Errorf("invalid version %q (want vMAJOR.MINOR.PATCH)", v)
+		}
+		nums[i] = n
+	}
+	return nums, pre, nil
+}
@@ -0,0 +1,58 @@
+package version
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.3", "v1.2.3", 0},
+		{"v1.2.3", "v1.10.0", -1},
+		{"1.3.0", "v1.2.9", 1},
+		{"v2.0.0-rc.1", "v2.0.0", -1},
+		{"v2.0.0+build.5", "v2.0.0", 0},
+	}
+	for _, c := range cases {
+		got, err := Compare(c.a, c.b)
+		if err != nil || got != c.want {
+			t.Errorf("Compare(%q, %q) = %d, %v; want %d", c.a, c.b, got, err, c.want)
+		}
+	}
+	if _, err := Compare("dev", "v1.0.0"); err == nil {
+		t.Errorf("expected error comparing a dev build")
+	}
+}
+
+func TestFetchRelease(t *testing.T) {
+	const feed = `{"tag_name":"v1.4.0","html_url":"https://example.invalid/v1.4.0","published_at":"2024-05-01T10:00:00Z"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(feed))
+	}))
+	defer srv.Close()
+	rel, err := FetchRelease(context.Background(), srv.URL)
+	if err != nil || rel.Version != "v1.4.0" || rel.URL != "https://example.invalid/v1.4.0" {
+		t.Fatalf("FetchRelease(url) = %+v, %v", rel, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "latest.json")
+	if err := os.WriteFile(path, []byte(feed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if rel, err := FetchRelease(context.Background(), path); err != nil || rel.Version != "v1.4.0" {
+		t.Fatalf("FetchRelease(file) = %+v, %v", rel, err)
+	}
+	if err := os.WriteFile(path, []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FetchRelease(context.Background(), path); err == nil {
+		t.Fatalf("expected error for a feed without tag_name")
+	}
+}
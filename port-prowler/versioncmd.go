@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"portprowler/version"
+)
+
+// runVersion implements `portprowler version [--check-update] [--feed f]`:
+// it prints the build information and, when asked, compares it with the
+// latest release in the feed.
+func runVersion(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	checkUpdate := fs.Bool("check-update", false, "compare with the latest published release")
+	feed := fs.String("feed", version.FeedURL, "release feed URL, or a local copy of it for offline hosts")
+	timeout := fs.Duration("timeout", 5*time.Second, "timeout for fetching the release feed")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	info := version.Get()
+	fmt.Fprintf(w, "portprowler %s\n", info)
+	if !*checkUpdate {
+		return 0
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	rel, err := version.FetchRelease(ctx, *feed)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not check for updates: %v\n", err)
+		return 4
+	}
+	cmp, err := version.Compare(info.Version, rel.Version)
+	switch {
+	case err != nil:
+		fmt.Fprintf(w, "Latest release: %s (%s); this build is not a release\n", rel.Version, rel.URL)
+	case cmp < 0:
+		fmt.Fprintf(w, "Update available: %s (%s)\n", rel.Version, rel.URL)
+	default:
+		fmt.Fprintf(w, "Up to date (latest release %s)\n", rel.Version)
+	}
+	return 0
+}
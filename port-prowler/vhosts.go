@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"portprowler/detector"
+	"portprowler/logging"
+	"portprowler/netutil"
+	"portprowler/port"
+	"portprowler/report"
+)
+
+// enumerateVHosts re-requests every open web port with each hostname that
+// resolved to its address and stores the comparison on all results for
+// that port. Targets given as IP literals contribute no names.
+func enumerateVHosts(ctx context.Context, cfgFor func(ip string) detector.Config, targets []port.Target, rep *report.ScanReport) {
+	names := make(map[string][]string)
+	for _, t := range targets {
+		if netutil.ParseIP(t.Name) == nil && !contains(names[t.IP], t.Name) {
+			names[t.IP] = append(names[t.IP], t.Name)
+		}
+	}
+	done := make(map[string][]port.VHost)
+	for i := range rep.Hosts {
+		for j := range rep.Hosts[i].Results {
+			r := &rep.Hosts[i].Results[j]
+			if len(names[r.IP]) == 0 || r.State != "open" || r.Proto != "tcp" || !detector.IsWebResult(*r) {
+				continue
+			}
+			key := fmt.Sprintf("%s:%d", r.IP, r.Port)
+			vh, ok := done[key]
+			if !ok {
+				vh = detector.EnumerateVHosts(ctx, cfgFor(r.IP), *r, names[r.IP])
+				done[key] = vh
+				for _, v := range vh {
+					if v.Differs {
+						logging.Infof("%s serves different content for %s", key, v.Name)
+					}
+				}
+			}
+			r.VHosts = vh
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"portprowler/alert"
+	"portprowler/logging"
+	"portprowler/output"
+	"portprowler/report"
+	"portprowler/sigs"
+)
+
+// runWatch implements `portprowler watch`: the scan is repeated every
+// --interval. The first scan is written like `portprowler scan` would;
+// after that stdout lists what changed since the previous scan, while -o
+// files and -f are rewritten with the latest results. With --alert rules,
+// changes that pass a rule are also raised as alerts on stderr. With
+// --notify-email, the first scan and later scans that changed something
+// (or raised an alert, when there are rules) are emailed, and --incident
+// pages on every scan; the service folds repeats into the open incident.
+// --issue files a ticket the first time a port is found exposed. A failed
+// --upload is a warning here, since the next scan uploads again.
+func runWatch(args []string) int {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	f := defaultScanFlags()
+	f.register(fs)
+	interval := fs.Duration("interval", time.Hour, "time between the start of one scan and the next")
+	count := fs.Int("count", 0, "stop after this many scans (0 runs until interrupted)")
+	var alertRules stringList
+	fs.Var(&alertRules, "alert", "raise an alert when the changes since the previous scan pass this rule, e.g. opened>5 or severity>=high (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s watch [flags] <target> [target...]\n", progName())
+		fs.PrintDefaults()
+	}
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return parseStatus(err)
+	}
+	logging.SetSilent(f.silent)
+	if *interval <= 0 || *count < 0 {
+		return exitStatus(usageErr("error: --interval must be positive and --count must not be negative"), fs)
+	}
+	if f.replay != "" {
+		return exitStatus(usageErr("error: --replay cannot be used with watch"), fs)
+	}
+	var rules []alert.Rule
+	for _, s := range alertRules {
+		r, rerr := alert.Parse(s)
+		if rerr != nil {
+			return exitStatus(usageErr("error: --alert: %v", rerr), fs)
+		}
+		if r.Metric == alert.MetricSeverity && f.scanContext == "" {
+			return exitStatus(usageErr("error: --alert %s needs --context to rate ports", r), fs)
+		}
+		rules = append(rules, r)
+	}
+	p, err := f.plan(names)
+	if err != nil {
+		return exitStatus(err, fs)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if f.sigFile != "" {
+		go sigs.Watch(ctx, f.sigFile, 5*time.Second, func(n int, err error) {
+			if err != nil {
+				logging.Warnf("--sig-file reload failed, keeping previous signatures: %v", err)
+				return
+			}
+			logging.Infof("Reloaded %d signature(s) from %s", n, f.sigFile)
+		})
+	}
+
+	// Once the first scan is on stdout, later scans only update files.
+	var fileSpecs []output.Spec
+	for _, spec := range p.specs {
+		if spec.Path != "" {
+			fileSpecs = append(fileSpecs, spec)
+		}
+	}
+
+	var prev *report.ScanReport
+	next := time.Now()
+	for n := 1; *count == 0 || n <= *count; n++ {
+		if prev != nil {
+			next = next.Add(*interval)
+			logging.Infof("Next scan at %s", next.Format(time.RFC3339))
+			select {
+			case <-ctx.Done():
+				return 0
+			case <-time.After(time.Until(next)):
+			}
+		}
+		rep, snap, err := p.run(ctx)
+		if ctx.Err() != nil {
+			return 0 // interrupted; a partial scan is not compared
+		}
+		if err != nil {
+			return exitStatus(err, fs)
+		}
+		if f.save {
+			if err := saveHistory(rep); err != nil {
+				return exitStatus(err, fs)
+			}
+		}
+		if f.redact {
+			if err := redactReport(&rep, f.redactMap); err != nil {
+				return exitStatus(err, fs)
+			}
+		}
+		specs := p.specs
+		var changes []report.Change
+		var alerts []alert.Alert
+		if prev != nil {
+			changes = report.Diff(*prev, rep)
+			logging.Infof("Scan %d: %d change(s) since the previous scan", n, len(changes))
+			if err := writeChanges(changes, "table", os.Stdout); err != nil {
+				return exitStatus(runtimeErr("failed to write to stdout: %v", err), fs)
+			}
+			alerts = alert.Evaluate(rules, changes, rep)
+			for _, a := range alerts {
+				logging.Warnf("alert %s: %s", a.Rule, a.Reason)
+			}
+			specs = fileSpecs
+		}
+		if err := writeOutputs(rep, snap.Summary(), specs, f.fingerprintOut, f.fileOut, p.recipients); err != nil {
+			return exitStatus(err, fs)
+		}
+		// Mail the first scan, then only scans that changed something or,
+		// with --alert rules, raised an alert.
+		if prev == nil || len(alerts) > 0 || len(rules) == 0 && len(changes) > 0 {
+			f.notify(rep, snap.Summary(), changes, alerts)
+		}
+		if err := f.signReports(p.specs); err != nil {
+			return exitStatus(err, fs)
+		}
+		if err := f.uploadReports(ctx, rep, snap.Summary(), p.specs, p.recipients); err != nil {
+			logging.Warnf("%v", err)
+		}
+		f.page(ctx, rep, alerts)
+		f.fileIssues(ctx, rep)
+		prev = &rep
+	}
+	return 0
+}
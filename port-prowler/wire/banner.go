@@ -0,0 +1,37 @@
+package wire
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxBanner is the number of raw bytes ParseBanner looks at.
+const MaxBanner = 2048
+
+// ParseBanner turns the raw bytes a service sent into printable text:
+// input beyond MaxBanner is ignored, invalid UTF-8 and control characters
+// other than tab and line breaks become '.', and surrounding white space
+// is trimmed. Binary protocols thus keep their readable parts (versions,
+// product names) for signature matching and reports.
+func ParseBanner(raw []byte) string {
+	if len(raw) > MaxBanner {
+		raw = raw[:MaxBanner]
+	}
+	var b strings.Builder
+	b.Grow(len(raw))
+	for len(raw) > 0 {
+		r, size := utf8.DecodeRune(raw)
+		raw = raw[size:]
+		switch {
+		case r == utf8.RuneError && size <= 1:
+			b.WriteByte('.')
+		case r == '\t' || r == '\n' || r == '\r':
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0):
+			b.WriteByte('.')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
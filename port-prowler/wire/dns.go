@@ -0,0 +1,253 @@
+// Package wire decodes bytes received from probed hosts. Everything here
+// handles untrusted input: lengths and counts are checked against the
+// data before use, and malformed input yields an error, never a panic.
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// DNS header flag bits.
+const (
+	dnsFlagQR = 0x8000
+	dnsFlagTC = 0x0200
+	dnsFlagRA = 0x0080
+)
+
+// DNS record types and classes whose data or use the scanner knows.
+const (
+	DNSTypeA     = 1
+	DNSTypeNS    = 2
+	DNSTypeCNAME = 5
+	DNSTypePTR   = 12
+	DNSTypeTXT   = 16
+	DNSTypeAAAA  = 28
+	DNSTypeANY   = 255
+
+	DNSClassIN    = 1
+	DNSClassCHAOS = 3
+)
+
+// Limits from RFC 1035.
+const (
+	maxDNSName  = 255
+	maxDNSLabel = 63
+	maxPointers = 16 // compression pointers followed per name
+)
+
+// DNSMessage is a decoded DNS message.
+type DNSMessage struct {
+	ID        uint16
+	Response  bool // QR bit
+	Opcode    uint8
+	Truncated bool
+	Recursion bool // RA bit: the server offers recursion
+	RCode     uint8
+	Questions []DNSQuestion
+	Answers   []DNSRecord
+	Authority []DNSRecord
+	Extra     []DNSRecord
+}
+
+// DNSQuestion is one entry of the question section.
+type DNSQuestion struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+// DNSRecord is a resource record; Data is its raw RDATA.
+type DNSRecord struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+	Data  []byte
+	Text  string // Data decoded for A, AAAA, NS, CNAME, PTR and TXT records; empty otherwise
+}
+
+// ErrShortDNS is returned for data that ends before the message does.
+var ErrShortDNS = errors.New("dns: message truncated")
+
+// ParseDNSResponse decodes a DNS message (query or response) received over
+// UDP. Names are decompressed with loop and length limits; record data is
+// only decoded into Text for a few common types.
+func ParseDNSResponse(pkt []byte) (DNSMessage, error) {
+	var m DNSMessage
+	if len(pkt) < 12 {
+		return m, ErrShortDNS
+	}
+	m.ID = binary.BigEndian.Uint16(pkt[0:2])
+	flags := binary.BigEndian.Uint16(pkt[2:4])
+	m.Response = flags&dnsFlagQR != 0
+	m.Opcode = uint8(flags>>11) & 0x0f
+	m.Truncated = flags&dnsFlagTC != 0
+	m.Recursion = flags&dnsFlagRA != 0
+	m.RCode = uint8(flags & 0x0f)
+	counts := [4]int{}
+	for i := range counts {
+		counts[i] = int(binary.BigEndian.Uint16(pkt[4+2*i:]))
+	}
+	// Every question takes at least 5 bytes and every record 11, so
+	// counts beyond that are lies; reject them before allocating.
+	if need := 12 + 5*counts[0] + 11*(counts[1]+counts[2]+counts[3]); need > len(pkt) {
+		return m, fmt.Errorf("dns: section counts need %d bytes, have %d", need, len(pkt))
+	}
+
+	off := 12
+	for i := 0; i < counts[0]; i++ {
+		name, next, err := readName(pkt, off)
+		if err != nil {
+			return m, err
+		}
+		if next+4 > len(pkt) {
+			return m, ErrShortDNS
+		}
+		m.Questions = append(m.Questions, DNSQuestion{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(pkt[next:]),
+			Class: binary.BigEndian.Uint16(pkt[next+2:]),
+		})
+		off = next + 4
+	}
+	for s, dst := range []*[]DNSRecord{&m.Answers, &m.Authority, &m.Extra} {
+		for i := 0; i < counts[s+1]; i++ {
+			rr, next, err := readRecord(pkt, off)
+			if err != nil {
+				return m, err
+			}
+			*dst = append(*dst, rr)
+			off = next
+		}
+	}
+	return m, nil
+}
+
+func readRecord(pkt []byte, off int) (DNSRecord, int, error) {
+	name, off, err := readName(pkt, off)
+	if err != nil {
+		return DNSRecord{}, 0, err
+	}
+	if off+10 > len(pkt) {
+		return DNSRecord{}, 0, ErrShortDNS
+	}
+	rr := DNSRecord{
+		Name:  name,
+		Type:  binary.BigEndian.Uint16(pkt[off:]),
+		Class: binary.BigEndian.Uint16(pkt[off+2:]),
+		TTL:   binary.BigEndian.Uint32(pkt[off+4:]),
+	}
+	n := int(binary.BigEndian.Uint16(pkt[off+8:]))
+	off += 10
+	if off+n > len(pkt) {
+		return DNSRecord{}, 0, ErrShortDNS
+	}
+	rr.Data = pkt[off : off+n : off+n]
+	rr.Text = recordText(pkt, off, rr.Type, rr.Data)
+	return rr, off + n, nil
+}
+
+// recordText decodes data, the RDATA of a record of type typ starting at
+// off in pkt (names in it may point into pkt). Malformed data gives "".
+func recordText(pkt []byte, off int, typ uint16, data []byte) string {
+	switch typ {
+	case DNSTypeA, DNSTypeAAAA:
+		if ip, ok := netip.AddrFromSlice(data); ok && (len(data) == 4) == (typ == DNSTypeA) {
+			return ip.String()
+		}
+	case DNSTypeNS, DNSTypeCNAME, DNSTypePTR:
+		if name, end, err := readName(pkt, off); err == nil && end <= off+len(data) {
+			return name
+		}
+	case DNSTypeTXT:
+		// One or more length-prefixed strings, read as one.
+		var b strings.Builder
+		for i := 0; i < len(data); {
+			l := int(data[i])
+			if i+1+l > len(data) {
+				return ""
+			}
+			b.Write(data[i+1 : i+1+l])
+			i += 1 + l
+		}
+		return b.String()
+	}
+	return ""
+}
+
+// DNSRCodeName returns the mnemonic of a response code, e.g. "REFUSED".
+func DNSRCodeName(rc uint8) string {
+	switch rc {
+	case 0:
+		return "NOERROR"
+	case 1:
+		return "FORMERR"
+	case 2:
+		return "SERVFAIL"
+	case 3:
+		return "NXDOMAIN"
+	case 4:
+		return "NOTIMP"
+	case 5:
+		return "REFUSED"
+	}
+	return fmt.Sprintf("RCODE%d", rc)
+}
+
+// readName decodes the possibly compressed name at off and returns it
+// with the offset just past its encoding at off.
+func readName(pkt []byte, off int) (string, int, error) {
+	var labels []string
+	size := 0
+	end := -1 // where parsing resumes after the first pointer
+	pointers := 0
+	for {
+		if off >= len(pkt) {
+			return "", 0, ErrShortDNS
+		}
+		l := int(pkt[off])
+		switch l & 0xc0 {
+		case 0x00:
+			if l == 0 {
+				if end < 0 {
+					end = off + 1
+				}
+				if len(labels) == 0 {
+					return ".", end, nil
+				}
+				return strings.Join(labels, ".") + ".", end, nil
+			}
+			if off+1+l > len(pkt) {
+				return "", 0, ErrShortDNS
+			}
+			size += l + 1
+			if size > maxDNSName {
+				return "", 0, errors.New("dns: name too long")
+			}
+			labels = append(labels, string(pkt[off+1:off+1+l]))
+			off += 1 + l
+		case 0xc0:
+			if off+2 > len(pkt) {
+				return "", 0, ErrShortDNS
+			}
+			pointers++
+			if pointers > maxPointers {
+				return "", 0, errors.New("dns: too many compression pointers")
+			}
+			target := int(binary.BigEndian.Uint16(pkt[off:]) & 0x3fff)
+			if target >= off {
+				return "", 0, errors.New("dns: compression pointer does not point backwards")
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = target
+		default:
+			return "", 0, fmt.Errorf("dns: unsupported label type 0x%02x", l&0xc0)
+		}
+	}
+}
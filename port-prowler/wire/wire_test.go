@@ -0,0 +1,151 @@
+package wire
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// exampleResponse answers an A query for example.com with one record whose
+// owner name is a compression pointer to the question.
+var exampleResponse = []byte{
+	0x12, 0x34, 0x81, 0x80, 0, 1, 0, 1, 0, 0, 0, 0,
+	7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0, 0, 1, 0, 1,
+	0xc0, 12, 0, 1, 0, 1, 0, 0, 0x0e, 0x10, 0, 4, 192, 0, 2, 1,
+}
+
+func TestParseDNSResponse(t *testing.T) {
+	m, err := ParseDNSResponse(exampleResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != 0x1234 || !m.Response || m.RCode != 0 {
+		t.Errorf("header = %#x response=%v rcode=%d", m.ID, m.Response, m.RCode)
+	}
+	if len(m.Questions) != 1 || m.Questions[0].Name != "example.com." || m.Questions[0].Type != 1 {
+		t.Errorf("questions = %+v", m.Questions)
+	}
+	if len(m.Answers) != 1 || m.Answers[0].Name != "example.com." || m.Answers[0].TTL != 3600 || string(m.Answers[0].Data) != "\xc0\x00\x02\x01" {
+		t.Errorf("answers = %+v", m.Answers)
+	}
+	if !m.Recursion || m.Answers[0].Text != "192.0.2.1" || DNSRCodeName(m.RCode) != "NOERROR" {
+		t.Errorf("recursion=%v text=%q rcode=%s", m.Recursion, m.Answers[0].Text, DNSRCodeName(m.RCode))
+	}
+}
+
+func TestParseDNSResponse_RecordText(t *testing.T) {
+	// version.bind CH TXT answered with two strings, and an NS record
+	// whose name points back into the question.
+	pkt := []byte{
+		0xab, 0xcd, 0x84, 0x05, 0, 1, 0, 2, 0, 0, 0, 0,
+		7, 'v', 'e', 'r', 's', 'i', 'o', 'n', 4, 'b', 'i', 'n', 'd', 0, 0, 16, 0, 3,
+		0xc0, 12, 0, 16, 0, 3, 0, 0, 0, 0, 0, 9, 4, 'B', 'I', 'N', 'D', 3, ' ', '9', '!',
+		0xc0, 12, 0, 2, 0, 3, 0, 0, 0, 0, 0, 2, 0xc0, 20,
+	}
+	m, err := ParseDNSResponse(pkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Recursion || DNSRCodeName(m.RCode) != "REFUSED" || DNSRCodeName(9) != "RCODE9" {
+		t.Errorf("recursion=%v rcode=%s", m.Recursion, DNSRCodeName(m.RCode))
+	}
+	if len(m.Answers) != 2 || m.Answers[0].Text != "BIND 9!" || m.Answers[1].Text != "bind." {
+		t.Errorf("answers = %+v", m.Answers)
+	}
+	pkt[len(pkt)-18] = 9 // TXT string longer than its record
+	if m, err := ParseDNSResponse(pkt); err != nil || m.Answers[0].Text != "" {
+		t.Errorf("malformed TXT: %v, %+v", err, m.Answers)
+	}
+}
+
+func TestParseDNSResponse_Malformed(t *testing.T) {
+	mutate := func(f func(b []byte) []byte) []byte {
+		return f(append([]byte(nil), exampleResponse...))
+	}
+	cases := map[string][]byte{
+		"short header":     exampleResponse[:11],
+		"truncated rdata":  exampleResponse[:len(exampleResponse)-1],
+		"huge counts":      mutate(func(b []byte) []byte { b[6], b[7] = 0xff, 0xff; return b }),
+		"pointer to self":  mutate(func(b []byte) []byte { b[30] = 29; return b }),
+		"forward pointer":  mutate(func(b []byte) []byte { b[12], b[13] = 0xc0, 29; return b }),
+		"label past end":   mutate(func(b []byte) []byte { b[12] = 60; return b }),
+		"bad label type":   mutate(func(b []byte) []byte { b[12] = 0x47; return b }),
+		"rdlength too big": mutate(func(b []byte) []byte { b[40] = 200; return b }),
+	}
+	for name, pkt := range cases {
+		if _, err := ParseDNSResponse(pkt); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+
+	long := []byte{0, 0, 0x80, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	for i := 0; i < 5; i++ {
+		long = append(long, 63)
+		long = append(long, strings.Repeat("a", 63)...)
+	}
+	long = append(long, 0, 0, 1, 0, 1)
+	if _, err := ParseDNSResponse(long); err == nil {
+		t.Error("over-long name: no error")
+	}
+}
+
+func TestParseBanner(t *testing.T) {
+	cases := map[string]string{
+		"SSH-2.0-OpenSSH_9.6\r\n":          "SSH-2.0-OpenSSH_9.6",
+		"  220 mail ESMTP\r\n":             "220 mail ESMTP",
+		"a\x00b\x1b[31mc":                  "a.b.[31mc",
+		"caf\xc3\xa9 \xff\xfe":             "café ..",
+		"\x00\x00\x00\x01J\x00\x00\x008.0": "....J...8.0",
+		"":                                 "",
+	}
+	for in, want := range cases {
+		if got := ParseBanner([]byte(in)); got != want {
+			t.Errorf("ParseBanner(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := ParseBanner([]byte(strings.Repeat("x", MaxBanner+100))); len(got) != MaxBanner {
+		t.Errorf("len = %d, want %d", len(got), MaxBanner)
+	}
+}
+
+func FuzzParseDNSResponse(f *testing.F) {
+	f.Add(exampleResponse)
+	f.Add(exampleResponse[:20])
+	f.Add([]byte{0, 0, 0x80, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0xc0, 12, 0, 1, 0, 1})
+	f.Fuzz(func(t *testing.T, pkt []byte) {
+		m, err := ParseDNSResponse(pkt)
+		if err != nil {
+			return
+		}
+		for _, q := range m.Questions {
+			if len(q.Name) > maxDNSName+1 {
+				t.Fatalf("name of %d bytes", len(q.Name))
+			}
+		}
+		for _, rr := range m.Answers {
+			if len(rr.Data) > len(pkt) {
+				t.Fatalf("rdata of %d bytes from a %d byte packet", len(rr.Data), len(pkt))
+			}
+		}
+	})
+}
+
+func FuzzParseBanner(f *testing.F) {
+	f.Add([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+	f.Add([]byte("HTTP/1.1 200 OK\r\nServer: nginx\r\n\r\n"))
+	f.Add([]byte{0x16, 0x03, 0x01, 0x00, 0xff, 0xc3})
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		s := ParseBanner(raw)
+		if !utf8.ValidString(s) {
+			t.Fatalf("invalid UTF-8: %q", s)
+		}
+		for _, r := range s {
+			if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0x7f {
+				t.Fatalf("control character %U in %q", r, s)
+			}
+		}
+		if s != strings.TrimSpace(s) {
+			t.Fatalf("untrimmed: %q", s)
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package yaml
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	v, err := Parse("a:\n  b: [1, 'x y', \"z\"]\n  c:\n  - d: true\n    e: null\n  - plain # note\nf: ~\ng: {h: 1, 'i': x, j: [2, 3]}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := v.(map[string]any)
+	a := m["a"].(map[string]any)
+	if b := a["b"].([]any); len(b) != 3 || b[1] != "x y" || b[2] != "z" {
+		t.Errorf("unexpected flow sequence %#v", a["b"])
+	}
+	c := a["c"].([]any)
+	if item := c[0].(map[string]any); item["d"] != true || item["e"] != nil || c[1] != "plain" {
+		t.Errorf("unexpected sequence %#v", c)
+	}
+	if _, ok := m["f"]; !ok || m["f"] != nil {
+		t.Errorf("want f null, got %#v", m["f"])
+	}
+	if g := m["g"].(map[string]any); g["h"] != "1" || g["i"] != "x" || len(g["j"].([]any)) != 2 {
+		t.Errorf("unexpected flow mapping %#v", g)
+	}
+	for _, bad := range []string{"a:\n    b: 1\n  c: 2\n", "a: |\n  text\n", "a:\n\tb: 1\n"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("want an error for %q", bad)
+		}
+	}
+}